@@ -0,0 +1,401 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/diskspace"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// errChecksFailed indicates that one or more pre-flight checks performed by
+// the check subcommand failed.
+var errChecksFailed = errors.New("pre-flight checks failed")
+
+// checkResult is the outcome of a single pre-flight check.
+type checkResult struct {
+
+	// check is a brief description of the check performed
+	check string
+
+	// target is the path (or other value) checked
+	target string
+
+	// err is the reason the check failed, if it failed
+	err error
+
+	// skipped is the reason the check was skipped, if it was skipped
+	skipped string
+}
+
+// checkSubcommand is a wrapper around the "check" subcommand logic. The
+// flags of the checked subcommand have already been parsed and validated;
+// deeper pre-flight checks (paths readable, output directories writable,
+// backup destination capacity) are performed without running the
+// subcommand, allowing scheduled job definitions to be validated.
+func checkSubcommand(appConfig *config.Config) error {
+
+	var results []checkResult
+
+	results = append(results, checkResult{
+		check:  "flags valid",
+		target: appConfig.Subcommand,
+	})
+
+	results = append(results, checkPaths(appConfig)...)
+	results = append(results, checkInputFiles(appConfig)...)
+	results = append(results, checkOutputFiles(appConfig)...)
+	results = append(results, checkBackupDestination(appConfig)...)
+
+	failed := printCheckResults(os.Stdout, results)
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errChecksFailed, failed, len(results))
+	}
+
+	fmt.Printf("All %d checks passed; the %s subcommand is ready to run.\n", len(results), appConfig.Subcommand)
+
+	return nil
+}
+
+// checkPaths confirms that each local path to evaluate is a readable
+// directory. Amazon S3 locations are not checked.
+func checkPaths(appConfig *config.Config) []checkResult {
+
+	results := make([]checkResult, 0, len(appConfig.Paths))
+
+	for _, path := range appConfig.Paths {
+		result := checkResult{check: "path readable", target: path}
+
+		switch {
+		case s3.IsURL(path):
+			result.skipped = "Amazon S3 locations are not checked"
+		default:
+			result.err = checkReadableDir(path)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// checkInputFiles confirms that each input file read by the subcommand
+// exists and is readable.
+func checkInputFiles(appConfig *config.Config) []checkResult {
+
+	var results []checkResult
+
+	for _, patterns := range [][]string{appConfig.InputCSVFiles, appConfig.InputFdupesFiles} {
+		if len(patterns) == 0 {
+			continue
+		}
+
+		files, err := inputCSVFiles(patterns)
+		if err != nil {
+			results = append(results, checkResult{check: "input file readable", target: fmt.Sprint(patterns), err: err})
+			continue
+		}
+
+		for _, file := range files {
+			results = append(results, checkResult{check: "input file readable", target: file, err: checkReadableFile(file)})
+		}
+	}
+
+	inputFiles := []string{appConfig.PreviousCSVFile, appConfig.BaselineFile}
+	if appConfig.PathsFrom != "" && appConfig.PathsFrom != "-" {
+		inputFiles = append(inputFiles, appConfig.PathsFrom)
+	}
+
+	// The audit log is read (instead of appended to) when verifying backups.
+	if appConfig.Subcommand == config.VerifyBackupSubcommand {
+		inputFiles = append(inputFiles, appConfig.AuditLogFile)
+	}
+
+	// The journal is read (instead of appended to) when undoing a prune run.
+	if appConfig.Subcommand == config.UndoSubcommand {
+		inputFiles = append(inputFiles, appConfig.JournalFile)
+	}
+
+	for _, file := range inputFiles {
+		if file == "" {
+			continue
+		}
+		results = append(results, checkResult{check: "input file readable", target: file, err: checkReadableFile(file)})
+	}
+
+	return results
+}
+
+// checkOutputFiles confirms that the directory of each file generated by
+// the subcommand is writable.
+func checkOutputFiles(appConfig *config.Config) []checkResult {
+
+	outputFiles := []string{
+		appConfig.OutputCSVFile,
+		appConfig.ExcelFile,
+		appConfig.NDJSONFile,
+		appConfig.DirSummaryCSVFile,
+		appConfig.ExtSummaryCSVFile,
+		appConfig.SmallFilesCSVFile,
+		appConfig.SummaryFile,
+		appConfig.ErrorsCSVFile,
+		appConfig.SnapshotFile,
+		appConfig.NearDuplicatesCSVFile,
+		appConfig.EXIFMatchesCSVFile,
+		appConfig.RelatedFilesCSVFile,
+		appConfig.SimilarFilesCSVFile,
+		appConfig.PlanFile,
+		appConfig.ScriptFile,
+		appConfig.RemoveListFile,
+	}
+
+	// The audit log and journal are appended to by the prune subcommand.
+	if appConfig.Subcommand == config.PruneSubcommand {
+		outputFiles = append(outputFiles, appConfig.AuditLogFile, appConfig.JournalFile)
+	}
+
+	var results []checkResult
+
+	seen := make(map[string]bool)
+	for _, file := range outputFiles {
+		if file == "" {
+			continue
+		}
+
+		dir := filepath.Dir(filepath.Clean(file))
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		results = append(results, checkResult{check: "output directory writable", target: dir, err: checkWritableDir(dir)})
+	}
+
+	return results
+}
+
+// checkBackupDestination confirms that the backup directory (or the
+// directory of the backup archive) used by the prune subcommand is writable
+// and has enough free space for the files flagged for removal in the input
+// CSV files. The backup directory used by scripts generated by the report
+// subcommand is only checked for existence since the script is run
+// elsewhere.
+func checkBackupDestination(appConfig *config.Config) []checkResult {
+
+	destination := appConfig.BackupDirectory
+	if appConfig.BackupArchive != "" {
+		destination = filepath.Dir(filepath.Clean(appConfig.BackupArchive))
+	}
+
+	if destination == "" {
+		return nil
+	}
+
+	if s3.IsURL(destination) {
+		return []checkResult{{
+			check:   "backup destination writable",
+			target:  destination,
+			skipped: "Amazon S3 locations are not checked",
+		}}
+	}
+
+	if appConfig.Subcommand != config.PruneSubcommand {
+		result := checkResult{check: "backup directory exists", target: destination}
+		if !paths.PathExists(destination) {
+			result.err = fmt.Errorf("%w: %q", paths.ErrPathNotExist, destination)
+		}
+		return []checkResult{result}
+	}
+
+	// A missing backup directory is created if requested, so check the
+	// closest existing parent directory instead.
+	existing := destination
+	if appConfig.CreateBackupDir {
+		for !paths.PathExists(existing) && filepath.Dir(existing) != existing {
+			existing = filepath.Dir(existing)
+		}
+	}
+
+	writable := checkResult{check: "backup destination writable", target: existing, err: checkWritableDir(existing)}
+	if writable.err != nil {
+		return []checkResult{writable}
+	}
+
+	capacity := checkResult{check: "backup destination capacity", target: existing}
+
+	required, err := backupSizeRequired(appConfig)
+	if err != nil {
+		capacity.err = err
+		return []checkResult{writable, capacity}
+	}
+
+	free, err := diskspace.Free(existing)
+	switch {
+	case errors.Is(err, diskspace.ErrUnsupported):
+		capacity.skipped = err.Error()
+	case err != nil:
+		capacity.err = err
+	case uint64(required) > free:
+		capacity.err = fmt.Errorf(
+			"%s required for files flagged for removal, %s free",
+			units.ByteCountIEC(required),
+			units.ByteCountIEC(int64(free)), // #nosec G115; free space is far below the int64 limit
+		)
+	default:
+		capacity.target = fmt.Sprintf(
+			"%s (%s required, %s free)",
+			existing,
+			units.ByteCountIEC(required),
+			units.ByteCountIEC(int64(free)), // #nosec G115; free space is far below the int64 limit
+		)
+	}
+
+	return []checkResult{writable, capacity}
+}
+
+// backupSizeRequired returns the total size of the files flagged for
+// removal (and so backed up first) in the input CSV files. Files listed
+// without a recorded size are sized using their current size, if found.
+func backupSizeRequired(appConfig *config.Config) (int64, error) {
+
+	inputFiles, err := inputCSVFiles(appConfig.InputCSVFiles)
+	if err != nil {
+		return 0, err
+	}
+
+	var required int64
+	for _, inputFile := range inputFiles {
+		_, err := parseInputCSVFile(inputFile, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, _ int) error {
+			if appConfig.RemoveUnkept {
+				dfsEntry.RemoveFile = dfsEntry.KeepRecorded && !dfsEntry.Keep
+			}
+
+			if !dfsEntry.RemoveFile {
+				return nil
+			}
+
+			size := dfsEntry.SizeInBytes
+			if size == 0 {
+				if info, err := os.Lstat(filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)); err == nil {
+					size = info.Size()
+				}
+			}
+			required += size
+
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return required, nil
+}
+
+// checkReadableDir confirms that the specified directory can be listed.
+func checkReadableDir(path string) error {
+
+	dir, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := dir.Close(); err != nil {
+			log.Printf("error occurred closing directory %q: %v", path, err)
+		}
+	}()
+
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// checkReadableFile confirms that the specified file can be read.
+func checkReadableFile(path string) error {
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("error occurred closing file %q: %v", path, err)
+		}
+	}()
+
+	buf := make([]byte, 1)
+	if _, err := file.Read(buf); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// checkWritableDir confirms that files can be created within the specified
+// directory by creating (and removing) a temporary file.
+func checkWritableDir(path string) error {
+
+	file, err := os.CreateTemp(path, ".bridge-check-*")
+	if err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(file.Name())
+}
+
+// printCheckResults writes the outcome of each check to the provided writer,
+// returning the number of failed checks.
+func printCheckResults(w io.Writer, results []checkResult) int {
+
+	tw := &tabwriter.Writer{}
+	tw.Init(w, 8, 8, 2, ' ', 0)
+
+	var failed int
+
+	_, _ = fmt.Fprintln(tw, "\nCheck\tTarget\tResult")
+	for _, result := range results {
+		outcome := "ok"
+		switch {
+		case result.err != nil:
+			outcome = "FAILED: " + result.err.Error()
+			failed++
+		case result.skipped != "":
+			outcome = "skipped: " + result.skipped
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", result.check, result.target, outcome)
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	if err := tw.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+
+	return failed
+}
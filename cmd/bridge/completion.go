@@ -0,0 +1,57 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/completion"
+	"github.com/atc0005/bridge/internal/config"
+)
+
+// completionSubcommand is a wrapper around the "completion" subcommand logic.
+// The generated script is written to stdout so that it can be sourced
+// directly or redirected to a file.
+func completionSubcommand(appConfig *config.Config) error {
+
+	flagSets := config.SubcommandFlagSets()
+	commands := make([]completion.Command, 0, len(flagSets))
+
+	for _, flagSet := range flagSets {
+
+		cmd := completion.Command{
+			Name:        flagSet.Name(),
+			Description: config.SubcommandDescription(flagSet.Name()),
+		}
+
+		flagSet.VisitAll(func(f *flag.Flag) {
+			cmd.Flags = append(cmd.Flags, completion.Flag{
+				Name:  f.Name,
+				Usage: f.Usage,
+			})
+		})
+
+		if cmd.Name == config.CompletionSubcommand {
+			cmd.Args = completion.SupportedShells()
+		}
+
+		commands = append(commands, cmd)
+	}
+
+	// Offer completions for the binary name as it was invoked, minus any
+	// extension (e.g., ".exe") since that is handled by the script itself.
+	appName := strings.TrimSuffix(
+		filepath.Base(os.Args[0]),
+		filepath.Ext(os.Args[0]),
+	)
+
+	return completion.Write(os.Stdout, appConfig.CompletionShell, appName, commands)
+}
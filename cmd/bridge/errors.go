@@ -0,0 +1,58 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+)
+
+// recordChangedFiles records the provided files (skipped as they changed
+// since they were found) in the errors report, returning the number of
+// files recorded.
+func recordChangedFiles(changed ...matches.FileMatches) int {
+
+	var count int
+
+	for _, fileMatches := range changed {
+		for _, file := range fileMatches {
+			errreport.RecordSkip(
+				file.FullPath,
+				errreport.OperationHash,
+				errreport.ClassChanged,
+				"size or modification time changed since the file was found",
+			)
+			count++
+		}
+	}
+
+	return count
+}
+
+// reportErrors summarizes the errors ignored and files skipped during the
+// run to the console and, if requested, writes them to the errors CSV file.
+// The CSV file is generated even if no errors were recorded so that
+// automation can rely on its presence.
+func reportErrors(appConfig *config.Config) {
+
+	errreport.Print(os.Stdout, appConfig.ErrorsCSVFile)
+
+	if appConfig.ErrorsCSVFile == "" {
+		return
+	}
+
+	if err := errreport.WriteCSV(appConfig.ErrorsCSVFile); err != nil {
+		log.Printf("Failed to create errors CSV file %q: %v", appConfig.ErrorsCSVFile, err)
+		return
+	}
+	log.Printf("Successfully created errors CSV file: %q", appConfig.ErrorsCSVFile)
+}
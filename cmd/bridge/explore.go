@@ -0,0 +1,226 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// exploreBarWidth is the width (in characters) of the bar showing the share
+// of wasted space of each listed subdirectory.
+const exploreBarWidth = 20
+
+// exploreFilesLimit is the maximum number of duplicate files listed
+// individually for a directory.
+const exploreFilesLimit = 10
+
+// exploreSubcommand is a wrapper around the "explore" subcommand logic. The
+// space wasted by duplicate files is aggregated per directory, either from
+// previously generated CSV reports or a new scan, and presented as a
+// navigable tree so that users can drill into where wasted space actually
+// lives before deciding what to prune.
+func exploreSubcommand(appConfig *config.Config) error {
+
+	var fileChecksumIndex matches.FileChecksumIndex
+	var err error
+
+	switch {
+	case appConfig.Paths != nil:
+		fileChecksumIndex, err = exploreScan(appConfig)
+	default:
+		fileChecksumIndex, err = exploreCSVFiles(appConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(fileChecksumIndex) == 0 {
+		fmt.Println("No duplicate files found; nothing to explore.")
+		return nil
+	}
+
+	return exploreWasteTree(os.Stdin, os.Stdout, matches.NewWasteTree(fileChecksumIndex))
+}
+
+// exploreCSVFiles returns the duplicate file sets listed in the requested
+// CSV files, using the keep column to determine the file kept from each
+// set.
+func exploreCSVFiles(appConfig *config.Config) (matches.FileChecksumIndex, error) {
+
+	inputFiles, err := inputCSVFiles(appConfig.InputCSVFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var dfsEntries dupesets.DuplicateFileSetEntries
+	for _, inputFile := range inputFiles {
+		_, err := parseInputCSVFile(inputFile, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, _ int) error {
+			dfsEntries = append(dfsEntries, dfsEntry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dfsEntries.FileChecksumIndex(), nil
+}
+
+// exploreScan evaluates the requested paths for duplicate files, suggesting
+// the file to keep from each set using the requested keep policy.
+func exploreScan(appConfig *config.Config) (matches.FileChecksumIndex, error) {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fileSizeIndex, _, err := matches.NewFileSizeIndex(
+		appConfig.RecursiveSearch,
+		appConfig.IgnoreErrors,
+		appConfig.FileSizeThreshold,
+		1,
+		dirExcludes(appConfig),
+		appConfig.Paths...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate paths: %w", err)
+	}
+
+	fileSizeIndex.PruneFileSizeIndex(appConfig.FileDuplicatesThreshold)
+	log.Printf("Generating checksums for %d files", fileSizeIndex.GetTotalFilesCount())
+
+	fileChecksumIndex, _, err := fileSizeIndex.ConfirmDuplicates(
+		ctx,
+		appConfig.IgnoreErrors,
+		appConfig.FileDuplicatesThreshold,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate checksums: %w", err)
+	}
+
+	fileChecksumIndex.UpdateKeeps(appConfig.KeepRule())
+
+	return fileChecksumIndex, nil
+}
+
+// exploreWasteTree lists the subdirectories and duplicate files of the
+// current directory of the tree, reading commands from the provided reader
+// to open a subdirectory (by number), go back up (..) or quit (q). Reading
+// stops once the reader is exhausted.
+func exploreWasteTree(r io.Reader, w io.Writer, top *matches.WasteNode) error {
+
+	scanner := bufio.NewScanner(r)
+	current := top
+
+	for {
+		printWasteNode(w, current)
+
+		_, _ = fmt.Fprint(w, "Enter a number to open a directory, .. to go up or q to quit: ")
+		if !scanner.Scan() {
+			_, _ = fmt.Fprintln(w)
+			return scanner.Err()
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		switch input {
+		case "q", "quit", "exit":
+			return nil
+		case "..", "u", "up":
+			if current.Parent == nil {
+				_, _ = fmt.Fprintln(w, "\nAlready at the top directory.")
+				continue
+			}
+			current = current.Parent
+		case "":
+			// Redraw the current directory.
+		default:
+			index, err := strconv.Atoi(input)
+			if err != nil || index < 1 || index > len(current.Children) {
+				_, _ = fmt.Fprintf(w, "\nInvalid selection %q.\n", input)
+				continue
+			}
+			current = current.Children[index-1]
+		}
+	}
+}
+
+// printWasteNode writes the wasted space of the provided directory along
+// with a bar chart of its subdirectories and its largest duplicate files.
+func printWasteNode(w io.Writer, node *matches.WasteNode) {
+
+	tw := &tabwriter.Writer{}
+	tw.Init(w, 8, 8, 2, ' ', 0)
+
+	path := node.Path
+	if path == "" {
+		path = "(all paths)"
+	}
+
+	_, _ = fmt.Fprintf(tw, "\n%s\n", path)
+	_, _ = fmt.Fprintf(tw, "%s wasted by %d duplicate files\n\n",
+		units.ByteCountIEC(node.WastedSpace),
+		node.DuplicateFiles,
+	)
+
+	for i, child := range node.Children {
+		_, _ = fmt.Fprintf(tw, "%d)\t%s\t[%s]\t%d files\t%s%c\n",
+			i+1,
+			units.ByteCountIEC(child.WastedSpace),
+			wasteBar(child.WastedSpace, node.WastedSpace),
+			child.DuplicateFiles,
+			child.Name(),
+			os.PathSeparator,
+		)
+	}
+
+	for i, file := range node.Files {
+		if i == exploreFilesLimit {
+			_, _ = fmt.Fprintf(tw, "\t(%d more duplicate files not shown)\n", len(node.Files)-exploreFilesLimit)
+			break
+		}
+		_, _ = fmt.Fprintf(tw, "\t%s\t[%s]\t\t%s\n",
+			units.ByteCountIEC(file.Allocated()),
+			wasteBar(file.Allocated(), node.WastedSpace),
+			file.Name(),
+		)
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	if err := tw.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// wasteBar returns a bar representing the share of the total wasted space.
+func wasteBar(wasted int64, total int64) string {
+
+	filled := 0
+	if total > 0 {
+		filled = int(wasted * exploreBarWidth / total)
+	}
+
+	return strings.Repeat("#", filled) + strings.Repeat(" ", exploreBarWidth-filled)
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// fdupesSizeLine matches the line preceding each group of files in output
+// generated using the size option of fdupes or jdupes (e.g., "1024 bytes
+// each:").
+var fdupesSizeLine = regexp.MustCompile(`^\d+ bytes? each:$`)
+
+// fdupesFile is a file listed in fdupes (or jdupes) output along with the
+// line number it is listed on.
+type fdupesFile struct {
+	lineNum int
+	path    string
+}
+
+// fdupesGroup is a group of duplicate files listed in fdupes (or jdupes)
+// output along with the line number of the first file in the group.
+type fdupesGroup struct {
+	lineNum int
+	files   []fdupesFile
+}
+
+// readFdupesGroups parses the specified file containing the output of fdupes
+// or jdupes (without the same line option), where each group of duplicate
+// files is listed one path per line and groups are separated by a blank
+// line. Relative paths are resolved using the current working directory.
+func readFdupesGroups(filename string) ([]fdupesGroup, error) {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input fdupes file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	var groups []fdupesGroup
+	var current fdupesGroup
+
+	scanner := bufio.NewScanner(file)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case line == "":
+			if len(current.files) > 0 {
+				groups = append(groups, current)
+			}
+			current = fdupesGroup{}
+
+		case fdupesSizeLine.MatchString(line):
+			continue
+
+		default:
+			path, err := filepath.Abs(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d of input fdupes file %q has invalid path: %w", lineNum, filename, err)
+			}
+
+			if len(current.files) == 0 {
+				current.lineNum = lineNum
+			}
+			current.files = append(current.files, fdupesFile{lineNum: lineNum, path: path})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input fdupes file %q: %w", filename, err)
+	}
+
+	if len(current.files) > 0 {
+		groups = append(groups, current)
+	}
+
+	return groups, nil
+}
+
+// validateFdupesGroups validates the provided groups parsed from the
+// specified fdupes (or jdupes) output file (see readFdupesGroups), returning
+// an entry for each listed file. The first file in each group is kept and
+// the remaining files are flagged for removal. Since fdupes output does not
+// record checksums, a checksum is generated for each file and every file in
+// a group is confirmed to match the kept file before being flagged. Files
+// which no longer exist are returned separately if the user opted to skip
+// them.
+func validateFdupesGroups(filename string, groups []fdupesGroup, appConfig *config.Config) (dupesets.DuplicateFileSetEntries, []staleRow, error) {
+
+	var dfsEntries dupesets.DuplicateFileSetEntries
+	var staleRows []staleRow
+
+	for groupIndex, group := range groups {
+
+		setID := strconv.Itoa(groupIndex + 1)
+
+		var kept *dupesets.DuplicateFileSetEntry
+		for _, file := range group.files {
+
+			path, lineNum := file.path, file.lineNum
+
+			dfsEntry := dupesets.DuplicateFileSetEntry{
+				ParentDirectory: filepath.Dir(path),
+				Filename:        filepath.Base(path),
+				SetID:           setID,
+				InputFile:       filename,
+				KeepRecorded:    true,
+			}
+
+			checksum, err := checksums.GenerateCheckSum(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist) && appConfig.SkipStale:
+				log.Printf("Skipping stale input line %d in %q: %v\n", lineNum, filename, err)
+				staleRows = append(staleRows, staleRow{
+					inputFile: filename,
+					rowNum:    lineNum,
+					entry:     dfsEntry,
+					err:       fmt.Errorf("%q: %w", path, dupesets.ErrPathNotFound),
+				})
+				continue
+
+			case err != nil:
+				log.Println("Error encountered generating checksum:", err)
+				if errreport.Ignore(appConfig.IgnoreErrors, err) {
+					log.Printf("IgnoringErrors set, ignoring input line %d.\n", lineNum)
+					errreport.Record(path, errreport.OperationHash, err)
+					continue
+				}
+				log.Println("IgnoringErrors NOT set. Exiting.")
+				return nil, nil, err
+			}
+			dfsEntry.Checksum = checksum
+
+			// The first file found in each group is kept.
+			switch {
+			case kept == nil:
+				dfsEntry.Keep = true
+
+			case checksum != kept.Checksum:
+				err := fmt.Errorf(
+					"content of %q differs from %q (group starting at line %d); files are not duplicates",
+					path,
+					filepath.Join(kept.ParentDirectory, kept.Filename),
+					group.lineNum,
+				)
+				log.Println("Error encountered validating fdupes group:", err)
+				if errreport.Ignore(appConfig.IgnoreErrors, err) {
+					log.Printf("IgnoringErrors set, ignoring input line %d.\n", lineNum)
+					errreport.Record(path, errreport.OperationValidate, err)
+					continue
+				}
+				log.Println("IgnoringErrors NOT set. Exiting.")
+				return nil, nil, err
+
+			default:
+				dfsEntry.RemoveFile = true
+			}
+
+			if err := dfsEntry.UpdateSizeInfo(); err != nil {
+				log.Println("Error encountered while attempting to update file size info:", err)
+				if errreport.Ignore(appConfig.IgnoreErrors, err) {
+					log.Printf("IgnoringErrors set, ignoring input line %d.\n", lineNum)
+					errreport.Record(path, errreport.OperationValidate, err)
+					continue
+				}
+				log.Println("IgnoringErrors NOT set. Exiting.")
+				return nil, nil, err
+			}
+
+			dfsEntries = append(dfsEntries, dfsEntry)
+			if dfsEntry.Keep {
+				kept = &dfsEntries[len(dfsEntries)-1]
+			}
+		}
+	}
+
+	log.Printf("Validated %d groups of duplicate files from input fdupes file %q\n", len(groups), filename)
+
+	return dfsEntries, staleRows, nil
+}
@@ -15,8 +15,42 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"github.com/atc0005/bridge/internal/checksums"
 	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// Exit codes returned by this application. These values are intended to
+// allow monitoring scripts and other automation to branch on results
+// without scraping console output.
+const (
+
+	// exitCodeSuccess indicates that the requested subcommand completed
+	// successfully. For the report subcommand this also indicates that no
+	// duplicate files were found.
+	exitCodeSuccess int = 0
+
+	// exitCodeDuplicatesFound indicates that the report subcommand completed
+	// successfully and found one or more sets of duplicate files.
+	exitCodeDuplicatesFound int = 1
+
+	// exitCodeConfigError indicates that the provided configuration was
+	// invalid or incomplete.
+	exitCodeConfigError int = 2
+
+	// exitCodeRuntimeError indicates that an error was encountered after
+	// configuration validation, such as failure to read an input file or to
+	// remove a flagged file.
+	exitCodeRuntimeError int = 3
+
+	// exitCodeBudgetExceeded indicates that the report subcommand completed
+	// successfully, but found more duplicate files or wasted space than the
+	// requested budget allows.
+	exitCodeBudgetExceeded int = 4
 )
 
 func main() {
@@ -43,26 +77,86 @@ func main() {
 		// sufficient enough
 		// if errors.Is(err, config.ErrMissingSubcommand) || errors.Is(err, flag.ErrHelp) {
 		if errors.Is(err, flag.ErrHelp) {
-			appExitCode = 0
+			appExitCode = exitCodeSuccess
 			return
 		}
 		fmt.Printf("\nERROR: %s\n", err)
-		appExitCode = 1
+		appExitCode = exitCodeConfigError
+		return
+	}
+
+	// The completion script is emitted to stdout, so skip all other output.
+	if os.Args[1] == config.CompletionSubcommand {
+		if err := completionSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Fprintln(os.Stderr, err)
+		}
 		return
 	}
 
+	// Apply the requested Unicode normalization form to paths throughout
+	// the application.
+	if appConfig.Normalization != "" {
+		paths.SetNormalization(appConfig.Normalization)
+	}
+
+	// Apply the requested tuning of file reads used to generate checksums.
+	if appConfig.ReadBufferSize != 0 {
+		checksums.SetReadBufferSize(appConfig.ReadBufferSize)
+	}
+	checksums.SetDropCache(appConfig.DropCache)
+
+	// Limit the errors ignored to the requested categories.
+	if categories := appConfig.IgnoredErrorCategories(); len(categories) > 0 {
+		errreport.SetIgnoredCategories(categories...)
+	}
+
+	// Apply the requested order of files within each duplicate file set.
+	if appConfig.FileOrder != "" {
+		matches.SetFileOrder(appConfig.FileOrder)
+	}
+
 	// DEBUG
-	log.Printf("Configuration: %+v\n", appConfig)
+	// Avoid logging secrets along with the other settings.
+	loggedConfig := *appConfig
+	if loggedConfig.APIToken != "" {
+		loggedConfig.APIToken = "REDACTED"
+	}
+	if loggedConfig.NotifyWebhook != "" {
+		// Webhook URLs commonly embed the credentials used to post to them.
+		loggedConfig.NotifyWebhook = "REDACTED"
+	}
+	if loggedConfig.PostURL != "" {
+		// Result URLs may carry credentials via user information or the
+		// query string.
+		loggedConfig.PostURL = "REDACTED"
+	}
+	if loggedConfig.NotifySMTPPassword != "" {
+		loggedConfig.NotifySMTPPassword = "REDACTED"
+	}
+	if loggedConfig.PostToken != "" {
+		loggedConfig.PostToken = "REDACTED"
+	}
+	if loggedConfig.PostHeaders != nil {
+		// Headers commonly carry API keys.
+		loggedConfig.PostHeaders = []string{"REDACTED"}
+	}
+	log.Printf("Configuration: %+v\n", &loggedConfig)
+
+	startTime := time.Now()
 
-	// behavior/logic switch between "prune" and "report" here
+	// behavior/logic switch between subcommands here
 	switch os.Args[1] {
 	case config.PruneSubcommand:
 
 		// DEBUG
 		fmt.Printf("subcommand '%s' called\n", config.PruneSubcommand)
 
-		if err := pruneSubcommand(appConfig); err != nil {
-			appExitCode = 1
+		summary, err := pruneSubcommand(appConfig)
+		reportErrors(appConfig)
+		sendNotification(appConfig, config.PruneSubcommand, summary, startTime, err)
+		if err != nil {
+			appExitCode = exitCodeRuntimeError
 			fmt.Println(err)
 			return
 		}
@@ -71,8 +165,126 @@ func main() {
 		// DEBUG
 		fmt.Printf("subcommand '%s' called\n", config.ReportSubcommand)
 
-		if err := reportSubcommand(appConfig); err != nil {
-			appExitCode = 1
+		summary, err := reportSubcommand(appConfig)
+		reportErrors(appConfig)
+		sendNotification(appConfig, config.ReportSubcommand, summary, startTime, err)
+		if err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+		// Let monitoring know that duplication has exceeded the budget.
+		if exceeded := budgetsExceeded(appConfig, summary); len(exceeded) > 0 {
+			for _, msg := range exceeded {
+				fmt.Println("Budget exceeded:", msg)
+			}
+			appExitCode = exitCodeBudgetExceeded
+			return
+		}
+
+		// Let automation know that there is (potentially) work to be done.
+		if summary.FileHashMatchSets > 0 {
+			appExitCode = exitCodeDuplicatesFound
+			return
+		}
+
+	case config.WatchSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.WatchSubcommand)
+
+		if err := watchSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.ServeSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.ServeSubcommand)
+
+		if err := serveSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.PurgeSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.PurgeSubcommand)
+
+		if err := purgeSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.VerifyBackupSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.VerifyBackupSubcommand)
+
+		if err := verifyBackupSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.UndoSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.UndoSubcommand)
+
+		if err := undoSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.StatsSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.StatsSubcommand)
+
+		if err := statsSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.MergeSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.MergeSubcommand)
+
+		if err := mergeSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.ExploreSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.ExploreSubcommand)
+
+		if err := exploreSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
+			fmt.Println(err)
+			return
+		}
+
+	case config.CheckSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.CheckSubcommand)
+
+		if err := checkSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeConfigError
+			fmt.Println(err)
+			return
+		}
+
+	case config.SelfTestSubcommand:
+		// DEBUG
+		fmt.Printf("subcommand '%s' called\n", config.SelfTestSubcommand)
+
+		if err := selfTestSubcommand(appConfig); err != nil {
+			appExitCode = exitCodeRuntimeError
 			fmt.Println(err)
 			return
 		}
@@ -80,7 +292,7 @@ func main() {
 	// We should not be able to reach this section
 	default:
 		log.Printf("invalid subcommand: %s", os.Args[1])
-		appExitCode = 1
+		appExitCode = exitCodeConfigError
 		return
 	}
 
@@ -0,0 +1,216 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/s3"
+)
+
+// mergedReports is the result of combining the rows of one or more
+// previously generated CSV reports.
+type mergedReports struct {
+
+	// index groups the files which still exist by checksum
+	index matches.FileChecksumIndex
+
+	// staleRows lists rows skipped since the file no longer exists or has
+	// changed size since the report was generated
+	staleRows []staleRow
+
+	// flagged is the number of rows flagged for removal in the input CSV
+	// files
+	flagged int
+
+	// ignoredErrors is the number of errors ignored (as requested)
+	ignoredErrors int
+}
+
+// mergeInputCSVFiles combines the rows of the specified CSV files, grouping
+// files by checksum across all files. Files listed more than once are only
+// included once. Byte comparison identifiers are only meaningful within the
+// report which assigned them, so sets identified this way are renumbered
+// and never combined with sets from other reports.
+func mergeInputCSVFiles(inputFiles []string, appConfig *config.Config) (mergedReports, error) {
+
+	merged := mergedReports{
+		index: make(matches.FileChecksumIndex),
+	}
+
+	seen := make(map[string]bool)
+	byteComparisonIDs := make(map[string]checksums.SHA256Checksum)
+	byteComparisonGroups := make(map[int64]int)
+
+	for _, inputFile := range inputFiles {
+		_, err := parseInputCSVFile(inputFile, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, rowNum int) error {
+
+			if dfsEntry.RemoveFile {
+				merged.flagged++
+			}
+
+			// S3 objects cannot be checked for existence without
+			// credentials, so they are not merged.
+			if s3.IsURL(dfsEntry.ParentDirectory) {
+				log.Printf(
+					"Skipping input row %d; S3 objects are not supported by the merge subcommand: %s/%s\n",
+					rowNum,
+					dfsEntry.ParentDirectory,
+					dfsEntry.Filename,
+				)
+				return nil
+			}
+
+			dfsEntry.ResolvePath()
+			fileFullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+			if seen[fileFullPath] {
+				return nil
+			}
+			seen[fileFullPath] = true
+
+			fileInfo, err := os.Stat(fileFullPath)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				merged.staleRows = append(merged.staleRows, staleRow{
+					inputFile: inputFile,
+					rowNum:    rowNum,
+					entry:     dfsEntry,
+					err:       fmt.Errorf("%q: %w", fileFullPath, dupesets.ErrPathNotFound),
+				})
+				return nil
+
+			case err != nil:
+				log.Println("Error encountered:", err)
+				if errreport.Ignore(appConfig.IgnoreErrors, err) {
+					log.Println("Ignoring error as requested")
+					merged.ignoredErrors++
+					return nil
+				}
+				return fmt.Errorf("unable to stat %q: %w", fileFullPath, err)
+
+			case dfsEntry.SizeInBytes != 0 && fileInfo.Size() != dfsEntry.SizeInBytes:
+				merged.staleRows = append(merged.staleRows, staleRow{
+					inputFile: inputFile,
+					rowNum:    rowNum,
+					entry:     dfsEntry,
+					err: fmt.Errorf(
+						"%q: %w; got %d bytes, expected %d bytes",
+						fileFullPath,
+						checksums.ErrSizeMismatch,
+						fileInfo.Size(),
+						dfsEntry.SizeInBytes,
+					),
+				})
+				return nil
+			}
+
+			checksum := dfsEntry.Checksum
+			if checksum.IsByteComparison() {
+				key := inputFile + "#" + string(checksum)
+				id, ok := byteComparisonIDs[key]
+				if !ok {
+					byteComparisonGroups[fileInfo.Size()]++
+					id = checksums.ByteComparisonID(fileInfo.Size(), byteComparisonGroups[fileInfo.Size()])
+					byteComparisonIDs[key] = id
+				}
+				checksum = id
+			}
+
+			// The input CSV file stands in for the evaluated path so that
+			// sets spanning more than one report (e.g., per-drive scans)
+			// are classified as found across roots.
+			fileMatch := matches.NewFileMatch(fileFullPath, fileInfo)
+			fileMatch.Checksum = checksum
+			fileMatch.Root = inputFile
+
+			merged.index[checksum] = append(merged.index[checksum], fileMatch)
+
+			return nil
+		})
+		if err != nil {
+			return merged, err
+		}
+	}
+
+	// Sets reduced to a single file by missing or modified files no longer
+	// reflect duplicate files.
+	merged.index.PruneFileChecksumIndex(2)
+
+	return merged, nil
+}
+
+// mergeSubcommand is a wrapper around the "merge" subcommand logic. The
+// rows of several previously generated CSV reports (e.g., per-drive scans)
+// are combined into a single CSV file for review, grouping files by checksum
+// across reports and dropping files which no longer exist.
+func mergeSubcommand(appConfig *config.Config) error {
+
+	inputFiles, err := inputCSVFiles(appConfig.InputCSVFiles)
+	if err != nil {
+		return err
+	}
+
+	merged, err := mergeInputCSVFiles(inputFiles, appConfig)
+	if err != nil {
+		return err
+	}
+
+	multipleReports := len(inputFiles) > 1
+
+	// Sets may now include files from several reports, so suggestions
+	// recorded in the input CSV files no longer apply.
+	merged.index.UpdateKeeps(appConfig.KeepRule())
+
+	stats := newReportStats(inputFiles, merged.index, nil)
+
+	printStaleRows(merged.staleRows)
+	printReportStats(stats, nil)
+
+	if merged.flagged > 0 {
+		// WARN
+		log.Printf(
+			"%d rows flagged for removal in input CSV files; "+
+				"flags are not carried over to the consolidated CSV file\n",
+			merged.flagged,
+		)
+	}
+
+	if merged.ignoredErrors > 0 {
+		// WARN
+		log.Printf("%d errors ignored as requested\n", merged.ignoredErrors)
+	}
+
+	reportColumns := matches.ReportColumns{
+		Scope: multipleReports,
+	}
+
+	if err := merged.index.WriteFileMatchesCSV(
+		appConfig.OutputCSVFile, appConfig.BlankLineBetweenSets, appConfig.SortOrder, reportColumns); err != nil {
+		return err
+	}
+	log.Printf("Successfully created CSV file: %q", appConfig.OutputCSVFile)
+
+	if appConfig.SummaryFile != "" {
+		if err := writeReportStats(appConfig.SummaryFile, stats); err != nil {
+			return err
+		}
+		log.Printf("Successfully created summary file: %q", appConfig.SummaryFile)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/notify"
+)
+
+// sendNotification posts a summary of the outcome of the specified
+// subcommand to the configured webhook and/or emails it, IF user requested
+// it. Failure to send a notification is logged but does not otherwise affect
+// the results of the subcommand.
+func sendNotification(
+	appConfig *config.Config,
+	subcommand string,
+	summary interface{},
+	startTime time.Time,
+	subcommandErr error,
+) {
+
+	opts := appConfig.NotifyOptions()
+	if !opts.Enabled() {
+		return
+	}
+
+	notification := notify.New(subcommand, summary, startTime, subcommandErr)
+	if err := notify.Send(opts, notification); err != nil {
+		// WARN
+		log.Println("Error encountered sending notification:", err)
+		return
+	}
+
+	log.Println("Successfully sent notification")
+}
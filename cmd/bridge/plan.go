@@ -0,0 +1,203 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/audit"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/script"
+)
+
+// planHeaderRow is the header row of the CSV file listing the changes that
+// the prune subcommand would make.
+var planHeaderRow = []string{
+	"action",
+	"path",
+	"size_in_bytes",
+	"checksum",
+	"set_id",
+	"backup_path",
+}
+
+// plannedBackupPath returns the location that the backup copy of the
+// specified file would be written to, or an empty string if backups were
+// not requested.
+func plannedBackupPath(appConfig *config.Config, filename string) (string, error) {
+
+	switch {
+	case s3.IsURL(appConfig.BackupDirectory):
+		bucket, prefix, err := s3.ParseURL(appConfig.BackupDirectory)
+		if err != nil {
+			return "", err
+		}
+
+		key, err := s3BackupKey(prefix, filename)
+		if err != nil {
+			return "", err
+		}
+		return s3.URL(bucket, key), nil
+
+	case appConfig.BackupDirectory != "":
+		return paths.PlannedBackupPath(filename, appConfig.BackupDirectory)
+
+	case appConfig.BackupArchive != "":
+		name, err := archive.MemberName(filename)
+		if err != nil {
+			return "", err
+		}
+		return appConfig.BackupArchive + "#" + name, nil
+
+	default:
+		return "", nil
+	}
+}
+
+// writePrunePlan writes the changes that the prune subcommand would make
+// (files to back up, then files to remove) to the specified CSV file so
+// that they can be reviewed or compared with a later run. Rows are listed in
+// the order that the changes would be made.
+func writePrunePlan(appConfig *config.Config, filename string, filesToRemove dupesets.DuplicateFileSetEntries) error {
+
+	var backupRows [][]string
+	var removeRows [][]string
+
+	for _, file := range filesToRemove {
+
+		fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+
+		backupPath, err := plannedBackupPath(appConfig, fullPathToFile)
+		if err != nil {
+			return err
+		}
+
+		row := func(action string) []string {
+			return []string{
+				action,
+				fullPathToFile,
+				strconv.FormatInt(file.SizeInBytes, 10),
+				file.Checksum.String(),
+				file.SetID,
+				backupPath,
+			}
+		}
+
+		if backupPath != "" {
+			backupRows = append(backupRows, row(audit.ActionBackup))
+		}
+		removeRows = append(removeRows, row(audit.ActionRemove))
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	csvWriter := csv.NewWriter(file)
+
+	rows := append([][]string{planHeaderRow}, backupRows...)
+	rows = append(rows, removeRows...)
+
+	if err := csvWriter.WriteAll(rows); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error writing plan to %q: %w", filename, err)
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error occurred syncing file %q: %w", filename, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing file %q: %w", filename, err)
+	}
+
+	return nil
+}
+
+// writePruneScript writes a script removing (and backing up, if a backup
+// directory was specified) the files flagged for removal, leaving removal
+// to the operator running the script. The files kept from each duplicate
+// file set are listed for review.
+func writePruneScript(appConfig *config.Config, inputFiles []string, dfsEntries dupesets.DuplicateFileSetEntries) error {
+
+	sw, err := script.NewWriter(
+		appConfig.ScriptFile,
+		appConfig.ScriptFormat,
+		appConfig.BackupDirectory,
+		"Removes the files flagged for removal in "+strings.Join(inputFiles, ", "),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Entries are sorted by input file and set ID, so the files of each set
+	// are listed together.
+	var keep []string
+	var remove []script.File
+	for i, entry := range dfsEntries {
+
+		fullPathToFile := filepath.Join(entry.ParentDirectory, entry.Filename)
+
+		if entry.RemoveFile {
+			remove = append(remove, script.File{
+				Path:     fullPathToFile,
+				Size:     entry.SizeInBytes,
+				Checksum: entry.Checksum.String(),
+			})
+		} else {
+			keep = append(keep, fullPathToFile)
+		}
+
+		last := i == len(dfsEntries)-1
+		if last || dfsEntries[i+1].SetID != entry.SetID || dfsEntries[i+1].InputFile != entry.InputFile {
+			setID := entry.SetID
+			if entry.InputFile != "" {
+				setID = fmt.Sprintf("%s in %q", entry.SetID, entry.InputFile)
+			}
+
+			if err := sw.WriteSet(setID, keep, remove); err != nil {
+				_ = sw.Close()
+				return err
+			}
+			keep, remove = nil, nil
+		}
+	}
+
+	return sw.Close()
+}
+
+// writeRemoveList writes the path to each file flagged for removal to the
+// requested list file (one per line or NUL-terminated), leaving removal to
+// other tools (e.g., xargs).
+func writeRemoveList(appConfig *config.Config, filesToRemove dupesets.DuplicateFileSetEntries) error {
+
+	lw, err := paths.NewListWriter(appConfig.RemoveListFile, appConfig.NullDelimited)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range filesToRemove {
+		if err := lw.Write(filepath.Join(file.ParentDirectory, file.Filename)); err != nil {
+			_ = lw.Close()
+			return err
+		}
+	}
+
+	return lw.Close()
+}
@@ -8,27 +8,166 @@
 package main
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/audit"
+	"github.com/atc0005/bridge/internal/checksums"
 	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/diskspace"
 	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/journal"
+	"github.com/atc0005/bridge/internal/matches"
 	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/progress"
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/units"
 )
 
-// pruneSubcommand is a wrapper around the "prune" subcommand logic
-func pruneSubcommand(appConfig *config.Config) error {
+// inputCSVFiles expands the provided input CSV file paths, treating paths
+// containing glob metacharacters as patterns. Files listed (or matched) more
+// than once are only returned once.
+func inputCSVFiles(patterns []string) ([]string, error) {
 
-	// DEBUG
-	fmt.Printf("subcommand '%s' called\n", config.PruneSubcommand)
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+
+		matches := []string{pattern}
+
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"invalid input CSV file pattern %q: %w",
+					pattern,
+					err,
+				)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf(
+					"no input CSV files match pattern %q",
+					pattern,
+				)
+			}
+		}
+
+		for _, file := range matches {
+			if seen[filepath.Clean(file)] {
+				continue
+			}
+			seen[filepath.Clean(file)] = true
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// staleRow is an input CSV row skipped since the file it lists no longer
+// exists or has changed since the report was generated.
+type staleRow struct {
+	inputFile string
+	rowNum    int
+	entry     dupesets.DuplicateFileSetEntry
+	err       error
+}
+
+// reason returns a brief description of why the row is stale.
+func (row staleRow) reason() string {
+	if errors.Is(row.err, dupesets.ErrPathNotFound) {
+		return "file no longer exists"
+	}
+
+	return "file modified since report was generated"
+}
+
+// printStaleRows writes a summary of the provided stale rows to stdout.
+func printStaleRows(staleRows []staleRow) {
+
+	if len(staleRows) == 0 {
+		return
+	}
+
+	var flagged int
+	for _, row := range staleRows {
+		if row.entry.RemoveFile {
+			flagged++
+		}
+	}
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Stale rows:")
+	_, _ = fmt.Fprintf(w, "%d\tstale rows skipped\n", len(staleRows))
+	_, _ = fmt.Fprintf(w, "%d\tstale rows flagged for removal\n", flagged)
+	_, _ = fmt.Fprintln(w)
 
-	file, err := os.Open(appConfig.InputCSVFile)
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+		"Input file",
+		"Row",
+		dupesets.TabWriterFileColumnHeaderName,
+		dupesets.TabWriterRemoveFileColumnHeaderName,
+		"Reason",
+	)
+	for _, row := range staleRows {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%t\t%s\n",
+			row.inputFile,
+			row.rowNum,
+			filepath.Join(row.entry.ParentDirectory, row.entry.Filename),
+			row.entry.RemoveFile,
+			row.reason(),
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// parseInputCSVFile parses the specified input CSV file (generated by the
+// report subcommand), calling fn with the entry parsed from each row along
+// with the row number. Rows which cannot be parsed are skipped if the user
+// opted to ignore errors. The columns located using the header row are
+// returned. Excel workbooks generated by the report subcommand are also
+// accepted (see parseInputWorkbook).
+func parseInputCSVFile(
+	filename string,
+	appConfig *config.Config,
+	fn func(dfsEntry dupesets.DuplicateFileSetEntry, rowNum int) error,
+) (dupesets.InputColumns, error) {
+
+	if isWorkbook(filename) {
+		return parseInputWorkbook(filename, appConfig, fn)
+	}
+
+	file, err := os.Open(filepath.Clean(filename))
 	if err != nil {
-		log.Fatal(err)
+		return dupesets.InputColumns{}, fmt.Errorf(
+			"failed to open input CSV file %q: %w",
+			filename,
+			err,
+		)
 	}
 
 	// #nosec G307
@@ -41,24 +180,53 @@ func pruneSubcommand(appConfig *config.Config) error {
 		if err := file.Close(); err != nil {
 			log.Printf(
 				"error occurred closing file %q: %v",
-				appConfig.InputCSVFile,
+				filename,
 				err,
 			)
 		}
 	}()
 
-	csvReader := csv.NewReader(file)
+	// Files generated by current versions of this application begin with a
+	// schema comment line.
+	bufReader := bufio.NewReader(file)
+	schemaVersion, commentLines, err := matches.ReadCSVSchemaVersion(bufReader)
+	if err != nil {
+		return dupesets.InputColumns{}, fmt.Errorf("failed to read input CSV file %q: %w", filename, err)
+	}
+
+	if schemaVersion > matches.CSVSchemaVersion {
+		// WARN
+		log.Printf(
+			"Input CSV file %q uses schema version %d (newer than the supported version %d); "+
+				"attempting to read known columns using the header row\n",
+			filename,
+			schemaVersion,
+			matches.CSVSchemaVersion,
+		)
+	}
 
-	// Require that the number of fields found matches what we expect to find
-	csvReader.FieldsPerRecord = config.InputCSVFieldCount
+	csvReader := csv.NewReader(bufReader)
+
+	// Rows of hand-edited files may have more (e.g., notes added in a
+	// trailing column) or fewer (e.g., trailing empty fields trimmed) fields
+	// than the header row; ParseInputRow enforces the minimum field count.
+	csvReader.FieldsPerRecord = -1
+
+	// Tolerate stray quotes (e.g., in a note added by hand) instead of
+	// rejecting the file.
+	csvReader.LazyQuotes = true
 
 	// TODO: Even with this set, we should probably still trim whitespace
 	// ourselves so that we can be assured that leading AND trailing
 	// whitespace has been removed
 	csvReader.TrimLeadingSpace = true
 
-	var dfsEntries dupesets.DuplicateFileSetEntries
-	var rowCounter = 0
+	// Columns are located using the header row if present, otherwise the
+	// fixed layout of files generated by older versions is assumed.
+	columns := dupesets.LegacyInputColumns
+	headerRow := commentLines + 1
+
+	var rowCounter = commentLines
 	for {
 
 		// Go ahead and bump the counter to reflect that humans start counting
@@ -70,189 +238,1423 @@ func pruneSubcommand(appConfig *config.Config) error {
 			break
 		}
 		if err != nil {
-			return err
+			return columns, fmt.Errorf("failed to read input CSV file %q: %w", filename, err)
+		}
+
+		// Spreadsheet applications save blank lines (e.g., those separating
+		// duplicate file sets) as rows of empty fields.
+		if isEmptyRow(record) {
+			continue
 		}
 
 		// If we are currently evaluating the very first line of the CSV file
 		// and the user did not override the default option of skipping the
 		// first row (due to it usually being the header row)
-		if rowCounter == 1 {
+		if rowCounter == headerRow {
 			if !appConfig.UseFirstRow {
 				// DEBUG
-				log.Println("Skipping first row in input file to avoid processing column headers")
+				log.Printf("Skipping first row in input file %q to avoid processing column headers\n", filename)
+
+				headerColumns, err := dupesets.NewInputColumns(record)
+				switch {
+				case err != nil:
+					// WARN
+					log.Printf(
+						"Unable to use header row of input file %q (%v); assuming the default column layout\n",
+						filename,
+						err,
+					)
+				default:
+					columns = headerColumns
+				}
+
 				continue
 			}
-			log.Println("Attempting to parse row 1 from input CSV file as requested")
+			log.Printf("Attempting to parse row %d from input CSV file %q as requested\n", rowCounter, filename)
 		}
 
-		dfsEntry, err := dupesets.ParseInputRow(record, config.InputCSVFieldCount, rowCounter)
+		dfsEntry, err := dupesets.ParseInputRow(record, columns, rowCounter)
 		if err != nil {
-			log.Println("Error encountered parsing CSV file:", err)
-			if appConfig.IgnoreErrors {
+			log.Printf("Error encountered parsing CSV file %q: %v\n", filename, err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
 				log.Printf("IgnoringErrors set, ignoring input row %d.\n", rowCounter)
+				errreport.Record(filename, errreport.OperationParse, fmt.Errorf("row %d: %w", rowCounter, err))
 				continue
 			}
 			log.Println("IgnoringErrors NOT set. Exiting.")
-			return err
+			return columns, err
+		}
+		dfsEntry.InputFile = filename
+
+		if err := fn(dfsEntry, rowCounter); err != nil {
+			return columns, err
+		}
+	}
+
+	return columns, nil
+}
+
+// readInputCSVRows parses the specified input CSV file, returning a row for
+// each listed file (not just those flagged for removal). Rows are not
+// validated; see validateInputCSVRows.
+func readInputCSVRows(filename string, appConfig *config.Config) ([]inputRow, error) {
+
+	var rows []inputRow
+
+	columns, err := parseInputCSVFile(filename, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, rowCounter int) error {
+
+		// Files not marked to be kept are removed if requested; rows
+		// without a keep value are always kept.
+		if appConfig.RemoveUnkept {
+			dfsEntry.RemoveFile = dfsEntry.KeepRecorded && !dfsEntry.Keep
+		}
+
+		// S3 objects may be listed in reports, but are not pruned
+		if s3.IsURL(dfsEntry.ParentDirectory) {
+			log.Printf(
+				"Skipping input row %d; S3 objects are not supported by the prune subcommand: %s/%s\n",
+				rowCounter,
+				dfsEntry.ParentDirectory,
+				dfsEntry.Filename,
+			)
+			return nil
+		}
+
+		// Reports generated with relative paths are resolved against the
+		// location the evaluated tree is found at on this system.
+		if err := dfsEntry.ResolveRoot(appConfig.Root); err != nil {
+			return fmt.Errorf("row %d in %q: %w", rowCounter, filename, err)
 		}
 
-		// validate input row before we consider it OK
-		if err = dupesets.ValidateInputRow(dfsEntry, rowCounter); err != nil {
+		// Paths recorded on another system may use a different Unicode
+		// normalization form than the local filesystem.
+		dfsEntry.ResolvePath()
+
+		rows = append(rows, inputRow{entry: dfsEntry, rowNum: rowCounter})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.RemoveUnkept && columns.Keep == -1 {
+		return nil, fmt.Errorf(
+			"input CSV file %q has no %s column; unable to determine which files to remove",
+			filename,
+			matches.CSVKeepColumnHeaderName,
+		)
+	}
+
+	return rows, nil
+}
+
+// validateInputCSVRows validates the provided rows parsed from input CSV
+// files, returning entries for all rows which passed validation. Rows for
+// files which no longer exist or have changed since the report was
+// generated are returned separately if the user opted to skip them.
+func validateInputCSVRows(rows []inputRow, appConfig *config.Config) (dupesets.DuplicateFileSetEntries, []staleRow, error) {
+
+	var dfsEntries dupesets.DuplicateFileSetEntries
+	var staleRows []staleRow
+
+	// Rows are validated concurrently, but handled in the order listed so
+	// that errors and stale rows are reported consistently.
+	for i, validationErr := range validateInputRows(rows, appConfig) {
+		dfsEntry, rowCounter := rows[i].entry, rows[i].rowNum
+		filename := dfsEntry.InputFile
+
+		if err := validationErr; err != nil {
+
+			// Reports are often acted on days later; files may have been
+			// moved or edited in the meantime.
+			if appConfig.SkipStale && dupesets.IsStale(err) {
+				log.Printf("Skipping stale input row %d in %q: %v\n", rowCounter, filename, err)
+				errreport.Record(filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename), errreport.OperationValidate, err)
+				staleRows = append(staleRows, staleRow{
+					inputFile: filename,
+					rowNum:    rowCounter,
+					entry:     dfsEntry,
+					err:       err,
+				})
+				continue
+			}
+
 			log.Println("Error encountered validating CSV row values:", err)
-			if appConfig.IgnoreErrors {
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
 				log.Printf("IgnoringErrors set, ignoring input row %d.\n", rowCounter)
+				errreport.Record(filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename), errreport.OperationValidate, err)
 				continue
 			}
 			log.Println("IgnoringErrors NOT set. Exiting.")
-			return err
+			return nil, nil, err
 		}
 
 		// update size details if found missing in CSV row
-		if err = dfsEntry.UpdateSizeInfo(); err != nil {
+		if err := dfsEntry.UpdateSizeInfo(); err != nil {
 			log.Println("Error encountered while attempting to update file size info:", err)
-			if appConfig.IgnoreErrors {
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
 				log.Printf("IgnoringErrors set, ignoring input row %d.\n", rowCounter)
+				errreport.Record(filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename), errreport.OperationValidate, err)
 				continue
 			}
 			log.Println("IgnoringErrors NOT set. Exiting.")
-			return err
+			return nil, nil, err
 		}
 
 		// Start off with collecting all entries in the CSV file that contain
 		// all required fields. We'll filter the entries later to just those
 		// that have been flagged for removal.
 		dfsEntries = append(dfsEntries, dfsEntry)
+	}
+
+	return dfsEntries, staleRows, nil
+}
+
+// inputRow is a parsed input CSV row awaiting validation.
+type inputRow struct {
+	entry  dupesets.DuplicateFileSetEntry
+	rowNum int
+}
 
+// validateInputRows validates the provided rows (see
+// dupesets.ValidateInputRow) using the requested number of concurrent
+// validators, returning the validation error (if any) of each row in the
+// same order as the rows. Progress is reported while checksums are verified
+// unless the user opted out. If the user opted to skip checksum
+// verification, only the size of each file is validated (see
+// dupesets.ValidateInputRowSize). Once a row fails validation with an error that
+// is neither skipped nor ignored as requested, rows not yet started are not
+// validated since the caller stops at that row.
+func validateInputRows(rows []inputRow, appConfig *config.Config) []error {
+
+	errs := make([]error, len(rows))
+	if len(rows) == 0 {
+		return errs
 	}
 
-	// at this point we have parsed the CSV file into dfsEntries, validated
-	// their content, regenerated file size details (if applicable) and are
-	// now ready to begin work to remove flagged files.
+	validate := dupesets.ValidateInputRow
+	if appConfig.SkipVerify {
+		validate = dupesets.ValidateInputRowSize
+	}
 
-	// DEBUG
-	// fmt.Println("Length of dfsEntries:", len(dfsEntries))
+	var meter *progress.Meter
+	if !appConfig.SkipVerify {
+		var total int64
+		for _, row := range rows {
+			total += row.entry.SizeInBytes
+		}
+		meter = pruneMeter(appConfig, "Validating checksums", total)
+	}
+	defer meter.Done()
 
-	// Print parsed CSV file to the console if user requested it
-	// NOTE: This contains ALL CSV file entries, not just those flagged for
-	// removal.
-	if appConfig.ConsoleReport {
-		fmt.Println("Parsed CSV contents:")
-		dfsEntries.Print(appConfig.BlankLineBetweenSets)
+	validators := appConfig.Validators
+	switch {
+	case validators < 1:
+		validators = 1
+	case validators > len(rows):
+		validators = len(rows)
 	}
 
-	// if there are no files flagged for removal, say so and exit.
-	filesToRemove := dfsEntries.FilesToRemove()
-	if len(filesToRemove) == 0 {
-		fmt.Printf("0 entries out of %d marked for removal in the %q input CSV file.\n",
-			len(dfsEntries), appConfig.InputCSVFile)
-		fmt.Println("Nothing to do, exiting.")
-		return nil
+	var failed atomic.Bool
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < validators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				if failed.Load() {
+					continue
+				}
+
+				err := validate(rows[index].entry, rows[index].rowNum)
+				errs[index] = err
+				meter.Add(rows[index].entry.SizeInBytes)
+
+				skipped := appConfig.SkipStale && dupesets.IsStale(err)
+				if err != nil && !skipped && !errreport.Ignore(appConfig.IgnoreErrors, err) {
+					failed.Store(true)
+				}
+			}
+		}()
 	}
 
-	// INFO? DEBUG?
-	log.Printf("Found %d files to remove in %q", len(filesToRemove), appConfig.InputCSVFile)
+	for index := range rows {
+		queue <- index
+	}
+	close(queue)
 
-	// DEBUG
-	// Is this really debug-level output, or is it useful to print upon
-	// request via the `console` flag?
-	if appConfig.ConsoleReport {
-		fmt.Println("Files marked for removal:")
-		filesToRemove.Print(appConfig.BlankLineBetweenSets)
+	wg.Wait()
+
+	return errs
+}
+
+// filesystemUsage records the free space of a filesystem containing files
+// flagged for removal, before and after removal.
+type filesystemUsage struct {
+
+	// path is a directory on the filesystem containing files flagged for
+	// removal
+	path string
+
+	freeBefore uint64
+	freeAfter  uint64
+}
+
+// newFilesystemUsages records the current free space of each filesystem
+// containing the provided files. Filesystems whose details cannot be
+// retrieved are skipped since free space details are informational only.
+func newFilesystemUsages(dfsEntries dupesets.DuplicateFileSetEntries) []*filesystemUsage {
+
+	var usages []*filesystemUsage
+	seen := make(map[string]bool)
+
+	for _, entry := range dfsEntries {
+		id, err := diskspace.FilesystemID(entry.ParentDirectory)
+		if err != nil {
+			log.Println("Unable to retrieve free space details:", err)
+			continue
+		}
+
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		free, err := diskspace.Free(entry.ParentDirectory)
+		if err != nil {
+			log.Println("Unable to retrieve free space details:", err)
+			continue
+		}
+
+		usages = append(usages, &filesystemUsage{
+			path:       entry.ParentDirectory,
+			freeBefore: free,
+		})
 	}
 
-	// Skip backup logic and file removal if running in "dry-run" mode
-	if !appConfig.DryRun {
+	return usages
+}
 
-		// DEBUG? INFO?
-		fmt.Println("Dry-run not enabled, file removal mode enabled")
+// printFilesystemUsages retrieves the current free space of each recorded
+// filesystem and prints the free space before and after removal.
+func printFilesystemUsages(usages []*filesystemUsage) {
 
-		if appConfig.BackupDirectory != "" {
-			// DEBUG
-			log.Println("Backup directory specified")
+	if len(usages) == 0 {
+		return
+	}
 
-			if !paths.PathExists(appConfig.BackupDirectory) {
-				// directory doesn't exist, what about the parent directory? do we
-				// have permission to create content within the parent directory
-				// to create the requested directory?
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
 
-				// perhaps we should abort if the target directory doesn't exist?
-				//
-				// For example, we could end up trying to create a directory like
-				// /tmp if the app is run as root. Since /tmp requires special
-				// permissions, creating it as this application could lead to a
-				// lot of problems that we cannot reliably anticipate and prevent
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Filesystem (path)\tFree before\tFree after\tChange")
 
-				return fmt.Errorf(
-					"backup directory %q specified, but does not exist",
-					appConfig.BackupDirectory,
-				)
-			}
+	for _, usage := range usages {
+		free, err := diskspace.Free(usage.path)
+		if err != nil {
+			log.Println("Unable to retrieve free space details:", err)
+			continue
+		}
+		usage.freeAfter = free
+
+		// Free space may also shrink (e.g., when backing up files to the
+		// same filesystem or due to unrelated activity).
+		//
+		// #nosec G115
+		change := "+" + units.ByteCountIEC(int64(usage.freeAfter-usage.freeBefore))
+		if usage.freeAfter < usage.freeBefore {
+			change = "-" + units.ByteCountIEC(int64(usage.freeBefore-usage.freeAfter))
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			usage.path,
+			units.ByteCountIEC(int64(usage.freeBefore)),
+			units.ByteCountIEC(int64(usage.freeAfter)),
+			change,
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
 
-			// attempt to backup files that the user marked for removal
-			for _, file := range filesToRemove {
+// backupToArchive copies the provided files into the backup archive
+// specified by the user, then reads the archive back to confirm that each
+// copy is good before the original files are removed. Backup paths are
+// recorded in the provided map; files whose copies fail verification are
+// recorded as not to be removed if the user opted to ignore errors. Files
+// are counted against the provided progress meter as they are added.
+func backupToArchive(
+	appConfig *config.Config,
+	filesToRemove dupesets.DuplicateFileSetEntries,
+	auditLog *audit.Log,
+	runJournal *journal.Journal,
+	backupPaths map[string]string,
+	skipRemoval map[string]bool,
+	meter *progress.Meter,
+) error {
 
-				fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+	aw, err := archive.NewWriter(appConfig.BackupArchive)
+	if err != nil {
+		return err
+	}
 
-				// attempt to backup files if user requested that we do so. if backup
-				// failure occurs, abort. If file already exists in specified backup
-				// directory check to see if they're identical. Report identical status
-				// (yeah, nay) and abort unless an override or force option is given
-				// (potential future work).
+	for _, file := range filesToRemove {
 
-				// DEBUG
-				// fmt.Printf("Calling BackupFile(%s, %s)\n", fullPathToFile, appConfig.BackupDirectory)
-
-				err := paths.BackupFile(fullPathToFile, appConfig.BackupDirectory)
-				if err != nil {
-					// FIXME: Implement check for appconfig.IgnoreErrors
-					// extend error message (potentially) to note that the error
-					// was encountered when creating a backup
-					return err
-				}
+		fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
 
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateStarted, "", nil)); err != nil {
+			if err := aw.Close(); err != nil {
+				log.Println("Error encountered closing backup archive:", err)
+			}
+			return err
+		}
+
+		if _, err := aw.Add(fullPathToFile, file.Checksum.String()); err != nil {
+			entry := auditEntry(file, audit.ActionBackup, audit.OutcomeFailed, err)
+			if err := auditLog.Record(entry); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+			if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateFailed, "", err)); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+
+			if err := aw.Close(); err != nil {
+				log.Println("Error encountered closing backup archive:", err)
 			}
 
-		} else {
-			// DEBUG
-			log.Println("backup directory not set, not backing up files")
+			return err
 		}
 
-		// Once backups complete remove original files. Allow IgnoreErrors setting
-		// to apply, but be very noisy about removal failures
+		meter.Add(file.SizeInBytes)
+	}
 
-		var filesRemovedSuccess int
-		var filesRemovedFail int
-		for _, dfsEntry := range filesToRemove {
+	if err := aw.Close(); err != nil {
+		return err
+	}
 
-			fullPathToFile := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+	failures, err := archive.Verify(appConfig.BackupArchive, aw.Members())
+	if err != nil {
+		return err
+	}
 
-			err = paths.RemoveFile(fullPathToFile, appConfig.DryRun)
-			if err != nil {
-				log.Printf("Error encountered while attempting to remove %q: %s\n",
-					dfsEntry.Filename, err)
-				if appConfig.IgnoreErrors {
-					log.Println("IgnoringErrors set, ignoring failed file removal")
-					filesRemovedFail++
-					continue
-				}
-				log.Println("IgnoringErrors NOT set. Exiting.")
-				return err
+	// Files are added in order; adding a file either succeeds or aborts
+	// the backup.
+	for index, member := range aw.Members() {
+
+		file := filesToRemove[index]
+		fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+		backupPath := appConfig.BackupArchive + "#" + member.Name
+
+		verifyErr := failures[member.Name]
+
+		// The file may have changed since its checksum was verified.
+		if verifyErr == nil &&
+			!file.Checksum.IsContentChecksum() &&
+			!file.Checksum.IsByteComparison() &&
+			!file.Checksum.IsPartial() &&
+			checksums.ParseChecksum(member.SHA256) != file.Checksum {
+			verifyErr = fmt.Errorf(
+				"checksum mismatch for %q in backup archive, file likely modified; got %s, expected %s",
+				member.Name,
+				checksums.ParseChecksum(member.SHA256),
+				file.Checksum,
+			)
+		}
+
+		if verifyErr != nil {
+			verifyErr = fmt.Errorf(
+				"backup copy %q of %q failed verification: %w",
+				backupPath,
+				fullPathToFile,
+				verifyErr,
+			)
+
+			entry := auditEntry(file, audit.ActionBackup, audit.OutcomeFailed, verifyErr)
+			entry.BackupPath = backupPath
+			if err := auditLog.Record(entry); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+			if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateFailed, backupPath, verifyErr)); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+
+			log.Println("Error encountered verifying backup:", verifyErr)
+			if errreport.Ignore(appConfig.IgnoreErrors, verifyErr) {
+				log.Printf("IgnoringErrors set, not removing %q\n", fullPathToFile)
+				errreport.Record(fullPathToFile, errreport.OperationBackup, verifyErr)
+				skipRemoval[fullPathToFile] = true
+				continue
 			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return verifyErr
+		}
 
-			// note that we have successfully removed a file
-			filesRemovedSuccess++
+		backupPaths[fullPathToFile] = backupPath
 
+		entry := auditEntry(file, audit.ActionBackup, audit.OutcomeSuccess, nil)
+		entry.BackupPath = backupPath
+		if err := auditLog.Record(entry); err != nil {
+			return err
 		}
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateCompleted, backupPath, nil)); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%d files backed up to %q", len(aw.Members()), appConfig.BackupArchive)
 
-		// print removal results summary
-		fmt.Printf("File removal: %d success, %d fail\n",
-			filesRemovedSuccess, filesRemovedFail)
+	return nil
+}
 
+// auditEntry returns an audit log entry recording the outcome of the
+// specified action for the provided file.
+func auditEntry(
+	dfsEntry dupesets.DuplicateFileSetEntry,
+	action string,
+	outcome string,
+	err error,
+) audit.Entry {
+	return audit.Entry{
+		Action:      action,
+		Path:        filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename),
+		SizeInBytes: dfsEntry.SizeInBytes,
+		Checksum:    dfsEntry.Checksum.String(),
+		Outcome:     outcome,
+		Err:         err,
 	}
+}
 
-	if appConfig.DryRun {
-		fmt.Println("Dry-run enabled, no files removed")
+// confirmationResponse is the response which must be typed to confirm file
+// removal.
+const confirmationResponse = "yes"
+
+// warnSkipVerify warns that checksums of files listed in input CSV files are
+// not verified before removal if the user opted to skip verification.
+func warnSkipVerify(appConfig *config.Config) {
+
+	if !appConfig.SkipVerify || len(appConfig.InputCSVFiles) == 0 {
+		return
 	}
 
-	return nil
+	// WARN
+	log.Println("WARNING: *** Checksum verification skipped as requested (skip-verify flag). ***")
+	log.Println("WARNING: Files listed in input CSV files are only checked for existence and size;")
+	log.Println("WARNING: files modified since the report was generated may be removed.")
+}
+
+// confirmRemoval prompts for typed confirmation before the specified number
+// of files are removed, returning true only if the expected response is read
+// from the provided input.
+func confirmRemoval(in io.Reader, fileCount int) (bool, error) {
+
+	fmt.Printf("Type %q to remove %d files: ", confirmationResponse, fileCount)
+
+	response, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	// Ensure that subsequent output starts on a new line when input ends
+	// without one (e.g., when stdin is not a terminal).
+	if !strings.HasSuffix(response, "\n") {
+		fmt.Println()
+	}
+
+	return strings.TrimSpace(response) == confirmationResponse, nil
+}
+
+// pruneSummary records the outcome of the prune subcommand. This is
+// included in notifications sent once the subcommand finishes.
+type pruneSummary struct {
+	InputFiles     []string `json:"input_files"`
+	Entries        int      `json:"entries"`
+	FlaggedFiles   int      `json:"flagged_files"`
+	FlaggedBytes   int64    `json:"flagged_bytes"`
+	DryRun         bool     `json:"dry_run"`
+	FilesRemoved   int      `json:"files_removed"`
+	FilesFailed    int      `json:"files_failed"`
+	FilesSkipped   int      `json:"files_skipped"`
+	BytesReclaimed int64    `json:"bytes_reclaimed"`
+}
+
+// Phases of the prune subcommand, run in order. No file is backed up or
+// removed until the parse, validate and plan phases have completed.
+const (
+	prunePhaseParse    string = "parse"
+	prunePhaseValidate string = "validate"
+	prunePhasePlan     string = "plan"
+	prunePhaseBackup   string = "backup"
+	prunePhaseRemove   string = "remove"
+)
+
+// prunePhases lists the phases of the prune subcommand in the order run.
+var prunePhases = []string{
+	prunePhaseParse,
+	prunePhaseValidate,
+	prunePhasePlan,
+	prunePhaseBackup,
+	prunePhaseRemove,
+}
+
+// startPrunePhase announces the start of the specified phase of the prune
+// subcommand.
+func startPrunePhase(phase string) {
+	for i, p := range prunePhases {
+		if p == phase {
+			fmt.Printf("\nPhase %d/%d: %s\n", i+1, len(prunePhases), phase)
+			return
+		}
+	}
+}
+
+// pruneMeter returns a progress meter for the specified operation of the
+// prune subcommand, or nil (which reports nothing) if the user opted out of
+// progress reporting.
+func pruneMeter(appConfig *config.Config, label string, total int64) *progress.Meter {
+	if appConfig.NoProgress {
+		return nil
+	}
+
+	return progress.NewMeter(label, total)
+}
+
+// pruneInput is the content of the input files read by the prune subcommand,
+// parsed but not yet validated.
+type pruneInput struct {
+
+	// inputFiles lists the input CSV files followed by the input fdupes
+	// files
+	inputFiles []string
+
+	// csvRows are the rows of all input CSV files, in the order listed
+	csvRows []inputRow
+
+	// fdupesFiles lists the input fdupes files
+	fdupesFiles []string
+
+	// fdupesGroups are the groups of duplicate files listed in each input
+	// fdupes file, indexed the same as fdupesFiles
+	fdupesGroups [][]fdupesGroup
+}
+
+// rows returns the number of files listed in the input files.
+func (input pruneInput) rows() int {
+	count := len(input.csvRows)
+	for _, groups := range input.fdupesGroups {
+		for _, group := range groups {
+			count += len(group.files)
+		}
+	}
+
+	return count
+}
+
+// parsePruneInput reads the input CSV and fdupes files requested by the
+// user without validating the files listed within them.
+func parsePruneInput(appConfig *config.Config) (pruneInput, error) {
+
+	var input pruneInput
+
+	csvFiles, err := inputCSVFiles(appConfig.InputCSVFiles)
+	if err != nil {
+		return input, err
+	}
+
+	for _, csvFile := range csvFiles {
+		rows, err := readInputCSVRows(csvFile, appConfig)
+		if err != nil {
+			return input, err
+		}
+		input.csvRows = append(input.csvRows, rows...)
+	}
+
+	input.fdupesFiles, err = inputCSVFiles(appConfig.InputFdupesFiles)
+	if err != nil {
+		return input, err
+	}
+
+	for _, fdupesFile := range input.fdupesFiles {
+		groups, err := readFdupesGroups(fdupesFile)
+		if err != nil {
+			return input, err
+		}
+		input.fdupesGroups = append(input.fdupesGroups, groups)
+	}
+
+	input.inputFiles = append(csvFiles, input.fdupesFiles...)
+
+	return input, nil
+}
+
+// pruneValidation records the outcome of the validate phase of the prune
+// subcommand.
+type pruneValidation struct {
+
+	// entries are the validated entries of all input files, merged and
+	// grouped by duplicate file set
+	entries dupesets.DuplicateFileSetEntries
+
+	// staleRows are the rows skipped since their files no longer exist or
+	// have changed, if the user opted to skip them
+	staleRows []staleRow
+
+	// rows is the number of files listed in the input files
+	rows int
+
+	// passed is the number of rows which passed validation
+	passed int
+
+	// repeated is the number of rows listing a file already listed by
+	// another row
+	repeated int
+}
+
+// validatePruneInput validates the parsed input files, merging the rows of
+// all input files so that large cleanups split across several report runs
+// are handled together.
+func validatePruneInput(appConfig *config.Config, input pruneInput) (pruneValidation, error) {
+
+	validation := pruneValidation{rows: input.rows()}
+
+	dfsEntries, staleRows, err := validateInputCSVRows(input.csvRows, appConfig)
+	if err != nil {
+		return validation, err
+	}
+
+	for i, fdupesFile := range input.fdupesFiles {
+		entries, stale, err := validateFdupesGroups(fdupesFile, input.fdupesGroups[i], appConfig)
+		if err != nil {
+			return validation, err
+		}
+		dfsEntries = append(dfsEntries, entries...)
+		staleRows = append(staleRows, stale...)
+	}
+
+	validation.staleRows = staleRows
+	validation.passed = len(dfsEntries)
+
+	// The same file may be listed in more than one input file (e.g., when
+	// report runs overlap).
+	dfsEntries, err = dfsEntries.RemoveRepeatedFiles()
+	if err != nil {
+		log.Println("Error encountered merging input CSV files:", err)
+		return validation, err
+	}
+	validation.repeated = validation.passed - len(dfsEntries)
+
+	// Rows may have been reordered (e.g., sorted in a spreadsheet). Confirm
+	// that each duplicate file set is intact before grouping entries by set.
+	if err := dfsEntries.ValidateSets(); err != nil {
+		log.Println("Error encountered validating duplicate file sets:", err)
+		return validation, err
+	}
+	dfsEntries.SortBySetID()
+
+	// Guard against removing every copy of a file, unless explicitly allowed.
+	if !appConfig.AllowRemoveAll {
+		if err := dfsEntries.ValidateKeepOne(); err != nil {
+			log.Println("Error encountered validating duplicate file sets:", err)
+			log.Println("Use the allow-remove-all flag to remove all files in a set.")
+			return validation, err
+		}
+	}
+
+	// Files in sets confirmed by byte comparison have no checksum to verify
+	// individually; compare them again before removing any of them.
+	if err := dfsEntries.VerifyByteComparisonSets(); err != nil {
+		log.Println("Error encountered verifying duplicate file sets:", err)
+		return validation, err
+	}
+
+	validation.entries = dfsEntries
+
+	return validation, nil
+}
+
+// print writes a summary of the validate phase to the console, followed by
+// the stale rows skipped (if any).
+func (validation pruneValidation) print() {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 2, ' ', 0)
+
+	skipped := validation.rows - validation.passed - len(validation.staleRows)
+
+	_, _ = fmt.Fprintln(w, "\nValidation summary:")
+	_, _ = fmt.Fprintf(w, "Rows validated\t%d\n", validation.rows)
+	_, _ = fmt.Fprintf(w, "Rows passed\t%d\n", validation.passed)
+	_, _ = fmt.Fprintf(w, "Stale rows skipped\t%d\n", len(validation.staleRows))
+	_, _ = fmt.Fprintf(w, "Rows skipped (errors ignored)\t%d\n", skipped)
+	_, _ = fmt.Fprintf(w, "Repeated rows merged\t%d\n", validation.repeated)
+	_, _ = fmt.Fprintf(w, "Entries to plan from\t%d\n", len(validation.entries))
+
+	if len(validation.staleRows) > 0 {
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+
+	printStaleRows(validation.staleRows)
+}
+
+// printPrunePlan summarizes what the prune subcommand is about to do with
+// the files flagged for removal, before any file is backed up or removed.
+func printPrunePlan(appConfig *config.Config, filesToRemove dupesets.DuplicateFileSetEntries) {
+
+	mode := "back up (if requested) and remove files"
+	switch {
+	case appConfig.ScriptFormat != "" || appConfig.RemoveListFile != "":
+		mode = "list files to remove; no files removed"
+	case appConfig.DryRun:
+		mode = "dry-run; no files removed"
+	}
+
+	backup := "none"
+	switch {
+	case s3.IsURL(appConfig.BackupDirectory):
+		backup = "Amazon S3 " + appConfig.BackupDirectory
+	case appConfig.BackupDirectory != "":
+		backup = "directory " + appConfig.BackupDirectory
+	case appConfig.BackupArchive != "":
+		backup = "archive " + appConfig.BackupArchive
+	}
+
+	confirmation := "required"
+	switch {
+	case appConfig.DryRun || appConfig.ScriptFormat != "" || appConfig.RemoveListFile != "":
+		confirmation = "not needed"
+	case appConfig.AssumeYes:
+		confirmation = "skipped (yes flag)"
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(w, "\nPlan summary:")
+	_, _ = fmt.Fprintf(w, "Mode\t%s\n", mode)
+	_, _ = fmt.Fprintf(w, "Files to remove\t%d\n", len(filesToRemove))
+	_, _ = fmt.Fprintf(w, "Space to reclaim\t%s\n", units.ByteCountIEC(filesToRemove.TotalSize()))
+	_, _ = fmt.Fprintf(w, "Backup destination\t%s\n", backup)
+	_, _ = fmt.Fprintf(w, "Confirmation\t%s\n", confirmation)
+	if appConfig.AuditLogFile != "" {
+		_, _ = fmt.Fprintf(w, "Audit log\t%s\n", appConfig.AuditLogFile)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// backupFlaggedFiles backs up the provided files to the backup directory,
+// archive or S3 location requested by the user, if any. Backup paths are
+// recorded in the provided map; files whose copies fail verification are
+// recorded as not to be removed if the user opted to ignore errors.
+func backupFlaggedFiles(
+	appConfig *config.Config,
+	filesToRemove dupesets.DuplicateFileSetEntries,
+	auditLog *audit.Log,
+	runJournal *journal.Journal,
+	backupPaths map[string]string,
+	skipRemoval map[string]bool,
+) error {
+
+	switch {
+	case s3.IsURL(appConfig.BackupDirectory):
+		// DEBUG
+		log.Println("S3 backup location specified")
+
+		meter := pruneMeter(appConfig, "Backing up files", filesToRemove.TotalSize())
+		defer meter.Done()
+
+		return backupToS3(appConfig, filesToRemove, auditLog, runJournal, backupPaths, skipRemoval, meter)
+
+	case appConfig.BackupDirectory != "":
+		// DEBUG
+		log.Println("Backup directory specified")
+
+		if !paths.PathExists(appConfig.BackupDirectory) {
+			// directory doesn't exist, what about the parent directory? do we
+			// have permission to create content within the parent directory
+			// to create the requested directory?
+
+			// perhaps we should abort if the target directory doesn't exist?
+			//
+			// For example, we could end up trying to create a directory like
+			// /tmp if the app is run as root. Since /tmp requires special
+			// permissions, creating it as this application could lead to a
+			// lot of problems that we cannot reliably anticipate and prevent.
+			// Only create the directory if the user explicitly asked us to.
+			if !appConfig.CreateBackupDir {
+				return fmt.Errorf(
+					"backup directory %q specified, but does not exist "+
+						"(use the create-backup-dir flag to create it)",
+					appConfig.BackupDirectory,
+				)
+			}
+
+			if err := paths.CreateBackupDirectory(appConfig.BackupDirectory); err != nil {
+				return err
+			}
+		}
+
+		meter := pruneMeter(appConfig, "Backing up files", filesToRemove.TotalSize())
+		defer meter.Done()
+
+		return backupToDirectory(appConfig, filesToRemove, auditLog, runJournal, backupPaths, skipRemoval, meter)
+
+	case appConfig.BackupArchive != "":
+		// DEBUG
+		log.Println("Backup archive specified")
+
+		archiveDir := filepath.Dir(filepath.Clean(appConfig.BackupArchive))
+		if appConfig.CreateBackupDir && !paths.PathExists(archiveDir) {
+			if err := paths.CreateBackupDirectory(archiveDir); err != nil {
+				return err
+			}
+		}
+
+		meter := pruneMeter(appConfig, "Backing up files", filesToRemove.TotalSize())
+		defer meter.Done()
+
+		return backupToArchive(appConfig, filesToRemove, auditLog, runJournal, backupPaths, skipRemoval, meter)
+
+	default:
+		// DEBUG
+		log.Println("backup directory not set, not backing up files")
+
+		return nil
+	}
+}
+
+// backupToDirectory copies the provided files to the backup directory
+// specified by the user, confirming that each copy is good before the
+// original files are removed. Backup paths are recorded in the provided
+// map; files whose copies fail verification are recorded as not to be
+// removed if the user opted to ignore errors. Files are counted against the
+// provided progress meter as they are copied.
+func backupToDirectory(
+	appConfig *config.Config,
+	filesToRemove dupesets.DuplicateFileSetEntries,
+	auditLog *audit.Log,
+	runJournal *journal.Journal,
+	backupPaths map[string]string,
+	skipRemoval map[string]bool,
+	meter *progress.Meter,
+) error {
+
+	// linkTargets maps checksums to verified backup copies which
+	// may be linked to (if requested)
+	linkTargets := make(map[checksums.SHA256Checksum]string)
+
+	// attempt to backup files that the user marked for removal
+	for _, file := range filesToRemove {
+
+		fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+
+		// attempt to backup files if user requested that we do so. if backup
+		// failure occurs, abort. If file already exists in specified backup
+		// directory check to see if they're identical. Report identical status
+		// (yeah, nay) and abort unless an override or force option is given
+		// (potential future work).
+
+		// DEBUG
+		// fmt.Printf("Calling BackupFile(%s, %s)\n", fullPathToFile, appConfig.BackupDirectory)
+
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateStarted, "", nil)); err != nil {
+			return err
+		}
+
+		var backupPath string
+		var err error
+
+		// Files sharing a checksum (other than content-only
+		// checksums) have identical content; store it only once.
+		existingBackup, linkable := linkTargets[file.Checksum]
+		if appConfig.BackupHardlinks && linkable {
+			backupPath, err = paths.LinkBackupFile(
+				fullPathToFile,
+				existingBackup,
+				appConfig.BackupDirectory,
+				appConfig.BackupCollision,
+			)
+			if err != nil {
+				log.Println("Unable to link backup copy, copying file instead:", err)
+				linkable = false
+			}
+		}
+
+		if !appConfig.BackupHardlinks || !linkable {
+			backupPath, err = paths.BackupFile(
+				fullPathToFile,
+				appConfig.BackupDirectory,
+				appConfig.BackupCollision,
+			)
+		}
+
+		meter.Add(file.SizeInBytes)
+
+		if err != nil {
+			entry := auditEntry(file, audit.ActionBackup, audit.OutcomeFailed, err)
+			if err := auditLog.Record(entry); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+			if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateFailed, "", err)); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+
+			if errors.Is(err, paths.ErrBackupCollision) {
+				log.Printf(
+					"A backup copy of %q already exists; see the backup-collision flag to skip identical copies or rename new copies instead",
+					fullPathToFile,
+				)
+			}
+
+			log.Println("Error encountered creating backup:", err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
+				log.Printf("IgnoringErrors set, not removing %q\n", fullPathToFile)
+				errreport.Record(fullPathToFile, errreport.OperationBackup, err)
+				skipRemoval[fullPathToFile] = true
+				continue
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		// Confirm that the backup copy is good before the original
+		// file is removed; matching sizes alone do not rule out
+		// corruption (e.g., on flaky USB drives).
+		if err := file.VerifyBackup(backupPath); err != nil {
+			entry := auditEntry(file, audit.ActionBackup, audit.OutcomeFailed, err)
+			entry.BackupPath = backupPath
+			if err := auditLog.Record(entry); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+			if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateFailed, backupPath, err)); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+
+			log.Println("Error encountered verifying backup:", err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
+				log.Printf("IgnoringErrors set, not removing %q\n", fullPathToFile)
+				errreport.Record(fullPathToFile, errreport.OperationBackup, err)
+				skipRemoval[fullPathToFile] = true
+				continue
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		backupPaths[fullPathToFile] = backupPath
+
+		if _, ok := linkTargets[file.Checksum]; !ok && !file.Checksum.IsContentChecksum() && !file.Checksum.IsPartial() {
+			linkTargets[file.Checksum] = backupPath
+		}
+
+		entry := auditEntry(file, audit.ActionBackup, audit.OutcomeSuccess, nil)
+		entry.BackupPath = backupPath
+		if err := auditLog.Record(entry); err != nil {
+			return err
+		}
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateCompleted, backupPath, nil)); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+// removeFlaggedFiles removes the provided files once backups are complete,
+// skipping files whose backup copies failed verification. The outcome of
+// each removal is recorded in the provided summary. Allow IgnoreErrors
+// setting to apply, but be very noisy about removal failures.
+func removeFlaggedFiles(
+	appConfig *config.Config,
+	filesToRemove dupesets.DuplicateFileSetEntries,
+	auditLog *audit.Log,
+	runJournal *journal.Journal,
+	backupPaths map[string]string,
+	skipRemoval map[string]bool,
+	summary *pruneSummary,
+) error {
+
+	meter := pruneMeter(appConfig, "Removing files", filesToRemove.TotalSize())
+	defer meter.Done()
+
+	for _, dfsEntry := range filesToRemove {
+
+		fullPathToFile := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+
+		meter.Add(dfsEntry.SizeInBytes)
+
+		if skipRemoval[fullPathToFile] {
+			log.Printf("Skipping removal of %q; backup copy could not be created or failed verification\n", fullPathToFile)
+			summary.FilesSkipped++
+			continue
+		}
+
+		// Record the removal before it is attempted so that an interrupted
+		// run can be resumed.
+		backupPath := backupPaths[fullPathToFile]
+		if err := runJournal.Record(journalEntry(dfsEntry, journal.ActionRemove, journal.StateStarted, backupPath, nil)); err != nil {
+			return err
+		}
+
+		err := paths.RemoveFile(fullPathToFile, appConfig.DryRun)
+
+		outcome := audit.OutcomeSuccess
+		state := journal.StateCompleted
+		if err != nil {
+			outcome = audit.OutcomeFailed
+			state = journal.StateFailed
+		}
+		entry := auditEntry(dfsEntry, audit.ActionRemove, outcome, err)
+		entry.BackupPath = backupPath
+		if err := auditLog.Record(entry); err != nil {
+			// Stop removing files if they can no longer be accounted for.
+			return err
+		}
+		if err := runJournal.Record(journalEntry(dfsEntry, journal.ActionRemove, state, backupPath, err)); err != nil {
+			return err
+		}
+
+		if err != nil {
+			log.Printf("Error encountered while attempting to remove %q: %s\n",
+				dfsEntry.Filename, err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
+				log.Println("IgnoringErrors set, ignoring failed file removal")
+				errreport.Record(fullPathToFile, errreport.OperationRemove, err)
+				summary.FilesFailed++
+				continue
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		// note that we have successfully removed a file
+		summary.FilesRemoved++
+		summary.BytesReclaimed += dfsEntry.SizeInBytes
+
+	}
+
+	return nil
+}
+
+// pruneSubcommand is a wrapper around the "prune" subcommand logic. The
+// input files are processed in distinct phases (see prunePhases): all rows
+// are parsed, then validated, then the removal is planned and summarized
+// before any file is backed up or removed. A summary of the files removed is
+// returned along with any error encountered.
+func pruneSubcommand(appConfig *config.Config) (pruneSummary, error) {
+
+	summary := pruneSummary{DryRun: appConfig.DryRun}
+
+	warnSkipVerify(appConfig)
+
+	startPrunePhase(prunePhaseParse)
+
+	input, err := parsePruneInput(appConfig)
+	if err != nil {
+		return summary, err
+	}
+
+	inputFiles := input.inputFiles
+	fmt.Printf("Parsed %d rows from %d input file(s)\n", input.rows(), len(inputFiles))
+
+	removalMode := !appConfig.DryRun && appConfig.ScriptFormat == "" && appConfig.RemoveListFile == ""
+
+	// Prevent concurrent runs (e.g., from competing cron entries) from
+	// removing the same files or writing to the same backup destination.
+	if removalMode {
+		locks, err := acquirePruneLocks(pruneLockPaths(appConfig, inputFiles), appConfig.ForceUnlock)
+		if err != nil {
+			return summary, err
+		}
+		defer releasePruneLocks(locks)
+	}
+
+	journalPath := pruneJournalPath(appConfig, inputFiles)
+
+	// Files removed by an interrupted run no longer exist; skip them instead
+	// of failing validation.
+	var journalStates map[string]journal.FileState
+	switch {
+	case appConfig.Resume:
+		journalStates, err = readPruneJournal(journalPath)
+		if err != nil {
+			return summary, err
+		}
+
+		skipped := input.skipProcessed(journalStates)
+		fmt.Printf("Resuming interrupted run recorded in journal %q: %d files already removed\n", journalPath, skipped)
+
+	case removalMode:
+		warnInterruptedRun(journalPath)
+	}
+
+	startPrunePhase(prunePhaseValidate)
+
+	validation, err := validatePruneInput(appConfig, input)
+	if err != nil {
+		return summary, err
+	}
+	validation.print()
+
+	dfsEntries := validation.entries
+
+	summary.InputFiles = inputFiles
+	summary.Entries = len(dfsEntries)
+
+	// at this point we have parsed the CSV file into dfsEntries, validated
+	// their content, regenerated file size details (if applicable) and are
+	// now ready to begin work to remove flagged files.
+
+	// DEBUG
+	// fmt.Println("Length of dfsEntries:", len(dfsEntries))
+
+	// Print parsed CSV file to the console if user requested it
+	// NOTE: This contains ALL CSV file entries, not just those flagged for
+	// removal.
+	if appConfig.ConsoleReport {
+		fmt.Println("Parsed CSV contents:")
+		dfsEntries.Print(appConfig.BlankLineBetweenSets)
+	}
+
+	startPrunePhase(prunePhasePlan)
+
+	// if there are no files flagged for removal, say so and exit.
+	filesToRemove := dfsEntries.FilesToRemove()
+	summary.FlaggedFiles = len(filesToRemove)
+	summary.FlaggedBytes = filesToRemove.TotalSize()
+	if len(filesToRemove) == 0 {
+		fmt.Printf("0 entries out of %d marked for removal in the %q input CSV file(s).\n",
+			len(dfsEntries), strings.Join(inputFiles, ", "))
+		fmt.Println("Nothing to do, exiting.")
+		return summary, nil
+	}
+
+	// INFO? DEBUG?
+	log.Printf("Found %d files to remove in %q", len(filesToRemove), strings.Join(inputFiles, ", "))
+
+	// DEBUG
+	// Is this really debug-level output, or is it useful to print upon
+	// request via the `console` flag?
+	if appConfig.ConsoleReport {
+		fmt.Println("Files marked for removal:")
+		filesToRemove.Print(appConfig.BlankLineBetweenSets)
+	}
+
+	filesToRemove.PrintRemovalSummary()
+
+	// Repeat the warning where it is seen before confirming removal.
+	warnSkipVerify(appConfig)
+
+	// Guard against an accidentally mass-edited input file.
+	if appConfig.MaxDelete > 0 && len(filesToRemove) > appConfig.MaxDelete {
+		return summary, fmt.Errorf(
+			"%d files flagged for removal exceeds the limit of %d files "+
+				"set by the max-delete flag; no files removed",
+			len(filesToRemove),
+			appConfig.MaxDelete,
+		)
+	}
+
+	if appConfig.MaxDeleteBytes > 0 && filesToRemove.TotalSize() > appConfig.MaxDeleteBytes {
+		return summary, fmt.Errorf(
+			"%d bytes (%s) flagged for removal exceeds the limit of %d bytes (%s) "+
+				"set by the max-delete-bytes flag; no files removed",
+			filesToRemove.TotalSize(),
+			units.ByteCountIEC(filesToRemove.TotalSize()),
+			appConfig.MaxDeleteBytes,
+			units.ByteCountIEC(appConfig.MaxDeleteBytes),
+		)
+	}
+
+	printPrunePlan(appConfig, filesToRemove)
+
+	// Leave removal to the operator running the generated script or to
+	// other tools reading the generated list.
+	if appConfig.ScriptFormat != "" || appConfig.RemoveListFile != "" {
+		if appConfig.ScriptFormat != "" {
+			if err := writePruneScript(appConfig, inputFiles, dfsEntries); err != nil {
+				return summary, err
+			}
+			log.Printf("Successfully created removal script: %q", appConfig.ScriptFile)
+		}
+
+		if appConfig.RemoveListFile != "" {
+			if err := writeRemoveList(appConfig, filesToRemove); err != nil {
+				return summary, err
+			}
+			log.Printf("Successfully created remove list file: %q", appConfig.RemoveListFile)
+		}
+
+		fmt.Println("Files to remove listed, no files removed")
+		return summary, nil
+	}
+
+	// Require confirmation before removing files, unless the user has
+	// already confirmed via flag.
+	if !appConfig.DryRun && !appConfig.AssumeYes {
+		confirmed, err := confirmRemoval(os.Stdin, len(filesToRemove))
+		if err != nil {
+			return summary, err
+		}
+
+		if !confirmed {
+			return summary, fmt.Errorf(
+				"file removal not confirmed; no files removed (use the yes flag to skip confirmation)",
+			)
+		}
+	}
+
+	// Record every file backed up or removed (or that would be removed) if
+	// requested.
+	var auditLog *audit.Log
+	if appConfig.AuditLogFile != "" {
+		auditLog, err = audit.NewLog(appConfig.AuditLogFile)
+		if err != nil {
+			return summary, err
+		}
+
+		defer func() {
+			if err := auditLog.Close(); err != nil {
+				log.Println("Error encountered closing audit log:", err)
+			}
+		}()
+	}
+
+	// Skip backup logic and file removal if running in "dry-run" mode
+	if appConfig.DryRun {
+		if appConfig.PlanFile != "" {
+			if err := writePrunePlan(appConfig, appConfig.PlanFile, filesToRemove); err != nil {
+				return summary, err
+			}
+			log.Printf("Successfully created plan file: %q", appConfig.PlanFile)
+		}
+
+		for _, dfsEntry := range filesToRemove {
+			entry := auditEntry(dfsEntry, audit.ActionRemove, audit.OutcomeDryRun, nil)
+			if err := auditLog.Record(entry); err != nil {
+				return summary, err
+			}
+		}
+
+		fmt.Println("Dry-run enabled, no files removed")
+		return summary, nil
+	}
+
+	// DEBUG? INFO?
+	fmt.Println("Dry-run not enabled, file removal mode enabled")
+
+	// Record free space before any changes (including backups) are made
+	// so that the real impact of the cleanup can be reported.
+	var filesystems []*filesystemUsage
+	if appConfig.FreeSpace {
+		filesystems = newFilesystemUsages(filesToRemove)
+	}
+
+	// Record each backup and removal before it is attempted and once it
+	// completes so that an interrupted run can be resumed.
+	runJournal, err := journal.Open(journalPath)
+	if err != nil {
+		if appConfig.JournalFile != "" {
+			return summary, err
+		}
+
+		// WARN
+		log.Println("Unable to create journal, continuing without it:", err)
+	}
+
+	defer func() {
+		if err := runJournal.Close(); err != nil {
+			log.Println("Error encountered closing journal:", err)
+		}
+	}()
+
+	runEntry := journal.Entry{
+		Action: journal.ActionRun,
+		State:  journal.StateStarted,
+		Path:   strings.Join(inputFiles, ", "),
+	}
+	if err := runJournal.Record(runEntry); err != nil {
+		return summary, err
+	}
+
+	// backupPaths maps files to their backup copies
+	backupPaths := make(map[string]string, len(filesToRemove))
+
+	// skipRemoval records files which are not to be removed since their
+	// backup copies failed verification
+	skipRemoval := make(map[string]bool)
+
+	// Backup copies verified by an interrupted run are used as is.
+	filesToBackup := make(dupesets.DuplicateFileSetEntries, 0, len(filesToRemove))
+	for _, dfsEntry := range filesToRemove {
+		fullPathToFile := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+		if backupPath := journalStates[fullPathToFile].BackupPath; backupPath != "" {
+			backupPaths[fullPathToFile] = backupPath
+			continue
+		}
+		filesToBackup = append(filesToBackup, dfsEntry)
+	}
+
+	startPrunePhase(prunePhaseBackup)
+
+	if len(filesToBackup) < len(filesToRemove) {
+		fmt.Printf("%d files already backed up by the interrupted run\n", len(filesToRemove)-len(filesToBackup))
+	}
+
+	if err := backupFlaggedFiles(appConfig, filesToBackup, auditLog, runJournal, backupPaths, skipRemoval); err != nil {
+		return summary, err
+	}
+
+	startPrunePhase(prunePhaseRemove)
+
+	if err := removeFlaggedFiles(appConfig, filesToRemove, auditLog, runJournal, backupPaths, skipRemoval, &summary); err != nil {
+		return summary, err
+	}
+
+	runEntry.State = journal.StateCompleted
+	if err := runJournal.Record(runEntry); err != nil {
+		return summary, err
+	}
+
+	// print removal results summary
+	fmt.Printf("File removal: %d success, %d fail\n",
+		summary.FilesRemoved, summary.FilesFailed)
+	if summary.FilesSkipped > 0 {
+		fmt.Printf("File removal: %d skipped due to failed backups\n",
+			summary.FilesSkipped)
+	}
+	fmt.Printf("Space reclaimed: %s of %s flagged for removal (%d of %d bytes)\n",
+		units.ByteCountIEC(summary.BytesReclaimed),
+		units.ByteCountIEC(filesToRemove.TotalSize()),
+		summary.BytesReclaimed,
+		filesToRemove.TotalSize(),
+	)
+
+	if appConfig.FreeSpace {
+		printFilesystemUsages(filesystems)
+	}
+
+	return summary, nil
 }
@@ -0,0 +1,147 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/journal"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// errNothingToResume indicates that the prune subcommand was asked to
+// resume a run, but the journal records no interrupted run.
+var errNothingToResume = errors.New("no interrupted prune run to resume")
+
+// pruneJournalSuffix is appended to the path of the first input file to
+// name the journal used if the user did not specify one.
+const pruneJournalSuffix = ".journal"
+
+// pruneJournalPath returns the path to the journal of the prune subcommand:
+// the journal specified by the user or, if not specified, a journal
+// alongside the first input file.
+func pruneJournalPath(appConfig *config.Config, inputFiles []string) string {
+
+	if appConfig.JournalFile != "" || len(inputFiles) == 0 {
+		return appConfig.JournalFile
+	}
+
+	return filepath.Clean(inputFiles[0] + pruneJournalSuffix)
+}
+
+// readPruneJournal returns the progress recorded for each file by the
+// interrupted run (and any attempts to resume it) in the specified journal.
+func readPruneJournal(journalPath string) (map[string]journal.FileState, error) {
+
+	entries, err := journal.Read(journalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	incomplete, ok := journal.Incomplete(entries)
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: the last run recorded in journal %q completed",
+			errNothingToResume,
+			journalPath,
+		)
+	}
+
+	return journal.FileStates(incomplete), nil
+}
+
+// warnInterruptedRun warns if the specified journal records a run which did
+// not complete, since files removed by that run are no longer found.
+func warnInterruptedRun(journalPath string) {
+
+	if journalPath == "" || !paths.PathExists(journalPath) {
+		return
+	}
+
+	entries, err := journal.Read(journalPath)
+	if err != nil {
+		log.Println("Unable to read journal:", err)
+		return
+	}
+
+	if _, ok := journal.Incomplete(entries); ok {
+		// WARN
+		log.Printf("WARNING: Journal %q records a prune run which did not complete; use the resume flag to continue it.\n", journalPath)
+	}
+}
+
+// skipProcessed removes the rows for files which the interrupted run
+// recorded in the journal has already removed (including files whose
+// removal started and which no longer exist), returning the number of rows
+// removed.
+func (input *pruneInput) skipProcessed(states map[string]journal.FileState) int {
+
+	processed := func(path string) bool {
+		state := states[path]
+		return state.Removed || (state.RemoveStarted && !paths.PathExists(path))
+	}
+
+	var skipped int
+
+	csvRows := input.csvRows[:0]
+	for _, row := range input.csvRows {
+		if processed(filepath.Join(row.entry.ParentDirectory, row.entry.Filename)) {
+			skipped++
+			continue
+		}
+		csvRows = append(csvRows, row)
+	}
+	input.csvRows = csvRows
+
+	for _, groups := range input.fdupesGroups {
+		for i := range groups {
+			files := groups[i].files[:0]
+			for _, file := range groups[i].files {
+				if processed(file.path) {
+					skipped++
+					continue
+				}
+				files = append(files, file)
+			}
+			groups[i].files = files
+		}
+	}
+
+	return skipped
+}
+
+// journalEntry returns a journal entry recording the state of the specified
+// action for the provided file.
+func journalEntry(
+	dfsEntry dupesets.DuplicateFileSetEntry,
+	action string,
+	state string,
+	backupPath string,
+	err error,
+) journal.Entry {
+
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	return journal.Entry{
+		Action:      action,
+		State:       state,
+		Path:        filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename),
+		SizeInBytes: dfsEntry.SizeInBytes,
+		Checksum:    dfsEntry.Checksum.String(),
+		BackupPath:  backupPath,
+		Err:         errMsg,
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/runlock"
+	"github.com/atc0005/bridge/internal/s3"
+)
+
+// pruneLockFileName is the name of the lock file created within the backup
+// directory while the prune subcommand runs.
+const pruneLockFileName = ".bridge-prune.lock"
+
+// pruneLockSuffix is appended to the path of each input file (and backup
+// archive) to name the lock file created alongside it.
+const pruneLockSuffix = ".lock"
+
+// pruneLockPaths returns the paths to the lock files preventing concurrent
+// runs of the prune subcommand from removing the same files or writing to
+// the same backup destination: one alongside each input file and one within
+// the backup directory (or alongside the backup archive). Amazon S3 backup
+// locations and backup directories which do not exist yet are not locked.
+func pruneLockPaths(appConfig *config.Config, inputFiles []string) []string {
+
+	var lockPaths []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			lockPaths = append(lockPaths, path)
+		}
+	}
+
+	for _, inputFile := range inputFiles {
+		add(inputFile + pruneLockSuffix)
+	}
+
+	switch {
+	case s3.IsURL(appConfig.BackupDirectory):
+	case appConfig.BackupDirectory != "":
+		if paths.PathExists(appConfig.BackupDirectory) {
+			add(filepath.Join(appConfig.BackupDirectory, pruneLockFileName))
+		}
+	case appConfig.BackupArchive != "":
+		add(appConfig.BackupArchive + pruneLockSuffix)
+	}
+
+	return lockPaths
+}
+
+// acquirePruneLocks acquires the specified lock files, removing existing
+// lock files first if requested. If a lock file is held by another run,
+// the lock files already acquired are released and the error is returned.
+// Lock files which cannot be created for other reasons (e.g., input files
+// stored in a read-only directory) are skipped.
+func acquirePruneLocks(lockPaths []string, force bool) ([]*runlock.Lock, error) {
+
+	if force {
+		// WARN
+		log.Println("WARNING: Removing existing lock files as requested (force-unlock flag)")
+	}
+
+	locks := make([]*runlock.Lock, 0, len(lockPaths))
+
+	for _, lockPath := range lockPaths {
+		lock, err := runlock.Acquire(lockPath, force)
+		switch {
+		case errors.Is(err, runlock.ErrLocked):
+			releasePruneLocks(locks)
+			log.Println("Another prune run appears to be in progress. If not (e.g., a previous run was killed), use the force-unlock flag to remove the lock file.")
+			return nil, err
+
+		case err != nil:
+			// WARN
+			log.Println("Unable to create lock file, continuing without it:", err)
+			continue
+		}
+
+		// DEBUG
+		log.Printf("Acquired lock file %q\n", lock.Path())
+
+		locks = append(locks, lock)
+	}
+
+	return locks, nil
+}
+
+// releasePruneLocks releases the provided lock files in the reverse order
+// acquired.
+func releasePruneLocks(locks []*runlock.Lock) {
+	for i := len(locks) - 1; i >= 0; i-- {
+		if err := locks[i].Release(); err != nil {
+			log.Println("Error encountered releasing lock file:", err)
+		}
+	}
+}
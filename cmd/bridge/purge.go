@@ -0,0 +1,248 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// expiredBackup is a file within the backup directory last modified before
+// the end of the retention period.
+type expiredBackup struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// expiredBackups returns the regular files within the specified backup
+// directory (recursively) last modified before the provided cutoff, oldest
+// first. Backup copies are created (not copied with their original
+// timestamps) by the prune subcommand, so the modification time reflects
+// when the backup was made.
+func expiredBackups(backupDir string, cutoff time.Time, ignoreErrors bool) ([]expiredBackup, error) {
+
+	var expired []expiredBackup
+
+	err := filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println("Error encountered:", err)
+			if errreport.Ignore(ignoreErrors, err) {
+				log.Println("IgnoringErrors set, skipping path")
+				return nil
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		// Symbolic links and other special files are never created by the
+		// prune subcommand; leave them alone.
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		// The lock file of a prune run in progress is not a backup copy.
+		if d.Name() == pruneLockFileName {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			log.Println("Error encountered:", err)
+			if errreport.Ignore(ignoreErrors, err) {
+				log.Println("IgnoringErrors set, skipping path")
+				return nil
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		if info.ModTime().Before(cutoff) {
+			expired = append(expired, expiredBackup{
+				path:    path,
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate backup directory %q: %w", backupDir, err)
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].modTime.Before(expired[j].modTime)
+	})
+
+	return expired, nil
+}
+
+// printExpiredBackups writes a summary of the provided expired backup copies
+// to stdout.
+func printExpiredBackups(expired []expiredBackup, cutoff time.Time) {
+
+	var totalSize int64
+	for _, file := range expired {
+		totalSize += file.size
+	}
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Purge summary:")
+	_, _ = fmt.Fprintf(w, "%s\tretention cutoff\n", cutoff.Format(time.RFC3339))
+	_, _ = fmt.Fprintf(w, "%d\tfiles to remove\n", len(expired))
+	_, _ = fmt.Fprintf(w, "%s\ttotal size of files to remove\n", units.ByteCountIEC(totalSize))
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", "Last modified", "Size", "File")
+	for _, file := range expired {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n",
+			file.modTime.Format(time.RFC3339),
+			units.ByteCountIEC(file.size),
+			file.path,
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// removeEmptyDirectories removes directories within (but not including) the
+// specified backup directory which are empty, deepest first, so that the
+// mirrored directory tree does not outlive the backup copies it held.
+// Failures are logged since leftover directories take up little space.
+func removeEmptyDirectories(backupDir string) {
+
+	var dirs []string
+
+	err := filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories; they are not removed.
+			return nil
+		}
+
+		if d.IsDir() && path != backupDir {
+			dirs = append(dirs, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Error encountered listing backup directories:", err)
+		return
+	}
+
+	// Child directories are listed after their parent; remove them first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+
+		if err := os.Remove(dirs[i]); err != nil {
+			log.Printf("Error encountered removing empty directory %q: %v\n", dirs[i], err)
+			continue
+		}
+
+		// DEBUG
+		log.Printf("Removed empty directory %q\n", dirs[i])
+	}
+}
+
+// purgeSubcommand is a wrapper around the "purge" subcommand logic. Backup
+// copies older than the retention period specified by the user are removed
+// so that the backup directory does not grow without bound.
+func purgeSubcommand(appConfig *config.Config) error {
+
+	backupDir := filepath.Clean(appConfig.BackupDirectory)
+
+	fileInfo, err := os.Stat(backupDir)
+	if err != nil {
+		return fmt.Errorf("unable to access backup directory %q: %w", backupDir, err)
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("backup directory %q is not a directory", backupDir)
+	}
+
+	cutoff := time.Now().Add(-appConfig.OlderThan)
+
+	expired, err := expiredBackups(backupDir, cutoff, appConfig.IgnoreErrors)
+	if err != nil {
+		return err
+	}
+
+	if len(expired) == 0 {
+		fmt.Printf("No files in %q last modified before %s.\n", backupDir, cutoff.Format(time.RFC3339))
+		fmt.Println("Nothing to do, exiting.")
+		return nil
+	}
+
+	printExpiredBackups(expired, cutoff)
+
+	if !appConfig.DryRun && !appConfig.AssumeYes {
+		confirmed, err := confirmRemoval(os.Stdin, len(expired))
+		if err != nil {
+			return err
+		}
+
+		if !confirmed {
+			return fmt.Errorf(
+				"file removal not confirmed; no files removed (use the yes flag to skip confirmation)",
+			)
+		}
+	}
+
+	var filesRemovedSuccess int
+	var filesRemovedFail int
+	var bytesReclaimed int64
+	for _, file := range expired {
+		if err := paths.RemoveFile(file.path, appConfig.DryRun); err != nil {
+			log.Printf("Error encountered while attempting to remove %q: %s\n", file.path, err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
+				log.Println("IgnoringErrors set, ignoring failed file removal")
+				filesRemovedFail++
+				continue
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		filesRemovedSuccess++
+		bytesReclaimed += file.size
+	}
+
+	if appConfig.DryRun {
+		fmt.Println("Dry-run enabled, no files removed")
+		return nil
+	}
+
+	removeEmptyDirectories(backupDir)
+
+	fmt.Printf("File removal: %d success, %d fail\n", filesRemovedSuccess, filesRemovedFail)
+	fmt.Printf("Space reclaimed: %s (%d bytes)\n", units.ByteCountIEC(bytesReclaimed), bytesReclaimed)
+
+	return nil
+}
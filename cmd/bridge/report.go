@@ -8,28 +8,92 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/atc0005/bridge/internal/checksums"
 	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
 	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/progress"
+	"github.com/atc0005/bridge/internal/publish"
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/units"
 )
 
-// reportSubcommand is a wrapper around the "report" subcommand logic.
-func reportSubcommand(appConfig *config.Config) error {
+// mebibyte is the number of bytes in one MiB, the unit used by the fast hash
+// flags.
+const mebibyte int64 = 1024 * 1024
+
+// dirExcludes returns the directories skipped when recursively evaluating
+// paths: well-known junk directories (see matches.DefaultDirExcludes) unless
+// the user opted out.
+func dirExcludes(appConfig *config.Config) matches.DirExcludes {
+	if appConfig.NoDefaultExcludes {
+		return nil
+	}
+
+	return matches.DefaultDirExcludes
+}
+
+// checksumMeter returns a meter reporting progress while generating
+// checksums for the files in the provided index. No meter is returned if
+// the user opted out of progress reporting.
+func checksumMeter(appConfig *config.Config, fileSizeIndex matches.FileSizeIndex) *progress.Meter {
+	if appConfig.NoProgress {
+		return nil
+	}
+
+	return progress.NewMeter(
+		"Generating checksums",
+		fileSizeIndex.PendingChecksumBytes(appConfig.ExtraHash),
+	)
+}
+
+// reportSubcommand is a wrapper around the "report" subcommand logic. The
+// summary of evaluated files is returned so that the caller can determine
+// the appropriate exit code.
+func reportSubcommand(appConfig *config.Config) (matches.DuplicateFilesSummary, error) {
+
+	startTime := time.Now()
+	var errCounts matches.ScanErrorCounts
+
+	// S3 URLs are evaluated separately from local paths
+	var localPaths, s3URLs []string
+	for _, path := range appConfig.Paths {
+		if s3.IsURL(path) {
+			s3URLs = append(s3URLs, path)
+			continue
+		}
+		localPaths = append(localPaths, path)
+	}
+
+	// Files below the size threshold are only listed if requested, so
+	// include them in the index until they can be split off.
+	fileSizeThreshold := appConfig.FileSizeThreshold
+	if appConfig.SmallFilesCSVFile != "" {
+		fileSizeThreshold = 0
+	}
 
 	// evaluate all paths building a combined index of all files based on size
-	combinedFileSizeIndex, err := matches.NewFileSizeIndex(
+	combinedFileSizeIndex, ignoredPathErrors, err := matches.NewFileSizeIndex(
 		appConfig.RecursiveSearch,
 		appConfig.IgnoreErrors,
-		appConfig.FileSizeThreshold,
-		appConfig.Paths...,
+		fileSizeThreshold,
+		appConfig.Walkers,
+		dirExcludes(appConfig),
+		localPaths...,
 	)
 
 	if err != nil {
-		if !appConfig.IgnoreErrors {
-			return fmt.Errorf(
+		if !errreport.Ignore(appConfig.IgnoreErrors, err) {
+			return matches.DuplicateFilesSummary{}, fmt.Errorf(
 				"failed to build file size index from paths (%q): %w",
 				appConfig.Paths.String(),
 				err,
@@ -38,22 +102,220 @@ func reportSubcommand(appConfig *config.Config) error {
 		log.Println("Error encountered:", err)
 		log.Println("Attempting to ignore errors as requested")
 	}
+	errCounts.Path = ignoredPathErrors
+
+	if len(s3URLs) > 0 {
+		s3FileSizeIndex, ignoredS3Errors, err := newS3FileSizeIndex(appConfig, s3URLs, fileSizeThreshold)
+		errCounts.Path += ignoredS3Errors
+		if err != nil {
+			return matches.DuplicateFilesSummary{}, err
+		}
+		combinedFileSizeIndex = matches.MergeFileSizeIndexes(combinedFileSizeIndex, s3FileSizeIndex)
+	}
+
+	// Files above the maximum size (if any) are not evaluated.
+	if appConfig.MaxFileSize > 0 {
+		skipped := combinedFileSizeIndex.RemoveAboveSize(appConfig.MaxFileSize)
+
+		// DEBUG
+		log.Printf("Skipped %d files larger than %d bytes\n", skipped, appConfig.MaxFileSize)
+	}
+
+	// Files outside the requested age range (if any) are not evaluated.
+	if appConfig.OlderThan > 0 || appConfig.NewerThan > 0 {
+		var earliest, latest time.Time
+		if appConfig.NewerThan > 0 {
+			earliest = startTime.Add(-appConfig.NewerThan)
+		}
+		if appConfig.OlderThan > 0 {
+			latest = startTime.Add(-appConfig.OlderThan)
+		}
+		skipped := combinedFileSizeIndex.RemoveOutsideModTimes(earliest, latest)
+
+		// DEBUG
+		log.Printf("Skipped %d files outside of the requested age range\n", skipped)
+	}
+
+	// List files below the size threshold (e.g., zero-byte files) IF user
+	// requested it. These files are not evaluated as duplicates.
+	smallFiles, zeroByteFiles, err := writeSmallFilesFile(appConfig, combinedFileSizeIndex, &errCounts)
+	if err != nil {
+		return matches.DuplicateFilesSummary{}, err
+	}
+
+	// Duplicate file sets already known when the baseline snapshot was
+	// written are excluded IF user requested it. The baseline is read
+	// first in case it is replaced by the snapshot written below.
+	var baseline matches.Snapshot
+	if appConfig.BaselineFile != "" {
+		baseline, err = matches.ReadSnapshot(appConfig.BaselineFile)
+		if err != nil {
+			return matches.DuplicateFilesSummary{}, err
+		}
+	}
+
+	// Record every evaluated file for use as a baseline by later scans IF
+	// user requested it.
+	if appConfig.SnapshotFile != "" {
+		if err := combinedFileSizeIndex.WriteSnapshot(appConfig.SnapshotFile); err != nil {
+			return matches.DuplicateFilesSummary{}, err
+		}
+		log.Printf("Successfully created snapshot file: %q", appConfig.SnapshotFile)
+	}
+
+	// Record the number of evaluated files before pruning entries which do
+	// not meet our file duplicates threshold.
+	totalEvaluatedFiles := combinedFileSizeIndex.GetTotalFilesCount()
+
+	// Directory summaries note whether all evaluated files in a directory
+	// have copies elsewhere, so record per directory counts before pruning.
+	var evaluatedFilesPerDirectory map[string]int
+	if appConfig.DirSummaryCSVFile != "" || appConfig.ExcelFile != "" {
+		evaluatedFilesPerDirectory = combinedFileSizeIndex.FilesPerDirectory()
+	}
+
+	// Images are compared by perceptual hash or EXIF metadata, related
+	// files by name and similar files by fuzzy hash regardless of file size,
+	// so retain a copy of the index before pruning.
+	var unprunedFileSizeIndex matches.FileSizeIndex
+	if appConfig.NearDuplicates || appConfig.EXIFMatches || appConfig.RelatedFiles || appConfig.SimilarFiles {
+		unprunedFileSizeIndex = matches.MergeFileSizeIndexes(combinedFileSizeIndex)
+	}
+
+	// Files compared by content only may differ in size due to metadata, so
+	// they are evaluated separately from the size based index.
+	contentOnly := appConfig.ContentOnly || appConfig.AudioContentOnly
+	var contentFileSizeIndex matches.FileSizeIndex
+	if contentOnly {
+		contentFileSizeIndex = combinedFileSizeIndex.SplitContentChecksumFiles(
+			func(filename string) bool {
+				return (appConfig.ContentOnly && checksums.SupportsImageContentCheckSum(filename)) ||
+					(appConfig.AudioContentOnly && checksums.SupportsAudioContentCheckSum(filename))
+			},
+		)
+	}
+
+	// Only the start of very large files is read IF user requested it, so
+	// these are evaluated separately in order to tag the resulting sets as
+	// unverified.
+	var partialFileSizeIndex matches.FileSizeIndex
+	if appConfig.FastHash > 0 {
+		partialFileSizeIndex = combinedFileSizeIndex.SplitAboveSize(appConfig.FastHashThreshold * mebibyte)
+		partialFileSizeIndex.PruneFileSizeIndex(appConfig.FileDuplicatesThreshold)
+	}
 
 	// TODO: Refactor this; merge into NewFileSizeIndex? NewFileChecksumIndex?
 	// Prune FileMatches entries from map if below our file duplicates threshold
 	combinedFileSizeIndex.PruneFileSizeIndex(appConfig.FileDuplicatesThreshold)
 
-	if err := combinedFileSizeIndex.UpdateChecksums(appConfig.IgnoreErrors); err != nil {
-		log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
-		return err
+	// Skip downloading S3 objects already known to be unique based on their
+	// ETag values.
+	if len(s3URLs) > 0 {
+		combinedFileSizeIndex.PruneByContentHint(appConfig.FileDuplicatesThreshold)
+		partialFileSizeIndex.PruneByContentHint(appConfig.FileDuplicatesThreshold)
+	}
+
+	// Skip reading files which are unchanged since an earlier run IF user
+	// requested it.
+	if appConfig.PreviousCSVFile != "" {
+		recorded, err := readPreviousChecksums(appConfig)
+		if err != nil {
+			return matches.DuplicateFilesSummary{}, err
+		}
+
+		reused := combinedFileSizeIndex.ApplyRecordedChecksums(recorded)
+		log.Printf(
+			"Reusing checksums for %d unchanged files recorded in %q\n",
+			reused,
+			appConfig.PreviousCSVFile,
+		)
+	}
+
+	// Write confirmed duplicate file sets as they are found instead of
+	// retaining them for later processing.
+	if appConfig.Stream {
+		duplicateFiles := matches.DuplicateFilesSummary{
+			TotalEvaluatedFiles: totalEvaluatedFiles,
+			SmallFiles:          smallFiles,
+			ZeroByteFiles:       zeroByteFiles,
+		}
+		return streamReport(appConfig, combinedFileSizeIndex, baseline, duplicateFiles, errCounts, startTime)
+	}
+
+	var fileChecksumIndex matches.FileChecksumIndex
+
+	switch {
+	case appConfig.CompareBytes:
+		// Skip checksum generation; files of the same size are compared
+		// directly.
+		var ignoredCompareErrors int
+		fileChecksumIndex, ignoredCompareErrors, err = matches.NewByteComparisonIndex(
+			combinedFileSizeIndex, appConfig.IgnoreErrors)
+		errCounts.Checksum = ignoredCompareErrors
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+
+	default:
+		meter := checksumMeter(appConfig, combinedFileSizeIndex)
+		ignoredChecksumErrors, err := combinedFileSizeIndex.UpdateChecksumsWith(appConfig.IgnoreErrors, appConfig.ExtraHash, meter)
+		meter.Done()
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.Checksum = ignoredChecksumErrors
+
+		if appConfig.RetryChanged {
+			retried := len(combinedFileSizeIndex.ChangedFiles())
+			if retried > 0 {
+				log.Printf("Retrying %d files which changed since they were found", retried)
+				ignoredRetryErrors, err := combinedFileSizeIndex.RetryChangedFiles(appConfig.IgnoreErrors, appConfig.ExtraHash)
+				errCounts.Checksum += ignoredRetryErrors
+				if err != nil {
+					log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+					return matches.DuplicateFilesSummary{}, err
+				}
+			}
+		}
+
+		// TODO: Move this to matches package
+		//
+		// At this point checksums have been calculated. We can use those
+		// checksums to build a FileChecksumIndex in order to map checksums to
+		// specific FileMatches objects.
+		fileChecksumIndex = matches.NewFileChecksumIndex(combinedFileSizeIndex)
 	}
 
-	// TODO: Move this to matches package
-	//
-	// At this point checksums have been calculated. We can use those
-	// checksums to build a FileChecksumIndex in order to map checksums to
-	// specific FileMatches objects.
-	fileChecksumIndex := matches.NewFileChecksumIndex(combinedFileSizeIndex)
+	if contentOnly {
+		ignoredContentChecksumErrors, err := contentFileSizeIndex.UpdateContentChecksums(appConfig.IgnoreErrors)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.Checksum += ignoredContentChecksumErrors
+
+		fileChecksumIndex = matches.MergeFileChecksumIndexes(
+			fileChecksumIndex,
+			matches.NewFileChecksumIndex(contentFileSizeIndex),
+		)
+	}
+
+	if appConfig.FastHash > 0 {
+		ignoredPartialChecksumErrors, err := partialFileSizeIndex.UpdatePartialChecksums(
+			appConfig.IgnoreErrors, appConfig.FastHash*mebibyte)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.Checksum += ignoredPartialChecksumErrors
+
+		fileChecksumIndex = matches.MergeFileChecksumIndexes(
+			fileChecksumIndex,
+			matches.NewFileChecksumIndex(partialFileSizeIndex),
+		)
+	}
 
 	// Remove FileMatches objects not meeting our file duplicates threshold
 	// value. Remaining FileMatches that meet our file duplicates value are
@@ -61,52 +323,812 @@ func reportSubcommand(appConfig *config.Config) error {
 	// log.Println("fileChecksumIndex before pruning:", len(fileChecksumIndex))
 	fileChecksumIndex.PruneFileChecksumIndex(appConfig.FileDuplicatesThreshold)
 
-	// Use text/tabwriter to dump results of the calculations directly to the
-	// console. This is primarily intended for troubleshooting purposes.
-	if appConfig.ConsoleReport {
-		fileChecksumIndex.PrintFileMatches(appConfig.BlankLineBetweenSets)
+	// Compare files byte for byte before treating them as duplicates;
+	// files which differ are removed, so prune any sets left below our
+	// file duplicates threshold.
+	var byteMismatches int
+	if appConfig.VerifyBytes {
+		var ignoredVerifyErrors int
+		byteMismatches, ignoredVerifyErrors, err = fileChecksumIndex.VerifyBytes(appConfig.IgnoreErrors)
+		errCounts.VerifyBytes = ignoredVerifyErrors
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		fileChecksumIndex.PruneFileChecksumIndex(appConfig.FileDuplicatesThreshold)
+	}
+
+	var baselineSets int
+	if baseline != nil {
+		baselineSets = fileChecksumIndex.ExcludeUnchanged(baseline)
+	}
+
+	// List tag differences to help choose which copy of a track to keep.
+	if appConfig.AudioContentOnly {
+		ignoredTagErrors, err := fileChecksumIndex.UpdateTagDifferences(appConfig.IgnoreErrors)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.AudioTags = ignoredTagErrors
+	}
+
+	// Record EXIF metadata for images in confirmed duplicate file sets for
+	// the optional EXIF report columns.
+	if appConfig.EXIF {
+		ignoredEXIFErrors, err := fileChecksumIndex.UpdateEXIF(appConfig.IgnoreErrors)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.EXIF += ignoredEXIFErrors
+	}
+
+	// Record the space allocated on disk to duplicate files so that wasted
+	// space reflects the space actually reclaimed by removing them.
+	if appConfig.AllocatedSize {
+		ignoredAllocatedErrors, err := fileChecksumIndex.UpdateAllocatedSizes(appConfig.IgnoreErrors)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.Allocated = ignoredAllocatedErrors
+	}
+
+	// Optional image similarity pass; reported separately since files in
+	// these sets are not guaranteed to be identical.
+	var nearDuplicateSets matches.NearDuplicateSets
+	if appConfig.NearDuplicates {
+		var ignoredPerceptualHashErrors int
+		nearDuplicateSets, ignoredPerceptualHashErrors, err = unprunedFileSizeIndex.FindNearDuplicates(
+			appConfig.NearDuplicatesDistance,
+			appConfig.IgnoreErrors,
+			fileChecksumIndex,
+		)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.PerceptualHash = ignoredPerceptualHashErrors
+
+		if appConfig.ConsoleReport {
+			nearDuplicateSets.PrintNearDuplicates(appConfig.BlankLineBetweenSets)
+		}
+	}
+
+	// Optional EXIF metadata pass; reported separately since files in these
+	// sets differ in content.
+	var exifMatchSets matches.EXIFMatchSets
+	if appConfig.EXIFMatches {
+		var ignoredEXIFErrors int
+		exifMatchSets, ignoredEXIFErrors, err = unprunedFileSizeIndex.FindEXIFMatches(
+			appConfig.IgnoreErrors,
+			fileChecksumIndex,
+		)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.EXIF += ignoredEXIFErrors
+
+		if appConfig.ConsoleReport {
+			exifMatchSets.PrintEXIFMatches(appConfig.BlankLineBetweenSets)
+		}
+	}
+
+	// Optional file name similarity pass; reported separately since files
+	// in these sets differ in content.
+	var relatedFileSets matches.RelatedFileSets
+	if appConfig.RelatedFiles {
+		relatedFileSets = unprunedFileSizeIndex.FindRelatedFiles(fileChecksumIndex)
+
+		if appConfig.ConsoleReport {
+			relatedFileSets.PrintRelatedFiles(appConfig.BlankLineBetweenSets)
+		}
+	}
+
+	// Optional document and archive similarity pass; reported separately
+	// since files in these sets differ in content.
+	var similarFileSets matches.SimilarSets
+	if appConfig.SimilarFiles {
+		var ignoredFuzzyHashErrors int
+		similarFileSets, ignoredFuzzyHashErrors, err = unprunedFileSizeIndex.FindSimilarFiles(
+			appConfig.SimilarFilesScore,
+			appConfig.IgnoreErrors,
+			fileChecksumIndex,
+		)
+		if err != nil {
+			log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+			return matches.DuplicateFilesSummary{}, err
+		}
+		errCounts.FuzzyHash = ignoredFuzzyHashErrors
+
+		if appConfig.ConsoleReport {
+			similarFileSets.PrintSimilarFiles(appConfig.BlankLineBetweenSets)
+		}
 	}
 
+	// Files skipped as they changed since they were found (and, if
+	// requested, changed again when retried).
+	changedFiles := recordChangedFiles(
+		combinedFileSizeIndex.ChangedFiles(),
+		contentFileSizeIndex.ChangedFiles(),
+		partialFileSizeIndex.ChangedFiles(),
+	)
+
+	// Files skipped as they were locked by another process (e.g., on
+	// Windows) are listed individually.
+	inUseFiles := append(combinedFileSizeIndex.InUseFiles(), contentFileSizeIndex.InUseFiles()...)
+	inUseFiles = append(inUseFiles, partialFileSizeIndex.InUseFiles()...)
+	sort.Strings(inUseFiles)
+
 	// TODO: Move this into a separate package?
 	// Note: FileSizeMatchSets represents *potential* duplicate files going
 	// off of file size only (inconclusive)
 	duplicateFiles := matches.DuplicateFilesSummary{
-		TotalEvaluatedFiles: len(combinedFileSizeIndex),
-		FileSizeMatches:     combinedFileSizeIndex.GetTotalFilesCount(),
-		FileSizeMatchSets:   len(combinedFileSizeIndex),
+		TotalEvaluatedFiles: totalEvaluatedFiles,
+		FileSizeMatches:     combinedFileSizeIndex.GetTotalFilesCount() + partialFileSizeIndex.GetTotalFilesCount(),
+		FileSizeMatchSets:   len(combinedFileSizeIndex) + len(partialFileSizeIndex),
 		FileHashMatches:     fileChecksumIndex.GetTotalFilesCount(),
 		FileHashMatchSets:   len(fileChecksumIndex),
-		WastedSpace:         fileChecksumIndex.GetWastedSpace(),
 		DuplicateCount:      fileChecksumIndex.GetDuplicateFilesCount(),
+		NearDuplicateSets:   len(nearDuplicateSets),
+		EXIFMatchSets:       len(exifMatchSets),
+		RelatedFileSets:     len(relatedFileSets),
+		SimilarFileSets:     len(similarFileSets),
+		ContentEqualSets:    fileChecksumIndex.GetContentEqualSetsCount(),
+		BytesVerifiedSets:   fileChecksumIndex.GetBytesVerifiedSetsCount(),
+		ByteMismatches:      byteMismatches,
+		ByteComparisonSets:  fileChecksumIndex.GetByteComparisonSetsCount(),
+		PartialSets:         fileChecksumIndex.GetPartialSetsCount(),
+		SmallFiles:          smallFiles,
+		ZeroByteFiles:       zeroByteFiles,
+		BaselineSets:        baselineSets,
+		ChangedFiles:        changedFiles,
+		InUseFiles:          inUseFiles,
+	}
+
+	// When evaluating multiple paths (e.g., an archive and an import
+	// folder), duplicates found across paths are often the only actionable
+	// ones, so classify each set accordingly.
+	multipleRoots := len(appConfig.Paths) > 1
+	if multipleRoots {
+		duplicateFiles.CrossRootSets = fileChecksumIndex.GetCrossRootSetsCount()
+		duplicateFiles.IntraRootSets = len(fileChecksumIndex) - duplicateFiles.CrossRootSets
+	}
+
+	// Suggest which file from each set to keep so that users only need to
+	// review the suggestions they disagree with.
+	fileChecksumIndex.UpdateKeeps(appConfig.KeepRule())
+
+	// Wasted space is attributed to the files not suggested for keeping, so
+	// calculate it and break it down by file extension only after
+	// suggestions are made.
+	duplicateFiles.WastedSpace = fileChecksumIndex.GetWastedSpace()
+	duplicateFiles.Extensions = matches.NewExtensionSummaries(fileChecksumIndex)
+
+	// Use text/tabwriter to dump results of the calculations directly to the
+	// console. This is primarily intended for troubleshooting purposes. Keep
+	// suggestions are made first so that they can be highlighted.
+	if appConfig.ConsoleReport {
+		fileChecksumIndex.PrintFileMatches(
+			appConfig.BlankLineBetweenSets, appConfig.SortOrder, appConfig.Color.Enabled(os.Stdout))
 	}
 
-	duplicateFiles.PrintSummary()
+	duplicateFiles.PrintSummary(appConfig.Color.Enabled(os.Stdout))
+
+	reportColumns := matches.ReportColumns{
+		EXIF:           appConfig.EXIF,
+		TagDifferences: appConfig.AudioContentOnly,
+		Scope:          multipleRoots,
+		Root:           multipleRoots,
+		RelativePaths:  appConfig.RelativePaths,
+		BytesVerified:  appConfig.VerifyBytes,
+		Allocated:      appConfig.AllocatedSize,
+		ExtraChecksum:  appConfig.ExtraHash != "",
+	}
 
 	// Use CSV writer to generate an input file in order to take action
 	// TODO: Implement better error handling
-	if err := fileChecksumIndex.WriteFileMatchesCSV(
-		appConfig.OutputCSVFile, appConfig.BlankLineBetweenSets); err != nil {
-		return err
+	csvWriter, err := matches.NewSplitCSVSetWriter(
+		appConfig.OutputCSVFile, appConfig.CSVMaxSize, appConfig.BlankLineBetweenSets, reportColumns)
+	if err != nil {
+		return duplicateFiles, err
+	}
+	if err := fileChecksumIndex.WriteSets(csvWriter, appConfig.SortOrder); err != nil {
+		_ = csvWriter.Close()
+		return duplicateFiles, err
+	}
+	if err := csvWriter.Close(); err != nil {
+		return duplicateFiles, err
+	}
+	duplicateFiles.CSVFiles = logCSVFiles(csvWriter)
+
+	// The summary lists the CSV file parts (if any), so write it only once
+	// the CSV file is complete.
+	if err := writeSummaryFile(appConfig, duplicateFiles, errCounts, startTime); err != nil {
+		return duplicateFiles, err
+	}
+
+	// Generate NDJSON file IF user requested it
+	if appConfig.NDJSONFile != "" {
+		if err := fileChecksumIndex.WriteFileMatchesNDJSON(appConfig.NDJSONFile, appConfig.SortOrder, multipleRoots); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created NDJSON file: %q", appConfig.NDJSONFile)
+	}
+
+	// Post results IF user requested it
+	if appConfig.PostURL != "" {
+		collector := &matches.SetCollector{Scoped: multipleRoots}
+		if err := fileChecksumIndex.WriteSets(collector, appConfig.SortOrder); err != nil {
+			return duplicateFiles, err
+		}
+		if err := postResults(appConfig, collector, duplicateFiles, errCounts, startTime); err != nil {
+			return duplicateFiles, err
+		}
+	}
+
+	// Generate removal script IF user requested it
+	if appConfig.ScriptFormat != "" {
+		scriptWriter, err := matches.NewScriptSetWriter(
+			appConfig.ScriptFile, appConfig.ScriptFormat, appConfig.BackupDirectory)
+		if err != nil {
+			return duplicateFiles, err
+		}
+
+		// Sets are numbered as in the CSV file.
+		if err := fileChecksumIndex.WriteSets(scriptWriter, appConfig.SortOrder); err != nil {
+			_ = scriptWriter.Close()
+			return duplicateFiles, err
+		}
+
+		if err := scriptWriter.Close(); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created removal script: %q", appConfig.ScriptFile)
+	}
+
+	// Generate list of files to remove IF user requested it
+	if appConfig.RemoveListFile != "" {
+		removeListWriter, err := matches.NewRemoveListSetWriter(
+			appConfig.RemoveListFile, appConfig.NullDelimited)
+		if err != nil {
+			return duplicateFiles, err
+		}
+
+		if err := fileChecksumIndex.WriteSets(removeListWriter, appConfig.SortOrder); err != nil {
+			_ = removeListWriter.Close()
+			return duplicateFiles, err
+		}
+
+		if err := removeListWriter.Close(); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created remove list file: %q", appConfig.RemoveListFile)
+	}
+
+	// Generate near duplicates CSV file IF user requested it
+	if appConfig.NearDuplicatesCSVFile != "" {
+		if err := nearDuplicateSets.WriteNearDuplicatesCSV(
+			appConfig.NearDuplicatesCSVFile, appConfig.BlankLineBetweenSets); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created near duplicates CSV file: %q", appConfig.NearDuplicatesCSVFile)
+	}
+
+	// Generate EXIF matches CSV file IF user requested it
+	if appConfig.EXIFMatchesCSVFile != "" {
+		if err := exifMatchSets.WriteEXIFMatchesCSV(
+			appConfig.EXIFMatchesCSVFile, appConfig.BlankLineBetweenSets); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created EXIF matches CSV file: %q", appConfig.EXIFMatchesCSVFile)
+	}
+
+	// Generate related files CSV file IF user requested it
+	if appConfig.RelatedFilesCSVFile != "" {
+		if err := relatedFileSets.WriteRelatedFilesCSV(
+			appConfig.RelatedFilesCSVFile, appConfig.BlankLineBetweenSets); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created related files CSV file: %q", appConfig.RelatedFilesCSVFile)
+	}
+
+	// Generate similar files CSV file IF user requested it
+	if appConfig.SimilarFilesCSVFile != "" {
+		if err := similarFileSets.WriteSimilarFilesCSV(
+			appConfig.SimilarFilesCSVFile, appConfig.BlankLineBetweenSets); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created similar files CSV file: %q", appConfig.SimilarFilesCSVFile)
+	}
+
+	var directorySummaries matches.DirectorySummaries
+	if evaluatedFilesPerDirectory != nil {
+		directorySummaries = matches.NewDirectorySummaries(evaluatedFilesPerDirectory, fileChecksumIndex)
+	}
+
+	// Generate directory summary CSV file IF user requested it
+	if appConfig.DirSummaryCSVFile != "" {
+		if err := directorySummaries.WriteCSV(appConfig.DirSummaryCSVFile); err != nil {
+			return duplicateFiles, err
+		}
+		log.Printf("Successfully created directory summary CSV file: %q", appConfig.DirSummaryCSVFile)
+	}
+
+	// Generate extension summary CSV file IF user requested it
+	if err := writeExtSummaryFile(appConfig, duplicateFiles); err != nil {
+		return duplicateFiles, err
 	}
-	log.Printf("Successfully created CSV file: %q", appConfig.OutputCSVFile)
 
 	// Generate Excel workbook for review IF user requested it
 	if appConfig.ExcelFile != "" {
 		// TODO: Implement better error handling
-		if err := fileChecksumIndex.WriteFileMatchesWorkbook(appConfig.ExcelFile, duplicateFiles); err != nil {
-			return err
+		if err := fileChecksumIndex.WriteFileMatchesWorkbook(
+			appConfig.ExcelFile, duplicateFiles, appConfig.SortOrder, reportColumns, directorySummaries); err != nil {
+			return duplicateFiles, err
 		}
 		log.Printf("Successfully created workbook file: %q", appConfig.ExcelFile)
 	}
 
+	printNextSteps(appConfig, duplicateFiles.CSVFiles)
+
+	return duplicateFiles, nil
+
+}
+
+// streamReport generates checksums for each group of identically sized files
+// in turn, writing confirmed duplicate file sets to the CSV (and NDJSON)
+// files as soon as each group has been checksummed. Sets are not retained,
+// so the summary is accumulated as sets are written, starting from the
+// provided summary of evaluated files. Sets whose files are all unchanged
+// since the provided baseline snapshot (if any) are skipped.
+func streamReport(
+	appConfig *config.Config,
+	fileSizeIndex matches.FileSizeIndex,
+	baseline matches.Snapshot,
+	duplicateFiles matches.DuplicateFilesSummary,
+	errCounts matches.ScanErrorCounts,
+	startTime time.Time,
+) (matches.DuplicateFilesSummary, error) {
+
+	duplicateFiles.FileSizeMatches = fileSizeIndex.GetTotalFilesCount()
+	duplicateFiles.FileSizeMatchSets = len(fileSizeIndex)
+
+	multipleRoots := len(appConfig.Paths) > 1
+	reportColumns := matches.ReportColumns{
+		EXIF:          appConfig.EXIF,
+		Scope:         multipleRoots,
+		Root:          multipleRoots,
+		RelativePaths: appConfig.RelativePaths,
+		BytesVerified: appConfig.VerifyBytes,
+		Allocated:     appConfig.AllocatedSize,
+		ExtraChecksum: appConfig.ExtraHash != "",
+	}
+
+	csvWriter, err := matches.NewSplitCSVSetWriter(
+		appConfig.OutputCSVFile, appConfig.CSVMaxSize, appConfig.BlankLineBetweenSets, reportColumns)
+	if err != nil {
+		return duplicateFiles, err
+	}
+	setWriters := []matches.SetWriter{csvWriter}
+
+	if appConfig.NDJSONFile != "" {
+		ndjsonWriter, err := matches.NewNDJSONSetWriter(appConfig.NDJSONFile, multipleRoots)
+		if err != nil {
+			_ = csvWriter.Close()
+			return duplicateFiles, err
+		}
+		setWriters = append(setWriters, ndjsonWriter)
+	}
+
+	if appConfig.ScriptFormat != "" {
+		scriptWriter, err := matches.NewScriptSetWriter(
+			appConfig.ScriptFile, appConfig.ScriptFormat, appConfig.BackupDirectory)
+		if err != nil {
+			for _, sw := range setWriters {
+				_ = sw.Close()
+			}
+			return duplicateFiles, err
+		}
+		setWriters = append(setWriters, scriptWriter)
+	}
+
+	if appConfig.RemoveListFile != "" {
+		removeListWriter, err := matches.NewRemoveListSetWriter(
+			appConfig.RemoveListFile, appConfig.NullDelimited)
+		if err != nil {
+			for _, sw := range setWriters {
+				_ = sw.Close()
+			}
+			return duplicateFiles, err
+		}
+		setWriters = append(setWriters, removeListWriter)
+	}
+
+	// Sets are retained in memory until posted once the scan completes.
+	var collector *matches.SetCollector
+	if appConfig.PostURL != "" {
+		collector = &matches.SetCollector{Scoped: multipleRoots}
+		setWriters = append(setWriters, collector)
+	}
+
+	closeSetWriters := func() error {
+		var firstErr error
+		for _, sw := range setWriters {
+			if err := sw.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	keepRule := appConfig.KeepRule()
+
+	meter := checksumMeter(appConfig, fileSizeIndex)
+	ignoredChecksumErrors, err := fileSizeIndex.StreamDuplicateSets(
+		appConfig.IgnoreErrors,
+		appConfig.FileDuplicatesThreshold,
+		appConfig.ExtraHash,
+		meter,
+		func(fileMatches matches.FileMatches) error {
+
+			if appConfig.VerifyBytes {
+				verified, mismatched, ignoredVerifyErrors, err := fileMatches.VerifyBytes(appConfig.IgnoreErrors)
+				errCounts.VerifyBytes += ignoredVerifyErrors
+				if err != nil {
+					return err
+				}
+				for _, file := range mismatched {
+					// WARN
+					log.Printf(
+						"Content of %q differs from %q despite matching checksum; excluding file from duplicate file set",
+						file.FullPath,
+						verified[0].FullPath,
+					)
+				}
+				duplicateFiles.ByteMismatches += len(mismatched)
+
+				if len(verified) < appConfig.FileDuplicatesThreshold {
+					return nil
+				}
+				fileMatches = verified
+			}
+
+			if baseline != nil && !fileMatches.ChangedSince(baseline) {
+				duplicateFiles.BaselineSets++
+				return nil
+			}
+
+			fileMatches.UpdateKeep(keepRule)
+
+			if appConfig.EXIF {
+				ignoredEXIFErrors, err := fileMatches.UpdateEXIF(appConfig.IgnoreErrors)
+				errCounts.EXIF += ignoredEXIFErrors
+				if err != nil {
+					return err
+				}
+			}
+
+			if appConfig.AllocatedSize {
+				ignoredAllocatedErrors, err := fileMatches.UpdateAllocatedSizes(appConfig.IgnoreErrors)
+				errCounts.Allocated += ignoredAllocatedErrors
+				if err != nil {
+					return err
+				}
+			}
+
+			duplicateFiles.AddSet(fileMatches, multipleRoots)
+
+			for _, sw := range setWriters {
+				if err := sw.WriteSet(duplicateFiles.FileHashMatchSets, fileMatches); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	)
+	meter.Done()
+	errCounts.Checksum = ignoredChecksumErrors
+	duplicateFiles.ChangedFiles = recordChangedFiles(fileSizeIndex.ChangedFiles())
+	duplicateFiles.InUseFiles = fileSizeIndex.InUseFiles()
+	if err != nil {
+		_ = closeSetWriters()
+		log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+		return matches.DuplicateFilesSummary{}, err
+	}
+
+	if err := closeSetWriters(); err != nil {
+		return duplicateFiles, err
+	}
+	duplicateFiles.CSVFiles = logCSVFiles(csvWriter)
+	if appConfig.NDJSONFile != "" {
+		log.Printf("Successfully created NDJSON file: %q", appConfig.NDJSONFile)
+	}
+	if appConfig.ScriptFormat != "" {
+		log.Printf("Successfully created removal script: %q", appConfig.ScriptFile)
+	}
+	if appConfig.RemoveListFile != "" {
+		log.Printf("Successfully created remove list file: %q", appConfig.RemoveListFile)
+	}
+
+	duplicateFiles.PrintSummary(appConfig.Color.Enabled(os.Stdout))
+
+	if err := writeSummaryFile(appConfig, duplicateFiles, errCounts, startTime); err != nil {
+		return duplicateFiles, err
+	}
+
+	if collector != nil {
+		if err := postResults(appConfig, collector, duplicateFiles, errCounts, startTime); err != nil {
+			return duplicateFiles, err
+		}
+	}
+
+	if err := writeExtSummaryFile(appConfig, duplicateFiles); err != nil {
+		return duplicateFiles, err
+	}
+
+	printNextSteps(appConfig, duplicateFiles.CSVFiles)
+
+	return duplicateFiles, nil
+}
+
+// budgetsExceeded returns a description of each requested duplication
+// budget (see the fail-if-wasted-gt and fail-if-dupes-gt flags) exceeded by
+// the scan results.
+func budgetsExceeded(appConfig *config.Config, duplicateFiles matches.DuplicateFilesSummary) []string {
+
+	var exceeded []string
+
+	if appConfig.FailIfWastedGT > 0 && duplicateFiles.WastedSpace > appConfig.FailIfWastedGT {
+		exceeded = append(exceeded, fmt.Sprintf(
+			"%s of wasted space exceeds the budget of %s",
+			units.ByteCountIEC(duplicateFiles.WastedSpace),
+			units.ByteCountIEC(appConfig.FailIfWastedGT),
+		))
+	}
+
+	if appConfig.FailIfDupesGT > 0 && duplicateFiles.DuplicateCount > appConfig.FailIfDupesGT {
+		exceeded = append(exceeded, fmt.Sprintf(
+			"%d duplicate files exceeds the budget of %d",
+			duplicateFiles.DuplicateCount,
+			appConfig.FailIfDupesGT,
+		))
+	}
+
+	return exceeded
+}
+
+// readPreviousChecksums returns the checksums recorded in the CSV file
+// generated by an earlier run of the report subcommand (see the
+// previous-csv flag). Files which no longer exist are simply not found in
+// the current scan, so their rows are dropped.
+func readPreviousChecksums(appConfig *config.Config) (matches.RecordedChecksums, error) {
+
+	recorded := make(matches.RecordedChecksums)
+
+	_, err := parseInputCSVFile(appConfig.PreviousCSVFile, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, _ int) error {
+		fullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+		if s3.IsURL(dfsEntry.ParentDirectory) {
+			fullPath = dfsEntry.ParentDirectory + "/" + dfsEntry.Filename
+		}
+
+		recorded.Add(
+			fullPath,
+			matches.RecordedChecksum{
+				Size:     dfsEntry.SizeInBytes,
+				ModTime:  dfsEntry.Modified,
+				Checksum: dfsEntry.Checksum,
+			},
+		)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous CSV file: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// logCSVFiles logs the CSV file written by the provided writer, returning
+// the numbered parts if the file was split due to its size.
+func logCSVFiles(csvWriter *matches.SplitCSVSetWriter) []string {
+
+	files := csvWriter.Files()
+	if len(files) == 1 {
+		log.Printf("Successfully created CSV file: %q", files[0])
+		return nil
+	}
+
+	for _, file := range files {
+		log.Printf("Successfully created CSV file part: %q", file)
+	}
+
+	return files
+}
+
+// writeSummaryFile generates a machine-readable summary IF user requested
+// it.
+func writeSummaryFile(
+	appConfig *config.Config,
+	duplicateFiles matches.DuplicateFilesSummary,
+	errCounts matches.ScanErrorCounts,
+	startTime time.Time,
+) error {
+
+	if appConfig.SummaryFile == "" {
+		return nil
+	}
+
+	summaryReport := newSummaryReport(appConfig, duplicateFiles, errCounts, startTime)
+	if err := summaryReport.WriteJSON(appConfig.SummaryFile); err != nil {
+		return err
+	}
+	log.Printf("Successfully created summary file: %q", appConfig.SummaryFile)
+
+	return nil
+}
+
+// newSummaryReport creates a machine-readable summary of scan results using
+// the scan parameters from the provided configuration.
+func newSummaryReport(
+	appConfig *config.Config,
+	duplicateFiles matches.DuplicateFilesSummary,
+	errCounts matches.ScanErrorCounts,
+	startTime time.Time,
+) matches.SummaryReport {
+
+	scanParams := matches.ScanParameters{
+		Paths:                   appConfig.Paths,
+		RecursiveSearch:         appConfig.RecursiveSearch,
+		FileSizeThreshold:       appConfig.FileSizeThreshold,
+		FileDuplicatesThreshold: appConfig.FileDuplicatesThreshold,
+		IgnoreErrors:            appConfig.IgnoreErrors,
+		IgnoreErrorsFor:         appConfig.IgnoredErrorCategories(),
+	}
+
+	return matches.NewSummaryReport(duplicateFiles, scanParams, errCounts, startTime)
+}
+
+// postResults posts all duplicate file sets retained by the provided
+// collector, along with a summary of scan results, to the requested URL.
+func postResults(
+	appConfig *config.Config,
+	collector *matches.SetCollector,
+	duplicateFiles matches.DuplicateFilesSummary,
+	errCounts matches.ScanErrorCounts,
+	startTime time.Time,
+) error {
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	summaryReport := newSummaryReport(appConfig, duplicateFiles, errCounts, startTime)
+	results := collector.ResultsReport(host, summaryReport)
+
+	opts := appConfig.PublishOptions()
+	if err := publish.Post(opts, results); err != nil {
+		return err
+	}
+	log.Printf("Successfully posted results to %q", opts.RedactedURL())
+
+	return nil
+}
+
+// printNextSteps lists the steps needed to remove duplicate files using the
+// generated CSV file (or its numbered parts, if split).
+func printNextSteps(appConfig *config.Config, csvParts []string) {
 	fmt.Printf("\n\nNext steps:\n\n")
-	fmt.Printf("* Open %q\n", appConfig.OutputCSVFile)
+	switch {
+	case len(csvParts) > 0:
+		fmt.Printf("* Open each of %q\n", csvParts)
+	default:
+		fmt.Printf("* Open %q\n", appConfig.OutputCSVFile)
+	}
 	fmt.Printf("* Fill in the %q field with \"true\" for any file that you wish to remove\n",
 		matches.CSVRemoveFileColumnHeaderName)
 	fmt.Printf("* Run \"%s %s -h\" for a quick list of applicable options\n",
 		os.Args[0], config.PruneSubcommand)
 	fmt.Println("* Read the README for examples, including optional \"backup first\" behavior.")
+}
+
+// newS3FileSizeIndex lists objects from the provided S3 URLs, building a
+// combined index of all objects based on size. Objects smaller than the
+// provided size threshold are skipped. The number of errors ignored (as
+// requested) while listing objects is also returned.
+func newS3FileSizeIndex(appConfig *config.Config, s3URLs []string, fileSizeThreshold int64) (matches.FileSizeIndex, int, error) {
+
+	client, err := s3.NewClientFromEnv()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	var ignoredErrors int
+	fileSizeIndexes := make([]matches.FileSizeIndex, 0, len(s3URLs))
+
+	for _, s3URL := range s3URLs {
+
+		bucket, prefix, err := s3.ParseURL(s3URL)
+		if err != nil {
+			return nil, ignoredErrors, err
+		}
+
+		root := prefix
+		if root == "" {
+			root = "."
+		}
+
+		// DEBUG
+		log.Println("Listing objects:", s3URL)
+
+		fileSizeIndex, ignored, err := matches.ProcessRemoteFS(
+			client.NewFS(context.Background(), bucket),
+			s3.URLScheme+"://"+bucket,
+			root,
+			appConfig.RecursiveSearch,
+			appConfig.IgnoreErrors,
+			fileSizeThreshold,
+			dirExcludes(appConfig),
+		)
+		ignoredErrors += ignored
+		if err != nil {
+			return nil, ignoredErrors, fmt.Errorf("failed to process path %q: %w", s3URL, err)
+		}
+
+		fileSizeIndexes = append(fileSizeIndexes, fileSizeIndex)
+	}
+
+	return matches.MergeFileSizeIndexes(fileSizeIndexes...), ignoredErrors, nil
+}
+
+// writeExtSummaryFile generates a CSV file listing duplicate files and
+// wasted space per file extension IF user requested it.
+func writeExtSummaryFile(appConfig *config.Config, duplicateFiles matches.DuplicateFilesSummary) error {
+
+	if appConfig.ExtSummaryCSVFile == "" {
+		return nil
+	}
+
+	if err := duplicateFiles.Extensions.WriteCSV(appConfig.ExtSummaryCSVFile); err != nil {
+		return err
+	}
+	log.Printf("Successfully created extension summary CSV file: %q", appConfig.ExtSummaryCSVFile)
 
 	return nil
+}
+
+// writeSmallFilesFile removes files below the size threshold from the
+// provided index and lists them in a CSV file IF user requested it. The
+// number of files listed and how many of them are zero-byte files are
+// returned. Checksums are generated for the listed files so that the CSV
+// file can be used as input for the prune subcommand.
+func writeSmallFilesFile(
+	appConfig *config.Config,
+	fileSizeIndex matches.FileSizeIndex,
+	errCounts *matches.ScanErrorCounts,
+) (int, int, error) {
+
+	if appConfig.SmallFilesCSVFile == "" {
+		return 0, 0, nil
+	}
+
+	smallFiles := fileSizeIndex.SplitBelowSize(appConfig.FileSizeThreshold)
+
+	ignoredChecksumErrors, err := smallFiles.UpdateChecksums(appConfig.IgnoreErrors)
+	errCounts.Checksum += ignoredChecksumErrors
+	if err != nil {
+		log.Println("Exiting; error encountered, option to ignore (minor) errors not provided.")
+		return 0, 0, err
+	}
+
+	if err := smallFiles.WriteSmallFilesCSV(appConfig.SmallFilesCSVFile); err != nil {
+		return 0, 0, err
+	}
+	log.Printf("Successfully created small files CSV file: %q", appConfig.SmallFilesCSVFile)
 
+	return smallFiles.GetTotalFilesCount(), smallFiles.ZeroByteFilesCount(), nil
 }
@@ -0,0 +1,278 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/audit"
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/journal"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/progress"
+	"github.com/atc0005/bridge/internal/s3"
+)
+
+// s3BackupKey returns the key used for the backup copy of the specified file
+// within the S3 backup location. As with backup directories, the original
+// path structure is preserved below the key prefix.
+func s3BackupKey(prefix string, filename string) (string, error) {
+
+	name, err := archive.MemberName(filename)
+	if err != nil {
+		return "", err
+	}
+
+	return s3.JoinKey(prefix, name), nil
+}
+
+// s3BackupDestination returns the key to upload the backup copy of the
+// specified file to, applying the collision policy if an object already
+// exists with the same key. True is returned if an identical copy already
+// exists at the returned key.
+func s3BackupDestination(
+	ctx context.Context,
+	client *s3.Client,
+	bucket string,
+	key string,
+	sourceFilename string,
+	collision paths.CollisionPolicy,
+) (string, bool, error) {
+
+	exists, err := client.ObjectExists(ctx, bucket, key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if !exists {
+		return key, false, nil
+	}
+
+	switch collision {
+	case paths.CollisionSkipIfIdentical:
+		content, err := s3.HashFile(sourceFilename)
+		if err != nil {
+			return "", false, err
+		}
+
+		same, err := client.ObjectMatches(ctx, bucket, key, content)
+		if err != nil {
+			return "", false, fmt.Errorf(
+				"failed to compare %q with existing destination object: %w",
+				sourceFilename,
+				err,
+			)
+		}
+
+		if same {
+			log.Printf(
+				"Destination object %q already exists with identical content; skipping backup of %q\n",
+				s3.URL(bucket, key),
+				sourceFilename,
+			)
+			return key, true, nil
+		}
+
+		return "", false, fmt.Errorf(
+			"%w: destination object %q already exists with different content; skipping backup of %q to prevent overwriting existing object",
+			paths.ErrBackupCollision,
+			s3.URL(bucket, key),
+			sourceFilename,
+		)
+
+	case paths.CollisionRename:
+		ext := path.Ext(key)
+		name := strings.TrimSuffix(key, ext)
+
+		for suffix := 1; ; suffix++ {
+			candidate := fmt.Sprintf("%s_%d%s", name, suffix, ext)
+
+			exists, err := client.ObjectExists(ctx, bucket, candidate)
+			if err != nil {
+				return "", false, err
+			}
+
+			if !exists {
+				log.Printf(
+					"Destination object %q already exists; backing up %q as %q\n",
+					s3.URL(bucket, key),
+					sourceFilename,
+					s3.URL(bucket, candidate),
+				)
+				return candidate, false, nil
+			}
+		}
+
+	default:
+		return "", false, fmt.Errorf(
+			"%w: destination object %q already exists; skipping backup of %q to prevent overwriting existing object",
+			paths.ErrBackupCollision,
+			s3.URL(bucket, key),
+			sourceFilename,
+		)
+	}
+}
+
+// backupFileToS3 uploads the specified file to the S3 backup location (unless
+// an identical copy is already present), then confirms that the uploaded
+// object matches the file before it is removed. The S3 URL of the backup
+// copy is returned.
+func backupFileToS3(
+	ctx context.Context,
+	client *s3.Client,
+	bucket string,
+	prefix string,
+	file dupesets.DuplicateFileSetEntry,
+	collision paths.CollisionPolicy,
+) (string, error) {
+
+	fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+
+	key, err := s3BackupKey(prefix, fullPathToFile)
+	if err != nil {
+		return "", err
+	}
+
+	key, identical, err := s3BackupDestination(ctx, client, bucket, key, fullPathToFile, collision)
+	if err != nil {
+		return "", err
+	}
+
+	backupURL := s3.URL(bucket, key)
+
+	var content s3.ContentInfo
+	if identical {
+		content, err = s3.HashFile(fullPathToFile)
+	} else {
+		content, err = client.UploadFile(ctx, bucket, key, fullPathToFile)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The file may have changed since its checksum was verified.
+	if !file.Checksum.IsContentChecksum() &&
+		!file.Checksum.IsByteComparison() &&
+		!file.Checksum.IsPartial() &&
+		checksums.ParseChecksum(content.SHA256) != file.Checksum {
+		return backupURL, fmt.Errorf(
+			"backup copy %q of %q failed verification: checksum mismatch, file likely modified; got %s, expected %s",
+			backupURL,
+			fullPathToFile,
+			checksums.ParseChecksum(content.SHA256),
+			file.Checksum,
+		)
+	}
+
+	same, err := client.ObjectMatches(ctx, bucket, key, content)
+	if err != nil {
+		return backupURL, fmt.Errorf(
+			"backup copy %q of %q failed verification: %w",
+			backupURL,
+			fullPathToFile,
+			err,
+		)
+	}
+
+	if !same {
+		return backupURL, fmt.Errorf(
+			"backup copy %q of %q failed verification: object content differs from file",
+			backupURL,
+			fullPathToFile,
+		)
+	}
+
+	return backupURL, nil
+}
+
+// backupToS3 copies the provided files to the S3 backup location specified
+// by the user, confirming that each uploaded copy is good before the
+// original files are removed. Backup paths are recorded in the provided map;
+// files whose copies fail verification are recorded as not to be removed if
+// the user opted to ignore errors. Files are counted against the provided
+// progress meter as they are uploaded.
+func backupToS3(
+	appConfig *config.Config,
+	filesToRemove dupesets.DuplicateFileSetEntries,
+	auditLog *audit.Log,
+	runJournal *journal.Journal,
+	backupPaths map[string]string,
+	skipRemoval map[string]bool,
+	meter *progress.Meter,
+) error {
+
+	bucket, prefix, err := s3.ParseURL(appConfig.BackupDirectory)
+	if err != nil {
+		return err
+	}
+
+	client, err := s3.NewClientFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx := context.Background()
+
+	var filesBackedUp int
+	for _, file := range filesToRemove {
+
+		fullPathToFile := filepath.Join(file.ParentDirectory, file.Filename)
+
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateStarted, "", nil)); err != nil {
+			return err
+		}
+
+		backupPath, err := backupFileToS3(ctx, client, bucket, prefix, file, appConfig.BackupCollision)
+		meter.Add(file.SizeInBytes)
+		if err != nil {
+			entry := auditEntry(file, audit.ActionBackup, audit.OutcomeFailed, err)
+			entry.BackupPath = backupPath
+			if err := auditLog.Record(entry); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+			if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateFailed, backupPath, err)); err != nil {
+				log.Println("Error encountered recording backup failure:", err)
+			}
+
+			log.Println("Error encountered backing up file:", err)
+			if errreport.Ignore(appConfig.IgnoreErrors, err) {
+				log.Printf("IgnoringErrors set, not removing %q\n", fullPathToFile)
+				errreport.Record(fullPathToFile, errreport.OperationBackup, err)
+				skipRemoval[fullPathToFile] = true
+				continue
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		backupPaths[fullPathToFile] = backupPath
+		filesBackedUp++
+
+		entry := auditEntry(file, audit.ActionBackup, audit.OutcomeSuccess, nil)
+		entry.BackupPath = backupPath
+		if err := auditLog.Record(entry); err != nil {
+			return err
+		}
+		if err := runJournal.Record(journalEntry(file, journal.ActionBackup, journal.StateCompleted, backupPath, nil)); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%d files backed up to %q", filesBackedUp, appConfig.BackupDirectory)
+
+	return nil
+}
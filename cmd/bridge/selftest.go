@@ -0,0 +1,387 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// errSelfTestFailed indicates that the results of a step of the self-test
+// did not match expectations.
+var errSelfTestFailed = errors.New("self-test failed")
+
+// selfTestFileRole is the expected outcome for a file created by the
+// self-test.
+type selfTestFileRole int
+
+// Expected outcomes for files created by the self-test
+const (
+
+	// selfTestUnique files are not duplicates of any other file and are
+	// expected to be left out of the report
+	selfTestUnique selfTestFileRole = iota
+
+	// selfTestKeep files are the oldest file of a duplicate file set and are
+	// expected to be kept
+	selfTestKeep
+
+	// selfTestRemove files are newer copies of a kept file and are expected
+	// to be backed up and removed
+	selfTestRemove
+)
+
+// selfTestFile is a file created by the self-test.
+type selfTestFile struct {
+
+	// path is the path to the file relative to the test directory
+	path string
+
+	// content is the content written to the file
+	content []byte
+
+	// role is the expected outcome for the file
+	role selfTestFileRole
+
+	// set is the duplicate file set the file is expected to be reported in
+	set string
+}
+
+// selfTestFiles returns the files created by the self-test: duplicate file
+// sets spread across directories (including a file large enough to be read
+// in several chunks and a file sharing its name with its duplicate) along
+// with files which share a size, but not content, with a duplicate and an
+// empty file, none of which should be reported.
+func selfTestFiles() []selfTestFile {
+
+	small := []byte("bridge self-test\n")
+	text := []byte("duplicate text content\n")
+	large := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+
+	// Same size as large, but with different content in the last chunk.
+	largeAltered := append([]byte(nil), large...)
+	largeAltered[len(largeAltered)-1] = 'x'
+
+	// Same size as text, but with different content.
+	textAltered := []byte("different text content\n")
+
+	return []selfTestFile{
+		{path: filepath.Join("photos", "beach.jpg"), content: small, role: selfTestKeep, set: "small"},
+		{path: filepath.Join("photos", "beach (copy).jpg"), content: small, role: selfTestRemove, set: "small"},
+		{path: filepath.Join("archive", "2020", "beach.jpg"), content: small, role: selfTestRemove, set: "small"},
+		{path: filepath.Join("docs", "notes.txt"), content: text, role: selfTestKeep, set: "text"},
+		{path: filepath.Join("docs", "old", "notes.txt"), content: text, role: selfTestRemove, set: "text"},
+		{path: filepath.Join("docs", "other.txt"), content: textAltered, role: selfTestUnique},
+		{path: filepath.Join("video", "clip.mp4"), content: large, role: selfTestKeep, set: "large"},
+		{path: filepath.Join("downloads", "clip.mp4"), content: large, role: selfTestRemove, set: "large"},
+		{path: filepath.Join("video", "clip-edited.mp4"), content: largeAltered, role: selfTestUnique},
+		{path: filepath.Join("docs", "empty.txt"), content: []byte{}, role: selfTestUnique},
+	}
+}
+
+// selfTestSubcommand is a wrapper around the "selftest" subcommand logic. A
+// temporary directory of known duplicate files is created and the report,
+// prune (dry-run) and prune subcommands of this executable are run against
+// it in turn, confirming after each step that the expected files were
+// reported, left in place, backed up and removed. This gives users and
+// packagers a quick confidence check on a new platform or release.
+func selfTestSubcommand(appConfig *config.Config) error {
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("unable to determine path to this executable: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "bridge-selftest-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary directory: %w", err)
+	}
+
+	// Reported paths have symlinks (e.g., /var on macOS) resolved.
+	if resolved, err := filepath.EvalSymlinks(tempDir); err == nil {
+		tempDir = resolved
+	}
+
+	if appConfig.KeepSelfTestDir {
+		fmt.Printf("Keeping self-test directory %q\n", tempDir)
+	} else {
+		defer func() {
+			if err := os.RemoveAll(tempDir); err != nil {
+				log.Printf("error occurred removing self-test directory %q: %v", tempDir, err)
+			}
+		}()
+	}
+
+	dataDir := filepath.Join(tempDir, "data")
+	backupDir := filepath.Join(tempDir, "backup")
+	csvFile := filepath.Join(tempDir, "report.csv")
+
+	files := selfTestFiles()
+	if err := createSelfTestFiles(dataDir, files); err != nil {
+		return err
+	}
+
+	steps := []struct {
+		name   string
+		args   []string
+		exit   int
+		verify func() error
+	}{
+		{
+			name: "report duplicate files",
+			args: []string{
+				config.ReportSubcommand,
+				"-recurse",
+				"-path", dataDir,
+				"-csvfile", csvFile,
+				"-keep-policy", "oldest",
+				"-no-progress",
+			},
+			exit: exitCodeDuplicatesFound,
+			verify: func() error {
+				return verifySelfTestReport(csvFile, dataDir, files)
+			},
+		},
+		{
+			name: "prune duplicate files (dry-run)",
+			args: []string{
+				config.PruneSubcommand,
+				"-input-csvfile", csvFile,
+				"-remove-unkept",
+				"-backup-dir", backupDir,
+				"-create-backup-dir",
+				"-dry-run",
+			},
+			exit: exitCodeSuccess,
+			verify: func() error {
+				return verifySelfTestFiles(dataDir, backupDir, files, false)
+			},
+		},
+		{
+			name: "prune duplicate files",
+			args: []string{
+				config.PruneSubcommand,
+				"-input-csvfile", csvFile,
+				"-remove-unkept",
+				"-backup-dir", backupDir,
+				"-create-backup-dir",
+				"-yes",
+			},
+			exit: exitCodeSuccess,
+			verify: func() error {
+				return verifySelfTestFiles(dataDir, backupDir, files, true)
+			},
+		},
+	}
+
+	for i, step := range steps {
+		output, err := runSelfTestStep(executable, step.exit, step.args...)
+		if err == nil {
+			err = step.verify()
+		}
+
+		if err != nil {
+			fmt.Printf("[%d/%d] %s: FAILED\n", i+1, len(steps), step.name)
+			fmt.Printf("\nCommand: %s %s\n", executable, strings.Join(step.args, " "))
+			fmt.Printf("Output:\n%s\n", output)
+			return fmt.Errorf("%w: %s: %w", errSelfTestFailed, step.name, err)
+		}
+
+		fmt.Printf("[%d/%d] %s: ok\n", i+1, len(steps), step.name)
+	}
+
+	fmt.Printf("\nSelf-test passed: %d steps completed as expected.\n", len(steps))
+
+	return nil
+}
+
+// createSelfTestFiles writes the self-test files within the specified
+// directory. Files expected to be kept are given the oldest modification
+// time of their set so that the oldest keep policy selects them.
+func createSelfTestFiles(dir string, files []selfTestFile) error {
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for i, file := range files {
+		path := filepath.Join(dir, file.path)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("unable to create self-test directory: %w", err)
+		}
+
+		if err := os.WriteFile(path, file.content, 0o600); err != nil {
+			return fmt.Errorf("unable to create self-test file: %w", err)
+		}
+
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if file.role == selfTestKeep {
+			modTime = base.Add(-time.Duration(i+1) * time.Minute)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			return fmt.Errorf("unable to set modification time of self-test file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// runSelfTestStep runs this executable with the provided arguments,
+// returning its combined output. An error is returned if the exit code
+// differs from the expected exit code.
+func runSelfTestStep(executable string, expectedExitCode int, args ...string) ([]byte, error) {
+
+	// #nosec G204; the executable is this application and the arguments
+	// are fixed apart from paths within the self-test directory
+	cmd := exec.Command(executable, args...)
+	output, err := cmd.CombinedOutput()
+
+	exitCode := exitCodeSuccess
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return output, err
+	}
+
+	if exitCode != expectedExitCode {
+		return output, fmt.Errorf("exit code %d returned, expected %d", exitCode, expectedExitCode)
+	}
+
+	return output, nil
+}
+
+// verifySelfTestReport confirms that the CSV file generated by the report
+// subcommand lists exactly the expected duplicate file sets, with the
+// oldest file of each set marked to be kept.
+func verifySelfTestReport(csvFile string, dataDir string, files []selfTestFile) error {
+
+	rows, err := readInputCSVRows(csvFile, &config.Config{})
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]selfTestFile)
+	for _, file := range files {
+		if file.role != selfTestUnique {
+			expected[filepath.Join(dataDir, file.path)] = file
+		}
+	}
+
+	// Map each expected set to the set ID reported for its files.
+	reportedSets := make(map[string]string)
+	seen := make(map[string]bool)
+
+	for _, row := range rows {
+		entry := row.entry
+		path := filepath.Join(entry.ParentDirectory, entry.Filename)
+
+		file, ok := expected[path]
+		if !ok {
+			return fmt.Errorf("unexpected file %q reported as a duplicate", path)
+		}
+		seen[path] = true
+
+		switch setID, ok := reportedSets[file.set]; {
+		case !ok:
+			reportedSets[file.set] = entry.SetID
+		case setID != entry.SetID:
+			return fmt.Errorf("file %q reported in set %s instead of set %s", path, entry.SetID, setID)
+		}
+
+		if entry.Keep != (file.role == selfTestKeep) {
+			return fmt.Errorf("file %q reported with keep value %t", path, entry.Keep)
+		}
+	}
+
+	var missing []string
+	for path := range expected {
+		if !seen[path] {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("duplicate files not reported: %v", missing)
+	}
+
+	setIDs := make(map[string]bool)
+	for _, setID := range reportedSets {
+		setIDs[setID] = true
+	}
+	if len(setIDs) != len(reportedSets) {
+		return fmt.Errorf("%d duplicate file sets reported, expected %d", len(setIDs), len(reportedSets))
+	}
+
+	return nil
+}
+
+// verifySelfTestFiles confirms that the self-test files expected to be
+// removed have been (or, if not pruned, have not been) removed and backed
+// up, and that all other files are unchanged.
+func verifySelfTestFiles(dataDir string, backupDir string, files []selfTestFile, pruned bool) error {
+
+	for _, file := range files {
+		path := filepath.Join(dataDir, file.path)
+
+		backupPath, err := paths.PlannedBackupPath(path, backupDir)
+		if err != nil {
+			return err
+		}
+
+		removed := pruned && file.role == selfTestRemove
+
+		switch {
+		case removed:
+			if paths.PathExists(path) {
+				return fmt.Errorf("file %q not removed", path)
+			}
+			if err := verifySelfTestContent(backupPath, file.content); err != nil {
+				return fmt.Errorf("backup copy of %q: %w", path, err)
+			}
+
+		default:
+			if err := verifySelfTestContent(path, file.content); err != nil {
+				return err
+			}
+			if paths.PathExists(backupPath) {
+				return fmt.Errorf("unexpected backup copy %q", backupPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifySelfTestContent confirms that the specified file exists with the
+// expected content.
+func verifySelfTestContent(path string, expected []byte) error {
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(content, expected) {
+		return fmt.Errorf("content of file %q changed", path)
+	}
+
+	return nil
+}
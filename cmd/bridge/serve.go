@@ -0,0 +1,45 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/metrics"
+	"github.com/atc0005/bridge/internal/server"
+)
+
+// serveSubcommand is a wrapper around the "serve" subcommand logic. The API
+// is served until the application is interrupted.
+func serveSubcommand(appConfig *config.Config) error {
+
+	srv := server.New(server.Options{
+		BackupDirectory: appConfig.BackupDirectory,
+		DryRun:          appConfig.DryRun,
+		Token:           appConfig.APIToken,
+		Metrics:         metrics.New(),
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if appConfig.APIToken == "" {
+		// WARN
+		log.Println("No API token specified; API requests addressed to localhost will be accepted without authentication")
+	}
+
+	log.Printf("Serving API on http://%s", appConfig.ListenAddress)
+	log.Printf("Serving metrics on http://%s%s", appConfig.ListenAddress, metrics.Endpoint)
+
+	return srv.ListenAndServe(ctx, appConfig.ListenAddress)
+}
@@ -0,0 +1,276 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// statsListLimit is the maximum number of file extensions and directories
+// listed individually in the console summary.
+const statsListLimit = 10
+
+// whatIfDirectoryLimit is the maximum number of directories listed for each
+// keep policy evaluated by the what-if analysis.
+const whatIfDirectoryLimit = 3
+
+// reportStats is the summary of one or more previously generated CSV
+// reports.
+type reportStats struct {
+
+	// InputFiles is the list of CSV files summarized
+	InputFiles []string `json:"input_files"`
+
+	// Files is the number of files listed in the CSV files
+	Files int `json:"files"`
+
+	// Sets is the number of duplicate file sets listed in the CSV files
+	Sets int `json:"duplicate_file_sets"`
+
+	// DuplicateFiles is the number of listed files which are duplicates of
+	// the file kept from each set
+	DuplicateFiles int `json:"duplicate_files"`
+
+	// WastedSpace is the space (in bytes) reclaimed by removing all
+	// duplicate files, keeping the file marked as the original to keep
+	// from each set
+	WastedSpace int64 `json:"wasted_space"`
+
+	// PartialSets is the number of sets matched using partial checksums
+	// only
+	PartialSets int `json:"partial_sets"`
+
+	// FlaggedFiles is the number of files flagged for removal
+	FlaggedFiles int `json:"flagged_files"`
+
+	// FlaggedSize is the total size (in bytes) of files flagged for removal
+	FlaggedSize int64 `json:"flagged_size"`
+
+	// Extensions is the number of duplicate files and wasted space per file
+	// extension
+	Extensions matches.ExtensionSummaries `json:"extensions,omitempty"`
+
+	// KeepPolicies is the effect of applying each keep policy, if requested
+	KeepPolicies []matches.KeepPolicyImpact `json:"keep_policies,omitempty"`
+
+	// UnknownModTimes is the number of listed files without a recorded
+	// modification time, if keep policies were evaluated
+	UnknownModTimes int `json:"unknown_mod_times,omitempty"`
+}
+
+// newReportStats summarizes the duplicate file sets in the provided index,
+// listing the specified files flagged for removal.
+func newReportStats(inputFiles []string, fi matches.FileChecksumIndex, flagged dupesets.DuplicateFileSetEntries) reportStats {
+	return reportStats{
+		InputFiles:     inputFiles,
+		Files:          fi.GetTotalFilesCount(),
+		Sets:           len(fi),
+		DuplicateFiles: fi.GetDuplicateFilesCount(),
+		WastedSpace:    fi.GetWastedSpace(),
+		PartialSets:    fi.GetPartialSetsCount(),
+		FlaggedFiles:   len(flagged),
+		FlaggedSize:    flagged.TotalSize(),
+		Extensions:     matches.NewExtensionSummaries(fi),
+	}
+}
+
+// printReportStats writes the provided summary along with the top
+// directories (by wasted space) to stdout.
+func printReportStats(stats reportStats, directories matches.DirectorySummaries) {
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Report statistics:")
+	_, _ = fmt.Fprintf(w, "%d\tinput CSV files\n", len(stats.InputFiles))
+	_, _ = fmt.Fprintf(w, "%d\tfiles listed\n", stats.Files)
+	_, _ = fmt.Fprintf(w, "%d\tduplicate file sets\n", stats.Sets)
+	_, _ = fmt.Fprintf(w, "%d\tduplicate files\n", stats.DuplicateFiles)
+	_, _ = fmt.Fprintf(w, "%s\twasted space for duplicate file sets\n", units.ByteCountIEC(stats.WastedSpace))
+	if stats.PartialSets > 0 {
+		_, _ = fmt.Fprintf(w, "%d\tduplicate file sets matched using partial checksums only (UNVERIFIED)\n", stats.PartialSets)
+	}
+	_, _ = fmt.Fprintf(w, "%d\tfiles flagged for removal\n", stats.FlaggedFiles)
+	_, _ = fmt.Fprintf(w, "%s\ttotal size of files flagged for removal\n", units.ByteCountIEC(stats.FlaggedSize))
+	_, _ = fmt.Fprintln(w)
+
+	if len(stats.Extensions) > 0 {
+		_, _ = fmt.Fprintln(w, "Wasted space by file extension:")
+		_, _ = fmt.Fprintln(w, "Extension\tFiles\tSize")
+		for _, es := range stats.Extensions.Top(statsListLimit) {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n",
+				es.Extension,
+				es.DuplicateFiles,
+				units.ByteCountIEC(es.WastedSpace),
+			)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(directories) > 0 {
+		_, _ = fmt.Fprintln(w, "Wasted space by directory:")
+		_, _ = fmt.Fprintln(w, "Files\tSize\tDirectory")
+		for i, ds := range directories {
+			if i == statsListLimit {
+				_, _ = fmt.Fprintf(w, "(%d more directories not shown)\n", len(directories)-statsListLimit)
+				break
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n",
+				ds.DuplicateFiles,
+				units.ByteCountIEC(ds.WastedSpace),
+				ds.Directory,
+			)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// printKeepPolicyImpacts writes the effect of applying each keep policy
+// (see the what-if flag) to stdout.
+func printKeepPolicyImpacts(stats reportStats) {
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Keep policy what-if analysis:")
+	_, _ = fmt.Fprintln(w, "Policy\tFiles removed\tSpace reclaimed\tSets changed")
+	for _, impact := range stats.KeepPolicies {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%d\n",
+			impact.Policy,
+			impact.FilesRemoved,
+			units.ByteCountIEC(impact.SpaceReclaimed),
+			impact.ChangedSets,
+		)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	for _, impact := range stats.KeepPolicies {
+		_, _ = fmt.Fprintf(w, "Most space reclaimed by the %s policy:\n", impact.Policy)
+		for _, dir := range impact.Directories {
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n",
+				dir.FilesRemoved,
+				units.ByteCountIEC(dir.SpaceReclaimed),
+				dir.Directory,
+			)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if stats.UnknownModTimes > 0 {
+		_, _ = fmt.Fprintf(w,
+			"NOTE: %d files have no recorded modification time; the oldest and newest policies fall back to path order for these.\n\n",
+			stats.UnknownModTimes,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// writeReportStats writes the provided summary to the specified file in
+// JSON format.
+func writeReportStats(filename string, stats reportStats) error {
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary as JSON: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Clean(filename), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write summary file %q: %w", filename, err)
+	}
+
+	return nil
+}
+
+// statsSubcommand is a wrapper around the "stats" subcommand logic. One or
+// more previously generated CSV reports are summarized using the details
+// recorded in them, without accessing the listed files, so that old reports
+// can be analyzed again (e.g., after being edited or on another system).
+func statsSubcommand(appConfig *config.Config) error {
+
+	inputFiles, err := inputCSVFiles(appConfig.InputCSVFiles)
+	if err != nil {
+		return err
+	}
+
+	var dfsEntries dupesets.DuplicateFileSetEntries
+	for _, inputFile := range inputFiles {
+		_, err := parseInputCSVFile(inputFile, appConfig, func(dfsEntry dupesets.DuplicateFileSetEntry, _ int) error {
+			dfsEntries = append(dfsEntries, dfsEntry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fileChecksumIndex := dfsEntries.FileChecksumIndex()
+	stats := newReportStats(inputFiles, fileChecksumIndex, dfsEntries.FilesToRemove())
+
+	// The number of files evaluated per directory is not recorded in
+	// reports, so directories are not flagged as removable.
+	directories := matches.NewDirectorySummaries(nil, fileChecksumIndex)
+
+	printReportStats(stats, directories)
+
+	if appConfig.WhatIf {
+		stats.KeepPolicies = fileChecksumIndex.KeepPolicyImpacts(appConfig.KeepRule(), whatIfDirectoryLimit)
+		for _, entry := range dfsEntries {
+			if entry.Modified.IsZero() {
+				stats.UnknownModTimes++
+			}
+		}
+
+		printKeepPolicyImpacts(stats)
+	}
+
+	if appConfig.DirSummaryCSVFile != "" {
+		if err := directories.WriteCSV(appConfig.DirSummaryCSVFile); err != nil {
+			return err
+		}
+		log.Printf("Successfully created directory summary CSV file: %q", appConfig.DirSummaryCSVFile)
+	}
+
+	if appConfig.ExtSummaryCSVFile != "" {
+		if err := stats.Extensions.WriteCSV(appConfig.ExtSummaryCSVFile); err != nil {
+			return err
+		}
+		log.Printf("Successfully created extension summary CSV file: %q", appConfig.ExtSummaryCSVFile)
+	}
+
+	if appConfig.SummaryFile != "" {
+		if err := writeReportStats(appConfig.SummaryFile, stats); err != nil {
+			return err
+		}
+		log.Printf("Successfully created summary file: %q", appConfig.SummaryFile)
+	}
+
+	return nil
+}
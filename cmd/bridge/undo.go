@@ -0,0 +1,389 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/journal"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/s3"
+)
+
+// errNothingToUndo indicates that the undo subcommand was asked to undo a
+// prune run, but the journal records no run.
+var errNothingToUndo = errors.New("no prune run to undo")
+
+// errNoBackupCopy indicates that no backup copy was recorded for a removed
+// file, e.g., since the prune run did not use a backup destination.
+var errNoBackupCopy = errors.New("no backup copy recorded")
+
+// restoreTempSuffix is appended to the name of each restored file to name
+// the temporary file written alongside it before the content is verified.
+const restoreTempSuffix = ".bridge-undo"
+
+// Outcomes of restoring a removed file
+const (
+	restoreRestored string = "restored"
+	restorePresent  string = "present"
+	restorePending  string = "dry-run"
+	restoreConflict string = "conflict"
+	restoreFailed   string = "failed"
+)
+
+// restoreResult is the outcome of restoring a single removed file.
+type restoreResult struct {
+	path       string
+	backupPath string
+	status     string
+	err        error
+}
+
+// detail returns a brief description of the outcome for display.
+func (result restoreResult) detail() string {
+	switch {
+	case result.err != nil:
+		return result.err.Error()
+	case result.status == restorePresent:
+		return "original path already holds a matching file"
+	case result.status == restorePending:
+		return "would restore from " + result.backupPath
+	default:
+		return ""
+	}
+}
+
+// removedFiles returns the journal entry recording the start of each
+// removal among the provided entries for files which were removed (or whose
+// removal started and which no longer exist), in the reverse order the
+// removals were started. If the removal of a file was started more than
+// once (e.g., by a resumed run), the latest entry is used.
+func removedFiles(entries []journal.Entry) []journal.Entry {
+
+	states := journal.FileStates(entries)
+
+	var order []string
+	latest := make(map[string]journal.Entry)
+	for _, entry := range entries {
+		if entry.Action != journal.ActionRemove || entry.State != journal.StateStarted {
+			continue
+		}
+
+		if _, ok := latest[entry.Path]; !ok {
+			order = append(order, entry.Path)
+		}
+		latest[entry.Path] = entry
+	}
+
+	removed := make([]journal.Entry, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		path := order[i]
+		if !states[path].Removed && paths.PathExists(path) {
+			continue
+		}
+
+		entry := latest[path]
+		if entry.BackupPath == "" {
+			entry.BackupPath = states[path].BackupPath
+		}
+		removed = append(removed, entry)
+	}
+
+	return removed
+}
+
+// restorer restores removed files from their backup copies. The Amazon S3
+// client is created when the first backup copy stored in Amazon S3 is
+// restored.
+type restorer struct {
+	ctx      context.Context
+	s3Client *s3.Client
+	dryRun   bool
+}
+
+// copyBackup writes the content of the specified backup copy (a local file,
+// an archive member recorded as archive#member or an Amazon S3 object) to
+// w.
+func (r *restorer) copyBackup(w io.Writer, backupPath string) error {
+
+	if archiveFile, member, ok := archiveMemberPath(backupPath); ok {
+		return archive.Extract(archiveFile, member, w)
+	}
+
+	var src io.ReadCloser
+
+	switch {
+	case s3.IsURL(backupPath):
+		bucket, key, err := s3.ParseURL(backupPath)
+		if err != nil {
+			return err
+		}
+
+		if r.s3Client == nil {
+			client, err := s3.NewClientFromEnv()
+			if err != nil {
+				return fmt.Errorf("failed to create S3 client: %w", err)
+			}
+			r.s3Client = client
+		}
+
+		src, err = r.s3Client.GetObject(r.ctx, bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve backup copy %q: %w", backupPath, err)
+		}
+
+	default:
+		file, err := os.Open(filepath.Clean(backupPath))
+		if err != nil {
+			return fmt.Errorf("failed to open backup copy %q: %w", backupPath, err)
+		}
+		src = file
+	}
+
+	_, copyErr := io.Copy(w, src)
+	closeErr := src.Close()
+
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		return fmt.Errorf("failed to read backup copy %q: %w", backupPath, err)
+	}
+
+	return nil
+}
+
+// restoreCopy copies the backup copy of the specified file to a temporary
+// file alongside the original path (creating the parent directory if it no
+// longer exists), verifies the copy against the checksum recorded for the
+// file and then moves it into place.
+func (r *restorer) restoreCopy(entry journal.Entry, checksum checksums.SHA256Checksum) error {
+
+	dir := filepath.Dir(entry.Path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", dir, err)
+	}
+
+	tempPath := filepath.Join(dir, "."+filepath.Base(entry.Path)+restoreTempSuffix)
+
+	// #nosec G302
+	// Restored files receive the default permissions for new files (subject
+	// to the umask), as do backup copies.
+	tempFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file %q: %w", tempPath, err)
+	}
+
+	var moved bool
+	defer func() {
+		if !moved {
+			if err := os.Remove(tempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Println("Error encountered removing temporary file:", err)
+			}
+		}
+	}()
+
+	copyErr := r.copyBackup(tempFile, entry.BackupPath)
+	var syncErr error
+	if copyErr == nil {
+		syncErr = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+
+	if err := errors.Join(copyErr, syncErr, closeErr); err != nil {
+		return err
+	}
+
+	if err := checksum.Verify(tempPath); err != nil {
+		return fmt.Errorf("backup copy %q does not match the checksum recorded for the file: %w", entry.BackupPath, err)
+	}
+
+	// The original path is checked again in case a file was created there
+	// while the backup copy was restored.
+	if paths.PathExists(entry.Path) {
+		return fmt.Errorf("a file was created at %q while restoring it; not overwriting", entry.Path)
+	}
+
+	if err := os.Rename(tempPath, entry.Path); err != nil {
+		return fmt.Errorf("failed to move restored file into place: %w", err)
+	}
+	moved = true
+
+	return nil
+}
+
+// restore restores the removed file recorded by the provided journal entry
+// from its backup copy. Existing files at the original path are never
+// overwritten; a file matching the checksum recorded for the removed file
+// (e.g., restored by an earlier undo) is reported as already present.
+func (r *restorer) restore(entry journal.Entry) restoreResult {
+
+	result := restoreResult{
+		path:       entry.Path,
+		backupPath: entry.BackupPath,
+	}
+
+	checksum := checksums.ParseChecksum(entry.Checksum)
+
+	if paths.PathExists(entry.Path) {
+		if err := checksum.Verify(entry.Path); err != nil {
+			result.status = restoreConflict
+			result.err = fmt.Errorf("a different file exists at the original path: %w", err)
+			return result
+		}
+		result.status = restorePresent
+		return result
+	}
+
+	if entry.BackupPath == "" {
+		result.status = restoreFailed
+		result.err = errNoBackupCopy
+		return result
+	}
+
+	if r.dryRun {
+		result.status = restorePending
+		return result
+	}
+
+	if err := r.restoreCopy(entry, checksum); err != nil {
+		result.status = restoreFailed
+		result.err = err
+		return result
+	}
+
+	result.status = restoreRestored
+
+	return result
+}
+
+// printRestoreResults writes a summary of the provided restore results to
+// stdout, listing each file which was not restored.
+func printRestoreResults(results []restoreResult) {
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.status]++
+	}
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Undo summary:")
+	_, _ = fmt.Fprintf(w, "%d\tfiles restored\n", counts[restoreRestored])
+	if counts[restorePending] > 0 {
+		_, _ = fmt.Fprintf(w, "%d\tfiles to restore (dry-run)\n", counts[restorePending])
+	}
+	_, _ = fmt.Fprintf(w, "%d\tfiles already present\n", counts[restorePresent])
+	_, _ = fmt.Fprintf(w, "%d\tfiles not restored; a different file exists at the original path\n", counts[restoreConflict])
+	_, _ = fmt.Fprintf(w, "%d\tfiles failed to restore\n", counts[restoreFailed])
+	_, _ = fmt.Fprintln(w)
+
+	if len(results) == counts[restoreRestored] {
+		if err := w.Flush(); err != nil {
+			log.Printf(
+				"error occurred flushing tabwriter: %v",
+				err,
+			)
+		}
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", "Status", "File", "Detail")
+	for _, result := range results {
+		if result.status == restoreRestored {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n",
+			result.status,
+			result.path,
+			result.detail(),
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// undoSubcommand is a wrapper around the "undo" subcommand logic. Files
+// removed by the last prune run recorded in the journal are restored from
+// their backup copies in the reverse order removed, verifying each restored
+// file against the checksum recorded when it was removed. Files which
+// cannot be restored are reported instead of stopping the undo.
+func undoSubcommand(appConfig *config.Config) error {
+
+	entries, err := journal.Read(appConfig.JournalFile)
+	if err != nil {
+		return err
+	}
+
+	lastRun := journal.LastRun(entries)
+	if len(lastRun) == 0 {
+		return fmt.Errorf("%w: journal %q records no runs", errNothingToUndo, appConfig.JournalFile)
+	}
+
+	if _, ok := journal.Incomplete(entries); ok {
+		// WARN
+		log.Println("WARNING: The last prune run recorded in the journal did not complete; restoring the files it removed before it stopped")
+	}
+
+	removed := removedFiles(lastRun)
+	if len(removed) == 0 {
+		fmt.Printf("The last prune run recorded in journal %q removed no files; nothing to undo\n", appConfig.JournalFile)
+		return nil
+	}
+
+	fmt.Printf(
+		"Restoring %d files removed by the prune run started %s\n",
+		len(removed),
+		lastRun[0].Time.Format(time.RFC3339),
+	)
+
+	r := restorer{
+		ctx:    context.Background(),
+		dryRun: appConfig.DryRun,
+	}
+
+	results := make([]restoreResult, 0, len(removed))
+	for _, entry := range removed {
+		results = append(results, r.restore(entry))
+	}
+
+	printRestoreResults(results)
+
+	if appConfig.DryRun {
+		fmt.Println("Dry-run enabled, no files restored")
+	}
+
+	var notRestored int
+	for _, result := range results {
+		if result.status == restoreConflict || result.status == restoreFailed {
+			notRestored++
+		}
+	}
+
+	if notRestored > 0 {
+		return fmt.Errorf("%d of %d removed files could not be restored", notRestored, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/audit"
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// Outcomes of verifying a backup copy
+const (
+	backupVerified   string = "verified"
+	backupFailed     string = "failed"
+	backupMissing    string = "missing"
+	backupUnrecorded string = "unrecorded"
+)
+
+// backupResult is the outcome of verifying a single backup copy.
+type backupResult struct {
+	path   string
+	status string
+	err    error
+}
+
+// detail returns a brief description of the outcome for display.
+func (result backupResult) detail() string {
+	switch {
+	case result.err != nil:
+		return result.err.Error()
+	case result.status == backupMissing:
+		return "recorded in audit log, but not found in backup directory"
+	case result.status == backupUnrecorded:
+		return "no checksum recorded for backup copy"
+	default:
+		return ""
+	}
+}
+
+// archiveMemberPath splits the backup path of a file backed up to an
+// archive, recorded as archive#member, into the path to the archive and the
+// name of the member. Since member names are file paths which may contain
+// "#", the first "#" following an archive file extension is used. False is
+// returned for other backup paths.
+func archiveMemberPath(backupPath string) (string, string, bool) {
+
+	for index := 0; index < len(backupPath); index++ {
+		if backupPath[index] != '#' || index == 0 {
+			continue
+		}
+
+		if _, err := archive.Format(backupPath[:index]); err == nil {
+			return backupPath[:index], backupPath[index+1:], true
+		}
+	}
+
+	return "", "", false
+}
+
+// recordedBackups returns the audit log entries of backup copies made within
+// the specified backup directory, keyed by the absolute path to the backup
+// copy. If a backup copy was recorded more than once, the latest entry is
+// used. Backup copies stored in archives are skipped since archives record
+// their own manifest.
+func recordedBackups(auditLogFile string, backupDir string) (map[string]audit.Entry, error) {
+
+	entries, err := audit.ReadLog(auditLogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[string]audit.Entry)
+	for _, entry := range entries {
+
+		if entry.Action != audit.ActionBackup ||
+			entry.Outcome != audit.OutcomeSuccess ||
+			entry.BackupPath == "" {
+			continue
+		}
+
+		if _, _, ok := archiveMemberPath(entry.BackupPath); ok {
+			continue
+		}
+
+		backupPath, err := filepath.Abs(entry.BackupPath)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(backupDir, backupPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		recorded[backupPath] = entry
+	}
+
+	return recorded, nil
+}
+
+// verifyArchive verifies the content of each member of the specified backup
+// archive against the manifest stored within the archive. The archive is
+// treated as an unrecorded file if it has no manifest.
+func verifyArchive(filename string) []backupResult {
+
+	members, failures, err := archive.VerifyManifest(filename)
+	switch {
+	case errors.Is(err, archive.ErrManifestNotFound):
+		return []backupResult{{path: filename, status: backupUnrecorded}}
+	case err != nil:
+		return []backupResult{{path: filename, status: backupFailed, err: err}}
+	}
+
+	results := make([]backupResult, 0, len(members))
+	for _, member := range members {
+		result := backupResult{
+			path:   filename + "#" + member.Name,
+			status: backupVerified,
+		}
+
+		if err := failures[member.Name]; err != nil {
+			result.status = backupFailed
+			result.err = err
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// verifyBackups walks the specified backup directory, verifying each backup
+// copy against the checksum recorded for it. Recorded backup copies which
+// no longer exist are also reported.
+func verifyBackups(backupDir string, recorded map[string]audit.Entry, ignoreErrors bool) ([]backupResult, error) {
+
+	var results []backupResult
+	seen := make(map[string]bool, len(recorded))
+
+	err := filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println("Error encountered:", err)
+			if errreport.Ignore(ignoreErrors, err) {
+				log.Println("IgnoringErrors set, skipping path")
+				return nil
+			}
+			log.Println("IgnoringErrors NOT set. Exiting.")
+			return err
+		}
+
+		// Symbolic links and other special files are never created by the
+		// prune subcommand.
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		// The lock file of a prune run in progress is not a backup copy.
+		if d.Name() == pruneLockFileName {
+			return nil
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		if entry, ok := recorded[absPath]; ok {
+			seen[absPath] = true
+
+			result := backupResult{path: path, status: backupVerified}
+			if err := checksums.ParseChecksum(entry.Checksum).Verify(path); err != nil {
+				result.status = backupFailed
+				result.err = err
+			}
+			results = append(results, result)
+
+			return nil
+		}
+
+		if _, err := archive.Format(path); err == nil {
+			results = append(results, verifyArchive(path)...)
+			return nil
+		}
+
+		results = append(results, backupResult{path: path, status: backupUnrecorded})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate backup directory %q: %w", backupDir, err)
+	}
+
+	var missing []string
+	for backupPath := range recorded {
+		if !seen[backupPath] {
+			missing = append(missing, backupPath)
+		}
+	}
+	sort.Strings(missing)
+
+	for _, backupPath := range missing {
+		results = append(results, backupResult{path: backupPath, status: backupMissing})
+	}
+
+	return results, nil
+}
+
+// printBackupResults writes a summary of the provided backup verification
+// results to stdout, listing each backup copy which was not verified.
+func printBackupResults(results []backupResult) {
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.status]++
+	}
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Backup verification summary:")
+	_, _ = fmt.Fprintf(w, "%d\tbackup copies verified\n", counts[backupVerified])
+	_, _ = fmt.Fprintf(w, "%d\tbackup copies failed verification\n", counts[backupFailed])
+	_, _ = fmt.Fprintf(w, "%d\tbackup copies recorded but missing\n", counts[backupMissing])
+	_, _ = fmt.Fprintf(w, "%d\tfiles without a recorded checksum (not verified)\n", counts[backupUnrecorded])
+	_, _ = fmt.Fprintln(w)
+
+	if len(results) == counts[backupVerified] {
+		if err := w.Flush(); err != nil {
+			log.Printf(
+				"error occurred flushing tabwriter: %v",
+				err,
+			)
+		}
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", "Status", "Backup copy", "Detail")
+	for _, result := range results {
+		if result.status == backupVerified {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n",
+			result.status,
+			result.path,
+			result.detail(),
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// verifyBackupSubcommand is a wrapper around the "verify-backup" subcommand
+// logic. Each backup copy within the backup directory is compared with the
+// checksum recorded in the audit log (or archive manifest) when it was
+// made, detecting backup copies which have since been corrupted (e.g., bit
+// rot) before they are relied upon.
+func verifyBackupSubcommand(appConfig *config.Config) error {
+
+	backupDir, err := filepath.Abs(appConfig.BackupDirectory)
+	if err != nil {
+		return fmt.Errorf("unable to resolve backup directory %q: %w", appConfig.BackupDirectory, err)
+	}
+
+	fileInfo, err := os.Stat(backupDir)
+	if err != nil {
+		return fmt.Errorf("unable to access backup directory %q: %w", backupDir, err)
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("backup directory %q is not a directory", backupDir)
+	}
+
+	recorded := make(map[string]audit.Entry)
+	if appConfig.AuditLogFile != "" {
+		recorded, err = recordedBackups(appConfig.AuditLogFile, backupDir)
+		if err != nil {
+			return err
+		}
+	} else {
+		// WARN
+		log.Println("No audit log specified; only backup archives can be verified")
+	}
+
+	results, err := verifyBackups(backupDir, recorded, appConfig.IgnoreErrors)
+	if err != nil {
+		return err
+	}
+
+	printBackupResults(results)
+
+	// Recorded backup copies may have been removed intentionally (e.g., by
+	// the purge subcommand), so missing copies are listed but not treated
+	// as failures.
+	var failed int
+	for _, result := range results {
+		if result.status == backupFailed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d backup copies failed verification", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/metrics"
+	"github.com/atc0005/bridge/internal/watch"
+)
+
+// watchSubcommand is a wrapper around the "watch" subcommand logic. Newly
+// introduced duplicate files are reported to the console until the
+// application is interrupted.
+func watchSubcommand(appConfig *config.Config) error {
+
+	var watchMetrics *metrics.Metrics
+	if appConfig.MetricsListenAddress != "" {
+		watchMetrics = metrics.New()
+	}
+
+	watcher, err := watch.New(watch.Options{
+		Paths:               appConfig.Paths,
+		Recursive:           appConfig.RecursiveSearch,
+		FileSizeThreshold:   appConfig.FileSizeThreshold,
+		DuplicatesThreshold: appConfig.FileDuplicatesThreshold,
+		IgnoreErrors:        appConfig.IgnoreErrors,
+		Interval:            appConfig.WatchInterval,
+		Metrics:             watchMetrics,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if watchMetrics != nil {
+		log.Printf("Serving metrics on http://%s%s", appConfig.MetricsListenAddress, metrics.Endpoint)
+
+		go func() {
+			if err := watchMetrics.ListenAndServe(ctx, appConfig.MetricsListenAddress); err != nil {
+				// WARN
+				log.Println("Error encountered:", err)
+			}
+		}()
+	}
+
+	return watcher.Run(ctx, func(event watch.Event) error {
+		fmt.Printf(
+			"%s\tDuplicate file: %s (%s, %s)\n",
+			time.Now().Format(time.RFC3339),
+			event.File.FullPath,
+			event.File.SizeHR(),
+			event.Checksum,
+		)
+		for _, file := range event.Matches {
+			if file.FullPath == event.File.FullPath {
+				continue
+			}
+			fmt.Printf("\t\tmatches: %s\n", file.FullPath)
+		}
+
+		return nil
+	})
+}
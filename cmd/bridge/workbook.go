@@ -0,0 +1,182 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/config"
+	"github.com/atc0005/bridge/internal/dupesets"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/xuri/excelize/v2"
+)
+
+// workbookRemoveColumnHeaderNames lists the (case-insensitive) header names
+// accepted for the column added by reviewers to mark files for removal in a
+// generated Excel workbook.
+var workbookRemoveColumnHeaderNames = []string{"remove", "remove file", matches.CSVRemoveFileColumnHeaderName}
+
+// isWorkbook indicates whether the specified input file is an Excel workbook
+// instead of a CSV file.
+func isWorkbook(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".xlsx")
+}
+
+// workbookHeader converts the header row of a worksheet in a generated Excel
+// workbook (where underscores in column names are replaced with spaces) to
+// the equivalent CSV header row.
+func workbookHeader(row []string) []string {
+
+	header := make([]string, len(row))
+	for i, cell := range row {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		for _, removeName := range workbookRemoveColumnHeaderNames {
+			if name == removeName {
+				name = matches.CSVRemoveFileColumnHeaderName
+			}
+		}
+		header[i] = strings.ReplaceAll(name, " ", "_")
+	}
+
+	return header
+}
+
+// isEmptyRow indicates whether every cell in the row is empty.
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseInputWorkbook parses each duplicate file set worksheet of an Excel
+// workbook previously generated by the report subcommand, calling the
+// provided function for each successfully parsed row. Files are marked for
+// removal using a column added by the reviewer (see
+// workbookRemoveColumnHeaderNames). Worksheets without the expected header
+// row (e.g., the summary sheet) are skipped. Since each set is listed on its
+// own worksheet, the worksheet name is used as the set ID unless a set_id
+// column is present.
+func parseInputWorkbook(
+	filename string,
+	appConfig *config.Config,
+	fn func(dfsEntry dupesets.DuplicateFileSetEntry, rowNum int) error,
+) (dupesets.InputColumns, error) {
+
+	f, err := excelize.OpenFile(filepath.Clean(filename))
+	if err != nil {
+		return dupesets.InputColumns{}, fmt.Errorf(
+			"failed to open input workbook %q: %w",
+			filename,
+			err,
+		)
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	var inputColumns dupesets.InputColumns
+	var setSheets int
+
+	for _, sheet := range f.GetSheetList() {
+
+		// Raw values are used so that large sizes are not formatted using
+		// scientific notation and boolean cells are read as 1 or 0.
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return inputColumns, fmt.Errorf(
+				"failed to read worksheet %q of input workbook %q: %w",
+				sheet,
+				filename,
+				err,
+			)
+		}
+
+		if len(rows) == 0 {
+			continue
+		}
+
+		columns, err := dupesets.NewInputColumns(workbookHeader(rows[0]))
+		if err != nil {
+			// DEBUG
+			log.Printf("Skipping worksheet %q of input workbook %q: %v\n", sheet, filename, err)
+			continue
+		}
+
+		if setSheets == 0 {
+			inputColumns = columns
+		}
+		setSheets++
+
+		for index, row := range rows[1:] {
+
+			// Excel starts at 1 and the header occupies row 1
+			rowNum := index + 2
+
+			if isEmptyRow(row) {
+				continue
+			}
+
+			// Trailing empty cells (e.g., files not marked for removal) are
+			// omitted by the workbook reader.
+			for len(row) < len(rows[0]) {
+				row = append(row, "")
+			}
+
+			dfsEntry, err := dupesets.ParseInputRow(row, columns, rowNum)
+			if err != nil {
+				log.Printf(
+					"Error encountered parsing worksheet %q of input workbook %q: %v\n",
+					sheet,
+					filename,
+					err,
+				)
+				if errreport.Ignore(appConfig.IgnoreErrors, err) {
+					log.Printf("IgnoringErrors set, ignoring input row %d.\n", rowNum)
+					errreport.Record(filename, errreport.OperationParse, fmt.Errorf("worksheet %q row %d: %w", sheet, rowNum, err))
+					continue
+				}
+				log.Println("IgnoringErrors NOT set. Exiting.")
+				return inputColumns, err
+			}
+			dfsEntry.InputFile = filename
+
+			if dfsEntry.SetID == "" {
+				dfsEntry.SetID = sheet
+			}
+
+			if err := fn(dfsEntry, rowNum); err != nil {
+				return inputColumns, err
+			}
+		}
+	}
+
+	if setSheets == 0 {
+		return inputColumns, fmt.Errorf(
+			"no duplicate file set worksheets with a %q column found in input workbook %q",
+			matches.CSVRemoveFileColumnHeaderName,
+			filename,
+		)
+	}
+
+	return inputColumns, nil
+}
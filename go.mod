@@ -2,7 +2,11 @@ module github.com/atc0005/bridge
 
 go 1.20
 
-require github.com/xuri/excelize/v2 v2.9.0
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/xuri/excelize/v2 v2.9.0
+	golang.org/x/text v0.20.0
+)
 
 require (
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
@@ -12,5 +16,5 @@ require (
 	github.com/xuri/nfp v0.0.0-20240318013403-ab9948c2c4a7 // indirect
 	golang.org/x/crypto v0.29.0 // indirect
 	golang.org/x/net v0.31.0 // indirect
-	golang.org/x/text v0.20.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
 )
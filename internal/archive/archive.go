@@ -0,0 +1,590 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package archive provides a writer for backing up files into a single
+// compressed archive (tar.gz or zip) along with an internal manifest.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest stored within each archive,
+// listing the original location of each archived file.
+const ManifestName string = "MANIFEST.csv"
+
+// ErrManifestNotFound indicates that an archive does not contain a manifest
+// (see ManifestName), e.g., since it was not created by this application.
+var ErrManifestNotFound = errors.New("manifest not found in archive")
+
+// ErrMemberNotFound indicates that an archive does not contain the requested
+// member.
+var ErrMemberNotFound = errors.New("member not found in archive")
+
+// errExtracted stops reading an archive once the requested member has been
+// extracted.
+var errExtracted = errors.New("member extracted")
+
+// Supported archive formats
+const (
+	FormatTarGz string = "tar.gz"
+	FormatZip   string = "zip"
+)
+
+// ErrUnsupportedFormat indicates that the archive format could not be
+// determined from the archive file name.
+var ErrUnsupportedFormat = errors.New("unsupported archive format; supported file extensions are .tar.gz, .tgz and .zip")
+
+// Format returns the archive format indicated by the file extension of the
+// specified archive file name.
+func Format(filename string) (string, error) {
+
+	name := strings.ToLower(filename)
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// MemberName returns the name used within an archive for the specified file:
+// the fully-qualified path to the file using forward slashes, omitting any
+// OS-specific volume name (e.g., "C:" on Windows) and the leading slash.
+func MemberName(filename string) (string, error) {
+
+	fullPath, err := filepath.Abs(filename)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine absolute path to %q: %w", filename, err)
+	}
+
+	fullPath = strings.TrimPrefix(fullPath, filepath.VolumeName(fullPath))
+
+	return strings.TrimLeft(filepath.ToSlash(fullPath), "/"), nil
+}
+
+// Member describes a file added to an archive.
+type Member struct {
+
+	// Name is the name of the file within the archive
+	Name string
+
+	// OriginalPath is the fully-qualified path to the original file
+	OriginalPath string
+
+	// SizeInBytes is the size of the file in bytes
+	SizeInBytes int64
+
+	// Checksum is the checksum recorded for the original file
+	Checksum string
+
+	// SHA256 is the SHA256 hash of the content written to the archive
+	SHA256 string
+}
+
+// Writer adds files to a new tar.gz or zip archive. The manifest is written
+// when the Writer is closed.
+type Writer struct {
+	filename string
+	format   string
+	file     *os.File
+	gzw      *gzip.Writer
+	tw       *tar.Writer
+	zw       *zip.Writer
+	members  []Member
+}
+
+// NewWriter creates the specified archive file. The archive format is
+// determined from the file extension. An existing file is not overwritten.
+func NewWriter(filename string) (*Writer, error) {
+
+	format, err := Format(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(
+		filepath.Clean(filename),
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create backup archive %q: %w", filename, err)
+	}
+
+	w := Writer{
+		filename: filename,
+		format:   format,
+		file:     file,
+	}
+
+	switch format {
+	case FormatTarGz:
+		w.gzw = gzip.NewWriter(file)
+		w.tw = tar.NewWriter(w.gzw)
+	case FormatZip:
+		w.zw = zip.NewWriter(file)
+	}
+
+	return &w, nil
+}
+
+// create adds a new member with the provided details to the archive,
+// returning a writer for its content.
+func (w *Writer) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+
+	switch w.format {
+	case FormatTarGz:
+		hdr := tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     size,
+			Mode:     0600,
+			ModTime:  modTime,
+		}
+		if err := w.tw.WriteHeader(&hdr); err != nil {
+			return nil, err
+		}
+		return w.tw, nil
+
+	default:
+		hdr := zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		return w.zw.CreateHeader(&hdr)
+	}
+}
+
+// Add copies the specified file into the archive, returning the name of the
+// file within the archive. The provided checksum is recorded in the
+// manifest.
+func (w *Writer) Add(sourceFilename string, checksum string) (string, error) {
+
+	name, err := MemberName(sourceFilename)
+	if err != nil {
+		return "", err
+	}
+
+	sourceFileHandle, err := os.Open(filepath.Clean(sourceFilename))
+	if err != nil {
+		return "", fmt.Errorf("unable to open source file %q in order to create backup copy: %w",
+			sourceFilename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := sourceFileHandle.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				sourceFilename,
+				err,
+			)
+		}
+	}()
+
+	sourceFileStat, err := sourceFileHandle.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !sourceFileStat.Mode().IsRegular() {
+		return "", fmt.Errorf("%q is not a regular file", sourceFilename)
+	}
+
+	dst, err := w.create(name, sourceFileStat.Size(), sourceFileStat.ModTime())
+	if err != nil {
+		return "", fmt.Errorf("failed to add %q to backup archive %q: %w", sourceFilename, w.filename, err)
+	}
+
+	h := sha256.New()
+	sizeCopied, err := io.Copy(io.MultiWriter(dst, h), sourceFileHandle)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %q to backup archive %q: %w", sourceFilename, w.filename, err)
+	}
+
+	if sizeCopied != sourceFileStat.Size() {
+		return "", fmt.Errorf(
+			"failed to copy %q to backup archive %q: %d of %d bytes copied",
+			sourceFilename,
+			w.filename,
+			sizeCopied,
+			sourceFileStat.Size(),
+		)
+	}
+
+	w.members = append(w.members, Member{
+		Name:         name,
+		OriginalPath: sourceFilename,
+		SizeInBytes:  sizeCopied,
+		Checksum:     checksum,
+		SHA256:       fmt.Sprintf("%x", h.Sum(nil)),
+	})
+
+	return name, nil
+}
+
+// Members returns the files added to the archive so far.
+func (w *Writer) Members() []Member {
+	return w.members
+}
+
+// manifest returns the CSV manifest listing all archived files.
+func (w *Writer) manifest() ([]byte, error) {
+
+	var buf bytes.Buffer
+	csvWriter := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"archive_path", "original_path", "size_in_bytes", "checksum", "sha256"},
+	}
+	for _, member := range w.members {
+		rows = append(rows, []string{
+			member.Name,
+			member.OriginalPath,
+			strconv.FormatInt(member.SizeInBytes, 10),
+			member.Checksum,
+			member.SHA256,
+		})
+	}
+
+	if err := csvWriter.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to generate backup archive manifest: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Close writes the manifest and closes the archive file.
+func (w *Writer) Close() error {
+
+	manifest, err := w.manifest()
+	if err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	dst, err := w.create(ManifestName, int64(len(manifest)), time.Now())
+	if err == nil {
+		_, err = dst.Write(manifest)
+	}
+	if err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to write manifest to backup archive %q: %w", w.filename, err)
+	}
+
+	switch w.format {
+	case FormatTarGz:
+		if err = w.tw.Close(); err == nil {
+			err = w.gzw.Close()
+		}
+	case FormatZip:
+		err = w.zw.Close()
+	}
+	if err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to finalize backup archive %q: %w", w.filename, err)
+	}
+
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("error occurred syncing backup archive %q: %w", w.filename, err)
+	}
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing backup archive %q: %w", w.filename, err)
+	}
+
+	return nil
+}
+
+// Verify reads the specified archive, confirming that each of the provided
+// members is present and that its content matches the SHA256 hash recorded
+// when it was added. A map of member names to verification errors is
+// returned for members which failed verification.
+func Verify(filename string, members []Member) (map[string]error, error) {
+
+	hashes, _, err := hashArchive(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyMembers(filename, members, hashes), nil
+}
+
+// VerifyManifest reads the specified archive, confirming that each member
+// listed in the manifest stored within the archive is present and that its
+// content matches the SHA256 hash recorded in the manifest. The archive is
+// read only once. The members listed in the manifest are returned along with
+// a map of member names to verification errors for members which failed
+// verification.
+func VerifyManifest(filename string) ([]Member, map[string]error, error) {
+
+	hashes, manifest, err := hashArchive(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%w: %q", ErrManifestNotFound, filename)
+	}
+
+	members, err := parseManifest(manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest of backup archive %q: %w", filename, err)
+	}
+
+	return members, verifyMembers(filename, members, hashes), nil
+}
+
+// Extract writes the content of the named member of the specified archive
+// to w. ErrMemberNotFound is returned if the archive has no such member.
+func Extract(filename string, name string, w io.Writer) error {
+
+	format, err := Format(filename)
+	if err != nil {
+		return err
+	}
+
+	extractMember := func(memberName string, r io.Reader) error {
+		if memberName != name {
+			return nil
+		}
+
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("failed to read %q from backup archive %q: %w", name, filename, err)
+		}
+
+		// Stop reading the archive once the member is extracted.
+		return errExtracted
+	}
+
+	switch format {
+	case FormatTarGz:
+		err = hashTarGz(filename, extractMember)
+	case FormatZip:
+		err = hashZip(filename, extractMember)
+	}
+
+	switch {
+	case errors.Is(err, errExtracted):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return fmt.Errorf("%w: %q not found in %q", ErrMemberNotFound, name, filename)
+	}
+}
+
+// hashArchive returns the SHA256 hash of each regular file in the specified
+// archive (keyed by name) along with the content of the manifest, if
+// present.
+func hashArchive(filename string) (map[string]string, []byte, error) {
+
+	format, err := Format(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make(map[string]string)
+	var manifest []byte
+
+	hashMember := func(name string, r io.Reader) error {
+		if name == ManifestName {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read %q from backup archive %q: %w", name, filename, err)
+			}
+			manifest = data
+			return nil
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return fmt.Errorf("failed to read %q from backup archive %q: %w", name, filename, err)
+		}
+		hashes[name] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	}
+
+	switch format {
+	case FormatTarGz:
+		err = hashTarGz(filename, hashMember)
+	case FormatZip:
+		err = hashZip(filename, hashMember)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hashes, manifest, nil
+}
+
+// verifyMembers compares the provided hashes of archive content with the
+// SHA256 hash recorded for each member, returning a map of member names to
+// verification errors for members which failed verification.
+func verifyMembers(filename string, members []Member, hashes map[string]string) map[string]error {
+
+	failures := make(map[string]error)
+	for _, member := range members {
+		hash, ok := hashes[member.Name]
+		switch {
+		case !ok:
+			failures[member.Name] = fmt.Errorf("%q not found in backup archive %q", member.Name, filename)
+		case hash != member.SHA256:
+			failures[member.Name] = fmt.Errorf(
+				"content of %q in backup archive %q does not match; got %s, expected %s",
+				member.Name,
+				filename,
+				hash,
+				member.SHA256,
+			)
+		}
+	}
+
+	return failures
+}
+
+// parseManifest parses the content of an archive manifest (see manifest).
+func parseManifest(data []byte) ([]Member, error) {
+
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+
+	members := make([]Member, 0, len(rows)-1)
+	for index, row := range rows[1:] {
+		if len(row) < 5 {
+			return nil, fmt.Errorf("row %d has %d fields, expected 5", index+2, len(row))
+		}
+
+		size, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d has invalid size: %w", index+2, err)
+		}
+
+		members = append(members, Member{
+			Name:         row[0],
+			OriginalPath: row[1],
+			SizeInBytes:  size,
+			Checksum:     row[3],
+			SHA256:       row[4],
+		})
+	}
+
+	return members, nil
+}
+
+// hashTarGz calls fn with the content of each regular file in the specified
+// tar.gz archive.
+func hashTarGz(filename string, fn func(name string, r io.Reader) error) error {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("failed to open backup archive %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	gzr, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read backup archive %q: %w", filename, err)
+	}
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backup archive %q: %w", filename, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// hashZip calls fn with the content of each file in the specified zip
+// archive.
+func hashZip(filename string, fn func(name string, r io.Reader) error) error {
+
+	zr, err := zip.OpenReader(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("failed to open backup archive %q: %w", filename, err)
+	}
+
+	defer func() {
+		if err := zr.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %q from backup archive %q: %w", zf.Name, filename, err)
+		}
+
+		err = fn(zf.Name, rc)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,438 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package audiotags provides minimal reading of common tags (title, artist,
+// album, etc.) from MP3 (ID3v1, ID3v2) and FLAC (Vorbis comment) files.
+//
+// Only the handful of tags used to describe differences between copies of
+// the same track are decoded; this is not a general purpose tagging library.
+package audiotags
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tag names returned by Read
+const (
+	TagTitle       string = "title"
+	TagArtist      string = "artist"
+	TagAlbum       string = "album"
+	TagAlbumArtist string = "album_artist"
+	TagTrack       string = "track"
+	TagDisc        string = "disc"
+	TagYear        string = "year"
+	TagGenre       string = "genre"
+)
+
+// ErrInvalid indicates that the tags could not be parsed.
+var ErrInvalid = errors.New("invalid audio tags")
+
+// maxTagSize is the largest ID3v2 tag or FLAC metadata block read into
+// memory. Larger tags (usually embedded artwork) are skipped.
+const maxTagSize int = 16 << 20
+
+// supportedExtensions are the file extensions of formats that tags can be
+// read from.
+var supportedExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+}
+
+// id3v2Frames maps ID3v2.3/2.4 and ID3v2.2 text frame IDs to tag names.
+var id3v2Frames = map[string]string{
+	"TIT2": TagTitle,
+	"TPE1": TagArtist,
+	"TALB": TagAlbum,
+	"TPE2": TagAlbumArtist,
+	"TRCK": TagTrack,
+	"TPOS": TagDisc,
+	"TYER": TagYear,
+	"TDRC": TagYear,
+	"TCON": TagGenre,
+	"TT2":  TagTitle,
+	"TP1":  TagArtist,
+	"TAL":  TagAlbum,
+	"TP2":  TagAlbumArtist,
+	"TRK":  TagTrack,
+	"TPA":  TagDisc,
+	"TYE":  TagYear,
+	"TCO":  TagGenre,
+}
+
+// vorbisComments maps Vorbis comment field names to tag names.
+var vorbisComments = map[string]string{
+	"TITLE":       TagTitle,
+	"ARTIST":      TagArtist,
+	"ALBUM":       TagAlbum,
+	"ALBUMARTIST": TagAlbumArtist,
+	"TRACKNUMBER": TagTrack,
+	"DISCNUMBER":  TagDisc,
+	"DATE":        TagYear,
+	"GENRE":       TagGenre,
+}
+
+// Tags is a collection of tag names to values.
+type Tags map[string]string
+
+// IsSupported indicates whether the specified filename has an extension of
+// a format that tags can be read from.
+func IsSupported(filename string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// Read returns the tags found at the start of an MP3 (ID3v2) or FLAC
+// (Vorbis comment) file. ID3v1 tags are read separately via ReadID3v1 since
+// they are stored at the end of the file.
+func Read(r io.Reader) (Tags, error) {
+
+	br := bufio.NewReader(r)
+	tags := make(Tags)
+
+	header, err := br.Peek(4)
+	if err != nil {
+		return tags, nil
+	}
+
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		if err := readID3v2(br, tags); err != nil {
+			return tags, err
+		}
+
+		header, err = br.Peek(4)
+		if err != nil {
+			return tags, nil
+		}
+	}
+
+	if bytes.Equal(header, []byte("fLaC")) {
+		if err := readFLAC(br, tags); err != nil {
+			return tags, err
+		}
+	}
+
+	return tags, nil
+}
+
+// ReadID3v1 returns the tags recorded in an ID3v1 tag, provided as the last
+// 128 bytes of a file. An empty collection is returned if the data is not
+// an ID3v1 tag.
+func ReadID3v1(tail []byte) Tags {
+
+	tags := make(Tags)
+
+	if len(tail) != 128 || !bytes.HasPrefix(tail, []byte("TAG")) {
+		return tags
+	}
+
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+
+	tags.set(TagTitle, field(tail[3:33]))
+	tags.set(TagArtist, field(tail[33:63]))
+	tags.set(TagAlbum, field(tail[63:93]))
+	tags.set(TagYear, field(tail[93:97]))
+
+	// ID3v1.1 stores the track number in the last byte of the comment field
+	if tail[125] == 0 && tail[126] != 0 {
+		tags.set(TagTrack, fmt.Sprintf("%d", tail[126]))
+	}
+
+	return tags
+}
+
+// Merge adds tags from other which are not already set.
+func (t Tags) Merge(other Tags) {
+	for name, value := range other {
+		if _, ok := t[name]; !ok {
+			t[name] = value
+		}
+	}
+}
+
+// Differences returns the names of tags whose values differ (or are missing)
+// between the provided collections of tags, sorted by name.
+func Differences(tags ...Tags) []string {
+
+	names := make(map[string]bool)
+	for _, t := range tags {
+		for name := range t {
+			names[name] = true
+		}
+	}
+
+	var differences []string
+	for name := range names {
+		for _, t := range tags[1:] {
+			if t[name] != tags[0][name] {
+				differences = append(differences, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(differences)
+
+	return differences
+}
+
+// Format returns the values of the specified tags as "name=value" pairs
+// separated by semicolons.
+func (t Tags) Format(names []string) string {
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, t[name]))
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+// set records a non-empty tag value.
+func (t Tags) set(name string, value string) {
+	if value != "" {
+		t[name] = value
+	}
+}
+
+// readID3v2 reads the text frames of interest from an ID3v2 tag.
+func readID3v2(r *bufio.Reader, tags Tags) error {
+
+	var header [10]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return fmt.Errorf("%w: truncated ID3v2 header", ErrInvalid)
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+
+	if size > maxTagSize {
+		_, err := r.Discard(size)
+		return err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("%w: truncated ID3v2 tag", ErrInvalid)
+	}
+
+	// skip the footer (ID3v2.4 only)
+	if flags&0x10 != 0 {
+		if _, err := r.Discard(10); err != nil {
+			return fmt.Errorf("%w: truncated ID3v2 footer", ErrInvalid)
+		}
+	}
+
+	// unsynchronisation applied to the whole tag (ID3v2.2 and ID3v2.3)
+	if flags&0x80 != 0 && version < 4 {
+		data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	// skip the extended header
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		extendedSize := int(binary.BigEndian.Uint32(data[:4]))
+		if version == 3 {
+			extendedSize += 4
+		} else {
+			extendedSize = syncsafe(data[:4])
+		}
+		if extendedSize > len(data) {
+			return fmt.Errorf("%w: invalid ID3v2 extended header", ErrInvalid)
+		}
+		data = data[extendedSize:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(data) >= headerSize && data[0] != 0 {
+
+		id := string(data[:idSize])
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+		default:
+			frameSize = syncsafe(data[4:8])
+		}
+
+		if frameSize < 0 || frameSize > len(data)-headerSize {
+			return fmt.Errorf("%w: invalid ID3v2 frame size for %q", ErrInvalid, id)
+		}
+
+		if name, ok := id3v2Frames[id]; ok && frameSize > 0 {
+			tags.set(name, decodeID3v2Text(data[headerSize:headerSize+frameSize]))
+		}
+
+		data = data[headerSize+frameSize:]
+	}
+
+	return nil
+}
+
+// readFLAC reads the Vorbis comment metadata block of a FLAC file.
+func readFLAC(r *bufio.Reader, tags Tags) error {
+
+	if _, err := r.Discard(4); err != nil {
+		return fmt.Errorf("%w: truncated FLAC header", ErrInvalid)
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return fmt.Errorf("%w: truncated FLAC metadata block", ErrInvalid)
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		// Vorbis comment
+		if blockType == 4 {
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return fmt.Errorf("%w: truncated Vorbis comment", ErrInvalid)
+			}
+			return readVorbisComment(data, tags)
+		}
+
+		if _, err := r.Discard(size); err != nil {
+			return fmt.Errorf("%w: truncated FLAC metadata block", ErrInvalid)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// readVorbisComment reads the fields of interest from a Vorbis comment.
+func readVorbisComment(data []byte, tags Tags) error {
+
+	next := func() ([]byte, error) {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: truncated Vorbis comment", ErrInvalid)
+		}
+		size := binary.LittleEndian.Uint32(data[:4])
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, fmt.Errorf("%w: truncated Vorbis comment", ErrInvalid)
+		}
+		value := data[4 : 4+size]
+		data = data[4+size:]
+		return value, nil
+	}
+
+	// vendor string
+	if _, err := next(); err != nil {
+		return err
+	}
+
+	if len(data) < 4 {
+		return fmt.Errorf("%w: truncated Vorbis comment", ErrInvalid)
+	}
+	count := binary.LittleEndian.Uint32(data[:4])
+	data = data[4:]
+
+	for i := uint32(0); i < count; i++ {
+		field, err := next()
+		if err != nil {
+			return err
+		}
+
+		name, value, found := strings.Cut(string(field), "=")
+		if !found {
+			continue
+		}
+
+		if tag, ok := vorbisComments[strings.ToUpper(name)]; ok {
+			tags.set(tag, strings.TrimSpace(value))
+		}
+	}
+
+	return nil
+}
+
+// decodeID3v2Text decodes the content of an ID3v2 text frame. Multiple
+// values are joined using a slash.
+func decodeID3v2Text(frame []byte) string {
+
+	encoding, text := frame[0], frame[1:]
+
+	var value string
+	switch encoding {
+	case 0:
+		value = latin1(text)
+	case 1, 2:
+		value = decodeUTF16(text, encoding == 2)
+	default:
+		value = string(text)
+	}
+
+	value = strings.TrimRight(value, "\x00")
+	value = strings.ReplaceAll(value, "\x00", "/")
+
+	return strings.TrimSpace(value)
+}
+
+// decodeUTF16 decodes UTF-16 text. A byte order mark (if present) overrides
+// the default byte order.
+func decodeUTF16(b []byte, bigEndian bool) string {
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	var units []uint16
+	for len(b) >= 2 {
+		switch {
+		case b[0] == 0xFF && b[1] == 0xFE:
+			order = binary.LittleEndian
+		case b[0] == 0xFE && b[1] == 0xFF:
+			order = binary.BigEndian
+		default:
+			units = append(units, order.Uint16(b))
+		}
+		b = b[2:]
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// latin1 decodes ISO-8859-1 text.
+func latin1(b []byte) string {
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+// syncsafe decodes a 28-bit ID3v2 syncsafe integer.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
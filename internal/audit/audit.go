@@ -0,0 +1,191 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package audit provides a log recording the files backed up and removed by
+// this application, allowing removed files to be accounted for later.
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionBackup string = "backup"
+	ActionRemove string = "remove"
+)
+
+// Outcomes recorded in the audit log
+const (
+	OutcomeSuccess string = "success"
+	OutcomeFailed  string = "failed"
+	OutcomeDryRun  string = "dry-run"
+)
+
+// Column header names for the audit log
+const (
+	TimestampColumnHeaderName   string = "timestamp"
+	ActionColumnHeaderName      string = "action"
+	PathColumnHeaderName        string = "path"
+	SizeInBytesColumnHeaderName string = "size_in_bytes"
+	ChecksumColumnHeaderName    string = "checksum"
+	BackupPathColumnHeaderName  string = "backup_path"
+	OutcomeColumnHeaderName     string = "outcome"
+	ErrorColumnHeaderName       string = "error"
+)
+
+// Entry represents a single action taken on a file.
+type Entry struct {
+
+	// Time is when the action was taken
+	Time time.Time
+
+	// Action is the action taken (e.g., backup, remove)
+	Action string
+
+	// Path is the fully-qualified path to the file
+	Path string
+
+	// SizeInBytes is the size of the file in bytes
+	SizeInBytes int64
+
+	// Checksum is the checksum recorded for the file
+	Checksum string
+
+	// BackupPath is the fully-qualified path to the backup copy of the file
+	// (if any)
+	BackupPath string
+
+	// Outcome is the result of the action (e.g., success, failed)
+	Outcome string
+
+	// Err is the error encountered while taking the action (if any)
+	Err error
+}
+
+// Log is an audit log file in CSV format. Entries are appended to the file
+// as they are recorded so that the log remains accurate if the application
+// is interrupted.
+type Log struct {
+	filename string
+	file     *os.File
+	w        *csv.Writer
+}
+
+// NewLog opens the specified audit log file for appending, creating the file
+// (and writing the header row) if it does not already exist.
+func NewLog(filename string) (*Log, error) {
+
+	file, err := os.OpenFile(
+		filepath.Clean(filename),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %q: %w", filename, err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to retrieve details for audit log %q: %w", filename, err)
+	}
+
+	auditLog := Log{
+		filename: filename,
+		file:     file,
+		w:        csv.NewWriter(file),
+	}
+
+	if fileInfo.Size() == 0 {
+		headerRow := []string{
+			TimestampColumnHeaderName,
+			ActionColumnHeaderName,
+			PathColumnHeaderName,
+			SizeInBytesColumnHeaderName,
+			ChecksumColumnHeaderName,
+			BackupPathColumnHeaderName,
+			OutcomeColumnHeaderName,
+			ErrorColumnHeaderName,
+		}
+
+		if err := auditLog.write(headerRow); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	return &auditLog, nil
+}
+
+// write writes and flushes the provided row.
+func (l *Log) write(row []string) error {
+
+	if err := l.w.Write(row); err != nil {
+		return fmt.Errorf("error writing record to audit log %q: %w", l.filename, err)
+	}
+
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		return fmt.Errorf("error writing record to audit log %q: %w", l.filename, err)
+	}
+
+	return nil
+}
+
+// Record appends the provided entry to the audit log. A nil Log discards the
+// entry, allowing callers to record entries unconditionally.
+func (l *Log) Record(entry Entry) error {
+
+	if l == nil {
+		return nil
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	var errMsg string
+	if entry.Err != nil {
+		errMsg = entry.Err.Error()
+	}
+
+	return l.write([]string{
+		entry.Time.Format(time.RFC3339),
+		entry.Action,
+		entry.Path,
+		strconv.FormatInt(entry.SizeInBytes, 10),
+		entry.Checksum,
+		entry.BackupPath,
+		entry.Outcome,
+		errMsg,
+	})
+}
+
+// Close syncs and closes the audit log file.
+func (l *Log) Close() error {
+
+	if l == nil {
+		return nil
+	}
+
+	if err := l.file.Sync(); err != nil {
+		_ = l.file.Close()
+		return fmt.Errorf("error occurred syncing audit log %q: %w", l.filename, err)
+	}
+
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing audit log %q: %w", l.filename, err)
+	}
+
+	return nil
+}
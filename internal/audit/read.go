@@ -0,0 +1,113 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package audit
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ReadLog reads all entries from the specified audit log file. Columns are
+// located using the header row so that columns added by later releases are
+// ignored.
+func ReadLog(filename string) ([]Entry, error) {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	csvReader := csv.NewReader(file)
+
+	headerRow, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header row of audit log %q: %w", filename, err)
+	}
+
+	columns := make(map[string]int, len(headerRow))
+	for index, name := range headerRow {
+		columns[name] = index
+	}
+
+	for _, name := range []string{
+		TimestampColumnHeaderName,
+		ActionColumnHeaderName,
+		PathColumnHeaderName,
+		SizeInBytesColumnHeaderName,
+		ChecksumColumnHeaderName,
+		BackupPathColumnHeaderName,
+		OutcomeColumnHeaderName,
+		ErrorColumnHeaderName,
+	} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("audit log %q is missing the %q column", filename, name)
+		}
+	}
+
+	var entries []Entry
+	rowNum := 1
+	for {
+		rowNum++
+
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log %q: %w", filename, err)
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, row[columns[TimestampColumnHeaderName]])
+		if err != nil {
+			return nil, fmt.Errorf("row %d of audit log %q has invalid timestamp: %w", rowNum, filename, err)
+		}
+
+		size, err := strconv.ParseInt(row[columns[SizeInBytesColumnHeaderName]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d of audit log %q has invalid size: %w", rowNum, filename, err)
+		}
+
+		entry := Entry{
+			Time:        timestamp,
+			Action:      row[columns[ActionColumnHeaderName]],
+			Path:        row[columns[PathColumnHeaderName]],
+			SizeInBytes: size,
+			Checksum:    row[columns[ChecksumColumnHeaderName]],
+			BackupPath:  row[columns[BackupPathColumnHeaderName]],
+			Outcome:     row[columns[OutcomeColumnHeaderName]],
+		}
+
+		if errMsg := row[columns[ErrorColumnHeaderName]]; errMsg != "" {
+			entry.Err = errors.New(errMsg)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
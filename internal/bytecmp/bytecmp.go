@@ -0,0 +1,220 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package bytecmp provides functions for comparing file content byte for
+// byte, without generating checksums.
+package bytecmp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ChunkSize is the number of bytes read from each reader at a time.
+const ChunkSize = 64 * 1024
+
+// readChunk fills buf from r, returning the number of bytes read and
+// whether the end of the content was reached.
+func readChunk(r io.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return n, true, nil
+	case err != nil:
+		return n, false, err
+	default:
+		return n, false, nil
+	}
+}
+
+// Equal compares the content of two readers byte for byte. Errors
+// encountered reading from either reader are returned, indicating which
+// reader (0 or 1) failed.
+func Equal(a io.Reader, b io.Reader) (bool, int, error) {
+
+	bufA := make([]byte, ChunkSize)
+	bufB := make([]byte, ChunkSize)
+
+	for {
+		nA, endA, err := readChunk(a, bufA)
+		if err != nil {
+			return false, 0, err
+		}
+
+		nB, endB, err := readChunk(b, bufB)
+		if err != nil {
+			return false, 1, err
+		}
+
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) || endA != endB {
+			return false, 0, nil
+		}
+
+		if endA {
+			return true, 0, nil
+		}
+	}
+}
+
+// EqualFiles compares the content of two files byte for byte.
+func EqualFiles(a string, b string) (bool, error) {
+
+	open := func(filename string) (*os.File, func(), error) {
+		f, err := os.Open(filepath.Clean(filename))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %q: %w", filename, err)
+		}
+
+		closeFile := func() {
+			if err := f.Close(); err != nil {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					filename,
+					err,
+				)
+			}
+		}
+
+		return f, closeFile, nil
+	}
+
+	fileA, closeA, err := open(a)
+	if err != nil {
+		return false, err
+	}
+	defer closeA()
+
+	fileB, closeB, err := open(b)
+	if err != nil {
+		return false, err
+	}
+	defer closeB()
+
+	equal, failed, err := Equal(fileA, fileB)
+	if err != nil {
+		filename := a
+		if failed == 1 {
+			filename = b
+		}
+		return false, fmt.Errorf("failed to read %q: %w", filename, err)
+	}
+
+	return equal, nil
+}
+
+// Partition reads all of the provided readers concurrently, one chunk at a
+// time, and groups readers with identical content. Each reader is read at
+// most once and readers stop being read as soon as their content is known
+// to be unique. Groups are returned as indexes into the provided slice, in
+// order of their first member; readers with unique content are returned as
+// single member groups.
+//
+// Readers which fail are excluded from the returned groups; their errors are
+// returned at the same index as the reader (nil for all other readers).
+func Partition(readers []io.Reader) ([][]int, []error) {
+
+	errs := make([]error, len(readers))
+
+	pending := [][]int{make([]int, 0, len(readers))}
+	for i := range readers {
+		pending[0] = append(pending[0], i)
+	}
+
+	var complete [][]int
+	buffers := make(map[int][]byte, len(readers))
+	buffer := func(i int) []byte {
+		if _, ok := buffers[i]; !ok {
+			buffers[i] = make([]byte, ChunkSize)
+		}
+		return buffers[i]
+	}
+
+	for len(pending) > 0 {
+
+		var next [][]int
+
+		for _, group := range pending {
+
+			if len(group) < 2 {
+				complete = append(complete, group)
+				continue
+			}
+
+			type subgroup struct {
+				members []int
+				chunk   []byte
+				end     bool
+			}
+
+			var subgroups []*subgroup
+
+			for _, i := range group {
+				buf := buffer(i)
+				n, end, err := readChunk(readers[i], buf)
+				if err != nil {
+					errs[i] = err
+					delete(buffers, i)
+					continue
+				}
+
+				chunk := buf[:n]
+
+				var matched bool
+				for _, sg := range subgroups {
+					if sg.end == end && bytes.Equal(sg.chunk, chunk) {
+						sg.members = append(sg.members, i)
+						matched = true
+						break
+					}
+				}
+
+				if !matched {
+					subgroups = append(subgroups, &subgroup{
+						members: []int{i},
+						chunk:   chunk,
+						end:     end,
+					})
+				}
+			}
+
+			for _, sg := range subgroups {
+				switch {
+				case sg.end || len(sg.members) < 2:
+					complete = append(complete, sg.members)
+					for _, i := range sg.members {
+						delete(buffers, i)
+					}
+				default:
+					next = append(next, sg.members)
+				}
+			}
+		}
+
+		pending = next
+	}
+
+	// Order groups by their first member for predictable results.
+	ordered := make([][]int, 0, len(complete))
+	first := make(map[int][]int, len(complete))
+	for _, group := range complete {
+		if len(group) > 0 {
+			first[group[0]] = group
+		}
+	}
+	for i := range readers {
+		if group, ok := first[i]; ok {
+			ordered = append(ordered, group)
+		}
+	}
+
+	return ordered, errs
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package checksums
+
+import (
+	"crypto/md5"  // #nosec G501 -- used to match files, not for security
+	"crypto/sha1" // #nosec G505 -- used to match files, not for security
+	"crypto/sha512"
+	"hash"
+)
+
+// Algorithm is a hash algorithm used to generate an additional checksum
+// for each file alongside the SHA256 checksum used to match files. The
+// additional checksum allows reports to be matched against tools (or
+// manifests) which only support that algorithm.
+type Algorithm string
+
+// Supported additional hash algorithms
+const (
+	// AlgorithmMD5 generates MD5 hashes
+	AlgorithmMD5 Algorithm = "md5"
+
+	// AlgorithmSHA1 generates SHA1 hashes
+	AlgorithmSHA1 Algorithm = "sha1"
+
+	// AlgorithmSHA512 generates SHA512 hashes
+	AlgorithmSHA512 Algorithm = "sha512"
+)
+
+// Algorithms is the list of supported additional hash algorithms.
+var Algorithms = []Algorithm{
+	AlgorithmMD5,
+	AlgorithmSHA1,
+	AlgorithmSHA512,
+}
+
+// IsValid indicates whether the hash algorithm is supported.
+func (a Algorithm) IsValid() bool {
+	for _, algorithm := range Algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prefix returns the prefix applied to hashes generated using the
+// algorithm, e.g., "md5:".
+func (a Algorithm) Prefix() string {
+	return string(a) + ":"
+}
+
+// newHash returns a new hash.Hash for the algorithm, or nil if the
+// algorithm is not supported.
+func (a Algorithm) newHash() hash.Hash {
+	switch a {
+	case AlgorithmMD5:
+		// #nosec G401 -- used to match files, not for security
+		return md5.New()
+	case AlgorithmSHA1:
+		// #nosec G401 -- used to match files, not for security
+		return sha1.New()
+	case AlgorithmSHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
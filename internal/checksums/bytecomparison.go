@@ -0,0 +1,75 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package checksums
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ByteComparisonPrefix is the prefix applied to identifiers of duplicate
+// file sets confirmed by comparing file content byte for byte instead of
+// generating checksums. These identifiers record the file size and a set
+// number rather than a hash of the file content.
+const ByteComparisonPrefix string = "bytes:"
+
+// ByteComparisonID returns the identifier for a duplicate file set of files
+// with the specified size, confirmed by comparing file content byte for
+// byte. The group number distinguishes sets of files with the same size.
+func ByteComparisonID(size int64, group int) SHA256Checksum {
+	return SHA256Checksum(fmt.Sprintf("%s%d-%d", ByteComparisonPrefix, size, group))
+}
+
+// IsByteComparison indicates whether the value is a byte comparison
+// identifier instead of a checksum.
+func (cs SHA256Checksum) IsByteComparison() bool {
+	return strings.HasPrefix(string(cs), ByteComparisonPrefix)
+}
+
+// byteComparisonSize returns the file size recorded in a byte comparison
+// identifier.
+func (cs SHA256Checksum) byteComparisonSize() (int64, error) {
+	sizeField, _, _ := strings.Cut(strings.TrimPrefix(string(cs), ByteComparisonPrefix), "-")
+
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid byte comparison identifier %q: %w", ErrInvalidChecksum, cs, err)
+	}
+
+	return size, nil
+}
+
+// verifyByteComparisonSize confirms that the size of the file matches the
+// size recorded in a byte comparison identifier. The content of the file
+// can only be verified by comparing it with other files in the same set.
+func (cs SHA256Checksum) verifyByteComparisonSize(file string) error {
+
+	size, err := cs.byteComparisonSize()
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := os.Stat(filepath.Clean(file))
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Size() != size {
+		return fmt.Errorf(
+			"%w, file likely modified; got %d bytes, expected %d bytes",
+			ErrSizeMismatch,
+			fileInfo.Size(),
+			size,
+		)
+	}
+
+	return nil
+}
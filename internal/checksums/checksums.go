@@ -14,9 +14,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/bytecmp"
 )
 
 // SHA256Checksum is a 64 character string representing a SHA256 hash
@@ -27,24 +31,104 @@ import (
 // type SHA256Checksum [64]string
 type SHA256Checksum string
 
+// SHA256Prefix is the algorithm prefix applied to checksums generated from
+// the entire content of a file, e.g., "sha256:<hash>". Recording the
+// algorithm allows checksums generated using other algorithms to be
+// distinguished from these.
+const SHA256Prefix string = "sha256:"
+
+// sha256HexLength is the length of a hex-encoded SHA256 hash.
+const sha256HexLength int = sha256.Size * 2
+
+// ErrChecksumMismatch indicates that the checksum generated for a file does
+// not match the checksum recorded for it; the file has likely been modified.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrSizeMismatch indicates that the size of a file does not match the size
+// recorded for it; the file has likely been modified.
+var ErrSizeMismatch = errors.New("size mismatch")
+
+// ErrContentMismatch indicates that the content of a copy differs from the
+// original file despite a matching checksum (e.g., beyond the bytes covered
+// by a partial checksum).
+var ErrContentMismatch = errors.New("content mismatch")
+
+// ErrInvalidChecksum indicates that a recorded checksum (or byte comparison
+// identifier) cannot be parsed.
+var ErrInvalidChecksum = errors.New("invalid checksum")
+
 func (cs SHA256Checksum) String() string {
 	// convert the value via `string(cs)` before recurring to prevent infinite
 	// recursion (per https://golang.org/pkg/fmt/ )
 	return string(cs)
 }
 
+// ParseChecksum converts a checksum read from a CSV file or provided by a
+// client into a SHA256Checksum. Bare SHA256 hashes recorded by older versions
+// of this application (without an algorithm prefix) are converted to the
+// current prefixed form so that they can be compared with newly generated
+// checksums; other values are returned unmodified.
+func ParseChecksum(value string) SHA256Checksum {
+	if isSHA256Hex(value) {
+		return SHA256Checksum(SHA256Prefix + strings.ToLower(value))
+	}
+
+	return SHA256Checksum(value)
+}
+
+// isSHA256Hex indicates whether the value is a bare hex-encoded SHA256 hash.
+func isSHA256Hex(value string) bool {
+	if len(value) != sha256HexLength {
+		return false
+	}
+
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'a' && r <= 'f':
+		case r >= 'A' && r <= 'F':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
 // Verify takes a path to a file, generates a SHA256 checksum from the file
-// and compares against the checksum value already present
+// and compares against the checksum value already present. Content-only
+// checksums are verified against the image content of the file and partial
+// checksums against the same number of leading bytes. For byte comparison
+// identifiers, only the file size is verified.
 func (cs SHA256Checksum) Verify(file string) error {
 
-	checksum, err := GenerateCheckSum(file)
+	if cs.IsByteComparison() {
+		return cs.verifyByteComparisonSize(file)
+	}
+
+	generate := GenerateCheckSum
+	switch {
+	case cs.IsContentChecksum():
+		generate = GenerateContentCheckSum
+	case cs.IsPartial():
+		size, err := cs.partialSize()
+		if err != nil {
+			return err
+		}
+		generate = func(file string) (SHA256Checksum, error) {
+			return GeneratePartialCheckSum(file, size)
+		}
+	}
+
+	checksum, err := generate(file)
 	if err != nil {
 		return err
 	}
 
 	if checksum.String() != cs.String() {
 		return fmt.Errorf(
-			"checksum mismatch, file likely modified; got %s, expected %s",
+			"%w, file likely modified; got %s, expected %s",
+			ErrChecksumMismatch,
 			checksum.String(),
 			cs.String(),
 		)
@@ -55,16 +139,53 @@ func (cs SHA256Checksum) Verify(file string) error {
 
 }
 
+// VerifyCopy confirms that a copy of the original file matches the checksum.
+// Since content-only checksums, partial checksums and byte comparison
+// identifiers do not cover every byte of a file, copies verified using them
+// are also compared byte for byte with the original file.
+func (cs SHA256Checksum) VerifyCopy(original string, copied string) error {
+
+	if err := cs.Verify(copied); err != nil {
+		return err
+	}
+
+	if !cs.IsContentChecksum() && !cs.IsByteComparison() && !cs.IsPartial() {
+		return nil
+	}
+
+	same, err := bytecmp.EqualFiles(original, copied)
+	if err != nil {
+		return err
+	}
+
+	if !same {
+		return fmt.Errorf("%w: content of %q differs from %q", ErrContentMismatch, copied, original)
+	}
+
+	return nil
+}
+
 // GenerateCheckSum returns a SHA256 hash as the checksum generated from a
-// provided fully-qualified path to a file.
+// provided fully-qualified path to a file. The kernel is advised that the
+// file is read sequentially (see also SetDropCache).
 func GenerateCheckSum(file string) (SHA256Checksum, error) {
+	checksum, _, err := GenerateCheckSums(file, "")
+	return checksum, err
+}
+
+// GenerateCheckSums returns a SHA256 hash as the checksum generated from a
+// provided fully-qualified path to a file along with a hash generated using
+// the specified additional algorithm, reading the file only once. The
+// additional hash is prefixed with the algorithm name (e.g., "md5:<hash>")
+// and is empty if no additional algorithm is specified.
+func GenerateCheckSums(file string, extra Algorithm) (SHA256Checksum, string, error) {
 
 	var checksum SHA256Checksum
+	var extraHash string
 
 	f, err := os.Open(filepath.Clean(file))
 	if err != nil {
-		// log.Fatal(err)
-		return checksum, err
+		return checksum, extraHash, err
 	}
 
 	// Note the duplicate f.Close() call at end of function and why
@@ -81,17 +202,103 @@ func GenerateCheckSum(file string) (SHA256Checksum, error) {
 		}
 	}()
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		// log.Fatal(err)
-		return checksum, err
+	adviseRead(f)
+
+	checksum, extraHash, err = generateCheckSums(f, extra)
+	if err != nil {
+		return checksum, extraHash, err
 	}
 
-	// Explicitly convert Sprintf output from string to our type
-	checksum = SHA256Checksum(fmt.Sprintf("%x", h.Sum(nil)))
+	adviseDone(f)
 
 	// defer the call to Close per above, and still report on an error if we
 	// encounter one (see "Understanding defer in Go" README reference entry)
-	return checksum, f.Close()
+	return checksum, extraHash, f.Close()
+
+}
+
+// GenerateCheckSumFS returns a SHA256 hash as the checksum generated from
+// the named file within the provided filesystem.
+func GenerateCheckSumFS(fsys fs.FS, name string) (SHA256Checksum, error) {
+	checksum, _, err := GenerateCheckSumsFS(fsys, name, "")
+	return checksum, err
+}
+
+// GenerateCheckSumsFS returns a SHA256 checksum and an additional hash (see
+// GenerateCheckSums) for the named file within the provided filesystem.
+func GenerateCheckSumsFS(fsys fs.FS, name string, extra Algorithm) (SHA256Checksum, string, error) {
+
+	var checksum SHA256Checksum
+	var extraHash string
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return checksum, extraHash, err
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			// Ignore "file already closed" errors
+			if !errors.Is(err, fs.ErrClosed) {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					name,
+					err,
+				)
+			}
+		}
+	}()
+
+	checksum, extraHash, err = generateCheckSums(f, extra)
+	if err != nil {
+		return checksum, extraHash, err
+	}
+
+	return checksum, extraHash, f.Close()
+}
+
+// generateCheckSum returns a SHA256 hash as the checksum generated from the
+// content read from the provided io.Reader, prefixed with SHA256Prefix.
+func generateCheckSum(r io.Reader) (SHA256Checksum, error) {
+
+	hash, err := sha256Hex(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Explicitly convert from string to our type
+	return SHA256Checksum(SHA256Prefix + hash), nil
+}
+
+// generateCheckSums returns a SHA256 checksum (see generateCheckSum) and a
+// hash generated using the additional algorithm (if any) from the content
+// read from the provided io.Reader in a single pass.
+func generateCheckSums(r io.Reader, extra Algorithm) (SHA256Checksum, string, error) {
+
+	extraHash := extra.newHash()
+	if extraHash == nil {
+		checksum, err := generateCheckSum(r)
+		return checksum, "", err
+	}
+
+	h := sha256.New()
+	if _, err := copyBuffered(io.MultiWriter(h, extraHash), r); err != nil {
+		return "", "", err
+	}
+
+	return SHA256Checksum(fmt.Sprintf("%s%x", SHA256Prefix, h.Sum(nil))),
+		fmt.Sprintf("%s%x", extra.Prefix(), extraHash.Sum(nil)),
+		nil
+}
+
+// sha256Hex returns the hex-encoded SHA256 hash of the content read from the
+// provided io.Reader.
+func sha256Hex(r io.Reader) (string, error) {
+
+	h := sha256.New()
+	if _, err := copyBuffered(h, r); err != nil {
+		return "", err
+	}
 
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
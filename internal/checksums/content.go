@@ -0,0 +1,419 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package checksums
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentChecksumPrefix is the prefix applied to checksums generated from
+// the image content or audio stream of a file only, ignoring metadata. Files
+// with matching content checksums are content-equal, but may not be
+// byte-equal.
+const ContentChecksumPrefix string = "content-sha256:"
+
+// JPEG markers of segments holding metadata (EXIF, XMP, IPTC, comments)
+// rather than image content.
+const (
+	jpegMarkerAPP1         byte = 0xE1
+	jpegMarkerAPP13        byte = 0xED
+	jpegMarkerComment      byte = 0xFE
+	jpegMarkerStartOfScan  byte = 0xDA
+	jpegMarkerStartOfImage byte = 0xD8
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the PNG chunk types holding metadata rather than
+// image content.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"iTXt": true,
+	"tEXt": true,
+	"tIME": true,
+	"zTXt": true,
+}
+
+// Sizes of tags stored at the end of MP3 and FLAC files.
+const (
+	id3v1TagSize    int = 128
+	apeFooterSize   int = 32
+	audioTailWindow int = 64 << 10
+)
+
+// imageContentExtensions are the file extensions of image formats supported
+// for content-only checksums.
+var imageContentExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+// audioContentExtensions are the file extensions of audio formats supported
+// for content-only checksums.
+var audioContentExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+}
+
+// IsContentChecksum indicates whether the checksum was generated from the
+// image content or audio stream of a file only.
+func (cs SHA256Checksum) IsContentChecksum() bool {
+	return strings.HasPrefix(string(cs), ContentChecksumPrefix)
+}
+
+// SupportsImageContentCheckSum indicates whether the specified filename has
+// an extension of an image format supported for content-only checksums.
+func SupportsImageContentCheckSum(filename string) bool {
+	return imageContentExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// SupportsAudioContentCheckSum indicates whether the specified filename has
+// an extension of an audio format supported for content-only checksums.
+func SupportsAudioContentCheckSum(filename string) bool {
+	return audioContentExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// GenerateContentCheckSum returns a SHA256 hash generated from the image
+// content of a JPEG or PNG file, skipping metadata such as EXIF and XMP
+// segments, or from the audio stream of an MP3 or FLAC file, skipping ID3,
+// APE and Vorbis comment tags. The checksum is prefixed with
+// ContentChecksumPrefix. For other file types the checksum of the complete
+// file is returned.
+func GenerateContentCheckSum(file string) (SHA256Checksum, error) {
+
+	var checksum SHA256Checksum
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return checksum, err
+	}
+
+	// Note the duplicate f.Close() call at end of function and why
+	defer func() {
+		if err := f.Close(); err != nil {
+			// Ignore "file already closed" errors
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					file,
+					err,
+				)
+			}
+		}
+	}()
+
+	checksum, err = generateContentCheckSum(f)
+	if err != nil {
+		return checksum, fmt.Errorf("failed to generate content checksum for %q: %w", file, err)
+	}
+
+	return checksum, f.Close()
+}
+
+// GenerateContentCheckSumFS returns a content-only checksum (see
+// GenerateContentCheckSum) for the named file within the provided
+// filesystem.
+func GenerateContentCheckSumFS(fsys fs.FS, name string) (SHA256Checksum, error) {
+
+	var checksum SHA256Checksum
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return checksum, err
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			// Ignore "file already closed" errors
+			if !errors.Is(err, fs.ErrClosed) {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					name,
+					err,
+				)
+			}
+		}
+	}()
+
+	checksum, err = generateContentCheckSum(f)
+	if err != nil {
+		return checksum, fmt.Errorf("failed to generate content checksum for %q: %w", name, err)
+	}
+
+	return checksum, f.Close()
+}
+
+// generateContentCheckSum returns a content-only checksum generated from the
+// content read from the provided io.Reader, falling back to a checksum of
+// all content if the format is not recognized.
+func generateContentCheckSum(r io.Reader) (SHA256Checksum, error) {
+
+	br := bufio.NewReader(r)
+	h := sha256.New()
+
+	// A short file is not a supported format
+	signature, _ := br.Peek(len(pngSignature))
+
+	var err error
+	switch {
+	case bytes.Equal(signature, pngSignature):
+		err = hashPNGContent(h, br)
+	case len(signature) >= 2 && signature[0] == 0xFF && signature[1] == jpegMarkerStartOfImage:
+		err = hashJPEGContent(h, br)
+	case bytes.HasPrefix(signature, []byte("ID3")),
+		bytes.HasPrefix(signature, []byte("fLaC")),
+		len(signature) >= 2 && signature[0] == 0xFF && signature[1]&0xE0 == 0xE0:
+		err = hashAudioContent(h, br)
+	default:
+		return generateCheckSum(br)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return SHA256Checksum(fmt.Sprintf("%s%x", ContentChecksumPrefix, h.Sum(nil))), nil
+}
+
+// hashJPEGContent writes all JPEG segments other than metadata segments to
+// the provided hash. All content following the first start of scan marker
+// (compressed image data) is included.
+func hashJPEGContent(h hash.Hash, r *bufio.Reader) error {
+
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return err
+	}
+	_, _ = h.Write(soi[:])
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("unexpected end of JPEG file: %w", err)
+		}
+		if b != 0xFF {
+			return fmt.Errorf("invalid JPEG marker prefix %#x", b)
+		}
+
+		// markers may be preceded by any number of fill bytes
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF {
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return fmt.Errorf("unexpected end of JPEG file: %w", err)
+		}
+
+		// standalone markers without a length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0xD9 {
+			_, _ = h.Write([]byte{0xFF, marker})
+			if marker == 0xD9 {
+				return nil
+			}
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return fmt.Errorf("unexpected end of JPEG file: %w", err)
+		}
+		if length < 2 {
+			return fmt.Errorf("invalid JPEG segment length %d", length)
+		}
+
+		switch marker {
+		case jpegMarkerAPP1, jpegMarkerAPP13, jpegMarkerComment:
+			if _, err := r.Discard(int(length) - 2); err != nil {
+				return fmt.Errorf("unexpected end of JPEG file: %w", err)
+			}
+			continue
+		}
+
+		_, _ = h.Write([]byte{0xFF, marker, byte(length >> 8), byte(length)})
+		if _, err := io.CopyN(h, r, int64(length)-2); err != nil {
+			return fmt.Errorf("unexpected end of JPEG file: %w", err)
+		}
+
+		if marker == jpegMarkerStartOfScan {
+			_, err := io.Copy(h, r)
+			return err
+		}
+	}
+}
+
+// hashPNGContent writes the signature and all chunks other than metadata
+// chunks to the provided hash.
+func hashPNGContent(h hash.Hash, r *bufio.Reader) error {
+
+	if _, err := io.CopyN(h, r, int64(len(pngSignature))); err != nil {
+		return err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("unexpected end of PNG file: %w", err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:])
+
+		// chunk data is followed by a 4 byte CRC
+		if pngMetadataChunks[chunkType] {
+			if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+				return fmt.Errorf("unexpected end of PNG file: %w", err)
+			}
+			continue
+		}
+
+		_, _ = h.Write(header[:])
+		if _, err := io.CopyN(h, r, length+4); err != nil {
+			return fmt.Errorf("unexpected end of PNG file: %w", err)
+		}
+
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
+
+// hashAudioContent writes the audio stream of an MP3 or FLAC file to the
+// provided hash, skipping a leading ID3v2 tag, FLAC metadata blocks other
+// than STREAMINFO and trailing ID3v1 and APEv2 tags.
+func hashAudioContent(h hash.Hash, r *bufio.Reader) error {
+
+	header, _ := r.Peek(10)
+	if len(header) == 10 && bytes.HasPrefix(header, []byte("ID3")) {
+
+		// tag size excludes the header and the optional footer
+		size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 |
+			int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+		size += 10
+		if header[5]&0x10 != 0 {
+			size += 10
+		}
+
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return fmt.Errorf("unexpected end of ID3v2 tag: %w", err)
+		}
+	}
+
+	if signature, _ := r.Peek(4); bytes.Equal(signature, []byte("fLaC")) {
+		if err := hashFLACMetadata(h, r); err != nil {
+			return err
+		}
+	}
+
+	tail, err := copyHoldingTail(h, r, audioTailWindow)
+	if err != nil {
+		return err
+	}
+
+	if len(tail) >= id3v1TagSize && bytes.HasPrefix(tail[len(tail)-id3v1TagSize:], []byte("TAG")) {
+		tail = tail[:len(tail)-id3v1TagSize]
+	}
+
+	if len(tail) >= apeFooterSize {
+		footer := tail[len(tail)-apeFooterSize:]
+		if bytes.HasPrefix(footer, []byte("APETAGEX")) {
+
+			// tag size includes the footer but not the optional header
+			size := uint64(binary.LittleEndian.Uint32(footer[12:16]))
+			if binary.LittleEndian.Uint32(footer[20:24])&(1<<31) != 0 {
+				size += uint64(apeFooterSize)
+			}
+
+			if size <= uint64(len(tail)) {
+				tail = tail[:uint64(len(tail))-size]
+			}
+		}
+	}
+
+	_, _ = h.Write(tail)
+
+	return nil
+}
+
+// hashFLACMetadata writes the FLAC signature and STREAMINFO metadata block to
+// the provided hash, skipping all other metadata blocks (e.g., Vorbis
+// comments, pictures and padding).
+func hashFLACMetadata(h hash.Hash, r *bufio.Reader) error {
+
+	if _, err := io.CopyN(h, r, 4); err != nil {
+		return err
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return fmt.Errorf("unexpected end of FLAC metadata: %w", err)
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		// STREAMINFO; the "last block" flag is masked since it depends on
+		// which other blocks are present
+		if blockType == 0 {
+			_, _ = h.Write([]byte{blockType, header[1], header[2], header[3]})
+			if _, err := io.CopyN(h, r, size); err != nil {
+				return fmt.Errorf("unexpected end of FLAC metadata: %w", err)
+			}
+		} else if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return fmt.Errorf("unexpected end of FLAC metadata: %w", err)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// copyHoldingTail writes all content read from the provided reader to the
+// hash except for the final window bytes, which are returned so that
+// trailing tags can be removed.
+func copyHoldingTail(h hash.Hash, r io.Reader, window int) ([]byte, error) {
+
+	buf := make([]byte, 0, window+32<<10)
+	chunk := make([]byte, 32<<10)
+
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		if len(buf) > window {
+			excess := len(buf) - window
+			_, _ = h.Write(buf[:excess])
+			buf = buf[:copy(buf, buf[excess:])]
+		}
+
+		if errors.Is(err, io.EOF) {
+			return buf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
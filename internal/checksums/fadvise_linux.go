@@ -0,0 +1,35 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build linux && (amd64 || arm64)
+
+package checksums
+
+import (
+	"os"
+	"syscall"
+)
+
+// Access pattern advice for posix_fadvise(2).
+const (
+	fadviseSequential = 2 // POSIX_FADV_SEQUENTIAL
+	fadviseDontNeed   = 4 // POSIX_FADV_DONTNEED
+)
+
+// fadvise applies the access pattern advice to the entire file. Advice is
+// only a hint, so errors are ignored.
+func fadvise(f *os.File, advice int) {
+	_, _, _ = syscall.Syscall6(
+		syscall.SYS_FADVISE64,
+		f.Fd(),
+		0,
+		0,
+		uintptr(advice),
+		0,
+		0,
+	)
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build !(linux && (amd64 || arm64))
+
+package checksums
+
+import "os"
+
+// Access pattern advice is not supported on this platform.
+const (
+	fadviseSequential = 0
+	fadviseDontNeed   = 0
+)
+
+// fadvise is not supported on this platform.
+func fadvise(_ *os.File, _ int) {}
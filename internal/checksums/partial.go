@@ -0,0 +1,129 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package checksums
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PartialChecksumPrefix is the prefix applied to checksums generated from
+// only the first bytes of a file (see GeneratePartialCheckSum). These
+// checksums record the number of bytes hashed followed by the hash, e.g.,
+// "partial-sha256:67108864:<hash>". Files with matching partial checksums
+// are likely, but not confirmed, to be identical.
+const PartialChecksumPrefix string = "partial-sha256:"
+
+// IsPartial indicates whether the checksum was generated from only the
+// first bytes of a file.
+func (cs SHA256Checksum) IsPartial() bool {
+	return strings.HasPrefix(string(cs), PartialChecksumPrefix)
+}
+
+// partialSize returns the number of bytes hashed recorded in a partial
+// checksum.
+func (cs SHA256Checksum) partialSize() (int64, error) {
+	sizeField, _, _ := strings.Cut(strings.TrimPrefix(string(cs), PartialChecksumPrefix), ":")
+
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("%w: invalid partial checksum %q", ErrInvalidChecksum, cs)
+	}
+
+	return size, nil
+}
+
+// GeneratePartialCheckSum returns a checksum generated from the first size
+// bytes of the provided fully-qualified path to a file. The checksum is
+// prefixed with PartialChecksumPrefix and the number of bytes hashed.
+func GeneratePartialCheckSum(file string, size int64) (SHA256Checksum, error) {
+
+	var checksum SHA256Checksum
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return checksum, err
+	}
+
+	// Note the duplicate f.Close() call at end of function and why
+	defer func() {
+		if err := f.Close(); err != nil {
+			// Ignore "file already closed" errors
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					file,
+					err,
+				)
+			}
+		}
+	}()
+
+	adviseRead(f)
+
+	checksum, err = generatePartialCheckSum(f, size)
+	if err != nil {
+		return checksum, fmt.Errorf("failed to generate partial checksum for %q: %w", file, err)
+	}
+
+	adviseDone(f)
+
+	return checksum, f.Close()
+}
+
+// GeneratePartialCheckSumFS returns a partial checksum (see
+// GeneratePartialCheckSum) for the named file within the provided
+// filesystem.
+func GeneratePartialCheckSumFS(fsys fs.FS, name string, size int64) (SHA256Checksum, error) {
+
+	var checksum SHA256Checksum
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return checksum, err
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			// Ignore "file already closed" errors
+			if !errors.Is(err, fs.ErrClosed) {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					name,
+					err,
+				)
+			}
+		}
+	}()
+
+	checksum, err = generatePartialCheckSum(f, size)
+	if err != nil {
+		return checksum, fmt.Errorf("failed to generate partial checksum for %q: %w", name, err)
+	}
+
+	return checksum, f.Close()
+}
+
+// generatePartialCheckSum returns a partial checksum generated from the
+// first size bytes read from the provided io.Reader.
+func generatePartialCheckSum(r io.Reader, size int64) (SHA256Checksum, error) {
+
+	hash, err := sha256Hex(io.LimitReader(r, size))
+	if err != nil {
+		return "", err
+	}
+
+	return SHA256Checksum(fmt.Sprintf("%s%d:%s", PartialChecksumPrefix, size, hash)), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package checksums
+
+import (
+	"io"
+	"os"
+)
+
+// DefaultReadBufferSize is the default size (in bytes) of the buffer used to
+// read file content while generating checksums.
+const DefaultReadBufferSize = 32 * 1024
+
+// MinReadBufferSize is the smallest supported size (in bytes) of the buffer
+// used to read file content while generating checksums.
+const MinReadBufferSize = 4 * 1024
+
+// readBufferSize is the size of the buffer used to read file content while
+// generating checksums.
+var readBufferSize = DefaultReadBufferSize
+
+// dropCache indicates whether the kernel should be advised to drop file
+// content from the page cache once a checksum has been generated.
+var dropCache bool
+
+// SetReadBufferSize sets the size (in bytes) of the buffer used to read file
+// content while generating checksums. Larger buffers can improve throughput
+// when reading large files from spinning disks. Sizes below
+// MinReadBufferSize are ignored.
+func SetReadBufferSize(size int) {
+	if size < MinReadBufferSize {
+		return
+	}
+
+	readBufferSize = size
+}
+
+// SetDropCache sets whether the kernel should be advised to drop file
+// content from the page cache once a checksum has been generated (where
+// supported). This prevents hashing large files from evicting content
+// cached for other applications (e.g., a media server running on the same
+// system), at the cost of reading the content from disk again if needed.
+func SetDropCache(drop bool) {
+	dropCache = drop
+}
+
+// copyBuffered copies from the reader to the writer using a buffer of the
+// configured size (see SetReadBufferSize).
+func copyBuffered(w io.Writer, r io.Reader) (int64, error) {
+
+	// Hide any WriterTo implementation of the reader (e.g., *os.File) so
+	// that the provided buffer is used.
+	return io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, readBufferSize))
+}
+
+// adviseRead advises the kernel that the file content will be read
+// sequentially so that read-ahead is increased (where supported).
+func adviseRead(f *os.File) {
+	fadvise(f, fadviseSequential)
+}
+
+// adviseDone advises the kernel that the file content will not be needed
+// again so that it can be dropped from the page cache, if requested (see
+// SetDropCache).
+func adviseDone(f *os.File) {
+	if dropCache {
+		fadvise(f, fadviseDontNeed)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package completion provides functions used to generate shell completion
+// scripts covering the subcommands and flags supported by this application.
+package completion
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Supported shells for completion script generation
+const (
+	ShellBash       string = "bash"
+	ShellZsh        string = "zsh"
+	ShellFish       string = "fish"
+	ShellPowerShell string = "powershell"
+)
+
+// Flag represents a subcommand flag offered as a completion candidate.
+type Flag struct {
+
+	// Name is the flag name without any leading dashes
+	Name string
+
+	// Usage is the help text for the flag
+	Usage string
+}
+
+// Command represents a subcommand offered as a completion candidate along
+// with the flags and positional arguments that it supports.
+type Command struct {
+
+	// Name is the subcommand name
+	Name string
+
+	// Description is a brief summary of the subcommand
+	Description string
+
+	// Flags supported by the subcommand
+	Flags []Flag
+
+	// Args are fixed values accepted as positional arguments by the
+	// subcommand
+	Args []string
+}
+
+// SupportedShells returns the list of shells that completion scripts can be
+// generated for.
+func SupportedShells() []string {
+	return []string{ShellBash, ShellZsh, ShellFish, ShellPowerShell}
+}
+
+// IsSupportedShell indicates whether a completion script can be generated
+// for the specified shell.
+func IsSupportedShell(shell string) bool {
+	for _, supported := range SupportedShells() {
+		if shell == supported {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write generates a completion script for the specified shell and writes it
+// to the provided io.Writer.
+func Write(w io.Writer, shell string, appName string, commands []Command) error {
+
+	var script string
+
+	switch shell {
+	case ShellBash:
+		script = bashScript(appName, commands)
+	case ShellZsh:
+		script = zshScript(appName, commands)
+	case ShellFish:
+		script = fishScript(appName, commands)
+	case ShellPowerShell:
+		script = powerShellScript(appName, commands)
+	default:
+		return fmt.Errorf(
+			"unsupported shell %q; supported shells: %s",
+			shell,
+			strings.Join(SupportedShells(), ", "),
+		)
+	}
+
+	if _, err := io.WriteString(w, script); err != nil {
+		return fmt.Errorf("failed to write %s completion script: %w", shell, err)
+	}
+
+	return nil
+}
+
+// commandNames returns the names of the provided commands.
+func commandNames(commands []Command) []string {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+
+	return names
+}
+
+// flagNames returns the sorted names of the provided flags prefixed with a
+// single dash as expected by the flag package.
+func flagNames(flags []Flag) []string {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// funcName returns a shell-safe function name for the provided application
+// name.
+func funcName(appName string) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(appName)
+}
+
+// singleQuote wraps a value in single quotes, escaping any embedded single
+// quotes using the POSIX shell convention.
+func singleQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func bashScript(appName string, commands []Command) string {
+
+	var b strings.Builder
+	fn := funcName(appName) + "_completions"
+
+	fmt.Fprintf(&b, "# bash completion for %s\n", appName)
+	fmt.Fprintf(&b, "# Load via: source <(%s completion bash)\n\n", appName)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur opts args\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n\n")
+	b.WriteString("    if [[ ${COMP_CWORD} -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W %s -- \"${cur}\") )\n",
+		singleQuote(strings.Join(commandNames(commands), " ")))
+	b.WriteString("        return 0\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s)\n", cmd.Name)
+		fmt.Fprintf(&b, "            opts=%s\n", singleQuote(strings.Join(flagNames(cmd.Flags), " ")))
+		fmt.Fprintf(&b, "            args=%s\n", singleQuote(strings.Join(cmd.Args, " ")))
+		b.WriteString("            ;;\n")
+	}
+	b.WriteString("        *)\n")
+	b.WriteString("            return 0\n")
+	b.WriteString("            ;;\n")
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ \"${cur}\" == -* ]]; then\n")
+	b.WriteString("        COMPREPLY=( $(compgen -W \"${opts}\" -- \"${cur}\") )\n")
+	b.WriteString("    elif [[ -n \"${args}\" ]]; then\n")
+	b.WriteString("        COMPREPLY=( $(compgen -W \"${args}\" -- \"${cur}\") )\n")
+	b.WriteString("    else\n")
+	b.WriteString("        COMPREPLY=( $(compgen -f -- \"${cur}\") )\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    return 0\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -o default -o filenames -F %s %s\n", fn, appName)
+
+	return b.String()
+}
+
+func zshScript(appName string, commands []Command) string {
+
+	var b strings.Builder
+	fn := funcName(appName)
+
+	fmt.Fprintf(&b, "#compdef %s\n\n", appName)
+	fmt.Fprintf(&b, "# zsh completion for %s\n", appName)
+	fmt.Fprintf(&b, "# Load via: source <(%s completion zsh)\n\n", appName)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local -a subcommands\n")
+	b.WriteString("    subcommands=(\n")
+	for _, cmd := range commands {
+		// colons separate the candidate from its description
+		fmt.Fprintf(&b, "        %s\n", singleQuote(cmd.Name+":"+strings.ReplaceAll(cmd.Description, ":", `\:`)))
+	}
+	b.WriteString("    )\n\n")
+	b.WriteString("    if (( CURRENT == 2 )); then\n")
+	b.WriteString("        _describe 'subcommand' subcommands\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    local -a opts args\n")
+	b.WriteString("    case ${words[2]} in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s)\n", cmd.Name)
+		fmt.Fprintf(&b, "            opts=(%s)\n", strings.Join(flagNames(cmd.Flags), " "))
+		fmt.Fprintf(&b, "            args=(%s)\n", strings.Join(cmd.Args, " "))
+		b.WriteString("            ;;\n")
+	}
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ ${PREFIX} == -* ]]; then\n")
+	b.WriteString("        compadd -- ${opts}\n")
+	b.WriteString("    elif (( ${#args} )); then\n")
+	b.WriteString("        compadd -- ${args}\n")
+	b.WriteString("    else\n")
+	b.WriteString("        _files\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "compdef %s %s\n", fn, appName)
+
+	return b.String()
+}
+
+func fishScript(appName string, commands []Command) string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# fish completion for %s\n", appName)
+	fmt.Fprintf(&b, "# Load via: %s completion fish | source\n\n", appName)
+
+	names := strings.Join(commandNames(commands), " ")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b,
+			"complete -c %s -f -n 'not __fish_seen_subcommand_from %s' -a %s -d %s\n",
+			appName,
+			names,
+			cmd.Name,
+			singleQuote(cmd.Description),
+		)
+	}
+
+	for _, cmd := range commands {
+		b.WriteString("\n")
+		for _, f := range cmd.Flags {
+			fmt.Fprintf(&b,
+				"complete -c %s -n '__fish_seen_subcommand_from %s' -o %s -d %s\n",
+				appName,
+				cmd.Name,
+				f.Name,
+				singleQuote(f.Usage),
+			)
+		}
+		if len(cmd.Args) > 0 {
+			fmt.Fprintf(&b,
+				"complete -c %s -f -n '__fish_seen_subcommand_from %s' -a %s\n",
+				appName,
+				cmd.Name,
+				singleQuote(strings.Join(cmd.Args, " ")),
+			)
+		}
+	}
+
+	return b.String()
+}
+
+func powerShellScript(appName string, commands []Command) string {
+
+	// PowerShell single-quoted strings escape embedded single quotes by
+	// doubling them.
+	psQuote := func(value string) string {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# PowerShell completion for %s\n", appName)
+	fmt.Fprintf(&b, "# Load via: %s completion powershell | Out-String | Invoke-Expression\n\n", appName)
+	fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName %s, %s -ScriptBlock {\n",
+		psQuote(appName), psQuote(appName+".exe"))
+	b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+	b.WriteString("    $subcommands = [ordered]@{\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s = %s\n", psQuote(cmd.Name), psQuote(cmd.Description))
+	}
+	b.WriteString("    }\n\n")
+	b.WriteString("    $flags = @{\n")
+	for _, cmd := range commands {
+		quoted := make([]string, 0, len(cmd.Flags))
+		for _, name := range flagNames(cmd.Flags) {
+			quoted = append(quoted, psQuote(name))
+		}
+		fmt.Fprintf(&b, "        %s = @(%s)\n", psQuote(cmd.Name), strings.Join(quoted, ", "))
+	}
+	b.WriteString("    }\n\n")
+	b.WriteString("    $positional = @{\n")
+	for _, cmd := range commands {
+		quoted := make([]string, 0, len(cmd.Args))
+		for _, arg := range cmd.Args {
+			quoted = append(quoted, psQuote(arg))
+		}
+		fmt.Fprintf(&b, "        %s = @(%s)\n", psQuote(cmd.Name), strings.Join(quoted, ", "))
+	}
+	b.WriteString("    }\n\n")
+	b.WriteString("    $elements = $commandAst.CommandElements\n")
+	b.WriteString("    if ($elements.Count -eq 1 -or ($elements.Count -eq 2 -and $wordToComplete -ne '')) {\n")
+	b.WriteString("        $subcommands.Keys | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	b.WriteString("            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $subcommands[$_])\n")
+	b.WriteString("        }\n")
+	b.WriteString("        return\n")
+	b.WriteString("    }\n\n")
+	b.WriteString("    $subcommand = $elements[1].ToString()\n")
+	b.WriteString("    if (-not $flags.ContainsKey($subcommand)) {\n")
+	b.WriteString("        return\n")
+	b.WriteString("    }\n\n")
+	b.WriteString("    $candidates = $flags[$subcommand]\n")
+	b.WriteString("    if (-not $wordToComplete.StartsWith('-')) {\n")
+	b.WriteString("        $candidates = $positional[$subcommand]\n")
+	b.WriteString("    }\n\n")
+	b.WriteString("    $candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	b.WriteString("        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+
+	return b.String()
+}
@@ -13,11 +13,30 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
-
+	"time"
+
+	"github.com/atc0005/bridge/internal/archive"
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/completion"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/fuzzyhash"
+	"github.com/atc0005/bridge/internal/imagehash"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/notify"
 	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/publish"
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/script"
+	"github.com/atc0005/bridge/internal/server"
+	"github.com/atc0005/bridge/internal/units"
+	"github.com/atc0005/bridge/internal/watch"
 )
 
 // ErrInvalidSubcommand represents cases where the user did not pass a valid
@@ -52,8 +71,82 @@ var version = "x.y.z"
 const myAppName string = "bridge"
 const myAppURL string = "https://github.com/atc0005/bridge"
 
+// CompletionSubcommand is meant as a label to be easily used/referenced in
+// place of the subcommand of the same name.
+const CompletionSubcommand string = "completion"
+
+// WatchSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const WatchSubcommand string = "watch"
+
+// ServeSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const ServeSubcommand string = "serve"
+
+// PurgeSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const PurgeSubcommand string = "purge"
+
+// VerifyBackupSubcommand is meant as a label to be easily used/referenced in
+// place of the subcommand of the same name.
+const VerifyBackupSubcommand string = "verify-backup"
+
+// UndoSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const UndoSubcommand string = "undo"
+
+// StatsSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const StatsSubcommand string = "stats"
+
+// MergeSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const MergeSubcommand string = "merge"
+
+// ExploreSubcommand is meant as a label to be easily used/referenced in
+// place of the subcommand of the same name.
+const ExploreSubcommand string = "explore"
+
+// CheckSubcommand is meant as a label to be easily used/referenced in place
+// of the subcommand of the same name.
+const CheckSubcommand string = "check"
+
+// SelfTestSubcommand is meant as a label to be easily used/referenced in
+// place of the subcommand of the same name.
+const SelfTestSubcommand string = "selftest"
+
+// APITokenEnvVar is the environment variable consulted for the API bearer
+// token if one is not provided via flag.
+const APITokenEnvVar string = "BRIDGE_API_TOKEN"
+
+// SMTPPasswordEnvVar is the environment variable consulted for the password
+// used to send email notifications if one is not provided via flag.
+const SMTPPasswordEnvVar string = "BRIDGE_SMTP_PASSWORD"
+
+// PostTokenEnvVar is the environment variable consulted for the bearer token
+// used to post results if one is not provided via flag.
+const PostTokenEnvVar string = "BRIDGE_POST_TOKEN"
+
 // TODO: Needed?
-var validSubcommands = []string{PruneSubcommand, ReportSubcommand}
+var validSubcommands = []string{PruneSubcommand, ReportSubcommand, WatchSubcommand, ServeSubcommand, PurgeSubcommand, VerifyBackupSubcommand, UndoSubcommand, StatsSubcommand, MergeSubcommand, ExploreSubcommand, CheckSubcommand, SelfTestSubcommand, CompletionSubcommand}
+
+// subcommandDescriptions provides a brief summary of each subcommand for use
+// in help output and generated shell completion scripts.
+var subcommandDescriptions = map[string]string{
+	PruneSubcommand:        "Remove (and optionally backup) files flagged in a CSV report",
+	ReportSubcommand:       "Generate a report of duplicate files across one or more paths",
+	WatchSubcommand:        "Monitor paths and report newly introduced duplicate files",
+	ServeSubcommand:        "Serve an HTTP API for running scans and removing duplicates",
+	PurgeSubcommand:        "Remove backup copies older than a retention period",
+	VerifyBackupSubcommand: "Confirm backup copies still match their recorded checksums",
+	UndoSubcommand:         "Restore files removed by the last prune run from backup copies",
+	StatsSubcommand:        "Summarize previously generated CSV reports without rescanning",
+	MergeSubcommand:        "Combine previously generated CSV reports into a single report",
+	ExploreSubcommand:      "Browse wasted space per directory from a scan or CSV reports",
+	CheckSubcommand:        "Validate the flags of another subcommand and run pre-flight checks",
+	SelfTestSubcommand:     "Confirm duplicate files are found and pruned as expected",
+	CompletionSubcommand:   "Generate a shell completion script",
+}
 
 // activeFlagSet represents the matching flagset for the options the user
 // chose. This is referenced later from Validate() in order to print the
@@ -61,13 +154,6 @@ var validSubcommands = []string{PruneSubcommand, ReportSubcommand}
 // fails.
 var activeFlagSet *flag.FlagSet
 
-// InputCSVFieldCount represents the number of expected fields when processing
-// an input file previously generated by this application for file removal
-// decision logic. This value is enforced by the CSV Reader object that
-// processes the CSV input file.
-// TODO: Find a better place to root this value
-const InputCSVFieldCount int = 6
-
 // multiValueFlag is a custom type that satisfies the flag.Value interface in
 // order to accept multiple values for some of our flags
 type multiValueFlag []string
@@ -92,6 +178,36 @@ func (i *multiValueFlag) Set(value string) error {
 	return nil
 }
 
+// byteSizeValue is a custom type that satisfies the flag.Value interface in
+// order to accept sizes with units (e.g., 500KB, 10MiB) for size flags
+type byteSizeValue int64
+
+// newByteSizeValue sets the provided size to its default value and returns
+// it as a byteSizeValue
+func newByteSizeValue(val int64, p *int64) *byteSizeValue {
+	*p = val
+	return (*byteSizeValue)(p)
+}
+
+// String returns the size in bytes
+func (b *byteSizeValue) String() string {
+	if b == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+// Set parses the provided size (see units.ParseByteCount)
+func (b *byteSizeValue) Set(value string) error {
+	size, err := units.ParseByteCount(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSizeValue(size)
+	return nil
+}
+
 // Branding is responsible for emitting application name, version and origin
 func Branding() {
 	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "\n%s %s\n%s\n\n", myAppName, version, myAppURL)
@@ -121,6 +237,32 @@ func MainCmdUsage(subCmds ...string) func() {
 
 }
 
+// CheckUsage is a custom override for the default Help text of the check
+// subcommand, which accepts the subcommand to check (and its flags) as
+// arguments.
+func CheckUsage(flagSet *flag.FlagSet) func() {
+
+	return func() {
+
+		myBinaryName := filepath.Base(os.Args[0])
+
+		Branding()
+
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage of \"%s %s\":\n",
+			myBinaryName,
+			flagSet.Name(),
+		)
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "\t%s %s SUBCOMMAND [FLAGS]\n\n",
+			myBinaryName,
+			flagSet.Name(),
+		)
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Validates the flags of the specified subcommand and runs pre-flight checks without running it.")
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "See available flags for each subcommand by running:\n\t%s SUBCOMMAND -h\n",
+			myBinaryName,
+		)
+	}
+}
+
 // SubcommandUsage is a custom override for the default Help text provided by
 // the flag package. Here we prepend some additional metadata to the existing
 // output.
@@ -160,6 +302,12 @@ type Config struct {
 	// report files.
 	IgnoreErrors bool
 
+	// IgnoreErrorsFor limits the errors ignored to the listed categories
+	// (e.g., permission-denied), allowing other errors such as checksum
+	// mismatches to remain fatal. Listing any category implies
+	// IgnoreErrors.
+	IgnoreErrorsFor multiValueFlag
+
 	// BlankLineBetweenSets controls whether a blank line is added between
 	// each set of matching files in console and file output.
 	BlankLineBetweenSets bool
@@ -184,24 +332,424 @@ type Config struct {
 	// limit the threshold to a specific size (e.g., DVD ISO images)
 	FileSizeThreshold int64
 
+	// MaxFileSize is the maximum size in bytes of files added to our
+	// FileSizeIndex. Zero indicates no limit.
+	MaxFileSize int64
+
+	// FailIfWastedGT is the wasted space budget in bytes for the report
+	// subcommand; a distinct exit code is returned if duplicate files waste
+	// more space than this. Zero indicates no budget.
+	FailIfWastedGT int64
+
+	// FailIfDupesGT is the duplicate files budget for the report
+	// subcommand; a distinct exit code is returned if more duplicate files
+	// than this are found. Zero indicates no budget.
+	FailIfDupesGT int
+
+	// WhatIf indicates whether the stats subcommand should simulate each
+	// keep policy, reporting the files which would be removed and the space
+	// reclaimed by each
+	WhatIf bool
+
 	// OutputCSVFile is the fully-qualified path to a CSV file that this application
 	// should generate
 	OutputCSVFile string
 
-	// OutputCSVFile is the fully-qualified path to a CSV file that this application
-	// should use for file removal decisions
-	InputCSVFile string
+	// CSVMaxSize is the size in bytes at which the CSV file generated by the
+	// report subcommand is split into numbered parts. Zero indicates no
+	// limit.
+	CSVMaxSize int64
+
+	// MaxDelete is the maximum number of files that the prune subcommand
+	// will remove in one run. Zero indicates no limit.
+	MaxDelete int
+
+	// MaxDeleteBytes is the maximum total size in bytes of files that the
+	// prune subcommand will remove in one run. Zero indicates no limit.
+	MaxDeleteBytes int64
+
+	// PlanFile is the fully-qualified path to a CSV file listing the changes
+	// that the prune subcommand would make in dry-run mode
+	PlanFile string
+
+	// ScriptFormat is the shell that a script removing duplicate files
+	// (instead of removing them directly) is generated for, if requested
+	ScriptFormat script.Format
+
+	// ScriptFile is the fully-qualified path to the script removing
+	// duplicate files that this application should generate
+	ScriptFile string
+
+	// RemoveListFile is the fully-qualified path to a file listing the
+	// duplicate files to remove (instead of removing them) that this
+	// application should generate
+	RemoveListFile string
+
+	// NullDelimited indicates whether lists of paths read (see PathsFrom)
+	// or written (see RemoveListFile) are NUL-delimited instead of
+	// newline-delimited
+	NullDelimited bool
+
+	// CreateBackupDir indicates whether the prune subcommand should create
+	// the backup directory (or the directory containing the backup archive)
+	// if it does not exist
+	CreateBackupDir bool
+
+	// BackupCollision is the policy applied by the prune subcommand when a
+	// backup copy of a file already exists in the backup directory
+	BackupCollision paths.CollisionPolicy
+
+	// Normalization is the Unicode normalization form applied to paths so
+	// that paths which differ only in normalization (e.g., between macOS
+	// and Linux) compare as equal
+	Normalization paths.Normalization
+
+	// ReadBufferSize is the size in bytes of the buffer used to read file
+	// content while generating checksums
+	ReadBufferSize int
+
+	// DropCache indicates whether the kernel should be advised to drop
+	// file content from the page cache once a checksum has been generated
+	DropCache bool
+
+	// BackupHardlinks indicates whether backup copies of files sharing a
+	// checksum should be stored once and hard linked
+	BackupHardlinks bool
+
+	// BackupArchive is the fully-qualified path to a tar.gz or zip archive
+	// that the prune subcommand should create to hold backup copies of
+	// files instead of a mirrored directory tree
+	BackupArchive string
+
+	// AuditLogFile is the fully-qualified path to a CSV file recording each
+	// file backed up or removed by the prune subcommand
+	AuditLogFile string
+
+	// JournalFile is the fully-qualified path to the journal recording each
+	// backup and removal before it is attempted and once it completes
+	JournalFile string
+
+	// Resume indicates whether the prune subcommand should resume the run
+	// recorded as interrupted in the journal, skipping files it already
+	// removed
+	Resume bool
+
+	// FreeSpace indicates whether the prune subcommand should report the
+	// free space of each affected filesystem before and after removal
+	FreeSpace bool
+
+	// AssumeYes indicates whether the prune subcommand should remove files
+	// without prompting for confirmation
+	AssumeYes bool
+
+	// AllowRemoveAll indicates whether the prune subcommand may remove every
+	// file in a duplicate file set
+	AllowRemoveAll bool
+
+	// ForceUnlock indicates whether the prune subcommand should remove
+	// existing lock files (e.g., left behind by a run which was killed)
+	// instead of refusing to run
+	ForceUnlock bool
+
+	// SkipStale indicates whether the prune subcommand should skip input CSV
+	// rows for files which no longer exist or have changed since the report
+	// was generated instead of treating them as errors
+	SkipStale bool
+
+	// RemoveUnkept indicates whether the prune subcommand should use the
+	// keep column instead of the remove_file column for removal decisions,
+	// removing files which are not marked to be kept
+	RemoveUnkept bool
+
+	// RelativePaths indicates whether the directory column of generated CSV
+	// files and Excel workbooks should record paths relative to the
+	// evaluated path instead of fully-qualified paths
+	RelativePaths bool
+
+	// Root is the directory that relative paths recorded in input CSV files
+	// (see RelativePaths) are resolved against by the prune subcommand
+	Root string
+
+	// InputCSVFiles is the collection of fully-qualified paths (or glob
+	// patterns) to CSV files that this application should use for file
+	// removal decisions
+	InputCSVFiles multiValueFlag
+
+	// InputFdupesFiles is the collection of fully-qualified paths (or glob
+	// patterns) to files containing the output of fdupes or jdupes that
+	// this application should use for file removal decisions
+	InputFdupesFiles multiValueFlag
 
 	// ExcelFile is the fully-qualified path to an Excel file that this
 	// application should generate
 	ExcelFile string
 
+	// CompareBytes indicates whether files of the same size should be
+	// compared byte for byte instead of generating checksums
+	CompareBytes bool
+
+	// VerifyBytes indicates whether files with matching checksums should be
+	// compared byte for byte before being reported as duplicates
+	VerifyBytes bool
+
+	// RetryChanged indicates whether files skipped because they changed
+	// between being found and being read should be evaluated again once all
+	// other files have been evaluated
+	RetryChanged bool
+
+	// FastHash is the number of MiB at the start of very large files (see
+	// FastHashThreshold) used to generate partial checksums instead of
+	// reading the entire file. Partial checksums are disabled if 0.
+	FastHash int64
+
+	// FastHashThreshold is the file size in MiB above which files are
+	// evaluated using partial checksums (if enabled)
+	FastHashThreshold int64
+
+	// ExtraHash is an additional hash algorithm used to generate a second
+	// hash of each duplicate file alongside the SHA256 checksum. No
+	// additional hash is generated if empty.
+	ExtraHash checksums.Algorithm
+
+	// PreviousCSVFile is the fully-qualified path to a CSV file generated
+	// by an earlier run of the report subcommand whose checksums are reused
+	// for unchanged files
+	PreviousCSVFile string
+
+	// SnapshotFile is the fully-qualified path to a snapshot manifest of
+	// all evaluated files that this application should generate
+	SnapshotFile string
+
+	// BaselineFile is the fully-qualified path to a snapshot manifest
+	// written by an earlier scan. Duplicate file sets whose files are all
+	// unchanged since are excluded.
+	BaselineFile string
+
+	// AllocatedSize indicates whether the space allocated on disk to
+	// duplicate files should be recorded and used to calculate wasted space
+	AllocatedSize bool
+
+	// Walkers is the number of directories read concurrently when local
+	// paths are crawled recursively
+	Walkers int
+
+	// Validators is the number of files whose checksums are verified
+	// concurrently before files are removed
+	Validators int
+
+	// SkipVerify indicates whether verifying the checksums of files listed
+	// in input CSV files before removal should be skipped, checking only
+	// their existence and size
+	SkipVerify bool
+
+	// NDJSONFile is the fully-qualified path to a file that this
+	// application should generate listing duplicate file sets as
+	// newline-delimited JSON
+	NDJSONFile string
+
+	// Stream indicates whether duplicate file sets should be written to the
+	// CSV and NDJSON files as soon as they are confirmed instead of after
+	// all files have been evaluated
+	Stream bool
+
+	// DirSummaryCSVFile is the fully-qualified path to a CSV file that this
+	// application should generate listing duplicate files and wasted space
+	// per directory
+	DirSummaryCSVFile string
+
+	// ExtSummaryCSVFile is the fully-qualified path to a CSV file that this
+	// application should generate listing duplicate files and wasted space
+	// per file extension
+	ExtSummaryCSVFile string
+
+	// SmallFilesCSVFile is the fully-qualified path to a CSV file that this
+	// application should generate listing files below the file size
+	// threshold (e.g., zero-byte files)
+	SmallFilesCSVFile string
+
+	// SummaryFile is the fully-qualified path to a JSON file that this
+	// application should generate containing a summary of the scan results
+	SummaryFile string
+
+	// ErrorsCSVFile is the fully-qualified path to a CSV file that this
+	// application should generate listing the errors ignored and files
+	// skipped during the run
+	ErrorsCSVFile string
+
+	// FileOrder is the order in which files are listed within each
+	// duplicate file set in console, CSV and Excel output
+	FileOrder matches.FileOrder
+
+	// SortOrder is the order in which duplicate file sets are listed in
+	// console, CSV and Excel output
+	SortOrder matches.SortOrder
+
+	// KeepPolicy determines which file from each duplicate file set is
+	// suggested as the original to keep in the keep column of generated
+	// files
+	KeepPolicy matches.KeepPolicy
+
+	// PreferPaths lists directories, in priority order, whose files are
+	// suggested as the original to keep over files elsewhere; the keep
+	// policy chooses between equally preferred files
+	PreferPaths multiValueFlag
+
+	// PreferMatch lists regular expressions matched against the full path
+	// of files which are suggested as the original to keep over other files
+	PreferMatch multiValueFlag
+
+	// RemoveMatch lists regular expressions matched against the full path
+	// of files which are suggested for removal over other files
+	RemoveMatch multiValueFlag
+
 	// BackupDirectory is writable directory path where files should be
 	// relocated instead of removed
 	BackupDirectory string
 
+	// NearDuplicates indicates whether images should be compared using
+	// perceptual hashes in order to find visually identical images
+	NearDuplicates bool
+
+	// NearDuplicatesDistance is the maximum number of bits by which the
+	// perceptual hashes of visually identical images may differ
+	NearDuplicatesDistance int
+
+	// NearDuplicatesCSVFile is the fully-qualified path to a CSV file that
+	// this application should generate listing near duplicate image sets
+	NearDuplicatesCSVFile string
+
+	// ContentOnly indicates whether JPEG and PNG files should be compared
+	// using checksums of their image content only, ignoring metadata
+	ContentOnly bool
+
+	// AudioContentOnly indicates whether MP3 and FLAC files should be
+	// compared using checksums of their audio stream only, ignoring tags
+	AudioContentOnly bool
+
+	// EXIF indicates whether EXIF metadata columns (capture time, camera
+	// make and model, dimensions) should be included for image files in
+	// generated CSV and Excel files
+	EXIF bool
+
+	// EXIFMatches indicates whether images with differing content but
+	// matching EXIF capture time and dimensions should be reported
+	EXIFMatches bool
+
+	// EXIFMatchesCSVFile is the fully-qualified path to a CSV file that this
+	// application should generate listing EXIF match sets
+	EXIFMatchesCSVFile string
+
+	// RelatedFiles indicates whether files with near-identical names but
+	// differing content (e.g., edited variants) should be reported
+	RelatedFiles bool
+
+	// RelatedFilesCSVFile is the fully-qualified path to a CSV file that
+	// this application should generate listing related file sets
+	RelatedFilesCSVFile string
+
+	// SimilarFiles indicates whether documents and archives should be
+	// compared using fuzzy hashes in order to find highly similar files
+	SimilarFiles bool
+
+	// SimilarFilesScore is the minimum similarity score (1-100) of fuzzy
+	// hashes of files reported as highly similar
+	SimilarFilesScore int
+
+	// SimilarFilesCSVFile is the fully-qualified path to a CSV file that
+	// this application should generate listing similar file sets
+	SimilarFilesCSVFile string
+
+	// WatchInterval is the delay between checks of changed files (or
+	// between scans of the monitored paths if filesystem notifications are
+	// unavailable) by the watch subcommand
+	WatchInterval time.Duration
+
+	// OlderThan is the retention period for backup copies; the purge
+	// subcommand removes files in the backup directory last modified longer
+	// ago than this. The report subcommand only evaluates files last
+	// modified longer ago than this, if specified.
+	OlderThan time.Duration
+
+	// NewerThan limits the files evaluated by the report subcommand to
+	// those last modified more recently than this, if specified
+	NewerThan time.Duration
+
+	// ListenAddress is the host:port that the serve subcommand listens on
+	ListenAddress string
+
+	// MetricsListenAddress is the (optional) host:port that the watch
+	// subcommand serves Prometheus metrics on
+	MetricsListenAddress string
+
+	// NotifyWebhook is the (optional) URL that a JSON summary is posted to
+	// once the report or prune subcommand finishes
+	NotifyWebhook string
+
+	// NotifySMTPServer is the (optional) host:port of the mail server used
+	// to email a summary once the report or prune subcommand finishes
+	NotifySMTPServer string
+
+	// NotifySMTPUsername is the (optional) username used to authenticate to
+	// the mail server
+	NotifySMTPUsername string
+
+	// NotifySMTPPassword is the password used to authenticate to the mail
+	// server
+	NotifySMTPPassword string
+
+	// NotifyEmailFrom is the sender address of email notifications
+	NotifyEmailFrom string
+
+	// NotifyEmailTo lists the recipient addresses of email notifications
+	NotifyEmailTo multiValueFlag
+
+	// PostURL is the (optional) URL that the report subcommand posts all
+	// duplicate file sets (along with a summary) to as JSON
+	PostURL string
+
+	// PostHeaders lists additional request headers, in "Name: value" form,
+	// sent when posting results
+	PostHeaders multiValueFlag
+
+	// PostToken is the (optional) bearer token sent when posting results
+	PostToken string
+
+	// APIToken is the (optional) bearer token required by the serve
+	// subcommand for all API requests
+	APIToken string
+
+	// CompletionShell is the name of the shell that a completion script
+	// should be generated for
+	CompletionShell string
+
+	// KeepSelfTestDir indicates whether the temporary directory used by the
+	// selftest subcommand should be kept for inspection instead of removed
+	KeepSelfTestDir bool
+
+	// Subcommand is the subcommand whose flags were parsed. For the check
+	// subcommand, this is the subcommand being checked.
+	Subcommand string
+
 	// Paths represents the various paths checked for duplicate files
 	Paths multiValueFlag
+
+	// NoDefaultExcludes indicates whether well-known junk directories
+	// (e.g., .git, node_modules) should be evaluated instead of skipped
+	NoDefaultExcludes bool
+
+	// Color specifies whether console output of the report subcommand is
+	// colored: auto, always or never
+	Color matches.ColorMode
+
+	// NoProgress indicates whether progress (percent complete, throughput
+	// and estimated time remaining) should not be reported while generating
+	// (or verifying) checksums and while backing up or removing files
+	NoProgress bool
+
+	// PathsFrom is the fully-qualified path to a file (or "-" for standard
+	// input) listing additional paths checked for duplicate files, one per
+	// line
+	PathsFrom string
 }
 
 // NewConfig is a factory function that produces a new Config object based
@@ -229,53 +777,201 @@ func NewConfig() (*Config, error) {
 		return nil, ErrMissingSubcommand
 	}
 
-	reportCmd := flag.NewFlagSet("report", flag.ContinueOnError)
-	reportCmd.Var(&config.Paths, "path", "Path to process. This flag may be repeated for each additional path to evaluate.")
-	reportCmd.Int64Var(&config.FileSizeThreshold, "size", 1, "File size limit (in bytes) for evaluation. Files smaller than this will be skipped.")
-	reportCmd.IntVar(&config.FileDuplicatesThreshold, "duplicates", 2, "Number of files of the same file size needed before duplicate validation logic is applied.")
-	reportCmd.BoolVar(&config.RecursiveSearch, "recurse", false, "Perform recursive search into subdirectories per provided path.")
-	reportCmd.BoolVar(&config.ConsoleReport, "console", false, "Dump (approximate) CSV file equivalent to console.")
-	reportCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible. This option does not affect handling of fatal errors such as failure to generate output report files.")
-	reportCmd.StringVar(&config.OutputCSVFile, "csvfile", "", "The (required) fully-qualified path to a CSV file that this application should generate.")
-	reportCmd.StringVar(&config.ExcelFile, "excelfile", "", "The (optional) fully-qualified path to an Excel file that this application should generate.")
-
-	pruneCmd := flag.NewFlagSet("prune", flag.ContinueOnError)
-	pruneCmd.BoolVar(&config.DryRun, "dry-run", false, "Don't actually remove files. Echo what would have been done to stdout.")
-	pruneCmd.BoolVar(&config.BlankLineBetweenSets, "blank-line", false, "Add a blank line between sets of matching files in console and file output.")
-	pruneCmd.StringVar(&config.InputCSVFile, "input-csvfile", "", "The fully-qualified path to a CSV file that this application should use for file removal decisions.")
-	pruneCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The writable directory path where files should be relocated instead of removing them. The original path structure will be created starting with the specified path as the root.")
-	pruneCmd.BoolVar(&config.ConsoleReport, "console", false, "Dump (approximate) CSV file equivalent to console.")
-	pruneCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible. This option does not affect handling of fatal errors such as failure to generate output report files.")
-	pruneCmd.BoolVar(&config.UseFirstRow, "use-first-row", false, "Attempt to use the first row of the input file. Normally this row is skipped since it is usually the header row and not duplicate file data.")
+	reportCmd := newReportFlagSet(&config)
+	pruneCmd := newPruneFlagSet(&config)
+	watchCmd := newWatchFlagSet(&config)
+	serveCmd := newServeFlagSet(&config)
+	purgeCmd := newPurgeFlagSet(&config)
+	verifyBackupCmd := newVerifyBackupFlagSet(&config)
+	undoCmd := newUndoFlagSet(&config)
+	statsCmd := newStatsFlagSet(&config)
+	mergeCmd := newMergeFlagSet(&config)
+	exploreCmd := newExploreFlagSet(&config)
+	completionCmd := newCompletionFlagSet(&config)
+	checkCmd := newCheckFlagSet(&config)
+	selfTestCmd := newSelfTestFlagSet(&config)
+
+	// The check subcommand parses (and validates) the flags of the
+	// subcommand provided as its first argument without running it.
+	config.Subcommand = os.Args[1]
+	args := os.Args[2:]
+	if config.Subcommand == CheckSubcommand {
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", CheckSubcommand)
+		checkCmd.Usage = CheckUsage(checkCmd)
+		if err := checkCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from checkCmd.Parse():", err)
+			return nil, err
+		}
+
+		switch checkCmd.Arg(0) {
+		case "":
+			checkCmd.Usage()
+			return nil, fmt.Errorf("subcommand to check not specified")
+		case CheckSubcommand, SelfTestSubcommand, CompletionSubcommand:
+			checkCmd.Usage()
+			return nil, fmt.Errorf("the %s subcommand cannot be checked", checkCmd.Arg(0))
+		}
+
+		config.Subcommand = checkCmd.Arg(0)
+		args = checkCmd.Args()[1:]
+	}
 
 	// Switch on the subcommand
 	// Parse the flags for appropriate FlagSet
 	// FlagSet.Parse() requires a set of arguments to parse as input
-	// os.Args[2:] will be all arguments starting after the subcommand (found at os.Args[1])
+	// args will be all arguments starting after the subcommand (found at
+	// os.Args[1], or os.Args[2] for the check subcommand)
 
 	// FIXME: How can we have "-h" and "-help" *not* caught by this switch
 	// statement?
-	switch os.Args[1] {
+	switch config.Subcommand {
 	case PruneSubcommand:
 		// DEBUG
 		fmt.Printf("DEBUG: subcommand '%s'\n", PruneSubcommand)
 		pruneCmd.Usage = SubcommandUsage(pruneCmd)
-		if err := pruneCmd.Parse(os.Args[2:]); err != nil {
+		if err := pruneCmd.Parse(args); err != nil {
 			fmt.Println("DEBUG: err returned from pruneCmd.Parse():", err)
 			return nil, err
 		}
+		if config.NotifySMTPPassword == "" {
+			config.NotifySMTPPassword = os.Getenv(SMTPPasswordEnvVar)
+		}
 		activeFlagSet = pruneCmd
 
 	case ReportSubcommand:
 		// DEBUG
 		fmt.Printf("DEBUG: subcommand '%s'\n", ReportSubcommand)
 		reportCmd.Usage = SubcommandUsage(reportCmd)
-		if err := reportCmd.Parse(os.Args[2:]); err != nil {
+		if err := reportCmd.Parse(args); err != nil {
 			fmt.Println("DEBUG: err returned from reportCmd.Parse():", err)
 			return nil, err
 		}
+		if config.NotifySMTPPassword == "" {
+			config.NotifySMTPPassword = os.Getenv(SMTPPasswordEnvVar)
+		}
+		if config.PostToken == "" {
+			config.PostToken = os.Getenv(PostTokenEnvVar)
+		}
 		activeFlagSet = reportCmd
 
+		// Paths listed in a file (or piped from find or locate) are
+		// evaluated along with paths specified via flag.
+		if config.PathsFrom != "" {
+			list, err := paths.ReadListFile(config.PathsFrom, config.NullDelimited)
+			if err != nil {
+				return nil, err
+			}
+
+			// DEBUG
+			fmt.Printf("DEBUG: read %d paths from %q\n", len(list), config.PathsFrom)
+
+			config.Paths = append(config.Paths, list...)
+		}
+
+	case WatchSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", WatchSubcommand)
+		watchCmd.Usage = SubcommandUsage(watchCmd)
+		if err := watchCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from watchCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = watchCmd
+
+	case ServeSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", ServeSubcommand)
+		serveCmd.Usage = SubcommandUsage(serveCmd)
+		if err := serveCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from serveCmd.Parse():", err)
+			return nil, err
+		}
+		if config.APIToken == "" {
+			config.APIToken = os.Getenv(APITokenEnvVar)
+		}
+		activeFlagSet = serveCmd
+
+	case PurgeSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", PurgeSubcommand)
+		purgeCmd.Usage = SubcommandUsage(purgeCmd)
+		if err := purgeCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from purgeCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = purgeCmd
+
+	case VerifyBackupSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", VerifyBackupSubcommand)
+		verifyBackupCmd.Usage = SubcommandUsage(verifyBackupCmd)
+		if err := verifyBackupCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from verifyBackupCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = verifyBackupCmd
+
+	case UndoSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", UndoSubcommand)
+		undoCmd.Usage = SubcommandUsage(undoCmd)
+		if err := undoCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from undoCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = undoCmd
+
+	case StatsSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", StatsSubcommand)
+		statsCmd.Usage = SubcommandUsage(statsCmd)
+		if err := statsCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from statsCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = statsCmd
+
+	case MergeSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", MergeSubcommand)
+		mergeCmd.Usage = SubcommandUsage(mergeCmd)
+		if err := mergeCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from mergeCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = mergeCmd
+
+	case ExploreSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", ExploreSubcommand)
+		exploreCmd.Usage = SubcommandUsage(exploreCmd)
+		if err := exploreCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from exploreCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = exploreCmd
+
+	case SelfTestSubcommand:
+		// DEBUG
+		fmt.Printf("DEBUG: subcommand '%s'\n", SelfTestSubcommand)
+		selfTestCmd.Usage = SubcommandUsage(selfTestCmd)
+		if err := selfTestCmd.Parse(args); err != nil {
+			fmt.Println("DEBUG: err returned from selfTestCmd.Parse():", err)
+			return nil, err
+		}
+		activeFlagSet = selfTestCmd
+
+	case CompletionSubcommand:
+		// NOTE: Debug output is intentionally skipped for this subcommand
+		// since the generated completion script is emitted to stdout.
+		completionCmd.Usage = SubcommandUsage(completionCmd)
+		if err := completionCmd.Parse(args); err != nil {
+			return nil, err
+		}
+		config.CompletionShell = completionCmd.Arg(0)
+		activeFlagSet = completionCmd
+
 	// TODO: How can we allow the flag package to deal with this instead of
 	// explicitly matching against the flags here? Otherwise the default case
 	// statement is used ...
@@ -298,10 +994,28 @@ func NewConfig() (*Config, error) {
 		activeFlagSet = mainFlagSet
 	}
 
+	// Files under paths listed more than once (or nested within another
+	// listed path) would otherwise be indexed twice and then reported as
+	// duplicates of themselves. Paths are collapsed before validation so that
+	// checks of the number of paths apply to the paths actually evaluated.
+	if len(config.Paths) > 1 {
+		var skipped []string
+		config.Paths, skipped = collapsePaths(config.Paths, config.RecursiveSearch)
+		for _, message := range skipped {
+			// WARN
+			log.Println(message)
+		}
+	}
+
 	if err := config.Validate(activeFlagSet); err != nil {
 		return nil, err
 	}
 
+	// Ignoring errors of specific categories implies ignoring errors.
+	if len(config.IgnoreErrorsFor) > 0 {
+		config.IgnoreErrors = true
+	}
+
 	return &config, nil
 }
 
@@ -310,16 +1024,202 @@ func (c Config) Validate(flagset *flag.FlagSet) error {
 
 	// We enforce some common validation requirements for all subcommands
 	// and then specific requirements as applicable to each subcommand.
-	switch os.Args[1] {
+
+	// Not all subcommands support setting the normalization form
+	if c.Normalization != "" && !c.Normalization.IsValid() {
+		flagset.Usage()
+		return fmt.Errorf(
+			"invalid normalization form %q; supported values: %v",
+			c.Normalization,
+			paths.Normalizations,
+		)
+	}
+
+	// Not all subcommands support setting the read buffer size
+	if c.ReadBufferSize != 0 && c.ReadBufferSize < checksums.MinReadBufferSize {
+		flagset.Usage()
+		return fmt.Errorf("%d bytes is the minimum read buffer size", checksums.MinReadBufferSize)
+	}
+
+	// Not all subcommands support limiting the ignored error categories
+	for _, category := range c.IgnoredErrorCategories() {
+		if !category.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid error category %q; supported values: %v",
+				category,
+				errreport.Categories,
+			)
+		}
+	}
+
+	switch c.Subcommand {
 
 	case PruneSubcommand:
 
 		// DEBUG
 		fmt.Printf("DEBUG: validating subcommand '%s'\n", PruneSubcommand)
 
-		if strings.TrimSpace(c.InputCSVFile) == "" {
+		if c.InputCSVFiles == nil && c.InputFdupesFiles == nil {
+			flagset.Usage()
+			return fmt.Errorf("required input CSV file or fdupes file to process not specified")
+		}
+
+		for _, file := range c.InputCSVFiles {
+			if strings.TrimSpace(file) == "" {
+				flagset.Usage()
+				return fmt.Errorf("empty input CSV file specified")
+			}
+		}
+
+		for _, file := range c.InputFdupesFiles {
+			if strings.TrimSpace(file) == "" {
+				flagset.Usage()
+				return fmt.Errorf("empty input fdupes file specified")
+			}
+		}
+
+		if c.Root != "" && !paths.PathExists(c.Root) {
+			flagset.Usage()
+			return fmt.Errorf("specified root directory %q does not exist", c.Root)
+		}
+
+		if c.Validators < 1 {
+			flagset.Usage()
+			return fmt.Errorf("number of validators must be 1 or greater")
+		}
+
+		if c.PlanFile != "" {
+			if !c.DryRun {
+				flagset.Usage()
+				return fmt.Errorf("plan file requires dry-run mode")
+			}
+
+			if !paths.PathExists(filepath.Dir(filepath.Clean(c.PlanFile))) {
+				return fmt.Errorf("parent directory for specified plan file to create does not exist")
+			}
+		}
+
+		if c.JournalFile != "" && !paths.PathExists(filepath.Dir(filepath.Clean(c.JournalFile))) {
+			return fmt.Errorf("parent directory for specified journal to create does not exist")
+		}
+
+		if c.Resume && c.JournalFile != "" && !paths.PathExists(c.JournalFile) {
+			return fmt.Errorf("journal %q specified, but does not exist; unable to resume", c.JournalFile)
+		}
+
+		if !c.BackupCollision.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid backup collision policy %q; supported values: %v",
+				c.BackupCollision,
+				paths.CollisionPolicies,
+			)
+		}
+
+		if strings.HasPrefix(c.BackupDirectory, "sftp://") {
+			flagset.Usage()
+			return fmt.Errorf(
+				"unsupported backup location %q; SFTP backup locations are not supported, use a local directory or an S3 URL",
+				c.BackupDirectory,
+			)
+		}
+
+		if s3.IsURL(c.BackupDirectory) {
+			if _, _, err := s3.ParseURL(c.BackupDirectory); err != nil {
+				flagset.Usage()
+				return fmt.Errorf("invalid backup location: %w", err)
+			}
+
+			if c.BackupHardlinks {
+				flagset.Usage()
+				return fmt.Errorf("backup hard links are not supported for S3 backup locations")
+			}
+
+			if c.CreateBackupDir {
+				flagset.Usage()
+				return fmt.Errorf("creating the backup directory is not supported for S3 backup locations")
+			}
+		}
+
+		if c.BackupHardlinks && c.BackupDirectory == "" {
+			flagset.Usage()
+			return fmt.Errorf("backup hard links require a backup directory")
+		}
+
+		if c.BackupArchive != "" {
+			if c.BackupDirectory != "" {
+				flagset.Usage()
+				return fmt.Errorf("backup directory and backup archive cannot both be specified")
+			}
+
+			if _, err := archive.Format(c.BackupArchive); err != nil {
+				flagset.Usage()
+				return fmt.Errorf("invalid backup archive %q: %w", c.BackupArchive, err)
+			}
+
+			// Archives are written in a single pass and cannot be reopened
+			// to append the backup copies of a resumed run.
+			if c.Resume {
+				flagset.Usage()
+				return fmt.Errorf(
+					"resuming a run is not supported with a backup archive; use a backup directory or start a new run with a new backup archive",
+				)
+			}
+
+			if _, err := os.Stat(c.BackupArchive); err == nil {
+				return fmt.Errorf("backup archive %q already exists", c.BackupArchive)
+			}
+		}
+
+		if err := c.validateScript(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+		if err := c.validateRemoveList(); err != nil {
+			return err
+		}
+
+		if err := c.NotifyOptions().Validate(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+		if c.NullDelimited && c.RemoveListFile == "" {
+			flagset.Usage()
+			return fmt.Errorf("NUL-delimited mode requires a remove list file (see remove-list flag)")
+		}
+
+		if c.ScriptFormat != "" {
+			if s3.IsURL(c.BackupDirectory) {
+				flagset.Usage()
+				return fmt.Errorf("S3 backup locations are not supported by generated scripts")
+			}
+
+			if c.BackupArchive != "" {
+				flagset.Usage()
+				return fmt.Errorf("backup archives are not supported by generated scripts")
+			}
+
+			if c.BackupHardlinks {
+				flagset.Usage()
+				return fmt.Errorf("backup hard links are not supported by generated scripts")
+			}
+		}
+
+		if c.MaxDelete < 0 {
+			flagset.Usage()
+			return fmt.Errorf("maximum number of files to remove cannot be negative")
+		}
+
+		if c.MaxDeleteBytes < 0 {
 			flagset.Usage()
-			return fmt.Errorf("required input CSV file to process not specified")
+			return fmt.Errorf("maximum size of files to remove cannot be negative")
+		}
+
+		if c.ErrorsCSVFile != "" && !paths.PathExists(filepath.Dir(c.ErrorsCSVFile)) {
+			return fmt.Errorf("parent directory for specified errors CSV file to create does not exist")
 		}
 
 		// c.BackupDirectory is optional; applying length checks here
@@ -334,22 +1234,79 @@ func (c Config) Validate(flagset *flag.FlagSet) error {
 
 		if c.Paths == nil {
 			flagset.Usage()
+			if c.PathsFrom != "" {
+				return fmt.Errorf("no paths listed in %q", c.PathsFrom)
+			}
 			return fmt.Errorf("one or more paths not provided")
 		}
 
+		if c.RelativePaths {
+			if len(c.Paths) > 1 {
+				flagset.Usage()
+				return fmt.Errorf("relative paths require a single path to evaluate")
+			}
+			if s3.IsURL(c.Paths[0]) {
+				flagset.Usage()
+				return fmt.Errorf("relative paths are not supported for S3 paths: %q", c.Paths[0])
+			}
+		}
+
 		if c.FileSizeThreshold < 0 {
 			flagset.Usage()
 			return fmt.Errorf("0 bytes is the minimum size for evaluated files")
 		}
 
-		if c.FileDuplicatesThreshold < 2 {
+		if c.OlderThan < 0 || c.NewerThan < 0 {
 			flagset.Usage()
-			return fmt.Errorf("2 is the minimum duplicates number for evaluated files")
+			return fmt.Errorf("file age limits cannot be negative")
 		}
 
-		// FIXME: The PathExists checks are currently duplicated here and within
-		// matches package
-		// NOTE: Checking at this point is cheaper than waiting until later and
+		if c.OlderThan > 0 && c.NewerThan > 0 && c.OlderThan >= c.NewerThan {
+			flagset.Usage()
+			return fmt.Errorf(
+				"no files can be both older than %v and newer than %v",
+				c.OlderThan,
+				c.NewerThan,
+			)
+		}
+
+		if c.MaxFileSize < 0 {
+			flagset.Usage()
+			return fmt.Errorf("maximum size for evaluated files cannot be negative")
+		}
+
+		if c.FailIfWastedGT < 0 {
+			flagset.Usage()
+			return fmt.Errorf("wasted space budget cannot be negative")
+		}
+
+		if c.CSVMaxSize < 0 {
+			flagset.Usage()
+			return fmt.Errorf("maximum size for CSV file parts cannot be negative")
+		}
+
+		if c.FailIfDupesGT < 0 {
+			flagset.Usage()
+			return fmt.Errorf("duplicate files budget cannot be negative")
+		}
+
+		if c.MaxFileSize > 0 && c.MaxFileSize < c.FileSizeThreshold {
+			flagset.Usage()
+			return fmt.Errorf(
+				"maximum size for evaluated files (%d bytes) is smaller than the minimum size (%d bytes)",
+				c.MaxFileSize,
+				c.FileSizeThreshold,
+			)
+		}
+
+		if c.FileDuplicatesThreshold < 2 {
+			flagset.Usage()
+			return fmt.Errorf("2 is the minimum duplicates number for evaluated files")
+		}
+
+		// FIXME: The PathExists checks are currently duplicated here and within
+		// matches package
+		// NOTE: Checking at this point is cheaper than waiting until later and
 		// then attempting to write out the file.
 		switch {
 		case c.OutputCSVFile == "":
@@ -370,6 +1327,575 @@ func (c Config) Validate(flagset *flag.FlagSet) error {
 			}
 		}
 
+		if c.NearDuplicatesDistance < 0 || c.NearDuplicatesDistance > imagehash.MaxDistance {
+			flagset.Usage()
+			return fmt.Errorf(
+				"near duplicates distance must be between 0 and %d",
+				imagehash.MaxDistance,
+			)
+		}
+
+		if c.NearDuplicatesCSVFile != "" {
+			if !c.NearDuplicates {
+				flagset.Usage()
+				return fmt.Errorf("near duplicates CSV file specified without enabling near duplicates detection")
+			}
+			if !paths.PathExists(filepath.Dir(c.NearDuplicatesCSVFile)) {
+				return fmt.Errorf("parent directory for specified near duplicates CSV file to create does not exist")
+			}
+		}
+
+		if !c.SortOrder.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid sort order %q; supported values: %v",
+				c.SortOrder,
+				matches.SortOrders,
+			)
+		}
+
+		if !c.FileOrder.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid set order %q; supported values: %v",
+				c.FileOrder,
+				matches.FileOrders,
+			)
+		}
+
+		if !c.Color.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid color mode %q; supported values: %v",
+				c.Color,
+				matches.ColorModes,
+			)
+		}
+
+		if !c.KeepPolicy.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid keep policy %q; supported values: %v",
+				c.KeepPolicy,
+				matches.KeepPolicies,
+			)
+		}
+
+		if err := c.validateKeepPreferences(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+		if c.EXIFMatchesCSVFile != "" {
+			if !c.EXIFMatches {
+				flagset.Usage()
+				return fmt.Errorf("EXIF matches CSV file specified without enabling EXIF matches detection")
+			}
+			if !paths.PathExists(filepath.Dir(c.EXIFMatchesCSVFile)) {
+				return fmt.Errorf("parent directory for specified EXIF matches CSV file to create does not exist")
+			}
+		}
+
+		if c.RelatedFilesCSVFile != "" {
+			if !c.RelatedFiles {
+				flagset.Usage()
+				return fmt.Errorf("related files CSV file specified without enabling related files detection")
+			}
+			if !paths.PathExists(filepath.Dir(c.RelatedFilesCSVFile)) {
+				return fmt.Errorf("parent directory for specified related files CSV file to create does not exist")
+			}
+		}
+
+		if c.SimilarFilesScore < 1 || c.SimilarFilesScore > fuzzyhash.MaxScore {
+			flagset.Usage()
+			return fmt.Errorf(
+				"similar files score must be between 1 and %d",
+				fuzzyhash.MaxScore,
+			)
+		}
+
+		if c.SimilarFilesCSVFile != "" {
+			if !c.SimilarFiles {
+				flagset.Usage()
+				return fmt.Errorf("similar files CSV file specified without enabling similar files detection")
+			}
+			if !paths.PathExists(filepath.Dir(c.SimilarFilesCSVFile)) {
+				return fmt.Errorf("parent directory for specified similar files CSV file to create does not exist")
+			}
+		}
+
+		if c.CompareBytes && c.VerifyBytes {
+			flagset.Usage()
+			return fmt.Errorf("byte verification is redundant when comparing files byte for byte")
+		}
+
+		if c.CompareBytes && c.RetryChanged {
+			flagset.Usage()
+			return fmt.Errorf("files are not checked for changes when comparing files byte for byte")
+		}
+
+		if c.FastHash < 0 {
+			flagset.Usage()
+			return fmt.Errorf("0 MiB is the minimum fast hash size (0 disables partial checksums)")
+		}
+
+		if c.FastHash > 0 {
+			if c.FastHashThreshold < c.FastHash {
+				flagset.Usage()
+				return fmt.Errorf("fast hash threshold (%d MiB) must not be less than the fast hash size (%d MiB)", c.FastHashThreshold, c.FastHash)
+			}
+
+			if c.CompareBytes {
+				flagset.Usage()
+				return fmt.Errorf("partial checksums are not used when comparing files byte for byte")
+			}
+		}
+
+		if c.ExtraHash != "" {
+			if !c.ExtraHash.IsValid() {
+				flagset.Usage()
+				return fmt.Errorf(
+					"invalid extra hash algorithm %q; supported values: %v",
+					c.ExtraHash,
+					checksums.Algorithms,
+				)
+			}
+
+			if c.CompareBytes {
+				flagset.Usage()
+				return fmt.Errorf("extra hashes are not generated when comparing files byte for byte")
+			}
+		}
+
+		if err := c.validateScript(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+		if err := c.validateRemoveList(); err != nil {
+			return err
+		}
+
+		if c.PostURL != "" {
+			if err := c.PublishOptions().Validate(); err != nil {
+				flagset.Usage()
+				return err
+			}
+		}
+
+		if err := c.NotifyOptions().Validate(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+		if c.NullDelimited && c.PathsFrom == "" && c.RemoveListFile == "" {
+			flagset.Usage()
+			return fmt.Errorf("NUL-delimited mode requires a paths file or remove list file (see paths-from and remove-list flags)")
+		}
+
+		if c.BackupDirectory != "" {
+			if c.ScriptFormat == "" {
+				flagset.Usage()
+				return fmt.Errorf("backup directory requires a generated script (see emit-script flag)")
+			}
+
+			if s3.IsURL(c.BackupDirectory) {
+				flagset.Usage()
+				return fmt.Errorf("S3 backup locations are not supported by generated scripts")
+			}
+		}
+
+		if c.SnapshotFile != "" && !paths.PathExists(filepath.Dir(c.SnapshotFile)) {
+			return fmt.Errorf("parent directory for specified snapshot file to create does not exist")
+		}
+
+		if c.BaselineFile != "" && !paths.PathExists(c.BaselineFile) {
+			return fmt.Errorf("specified baseline snapshot file %q does not exist", c.BaselineFile)
+		}
+
+		if c.PreviousCSVFile != "" {
+			if !paths.PathExists(c.PreviousCSVFile) {
+				return fmt.Errorf("specified previous CSV file %q does not exist", c.PreviousCSVFile)
+			}
+
+			if c.CompareBytes {
+				flagset.Usage()
+				return fmt.Errorf("checksums from a previous CSV file are not used when comparing files byte for byte")
+			}
+		}
+
+		if c.Walkers < 1 {
+			flagset.Usage()
+			return fmt.Errorf("number of walkers must be 1 or greater")
+		}
+
+		// Optional flag, optional file generation
+		if c.NDJSONFile != "" {
+			if !paths.PathExists(filepath.Dir(c.NDJSONFile)) {
+				return fmt.Errorf("parent directory for specified NDJSON file to create does not exist")
+			}
+		}
+
+		// Streamed sets are not retained, so options which require all
+		// confirmed sets (or a second pass over evaluated files) are not
+		// supported.
+		if c.Stream {
+			incompatible := []struct {
+				flag string
+				set  bool
+			}{
+				{"console", c.ConsoleReport},
+				{"compare-bytes", c.CompareBytes},
+				{"excelfile", c.ExcelFile != ""},
+				{"dir-summary", c.DirSummaryCSVFile != ""},
+				{"near-duplicates", c.NearDuplicates},
+				{"exif-matches", c.EXIFMatches},
+				{"related-files", c.RelatedFiles},
+				{"similar-files", c.SimilarFiles},
+				{"content-only", c.ContentOnly},
+				{"audio-content-only", c.AudioContentOnly},
+				{"fast-hash", c.FastHash > 0},
+				{"set-order parent-size", c.FileOrder == matches.FileOrderParentSize},
+				{"retry-changed", c.RetryChanged},
+			}
+
+			for _, option := range incompatible {
+				if option.set {
+					flagset.Usage()
+					return fmt.Errorf("the %q option is not supported in stream mode", option.flag)
+				}
+			}
+		}
+
+		// Optional flag, optional file generation
+		if c.DirSummaryCSVFile != "" {
+			if !paths.PathExists(filepath.Dir(c.DirSummaryCSVFile)) {
+				return fmt.Errorf("parent directory for specified directory summary CSV file to create does not exist")
+			}
+		}
+
+		// Optional flag, optional file generation
+		if c.ExtSummaryCSVFile != "" {
+			if !paths.PathExists(filepath.Dir(c.ExtSummaryCSVFile)) {
+				return fmt.Errorf("parent directory for specified extension summary CSV file to create does not exist")
+			}
+		}
+
+		// Optional flag, optional file generation
+		if c.SmallFilesCSVFile != "" {
+			if !paths.PathExists(filepath.Dir(c.SmallFilesCSVFile)) {
+				return fmt.Errorf("parent directory for specified small files CSV file to create does not exist")
+			}
+		}
+
+		// Optional flag, optional file generation
+		if c.SummaryFile != "" {
+			if !paths.PathExists(filepath.Dir(c.SummaryFile)) {
+				return fmt.Errorf("parent directory for specified summary file to create does not exist")
+			}
+		}
+
+		// Optional flag, optional file generation
+		if c.ErrorsCSVFile != "" {
+			if !paths.PathExists(filepath.Dir(c.ErrorsCSVFile)) {
+				return fmt.Errorf("parent directory for specified errors CSV file to create does not exist")
+			}
+		}
+
+	case WatchSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", WatchSubcommand)
+
+		if c.Paths == nil {
+			flagset.Usage()
+			return fmt.Errorf("one or more paths not provided")
+		}
+
+		for _, path := range c.Paths {
+			if s3.IsURL(path) {
+				return fmt.Errorf("S3 paths are not supported by the %s subcommand: %q", WatchSubcommand, path)
+			}
+		}
+
+		if c.FileSizeThreshold < 0 {
+			flagset.Usage()
+			return fmt.Errorf("0 bytes is the minimum size for evaluated files")
+		}
+
+		if c.FileDuplicatesThreshold < 2 {
+			flagset.Usage()
+			return fmt.Errorf("2 is the minimum duplicates number for evaluated files")
+		}
+
+		if c.WatchInterval < watch.MinInterval {
+			flagset.Usage()
+			return fmt.Errorf("%s is the minimum interval between checks", watch.MinInterval)
+		}
+
+	case ServeSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", ServeSubcommand)
+
+		if strings.TrimSpace(c.ListenAddress) == "" {
+			flagset.Usage()
+			return fmt.Errorf("address to listen on not specified")
+		}
+
+		listenHost, _, err := net.SplitHostPort(c.ListenAddress)
+		if err != nil {
+			flagset.Usage()
+			return fmt.Errorf("invalid address to listen on %q: %w", c.ListenAddress, err)
+		}
+
+		// Without a token, anyone able to reach the API could scan paths
+		// and remove files.
+		if c.APIToken == "" && !server.IsLoopback(listenHost) {
+			return fmt.Errorf(
+				"an API token is required to listen on %q; specify a token (or the %s environment variable) or listen on localhost",
+				c.ListenAddress,
+				APITokenEnvVar,
+			)
+		}
+
+		if c.BackupDirectory != "" && !paths.PathExists(c.BackupDirectory) {
+			return fmt.Errorf("backup directory %q specified, but does not exist", c.BackupDirectory)
+		}
+
+	case PurgeSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", PurgeSubcommand)
+
+		if strings.TrimSpace(c.BackupDirectory) == "" {
+			flagset.Usage()
+			return fmt.Errorf("backup directory to purge not specified")
+		}
+
+		if s3.IsURL(c.BackupDirectory) {
+			return fmt.Errorf("S3 backup locations are not supported by the %s subcommand: %q", PurgeSubcommand, c.BackupDirectory)
+		}
+
+		if c.OlderThan <= 0 {
+			flagset.Usage()
+			return fmt.Errorf("retention period not specified")
+		}
+
+		if !paths.PathExists(c.BackupDirectory) {
+			return fmt.Errorf("backup directory %q specified, but does not exist", c.BackupDirectory)
+		}
+
+	case VerifyBackupSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", VerifyBackupSubcommand)
+
+		if strings.TrimSpace(c.BackupDirectory) == "" {
+			flagset.Usage()
+			return fmt.Errorf("backup directory to verify not specified")
+		}
+
+		if s3.IsURL(c.BackupDirectory) {
+			return fmt.Errorf("S3 backup locations are not supported by the %s subcommand: %q", VerifyBackupSubcommand, c.BackupDirectory)
+		}
+
+		if !paths.PathExists(c.BackupDirectory) {
+			return fmt.Errorf("backup directory %q specified, but does not exist", c.BackupDirectory)
+		}
+
+		if c.AuditLogFile != "" && !paths.PathExists(c.AuditLogFile) {
+			return fmt.Errorf("audit log %q specified, but does not exist", c.AuditLogFile)
+		}
+
+	case UndoSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", UndoSubcommand)
+
+		if strings.TrimSpace(c.JournalFile) == "" {
+			flagset.Usage()
+			return fmt.Errorf("journal of the prune run to undo not specified")
+		}
+
+		if !paths.PathExists(c.JournalFile) {
+			return fmt.Errorf("journal %q specified, but does not exist", c.JournalFile)
+		}
+
+	case StatsSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", StatsSubcommand)
+
+		if c.InputCSVFiles == nil {
+			flagset.Usage()
+			return fmt.Errorf("required input CSV file to summarize not specified")
+		}
+
+		for _, file := range c.InputCSVFiles {
+			if strings.TrimSpace(file) == "" {
+				flagset.Usage()
+				return fmt.Errorf("empty input CSV file specified")
+			}
+		}
+
+		for _, file := range []string{c.DirSummaryCSVFile, c.ExtSummaryCSVFile, c.SummaryFile} {
+			if file != "" && !paths.PathExists(filepath.Dir(file)) {
+				return fmt.Errorf("parent directory for specified file %q to create does not exist", file)
+			}
+		}
+
+		if (len(c.PreferPaths) > 0 || len(c.PreferMatch) > 0 || len(c.RemoveMatch) > 0) && !c.WhatIf {
+			flagset.Usage()
+			return fmt.Errorf("keep preferences specified without enabling what-if analysis")
+		}
+
+		if err := c.validateKeepPreferences(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+	case MergeSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", MergeSubcommand)
+
+		if c.InputCSVFiles == nil {
+			flagset.Usage()
+			return fmt.Errorf("required input CSV files to merge not specified")
+		}
+
+		for _, file := range c.InputCSVFiles {
+			if strings.TrimSpace(file) == "" {
+				flagset.Usage()
+				return fmt.Errorf("empty input CSV file specified")
+			}
+		}
+
+		switch {
+		case c.OutputCSVFile == "":
+			flagset.Usage()
+			return fmt.Errorf("missing fully-qualified path to CSV file to create")
+		case !paths.PathExists(filepath.Dir(c.OutputCSVFile)):
+			return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+		}
+
+		if c.SummaryFile != "" && !paths.PathExists(filepath.Dir(c.SummaryFile)) {
+			return fmt.Errorf("parent directory for specified summary file to create does not exist")
+		}
+
+		if !c.SortOrder.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid sort order %q; supported values: %v",
+				c.SortOrder,
+				matches.SortOrders,
+			)
+		}
+
+		if !c.FileOrder.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid set order %q; supported values: %v",
+				c.FileOrder,
+				matches.FileOrders,
+			)
+		}
+
+		if !c.KeepPolicy.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid keep policy %q; supported values: %v",
+				c.KeepPolicy,
+				matches.KeepPolicies,
+			)
+		}
+
+		if err := c.validateKeepPreferences(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+	case ExploreSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", ExploreSubcommand)
+
+		switch {
+		case c.InputCSVFiles == nil && c.Paths == nil:
+			flagset.Usage()
+			return fmt.Errorf("either input CSV files or paths to scan must be specified")
+		case c.InputCSVFiles != nil && c.Paths != nil:
+			flagset.Usage()
+			return fmt.Errorf("input CSV files and paths to scan cannot be combined")
+		}
+
+		for _, file := range c.InputCSVFiles {
+			if strings.TrimSpace(file) == "" {
+				flagset.Usage()
+				return fmt.Errorf("empty input CSV file specified")
+			}
+		}
+
+		for _, path := range c.Paths {
+			if s3.IsURL(path) {
+				return fmt.Errorf("S3 paths are not supported by the %s subcommand: %q", ExploreSubcommand, path)
+			}
+		}
+
+		if c.FileSizeThreshold < 0 {
+			flagset.Usage()
+			return fmt.Errorf("0 bytes is the minimum size for evaluated files")
+		}
+
+		if c.FileDuplicatesThreshold < 2 {
+			flagset.Usage()
+			return fmt.Errorf("2 is the minimum duplicates number for evaluated files")
+		}
+
+		if !c.KeepPolicy.IsValid() {
+			flagset.Usage()
+			return fmt.Errorf(
+				"invalid keep policy %q; supported values: %v",
+				c.KeepPolicy,
+				matches.KeepPolicies,
+			)
+		}
+
+		if err := c.validateKeepPreferences(); err != nil {
+			flagset.Usage()
+			return err
+		}
+
+	case SelfTestSubcommand:
+
+		// DEBUG
+		fmt.Printf("DEBUG: validating subcommand '%s'\n", SelfTestSubcommand)
+
+		if flagset.NArg() > 0 {
+			flagset.Usage()
+			return fmt.Errorf("unexpected arguments: %v", flagset.Args())
+		}
+
+	case CompletionSubcommand:
+
+		if c.CompletionShell == "" {
+			flagset.Usage()
+			return fmt.Errorf(
+				"shell to generate completion script for not specified; supported shells: %s",
+				strings.Join(completion.SupportedShells(), ", "),
+			)
+		}
+
+		if !completion.IsSupportedShell(c.CompletionShell) {
+			flagset.Usage()
+			return fmt.Errorf(
+				"unsupported shell %q; supported shells: %s",
+				c.CompletionShell,
+				strings.Join(completion.SupportedShells(), ", "),
+			)
+		}
+
 	default:
 		// NOTE: This default case statement should not be reached due to
 		// NewConfig() applying the same set of subcommand checks, but
@@ -387,3 +1913,226 @@ func (c Config) Validate(flagset *flag.FlagSet) error {
 	return nil
 
 }
+
+// validateScript verifies the options used to generate a script removing
+// duplicate files (shared by the report and prune subcommands).
+func (c Config) validateScript() error {
+
+	if c.ScriptFormat == "" {
+		if c.ScriptFile != "" {
+			return fmt.Errorf("script file requires a script format (see emit-script flag)")
+		}
+		return nil
+	}
+
+	if !c.ScriptFormat.IsValid() {
+		return fmt.Errorf(
+			"invalid script format %q; supported values: %v",
+			c.ScriptFormat,
+			script.Formats,
+		)
+	}
+
+	if strings.TrimSpace(c.ScriptFile) == "" {
+		return fmt.Errorf("required script file to generate not specified (see script-file flag)")
+	}
+
+	if !paths.PathExists(filepath.Dir(filepath.Clean(c.ScriptFile))) {
+		return fmt.Errorf("parent directory for specified script file to create does not exist")
+	}
+
+	return nil
+}
+
+// PublishOptions returns the settings used by the report subcommand to post
+// results.
+func (c Config) PublishOptions() publish.Options {
+	return publish.Options{
+		URL:     c.PostURL,
+		Headers: c.PostHeaders,
+		Token:   c.PostToken,
+	}
+}
+
+// NotifyOptions returns the settings used to send notifications once the
+// report or prune subcommand finishes.
+func (c Config) NotifyOptions() notify.Options {
+	return notify.Options{
+		WebhookURL:   c.NotifyWebhook,
+		SMTPServer:   c.NotifySMTPServer,
+		SMTPUsername: c.NotifySMTPUsername,
+		SMTPPassword: c.NotifySMTPPassword,
+		EmailFrom:    c.NotifyEmailFrom,
+		EmailTo:      c.NotifyEmailTo,
+	}
+}
+
+// KeepRule returns the rule used to suggest which file from each duplicate
+// file set to keep. Local preferred paths are made absolute and normalized
+// so that they may be compared with the fully-qualified paths recorded for
+// files. Patterns are assumed to have been verified by Validate.
+func (c Config) KeepRule() matches.KeepRule {
+
+	preferPaths := make([]string, 0, len(c.PreferPaths))
+	for _, preferPath := range c.PreferPaths {
+		if !s3.IsURL(preferPath) {
+			if absPath, err := filepath.Abs(preferPath); err == nil {
+				preferPath = absPath
+			}
+		}
+		preferPaths = append(preferPaths, paths.Normalize(preferPath))
+	}
+
+	compile := func(patterns []string) []*regexp.Regexp {
+		compiled := make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			compiled = append(compiled, regexp.MustCompile(pattern))
+		}
+		return compiled
+	}
+
+	return matches.KeepRule{
+		Policy:      c.KeepPolicy,
+		PreferPaths: preferPaths,
+		PreferMatch: compile(c.PreferMatch),
+		RemoveMatch: compile(c.RemoveMatch),
+	}
+}
+
+// validateKeepPreferences verifies that no empty preferred paths were
+// specified and that all preference and removal patterns are valid regular
+// expressions.
+func (c Config) validateKeepPreferences() error {
+
+	for _, preferPath := range c.PreferPaths {
+		if strings.TrimSpace(preferPath) == "" {
+			return fmt.Errorf("empty preferred path specified")
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, c.PreferMatch...), c.RemoveMatch...) {
+		if pattern == "" {
+			return fmt.Errorf("empty preference pattern specified")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid preference pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// canonicalPath returns the absolute path, with symbolic links resolved where
+// possible, used to compare paths to evaluate. S3 URLs are compared as-is
+// apart from any trailing slash.
+func canonicalPath(path string) string {
+
+	if s3.IsURL(path) {
+		return strings.TrimRight(path, "/")
+	}
+
+	canonical, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(canonical); err == nil {
+		canonical = resolved
+	}
+
+	return canonical
+}
+
+// collapsePaths returns the paths to evaluate with duplicate entries (by
+// canonical path) removed along with, if subdirectories are evaluated, local
+// paths nested within another listed path. The first entry for each path is
+// kept as specified. A message describing each removed entry is also
+// returned.
+func collapsePaths(list multiValueFlag, recursive bool) (multiValueFlag, []string) {
+
+	canonical := make([]string, len(list))
+	for i, path := range list {
+		canonical[i] = canonicalPath(path)
+	}
+
+	nested := func(path string, dir string) bool {
+		if s3.IsURL(path) || s3.IsURL(dir) || path == dir {
+			return false
+		}
+		if !strings.HasSuffix(dir, string(filepath.Separator)) {
+			dir += string(filepath.Separator)
+		}
+		return strings.HasPrefix(path, dir)
+	}
+
+	collapsed := make(multiValueFlag, 0, len(list))
+	var skipped []string
+
+	for i, path := range list {
+
+		duplicate := false
+		for j := 0; j < i; j++ {
+			if canonical[j] == canonical[i] {
+				skipped = append(skipped, fmt.Sprintf(
+					"Skipping path %q: same as path %q",
+					path,
+					list[j],
+				))
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		if recursive {
+			for j := range list {
+				if nested(canonical[i], canonical[j]) {
+					skipped = append(skipped, fmt.Sprintf(
+						"Skipping path %q: already evaluated as part of path %q",
+						path,
+						list[j],
+					))
+					duplicate = true
+					break
+				}
+			}
+			if duplicate {
+				continue
+			}
+		}
+
+		collapsed = append(collapsed, path)
+	}
+
+	return collapsed, skipped
+}
+
+// validateRemoveList verifies the options used to generate a list of files
+// to remove (shared by the report and prune subcommands).
+func (c Config) validateRemoveList() error {
+
+	if c.RemoveListFile != "" && !paths.PathExists(filepath.Dir(filepath.Clean(c.RemoveListFile))) {
+		return fmt.Errorf("parent directory for specified remove list file to create does not exist")
+	}
+
+	return nil
+}
+
+// IgnoredErrorCategories returns the error categories listed via the
+// ignore-errors-for flag, which may be repeated or list several
+// comma-separated categories.
+func (c Config) IgnoredErrorCategories() []errreport.Category {
+
+	var categories []errreport.Category
+	for _, value := range c.IgnoreErrorsFor {
+		for _, category := range strings.Split(value, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, errreport.Category(category))
+			}
+		}
+	}
+
+	return categories
+}
@@ -0,0 +1,386 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package config
+
+import (
+	"flag"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// newReportFlagSet returns a flagset for the report subcommand with flag
+// values bound to the provided Config.
+func newReportFlagSet(config *Config) *flag.FlagSet {
+
+	reportCmd := flag.NewFlagSet(ReportSubcommand, flag.ContinueOnError)
+	reportCmd.Var(&config.Paths, "path", "Path (local directory or s3://bucket/prefix URL) to process. This flag may be repeated for each additional path to evaluate.")
+	reportCmd.StringVar(&config.PathsFrom, "paths-from", "", "The fully-qualified path to a file listing additional paths to process, one per line (e.g., the output of find or locate). Specify - to read paths from standard input. Blank lines are skipped.")
+	reportCmd.StringVar(&config.RemoveListFile, "remove-list", "", "The (optional) fully-qualified path to a file listing the files not suggested for keeping (see keep-policy flag) from each duplicate file set, one per line (or NUL-terminated, see null flag), for use with tools such as xargs.")
+	reportCmd.BoolVar(&config.NullDelimited, "null", false, "Read paths from the paths-from file and write paths to the remove-list file NUL-delimited (as with find -print0 and xargs -0) instead of one per line, so that paths containing newlines are handled intact.")
+	reportCmd.Var(newByteSizeValue(1, &config.FileSizeThreshold), "size", "File size limit for evaluation, in bytes or with a unit (e.g., 500KB, 10MiB, 4GB). Files smaller than this will be skipped.")
+	reportCmd.Var(newByteSizeValue(0, &config.MaxFileSize), "max-size", "Maximum file size for evaluation, in bytes or with a unit (e.g., 500KB, 10MiB, 4GB). Files larger than this will be skipped. The default of 0 applies no limit.")
+	reportCmd.Var(newByteSizeValue(0, &config.FailIfWastedGT), "fail-if-wasted-gt", "Wasted space budget, in bytes or with a unit (e.g., 500MB, 50GB). Exit with a distinct exit code (4) if duplicate files waste more space than this, for use by scheduled runs and monitoring. The default of 0 applies no budget.")
+	reportCmd.IntVar(&config.FailIfDupesGT, "fail-if-dupes-gt", 0, "Duplicate files budget. Exit with a distinct exit code (4) if more duplicate files (files other than the one kept from each set) than this are found, for use by scheduled runs and monitoring. The default of 0 applies no budget.")
+	reportCmd.Func("older-than", "Only evaluate files last modified longer ago than this (e.g., 180d, 2w, 6mo, 1y, 36h). Months and years are approximated as 30 and 365 days.", func(value string) error {
+		olderThan, err := units.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		config.OlderThan = olderThan
+		return nil
+	})
+	reportCmd.Func("newer-than", "Only evaluate files last modified more recently than this (e.g., 2w, 30d, 6mo, 36h). Months and years are approximated as 30 and 365 days.", func(value string) error {
+		newerThan, err := units.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		config.NewerThan = newerThan
+		return nil
+	})
+	reportCmd.IntVar(&config.FileDuplicatesThreshold, "duplicates", 2, "Number of files of the same file size needed before duplicate validation logic is applied.")
+	reportCmd.BoolVar(&config.RecursiveSearch, "recurse", false, "Perform recursive search into subdirectories per provided path.")
+	reportCmd.BoolVar(&config.RelativePaths, "relative-paths", false, "Record the directory column of CSV and Excel output relative to the evaluated path instead of fully-qualified, so that reports remain usable when the same tree is mounted at a different location. Requires a single path. See the root flag of the prune subcommand.")
+	reportCmd.BoolVar(&config.NoDefaultExcludes, "no-default-excludes", false, "Evaluate the content of well-known junk directories which are skipped by default when recursively evaluating paths: .git, .svn, node_modules, @eaDir, .Trash-* and System Volume Information.")
+	reportCmd.IntVar(&config.Walkers, "walkers", 1, "Number of directories read concurrently during a recursive search of local paths. Values greater than 1 can speed up crawling network shares containing many small files.")
+	reportCmd.BoolVar(&config.NoProgress, "no-progress", false, "Do not report progress while generating checksums. By default the percent complete, throughput (MB/s) and estimated time remaining are redrawn in place when standard error is a terminal and logged every 15 seconds otherwise.")
+	reportCmd.BoolVar(&config.ConsoleReport, "console", false, "Dump (approximate) CSV file equivalent to console.")
+	reportCmd.StringVar((*string)(&config.Color), "color", string(matches.ColorAuto), "Color console output: files suggested for removal in red, files suggested for keeping in green and set separators in cyan. Supported values: auto (only if standard output is a terminal and the NO_COLOR environment variable is not set), always or never.")
+	reportCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible. This option does not affect handling of fatal errors such as failure to generate output report files.")
+	reportCmd.Var(&config.IgnoreErrorsFor, "ignore-errors-for", "Ignore only errors of the specified category (implies the ignore-errors flag), so that other errors remain fatal: permission-denied, vanished-file, read-error or checksum-mismatch. Several categories may be separated by commas or this flag may be repeated.")
+	reportCmd.StringVar(&config.OutputCSVFile, "csvfile", "", "The (required) fully-qualified path to a CSV file that this application should generate.")
+	reportCmd.Var(newByteSizeValue(0, &config.CSVMaxSize), "csv-max-size", "Split the CSV file into numbered parts (e.g., report-001.csv, report-002.csv) once a part reaches this size, in bytes or with a unit (e.g., 100MB). Duplicate file sets are never split across parts. The default of 0 applies no limit.")
+	reportCmd.StringVar(&config.ExcelFile, "excelfile", "", "The (optional) fully-qualified path to an Excel file that this application should generate.")
+	reportCmd.BoolVar(&config.NearDuplicates, "near-duplicates", false, "Compare JPEG, PNG and GIF images using perceptual hashes to find visually identical images whose file content differs (e.g., re-encoded or resized). These are reported separately from confirmed duplicates.")
+	reportCmd.IntVar(&config.NearDuplicatesDistance, "near-duplicates-distance", 4, "Maximum number of bits (0-16) by which perceptual hashes of visually identical images may differ. Larger values group images which are merely similar.")
+	reportCmd.StringVar(&config.NearDuplicatesCSVFile, "near-duplicates-csvfile", "", "The (optional) fully-qualified path to a CSV file listing near duplicate image sets that this application should generate.")
+	reportCmd.BoolVar(&config.ContentOnly, "content-only", false, "Compare JPEG and PNG files using checksums of their image content only, ignoring metadata such as EXIF and XMP. Copies of a photo tagged by different applications are reported as content-equal duplicates.")
+	reportCmd.BoolVar(&config.AudioContentOnly, "audio-content-only", false, "Compare MP3 and FLAC files using checksums of their audio stream only, ignoring ID3, APE and Vorbis comment tags. Tags which differ between content-equal duplicates are listed in the tag_differences column.")
+	reportCmd.BoolVar(&config.EXIF, "exif", false, "Include EXIF capture time, camera make and model and image dimensions columns for JPEG and PNG files in CSV and Excel output.")
+	reportCmd.BoolVar(&config.EXIFMatches, "exif-matches", false, "Report JPEG and PNG images whose file content differs but whose EXIF capture time and dimensions match (likely re-exports). These are reported separately from confirmed duplicates.")
+	reportCmd.StringVar(&config.EXIFMatchesCSVFile, "exif-matches-csvfile", "", "The (optional) fully-qualified path to a CSV file listing EXIF match sets that this application should generate.")
+	reportCmd.BoolVar(&config.RelatedFiles, "related-files", false, "Report files with near-identical names but differing content (e.g., \"IMG_1234.jpg\" and \"IMG_1234 (1).jpg\" or \"IMG_1234-edited.jpg\"). These are reported separately from confirmed duplicates.")
+	reportCmd.StringVar(&config.RelatedFilesCSVFile, "related-files-csvfile", "", "The (optional) fully-qualified path to a CSV file listing related file sets that this application should generate.")
+	reportCmd.BoolVar(&config.SimilarFiles, "similar-files", false, "Compare documents and archives using fuzzy hashes (ssdeep) to find files which are highly similar but not identical (e.g., successive edits of the same document). These are reported separately from confirmed duplicates.")
+	reportCmd.IntVar(&config.SimilarFilesScore, "similar-files-score", 80, "Minimum similarity score (1-100) of the fuzzy hashes of files reported as highly similar. Smaller values group files which are merely related.")
+	reportCmd.StringVar(&config.SimilarFilesCSVFile, "similar-files-csvfile", "", "The (optional) fully-qualified path to a CSV file listing similar file sets that this application should generate.")
+	reportCmd.StringVar((*string)(&config.SortOrder), "sort", string(matches.SortByWastedSpace), "Order in which duplicate file sets are listed in console, CSV and Excel output: wasted-space, size, path or count.")
+	reportCmd.StringVar((*string)(&config.FileOrder), "set-order", string(matches.FileOrderPath), "Order in which files are listed within each duplicate file set in console, CSV and Excel output: path, mtime (oldest first) or parent-size (directories holding the most duplicate data first). Ties are broken by path.")
+	reportCmd.StringVar((*string)(&config.KeepPolicy), "keep-policy", string(matches.KeepOldest), "How the file suggested as the original to keep (keep column) is chosen from each duplicate file set: oldest, newest or shortest-path. Ties are broken by lexical path order.")
+	reportCmd.Var(&config.PreferPaths, "prefer-path", "Directory whose files are suggested as the original to keep (keep column) over files elsewhere, such as a curated archive. This flag may be repeated in priority order; the keep-policy flag chooses between equally preferred files.")
+	reportCmd.Var(&config.PreferMatch, "prefer-match", "Regular expression (e.g., /Archive/) matched against the full path of files which are suggested as the original to keep (keep column) over other files. Takes precedence over the prefer-path flag. This flag may be repeated.")
+	reportCmd.Var(&config.RemoveMatch, "remove-match", "Regular expression (e.g., /Downloads/) matched against the full path of files which are suggested for removal (keep column) over other files. Takes precedence over the prefer-match and prefer-path flags. This flag may be repeated.")
+	reportCmd.BoolVar(&config.CompareBytes, "compare-bytes", false, "Confirm duplicates by comparing the content of files of the same size byte for byte instead of generating checksums. Each file in a small group of identically sized files is read at most once.")
+	reportCmd.BoolVar(&config.VerifyBytes, "verify-bytes", false, "Compare the content of files with matching checksums byte for byte before reporting them as duplicates, guarding against hash collisions and read errors. Files whose content differs are excluded from duplicate file sets.")
+	reportCmd.BoolVar(&config.RetryChanged, "retry-changed", false, "Evaluate files skipped because their size or modification time changed between being found and being read (e.g., files still being downloaded) again once all other files have been evaluated. Files which changed again remain skipped.")
+	reportCmd.Int64Var(&config.FastHash, "fast-hash", 0, "Fast but approximate mode: generate checksums from only the first number of MiB of files larger than the fast-hash-threshold size instead of reading the entire file. Sets found this way are reported as unverified. The default of 0 disables partial checksums.")
+	reportCmd.StringVar((*string)(&config.ExtraHash), "extra-hash", "", "Additional hash algorithm used to generate a second hash of each duplicate file (in the same read pass as the SHA256 checksum), recorded in the extra_checksum report column: md5, sha1 or sha512. Disabled by default.")
+	reportCmd.StringVar(&config.PreviousCSVFile, "previous-csv", "", "The (optional) fully-qualified path to a CSV file generated by an earlier run of this subcommand. Checksums recorded for files which are unchanged since (same size and modification time) are reused instead of reading the files again.")
+	reportCmd.StringVar(&config.SnapshotFile, "snapshot", "", "The (optional) fully-qualified path to a snapshot manifest (CSV file) listing the path, size and modification time of every evaluated file that this application should generate, for use as a baseline by later scans.")
+	reportCmd.StringVar(&config.BaselineFile, "baseline", "", "The (optional) fully-qualified path to a snapshot manifest written by an earlier scan (see snapshot flag). Only duplicate file sets involving files added or modified since the snapshot are reported.")
+	reportCmd.Int64Var(&config.FastHashThreshold, "fast-hash-threshold", 1024, "File size in MiB above which files are evaluated using partial checksums when the fast-hash flag is specified.")
+	reportCmd.BoolVar(&config.AllocatedSize, "allocated-size", false, "Record the space allocated on disk to duplicate files in an allocated_size_in_bytes column and use it instead of the file size to calculate wasted space, so that sparse files and files on compressed filesystems are not overstated.")
+	reportCmd.StringVar(&config.NDJSONFile, "ndjson-file", "", "The (optional) fully-qualified path to a file listing duplicate file sets as newline-delimited JSON (one JSON object per set) that this application should generate.")
+	reportCmd.BoolVar(&config.Stream, "stream", false, "Write each duplicate file set to the CSV (and NDJSON) file as soon as it is confirmed instead of after all files have been evaluated. This reduces memory use for large scans; sets are listed largest file size first.")
+	reportCmd.StringVar(&config.DirSummaryCSVFile, "dir-summary", "", "The (optional) fully-qualified path to a CSV file listing the number of duplicate files and wasted space per directory that this application should generate.")
+	reportCmd.StringVar(&config.ExtSummaryCSVFile, "ext-summary", "", "The (optional) fully-qualified path to a CSV file listing the number of duplicate files and wasted space per file extension that this application should generate.")
+	reportCmd.StringVar(&config.SmallFilesCSVFile, "small-files", "", "The (optional) fully-qualified path to a CSV file listing zero-byte files and other files smaller than the size limit (see size flag) that this application should generate. Files flagged for removal in this file can be removed using the prune subcommand.")
+	reportCmd.IntVar(&config.ReadBufferSize, "read-buffer", checksums.DefaultReadBufferSize, "Size (in bytes) of the buffer used to read file content while generating checksums. Larger buffers can improve throughput when reading large files from spinning disks.")
+	reportCmd.BoolVar(&config.DropCache, "drop-cache", false, "Advise the kernel to drop file content from the page cache once a checksum has been generated (Linux only), so that hashing large files does not evict content cached for other applications.")
+	reportCmd.StringVar((*string)(&config.Normalization), "normalization", string(paths.NormalizationNFC), "Unicode normalization form applied to paths so that paths differing only in normalization (e.g., between macOS and Linux) compare as equal: nfc, nfd or none.")
+	reportCmd.StringVar(&config.SummaryFile, "summary-file", "", "The (optional) fully-qualified path to a JSON file containing a summary of scan results that this application should generate.")
+	reportCmd.StringVar(&config.ErrorsCSVFile, "errors-csv", "", "The (optional) fully-qualified path to a CSV file listing the errors ignored (see ignore-errors flag) and files skipped during the run, with the path, operation and error class of each, that this application should generate.")
+	reportCmd.StringVar((*string)(&config.ScriptFormat), "emit-script", "", "Generate a script removing the files not suggested for keeping (see keep-policy flag) from each duplicate file set, for review and removal under a different account: sh or powershell. Requires the script-file flag.")
+	reportCmd.StringVar(&config.ScriptFile, "script-file", "", "The fully-qualified path to the removal script (see emit-script flag) that this application should generate.")
+	reportCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The directory path where the generated removal script (see emit-script flag) should copy files before removing them. The original path structure will be created starting with the specified path as the root.")
+	reportCmd.StringVar(&config.PostURL, "post-url", "", "The (optional) http or https URL that all duplicate file sets (along with a summary of scan results) should be posted to as JSON once the scan completes, for collection by a central inventory service.")
+	reportCmd.Var(&config.PostHeaders, "post-header", "Additional request header, in \"Name: value\" form (e.g., \"X-API-Key: secret\"), sent when posting results (see post-url flag). This flag may be repeated for each additional header.")
+	reportCmd.StringVar(&config.PostToken, "post-token", "", "The (optional) bearer token sent via the Authorization header when posting results (see post-url flag). If not specified, the "+PostTokenEnvVar+" environment variable is used.")
+	addNotifyFlags(reportCmd, config)
+
+	return reportCmd
+}
+
+// newPruneFlagSet returns a flagset for the prune subcommand with flag values
+// bound to the provided Config.
+func newPruneFlagSet(config *Config) *flag.FlagSet {
+
+	pruneCmd := flag.NewFlagSet(PruneSubcommand, flag.ContinueOnError)
+	pruneCmd.BoolVar(&config.DryRun, "dry-run", false, "Don't actually remove files. Echo what would have been done to stdout.")
+	pruneCmd.BoolVar(&config.BlankLineBetweenSets, "blank-line", false, "Add a blank line between sets of matching files in console and file output.")
+	pruneCmd.Var(&config.InputCSVFiles, "input-csvfile", "The fully-qualified path to a CSV file (or Excel workbook generated by the report subcommand) that this application should use for file removal decisions. Glob patterns (e.g., reports/*.csv) are expanded. This flag may be repeated for each additional CSV file; rows from all files are merged before validation and removal.")
+	pruneCmd.Var(&config.InputFdupesFiles, "input-fdupes", "The fully-qualified path to a file containing the output of fdupes or jdupes (one path per line, with a blank line between each group of duplicate files) that this application should use for file removal decisions. The first file listed in each group is kept and the others are removed. Glob patterns are expanded. This flag may be repeated and may be combined with the input-csvfile flag.")
+	pruneCmd.IntVar(&config.MaxDelete, "max-delete", 0, "The maximum number of files to remove in one run. No files are removed if more files are flagged for removal. The default of 0 applies no limit.")
+	pruneCmd.Int64Var(&config.MaxDeleteBytes, "max-delete-bytes", 0, "The maximum total size in bytes of files to remove in one run. No files are removed if the files flagged for removal are larger in total. The default of 0 applies no limit.")
+	pruneCmd.StringVar(&config.PlanFile, "plan-file", "", "The fully-qualified path to a CSV file listing the files that would be backed up and removed. Requires dry-run mode.")
+	pruneCmd.StringVar((*string)(&config.ScriptFormat), "emit-script", "", "Generate a script removing (and backing up, if a backup directory is specified) the files flagged for removal instead of removing them, for review and removal under a different account: sh or powershell. Requires the script-file flag.")
+	pruneCmd.StringVar(&config.ScriptFile, "script-file", "", "The fully-qualified path to the removal script (see emit-script flag) that this application should generate.")
+	pruneCmd.StringVar(&config.RemoveListFile, "remove-list", "", "The fully-qualified path to a file listing the files flagged for removal, one per line (or NUL-terminated, see null flag), instead of removing them, for use with tools such as xargs.")
+	pruneCmd.BoolVar(&config.NullDelimited, "null", false, "Write paths to the remove-list file NUL-terminated (for use with xargs -0) instead of one per line, so that paths containing newlines are handled intact.")
+	pruneCmd.BoolVar(&config.CreateBackupDir, "create-backup-dir", false, "Create the backup directory (or the directory containing the backup archive), including any missing parent directories, if it does not exist.")
+	pruneCmd.StringVar((*string)(&config.BackupCollision), "backup-collision", string(paths.CollisionFail), "How to handle backup copies which already exist in the backup directory: fail (abort), skip-if-identical (treat an existing file with identical content as the backup copy) or rename (add a numeric suffix).")
+	pruneCmd.BoolVar(&config.BackupHardlinks, "backup-hardlinks", false, "Store only one copy of files sharing a checksum in the backup directory, hard linking the rest. Files are copied if hard links are not supported by the backup directory filesystem.")
+	pruneCmd.StringVar(&config.BackupArchive, "backup-archive", "", "The fully-qualified path to a new tar.gz (or .tgz) or zip archive to copy files into before removing them, instead of a mirrored directory tree. The archive includes a manifest listing the original location of each file.")
+	pruneCmd.StringVar(&config.AuditLogFile, "audit-log", "", "The fully-qualified path to a CSV file recording each file backed up or removed (with timestamp, checksum, size, backup location and outcome). Entries are appended if the file already exists.")
+	pruneCmd.StringVar(&config.JournalFile, "journal", "", "The fully-qualified path to the journal recording each backup and removal before it is attempted and once it completes, allowing an interrupted run to be resumed (see resume flag). Defaults to the path of the first input file with a .journal suffix.")
+	pruneCmd.BoolVar(&config.Resume, "resume", false, "Resume the run recorded as interrupted in the journal (see journal flag), skipping files it already removed and reusing the backup copies it already verified.")
+	pruneCmd.StringVar(&config.ErrorsCSVFile, "errors-csv", "", "The (optional) fully-qualified path to a CSV file listing the errors ignored (see ignore-errors flag) and files skipped during the run, with the path, operation and error class of each, that this application should generate.")
+	pruneCmd.BoolVar(&config.FreeSpace, "free-space", false, "Report the free space of each filesystem containing files flagged for removal before and after removal.")
+	pruneCmd.BoolVar(&config.AssumeYes, "yes", false, "Remove files without prompting for confirmation. Without this flag, a summary of the files to remove is displayed and the removal must be confirmed by typing \"yes\".")
+	pruneCmd.BoolVar(&config.AllowRemoveAll, "allow-remove-all", false, "Allow removal of every file in a duplicate file set. By default, no files are removed if all files in any set are flagged for removal.")
+	pruneCmd.BoolVar(&config.ForceUnlock, "force-unlock", false, "Remove lock files left behind by a previous run (e.g., one which was killed) instead of refusing to run. Lock files prevent concurrent runs (e.g., from competing cron entries) from removing the same files or writing to the same backup directory; only use this flag once no other run is in progress.")
+	pruneCmd.BoolVar(&config.RemoveUnkept, "remove-unkept", false, "Use the keep column instead of the remove_file column for removal decisions: files marked false in the keep column are removed. Rows with an empty keep value are kept.")
+	pruneCmd.BoolVar(&config.SkipStale, "skip-stale", false, "Skip input rows for files which no longer exist or have changed since the report was generated, listing them in a stale rows summary, instead of treating them as errors.")
+	pruneCmd.IntVar(&config.Validators, "validators", 4, "Number of files whose checksums are verified concurrently before removal. Lower values (e.g., 1) may be faster for files stored on a single spinning disk.")
+	pruneCmd.BoolVar(&config.SkipVerify, "skip-verify", false, "Skip verifying the checksums of files listed in input CSV files before removal, checking only that each file exists with the recorded size. Intended for pruning static data immediately after generating the report; files modified since then (without a change in size) are not detected. Backup copies are still verified.")
+	pruneCmd.BoolVar(&config.NoProgress, "no-progress", false, "Do not report progress while verifying checksums, backing up files or removing files. By default the percent complete, throughput (MB/s) and estimated time remaining are redrawn in place when standard error is a terminal and logged every 15 seconds otherwise.")
+	pruneCmd.IntVar(&config.ReadBufferSize, "read-buffer", checksums.DefaultReadBufferSize, "Size (in bytes) of the buffer used to read file content while generating checksums. Larger buffers can improve throughput when reading large files from spinning disks.")
+	pruneCmd.BoolVar(&config.DropCache, "drop-cache", false, "Advise the kernel to drop file content from the page cache once a checksum has been generated (Linux only), so that hashing large files does not evict content cached for other applications.")
+	pruneCmd.StringVar((*string)(&config.Normalization), "normalization", string(paths.NormalizationNFC), "Unicode normalization form applied to paths. Input CSV file paths which do not exist as recorded are also tried in the alternate normalization form (e.g., files listed in a report generated on macOS): nfc, nfd or none.")
+	pruneCmd.StringVar(&config.Root, "root", "", "The directory that relative paths recorded in input CSV files (see the relative-paths flag of the report subcommand) are resolved against, such as the location the evaluated tree is mounted at on this system.")
+	pruneCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The writable directory path (or S3 URL) where files should be relocated instead of removing them. The original path structure will be created starting with the specified path as the root.")
+	pruneCmd.BoolVar(&config.ConsoleReport, "console", false, "Dump (approximate) CSV file equivalent to console.")
+	pruneCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible. This option does not affect handling of fatal errors such as failure to generate output report files.")
+	pruneCmd.Var(&config.IgnoreErrorsFor, "ignore-errors-for", "Ignore only errors of the specified category (implies the ignore-errors flag), so that other errors remain fatal: permission-denied, vanished-file, read-error or checksum-mismatch. Several categories may be separated by commas or this flag may be repeated.")
+	pruneCmd.BoolVar(&config.UseFirstRow, "use-first-row", false, "Attempt to use the first row of the input file. Normally this row is skipped since it is usually the header row and not duplicate file data.")
+	addNotifyFlags(pruneCmd, config)
+
+	return pruneCmd
+}
+
+// addNotifyFlags binds the flags used to send notifications once a
+// subcommand finishes (shared by the report and prune subcommands) to the
+// provided Config.
+func addNotifyFlags(flagSet *flag.FlagSet, config *Config) {
+	flagSet.StringVar(&config.NotifyWebhook, "notify-webhook", "", "The (optional) URL (e.g., a Slack, Discord or ntfy webhook) that a JSON summary of the results should be posted to once finished.")
+	flagSet.StringVar(&config.NotifySMTPServer, "notify-smtp-server", "", "The (optional) host:port of the mail server used to email a summary of the results once finished. STARTTLS is used if supported by the server.")
+	flagSet.StringVar(&config.NotifySMTPUsername, "notify-smtp-username", "", "The (optional) username used to authenticate to the mail server.")
+	flagSet.StringVar(&config.NotifySMTPPassword, "notify-smtp-password", "", "The password used to authenticate to the mail server. If not specified, the "+SMTPPasswordEnvVar+" environment variable is used.")
+	flagSet.StringVar(&config.NotifyEmailFrom, "notify-email-from", "", "The sender address of email notifications.")
+	flagSet.Var(&config.NotifyEmailTo, "notify-email-to", "The recipient address of email notifications. This flag may be repeated for each additional recipient.")
+}
+
+// newWatchFlagSet returns a flagset for the watch subcommand with flag values
+// bound to the provided Config.
+func newWatchFlagSet(config *Config) *flag.FlagSet {
+
+	watchCmd := flag.NewFlagSet(WatchSubcommand, flag.ContinueOnError)
+	watchCmd.Var(&config.Paths, "path", "Path to monitor. This flag may be repeated for each additional path to monitor.")
+	watchCmd.Var(newByteSizeValue(1, &config.FileSizeThreshold), "size", "File size limit for evaluation, in bytes or with a unit (e.g., 500KB, 10MiB, 4GB). Files smaller than this will be skipped.")
+	watchCmd.IntVar(&config.FileDuplicatesThreshold, "duplicates", 2, "Number of identical files needed before a new file is reported as a duplicate.")
+	watchCmd.BoolVar(&config.RecursiveSearch, "recurse", false, "Monitor subdirectories per provided path.")
+	watchCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible. This option does not affect handling of fatal errors such as failure to generate output report files.")
+	watchCmd.Var(&config.IgnoreErrorsFor, "ignore-errors-for", "Ignore only errors of the specified category (implies the ignore-errors flag), so that other errors remain fatal: permission-denied, vanished-file, read-error or checksum-mismatch. Several categories may be separated by commas or this flag may be repeated.")
+	watchCmd.IntVar(&config.ReadBufferSize, "read-buffer", checksums.DefaultReadBufferSize, "Size (in bytes) of the buffer used to read file content while generating checksums. Larger buffers can improve throughput when reading large files from spinning disks.")
+	watchCmd.BoolVar(&config.DropCache, "drop-cache", false, "Advise the kernel to drop file content from the page cache once a checksum has been generated (Linux only), so that hashing large files does not evict content cached for other applications.")
+	watchCmd.StringVar((*string)(&config.Normalization), "normalization", string(paths.NormalizationNFC), "Unicode normalization form applied to paths so that paths differing only in normalization (e.g., between macOS and Linux) compare as equal: nfc, nfd or none.")
+	watchCmd.DurationVar(&config.WatchInterval, "interval", 5*time.Second, "Delay between checks of changed files (e.g., 10s, 1m). New files are evaluated once unchanged between two checks. The monitored paths are rescanned in full at this interval if filesystem notifications are unavailable.")
+	watchCmd.StringVar(&config.MetricsListenAddress, "metrics-listen", "", "The (optional) host:port that Prometheus metrics (files scanned, bytes hashed, duplicate file sets, wasted space and errors) should be served on at the /metrics path. Metrics are not served if not specified.")
+
+	return watchCmd
+}
+
+// newServeFlagSet returns a flagset for the serve subcommand with flag values
+// bound to the provided Config.
+func newServeFlagSet(config *Config) *flag.FlagSet {
+
+	serveCmd := flag.NewFlagSet(ServeSubcommand, flag.ContinueOnError)
+	serveCmd.StringVar(&config.ListenAddress, "listen", "localhost:8080", "The host:port that the HTTP API should listen on.")
+	serveCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The writable directory path where files should be copied before they are removed via the API. The original path structure will be created starting with the specified path as the root.")
+	serveCmd.BoolVar(&config.DryRun, "dry-run", false, "Don't actually remove files flagged via the API. Echo what would have been done to stdout.")
+	serveCmd.StringVar((*string)(&config.Normalization), "normalization", string(paths.NormalizationNFC), "Unicode normalization form applied to paths so that paths differing only in normalization (e.g., between macOS and Linux) compare as equal: nfc, nfd or none.")
+	serveCmd.StringVar(&config.APIToken, "token", "", "The (optional) bearer token required for all API requests. If not specified, the "+APITokenEnvVar+" environment variable is used.")
+
+	return serveCmd
+}
+
+// newPurgeFlagSet returns a flagset for the purge subcommand with flag values
+// bound to the provided Config.
+func newPurgeFlagSet(config *Config) *flag.FlagSet {
+
+	purgeCmd := flag.NewFlagSet(PurgeSubcommand, flag.ContinueOnError)
+	purgeCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The (required) backup directory path previously used by the prune subcommand (or containing backup archives) to remove old backup copies from.")
+	purgeCmd.Func("older-than", "The (required) retention period for backup copies (e.g., 30d, 2w, 6mo, 36h). Files in the backup directory last modified longer ago than this are removed.", func(value string) error {
+		olderThan, err := units.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		config.OlderThan = olderThan
+		return nil
+	})
+	purgeCmd.BoolVar(&config.DryRun, "dry-run", false, "Don't actually remove files. Echo what would have been done to stdout.")
+	purgeCmd.BoolVar(&config.AssumeYes, "yes", false, "Remove files without prompting for confirmation. Without this flag, a summary of the files to remove is displayed and the removal must be confirmed by typing \"yes\".")
+	purgeCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible, such as failure to remove individual files.")
+
+	return purgeCmd
+}
+
+// newVerifyBackupFlagSet returns a flagset for the verify-backup subcommand
+// with flag values bound to the provided Config.
+func newVerifyBackupFlagSet(config *Config) *flag.FlagSet {
+
+	verifyBackupCmd := flag.NewFlagSet(VerifyBackupSubcommand, flag.ContinueOnError)
+	verifyBackupCmd.StringVar(&config.BackupDirectory, "backup-dir", "", "The (required) backup directory path previously used by the prune subcommand (or containing backup archives) to verify backup copies within.")
+	verifyBackupCmd.StringVar(&config.AuditLogFile, "audit-log", "", "The fully-qualified path to the audit log CSV file written by the prune subcommand when backup copies were made. Checksums recorded in the audit log are used to verify backup copies; backup archives are verified using their own manifest.")
+	verifyBackupCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible, such as failure to read individual backup copies.")
+
+	return verifyBackupCmd
+}
+
+// newUndoFlagSet returns a flagset for the undo subcommand with flag values
+// bound to the provided Config.
+func newUndoFlagSet(config *Config) *flag.FlagSet {
+
+	undoCmd := flag.NewFlagSet(UndoSubcommand, flag.ContinueOnError)
+	undoCmd.StringVar(&config.JournalFile, "journal", "", "The (required) fully-qualified path to the journal written by the prune subcommand (by default, the first input file with a .journal suffix). Files removed by the last run recorded in the journal are restored from their backup copies.")
+	undoCmd.BoolVar(&config.DryRun, "dry-run", false, "Don't actually restore files. Echo what would have been done to stdout.")
+
+	return undoCmd
+}
+
+// newStatsFlagSet returns a flagset for the stats subcommand with flag values
+// bound to the provided Config.
+func newStatsFlagSet(config *Config) *flag.FlagSet {
+
+	statsCmd := flag.NewFlagSet(StatsSubcommand, flag.ContinueOnError)
+	statsCmd.Var(&config.InputCSVFiles, "input-csvfile", "The fully-qualified path to a CSV file previously generated by the report subcommand to summarize. Glob patterns (e.g., reports/*.csv) are expanded. This flag may be repeated for each additional CSV file; rows from all files are combined.")
+	statsCmd.StringVar(&config.DirSummaryCSVFile, "dir-summary", "", "The (optional) fully-qualified path to a CSV file listing the number of duplicate files and wasted space per directory that this application should generate.")
+	statsCmd.StringVar(&config.ExtSummaryCSVFile, "ext-summary", "", "The (optional) fully-qualified path to a CSV file listing the number of duplicate files and wasted space per file extension that this application should generate.")
+	statsCmd.BoolVar(&config.WhatIf, "what-if", false, "Simulate each keep policy (see the keep-policy flag of the report subcommand) against the duplicate file sets listed in the CSV files, reporting the number of files each would remove, the space each would reclaim and the directories most affected, to help choose a policy before removing files.")
+	statsCmd.Var(&config.PreferPaths, "prefer-path", "Directory whose files are favored when simulating each keep policy (see the what-if flag), evaluated in addition to each policy alone. This flag may be repeated in priority order.")
+	statsCmd.Var(&config.PreferMatch, "prefer-match", "Regular expression matched against the full path of files favored when simulating each keep policy (see the what-if flag). This flag may be repeated.")
+	statsCmd.Var(&config.RemoveMatch, "remove-match", "Regular expression matched against the full path of files disfavored when simulating each keep policy (see the what-if flag). This flag may be repeated.")
+	statsCmd.StringVar(&config.SummaryFile, "summary-file", "", "The (optional) fully-qualified path to a JSON file containing the summary of the input CSV files that this application should generate.")
+	statsCmd.BoolVar(&config.UseFirstRow, "use-first-row", false, "Attempt to use the first row of the input file. Normally this row is skipped since it is usually the header row and not duplicate file data.")
+	statsCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible, such as rows which cannot be parsed.")
+
+	return statsCmd
+}
+
+// newMergeFlagSet returns a flagset for the merge subcommand with flag values
+// bound to the provided Config.
+func newMergeFlagSet(config *Config) *flag.FlagSet {
+
+	mergeCmd := flag.NewFlagSet(MergeSubcommand, flag.ContinueOnError)
+	mergeCmd.Var(&config.InputCSVFiles, "input-csvfile", "The fully-qualified path to a CSV file previously generated by the report subcommand to merge. Glob patterns (e.g., reports/*.csv) are expanded. This flag may be repeated for each additional CSV file.")
+	mergeCmd.StringVar(&config.OutputCSVFile, "csvfile", "", "The (required) fully-qualified path to the consolidated CSV file that this application should generate.")
+	mergeCmd.StringVar(&config.SummaryFile, "summary-file", "", "The (optional) fully-qualified path to a JSON file containing a summary of the consolidated duplicate file sets that this application should generate.")
+	mergeCmd.StringVar((*string)(&config.SortOrder), "sort", string(matches.SortByWastedSpace), "Order in which duplicate file sets are listed in the consolidated CSV file: wasted-space, size, path or count.")
+	mergeCmd.StringVar((*string)(&config.FileOrder), "set-order", string(matches.FileOrderPath), "Order in which files are listed within each consolidated duplicate file set: path, mtime (oldest first) or parent-size (directories holding the most duplicate data first). Ties are broken by path.")
+	mergeCmd.StringVar((*string)(&config.KeepPolicy), "keep-policy", string(matches.KeepOldest), "How the file suggested as the original to keep (keep column) is chosen from each consolidated duplicate file set: oldest, newest or shortest-path. Ties are broken by lexical path order.")
+	mergeCmd.Var(&config.PreferPaths, "prefer-path", "Directory whose files are suggested as the original to keep (keep column) over files elsewhere, such as a curated archive. This flag may be repeated in priority order; the keep-policy flag chooses between equally preferred files.")
+	mergeCmd.Var(&config.PreferMatch, "prefer-match", "Regular expression (e.g., /Archive/) matched against the full path of files which are suggested as the original to keep (keep column) over other files. Takes precedence over the prefer-path flag. This flag may be repeated.")
+	mergeCmd.Var(&config.RemoveMatch, "remove-match", "Regular expression (e.g., /Downloads/) matched against the full path of files which are suggested for removal (keep column) over other files. Takes precedence over the prefer-match and prefer-path flags. This flag may be repeated.")
+	mergeCmd.BoolVar(&config.BlankLineBetweenSets, "blank-line", false, "Add a blank line between sets of matching files in the consolidated CSV file.")
+	mergeCmd.BoolVar(&config.UseFirstRow, "use-first-row", false, "Attempt to use the first row of the input file. Normally this row is skipped since it is usually the header row and not duplicate file data.")
+	mergeCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible, such as rows which cannot be parsed or files which cannot be accessed.")
+
+	return mergeCmd
+}
+
+// newExploreFlagSet returns a flagset for the explore subcommand with flag
+// values bound to the provided Config.
+func newExploreFlagSet(config *Config) *flag.FlagSet {
+
+	exploreCmd := flag.NewFlagSet(ExploreSubcommand, flag.ContinueOnError)
+	exploreCmd.Var(&config.InputCSVFiles, "input-csvfile", "The fully-qualified path to a CSV file (or Excel workbook) previously generated by the report subcommand to explore. Glob patterns (e.g., reports/*.csv) are expanded. This flag may be repeated for each additional CSV file; rows from all files are combined.")
+	exploreCmd.Var(&config.Paths, "path", "Path to scan for duplicate files to explore instead of reading CSV files. This flag may be repeated for each additional path.")
+	exploreCmd.BoolVar(&config.RecursiveSearch, "recurse", false, "Perform recursive search into subdirectories per provided path.")
+	exploreCmd.Var(newByteSizeValue(1, &config.FileSizeThreshold), "size", "File size limit for evaluation, in bytes or with a unit (e.g., 500KB, 10MiB, 4GB). Files smaller than this will be skipped.")
+	exploreCmd.IntVar(&config.FileDuplicatesThreshold, "duplicates", 2, "Number of files of the same file size needed before duplicate validation logic is applied.")
+	exploreCmd.StringVar((*string)(&config.KeepPolicy), "keep-policy", string(matches.KeepOldest), "How the file kept from each duplicate file set found by scanning paths is chosen, and so not counted as wasted space: oldest, newest or shortest-path. Ties are broken by lexical path order. The keep column is used for CSV files.")
+	exploreCmd.Var(&config.PreferPaths, "prefer-path", "Directory whose files are kept over files elsewhere when scanning paths (see the keep-policy flag). This flag may be repeated in priority order.")
+	exploreCmd.BoolVar(&config.NoDefaultExcludes, "no-default-excludes", false, "Evaluate the content of well-known junk directories which are skipped by default when recursively evaluating paths: .git, .svn, node_modules, @eaDir, .Trash-* and System Volume Information.")
+	exploreCmd.BoolVar(&config.UseFirstRow, "use-first-row", false, "Attempt to use the first row of the input file. Normally this row is skipped since it is usually the header row and not duplicate file data.")
+	exploreCmd.BoolVar(&config.IgnoreErrors, "ignore-errors", false, "Ignore minor errors whenever possible, such as rows which cannot be parsed or files which cannot be read.")
+	exploreCmd.Var(&config.IgnoreErrorsFor, "ignore-errors-for", "Ignore only errors of the specified category (implies the ignore-errors flag), so that other errors remain fatal: permission-denied, vanished-file, read-error or checksum-mismatch. Several categories may be separated by commas or this flag may be repeated.")
+
+	return exploreCmd
+}
+
+// newCompletionFlagSet returns a flagset for the completion subcommand. The
+// shell to generate a completion script for is provided as a positional
+// argument instead of a flag.
+func newCompletionFlagSet(_ *Config) *flag.FlagSet {
+
+	completionCmd := flag.NewFlagSet(CompletionSubcommand, flag.ContinueOnError)
+
+	return completionCmd
+}
+
+// newCheckFlagSet returns a flagset for the check subcommand. The
+// subcommand to check (along with its flags) is provided as positional
+// arguments instead of flags.
+func newCheckFlagSet(_ *Config) *flag.FlagSet {
+
+	checkCmd := flag.NewFlagSet(CheckSubcommand, flag.ContinueOnError)
+
+	return checkCmd
+}
+
+// newSelfTestFlagSet returns a flagset for the selftest subcommand.
+func newSelfTestFlagSet(config *Config) *flag.FlagSet {
+
+	selfTestCmd := flag.NewFlagSet(SelfTestSubcommand, flag.ContinueOnError)
+
+	selfTestCmd.BoolVar(&config.KeepSelfTestDir, "keep-dir", false, "Keep the temporary directory of test files (and the generated CSV file and backups) for inspection instead of removing it once the self-test completes.")
+
+	return selfTestCmd
+}
+
+// SubcommandFlagSets returns a flagset for each supported subcommand in the
+// order the subcommands are listed in help output. The flagsets are bound to
+// a throwaway Config and are intended for introspection (e.g., generating
+// shell completion scripts), not for parsing user-provided flags.
+func SubcommandFlagSets() []*flag.FlagSet {
+
+	config := Config{}
+
+	flagSets := make([]*flag.FlagSet, 0, len(validSubcommands))
+	for _, subcommand := range validSubcommands {
+		switch subcommand {
+		case ReportSubcommand:
+			flagSets = append(flagSets, newReportFlagSet(&config))
+		case PruneSubcommand:
+			flagSets = append(flagSets, newPruneFlagSet(&config))
+		case WatchSubcommand:
+			flagSets = append(flagSets, newWatchFlagSet(&config))
+		case ServeSubcommand:
+			flagSets = append(flagSets, newServeFlagSet(&config))
+		case PurgeSubcommand:
+			flagSets = append(flagSets, newPurgeFlagSet(&config))
+		case VerifyBackupSubcommand:
+			flagSets = append(flagSets, newVerifyBackupFlagSet(&config))
+		case UndoSubcommand:
+			flagSets = append(flagSets, newUndoFlagSet(&config))
+		case StatsSubcommand:
+			flagSets = append(flagSets, newStatsFlagSet(&config))
+		case MergeSubcommand:
+			flagSets = append(flagSets, newMergeFlagSet(&config))
+		case ExploreSubcommand:
+			flagSets = append(flagSets, newExploreFlagSet(&config))
+		case CheckSubcommand:
+			flagSets = append(flagSets, newCheckFlagSet(&config))
+		case SelfTestSubcommand:
+			flagSets = append(flagSets, newSelfTestFlagSet(&config))
+		case CompletionSubcommand:
+			flagSets = append(flagSets, newCompletionFlagSet(&config))
+		}
+	}
+
+	return flagSets
+}
+
+// SubcommandDescription returns a brief summary of the specified subcommand
+// or an empty string if the subcommand is not recognized.
+func SubcommandDescription(subcommand string) string {
+	return subcommandDescriptions[subcommand]
+}
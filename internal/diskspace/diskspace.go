@@ -0,0 +1,35 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package diskspace provides functions for retrieving the free space of the
+// filesystem containing a path and the space allocated to files.
+package diskspace
+
+import "errors"
+
+// ErrUnsupported indicates that retrieving filesystem details is not
+// supported on the current platform.
+var ErrUnsupported = errors.New("filesystem details not supported on this platform")
+
+// Free returns the number of bytes available to the current user on the
+// filesystem containing the specified path.
+func Free(path string) (uint64, error) {
+	return free(path)
+}
+
+// FilesystemID returns a value identifying the filesystem containing the
+// specified path. Paths on the same filesystem share the same value.
+func FilesystemID(path string) (string, error) {
+	return filesystemID(path)
+}
+
+// Allocated returns the number of bytes allocated on disk to the specified
+// file. This may be smaller than the length of the file for sparse files or
+// files on compressed filesystems.
+func Allocated(path string) (int64, error) {
+	return allocated(path)
+}
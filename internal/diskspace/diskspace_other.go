@@ -0,0 +1,22 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build !linux && !darwin && !freebsd && !windows
+
+package diskspace
+
+func free(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func filesystemID(path string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func allocated(path string) (int64, error) {
+	return 0, ErrUnsupported
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build linux || darwin || freebsd
+
+package diskspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+)
+
+func free(path string) (uint64, error) {
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Clean(path), &stat); err != nil {
+		return 0, fmt.Errorf("failed to retrieve filesystem details for %q: %w", path, err)
+	}
+
+	// Field types vary between platforms.
+	//
+	// #nosec G115
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+func filesystemID(path string) (string, error) {
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(filepath.Clean(path), &stat); err != nil {
+		return "", fmt.Errorf("failed to retrieve file details for %q: %w", path, err)
+	}
+
+	return fmt.Sprint(stat.Dev), nil
+}
+
+func allocated(path string) (int64, error) {
+
+	var stat syscall.Stat_t
+	if err := syscall.Stat(filepath.Clean(path), &stat); err != nil {
+		return 0, fmt.Errorf("failed to retrieve file details for %q: %w", path, err)
+	}
+
+	// Blocks are always reported in 512-byte units, regardless of the
+	// block size of the filesystem.
+	//
+	// #nosec G115
+	return int64(stat.Blocks) * 512, nil
+}
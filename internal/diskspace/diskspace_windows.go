@@ -0,0 +1,87 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build windows
+
+package diskspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procGetDiskFreeSpaceExW    = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procGetCompressedFileSizeW = kernel32.NewProc("GetCompressedFileSizeW")
+)
+
+// invalidFileSize is returned by GetCompressedFileSizeW as the low-order
+// size value on failure (and for some valid sizes).
+const invalidFileSize = 0xFFFFFFFF
+
+func free(path string) (uint64, error) {
+
+	pathPtr, err := syscall.UTF16PtrFromString(filepath.Clean(path))
+	if err != nil {
+		return 0, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	var freeBytesAvailable uint64
+
+	// #nosec G103
+	result, _, err := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		0,
+		0,
+	)
+	if result == 0 {
+		return 0, fmt.Errorf("failed to retrieve filesystem details for %q: %w", path, err)
+	}
+
+	return freeBytesAvailable, nil
+}
+
+func filesystemID(path string) (string, error) {
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %q: %w", path, err)
+	}
+
+	return strings.ToUpper(filepath.VolumeName(absPath)), nil
+}
+
+func allocated(path string) (int64, error) {
+
+	pathPtr, err := syscall.UTF16PtrFromString(filepath.Clean(path))
+	if err != nil {
+		return 0, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	var high uint32
+
+	// The compressed size is the space allocated to compressed and sparse
+	// files; the length of the file is returned for other files.
+	//
+	// #nosec G103
+	low, _, err := procGetCompressedFileSizeW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&high)),
+	)
+	if low == invalidFileSize {
+		if errno, ok := err.(syscall.Errno); ok && errno != 0 {
+			return 0, fmt.Errorf("failed to retrieve file details for %q: %w", path, err)
+		}
+	}
+
+	return int64(high)<<32 | int64(low), nil
+}
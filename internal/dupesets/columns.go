@@ -0,0 +1,180 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package dupesets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/matches"
+)
+
+// InputColumns records the position of each known column within rows of an
+// input CSV file. Optional columns which are not present are recorded as -1.
+type InputColumns struct {
+	Directory   int
+	Filename    int
+	SizeHR      int
+	SizeInBytes int
+	Checksum    int
+	RemoveFile  int
+	SetID       int
+	Keep        int
+	Modified    int
+}
+
+// LegacyInputColumns is the fixed column layout used for input CSV files
+// without a (recognized) header row. The set ID column is only present in
+// files generated by recent versions of this application. Columns whose
+// position varies between versions (e.g., keep) are not used.
+var LegacyInputColumns = InputColumns{
+	Directory:   0,
+	Filename:    1,
+	SizeHR:      2,
+	SizeInBytes: 3,
+	Checksum:    4,
+	RemoveFile:  5,
+	SetID:       6,
+	Keep:        -1,
+	Modified:    -1,
+}
+
+// NewInputColumns returns the position of each known column based on the
+// provided header row. Columns may appear in any order and unknown columns
+// (e.g., optional report columns or columns added by later versions of this
+// application or by the user) are ignored. An error is returned if any of
+// the required directory, file, checksum or remove_file columns are missing.
+func NewInputColumns(header []string) (InputColumns, error) {
+
+	columns := InputColumns{
+		Directory:   -1,
+		Filename:    -1,
+		SizeHR:      -1,
+		SizeInBytes: -1,
+		Checksum:    -1,
+		RemoveFile:  -1,
+		SetID:       -1,
+		Keep:        -1,
+		Modified:    -1,
+	}
+
+	known := map[string]*int{
+		matches.CSVDirectoryColumnHeaderName:            &columns.Directory,
+		matches.CSVFileColumnHeaderName:                 &columns.Filename,
+		matches.CSVSizeColumnHeaderName:                 &columns.SizeHR,
+		matches.CSVSizeInBytesDirectoryColumnHeaderName: &columns.SizeInBytes,
+		matches.CSVChecksumColumnHeaderName:             &columns.Checksum,
+		matches.CSVRemoveFileColumnHeaderName:           &columns.RemoveFile,
+		matches.CSVSetIDColumnHeaderName:                &columns.SetID,
+		matches.CSVKeepColumnHeaderName:                 &columns.Keep,
+		matches.CSVModifiedColumnHeaderName:             &columns.Modified,
+	}
+
+	for index, name := range header {
+		position, ok := known[headerName(name)]
+		if !ok || *position != -1 {
+			continue
+		}
+		*position = index
+	}
+
+	var missing []string
+	for _, required := range []struct {
+		name     string
+		position int
+	}{
+		{matches.CSVDirectoryColumnHeaderName, columns.Directory},
+		{matches.CSVFileColumnHeaderName, columns.Filename},
+		{matches.CSVChecksumColumnHeaderName, columns.Checksum},
+		{matches.CSVRemoveFileColumnHeaderName, columns.RemoveFile},
+	} {
+		if required.position == -1 {
+			missing = append(missing, required.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return columns, fmt.Errorf(
+			"header row is missing required column(s): %s",
+			strings.Join(missing, ", "),
+		)
+	}
+
+	return columns, nil
+}
+
+// headerName normalizes the name of a column in the header row so that
+// names changed by hand or by a spreadsheet application (e.g., "Size In
+// Bytes" or a name preceded by a byte order mark) are still recognized.
+func headerName(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// requiredFields returns the minimum number of fields a row must contain in
+// order to include every required column.
+func (ic InputColumns) requiredFields() int {
+
+	required := 0
+	for _, position := range []int{ic.Directory, ic.Filename, ic.Checksum, ic.RemoveFile} {
+		if position+1 > required {
+			required = position + 1
+		}
+	}
+
+	return required
+}
+
+// field returns the trimmed value of the column at the specified position
+// within the row, or an empty string if the column is not present.
+func field(row []string, position int) string {
+	if position < 0 || position >= len(row) {
+		return ""
+	}
+
+	return strings.TrimSpace(row[position])
+}
+
+// parseBool returns the boolean value of a field. In addition to the values
+// accepted by strconv.ParseBool (e.g., TRUE or 1), values commonly used to
+// mark cells by hand in a spreadsheet (e.g., "yes" or "x") are accepted.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "x", "y", "yes", "on":
+		return true, nil
+	case "n", "no", "off":
+		return false, nil
+	default:
+		return strconv.ParseBool(value)
+	}
+}
+
+// parseSize returns the size (in bytes) recorded in a field. Thousands
+// separators (e.g., 1,234,567) and a fractional part of zero (e.g.,
+// 1234567.00) added by spreadsheet applications when formatting numbers are
+// accepted.
+func parseSize(value string) (int64, error) {
+
+	size := strings.NewReplacer(
+		",", "",
+		"_", "",
+		"'", "",
+		" ", "",
+		"\u00a0", "",
+		"\u202f", "",
+	).Replace(value)
+
+	if whole, fraction, found := strings.Cut(size, "."); found && strings.Trim(fraction, "0") == "" {
+		size = whole
+	}
+
+	return strconv.ParseInt(size, 10, 64)
+}
@@ -10,16 +10,21 @@
 package dupesets
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
-	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/atc0005/bridge/internal/bytecmp"
 	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/matches"
 	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/s3"
 	"github.com/atc0005/bridge/internal/units"
 )
 
@@ -32,6 +37,30 @@ const (
 	TabWriterRemoveFileColumnHeaderName string = "Remove"
 )
 
+// ErrPathNotFound indicates that a path listed in an input CSV file row does
+// not exist. It is paths.ErrPathNotExist, which also matches fs.ErrNotExist.
+var ErrPathNotFound = paths.ErrPathNotExist
+
+// ErrInvalidRow indicates that an input CSV file row cannot be parsed.
+var ErrInvalidRow = errors.New("invalid row")
+
+// ErrInconsistentSet indicates that the rows of a duplicate file set are
+// inconsistent with each other (e.g., record differing checksums) and may
+// have been modified since the report was generated.
+var ErrInconsistentSet = errors.New("inconsistent duplicate file set")
+
+// ErrAllFilesFlagged indicates that every file in a duplicate file set is
+// flagged for removal.
+var ErrAllFilesFlagged = errors.New("all files flagged for removal")
+
+// ErrConflictingRows indicates that a file is listed more than once with
+// conflicting values.
+var ErrConflictingRows = errors.New("conflicting rows")
+
+// ErrRootRequired indicates that a relative path was recorded but no root
+// directory was provided to resolve it.
+var ErrRootRequired = errors.New("root directory required")
+
 // DuplicateFileSetEntry represents a duplicate file set entry recorded as a
 // row within an input CSV file. This row is expected to contain data
 // originally generated by the `report` subcommand and a user-provided flag
@@ -62,6 +91,27 @@ type DuplicateFileSetEntry struct {
 	// RemoveFile is a flag indicating whether a file from a duplicate file
 	// set is to be removed
 	RemoveFile bool
+
+	// SetID identifies the duplicate file set that the file belongs to. This
+	// is empty for input files generated before set IDs were recorded.
+	SetID string
+
+	// InputFile is the input CSV file that the entry was read from. Set IDs
+	// are only unique within a single input file.
+	InputFile string
+
+	// Keep indicates whether the file is marked as the original to keep
+	// from the duplicate file set (keep column). This is only meaningful if
+	// KeepRecorded is set.
+	Keep bool
+
+	// KeepRecorded indicates whether a value was recorded in the keep
+	// column for the file.
+	KeepRecorded bool
+
+	// Modified is the modification time recorded for the file when the
+	// report was generated. This is the zero value if not recorded.
+	Modified time.Time
 }
 
 // DuplicateFileSetEntries is a collection of DuplicateFileSetEntry objects.
@@ -94,7 +144,7 @@ func (dfsEntries DuplicateFileSetEntries) Print(addSeparatorLine bool) {
 	)
 	_, _ = fmt.Fprintln(w, headerRow)
 
-	var lastChecksum checksums.SHA256Checksum
+	var lastSetKey string
 	var entriesCtr int
 	for _, row := range dfsEntries {
 
@@ -103,6 +153,17 @@ func (dfsEntries DuplicateFileSetEntries) Print(addSeparatorLine bool) {
 		// using len() builtin.
 		entriesCtr++
 
+		// if user requested a blank line between file sets, look at the
+		// set ID (or checksum if not set) for the last entry and compare
+		// against the current entry. A match indicates we are still
+		// processing files of the same set, so do not add a blank line.
+		// Also, skip adding a blank line for the first item.
+		if addSeparatorLine && entriesCtr != 1 {
+			if lastSetKey != row.setKey() {
+				_, _ = fmt.Fprintf(w, "\n")
+			}
+		}
+
 		_, _ = fmt.Fprintf(w,
 			"%v\t%v\t%v\t%v\t%v\n",
 			row.ParentDirectory,
@@ -112,20 +173,9 @@ func (dfsEntries DuplicateFileSetEntries) Print(addSeparatorLine bool) {
 			row.RemoveFile,
 		)
 
-		// if user requested a blank line between file sets, look at the
-		// checksum for the last entry and compare against the current
-		// checksum. A match indicates we are still processing files of the
-		// same set, so do not add a blank line. Also, skip adding a blank
-		// line for the first item.
-		if addSeparatorLine && entriesCtr != 1 {
-			if lastChecksum != row.Checksum {
-				_, _ = fmt.Fprintf(w, "\n")
-			}
-		}
-
-		// record current checksum for comparison at the top of the next loop
+		// record current set for comparison at the top of the next loop
 		// iteration.
-		lastChecksum = row.Checksum
+		lastSetKey = row.setKey()
 
 	}
 
@@ -139,6 +189,304 @@ func (dfsEntries DuplicateFileSetEntries) Print(addSeparatorLine bool) {
 
 }
 
+// setKey returns the value used to determine which duplicate file set the
+// entry belongs to: the set ID (qualified by input file) if recorded,
+// otherwise the checksum.
+func (dfsEntry DuplicateFileSetEntry) setKey() string {
+	if dfsEntry.SetID != "" {
+		return dfsEntry.InputFile + "\x00" + dfsEntry.SetID
+	}
+
+	return dfsEntry.Checksum.String()
+}
+
+// ValidateSets confirms that all entries sharing a set ID also share the
+// same checksum. A mismatch indicates that rows were edited (or copied
+// between sets) after the input file was generated, in which case removal
+// decisions may no longer reflect the original duplicate file sets.
+func (dfsEntries DuplicateFileSetEntries) ValidateSets() error {
+
+	setChecksums := make(map[string]checksums.SHA256Checksum)
+
+	for _, entry := range dfsEntries {
+		if entry.SetID == "" {
+			continue
+		}
+
+		checksum, ok := setChecksums[entry.setKey()]
+		if !ok {
+			setChecksums[entry.setKey()] = entry.Checksum
+			continue
+		}
+
+		if checksum != entry.Checksum {
+			setDescription := entry.SetID
+			if entry.InputFile != "" {
+				setDescription = fmt.Sprintf("%s in %q", entry.SetID, entry.InputFile)
+			}
+
+			return fmt.Errorf(
+				"%w: set %s contains files with differing checksums (%s, %s); "+
+					"rows for %q may have been modified",
+				ErrInconsistentSet,
+				setDescription,
+				checksum,
+				entry.Checksum,
+				filepath.Join(entry.ParentDirectory, entry.Filename),
+			)
+		}
+	}
+
+	return nil
+}
+
+// VerifyByteComparisonSets compares the content of each file in duplicate
+// file sets confirmed by byte comparison (instead of checksums) or by
+// partial checksums with the content of the first file in the set, byte for
+// byte. Only sets with at least one file flagged for removal are compared.
+// Unlike checksums, byte comparison identifiers cannot be used to verify
+// individual files, so a file modified since the report was generated is
+// only detected this way. Partial checksums only cover the start of each
+// file, so files in these sets are not confirmed to be identical until
+// compared.
+func (dfsEntries DuplicateFileSetEntries) VerifyByteComparisonSets() error {
+
+	sets := make(map[string]DuplicateFileSetEntries)
+	var keys []string
+
+	for _, entry := range dfsEntries {
+		if !entry.Checksum.IsByteComparison() && !entry.Checksum.IsPartial() {
+			continue
+		}
+
+		key := entry.setKey()
+		if _, ok := sets[key]; !ok {
+			keys = append(keys, key)
+		}
+		sets[key] = append(sets[key], entry)
+	}
+
+	for _, key := range keys {
+		set := sets[key]
+
+		if len(set.FilesToRemove()) == 0 {
+			continue
+		}
+
+		first := filepath.Join(set[0].ParentDirectory, set[0].Filename)
+		for _, entry := range set[1:] {
+			fileFullPath := filepath.Join(entry.ParentDirectory, entry.Filename)
+
+			same, err := bytecmp.EqualFiles(first, fileFullPath)
+			if err != nil {
+				return err
+			}
+
+			if !same {
+				return fmt.Errorf(
+					"%w: content of %q differs from %q despite sharing identifier %s; "+
+						"file may have been modified since the report was generated "+
+						"(or differs beyond the start of the file for partial checksums)",
+					checksums.ErrContentMismatch,
+					fileFullPath,
+					first,
+					entry.Checksum,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SortBySetID groups entries by input file (in the order first seen) and
+// then by set ID, preserving the original order of entries within each set.
+// Numeric set IDs are sorted numerically. Entries without a set ID are listed
+// last within each input file.
+func (dfsEntries DuplicateFileSetEntries) SortBySetID() {
+
+	inputFileOrder := make(map[string]int)
+	for _, entry := range dfsEntries {
+		if _, ok := inputFileOrder[entry.InputFile]; !ok {
+			inputFileOrder[entry.InputFile] = len(inputFileOrder)
+		}
+	}
+
+	sort.SliceStable(dfsEntries, func(i, j int) bool {
+		fileA := inputFileOrder[dfsEntries[i].InputFile]
+		fileB := inputFileOrder[dfsEntries[j].InputFile]
+		if fileA != fileB {
+			return fileA < fileB
+		}
+
+		a, b := dfsEntries[i].SetID, dfsEntries[j].SetID
+
+		switch {
+		case a == b:
+			return false
+		case a == "":
+			return false
+		case b == "":
+			return true
+		}
+
+		numA, errA := strconv.Atoi(a)
+		numB, errB := strconv.Atoi(b)
+		if errA == nil && errB == nil {
+			return numA < numB
+		}
+
+		return a < b
+	})
+}
+
+// ValidateKeepOne confirms that at least one file from each duplicate file
+// set is retained. Sets are grouped by set ID (or checksum if not recorded).
+// An error is returned if every file in a set is flagged for removal (e.g.,
+// after an accidental fill-down in a spreadsheet) since no copy of the file
+// would remain.
+func (dfsEntries DuplicateFileSetEntries) ValidateKeepOne() error {
+
+	type setCounts struct {
+		entry   DuplicateFileSetEntry
+		files   int
+		flagged int
+	}
+
+	sets := make(map[string]*setCounts)
+	var keys []string
+
+	for _, entry := range dfsEntries {
+		key := entry.setKey()
+
+		counts, ok := sets[key]
+		if !ok {
+			counts = &setCounts{entry: entry}
+			sets[key] = counts
+			keys = append(keys, key)
+		}
+
+		counts.files++
+		if entry.RemoveFile {
+			counts.flagged++
+		}
+	}
+
+	var invalidSets int
+	var firstInvalid DuplicateFileSetEntry
+
+	for _, key := range keys {
+		counts := sets[key]
+		if counts.flagged == 0 || counts.flagged < counts.files {
+			continue
+		}
+
+		if invalidSets == 0 {
+			firstInvalid = counts.entry
+		}
+		invalidSets++
+
+		setDescription := counts.entry.Checksum.String()
+		if counts.entry.SetID != "" {
+			setDescription = fmt.Sprintf("%s (checksum %s)", counts.entry.SetID, counts.entry.Checksum)
+		}
+
+		// WARN
+		log.Printf(
+			"All %d files in duplicate file set %s flagged for removal\n",
+			counts.files,
+			setDescription,
+		)
+	}
+
+	if invalidSets > 0 {
+		return fmt.Errorf(
+			"%w in %d duplicate file set(s), including the set containing %q; "+
+				"at least one file from each set must be kept",
+			ErrAllFilesFlagged,
+			invalidSets,
+			filepath.Join(firstInvalid.ParentDirectory, firstInvalid.Filename),
+		)
+	}
+
+	return nil
+}
+
+// RemoveRepeatedFiles returns the entries with files listed more than once
+// (e.g., in overlapping input CSV files) reduced to their first entry. An
+// error is returned if repeated entries for a file disagree on whether the
+// file is to be removed, since one set may be relying on the file as the copy
+// to keep.
+func (dfsEntries DuplicateFileSetEntries) RemoveRepeatedFiles() (DuplicateFileSetEntries, error) {
+
+	seen := make(map[string]DuplicateFileSetEntry, len(dfsEntries))
+	unique := make(DuplicateFileSetEntries, 0, len(dfsEntries))
+
+	for _, entry := range dfsEntries {
+		fileFullPath := filepath.Join(entry.ParentDirectory, entry.Filename)
+
+		first, ok := seen[fileFullPath]
+		if !ok {
+			seen[fileFullPath] = entry
+			unique = append(unique, entry)
+			continue
+		}
+
+		if first.RemoveFile != entry.RemoveFile {
+			return nil, fmt.Errorf(
+				"%w: file %q is listed more than once (in %q and %q) with conflicting remove_file values",
+				ErrConflictingRows,
+				fileFullPath,
+				first.InputFile,
+				entry.InputFile,
+			)
+		}
+
+		log.Printf(
+			"File %q listed in %q is also listed in %q; ignoring repeated entry\n",
+			fileFullPath,
+			entry.InputFile,
+			first.InputFile,
+		)
+	}
+
+	return unique, nil
+}
+
+// FileChecksumIndex returns the entries grouped into duplicate file sets by
+// checksum, for use with the summaries generated by the report subcommand.
+// Files listed more than once (e.g., in overlapping input CSV files) are
+// only included once. The files are not accessed.
+func (dfsEntries DuplicateFileSetEntries) FileChecksumIndex() matches.FileChecksumIndex {
+
+	fileChecksumIndex := make(matches.FileChecksumIndex)
+	seen := make(map[string]bool, len(dfsEntries))
+
+	for _, entry := range dfsEntries {
+		fileFullPath := filepath.Join(entry.ParentDirectory, entry.Filename)
+		if s3.IsURL(entry.ParentDirectory) {
+			fileFullPath = entry.ParentDirectory + "/" + entry.Filename
+		}
+		if seen[fileFullPath] {
+			continue
+		}
+		seen[fileFullPath] = true
+
+		fileChecksumIndex[entry.Checksum] = append(
+			fileChecksumIndex[entry.Checksum],
+			matches.NewRecordedFileMatch(
+				fileFullPath,
+				entry.SizeInBytes,
+				entry.Modified,
+				entry.Checksum,
+				entry.Keep,
+			),
+		)
+	}
+
+	return fileChecksumIndex
+}
+
 // FilesToRemove returns a dfsEntries object representing the files that the
 // user has flagged for removal
 func (dfsEntries DuplicateFileSetEntries) FilesToRemove() DuplicateFileSetEntries {
@@ -153,6 +501,147 @@ func (dfsEntries DuplicateFileSetEntries) FilesToRemove() DuplicateFileSetEntrie
 	return filesToRemove
 }
 
+// VerifyBackup confirms that the provided backup copy of the file matches
+// the checksum recorded for the file (see checksums.VerifyCopy).
+func (dfsEntry DuplicateFileSetEntry) VerifyBackup(backupPath string) error {
+
+	fileFullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+
+	if err := dfsEntry.Checksum.VerifyCopy(fileFullPath, backupPath); err != nil {
+		return fmt.Errorf(
+			"backup copy %q of %q failed verification: %w",
+			backupPath,
+			fileFullPath,
+			err,
+		)
+	}
+
+	return nil
+}
+
+// TotalSize returns the combined size in bytes of all entries.
+func (dfsEntries DuplicateFileSetEntries) TotalSize() int64 {
+
+	var total int64
+	for _, entry := range dfsEntries {
+		total += entry.SizeInBytes
+	}
+
+	return total
+}
+
+// PrintRemovalSummary writes a summary of the entries (expected to be those
+// flagged for removal) to stdout: the number of files, their total size and
+// the number of files and bytes per directory. Directories with the most
+// files are listed first.
+func (dfsEntries DuplicateFileSetEntries) PrintRemovalSummary() {
+
+	type directorySummary struct {
+		directory string
+		files     int
+		size      int64
+	}
+
+	directories := make(map[string]*directorySummary)
+	var summaries []*directorySummary
+
+	for _, entry := range dfsEntries {
+		summary, ok := directories[entry.ParentDirectory]
+		if !ok {
+			summary = &directorySummary{directory: entry.ParentDirectory}
+			directories[entry.ParentDirectory] = summary
+			summaries = append(summaries, summary)
+		}
+
+		summary.files++
+		summary.size += entry.SizeInBytes
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].files != summaries[j].files {
+			return summaries[i].files > summaries[j].files
+		}
+		return summaries[i].directory < summaries[j].directory
+	})
+
+	w := &tabwriter.Writer{}
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Removal summary:")
+	_, _ = fmt.Fprintf(w, "%d\tfiles to remove\n", len(dfsEntries))
+	_, _ = fmt.Fprintf(w, "%s\ttotal size of files to remove\n", units.ByteCountIEC(dfsEntries.TotalSize()))
+	_, _ = fmt.Fprintf(w, "%d\tdirectories affected\n", len(summaries))
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n",
+		TabWriterDirectoryColumnHeaderName,
+		"Files",
+		TabWriterSizeColumnHeaderName,
+	)
+	for _, summary := range summaries {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n",
+			summary.directory,
+			summary.files,
+			units.ByteCountIEC(summary.size),
+		)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// ResolveRoot resolves the parent directory of the entry against the
+// provided root directory if it was recorded as a relative path (see the
+// relative-paths flag of the report subcommand). Relative paths are recorded
+// using slash separators. An error is returned if the parent directory is
+// relative and no root directory is provided.
+func (dfsEntry *DuplicateFileSetEntry) ResolveRoot(root string) error {
+
+	if dfsEntry == nil || filepath.IsAbs(dfsEntry.ParentDirectory) {
+		return nil
+	}
+
+	if root == "" {
+		return fmt.Errorf(
+			"%w: relative parent directory %q recorded; root directory required to resolve it",
+			ErrRootRequired,
+			dfsEntry.ParentDirectory,
+		)
+	}
+
+	dfsEntry.ParentDirectory = filepath.Join(root, filepath.FromSlash(dfsEntry.ParentDirectory))
+
+	return nil
+}
+
+// ResolvePath updates the parent directory and filename of the entry to
+// match the path used by the filesystem if the recorded path differs only
+// in Unicode normalization (e.g., a CSV file generated on macOS and used on
+// Linux). The entry is left unmodified if the file is not found.
+func (dfsEntry *DuplicateFileSetEntry) ResolvePath() {
+
+	if dfsEntry == nil {
+		return
+	}
+
+	recorded := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+	resolved := paths.Resolve(recorded)
+	if resolved == recorded {
+		return
+	}
+
+	// DEBUG
+	log.Printf("Resolved %q to %q using alternate Unicode normalization\n", recorded, resolved)
+
+	dfsEntry.ParentDirectory = filepath.Dir(resolved)
+	dfsEntry.Filename = filepath.Base(resolved)
+}
+
 // UpdateSizeInfo fills in potentially missing size information for each entry
 // in the duplicate file set.
 func (dfsEntry *DuplicateFileSetEntry) UpdateSizeInfo() error {
@@ -194,9 +683,68 @@ func (dfsEntry *DuplicateFileSetEntry) UpdateSizeInfo() error {
 // processed further
 func ValidateInputRow(dfsEntry DuplicateFileSetEntry, rowNum int) error {
 
+	if err := validateInputRowPath(dfsEntry, rowNum); err != nil {
+		return err
+	}
+
+	// Now that we know the parent directory exists and the full path to the
+	// file exists, verify checksum before proceeding further
+	fileFullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+	if err := dfsEntry.Checksum.Verify(fileFullPath); err != nil {
+		return fmt.Errorf(
+			"checksum validation failed for %q: %w", fileFullPath, err)
+	}
+
+	// TODO: Any validation needed against the RemoveFile field? At this point
+	// we are not trying to decide whether the file should be removed, just
+	// whether the DuplicateFileSetEntry object is properly constructed.
+
+	// Optimism!
+	return nil
+}
+
+// ValidateInputRowSize performs the same validation steps as
+// ValidateInputRow, except that the size of the file is compared with the
+// recorded size (if any) instead of verifying its checksum. This avoids
+// reading the content of every file a second time, but does not detect
+// files modified without a change in size.
+func ValidateInputRowSize(dfsEntry DuplicateFileSetEntry, rowNum int) error {
+
+	if err := validateInputRowPath(dfsEntry, rowNum); err != nil {
+		return err
+	}
+
+	if dfsEntry.SizeInBytes == 0 {
+		return nil
+	}
+
+	fileFullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
+	fileInfo, err := os.Stat(fileFullPath)
+	if err != nil {
+		return fmt.Errorf(
+			"size validation failed for %q: %w", fileFullPath, err)
+	}
+
+	if fileInfo.Size() != dfsEntry.SizeInBytes {
+		return fmt.Errorf(
+			"size validation failed for %q: %w, file likely modified; got %d bytes, expected %d bytes",
+			fileFullPath,
+			checksums.ErrSizeMismatch,
+			fileInfo.Size(),
+			dfsEntry.SizeInBytes,
+		)
+	}
+
+	return nil
+}
+
+// validateInputRowPath confirms that the parent directory and the file
+// listed in an input CSV row exist.
+func validateInputRowPath(dfsEntry DuplicateFileSetEntry, rowNum int) error {
+
 	if !paths.PathExists(dfsEntry.ParentDirectory) {
 		return fmt.Errorf(
-			"row %d, field %d has invalid parent directory path", rowNum, 0)
+			"row %d, field %d has invalid parent directory path: %w", rowNum, 0, ErrPathNotFound)
 	}
 
 	// Filename field
@@ -208,78 +756,75 @@ func ValidateInputRow(dfsEntry DuplicateFileSetEntry, rowNum int) error {
 	fileFullPath := filepath.Join(dfsEntry.ParentDirectory, dfsEntry.Filename)
 	if !paths.PathExists(fileFullPath) {
 		return fmt.Errorf(
-			"row %d, has invalid path to file: %q", rowNum, fileFullPath)
+			"row %d, has invalid path to file: %q: %w", rowNum, fileFullPath, ErrPathNotFound)
 	}
 
-	// Now that we know the parent directory exists and the full path to the
-	// file exists, verify checksum before proceeding further
-	if err := dfsEntry.Checksum.Verify(fileFullPath); err != nil {
-		return fmt.Errorf(
-			"checksum validation failed for %q: %w", fileFullPath, err)
-	}
-
-	// TODO: Any validation needed against the RemoveFile field? At this point
-	// we are not trying to decide whether the file should be removed, just
-	// whether the DuplicateFileSetEntry object is properly constructed.
-
-	// Optimism!
 	return nil
 }
 
+// IsStale indicates whether the provided error (returned by
+// ValidateInputRow) reports that the file listed in an input CSV row no
+// longer exists or has been modified since the report was generated, as
+// opposed to a problem reading the file.
+func IsStale(err error) bool {
+	return errors.Is(err, ErrPathNotFound) ||
+		errors.Is(err, checksums.ErrChecksumMismatch) ||
+		errors.Is(err, checksums.ErrSizeMismatch)
+}
+
 // ParseInputRow evaluates each row returned from the CSV Reader returning a
 // DuplicateFileSetEntry object if parsing succeeds, otherwise returning an
-// error.
-func ParseInputRow(row []string, fieldCount int, rowNum int) (DuplicateFileSetEntry, error) {
-
-	// TODO: Use error wrapping extensively in this function
+// error. Fields are located using the provided column positions (see
+// NewInputColumns); fields in unknown columns are ignored. Values edited by
+// hand or reformatted by a spreadsheet application (e.g., sizes with
+// thousands separators or "yes" in the remove_file column) are accepted.
+func ParseInputRow(row []string, columns InputColumns, rowNum int) (DuplicateFileSetEntry, error) {
 
 	dfsEntry := DuplicateFileSetEntry{}
 	var err error
 
-	// The CSV Reader already performs field count validation, but let's be
-	// paranoid and recheck to help ensure that we didn't make a mistake and
-	// pass a different slice than expected.
-	if len(row) != fieldCount {
+	// Rows may contain additional (optional or unknown) columns; these are
+	// ignored.
+	if len(row) < columns.requiredFields() {
 		return dfsEntry, fmt.Errorf(
-			"unexpected number of fields received. got %d, expected %d",
+			"%w: unexpected number of fields received. got %d, expected at least %d",
+			ErrInvalidRow,
 			len(row),
-			fieldCount,
+			columns.requiredFields(),
 		)
 	}
 
-	// Go ahead and trim space from all fields
-	for index, field := range row {
-		row[index] = strings.TrimSpace(field)
-	}
-
 	// ParentDirectory
-	if row[0] == "" {
+	parentDirectory := field(row, columns.Directory)
+	if parentDirectory == "" {
 		return dfsEntry,
-			fmt.Errorf("row %d, field %d has empty parent directory path", rowNum, 1)
+			fmt.Errorf("%w: row %d, field %d has empty parent directory path", ErrInvalidRow, rowNum, columns.Directory+1)
 	}
 
 	// Filename
-	if row[1] == "" {
+	filename := field(row, columns.Filename)
+	if filename == "" {
 		return dfsEntry,
-			fmt.Errorf("row %d, field %d has empty filename", rowNum, 2)
+			fmt.Errorf("%w: row %d, field %d has empty filename", ErrInvalidRow, rowNum, columns.Filename+1)
 	}
 
 	// Do not require that this field be populated. We do not have an
 	// immediate use for the value in this field and it was mostly used to
 	// display the size value as a human-readable value for the report.
-	if row[2] == "" {
-		log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, 3, row[2])
+	sizeHR := field(row, columns.SizeHR)
+	if sizeHR == "" {
+		log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, columns.SizeHR+1, sizeHR)
 	}
 
 	// This field is optional; we can regenerate the value later when needed
 	// if the row field is empty, we end up with the zero value that we can
 	// later check against.
 	var sizeInBytes int64
-	if row[3] != "" {
-		sizeInBytes, err = strconv.ParseInt(row[3], 10, 64)
+	if sizeField := field(row, columns.SizeInBytes); sizeField != "" {
+		sizeInBytes, err = parseSize(sizeField)
 		if err != nil {
-			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, 4, row[3])
-			return dfsEntry, fmt.Errorf("failed to convert CSV sizeInBytes field: %w", err)
+			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, columns.SizeInBytes+1, sizeField)
+			return dfsEntry, fmt.Errorf("%w: failed to convert CSV sizeInBytes field: %w", ErrInvalidRow, err)
 		}
 	}
 
@@ -288,30 +833,63 @@ func ParseInputRow(row []string, fieldCount int, rowNum int) (DuplicateFileSetEn
 	// that the file to be removed matches the original checksum recorded for
 	// it. If we allowed an empty checksum here, we remove any protection
 	// against removing non-duplicate files.
-	if row[4] == "" {
+	checksum := field(row, columns.Checksum)
+	if checksum == "" {
 		return dfsEntry,
-			fmt.Errorf("row %d, field %d has empty checksum", rowNum, 5)
+			fmt.Errorf("%w: row %d, field %d has empty checksum", ErrInvalidRow, rowNum, columns.Checksum+1)
 	}
 
 	// Optional field, use default zero value of false if not set
 	var removeFile bool
-	if row[5] != "" {
-		removeFile, err = strconv.ParseBool(row[5])
+	if removeField := field(row, columns.RemoveFile); removeField != "" {
+		removeFile, err = parseBool(removeField)
+		if err != nil {
+			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, columns.RemoveFile+1, removeField)
+			return dfsEntry, fmt.Errorf("%w: failed to convert CSV remove_file field: %w", ErrInvalidRow, err)
+		}
+	}
+
+	// Optional field; the keep column is only present in input files
+	// generated by current versions of this application
+	var keep bool
+	keepField := field(row, columns.Keep)
+	if keepField != "" {
+		keep, err = parseBool(keepField)
+		if err != nil {
+			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, columns.Keep+1, keepField)
+			return dfsEntry, fmt.Errorf("%w: failed to convert CSV keep field: %w", ErrInvalidRow, err)
+		}
+	}
+
+	// Optional field; the modification time is informational only, so
+	// values which cannot be parsed (e.g., reformatted by a spreadsheet
+	// application) are ignored instead of rejecting the row.
+	var modified time.Time
+	if modifiedField := field(row, columns.Modified); modifiedField != "" {
+		modified, err = time.Parse(time.RFC3339, modifiedField)
 		if err != nil {
-			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, 6, row[5])
-			return dfsEntry, fmt.Errorf("failed to convert CSV remove_file field: %w", err)
+			log.Printf("DEBUG | CSV row %d, field %d: %q\n", rowNum, columns.Modified+1, modifiedField)
+			modified = time.Time{}
 		}
 	}
 
 	// convert a CSV row into an object representing the various named
 	// fields found in that row
 	dfsEntry = DuplicateFileSetEntry{
-		ParentDirectory: row[0],
-		Filename:        row[1],
-		SizeHR:          row[2],
+		ParentDirectory: parentDirectory,
+		Filename:        filename,
+		SizeHR:          sizeHR,
 		SizeInBytes:     sizeInBytes,
-		Checksum:        checksums.SHA256Checksum(row[4]),
+		Checksum:        checksums.ParseChecksum(checksum),
 		RemoveFile:      removeFile,
+
+		// Optional field; the set ID is only recorded in input files
+		// generated by current versions of this application
+		SetID: field(row, columns.SetID),
+
+		Keep:         keep,
+		KeepRecorded: keepField != "",
+		Modified:     modified,
 	}
 
 	// everything went well
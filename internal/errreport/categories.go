@@ -0,0 +1,95 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package errreport
+
+// Category is a category of errors which may be ignored (as requested)
+// independently of other errors, such as permission errors encountered
+// reading system directories.
+type Category string
+
+// Categories of errors which may be ignored
+const (
+	// CategoryPermissionDenied is an error due to insufficient permissions
+	// to read (or remove) a file or directory.
+	CategoryPermissionDenied Category = "permission-denied"
+
+	// CategoryVanishedFile is an error due to a file or directory which no
+	// longer exists, such as a file removed after it was found.
+	CategoryVanishedFile Category = "vanished-file"
+
+	// CategoryChecksumMismatch is an error due to content which no longer
+	// matches its recorded size or checksum, such as a file modified since
+	// a report was generated or a backup copy which failed verification.
+	CategoryChecksumMismatch Category = "checksum-mismatch"
+
+	// CategoryReadError is any other error, such as an I/O error reading a
+	// file or a malformed input file row.
+	CategoryReadError Category = "read-error"
+)
+
+// Categories is the list of supported error categories.
+var Categories = []Category{
+	CategoryPermissionDenied,
+	CategoryVanishedFile,
+	CategoryReadError,
+	CategoryChecksumMismatch,
+}
+
+// ignoredCategories is the set of error categories ignored when ignoring
+// errors is requested. All categories are ignored if empty.
+var ignoredCategories map[Category]bool
+
+// IsValid indicates whether the error category is supported.
+func (c Category) IsValid() bool {
+	for _, category := range Categories {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CategoryOf returns the category of the provided error.
+func CategoryOf(err error) Category {
+	switch Classify(err) {
+	case ClassPermission:
+		return CategoryPermissionDenied
+	case ClassNotFound:
+		return CategoryVanishedFile
+	case ClassChanged:
+		return CategoryChecksumMismatch
+	default:
+		return CategoryReadError
+	}
+}
+
+// SetIgnoredCategories limits the errors ignored when ignoring errors is
+// requested to the specified categories. All categories are ignored if none
+// are specified.
+func SetIgnoredCategories(categories ...Category) {
+	ignoredCategories = make(map[Category]bool, len(categories))
+	for _, category := range categories {
+		ignoredCategories[category] = true
+	}
+}
+
+// Ignore indicates whether the provided error should be ignored: ignoring
+// errors was requested and the category of the error is one of the ignored
+// categories (see SetIgnoredCategories).
+func Ignore(ignoreErrors bool, err error) bool {
+	if !ignoreErrors {
+		return false
+	}
+
+	if len(ignoredCategories) == 0 {
+		return true
+	}
+
+	return ignoredCategories[CategoryOf(err)]
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package errreport collects the errors ignored (as requested) and the files
+// skipped during a run so that they can be summarized once the run
+// completes, instead of only being scattered through the log output of a
+// long run.
+package errreport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// Operation is the operation being performed on a file when an error was
+// encountered.
+type Operation string
+
+// Operations during which errors are recorded
+const (
+	OperationWalk     Operation = "walk"
+	OperationHash     Operation = "hash"
+	OperationCompare  Operation = "compare"
+	OperationMetadata Operation = "metadata"
+	OperationParse    Operation = "parse"
+	OperationValidate Operation = "validate"
+	OperationBackup   Operation = "backup"
+	OperationRemove   Operation = "remove"
+)
+
+// Class is the broad category of an error, allowing errors to be grouped
+// regardless of the exact message returned by the operating system.
+type Class string
+
+// Classes of recorded errors
+const (
+	ClassNotFound   Class = "not-found"
+	ClassPermission Class = "permission"
+	ClassInUse      Class = "in-use"
+	ClassChanged    Class = "changed"
+	ClassOther      Class = "other"
+)
+
+// Column header names for the errors CSV file
+const (
+	PathColumnHeaderName      string = "path"
+	OperationColumnHeaderName string = "operation"
+	ClassColumnHeaderName     string = "class"
+	ErrorColumnHeaderName     string = "error"
+)
+
+// consoleEntriesLimit is the maximum number of entries listed individually
+// in the console summary.
+const consoleEntriesLimit = 20
+
+// Entry is a single error ignored (or file skipped) during a run.
+type Entry struct {
+
+	// Path is the fully-qualified path to the file (or directory), if known
+	Path string
+
+	// Operation is the operation being performed when the error was
+	// encountered
+	Operation Operation
+
+	// Class is the broad category of the error
+	Class Class
+
+	// Err is the error message
+	Err string
+}
+
+var (
+	mu      sync.Mutex
+	entries []Entry
+)
+
+// Classify returns the broad category of the provided error.
+func Classify(err error) Class {
+	switch {
+	case paths.IsInUse(err):
+		return ClassInUse
+	case errors.Is(err, fs.ErrNotExist):
+		return ClassNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ClassPermission
+	case errors.Is(err, checksums.ErrChecksumMismatch),
+		errors.Is(err, checksums.ErrSizeMismatch),
+		errors.Is(err, checksums.ErrContentMismatch):
+		return ClassChanged
+	default:
+		return ClassOther
+	}
+}
+
+// Record records an error ignored while performing the specified operation
+// on the file (or directory) at the provided path. If no path is provided,
+// the path recorded by the error (if any) is used.
+func Record(path string, operation Operation, err error) {
+
+	if path == "" {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			path = pathErr.Path
+		}
+	}
+
+	add(Entry{
+		Path:      path,
+		Operation: operation,
+		Class:     Classify(err),
+		Err:       err.Error(),
+	})
+}
+
+// RecordSkip records a file skipped while performing the specified
+// operation for the provided reason.
+func RecordSkip(path string, operation Operation, class Class, reason string) {
+	add(Entry{
+		Path:      path,
+		Operation: operation,
+		Class:     class,
+		Err:       reason,
+	})
+}
+
+func add(entry Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries = append(entries, entry)
+}
+
+// Entries returns the errors recorded so far, in the order recorded.
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]Entry(nil), entries...)
+}
+
+// Reset discards all recorded errors (e.g., before starting another scan).
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries = nil
+}
+
+// Print writes a summary of the recorded errors to the provided writer: the
+// number of errors per operation and class followed by the first recorded
+// errors. Nothing is written if no errors were recorded. The errorsFile
+// (if any) is mentioned as listing all errors.
+func Print(w io.Writer, errorsFile string) {
+
+	recorded := Entries()
+	if len(recorded) == 0 {
+		return
+	}
+
+	type group struct {
+		operation Operation
+		class     Class
+	}
+	counts := make(map[group]int)
+	for _, entry := range recorded {
+		counts[group{entry.Operation, entry.Class}]++
+	}
+
+	groups := make([]group, 0, len(counts))
+	for g := range counts {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].operation != groups[j].operation {
+			return groups[i].operation < groups[j].operation
+		}
+		return groups[i].class < groups[j].class
+	})
+
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 8, 8, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(tw, "Errors and skipped files (%d):\n", len(recorded))
+	_, _ = fmt.Fprintln(tw, "Operation\tClass\tCount")
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", g.operation, g.class, counts[g])
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	_, _ = fmt.Fprintln(tw, "Operation\tClass\tPath\tError")
+	for i, entry := range recorded {
+		if i == consoleEntriesLimit {
+			_, _ = fmt.Fprintf(tw, "(%d more not shown", len(recorded)-consoleEntriesLimit)
+			if errorsFile != "" {
+				_, _ = fmt.Fprintf(tw, "; see %q", errorsFile)
+			}
+			_, _ = fmt.Fprintln(tw, ")")
+			break
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Operation, entry.Class, entry.Path, entry.Err)
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	if err := tw.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
+
+// WriteCSV writes all recorded errors to the specified CSV file, listing
+// the path, operation, class and message of each error.
+func WriteCSV(filename string) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	header := []string{
+		PathColumnHeaderName,
+		OperationColumnHeaderName,
+		ClassColumnHeaderName,
+		ErrorColumnHeaderName,
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for _, entry := range Entries() {
+		row := []string{
+			entry.Path,
+			string(entry.Operation),
+			string(entry.Class),
+			entry.Err,
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
@@ -0,0 +1,386 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package exif provides minimal extraction of EXIF metadata (capture time,
+// camera make and model) from JPEG and PNG files.
+//
+// Only the handful of tags used by this application are decoded; this is
+// not a general purpose EXIF library.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotFound indicates that the file does not contain EXIF metadata.
+var ErrNotFound = errors.New("EXIF metadata not found")
+
+// ErrInvalid indicates that the EXIF metadata could not be parsed.
+var ErrInvalid = errors.New("invalid EXIF metadata")
+
+// DateTimeLayout is the layout used by EXIF for date/time values.
+const DateTimeLayout string = "2006:01:02 15:04:05"
+
+// TIFF tags of interest
+const (
+	tagMake                uint16 = 0x010F
+	tagModel               uint16 = 0x0110
+	tagExifIFDPointer      uint16 = 0x8769
+	tagDateTimeOriginal    uint16 = 0x9003
+	tagDateTimeDigitized   uint16 = 0x9004
+	tagPixelXDimension     uint16 = 0xA002
+	tagPixelYDimension     uint16 = 0xA003
+	tiffTypeASCII          uint16 = 2
+	tiffTypeShort          uint16 = 3
+	tiffTypeLong           uint16 = 4
+	maxIFDEntries          int    = 1000
+	maxPNGChunkSize        uint32 = 4 << 20
+	jpegMarkerStartOfScan  byte   = 0xDA
+	jpegMarkerEndOfImage   byte   = 0xD9
+	jpegMarkerAPP1         byte   = 0xE1
+	jpegMarkerStartOfImage byte   = 0xD8
+)
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// supportedExtensions are the file extensions of formats that EXIF metadata
+// can be read from.
+var supportedExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+// Metadata is the subset of EXIF metadata used by this application.
+type Metadata struct {
+
+	// CaptureTime is the date/time that the image was captured
+	// (DateTimeOriginal, falling back to DateTimeDigitized). EXIF does not
+	// record a time zone, so the value is recorded as UTC. The zero value
+	// indicates that the capture time is not known.
+	CaptureTime time.Time
+
+	// Make is the camera manufacturer
+	Make string
+
+	// Model is the camera model
+	Model string
+
+	// Width is the image width in pixels as recorded in EXIF metadata
+	Width int
+
+	// Height is the image height in pixels as recorded in EXIF metadata
+	Height int
+}
+
+// IsSupported indicates whether the specified filename has an extension of
+// a format that EXIF metadata can be read from.
+func IsSupported(filename string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// Read extracts EXIF metadata from a JPEG or PNG file. ErrNotFound is
+// returned if the file does not contain EXIF metadata.
+func Read(r io.Reader) (Metadata, error) {
+
+	br := bufio.NewReader(r)
+
+	signature, err := br.Peek(len(pngSignature))
+	if err != nil {
+		return Metadata{}, ErrNotFound
+	}
+
+	var tiff []byte
+	switch {
+	case bytes.Equal(signature, pngSignature):
+		tiff, err = findPNG(br)
+	case signature[0] == 0xFF && signature[1] == jpegMarkerStartOfImage:
+		tiff, err = findJPEG(br)
+	default:
+		return Metadata{}, ErrNotFound
+	}
+
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return parseTIFF(tiff)
+}
+
+// findJPEG returns the TIFF structure embedded in the APP1 segment of a
+// JPEG file.
+func findJPEG(r *bufio.Reader) ([]byte, error) {
+
+	// skip SOI marker
+	if _, err := r.Discard(2); err != nil {
+		return nil, ErrNotFound
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		if b != 0xFF {
+			return nil, fmt.Errorf("%w: unexpected JPEG marker prefix %#x", ErrInvalid, b)
+		}
+
+		// markers may be preceded by any number of fill bytes
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF {
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return nil, ErrNotFound
+		}
+
+		// EXIF metadata precedes the image data
+		if marker == jpegMarkerStartOfScan || marker == jpegMarkerEndOfImage {
+			return nil, ErrNotFound
+		}
+
+		// standalone markers without a length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, ErrNotFound
+		}
+		if length < 2 {
+			return nil, fmt.Errorf("%w: invalid JPEG segment length %d", ErrInvalid, length)
+		}
+
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, ErrNotFound
+		}
+
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			return payload[len(exifHeader):], nil
+		}
+	}
+}
+
+// findPNG returns the TIFF structure stored in the eXIf chunk of a PNG
+// file.
+func findPNG(r *bufio.Reader) ([]byte, error) {
+
+	if _, err := r.Discard(len(pngSignature)); err != nil {
+		return nil, ErrNotFound
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, ErrNotFound
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		chunkType := string(header[4:])
+
+		switch chunkType {
+		case "eXIf":
+			if length > maxPNGChunkSize {
+				return nil, fmt.Errorf("%w: eXIf chunk too large (%d bytes)", ErrInvalid, length)
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, ErrNotFound
+			}
+			return data, nil
+
+		case "IEND":
+			return nil, ErrNotFound
+		}
+
+		// skip chunk data and CRC
+		if _, err := io.CopyN(io.Discard, r, int64(length)+4); err != nil {
+			return nil, ErrNotFound
+		}
+	}
+}
+
+// parseTIFF decodes the tags of interest from a TIFF structure.
+func parseTIFF(tiff []byte) (Metadata, error) {
+
+	if len(tiff) < 8 {
+		return Metadata{}, fmt.Errorf("%w: TIFF header too short", ErrInvalid)
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return Metadata{}, fmt.Errorf("%w: unknown byte order %q", ErrInvalid, tiff[:2])
+	}
+
+	if order.Uint16(tiff[2:4]) != 42 {
+		return Metadata{}, fmt.Errorf("%w: invalid TIFF header", ErrInvalid)
+	}
+
+	var metadata Metadata
+	var dateTimeOriginal, dateTimeDigitized string
+
+	ifd0, err := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	metadata.Make = ifd0.ascii(tagMake)
+	metadata.Model = ifd0.ascii(tagModel)
+
+	if offset, ok := ifd0.uint(tagExifIFDPointer); ok {
+		exifIFD, err := readIFD(tiff, order, uint32(offset))
+		if err != nil {
+			return Metadata{}, err
+		}
+		dateTimeOriginal = exifIFD.ascii(tagDateTimeOriginal)
+		dateTimeDigitized = exifIFD.ascii(tagDateTimeDigitized)
+		if width, ok := exifIFD.uint(tagPixelXDimension); ok {
+			metadata.Width = width
+		}
+		if height, ok := exifIFD.uint(tagPixelYDimension); ok {
+			metadata.Height = height
+		}
+	}
+
+	for _, value := range []string{dateTimeOriginal, dateTimeDigitized} {
+		if captureTime, err := time.Parse(DateTimeLayout, value); err == nil {
+			metadata.CaptureTime = captureTime
+			break
+		}
+	}
+
+	return metadata, nil
+}
+
+// ifd is a decoded TIFF image file directory.
+type ifd struct {
+	tiff    []byte
+	order   binary.ByteOrder
+	entries map[uint16]ifdEntry
+}
+
+// ifdEntry is a single entry within an IFD.
+type ifdEntry struct {
+	fieldType uint16
+	count     uint32
+	value     []byte
+}
+
+// readIFD decodes the IFD found at the specified offset.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) (ifd, error) {
+
+	dir := ifd{tiff: tiff, order: order, entries: make(map[uint16]ifdEntry)}
+
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return dir, fmt.Errorf("%w: IFD offset out of range", ErrInvalid)
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	if count > maxIFDEntries {
+		return dir, fmt.Errorf("%w: too many IFD entries (%d)", ErrInvalid, count)
+	}
+
+	start := uint64(offset) + 2
+	if start+uint64(count)*12 > uint64(len(tiff)) {
+		return dir, fmt.Errorf("%w: IFD entries out of range", ErrInvalid)
+	}
+
+	for i := 0; i < count; i++ {
+		entry := tiff[start+uint64(i)*12:]
+		tag := order.Uint16(entry[0:2])
+		fieldType := order.Uint16(entry[2:4])
+		valueCount := order.Uint32(entry[4:8])
+
+		var size uint64
+		switch fieldType {
+		case tiffTypeASCII:
+			size = uint64(valueCount)
+		case tiffTypeShort:
+			size = uint64(valueCount) * 2
+		case tiffTypeLong:
+			size = uint64(valueCount) * 4
+		default:
+			// other types are not used
+			continue
+		}
+
+		value := entry[8:12]
+		if size > 4 {
+			valueOffset := uint64(order.Uint32(entry[8:12]))
+			if valueOffset+size > uint64(len(tiff)) {
+				continue
+			}
+			value = tiff[valueOffset : valueOffset+size]
+		}
+
+		dir.entries[tag] = ifdEntry{
+			fieldType: fieldType,
+			count:     valueCount,
+			value:     value,
+		}
+	}
+
+	return dir, nil
+}
+
+// ascii returns the string value of the specified tag, or an empty string
+// if not present.
+func (d ifd) ascii(tag uint16) string {
+
+	entry, ok := d.entries[tag]
+	if !ok || entry.fieldType != tiffTypeASCII {
+		return ""
+	}
+
+	value := entry.value
+	if uint64(len(value)) > uint64(entry.count) {
+		value = value[:entry.count]
+	}
+
+	// values are NUL terminated and may be padded
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(string(value))
+}
+
+// uint returns the numeric value of the specified SHORT or LONG tag.
+func (d ifd) uint(tag uint16) (int, bool) {
+
+	entry, ok := d.entries[tag]
+	if !ok || entry.count < 1 {
+		return 0, false
+	}
+
+	switch entry.fieldType {
+	case tiffTypeShort:
+		return int(d.order.Uint16(entry.value)), true
+	case tiffTypeLong:
+		return int(d.order.Uint32(entry.value)), true
+	}
+
+	return 0, false
+}
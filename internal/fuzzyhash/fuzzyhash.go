@@ -0,0 +1,608 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package fuzzyhash provides fuzzy hashing of documents and archives,
+// allowing files which are highly similar but not identical (e.g.,
+// successive edits of the same document) to be grouped.
+//
+// The context triggered piecewise hashing algorithm used by ssdeep is
+// implemented and hashes use the ssdeep format (blocksize:hash:hash). A
+// rolling hash over a small window of the content selects the points at
+// which the content is split into pieces, and each piece contributes one
+// character to the hash. Since the split points depend only on nearby
+// content, an edit changes only the characters of the affected pieces and
+// the hashes of similar files share most of their characters.
+package fuzzyhash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxScore is the similarity score of files whose hashes are identical.
+const MaxScore int = 100
+
+// MinFileSize is the size of the smallest file hashed. Hashes of smaller
+// files have too few pieces to be compared reliably.
+const MinFileSize int64 = 4096
+
+const (
+	rollingWindow  int    = 7
+	minBlockSize   uint32 = 3
+	spamSumLength  int    = 64
+	numBlockHashes int    = 31
+	hashPrime      uint32 = 0x01000193
+	hashInit       uint32 = 0x28021967
+	b64            string = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// ErrInvalidHash indicates that a value is not a hash in the ssdeep format.
+var ErrInvalidHash = errors.New("invalid fuzzy hash")
+
+// supportedExtensions are the file extensions of documents and archives
+// which are hashed.
+var supportedExtensions = map[string]bool{
+	// Documents
+	".txt":  true,
+	".md":   true,
+	".csv":  true,
+	".rtf":  true,
+	".tex":  true,
+	".htm":  true,
+	".html": true,
+	".xml":  true,
+	".json": true,
+	".pdf":  true,
+	".doc":  true,
+	".docx": true,
+	".xls":  true,
+	".xlsx": true,
+	".ppt":  true,
+	".pptx": true,
+	".odt":  true,
+	".ods":  true,
+	".odp":  true,
+
+	// Archives
+	".zip": true,
+	".tar": true,
+	".tgz": true,
+	".gz":  true,
+	".bz2": true,
+	".xz":  true,
+	".7z":  true,
+	".rar": true,
+}
+
+// IsSupported indicates whether the specified filename has the extension of
+// a supported document or archive format.
+func IsSupported(filename string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// Hash is a fuzzy hash in the ssdeep format.
+type Hash string
+
+// String returns the hash.
+func (h Hash) String() string {
+	return string(h)
+}
+
+// blockSize returns the block size used for the hash at the specified
+// index: the minimum block size doubled index times.
+func blockSize(index int) uint32 {
+	return minBlockSize << uint(index)
+}
+
+// rollingHash is the rolling hash over the last rollingWindow bytes which
+// selects the points at which the content is split into pieces.
+type rollingHash struct {
+	window     [rollingWindow]uint32
+	h1, h2, h3 uint32
+	n          int
+}
+
+// update adds the provided byte to the rolling hash, removing the oldest
+// byte in the window.
+func (r *rollingHash) update(c byte) {
+	r.h2 -= r.h1
+	r.h2 += uint32(rollingWindow) * uint32(c)
+
+	r.h1 += uint32(c)
+	r.h1 -= r.window[r.n]
+
+	r.window[r.n] = uint32(c)
+	r.n = (r.n + 1) % rollingWindow
+
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+// sum returns the current value of the rolling hash.
+func (r *rollingHash) sum() uint32 {
+	return r.h1 + r.h2 + r.h3
+}
+
+// sumHash adds the provided byte to the hash of the current piece.
+func sumHash(c byte, h uint32) uint32 {
+	return (h * hashPrime) ^ uint32(c)
+}
+
+// blockHash is the hash of the content for a single block size. The half
+// hash is limited to half the length of the hash, as used for the second
+// part of the final hash.
+type blockHash struct {
+	h, halfH   uint32
+	digest     [spamSumLength]byte
+	halfDigest byte
+	dLen       int
+}
+
+// state is the state of a hash in progress. Hashes for several block sizes
+// are calculated at once so that the content is read only once; block
+// sizes which would produce hashes that are too long are dropped as more
+// content is read.
+type state struct {
+	bh        [numBlockHashes]blockHash
+	bhStart   int
+	bhEnd     int
+	totalSize uint64
+	roll      rollingHash
+}
+
+// newState returns the state of a new hash.
+func newState() *state {
+	s := state{bhEnd: 1}
+	s.bh[0].h = hashInit
+	s.bh[0].halfH = hashInit
+	return &s
+}
+
+// tryForkBlockHash starts the hash for the next larger block size from the
+// hash of the largest block size in progress.
+func (s *state) tryForkBlockHash() {
+	if s.bhEnd >= numBlockHashes {
+		return
+	}
+
+	prev := &s.bh[s.bhEnd-1]
+	next := &s.bh[s.bhEnd]
+	next.h = prev.h
+	next.halfH = prev.halfH
+	next.halfDigest = 0
+	next.dLen = 0
+
+	s.bhEnd++
+}
+
+// tryReduceBlockHash drops the hash of the smallest block size in progress
+// once it can no longer be selected for the final hash.
+func (s *state) tryReduceBlockHash() {
+	if s.bhEnd-s.bhStart < 2 {
+		return
+	}
+
+	if uint64(blockSize(s.bhStart))*uint64(spamSumLength) >= s.totalSize {
+		return
+	}
+
+	if s.bh[s.bhStart+1].dLen < spamSumLength/2 {
+		return
+	}
+
+	s.bhStart++
+}
+
+// update adds the provided content to the hash.
+func (s *state) update(data []byte) {
+
+	for _, c := range data {
+		s.totalSize++
+
+		s.roll.update(c)
+		h := s.roll.sum()
+
+		for i := s.bhStart; i < s.bhEnd; i++ {
+			s.bh[i].h = sumHash(c, s.bh[i].h)
+			s.bh[i].halfH = sumHash(c, s.bh[i].halfH)
+		}
+
+		// A piece boundary for a block size is also a boundary for every
+		// smaller block size.
+		for i := s.bhStart; i < s.bhEnd; i++ {
+			bs := blockSize(i)
+			if h%bs != bs-1 {
+				break
+			}
+
+			bh := &s.bh[i]
+			if bh.dLen == 0 {
+				s.tryForkBlockHash()
+			}
+
+			bh.digest[bh.dLen] = b64[bh.h%64]
+			bh.halfDigest = b64[bh.halfH%64]
+
+			if bh.dLen < spamSumLength-1 {
+				bh.dLen++
+				bh.digest[bh.dLen] = 0
+				bh.h = hashInit
+				if bh.dLen < spamSumLength/2 {
+					bh.halfH = hashInit
+					bh.halfDigest = 0
+				}
+				continue
+			}
+
+			s.tryReduceBlockHash()
+		}
+	}
+}
+
+// digest returns the final hash of the content read, selecting the
+// smallest block size which produces a hash of at least half the maximum
+// length.
+func (s *state) digest() (Hash, error) {
+
+	bi := s.bhStart
+	h := s.roll.sum()
+
+	for uint64(blockSize(bi))*uint64(spamSumLength) < s.totalSize {
+		bi++
+		if bi >= numBlockHashes {
+			return "", fmt.Errorf("%w: content too large", ErrInvalidHash)
+		}
+	}
+
+	for bi >= s.bhEnd {
+		bi--
+	}
+
+	for bi > s.bhStart && s.bh[bi].dLen < spamSumLength/2 {
+		bi--
+	}
+
+	var b strings.Builder
+	b.WriteString(strconv.FormatUint(uint64(blockSize(bi)), 10))
+	b.WriteByte(':')
+
+	bh := &s.bh[bi]
+	b.Write(bh.digest[:bh.dLen])
+	switch {
+	case h != 0:
+		b.WriteByte(b64[bh.h%64])
+	case bh.digest[bh.dLen] != 0:
+		b.WriteByte(bh.digest[bh.dLen])
+	}
+
+	b.WriteByte(':')
+
+	switch {
+	case bi < s.bhEnd-1:
+		bh = &s.bh[bi+1]
+		length := bh.dLen
+		if length > spamSumLength/2-1 {
+			length = spamSumLength/2 - 1
+		}
+		b.Write(bh.digest[:length])
+
+		switch {
+		case h != 0:
+			b.WriteByte(b64[bh.halfH%64])
+		case bh.halfDigest != 0:
+			b.WriteByte(bh.halfDigest)
+		}
+
+	case h != 0:
+		b.WriteByte(b64[bh.h%64])
+	}
+
+	return Hash(b.String()), nil
+}
+
+// Sum reads the content provided by the reader and returns its fuzzy hash.
+func Sum(r io.Reader) (Hash, error) {
+
+	s := newState()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		s.update(buf[:n])
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+
+	return s.digest()
+}
+
+// parsedHash is a hash split into its block size and the hashes for that
+// block size and for double that block size, with runs of more than three
+// identical characters shortened to three.
+type parsedHash struct {
+	blockSize uint32
+	first     string
+	second    string
+}
+
+// parse splits the hash into its components.
+func (h Hash) parse() (parsedHash, error) {
+
+	parts := strings.SplitN(string(h), ":", 3)
+	if len(parts) != 3 {
+		return parsedHash{}, fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	}
+
+	bs, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || bs == 0 {
+		return parsedHash{}, fmt.Errorf("%w: %q has invalid block size", ErrInvalidHash, h)
+	}
+
+	// Any trailing ",filename" (as written by ssdeep) is ignored.
+	second, _, _ := strings.Cut(parts[2], ",")
+
+	return parsedHash{
+		blockSize: uint32(bs),
+		first:     eliminateSequences(parts[1]),
+		second:    eliminateSequences(second),
+	}, nil
+}
+
+// eliminateSequences shortens runs of more than three identical characters
+// to three, since long runs (e.g., from padding) carry little information
+// and would inflate scores.
+func eliminateSequences(s string) string {
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if i >= 3 && s[i] == s[i-1] && s[i] == s[i-2] && s[i] == s[i-3] {
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// hasCommonSubstring indicates whether the provided strings share a
+// substring of at least rollingWindow characters. Hashes without one are
+// not compared, as any resemblance is likely coincidental.
+func hasCommonSubstring(s1 string, s2 string) bool {
+
+	if len(s1) < rollingWindow || len(s2) < rollingWindow {
+		return false
+	}
+
+	for i := 0; i+rollingWindow <= len(s1); i++ {
+		if strings.Contains(s2, s1[i:i+rollingWindow]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editDistance returns the number of insertions and deletions needed to
+// change s1 into s2; a substitution counts as an insertion and a deletion.
+func editDistance(s1 string, s2 string) int {
+
+	prev := make([]int, len(s2)+1)
+	curr := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s1); i++ {
+		curr[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := prev[j-1]
+			if s1[i-1] != s2[j-1] {
+				cost += 2
+			}
+			if prev[j]+1 < cost {
+				cost = prev[j] + 1
+			}
+			if curr[j-1]+1 < cost {
+				cost = curr[j-1] + 1
+			}
+			curr[j] = cost
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(s2)]
+}
+
+// scoreStrings returns the similarity score (0-100) of two hashes for the
+// same block size. Scores of hashes for small block sizes are capped, since
+// short hashes of small files match more easily.
+func scoreStrings(s1 string, s2 string, bs uint32) int {
+
+	if len(s1) > spamSumLength || len(s2) > spamSumLength {
+		return 0
+	}
+
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+
+	score := editDistance(s1, s2)
+	score = score * spamSumLength / (len(s1) + len(s2))
+	score = 100 * score / spamSumLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	if bs >= uint32((99+rollingWindow)/rollingWindow)*minBlockSize {
+		return score
+	}
+
+	shortest := len(s1)
+	if len(s2) < shortest {
+		shortest = len(s2)
+	}
+
+	if limit := int(bs/minBlockSize) * shortest; score > limit {
+		score = limit
+	}
+
+	return score
+}
+
+// Compare returns the similarity score of two hashes: 0 for files with no
+// meaningful similarity up to MaxScore for files whose hashes match. Only
+// hashes of files whose block sizes are equal or differ by a factor of two
+// can be compared; the score of other hashes is 0.
+func Compare(h1 Hash, h2 Hash) (int, error) {
+
+	p1, err := h1.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	p2, err := h2.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case p1.blockSize == p2.blockSize:
+		if p1.first == p2.first && p1.second == p2.second {
+			return MaxScore, nil
+		}
+
+		score := scoreStrings(p1.first, p2.first, p1.blockSize)
+		if second := scoreStrings(p1.second, p2.second, p1.blockSize*2); second > score {
+			score = second
+		}
+		return score, nil
+
+	case p1.blockSize == p2.blockSize*2:
+		return scoreStrings(p1.first, p2.second, p1.blockSize), nil
+
+	case p2.blockSize == p1.blockSize*2:
+		return scoreStrings(p1.second, p2.first, p2.blockSize), nil
+
+	default:
+		return 0, nil
+	}
+}
+
+// Group returns the indexes of hashes grouped by similarity. Hashes whose
+// similarity score is at least minScore are placed in the same group, as
+// are hashes linked through a chain of similar hashes. Only groups with two
+// or more entries are returned. Invalid hashes are not grouped.
+//
+// Since similar hashes must share a substring of rollingWindow characters
+// for the same block size, only hashes sharing such a substring are
+// compared.
+func Group(hashes []Hash, minScore int) [][]int {
+
+	if minScore < 1 {
+		minScore = 1
+	}
+	if minScore > MaxScore {
+		minScore = MaxScore
+	}
+
+	type gramKey struct {
+		blockSize uint32
+		gram      string
+	}
+
+	index := make(map[gramKey][]int)
+	for i, hash := range hashes {
+		p, err := hash.parse()
+		if err != nil {
+			continue
+		}
+
+		seen := make(map[gramKey]bool)
+		add := func(bs uint32, s string) {
+			for j := 0; j+rollingWindow <= len(s); j++ {
+				key := gramKey{blockSize: bs, gram: s[j : j+rollingWindow]}
+				if !seen[key] {
+					seen[key] = true
+					index[key] = append(index[key], i)
+				}
+			}
+		}
+		add(p.blockSize, p.first)
+		add(p.blockSize*2, p.second)
+	}
+
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	union := func(i, j int) {
+		rootI, rootJ := find(i), find(j)
+		if rootI != rootJ {
+			parent[rootJ] = rootI
+		}
+	}
+
+	compared := make(map[[2]int]bool)
+	for _, candidates := range index {
+		for x := 0; x < len(candidates); x++ {
+			for y := x + 1; y < len(candidates); y++ {
+				i, j := candidates[x], candidates[y]
+
+				pair := [2]int{i, j}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				if find(i) == find(j) {
+					continue
+				}
+
+				score, err := Compare(hashes[i], hashes[j])
+				if err == nil && score >= minScore {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range hashes {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+
+	results := make([][]int, 0, len(groups))
+	for i := range hashes {
+		group, ok := groups[i]
+		if !ok || len(group) < 2 {
+			continue
+		}
+		results = append(results, group)
+	}
+
+	return results
+}
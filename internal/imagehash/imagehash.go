@@ -0,0 +1,252 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package imagehash provides perceptual hashing of images, allowing visually
+// identical images to be grouped even when their file content differs
+// (e.g., re-encoded, resized or with metadata stripped).
+//
+// The difference hash (dHash) algorithm is used: the image is reduced to a
+// 9x8 grayscale thumbnail and each bit of the 64-bit hash records whether a
+// pixel is brighter than its right-hand neighbor. Hashes of visually similar
+// images differ in only a few bits.
+package imagehash
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"path/filepath"
+	"strings"
+
+	// Register decoders for supported image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxDistance is the largest supported Hamming distance between hashes of
+// images considered visually identical. Larger values group images which
+// are merely similar.
+const MaxDistance int = 16
+
+const (
+	hashWidth  int = 9
+	hashHeight int = 8
+)
+
+// supportedExtensions are the file extensions of image formats that can be
+// decoded.
+var supportedExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// Hash is a 64-bit perceptual hash of an image.
+type Hash uint64
+
+// String returns the hash as a hex-encoded value.
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Distance returns the number of bits that differ between two hashes.
+func (h Hash) Distance(other Hash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// IsSupported indicates whether the specified filename has an extension of
+// a supported image format.
+func IsSupported(filename string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// DHash decodes the image provided by the reader and returns its difference
+// hash.
+func DHash(r io.Reader) (Hash, error) {
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return dHash(img), nil
+}
+
+// dHash calculates the difference hash for a decoded image.
+func dHash(img image.Image) Hash {
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Reduce the image to a thumbnail by averaging the luminance of all
+	// pixels that fall within each thumbnail cell.
+	var sums [hashHeight][hashWidth]uint64
+	var counts [hashHeight][hashWidth]uint64
+
+	luma := lumaFunc(img)
+
+	for y := 0; y < height; y++ {
+		cellY := y * hashHeight / height
+		for x := 0; x < width; x++ {
+			cellX := x * hashWidth / width
+			sums[cellY][cellX] += uint64(luma(bounds.Min.X+x, bounds.Min.Y+y))
+			counts[cellY][cellX]++
+		}
+	}
+
+	var thumbnail [hashHeight][hashWidth]uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth; x++ {
+			if counts[y][x] > 0 {
+				thumbnail[y][x] = sums[y][x] / counts[y][x]
+			}
+		}
+	}
+
+	// Images smaller than the thumbnail leave some cells empty; fill them
+	// from the nearest populated cell to the left (or above).
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth; x++ {
+			if counts[y][x] > 0 {
+				continue
+			}
+			switch {
+			case x > 0:
+				thumbnail[y][x] = thumbnail[y][x-1]
+			case y > 0:
+				thumbnail[y][x] = thumbnail[y-1][x]
+			}
+		}
+	}
+
+	var hash Hash
+	var bit uint
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			if thumbnail[y][x] > thumbnail[y][x+1] {
+				hash |= 1 << bit
+			}
+			bit++
+		}
+	}
+
+	return hash
+}
+
+// lumaFunc returns a function providing the 16-bit luminance of the pixel
+// at the specified coordinates, using direct access to the luma plane for
+// common image types.
+func lumaFunc(img image.Image) func(x, y int) uint32 {
+
+	switch i := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) uint32 {
+			return uint32(i.Y[i.YOffset(x, y)]) * 0x101
+		}
+
+	case *image.Gray:
+		return func(x, y int) uint32 {
+			return uint32(i.Pix[i.PixOffset(x, y)]) * 0x101
+		}
+
+	default:
+		return func(x, y int) uint32 {
+			r, g, b, _ := img.At(x, y).RGBA()
+
+			// ITU-R BT.601 luma coefficients, as used by color.GrayModel
+			return (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+		}
+	}
+}
+
+// Group returns the indexes of hashes grouped by similarity. Hashes within
+// the specified Hamming distance of each other are placed in the same
+// group, as are hashes linked through a chain of similar hashes. Only
+// groups with two or more entries are returned.
+func Group(hashes []Hash, maxDistance int) [][]int {
+
+	if maxDistance < 0 {
+		maxDistance = 0
+	}
+	if maxDistance > MaxDistance {
+		maxDistance = MaxDistance
+	}
+
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	union := func(i, j int) {
+		rootI, rootJ := find(i), find(j)
+		if rootI != rootJ {
+			parent[rootJ] = rootI
+		}
+	}
+
+	// Split each hash into maxDistance+1 chunks. Per the pigeonhole
+	// principle, hashes differing in at most maxDistance bits share at
+	// least one identical chunk, so only hashes sharing a chunk need to be
+	// compared.
+	chunks := maxDistance + 1
+	for chunk := 0; chunk < chunks; chunk++ {
+
+		start := chunk * 64 / chunks
+		end := (chunk + 1) * 64 / chunks
+		mask := uint64(1)<<uint(end-start) - 1
+
+		buckets := make(map[uint64][]int)
+		for i, hash := range hashes {
+			key := (uint64(hash) >> uint(start)) & mask
+			buckets[key] = append(buckets[key], i)
+		}
+
+		for _, bucket := range buckets {
+			for a := 0; a < len(bucket); a++ {
+				for b := a + 1; b < len(bucket); b++ {
+					i, j := bucket[a], bucket[b]
+					if find(i) == find(j) {
+						continue
+					}
+					if hashes[i].Distance(hashes[j]) <= maxDistance {
+						union(i, j)
+					}
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range hashes {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	var groups [][]int
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+
+	return groups
+}
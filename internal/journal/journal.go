@@ -0,0 +1,190 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package journal provides a journal recording each backup and removal
+// performed by the prune subcommand before it is attempted and again once it
+// completes, allowing an interrupted run to be resumed (or rolled back)
+// without comparing the input files against the filesystem.
+package journal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Actions recorded in the journal
+const (
+	ActionRun    string = "run"
+	ActionBackup string = "backup"
+	ActionRemove string = "remove"
+)
+
+// States recorded in the journal
+const (
+	StateStarted   string = "started"
+	StateCompleted string = "completed"
+	StateFailed    string = "failed"
+)
+
+// Column header names for the journal
+const (
+	TimestampColumnHeaderName   string = "timestamp"
+	ActionColumnHeaderName      string = "action"
+	StateColumnHeaderName       string = "state"
+	PathColumnHeaderName        string = "path"
+	SizeInBytesColumnHeaderName string = "size_in_bytes"
+	ChecksumColumnHeaderName    string = "checksum"
+	BackupPathColumnHeaderName  string = "backup_path"
+	ErrorColumnHeaderName       string = "error"
+)
+
+// headerRow lists the journal columns in the order written.
+var headerRow = []string{
+	TimestampColumnHeaderName,
+	ActionColumnHeaderName,
+	StateColumnHeaderName,
+	PathColumnHeaderName,
+	SizeInBytesColumnHeaderName,
+	ChecksumColumnHeaderName,
+	BackupPathColumnHeaderName,
+	ErrorColumnHeaderName,
+}
+
+// Entry represents a single change in the state of an action.
+type Entry struct {
+
+	// Time is when the state of the action changed
+	Time time.Time
+
+	// Action is the action (e.g., run, backup, remove)
+	Action string
+
+	// State is the new state of the action (e.g., started, completed)
+	State string
+
+	// Path is the fully-qualified path to the file (for run entries, the
+	// input files of the run)
+	Path string
+
+	// SizeInBytes is the size of the file in bytes
+	SizeInBytes int64
+
+	// Checksum is the checksum recorded for the file
+	Checksum string
+
+	// BackupPath is the fully-qualified path to the backup copy of the file
+	// (if any)
+	BackupPath string
+
+	// Err is the error message for failed actions
+	Err string
+}
+
+// Journal is a journal file in CSV format. Entries are appended and synced
+// to disk as they are recorded so that the journal remains accurate if the
+// application (or system) is interrupted.
+type Journal struct {
+	filename string
+	file     *os.File
+	w        *csv.Writer
+}
+
+// Open opens the specified journal file for appending, creating the file
+// (and writing the header row) if it does not already exist.
+func Open(filename string) (*Journal, error) {
+
+	file, err := os.OpenFile(
+		filepath.Clean(filename),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal %q: %w", filename, err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to retrieve details for journal %q: %w", filename, err)
+	}
+
+	j := Journal{
+		filename: filename,
+		file:     file,
+		w:        csv.NewWriter(file),
+	}
+
+	if fileInfo.Size() == 0 {
+		if err := j.write(headerRow); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	return &j, nil
+}
+
+// write writes, flushes and syncs the provided row.
+func (j *Journal) write(row []string) error {
+
+	if err := j.w.Write(row); err != nil {
+		return fmt.Errorf("error writing record to journal %q: %w", j.filename, err)
+	}
+
+	j.w.Flush()
+	if err := j.w.Error(); err != nil {
+		return fmt.Errorf("error writing record to journal %q: %w", j.filename, err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("error syncing journal %q: %w", j.filename, err)
+	}
+
+	return nil
+}
+
+// Record appends the provided entry to the journal. A nil Journal discards
+// the entry, allowing callers to record entries unconditionally.
+func (j *Journal) Record(entry Entry) error {
+
+	if j == nil {
+		return nil
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	return j.write([]string{
+		entry.Time.Format(time.RFC3339),
+		entry.Action,
+		entry.State,
+		entry.Path,
+		strconv.FormatInt(entry.SizeInBytes, 10),
+		entry.Checksum,
+		entry.BackupPath,
+		entry.Err,
+	})
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+
+	if j == nil {
+		return nil
+	}
+
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing journal %q: %w", j.filename, err)
+	}
+
+	return nil
+}
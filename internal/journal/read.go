@@ -0,0 +1,197 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package journal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileState is the progress recorded in the journal for a single file.
+type FileState struct {
+
+	// BackupPath is the path to the verified backup copy of the file, if a
+	// backup completed
+	BackupPath string
+
+	// RemoveStarted indicates whether removal of the file was started
+	RemoveStarted bool
+
+	// Removed indicates whether removal of the file completed
+	Removed bool
+}
+
+// Read reads all entries from the specified journal file. Columns are
+// located using the header row. A final row cut short (e.g., when the
+// system lost power while it was written) is skipped.
+func Read(filename string) ([]Entry, error) {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header row of journal %q: %w", filename, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		columns[name] = index
+	}
+
+	for _, name := range headerRow {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("journal %q is missing the %q column", filename, name)
+		}
+	}
+
+	var entries []Entry
+	rowNum := 1
+	for {
+		rowNum++
+
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal %q: %w", filename, err)
+		}
+
+		if len(row) < len(header) {
+			log.Printf("Skipping incomplete row %d of journal %q\n", rowNum, filename)
+			continue
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, row[columns[TimestampColumnHeaderName]])
+		if err != nil {
+			return nil, fmt.Errorf("row %d of journal %q has invalid timestamp: %w", rowNum, filename, err)
+		}
+
+		size, err := strconv.ParseInt(row[columns[SizeInBytesColumnHeaderName]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d of journal %q has invalid size: %w", rowNum, filename, err)
+		}
+
+		entries = append(entries, Entry{
+			Time:        timestamp,
+			Action:      row[columns[ActionColumnHeaderName]],
+			State:       row[columns[StateColumnHeaderName]],
+			Path:        row[columns[PathColumnHeaderName]],
+			SizeInBytes: size,
+			Checksum:    row[columns[ChecksumColumnHeaderName]],
+			BackupPath:  row[columns[BackupPathColumnHeaderName]],
+			Err:         row[columns[ErrorColumnHeaderName]],
+		})
+	}
+
+	return entries, nil
+}
+
+// Incomplete returns the entries recorded since the last run which
+// completed, covering the run which was interrupted (or which stopped due
+// to an error) and any attempts to resume it. False is returned if no run
+// has been started since the last run completed.
+func Incomplete(entries []Entry) ([]Entry, bool) {
+
+	start := 0
+	for i, entry := range entries {
+		if entry.Action == ActionRun && entry.State == StateCompleted {
+			start = i + 1
+		}
+	}
+
+	for _, entry := range entries[start:] {
+		if entry.Action == ActionRun && entry.State == StateStarted {
+			return entries[start:], true
+		}
+	}
+
+	return nil, false
+}
+
+// FileStates returns the progress recorded by the provided entries for each
+// file, keyed by path.
+func FileStates(entries []Entry) map[string]FileState {
+
+	states := make(map[string]FileState)
+
+	for _, entry := range entries {
+		if entry.Action == ActionRun {
+			continue
+		}
+
+		state := states[entry.Path]
+
+		switch {
+		case entry.Action == ActionBackup && entry.State == StateCompleted:
+			state.BackupPath = entry.BackupPath
+
+		case entry.Action == ActionRemove && entry.State == StateStarted:
+			state.RemoveStarted = true
+
+		case entry.Action == ActionRemove && entry.State == StateCompleted:
+			state.Removed = true
+		}
+
+		states[entry.Path] = state
+	}
+
+	return states
+}
+
+// LastRun returns the entries recorded by the last run. If a run has been
+// started since the last run which completed, the entries returned by
+// Incomplete are returned. Otherwise, the entries recorded since the
+// previous run completed are returned, covering the run which completed
+// last along with any interrupted attempts which it resumed. Nil is returned
+// if no run is recorded.
+func LastRun(entries []Entry) []Entry {
+
+	if incomplete, ok := Incomplete(entries); ok {
+		return incomplete
+	}
+
+	last, previous := -1, -1
+	for i, entry := range entries {
+		if entry.Action == ActionRun && entry.State == StateCompleted {
+			previous, last = last, i
+		}
+	}
+
+	if last == -1 {
+		return nil
+	}
+
+	return entries[previous+1 : last+1]
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/atc0005/bridge/internal/diskspace"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// CSVAllocatedSizeInBytesColumnHeaderName is the name of the column
+// recording the space allocated on disk to each file.
+const CSVAllocatedSizeInBytesColumnHeaderName = "allocated_size_in_bytes"
+
+// Allocated returns the space (in bytes) allocated on disk to the file if
+// recorded (see UpdateAllocatedSizes), otherwise the length of the file.
+// Sparse files and files on compressed filesystems may use less space than
+// their length, while other files are usually rounded up to a whole number
+// of filesystem blocks.
+func (fm FileMatch) Allocated() int64 {
+	if fm.allocatedRecorded {
+		return fm.allocated
+	}
+
+	return fm.size
+}
+
+// allocatedField returns the space allocated on disk to the file formatted
+// for use in CSV output, or an empty string if it has not been recorded.
+func (fm FileMatch) allocatedField() string {
+	if !fm.allocatedRecorded {
+		return ""
+	}
+
+	return strconv.FormatInt(fm.allocated, 10)
+}
+
+// WastedSpace returns the space (in bytes) reclaimed by removing all files
+// in the duplicate file set other than the file marked as the original to
+// keep (see UpdateKeep), or the file whose path sorts first if none are
+// marked.
+func (fm FileMatches) WastedSpace() int64 {
+
+	if len(fm) == 0 {
+		return 0
+	}
+
+	var wastedSpace int64
+	kept := fm.keptIndex()
+
+	for i, file := range fm {
+		if i == kept {
+			continue
+		}
+		wastedSpace += file.Allocated()
+	}
+
+	return wastedSpace
+}
+
+// UpdateAllocatedSizes records the space allocated on disk to each file in
+// the duplicate file set. Files outside of the OS filesystem (e.g., S3
+// objects) are skipped. The number of errors ignored (as requested) is also
+// returned.
+func (fm FileMatches) UpdateAllocatedSizes(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for index, file := range fm {
+
+		if file.FS != nil {
+			continue
+		}
+
+		allocated, err := diskspace.Allocated(file.osPath())
+		if err != nil {
+
+			if !errreport.Ignore(ignoreErrors, err) {
+				return ignoredErrors, err
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(file.FullPath, errreport.OperationMetadata, err)
+
+			continue
+		}
+
+		fm[index].allocated = allocated
+		fm[index].allocatedRecorded = true
+	}
+
+	return ignoredErrors, nil
+}
+
+// UpdateAllocatedSizes records the space allocated on disk to each file in
+// the index. The number of errors ignored (as requested) is also returned.
+func (fi FileChecksumIndex) UpdateAllocatedSizes(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		ignored, err := fileMatches.UpdateAllocatedSizes(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return ignoredErrors, err
+		}
+	}
+
+	return ignoredErrors, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/atc0005/bridge/internal/audiotags"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// AudioTags returns the tags of an MP3 or FLAC file. ID3v1 tags are only
+// read from files supporting random access (e.g., local files).
+func (fm FileMatch) AudioTags() (audiotags.Tags, error) {
+
+	var tags audiotags.Tags
+
+	err := fm.read(func(r io.Reader) error {
+		var err error
+		tags, err = audiotags.Read(r)
+		if err != nil {
+			return fmt.Errorf("failed to read tags from %q: %w", fm.FullPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = fm.read(func(r io.Reader) error {
+		ra, ok := r.(io.ReaderAt)
+		if !ok || fm.Size() < 128 {
+			return nil
+		}
+
+		tail := make([]byte, 128)
+		if _, err := ra.ReadAt(tail, fm.Size()-128); err != nil {
+			return fmt.Errorf("failed to read ID3v1 tag from %q: %w", fm.FullPath, err)
+		}
+		tags.Merge(audiotags.ReadID3v1(tail))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// UpdateTagDifferences records the tags which differ between audio files in
+// each content-equal duplicate file set. The number of errors ignored (as
+// requested) is also returned.
+func (fi FileChecksumIndex) UpdateTagDifferences(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for checksum, fileMatches := range fi {
+		if !checksum.IsContentChecksum() {
+			continue
+		}
+
+		tags := make([]audiotags.Tags, len(fileMatches))
+		audioFiles := true
+		for index, file := range fileMatches {
+			if !audiotags.IsSupported(file.Name()) {
+				audioFiles = false
+				break
+			}
+
+			fileTags, err := file.AudioTags()
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return ignoredErrors, err
+				}
+
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(file.FullPath, errreport.OperationMetadata, err)
+
+				fileTags = make(audiotags.Tags)
+			}
+
+			tags[index] = fileTags
+		}
+
+		if !audioFiles {
+			continue
+		}
+
+		differences := audiotags.Differences(tags...)
+		if len(differences) == 0 {
+			continue
+		}
+
+		for index := range fileMatches {
+			fileMatches[index].TagDifferences = tags[index].Format(differences)
+		}
+	}
+
+	return ignoredErrors, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/atc0005/bridge/internal/bytecmp"
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// maxOpenFiles is the largest group of identically sized files compared
+// by reading all files concurrently. Larger groups are compared against one
+// file from each set found so far instead, limiting the number of open
+// files at the cost of reading some files more than once.
+const maxOpenFiles = 64
+
+// NewByteComparisonIndex confirms duplicate files within each group of
+// identically sized files in the index by comparing file content byte for
+// byte instead of generating checksums. Files in each confirmed duplicate
+// file set are assigned a byte comparison identifier (see
+// checksums.ByteComparisonID) in place of a checksum. The number of errors
+// ignored (as requested) is also returned.
+func NewByteComparisonIndex(fi FileSizeIndex, ignoreErrors bool) (FileChecksumIndex, int, error) {
+
+	fileChecksumIndex := make(FileChecksumIndex)
+	var ignoredErrors int
+
+	for size, fileMatches := range fi {
+
+		groups, ignored, err := fileMatches.partitionBytes(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return nil, ignoredErrors, err
+		}
+
+		var setNumber int
+		for _, group := range groups {
+			if len(group) < 2 {
+				continue
+			}
+
+			setNumber++
+			id := checksums.ByteComparisonID(size, setNumber)
+			for index := range group {
+				group[index].Checksum = id
+			}
+
+			fileChecksumIndex[id] = group
+		}
+	}
+
+	return fileChecksumIndex, ignoredErrors, nil
+}
+
+// partitionBytes groups identically sized files by content, comparing file
+// content byte for byte. Files are sorted by path so that groups (and so
+// byte comparison identifiers) are assigned consistently.
+func (fm FileMatches) partitionBytes(ignoreErrors bool) ([]FileMatches, int, error) {
+
+	files := make(FileMatches, len(fm))
+	copy(files, fm)
+	files.SortByPath()
+
+	if len(files) > maxOpenFiles {
+		return files.partitionBytesByRepresentative(ignoreErrors)
+	}
+
+	var ignoredErrors int
+
+	ignore := func(err error) error {
+		if !errreport.Ignore(ignoreErrors, err) {
+			return err
+		}
+
+		// WARN
+		log.Println("Error encountered:", err)
+		log.Println("Ignoring error as requested")
+		ignoredErrors++
+		errreport.Record("", errreport.OperationCompare, err)
+
+		return nil
+	}
+
+	readers := make([]io.Reader, 0, len(files))
+	opened := make(FileMatches, 0, len(files))
+	closers := make([]io.Closer, 0, len(files))
+
+	defer func() {
+		for index, closer := range closers {
+			if err := closer.Close(); err != nil {
+				log.Printf(
+					"error occurred closing file %q: %v",
+					opened[index].FullPath,
+					err,
+				)
+			}
+		}
+	}()
+
+	for _, file := range files {
+		f, err := file.open()
+		if err != nil {
+			if err := ignore(fmt.Errorf("failed to open %q: %w", file.FullPath, err)); err != nil {
+				return nil, ignoredErrors, err
+			}
+			continue
+		}
+
+		readers = append(readers, f)
+		opened = append(opened, file)
+		closers = append(closers, f)
+	}
+
+	indexGroups, errs := bytecmp.Partition(readers)
+
+	for index, err := range errs {
+		if err == nil {
+			continue
+		}
+		if err := ignore(fmt.Errorf("failed to read %q: %w", opened[index].FullPath, err)); err != nil {
+			return nil, ignoredErrors, err
+		}
+	}
+
+	groups := make([]FileMatches, 0, len(indexGroups))
+	for _, indexGroup := range indexGroups {
+		group := make(FileMatches, 0, len(indexGroup))
+		for _, index := range indexGroup {
+			group = append(group, opened[index])
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, ignoredErrors, nil
+}
+
+// partitionBytesByRepresentative groups identically sized files by content,
+// comparing each file with the first file of each group found so far. Groups
+// are listed in the order of their first file.
+func (fm FileMatches) partitionBytesByRepresentative(ignoreErrors bool) ([]FileMatches, int, error) {
+
+	var groups []FileMatches
+	var ignoredErrors int
+
+	for _, file := range fm {
+
+		matched := -1
+		var failed bool
+
+		for index, group := range groups {
+			same, err := group[0].SameBytes(file)
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return nil, ignoredErrors, err
+				}
+
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(file.FullPath, errreport.OperationCompare, err)
+
+				failed = true
+				break
+			}
+
+			if same {
+				matched = index
+				break
+			}
+		}
+
+		switch {
+		case failed:
+			continue
+		case matched >= 0:
+			groups[matched] = append(groups[matched], file)
+		default:
+			groups = append(groups, FileMatches{file})
+		}
+	}
+
+	return groups, ignoredErrors, nil
+}
+
+// GetByteComparisonSetsCount returns the number of duplicate file sets
+// confirmed by comparing file content byte for byte instead of checksums.
+func (fi FileChecksumIndex) GetByteComparisonSetsCount() int {
+
+	var sets int
+
+	for checksum := range fi {
+		if checksum.IsByteComparison() {
+			sets++
+		}
+	}
+
+	return sets
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"log"
+	"os"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// Changed indicates whether the size or modification time of the file
+// differs from the values recorded when the file was found. Files which are
+// still being written (e.g., within an active download folder) would
+// otherwise be grouped using a checksum of content which no longer matches
+// the recorded size. Objects stored in S3 are not checked; they are replaced
+// as a whole instead of modified in place.
+func (fm FileMatch) Changed() (bool, error) {
+
+	if fm.FS != nil {
+		return false, nil
+	}
+
+	info, err := os.Lstat(fm.osPath())
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size() != fm.size || !info.ModTime().Equal(fm.ModTime()), nil
+}
+
+// skipChanged indicates whether the file should be skipped instead of
+// generating a checksum because it changed since it was found (see Changed),
+// marking it as changed if so. Errors checking the file are left to be
+// reported when the file is read.
+func (fm *FileMatch) skipChanged() bool {
+
+	changed, err := fm.Changed()
+	if err != nil || !changed {
+		return false
+	}
+
+	// WARN
+	log.Printf("Skipping file %q; size or modification time changed since it was found", fm.FullPath)
+	fm.changed = true
+
+	return true
+}
+
+// skippedFiles returns the files skipped when generating checksums because
+// they changed since they were found (see Changed) or were in use by
+// another process (see InUseFiles).
+func (fm FileMatches) skippedFiles() FileMatches {
+
+	var skipped FileMatches
+
+	for _, file := range fm {
+		if file.changed || file.inUse {
+			skipped = append(skipped, file)
+		}
+	}
+
+	return skipped
+}
+
+// ChangedFiles returns the files in the index skipped when generating
+// checksums because they changed since they were found (see Changed).
+func (fi FileSizeIndex) ChangedFiles() FileMatches {
+
+	var changed FileMatches
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if file.changed {
+				changed = append(changed, file)
+			}
+		}
+	}
+
+	return changed
+}
+
+// RetryChangedFiles generates checksums (and additional hashes using the
+// specified algorithm, if any) for files skipped by UpdateChecksumsWith
+// because they changed since they were found, recording their current size
+// and modification time first. Files which changed again in the meantime
+// remain skipped. Retried files are only grouped with other files whose
+// checksums were generated; files of a new size which no other evaluated
+// file shared are not reported. The number of errors ignored (as
+// requested) is returned along with any error that was not ignored.
+func (fi FileSizeIndex) RetryChangedFiles(ignoreErrors bool, extra checksums.Algorithm) (int, error) {
+
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		for index := range fileMatches {
+
+			if !fileMatches[index].changed {
+				continue
+			}
+
+			info, err := os.Lstat(fileMatches[index].osPath())
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return ignoredErrors, err
+				}
+
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(fileMatches[index].FullPath, errreport.OperationHash, err)
+
+				continue
+			}
+
+			fileMatches[index].size = info.Size()
+			fileMatches[index].modTimeSec = info.ModTime().Unix()
+			fileMatches[index].modTimeNsec = int32(info.ModTime().Nanosecond()) // #nosec G115; always within [0, 999999999]
+			fileMatches[index].changed = false
+
+			// Update the file in place via a single-entry slice sharing the
+			// same backing array.
+			ignored, err := fileMatches[index:index+1].UpdateChecksumsWith(ignoreErrors, extra, nil)
+			ignoredErrors += ignored
+			if err != nil {
+				return ignoredErrors, err
+			}
+		}
+	}
+
+	return ignoredErrors, nil
+}
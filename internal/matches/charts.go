@@ -0,0 +1,154 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// chartExtensionsLimit is the maximum number of file extensions shown
+// individually in the wasted space chart; the space wasted by files with any
+// other extension is combined into a single "other" entry.
+const chartExtensionsLimit = 9
+
+// sizeBucket is a range of file sizes used to group duplicate file sets.
+type sizeBucket struct {
+	Label string
+
+	// Limit is the exclusive upper bound of the range in bytes; 0 indicates
+	// that the range has no upper bound.
+	Limit int64
+}
+
+// sizeBuckets is the list of file size ranges, smallest first, used when
+// counting duplicate file sets by size.
+var sizeBuckets = []sizeBucket{
+	{Label: "< 100 KiB", Limit: 100 << 10},
+	{Label: "100 KiB - 1 MiB", Limit: 1 << 20},
+	{Label: "1 MiB - 10 MiB", Limit: 10 << 20},
+	{Label: "10 MiB - 100 MiB", Limit: 100 << 20},
+	{Label: "100 MiB - 1 GiB", Limit: 1 << 30},
+	{Label: ">= 1 GiB"},
+}
+
+// setsBySizeBucket returns the number of duplicate file sets within each of
+// the sizeBuckets ranges, based on the size of the files in each set.
+func (fi FileChecksumIndex) setsBySizeBucket() []int {
+
+	counts := make([]int, len(sizeBuckets))
+
+	for _, fileMatches := range fi {
+		size := fileMatches[0].Size()
+		for i, bucket := range sizeBuckets {
+			if bucket.Limit == 0 || size < bucket.Limit {
+				counts[i]++
+				break
+			}
+		}
+	}
+
+	return counts
+}
+
+// writeSummaryCharts writes the data for and adds charts of the wasted space
+// by file extension (pie) and the number of duplicate file sets by size
+// (bar) to the specified workbook sheet. The chart data is written to columns
+// D through H, to the right of the summary values.
+func (fi FileChecksumIndex) writeSummaryCharts(f *excelize.File, sheet string, extensionSummaries ExtensionSummaries) error {
+
+	// Nothing to chart
+	if len(fi) == 0 {
+		return nil
+	}
+
+	extensions := extensionSummaries.Top(chartExtensionsLimit)
+
+	rows := [][]interface{}{
+		{"extension", "wasted space (bytes)"},
+	}
+	for _, entry := range extensions {
+		rows = append(rows, []interface{}{entry.Extension, entry.WastedSpace})
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(4, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write chart data: %w", err)
+		}
+	}
+
+	// Write the set counts in the same rows, leaving column F blank
+	rows = [][]interface{}{
+		{"file size", "duplicate sets"},
+	}
+	for i, count := range fi.setsBySizeBucket() {
+		rows = append(rows, []interface{}{sizeBuckets[i].Label, count})
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(7, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write chart data: %w", err)
+		}
+	}
+
+	wastedSpaceChart := excelize.Chart{
+		Type: excelize.Pie,
+		Series: []excelize.ChartSeries{
+			{
+				Name:       fmt.Sprintf("%s!$E$1", sheet),
+				Categories: fmt.Sprintf("%s!$D$2:$D$%d", sheet, len(extensions)+1),
+				Values:     fmt.Sprintf("%s!$E$2:$E$%d", sheet, len(extensions)+1),
+			},
+		},
+		Title: []excelize.RichTextRun{
+			{Text: "Wasted space by file extension"},
+		},
+		Legend: excelize.ChartLegend{
+			Position: "right",
+		},
+		PlotArea: excelize.ChartPlotArea{
+			ShowPercent: true,
+		},
+	}
+
+	if err := f.AddChart(sheet, "J1", &wastedSpaceChart); err != nil {
+		return fmt.Errorf("failed to add wasted space chart: %w", err)
+	}
+
+	setSizesChart := excelize.Chart{
+		Type: excelize.Bar,
+		Series: []excelize.ChartSeries{
+			{
+				Name:       fmt.Sprintf("%s!$H$1", sheet),
+				Categories: fmt.Sprintf("%s!$G$2:$G$%d", sheet, len(sizeBuckets)+1),
+				Values:     fmt.Sprintf("%s!$H$2:$H$%d", sheet, len(sizeBuckets)+1),
+			},
+		},
+		Title: []excelize.RichTextRun{
+			{Text: "Duplicate sets by file size"},
+		},
+		Legend: excelize.ChartLegend{
+			Position: "none",
+		},
+	}
+
+	if err := f.AddChart(sheet, "J17", &setSizesChart); err != nil {
+		return fmt.Errorf("failed to add duplicate sets chart: %w", err)
+	}
+
+	return nil
+}
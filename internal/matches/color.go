@@ -0,0 +1,104 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"os"
+
+	"github.com/atc0005/bridge/internal/progress"
+)
+
+// ColorMode specifies whether console output is colored using ANSI escape
+// sequences.
+type ColorMode string
+
+const (
+	// ColorAuto colors console output only if standard output is a
+	// terminal and the NO_COLOR environment variable is not set.
+	ColorAuto ColorMode = "auto"
+
+	// ColorAlways colors console output even if it is redirected.
+	ColorAlways ColorMode = "always"
+
+	// ColorNever does not color console output.
+	ColorNever ColorMode = "never"
+)
+
+// ColorModes is the list of supported color modes.
+var ColorModes = []ColorMode{
+	ColorAuto,
+	ColorAlways,
+	ColorNever,
+}
+
+// ANSI escape sequences used to color console output. Each line of colored
+// output starts with one of the color sequences, which are all the same
+// length, so that columns aligned using a tabwriter stay aligned; the reset
+// sequence ends the line.
+const (
+	ansiDefault = "\x1b[39m"
+	ansiBold    = "\x1b[01m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiCyan    = "\x1b[36m"
+	ansiReset   = "\x1b[0m"
+)
+
+// IsValid indicates whether the color mode is supported.
+func (cm ColorMode) IsValid() bool {
+	for _, mode := range ColorModes {
+		if cm == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enabled indicates whether output written to the provided file should be
+// colored. See https://no-color.org/ for the NO_COLOR convention.
+func (cm ColorMode) Enabled(file *os.File) bool {
+	switch cm {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return progress.IsTerminal(file)
+}
+
+// consoleSetSeparatorWidth is the width of the rule in each column
+// separating duplicate file sets in colored console output.
+const consoleSetSeparatorWidth = 4
+
+// consoleColors provides the ANSI escape sequences starting and ending each
+// line of console output, or empty strings if output is not colored.
+type consoleColors bool
+
+// line returns the sequence starting a line in the specified color.
+func (cc consoleColors) line(color string) string {
+	if !cc {
+		return ""
+	}
+
+	return color
+}
+
+// reset returns the sequence ending a colored line.
+func (cc consoleColors) reset() string {
+	if !cc {
+		return ""
+	}
+
+	return ansiReset
+}
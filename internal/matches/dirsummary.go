@@ -0,0 +1,209 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// DirectorySummary is the aggregated duplication details for a single
+// directory.
+type DirectorySummary struct {
+
+	// Directory is the path to the directory
+	Directory string
+
+	// EvaluatedFiles is the number of files in the directory which were
+	// evaluated for duplicates
+	EvaluatedFiles int
+
+	// DuplicateFiles is the number of files in the directory which belong
+	// to a duplicate file set
+	DuplicateFiles int
+
+	// CopiesElsewhere is the number of files in the directory with at least
+	// one duplicate located in another directory
+	CopiesElsewhere int
+
+	// WastedSpace is the space (in bytes) reclaimed by removing the
+	// duplicate files of the directory while keeping at least one copy of
+	// each file (within the directory or elsewhere)
+	WastedSpace int64
+}
+
+// DirectorySummaries is a collection of DirectorySummary values.
+type DirectorySummaries []DirectorySummary
+
+// FilesPerDirectory returns the number of files in the index for each
+// directory.
+func (fi FileSizeIndex) FilesPerDirectory() map[string]int {
+
+	counts := make(map[string]int)
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			counts[file.ParentDirectory()]++
+		}
+	}
+
+	return counts
+}
+
+// NewDirectorySummaries aggregates the duplicate file sets in the index per
+// directory. The number of evaluated files per directory (see
+// FilesPerDirectory) is used to determine whether all evaluated files in a
+// directory have copies elsewhere. Summaries are sorted by wasted space,
+// largest first.
+func NewDirectorySummaries(evaluatedFiles map[string]int, fi FileChecksumIndex) DirectorySummaries {
+
+	summaries := make(map[string]*DirectorySummary)
+
+	summary := func(dir string) *DirectorySummary {
+		if _, ok := summaries[dir]; !ok {
+			summaries[dir] = &DirectorySummary{
+				Directory:      dir,
+				EvaluatedFiles: evaluatedFiles[dir],
+			}
+		}
+		return summaries[dir]
+	}
+
+	for _, fileMatches := range fi {
+
+		byDirectory := make(map[string]FileMatches)
+		for _, file := range fileMatches {
+			byDirectory[file.ParentDirectory()] = append(byDirectory[file.ParentDirectory()], file)
+		}
+
+		for dir, files := range byDirectory {
+			s := summary(dir)
+			s.DuplicateFiles += len(files)
+
+			var totalSize, largest int64
+			for _, file := range files {
+				totalSize += file.Allocated()
+				if file.Allocated() > largest {
+					largest = file.Allocated()
+				}
+			}
+
+			// If all copies are within this directory, one must be kept
+			if len(byDirectory) == 1 {
+				s.WastedSpace += totalSize - largest
+				continue
+			}
+
+			s.CopiesElsewhere += len(files)
+			s.WastedSpace += totalSize
+		}
+	}
+
+	result := make(DirectorySummaries, 0, len(summaries))
+	for _, s := range summaries {
+		result = append(result, *s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].WastedSpace != result[j].WastedSpace {
+			return result[i].WastedSpace > result[j].WastedSpace
+		}
+		return result[i].Directory < result[j].Directory
+	})
+
+	return result
+}
+
+// Removable indicates whether every evaluated file in the directory has a
+// copy in another directory; the directory could be removed as a whole
+// without losing the content of any evaluated file.
+func (ds DirectorySummary) Removable() bool {
+	return ds.EvaluatedFiles > 0 && ds.CopiesElsewhere == ds.EvaluatedFiles
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (dss DirectorySummaries) GenerateCSVHeaderRow() []string {
+	return []string{
+		CSVDirectoryColumnHeaderName,
+		"evaluated_files",
+		"duplicate_files",
+		"copies_elsewhere",
+		"wasted_space",
+		"wasted_space_in_bytes",
+		"removable",
+	}
+}
+
+// GenerateCSVDataRow returns a string slice for use with a CSV Writer as a
+// data (non-header) row.
+func (ds DirectorySummary) GenerateCSVDataRow() []string {
+	return []string{
+		ds.Directory,
+		strconv.Itoa(ds.EvaluatedFiles),
+		strconv.Itoa(ds.DuplicateFiles),
+		strconv.Itoa(ds.CopiesElsewhere),
+		units.ByteCountIEC(ds.WastedSpace),
+		strconv.FormatInt(ds.WastedSpace, 10),
+		strconv.FormatBool(ds.Removable()),
+	}
+}
+
+// WriteCSV writes the directory summaries to the specified CSV file.
+func (dss DirectorySummaries) WriteCSV(filename string) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(dss.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for _, ds := range dss {
+		if err := w.Write(ds.GenerateCSVDataRow()); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
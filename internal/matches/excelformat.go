@@ -0,0 +1,165 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// excelKeepColumn is the column of the keep field in the duplicate file set
+// sheets of the Excel workbook.
+const excelKeepColumn = "G"
+
+// excelBandColors is the pair of fill (and sheet tab) colors alternated
+// between duplicate file sets in the Excel workbook.
+var excelBandColors = [2]string{"DDEBF7", "E2EFDA"}
+
+// excelFlaggedFillColor and excelFlaggedFontColor are the colors used to
+// highlight rows for files suggested for removal in the Excel workbook.
+const (
+	excelFlaggedFillColor = "FFC7CE"
+	excelFlaggedFontColor = "9C0006"
+)
+
+// excelSetStyles is the collection of conditional format styles applied to
+// the duplicate file set sheets of the Excel workbook.
+type excelSetStyles struct {
+	flagged int
+	bands   [2]int
+}
+
+// newExcelSetStyles registers the conditional format styles applied to the
+// duplicate file set sheets with the provided workbook.
+func newExcelSetStyles(f *excelize.File) (excelSetStyles, error) {
+
+	var styles excelSetStyles
+
+	flagged, err := f.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: excelFlaggedFontColor},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Pattern: 1,
+			Color:   []string{excelFlaggedFillColor},
+		},
+	})
+	if err != nil {
+		return styles, fmt.Errorf("failed to create conditional format style: %w", err)
+	}
+	styles.flagged = flagged
+
+	for i, color := range excelBandColors {
+		band, err := f.NewConditionalStyle(&excelize.Style{
+			Fill: excelize.Fill{
+				Type:    "pattern",
+				Pattern: 1,
+				Color:   []string{color},
+			},
+		})
+		if err != nil {
+			return styles, fmt.Errorf("failed to create conditional format style: %w", err)
+		}
+		styles.bands[i] = band
+	}
+
+	return styles, nil
+}
+
+// formatSetSheet applies conditional formatting to the data rows of the
+// sheet for a duplicate file set: rows for files not marked to be kept
+// (suggested for removal) are highlighted and all other rows are filled with
+// one of the band colors, alternating between sets based on the position of
+// the set in the workbook. Since the formatting is conditional, changes made
+// to the keep column while reviewing the workbook are reflected immediately.
+func formatSetSheet(f *excelize.File, sheet string, styles excelSetStyles, setIndex int, rows int, columns int) error {
+
+	lastCell, err := excelize.CoordinatesToCellName(columns, rows+1)
+	if err != nil {
+		return err
+	}
+	rangeRef := "A2:" + lastCell
+
+	band := setIndex % len(excelBandColors)
+
+	rules := []excelize.ConditionalFormatOptions{
+		{
+			Type:       "formula",
+			Criteria:   fmt.Sprintf("$%s2=FALSE", excelKeepColumn),
+			Format:     &styles.flagged,
+			StopIfTrue: true,
+		},
+		{
+			Type:     "formula",
+			Criteria: "TRUE",
+			Format:   &styles.bands[band],
+		},
+	}
+
+	if err := f.SetConditionalFormat(sheet, rangeRef, rules); err != nil {
+		return fmt.Errorf("failed to apply conditional formatting: %w", err)
+	}
+
+	// Color the sheet tab to match (as an ARGB value), so that the banding
+	// is also visible when moving between sets
+	tabColor := "FF" + excelBandColors[band]
+	if err := f.SetSheetProps(sheet, &excelize.SheetPropsOptions{
+		TabColorRGB: &tabColor,
+	}); err != nil {
+		return fmt.Errorf("failed to set sheet tab color: %w", err)
+	}
+
+	return nil
+}
+
+// formatMoreSetsSheet applies conditional formatting to the data rows of the
+// sheet listing several duplicate file sets (see excelMoreSetsSheet): rows
+// for files suggested for removal are highlighted as with formatSetSheet and
+// all other rows are filled with one of the band colors based on the set ID
+// recorded in the last column, so that consecutive sets alternate.
+func formatMoreSetsSheet(f *excelize.File, sheet string, styles excelSetStyles, rows int, columns int) error {
+
+	lastCell, err := excelize.CoordinatesToCellName(columns, rows+1)
+	if err != nil {
+		return err
+	}
+	rangeRef := "A2:" + lastCell
+
+	setIDColumn, err := excelize.ColumnNumberToName(columns)
+	if err != nil {
+		return err
+	}
+
+	// Set IDs start at one, so odd set IDs use the first band color as with
+	// the first set listed on its own sheet.
+	rules := []excelize.ConditionalFormatOptions{
+		{
+			Type:       "formula",
+			Criteria:   fmt.Sprintf("$%s2=FALSE", excelKeepColumn),
+			Format:     &styles.flagged,
+			StopIfTrue: true,
+		},
+		{
+			Type:       "formula",
+			Criteria:   fmt.Sprintf("ISODD($%s2)", setIDColumn),
+			Format:     &styles.bands[0],
+			StopIfTrue: true,
+		},
+		{
+			Type:     "formula",
+			Criteria: "TRUE",
+			Format:   &styles.bands[1],
+		},
+	}
+
+	if err := f.SetConditionalFormat(sheet, rangeRef, rules); err != nil {
+		return fmt.Errorf("failed to apply conditional formatting: %w", err)
+	}
+
+	return nil
+}
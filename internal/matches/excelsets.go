@@ -0,0 +1,230 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/xuri/excelize/v2"
+)
+
+// excelSetsIndexSheet is the name of the sheet of the Excel workbook mapping
+// set numbers to the sheet listing each duplicate file set and its checksum.
+const excelSetsIndexSheet = "Sets"
+
+// excelMoreSetsSheet is the name of the sheet of the Excel workbook listing
+// all duplicate file sets beyond maxExcelSetSheets, distinguished by a
+// set ID column. Additional sheets are numbered (e.g., "More sets 2") if
+// the rows do not fit on a single sheet.
+const excelMoreSetsSheet = "More sets"
+
+// excelMaxRows is the maximum number of rows (including the header row) of
+// a sheet supported by Excel.
+const excelMaxRows = 1048576
+
+// maxExcelSetSheets is the maximum number of duplicate file sets listed on
+// their own sheet of the Excel workbook. Workbooks with many thousands of
+// sheets are slow to open and impractical to navigate.
+const maxExcelSetSheets = 1000
+
+// excelSetSheetName returns the name of the sheet listing the duplicate file
+// set with the specified set ID (e.g., "Set 001"). Set IDs are zero padded to
+// the number of digits of the highest set ID listed on its own sheet (at
+// least three) so that sheets sort in order. Names are well within the 31
+// character limit Excel places on sheet names.
+func excelSetSheetName(setID int, sets int) string {
+
+	if sets > maxExcelSetSheets {
+		sets = maxExcelSetSheets
+	}
+
+	width := len(strconv.Itoa(sets))
+	if width < 3 {
+		width = 3
+	}
+
+	return fmt.Sprintf("Set %0*d", width, setID)
+}
+
+// numberedSheetName returns the name of the specified part (starting with
+// one) of a sheet whose rows are split across several sheets.
+func numberedSheetName(name string, part int) string {
+	if part == 1 {
+		return name
+	}
+
+	return fmt.Sprintf("%s %d", name, part)
+}
+
+// excelSetSheets returns the name of the sheet listing each duplicate file
+// set (listed in the specified order). Sets beyond maxExcelSetSheets are
+// listed together, starting another sheet whenever the next set would
+// exceed the row limit. Sets are never split across sheets.
+func (fi FileChecksumIndex) excelSetSheets(ordered []checksums.SHA256Checksum) []string {
+
+	sheets := make([]string, len(ordered))
+	part := 1
+	rows := 1
+
+	for setIndex, checksum := range ordered {
+		if setIndex < maxExcelSetSheets {
+			sheets[setIndex] = excelSetSheetName(setIndex+1, len(ordered))
+			continue
+		}
+
+		setRows := len(fi[checksum])
+		if rows > 1 && rows+setRows > excelMaxRows {
+			part++
+			rows = 1
+		}
+
+		sheets[setIndex] = numberedSheetName(excelMoreSetsSheet, part)
+		rows += setRows
+	}
+
+	return sheets
+}
+
+// writeExcelSetHeader writes the header row of a sheet listing duplicate file
+// sets, optionally followed by a set ID column (for sheets listing more than
+// one set), returning the number of columns.
+func writeExcelSetHeader(f *excelize.File, sheet string, columns ReportColumns, withSetID bool) (int, error) {
+
+	headers := []string{
+		CSVDirectoryColumnHeaderName,
+		CSVFileColumnHeaderName,
+		CSVSizeColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		CSVChecksumColumnHeaderName,
+		CSVModifiedColumnHeaderName,
+		CSVKeepColumnHeaderName,
+		CSVRoleColumnHeaderName,
+	}
+	headers = append(headers, columns.headers()...)
+
+	if withSetID {
+		headers = append(headers, CSVSetIDColumnHeaderName)
+	}
+
+	for i, header := range headers {
+		cell, err := excelize.CoordinatesToCellName(i+1, 1)
+		if err != nil {
+			return 0, err
+		}
+		if err := f.SetCellValue(sheet, cell, strings.ReplaceAll(header, "_", " ")); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(headers), nil
+}
+
+// writeExcelSetRows writes one row per file in the duplicate file set to the
+// sheet starting with the specified row, optionally followed by the set ID
+// (see writeExcelSetHeader).
+func writeExcelSetRows(f *excelize.File, sheet string, firstRow int, setID int, withSetID bool, fileMatches FileMatches, columns ReportColumns) error {
+
+	var scope string
+	if columns.Scope {
+		scope = fileMatches.Scope()
+	}
+
+	for index, file := range fileMatches {
+
+		values := []interface{}{
+			file.directoryField(columns),
+			file.Name(),
+			file.SizeHR(),
+			file.Size(),
+			file.Checksum.String(),
+			nil,
+			file.Keep,
+			file.Role(),
+		}
+
+		// Leave the cell empty if the modification time is not known
+		// instead of showing a nonsensical date.
+		if !file.ModTime().IsZero() {
+			values[5] = file.ModTime()
+		}
+
+		for _, value := range file.optionalFields(scope, columns) {
+			values = append(values, value)
+		}
+
+		if withSetID {
+			values = append(values, setID)
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, firstRow+index)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeExcelSetsIndex creates the index sheet listing the set ID, sheet
+// (see excelSetSheets), checksum, number of files and wasted space of each
+// duplicate file set (listed in the specified order). Sheet names link to
+// the sheet listing the set. Additional index sheets are numbered (e.g.,
+// "Sets 2") if the sets do not fit on a single sheet.
+func (fi FileChecksumIndex) writeExcelSetsIndex(f *excelize.File, ordered []checksums.SHA256Checksum, sheets []string) error {
+
+	header := []interface{}{"set id", "sheet", "checksum", "files", "size in bytes", "wasted space"}
+	setsPerSheet := excelMaxRows - 1
+
+	for setIndex, checksum := range ordered {
+		setID := setIndex + 1
+		fileMatches := fi[checksum]
+		sheet := sheets[setIndex]
+
+		indexSheet := numberedSheetName(excelSetsIndexSheet, setIndex/setsPerSheet+1)
+		indexRow := setIndex%setsPerSheet + 2
+
+		if setIndex%setsPerSheet == 0 {
+			if _, err := f.NewSheet(indexSheet); err != nil {
+				return fmt.Errorf("failed to add new worksheet: %w", err)
+			}
+			if err := f.SetSheetRow(indexSheet, "A1", &header); err != nil {
+				return err
+			}
+		}
+
+		row := []interface{}{
+			setID,
+			sheet,
+			checksum.String(),
+			len(fileMatches),
+			fileMatches[0].Size(),
+			fileMatches.WastedSpace(),
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, indexRow)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(indexSheet, cell, &row); err != nil {
+			return err
+		}
+
+		link := fmt.Sprintf("B%d", indexRow)
+		if err := f.SetCellHyperLink(indexSheet, link, fmt.Sprintf("'%s'!A1", sheet), "Location"); err != nil {
+			return fmt.Errorf("failed to link to worksheet %q: %w", sheet, err)
+		}
+	}
+
+	return nil
+}
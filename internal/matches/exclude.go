@@ -0,0 +1,40 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"path"
+)
+
+// DirExcludes is a list of directory name patterns (see path.Match) which
+// are skipped, along with their content, when recursively evaluating paths.
+type DirExcludes []string
+
+// DefaultDirExcludes lists well-known directories (e.g., version control
+// metadata, dependency caches, NAS thumbnails and trash folders) whose
+// content generates large numbers of meaningless duplicate file matches.
+var DefaultDirExcludes = DirExcludes{
+	".git",
+	".svn",
+	"node_modules",
+	"@eaDir",
+	".Trash-*",
+	"System Volume Information",
+}
+
+// Match indicates whether the provided directory name matches any of the
+// excluded directory name patterns.
+func (de DirExcludes) Match(name string) bool {
+	for _, pattern := range de {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
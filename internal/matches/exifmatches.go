@@ -0,0 +1,390 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/exif"
+	"github.com/atc0005/bridge/internal/paths"
+
+	// Register decoders for image formats supported by the exif package
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// captureTimeLayout is the layout used when displaying EXIF capture times.
+const captureTimeLayout string = "2006-01-02 15:04:05"
+
+// EXIFMatchSets is a collection of image file sets whose content differs
+// but whose EXIF capture time and dimensions match. Files in these sets are
+// likely re-exports of the same photo (e.g., with edited metadata or a
+// different encoder) and should be reviewed before any files are removed.
+type EXIFMatchSets []FileMatches
+
+// exifKey is used to group image files by EXIF capture time and dimensions.
+type exifKey struct {
+	captureTime time.Time
+	width       int
+	height      int
+}
+
+// read opens the file and passes its content to the provided function.
+func (fm FileMatch) read(fn func(r io.Reader) error) error {
+
+	f, err := fm.open()
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", fm.FullPath, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				fm.FullPath,
+				err,
+			)
+		}
+	}()
+
+	return fn(f)
+}
+
+// EXIFMetadata returns the EXIF metadata of the image file. The recorded
+// width and height are the dimensions of the decoded image rather than the
+// (optional) values recorded in EXIF metadata. Images without EXIF metadata
+// are not considered an error; only the dimensions are returned.
+func (fm FileMatch) EXIFMetadata() (exif.Metadata, error) {
+
+	var metadata exif.Metadata
+
+	err := fm.read(func(r io.Reader) error {
+		var err error
+		metadata, err = exif.Read(r)
+		if err != nil && !errors.Is(err, exif.ErrNotFound) {
+			return fmt.Errorf("failed to read EXIF metadata from %q: %w", fm.FullPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return exif.Metadata{}, err
+	}
+
+	err = fm.read(func(r io.Reader) error {
+		config, _, err := image.DecodeConfig(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode image %q: %w", fm.FullPath, err)
+		}
+		metadata.Width = config.Width
+		metadata.Height = config.Height
+		return nil
+	})
+	if err != nil {
+		return exif.Metadata{}, err
+	}
+
+	return metadata, nil
+}
+
+// exifFields returns the EXIF metadata values for use with the optional EXIF
+// report columns. Empty values are returned for files without metadata.
+func (fm FileMatch) exifFields() []string {
+
+	fields := make([]string, 5)
+
+	if fm.EXIF == nil {
+		return fields
+	}
+
+	if !fm.EXIF.CaptureTime.IsZero() {
+		fields[0] = fm.EXIF.CaptureTime.Format(captureTimeLayout)
+	}
+	fields[1] = fm.EXIF.Make
+	fields[2] = fm.EXIF.Model
+	if fm.EXIF.Width > 0 && fm.EXIF.Height > 0 {
+		fields[3] = strconv.Itoa(fm.EXIF.Width)
+		fields[4] = strconv.Itoa(fm.EXIF.Height)
+	}
+
+	return fields
+}
+
+// UpdateEXIF records EXIF metadata for all supported image files in the
+// provided FileMatches, returning the number of errors ignored (as
+// requested).
+func (fm FileMatches) UpdateEXIF(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for index := range fm {
+		if !exif.IsSupported(fm[index].Name()) {
+			continue
+		}
+
+		metadata, err := fm[index].EXIFMetadata()
+		if err != nil {
+			if !errreport.Ignore(ignoreErrors, err) {
+				return ignoredErrors, err
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(fm[index].FullPath, errreport.OperationMetadata, err)
+
+			continue
+		}
+
+		fm[index].EXIF = &metadata
+	}
+
+	return ignoredErrors, nil
+}
+
+// UpdateEXIF records EXIF metadata for all supported image files in the
+// index. The number of errors ignored (as requested) is also returned.
+func (fi FileChecksumIndex) UpdateEXIF(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		ignored, err := fileMatches.UpdateEXIF(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return ignoredErrors, err
+		}
+	}
+
+	return ignoredErrors, nil
+}
+
+// FindEXIFMatches records EXIF metadata for all supported image files in the
+// index and groups images with identical EXIF capture times and dimensions.
+// Images without a recorded capture time are skipped. Sets composed
+// entirely of files already confirmed as duplicates of each other (via the
+// provided checksum index) are omitted. The number of errors ignored (as
+// requested) is also returned.
+func (fi FileSizeIndex) FindEXIFMatches(ignoreErrors bool, exact FileChecksumIndex) (EXIFMatchSets, int, error) {
+
+	var ignoredErrors int
+	groups := make(map[exifKey]FileMatches)
+
+	for _, fileMatches := range fi {
+		ignored, err := fileMatches.UpdateEXIF(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return nil, ignoredErrors, err
+		}
+
+		for _, file := range fileMatches {
+			if file.EXIF == nil || file.EXIF.CaptureTime.IsZero() {
+				continue
+			}
+
+			key := exifKey{
+				captureTime: file.EXIF.CaptureTime,
+				width:       file.EXIF.Width,
+				height:      file.EXIF.Height,
+			}
+			groups[key] = append(groups[key], file)
+		}
+	}
+
+	// Record checksums for confirmed duplicates so that sets of
+	// byte-identical files (already reported) can be skipped.
+	confirmed := make(map[string]checksums.SHA256Checksum)
+	for checksum, fileMatches := range exact {
+		for _, file := range fileMatches {
+			confirmed[file.FullPath] = checksum
+		}
+	}
+
+	var sets EXIFMatchSets
+	for _, set := range groups {
+		if len(set) < 2 {
+			continue
+		}
+
+		identical := true
+		for _, file := range set {
+			checksum, ok := confirmed[file.FullPath]
+			if !ok || checksum != confirmed[set[0].FullPath] {
+				identical = false
+				break
+			}
+		}
+
+		if identical {
+			continue
+		}
+
+		sort.Slice(set, func(i, j int) bool {
+			return set[i].FullPath < set[j].FullPath
+		})
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i][0].FullPath < sets[j][0].FullPath
+	})
+
+	return sets, ignoredErrors, nil
+}
+
+// GetTotalFilesCount returns the total number of files in all EXIF match
+// sets.
+func (ems EXIFMatchSets) GetTotalFilesCount() int {
+
+	var files int
+
+	for _, set := range ems {
+		files += len(set)
+	}
+
+	return files
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (ems EXIFMatchSets) GenerateCSVHeaderRow() []string {
+	return []string{
+		"set",
+		CSVDirectoryColumnHeaderName,
+		CSVFileColumnHeaderName,
+		CSVSizeColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		CSVCaptureTimeColumnHeaderName,
+		CSVCameraMakeColumnHeaderName,
+		CSVCameraModelColumnHeaderName,
+		CSVWidthColumnHeaderName,
+		CSVHeightColumnHeaderName,
+	}
+}
+
+// WriteEXIFMatchesCSV writes the EXIF match sets to the specified CSV file.
+func (ems EXIFMatchSets) WriteEXIFMatchesCSV(filename string, blankLineBetweenSets bool) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(ems.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for i, set := range ems {
+
+		if blankLineBetweenSets && i > 0 {
+			if err := w.Write(make([]string, len(ems.GenerateCSVHeaderRow()))); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+
+		for _, file := range set {
+			record := append([]string{
+				strconv.Itoa(i + 1),
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				strconv.FormatInt(file.Size(), 10),
+			}, file.exifFields()...)
+
+			if err := w.Write(record); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// PrintEXIFMatches prints EXIF match sets to stdout in a section clearly
+// separated from confirmed duplicate file sets.
+func (ems EXIFMatchSets) PrintEXIFMatches(blankLineBetweenSets bool) {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "EXIF matches (same capture time and dimensions; file content differs)")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w,
+		"Set\tDirectory\tFile\tSize\tCapture Time\tCamera\tDimensions\t")
+
+	for i, set := range ems {
+		for _, file := range set {
+			fields := file.exifFields()
+			_, _ = fmt.Fprintf(w,
+				"%d\t%s\t%s\t%s\t%s\t%s %s\t%sx%s\n",
+				i+1,
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				fields[0],
+				fields[1],
+				fields[2],
+				fields[3],
+				fields[4],
+			)
+		}
+
+		if blankLineBetweenSets {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
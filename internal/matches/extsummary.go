@@ -0,0 +1,237 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/units"
+)
+
+// CSVExtensionColumnHeaderName is the name of the file extension column in
+// the extension summary CSV file.
+const CSVExtensionColumnHeaderName = "extension"
+
+// noExtensionLabel is the label used for files without a file extension.
+const noExtensionLabel = "(none)"
+
+// otherExtensionsLabel is the label used for the combined entry of files
+// with an extension not listed individually.
+const otherExtensionsLabel = "(other)"
+
+// consoleExtensionsLimit is the maximum number of file extensions listed
+// individually in the console summary.
+const consoleExtensionsLimit = 10
+
+// ExtensionSummary is the aggregated duplication details for a single
+// (lowercase) file extension.
+type ExtensionSummary struct {
+
+	// Extension is the file extension, including the leading period
+	Extension string `json:"extension"`
+
+	// DuplicateFiles is the number of duplicate files with the extension,
+	// not counting the file kept from each duplicate file set
+	DuplicateFiles int `json:"duplicate_files"`
+
+	// WastedSpace is the space (in bytes) reclaimed by removing the
+	// duplicate files with the extension
+	WastedSpace int64 `json:"wasted_space"`
+}
+
+// ExtensionSummaries is a collection of ExtensionSummary values, sorted by
+// wasted space, largest first.
+type ExtensionSummaries []ExtensionSummary
+
+// fileExtension returns the lowercase extension of the file, or
+// noExtensionLabel if the file does not have one.
+func fileExtension(file FileMatch) string {
+	ext := strings.ToLower(filepath.Ext(file.Name()))
+	if ext == "" {
+		return noExtensionLabel
+	}
+
+	return ext
+}
+
+// keptIndex returns the index of the file marked as the original to keep. If
+// none are marked, the index of the file preferred by tieBreak is returned so
+// that the result does not depend on the order of files within the set.
+func (fm FileMatches) keptIndex() int {
+	for i := range fm {
+		if fm[i].Keep {
+			return i
+		}
+	}
+
+	kept := 0
+	for i := range fm {
+		if tieBreak(fm[i], fm[kept]) {
+			kept = i
+		}
+	}
+
+	return kept
+}
+
+// NewExtensionSummaries aggregates the duplicate file sets in the index per
+// file extension. The file kept from each set (see UpdateKeeps) is not
+// counted as a duplicate file.
+func NewExtensionSummaries(fi FileChecksumIndex) ExtensionSummaries {
+
+	var summaries ExtensionSummaries
+
+	for _, fileMatches := range fi {
+		summaries = summaries.AddSet(fileMatches)
+	}
+
+	return summaries
+}
+
+// AddSet returns the extension summaries updated with the duplicate files
+// of a single confirmed duplicate file set. This is used to accumulate
+// summaries when sets are not retained (e.g., in stream mode).
+func (ess ExtensionSummaries) AddSet(fileMatches FileMatches) ExtensionSummaries {
+
+	if len(fileMatches) == 0 {
+		return ess
+	}
+
+	kept := fileMatches.keptIndex()
+
+	for i, file := range fileMatches {
+		if i == kept {
+			continue
+		}
+
+		ext := fileExtension(file)
+
+		found := false
+		for j := range ess {
+			if ess[j].Extension == ext {
+				ess[j].DuplicateFiles++
+				ess[j].WastedSpace += file.Allocated()
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			ess = append(ess, ExtensionSummary{
+				Extension:      ext,
+				DuplicateFiles: 1,
+				WastedSpace:    file.Allocated(),
+			})
+		}
+	}
+
+	sort.Slice(ess, func(i, j int) bool {
+		if ess[i].WastedSpace != ess[j].WastedSpace {
+			return ess[i].WastedSpace > ess[j].WastedSpace
+		}
+		return ess[i].Extension < ess[j].Extension
+	})
+
+	return ess
+}
+
+// Top returns the first limit extension summaries, combining any remaining
+// summaries into a single entry.
+func (ess ExtensionSummaries) Top(limit int) ExtensionSummaries {
+
+	if len(ess) <= limit {
+		return ess
+	}
+
+	top := make(ExtensionSummaries, limit, limit+1)
+	copy(top, ess[:limit])
+
+	other := ExtensionSummary{Extension: otherExtensionsLabel}
+	for _, es := range ess[limit:] {
+		other.DuplicateFiles += es.DuplicateFiles
+		other.WastedSpace += es.WastedSpace
+	}
+
+	return append(top, other)
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (ess ExtensionSummaries) GenerateCSVHeaderRow() []string {
+	return []string{
+		CSVExtensionColumnHeaderName,
+		"duplicate_files",
+		"wasted_space",
+		"wasted_space_in_bytes",
+	}
+}
+
+// GenerateCSVDataRow returns a string slice for use with a CSV Writer as a
+// data (non-header) row.
+func (es ExtensionSummary) GenerateCSVDataRow() []string {
+	return []string{
+		es.Extension,
+		strconv.Itoa(es.DuplicateFiles),
+		units.ByteCountIEC(es.WastedSpace),
+		strconv.FormatInt(es.WastedSpace, 10),
+	}
+}
+
+// WriteCSV writes the extension summaries to the specified CSV file.
+func (ess ExtensionSummaries) WriteCSV(filename string) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(ess.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for _, es := range ess {
+		if err := w.Write(es.GenerateCSVDataRow()); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import "time"
+
+// RemoveOutsideModTimes removes files last modified before earliest or
+// after latest from the index, returning the number of files removed. A
+// zero time applies no limit.
+func (fi FileSizeIndex) RemoveOutsideModTimes(earliest time.Time, latest time.Time) int {
+
+	var removed int
+
+	for key, fileMatches := range fi {
+
+		kept := fileMatches[:0]
+		for _, file := range fileMatches {
+			modTime := file.ModTime()
+			if (!earliest.IsZero() && modTime.Before(earliest)) ||
+				(!latest.IsZero() && modTime.After(latest)) {
+				removed++
+				continue
+			}
+			kept = append(kept, file)
+		}
+
+		if len(kept) == 0 {
+			delete(fi, key)
+			continue
+		}
+		fi[key] = kept
+	}
+
+	return removed
+}
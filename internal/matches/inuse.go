@@ -0,0 +1,59 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"log"
+	"sort"
+
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// skipInUse indicates whether the provided error reading the file was
+// returned because the file is in use (locked) by another process (see
+// paths.IsInUse), marking the file as in use if so. These files are skipped
+// instead of being treated as errors, regardless of whether errors are
+// ignored.
+func (fm *FileMatch) skipInUse(err error) bool {
+
+	if !paths.IsInUse(err) {
+		return false
+	}
+
+	// WARN
+	log.Printf("Skipping file %q; file is in use by another process", fm.FullPath)
+	fm.inUse = true
+	errreport.Record(fm.FullPath, errreport.OperationHash, err)
+
+	return true
+}
+
+// InUseFiles returns the full path of files in the index skipped when
+// generating checksums because they were in use (locked) by another
+// process, such as an open Outlook data file on Windows, sorted by path.
+func (fi FileSizeIndex) InUseFiles() []string {
+
+	var inUse []string
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if file.inUse {
+				inUse = append(inUse, file.FullPath)
+			}
+		}
+	}
+
+	sort.Strings(inUse)
+
+	return inUse
+}
+
+// consoleInUseFilesLimit is the maximum number of files skipped as they were
+// in use listed individually in the console summary.
+const consoleInUseFilesLimit = 10
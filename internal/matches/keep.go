@@ -0,0 +1,230 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"regexp"
+	"strings"
+)
+
+// KeepPolicy specifies how the file suggested as the original to keep is
+// chosen from each duplicate file set.
+type KeepPolicy string
+
+const (
+	// KeepOldest suggests keeping the file with the oldest modification
+	// time.
+	KeepOldest KeepPolicy = "oldest"
+
+	// KeepNewest suggests keeping the file with the newest modification
+	// time.
+	KeepNewest KeepPolicy = "newest"
+
+	// KeepShortestPath suggests keeping the file with the shortest full
+	// path, which is often the copy closest to the root of an archive.
+	KeepShortestPath KeepPolicy = "shortest-path"
+)
+
+// KeepPolicies is the list of supported keep policies.
+var KeepPolicies = []KeepPolicy{
+	KeepOldest,
+	KeepNewest,
+	KeepShortestPath,
+}
+
+// IsValid indicates whether the keep policy is supported.
+func (kp KeepPolicy) IsValid() bool {
+	for _, policy := range KeepPolicies {
+		if kp == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preferred indicates whether file a is preferred over file b as the file to
+// keep according to the policy. Ties (e.g., identical modification times or
+// path lengths) are broken by tieBreak.
+func (kp KeepPolicy) preferred(a FileMatch, b FileMatch) bool {
+
+	switch kp {
+	case KeepOldest:
+		if !a.ModTime().Equal(b.ModTime()) {
+			return a.ModTime().Before(b.ModTime())
+		}
+	case KeepNewest:
+		if !a.ModTime().Equal(b.ModTime()) {
+			return a.ModTime().After(b.ModTime())
+		}
+	case KeepShortestPath:
+		if len(a.FullPath) != len(b.FullPath) {
+			return len(a.FullPath) < len(b.FullPath)
+		}
+	}
+
+	return tieBreak(a, b)
+}
+
+// tieBreak indicates whether file a is preferred over file b when all other
+// keep criteria tie. The file whose full path sorts first in lexical (byte)
+// order is preferred so that repeated runs with the same input suggest the
+// same file regardless of the order in which files were found.
+func tieBreak(a FileMatch, b FileMatch) bool {
+	return a.FullPath < b.FullPath
+}
+
+// KeepRule specifies how the file suggested as the original to keep is
+// chosen from each duplicate file set. Files matching a removal pattern are
+// only kept if every file in the set does. Otherwise files matching a
+// preference pattern are favored, then files under the preferred paths
+// (earlier paths first), with the keep policy choosing between equally
+// preferred files.
+type KeepRule struct {
+
+	// Policy chooses between equally preferred files
+	Policy KeepPolicy
+
+	// PreferPaths lists directories, in priority order, whose files are
+	// favored over files elsewhere (e.g., a curated archive over ad-hoc
+	// collection folders)
+	PreferPaths []string
+
+	// PreferMatch lists patterns matched against the full path of files
+	// which are favored over other files (e.g., /Archive/)
+	PreferMatch []*regexp.Regexp
+
+	// RemoveMatch lists patterns matched against the full path of files
+	// which are suggested for removal over other files (e.g., /Downloads/).
+	// These take precedence over PreferMatch.
+	RemoveMatch []*regexp.Regexp
+}
+
+// HasPreferences indicates whether the rule favors files by path in addition
+// to the keep policy.
+func (kr KeepRule) HasPreferences() bool {
+	return len(kr.PreferPaths) > 0 || len(kr.PreferMatch) > 0 || len(kr.RemoveMatch) > 0
+}
+
+// String returns a short description of the rule.
+func (kr KeepRule) String() string {
+
+	var preferences []string
+	if len(kr.RemoveMatch) > 0 {
+		preferences = append(preferences, "remove-match")
+	}
+	if len(kr.PreferMatch) > 0 {
+		preferences = append(preferences, "prefer-match")
+	}
+	if len(kr.PreferPaths) > 0 {
+		preferences = append(preferences, "prefer-path")
+	}
+
+	if len(preferences) == 0 {
+		return string(kr.Policy)
+	}
+
+	return strings.Join(preferences, ", ") + ", then " + string(kr.Policy)
+}
+
+// matchRank returns 0 for files matching a preference pattern, 2 for files
+// matching a removal pattern and 1 for all other files.
+func (kr KeepRule) matchRank(file FileMatch) int {
+	for _, pattern := range kr.RemoveMatch {
+		if pattern.MatchString(file.FullPath) {
+			return 2
+		}
+	}
+
+	for _, pattern := range kr.PreferMatch {
+		if pattern.MatchString(file.FullPath) {
+			return 0
+		}
+	}
+
+	return 1
+}
+
+// pathRank returns the position of the first preferred path that the file
+// is found under, or the number of preferred paths if the file is not under
+// any of them.
+func (kr KeepRule) pathRank(file FileMatch) int {
+	for index, preferPath := range kr.PreferPaths {
+		if underPath(file.FullPath, preferPath) {
+			return index
+		}
+	}
+
+	return len(kr.PreferPaths)
+}
+
+// preferred indicates whether file a is preferred over file b as the file to
+// keep according to the rule.
+func (kr KeepRule) preferred(a FileMatch, b FileMatch) bool {
+
+	if rankA, rankB := kr.matchRank(a), kr.matchRank(b); rankA != rankB {
+		return rankA < rankB
+	}
+
+	if rankA, rankB := kr.pathRank(a), kr.pathRank(b); rankA != rankB {
+		return rankA < rankB
+	}
+
+	return kr.Policy.preferred(a, b)
+}
+
+// underPath indicates whether the specified path is the directory path or
+// is found somewhere beneath it. Both slash and backslash separators are
+// recognized so that local, Windows and Amazon S3 paths may be evaluated.
+func underPath(path string, dir string) bool {
+
+	trimmed := strings.TrimRight(dir, `/\`)
+	if trimmed == "" {
+		// The filesystem root.
+		return strings.HasPrefix(path, dir)
+	}
+
+	if !strings.HasPrefix(path, trimmed) {
+		return false
+	}
+
+	rest := path[len(trimmed):]
+
+	return rest == "" || rest[0] == '/' || rest[0] == '\\'
+}
+
+// SuggestedKeep returns the index of the file suggested as the original to
+// keep according to the specified rule, or -1 if there are no files.
+func (fm FileMatches) SuggestedKeep(rule KeepRule) int {
+
+	keep := -1
+	for index := range fm {
+		if keep == -1 || rule.preferred(fm[index], fm[keep]) {
+			keep = index
+		}
+	}
+
+	return keep
+}
+
+// UpdateKeep marks the file suggested as the original to keep according to
+// the specified rule, clearing the mark from all other files in the set.
+func (fm FileMatches) UpdateKeep(rule KeepRule) {
+	keep := fm.SuggestedKeep(rule)
+	for index := range fm {
+		fm[index].Keep = index == keep
+	}
+}
+
+// UpdateKeeps marks the file suggested as the original to keep in each
+// duplicate file set according to the specified rule.
+func (fi FileChecksumIndex) UpdateKeeps(rule KeepRule) {
+	for _, fileMatches := range fi {
+		fileMatches.UpdateKeep(rule)
+	}
+}
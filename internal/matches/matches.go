@@ -10,17 +10,26 @@
 package matches
 
 import (
-	"encoding/csv"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/exif"
 	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/progress"
+	"github.com/atc0005/bridge/internal/s3"
 	"github.com/atc0005/bridge/internal/units"
 
 	"github.com/xuri/excelize/v2"
@@ -34,25 +43,343 @@ const (
 	CSVSizeInBytesDirectoryColumnHeaderName string = "size_in_bytes"
 	CSVChecksumColumnHeaderName             string = "checksum"
 	CSVRemoveFileColumnHeaderName           string = "remove_file"
+	CSVSetIDColumnHeaderName                string = "set_id"
+	CSVModifiedColumnHeaderName             string = "modified"
+	CSVKeepColumnHeaderName                 string = "keep"
+	CSVRoleColumnHeaderName                 string = "role"
+	CSVCaptureTimeColumnHeaderName          string = "capture_time"
+	CSVCameraMakeColumnHeaderName           string = "camera_make"
+	CSVCameraModelColumnHeaderName          string = "camera_model"
+	CSVWidthColumnHeaderName                string = "width"
+	CSVHeightColumnHeaderName               string = "height"
+	CSVTagDifferencesColumnHeaderName       string = "tag_differences"
+	CSVScopeColumnHeaderName                string = "scope"
+	CSVRootColumnHeaderName                 string = "root"
+	CSVBytesVerifiedColumnHeaderName        string = "bytes_verified"
+	CSVExtraChecksumColumnHeaderName        string = "extra_checksum"
 )
 
-// FileMatch represents a superset of statistics (including os.FileInfo) for a
-// file matched by provided search criteria. This allows us to record the
-// original full path while also recording file metadata used in later
-// calculations.
-type FileMatch struct {
+// Values of the role column, distinguishing the file suggested as the
+// original to keep (see UpdateKeeps) from the duplicates of it.
+const (
+	RoleOriginal  string = "original"
+	RoleDuplicate string = "duplicate"
+)
+
+// ReportColumns specifies the optional columns included in generated CSV
+// files and Excel workbooks. Optional columns follow the standard columns
+// (and the set_id, modified, keep and role columns) so that generated CSV
+// files remain valid input for the prune subcommand.
+type ReportColumns struct {
+
+	// EXIF includes capture time, camera make, camera model and image
+	// dimensions columns for image files
+	EXIF bool
+
+	// TagDifferences includes a column listing the tags which differ
+	// between audio files in content-equal duplicate file sets
+	TagDifferences bool
+
+	// Scope includes a column indicating whether the duplicate file set is
+	// within a single root path or across root paths
+	Scope bool
+
+	// Root includes a column recording the evaluated path (e.g., as
+	// provided via the path flag) that each file was found within
+	Root bool
+
+	// RelativePaths records the directory column relative to the evaluated
+	// path that each file was found within, using slash separators, so
+	// that reports remain usable when the same tree is mounted elsewhere
+	RelativePaths bool
+
+	// BytesVerified includes a column indicating whether files were
+	// compared byte for byte after checksums matched
+	BytesVerified bool
+
+	// Allocated includes a column recording the space allocated on disk to
+	// each file (see UpdateAllocatedSizes)
+	Allocated bool
+
+	// ExtraChecksum includes a column recording the hash generated for each
+	// file using an additional algorithm (see UpdateChecksumsWith)
+	ExtraChecksum bool
+}
+
+// headers returns the CSV header names of the enabled optional columns.
+func (rc ReportColumns) headers() []string {
+
+	var headers []string
+
+	if rc.EXIF {
+		headers = append(headers,
+			CSVCaptureTimeColumnHeaderName,
+			CSVCameraMakeColumnHeaderName,
+			CSVCameraModelColumnHeaderName,
+			CSVWidthColumnHeaderName,
+			CSVHeightColumnHeaderName,
+		)
+	}
+
+	if rc.TagDifferences {
+		headers = append(headers, CSVTagDifferencesColumnHeaderName)
+	}
+
+	if rc.Scope {
+		headers = append(headers, CSVScopeColumnHeaderName)
+	}
+
+	if rc.Root {
+		headers = append(headers, CSVRootColumnHeaderName)
+	}
+
+	if rc.BytesVerified {
+		headers = append(headers, CSVBytesVerifiedColumnHeaderName)
+	}
+
+	if rc.Allocated {
+		headers = append(headers, CSVAllocatedSizeInBytesColumnHeaderName)
+	}
+
+	if rc.ExtraChecksum {
+		headers = append(headers, CSVExtraChecksumColumnHeaderName)
+	}
+
+	return headers
+}
+
+// optionalFields returns the values of the enabled optional columns for the
+// file. The scope is that of the duplicate file set containing the file.
+func (fm FileMatch) optionalFields(scope string, columns ReportColumns) []string {
+
+	var fields []string
+
+	if columns.EXIF {
+		fields = append(fields, fm.exifFields()...)
+	}
+
+	if columns.TagDifferences {
+		fields = append(fields, fm.TagDifferences)
+	}
+
+	if columns.Scope {
+		fields = append(fields, scope)
+	}
+
+	if columns.Root {
+		fields = append(fields, fm.Root)
+	}
+
+	if columns.BytesVerified {
+		fields = append(fields, fm.bytesVerifiedField())
+	}
 
-	// File metadata used in various calculations
-	os.FileInfo
+	if columns.Allocated {
+		fields = append(fields, fm.allocatedField())
+	}
+
+	if columns.ExtraChecksum {
+		fields = append(fields, fm.ExtraChecksum)
+	}
+
+	return fields
+}
 
-	// The full path to the file
+// FileMatch represents the statistics for a file matched by provided search
+// criteria. This allows us to record the original full path while also
+// recording file metadata used in later calculations.
+//
+// Only the file metadata needed by later calculations is retained (instead
+// of the complete os.FileInfo value) in order to limit memory use when
+// evaluating millions of files.
+type FileMatch struct {
+
+	// The full path to the file. This is the only path recorded for each
+	// file; the parent directory and base name are derived from it (see
+	// ParentDirectory and Name).
 	FullPath string
 
-	// Directory containing the file; analogue to Name() method
-	ParentDirectory string
+	// Root is the evaluated path (e.g., as provided via the path flag) that
+	// the file was found in. Files found within the same path share a
+	// single copy.
+	Root string
 
 	// Checksum calculated for files meeting the duplicates threshold
 	Checksum checksums.SHA256Checksum
+
+	// FS is the filesystem containing the file. If nil, the file is located
+	// within the OS filesystem and FullPath is a native OS path.
+	FS fs.FS
+
+	// EXIF is the EXIF metadata and dimensions of an image file. This is nil
+	// if metadata has not been extracted or the file is not a supported
+	// image format.
+	EXIF *exif.Metadata
+
+	// TagDifferences lists the tags (as name=value pairs) of an audio file
+	// which differ from other files in the same content-equal duplicate
+	// file set
+	TagDifferences string
+
+	// BytesVerified indicates whether the content of the file was compared
+	// byte for byte with other files in the same duplicate file set
+	BytesVerified bool
+
+	// Keep indicates whether the file is suggested as the original to keep
+	// from the duplicate file set (see KeepPolicy)
+	Keep bool
+
+	// ExtraChecksum is the hash of the file content generated using an
+	// additional algorithm, prefixed with the algorithm name (e.g.,
+	// "md5:<hash>"), if requested (see UpdateChecksumsWith)
+	ExtraChecksum string
+
+	// contentHint is the content fingerprint provided by the file metadata
+	// (see ContentHinter), if any
+	contentHint string
+
+	// size is the length of the file in bytes
+	size int64
+
+	// allocated is the space allocated to the file on disk in bytes, if
+	// recorded (see UpdateAllocatedSizes)
+	allocated int64
+
+	// modTimeSec and modTimeNsec record the modification time of the file
+	// as seconds and nanoseconds since the Unix epoch
+	modTimeSec  int64
+	modTimeNsec int32
+
+	// mode is the file mode bits of the file
+	mode fs.FileMode
+
+	// allocatedRecorded indicates whether the space allocated to the file
+	// on disk has been recorded
+	allocatedRecorded bool
+
+	// readPath is the path used to read the file content, if it differs
+	// from FullPath: the slash-separated path to the file within FS if FS
+	// is set, otherwise the path to the file as recorded by the OS
+	// filesystem if it differs from FullPath only in Unicode normalization
+	// (see paths.Normalize)
+	readPath string
+
+	// changed indicates whether the file was skipped when generating
+	// checksums because it changed since it was found (see Changed)
+	changed bool
+
+	// inUse indicates whether the file was skipped when generating
+	// checksums because it was in use by another process (see InUseFiles)
+	inUse bool
+}
+
+// NewFileMatch creates a FileMatch from the provided file metadata,
+// retaining only the details used in later calculations.
+func NewFileMatch(fullPath string, info fs.FileInfo) FileMatch {
+
+	fm := FileMatch{
+		FullPath:    fullPath,
+		size:        info.Size(),
+		modTimeSec:  info.ModTime().Unix(),
+		modTimeNsec: int32(info.ModTime().Nanosecond()), // #nosec G115; always within [0, 999999999]
+		mode:        info.Mode(),
+	}
+
+	if hinter, ok := info.Sys().(ContentHinter); ok {
+		if hint, ok := hinter.ContentHint(); ok {
+			fm.contentHint = hint
+		}
+	}
+
+	return fm
+}
+
+// NewRecordedFileMatch creates a FileMatch from the details recorded for a
+// file in a previously generated report, without accessing the file. This
+// allows reports to be analyzed again after the fact (e.g., on another
+// system). The modification time is the zero value if not recorded.
+func NewRecordedFileMatch(fullPath string, size int64, modified time.Time, checksum checksums.SHA256Checksum, keep bool) FileMatch {
+	return FileMatch{
+		FullPath:    fullPath,
+		Checksum:    checksum,
+		Keep:        keep,
+		size:        size,
+		modTimeSec:  modified.Unix(),
+		modTimeNsec: int32(modified.Nanosecond()), // #nosec G115; always within [0, 999999999]
+	}
+}
+
+// Name returns the base name of the file.
+func (fm FileMatch) Name() string {
+	if fm.FS != nil {
+		return paths.Normalize(path.Base(fm.FSPath()))
+	}
+
+	return filepath.Base(fm.FullPath)
+}
+
+// ParentDirectory returns the directory containing the file; analogue to
+// the Name method.
+func (fm FileMatch) ParentDirectory() string {
+	if fm.FS == nil && !s3.IsURL(fm.FullPath) {
+		return filepath.Dir(fm.FullPath)
+	}
+
+	// Paths of files within other filesystems are slash-separated and may
+	// be prefixed with a location (e.g., "s3://bucket") whose separators
+	// path.Dir would collapse.
+	i := strings.LastIndex(fm.FullPath, "/")
+	switch {
+	case i < 0:
+		return "."
+	case i == 0:
+		return "/"
+	default:
+		return fm.FullPath[:i]
+	}
+}
+
+// FSPath returns the slash-separated path to the file within FS. This is
+// used to read the file content when FS is set.
+func (fm FileMatch) FSPath() string {
+	if fm.readPath != "" {
+		return fm.readPath
+	}
+
+	return fm.FullPath
+}
+
+// osPath returns the path used to read the file from the OS filesystem.
+func (fm FileMatch) osPath() string {
+	if fm.readPath != "" {
+		return fm.readPath
+	}
+
+	return fm.FullPath
+}
+
+// Size returns the length of the file in bytes.
+func (fm FileMatch) Size() int64 {
+	return fm.size
+}
+
+// ModTime returns the modification time of the file.
+func (fm FileMatch) ModTime() time.Time {
+	return time.Unix(fm.modTimeSec, int64(fm.modTimeNsec))
+}
+
+// Mode returns the file mode bits of the file.
+func (fm FileMatch) Mode() fs.FileMode {
+	return fm.mode
+}
+
+// ContentHinter is implemented by file metadata (as returned from the Sys
+// method of the embedded FileInfo) able to provide a cheap content
+// fingerprint (e.g., an MD5 based ETag for an object stored remotely). Files
+// of the same size with differing hints are known to differ in content
+// without reading them.
+type ContentHinter interface {
+	ContentHint() (string, bool)
 }
 
 // FileMatches is a slice of FileMatch objects that represents the search
@@ -78,25 +405,215 @@ type FileChecksumIndex map[checksums.SHA256Checksum]FileMatches
 // methods, notably just prior to application exit via console and the first
 // sheet in the generated workbook.
 type DuplicateFilesSummary struct {
-	TotalEvaluatedFiles int
+	TotalEvaluatedFiles int `json:"total_evaluated_files"`
 
 	// Number of sets based on identical file size
-	FileSizeMatchSets int
+	FileSizeMatchSets int `json:"file_size_match_sets"`
 
 	// Number of sets based on identical file hash
-	FileHashMatchSets int
+	FileHashMatchSets int `json:"file_hash_match_sets"`
 
 	// Identical files count based on file size
-	FileSizeMatches int
+	FileSizeMatches int `json:"file_size_matches"`
 
 	// Identical files count based on file hash
-	FileHashMatches int
+	FileHashMatches int `json:"file_hash_matches"`
 
 	// Wasted space for duplicate file sets in bytes
-	WastedSpace int64
+	WastedSpace int64 `json:"wasted_space"`
 
 	// DuplicateCount represents the number of duplicated files
-	DuplicateCount int
+	DuplicateCount int `json:"duplicate_count"`
+
+	// NearDuplicateSets is the number of sets of visually identical images
+	// found using perceptual hashes (if requested)
+	NearDuplicateSets int `json:"near_duplicate_sets"`
+
+	// EXIFMatchSets is the number of sets of image files with differing
+	// content but matching EXIF capture time and dimensions
+	EXIFMatchSets int `json:"exif_match_sets"`
+
+	// RelatedFileSets is the number of sets of files with near-identical
+	// names but differing content (if requested)
+	RelatedFileSets int `json:"related_file_sets"`
+
+	// SimilarFileSets is the number of sets of highly similar documents and
+	// archives found using fuzzy hashes (if requested)
+	SimilarFileSets int `json:"similar_file_sets"`
+
+	// ContentEqualSets is the number of confirmed duplicate file sets with
+	// identical image content but possibly differing metadata
+	ContentEqualSets int `json:"content_equal_sets"`
+
+	// CrossRootSets is the number of confirmed duplicate file sets with
+	// files found in more than one evaluated path (if multiple paths were
+	// evaluated)
+	CrossRootSets int `json:"cross_root_sets"`
+
+	// IntraRootSets is the number of confirmed duplicate file sets with all
+	// files found within a single evaluated path (if multiple paths were
+	// evaluated)
+	IntraRootSets int `json:"intra_root_sets"`
+
+	// BytesVerifiedSets is the number of confirmed duplicate file sets
+	// whose files were compared byte for byte (if requested)
+	BytesVerifiedSets int `json:"bytes_verified_sets"`
+
+	// ByteMismatches is the number of files excluded from duplicate file
+	// sets because their content differed despite a matching checksum (if
+	// byte for byte verification was requested)
+	ByteMismatches int `json:"byte_mismatches"`
+
+	// ByteComparisonSets is the number of confirmed duplicate file sets
+	// found by comparing file content byte for byte instead of checksums
+	// (if requested)
+	ByteComparisonSets int `json:"byte_comparison_sets"`
+
+	// PartialSets is the number of duplicate file sets found using checksums
+	// of only the first bytes of very large files and not verified byte
+	// for byte (if requested)
+	PartialSets int `json:"partial_sets"`
+
+	// SmallFiles is the number of files below the file size threshold
+	// which were listed separately instead of being evaluated (if
+	// requested)
+	SmallFiles int `json:"small_files"`
+
+	// ZeroByteFiles is the number of zero-byte files among SmallFiles
+	ZeroByteFiles int `json:"zero_byte_files"`
+
+	// BaselineSets is the number of confirmed duplicate file sets excluded
+	// since all files in the set were unmodified since the baseline
+	// snapshot was written (if requested)
+	BaselineSets int `json:"baseline_sets"`
+
+	// Extensions is the breakdown of duplicate files and wasted space by
+	// file extension, largest wasted space first
+	Extensions ExtensionSummaries `json:"extensions"`
+
+	// ChangedFiles is the number of files skipped because their size or
+	// modification time changed between finding the file and generating its
+	// checksum (e.g., files still being downloaded)
+	ChangedFiles int `json:"changed_files"`
+
+	// InUseFiles lists the full path of files skipped because they were in
+	// use (locked) by another process when their checksum was generated
+	InUseFiles []string `json:"in_use_files,omitempty"`
+
+	// CSVFiles lists the numbered parts of the generated CSV file, in
+	// order, if it was split due to its size (if requested)
+	CSVFiles []string `json:"csv_files,omitempty"`
+}
+
+// ScanParameters records the user-specified settings used when evaluating
+// paths for duplicate files.
+type ScanParameters struct {
+
+	// Paths evaluated for duplicate files
+	Paths []string `json:"paths"`
+
+	// RecursiveSearch indicates whether paths were crawled recursively
+	RecursiveSearch bool `json:"recursive_search"`
+
+	// FileSizeThreshold is the minimum size in bytes for evaluated files
+	FileSizeThreshold int64 `json:"file_size_threshold"`
+
+	// FileDuplicatesThreshold is the number of files of the same file size
+	// needed before duplicate validation logic is applied
+	FileDuplicatesThreshold int `json:"file_duplicates_threshold"`
+
+	// IgnoreErrors indicates whether minor errors were ignored
+	IgnoreErrors bool `json:"ignore_errors"`
+
+	// IgnoreErrorsFor lists the categories of errors ignored, if ignoring
+	// errors was limited to specific categories
+	IgnoreErrorsFor []errreport.Category `json:"ignore_errors_for,omitempty"`
+}
+
+// ScanErrorCounts records the number of errors ignored (as requested) while
+// evaluating paths for duplicate files.
+type ScanErrorCounts struct {
+
+	// Path is the number of errors ignored while crawling paths
+	Path int `json:"path"`
+
+	// Checksum is the number of errors ignored while generating checksums
+	Checksum int `json:"checksum"`
+
+	// PerceptualHash is the number of errors ignored while generating
+	// perceptual hashes for images
+	PerceptualHash int `json:"perceptual_hash"`
+
+	// FuzzyHash is the number of errors ignored while generating fuzzy
+	// hashes for documents and archives
+	FuzzyHash int `json:"fuzzy_hash"`
+
+	// EXIF is the number of errors ignored while reading EXIF metadata
+	EXIF int `json:"exif"`
+
+	// AudioTags is the number of errors ignored while reading audio tags
+	AudioTags int `json:"audio_tags"`
+
+	// VerifyBytes is the number of errors ignored while comparing file
+	// content byte for byte
+	VerifyBytes int `json:"verify_bytes"`
+
+	// Allocated is the number of errors ignored while retrieving the space
+	// allocated on disk to files
+	Allocated int `json:"allocated_size"`
+}
+
+// SummaryReport is a machine-readable collection of the
+// DuplicateFilesSummary along with details of the scan that produced it.
+// This is intended for use by dashboards or other tooling that trends
+// results over time.
+type SummaryReport struct {
+	Summary        DuplicateFilesSummary `json:"summary"`
+	Parameters     ScanParameters        `json:"parameters"`
+	Errors         ScanErrorCounts       `json:"errors"`
+	StartTime      time.Time             `json:"start_time"`
+	EndTime        time.Time             `json:"end_time"`
+	ElapsedTime    string                `json:"elapsed_time"`
+	ElapsedSeconds float64               `json:"elapsed_seconds"`
+}
+
+// NewSummaryReport creates a SummaryReport using the provided scan details,
+// recording the current time as the end time of the scan.
+func NewSummaryReport(summary DuplicateFilesSummary, params ScanParameters, errCounts ScanErrorCounts, startTime time.Time) SummaryReport {
+
+	endTime := time.Now()
+	elapsed := endTime.Sub(startTime)
+
+	return SummaryReport{
+		Summary:        summary,
+		Parameters:     params,
+		Errors:         errCounts,
+		StartTime:      startTime,
+		EndTime:        endTime,
+		ElapsedTime:    elapsed.String(),
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+}
+
+// WriteJSON writes the SummaryReport to the specified file in JSON format.
+func (sr SummaryReport) WriteJSON(filename string) error {
+
+	if !paths.PathExists(filepath.Dir(filepath.Clean(filename))) {
+		return fmt.Errorf("parent directory for specified summary file to create does not exist")
+	}
+
+	data, err := json.MarshalIndent(sr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary as JSON: %w", err)
+	}
+
+	// The summary file is not sensitive, but there is no need to allow
+	// others to modify it.
+	if err := os.WriteFile(filepath.Clean(filename), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write summary file %q: %w", filename, err)
+	}
+
+	return nil
 }
 
 // TotalFileSize returns the cumulative size of all files in the slice in bytes
@@ -124,6 +641,38 @@ func (fm FileMatch) SizeHR() string {
 	return units.ByteCountIEC(fm.Size())
 }
 
+// GenerateCheckSum returns a SHA256 checksum of the file content, reading
+// from the filesystem recorded for the file.
+func (fm FileMatch) GenerateCheckSum() (checksums.SHA256Checksum, error) {
+	if fm.FS != nil {
+		return checksums.GenerateCheckSumFS(fm.FS, fm.FSPath())
+	}
+
+	return checksums.GenerateCheckSum(fm.osPath())
+}
+
+// GenerateCheckSums returns a SHA256 checksum of the file content along with
+// a hash generated using the specified additional algorithm, reading the
+// file only once.
+func (fm FileMatch) GenerateCheckSums(extra checksums.Algorithm) (checksums.SHA256Checksum, string, error) {
+	if fm.FS != nil {
+		return checksums.GenerateCheckSumsFS(fm.FS, fm.FSPath(), extra)
+	}
+
+	return checksums.GenerateCheckSums(fm.osPath(), extra)
+}
+
+// GenerateContentCheckSum returns a SHA256 checksum of the image content of
+// the file, ignoring metadata, reading from the filesystem recorded for the
+// file.
+func (fm FileMatch) GenerateContentCheckSum() (checksums.SHA256Checksum, error) {
+	if fm.FS != nil {
+		return checksums.GenerateContentCheckSumFS(fm.FS, fm.FSPath())
+	}
+
+	return checksums.GenerateContentCheckSum(fm.osPath())
+}
+
 // SortByModTimeAsc sorts slice of FileMatch objects in ascending order with
 // older values listed first.
 func (fm FileMatches) SortByModTimeAsc() {
@@ -174,9 +723,40 @@ func MergeFileSizeIndexes(fileSizeIndexes ...FileSizeIndex) FileSizeIndex {
 	return mergedFileSizeIndex
 }
 
+// PendingChecksumBytes returns the total size of the files in the index
+// which UpdateChecksumsWith reads to generate checksums (and additional
+// hashes using the specified algorithm, if any). Files with the requested
+// values already recorded are not included.
+func (fi FileSizeIndex) PendingChecksumBytes(extra checksums.Algorithm) int64 {
+
+	var total int64
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if file.Checksum != "" && (extra == "" || file.ExtraChecksum != "") {
+				continue
+			}
+			total += file.Size()
+		}
+	}
+
+	return total
+}
+
 // UpdateChecksums acts as a wrapper around the UpdateChecksums method for
-// FileMatches objects
-func (fi FileSizeIndex) UpdateChecksums(ignoreErrors bool) error {
+// FileMatches objects. The number of errors ignored (as requested) is
+// returned along with any error that was not ignored.
+func (fi FileSizeIndex) UpdateChecksums(ignoreErrors bool) (int, error) {
+	return fi.UpdateChecksumsWith(ignoreErrors, "", nil)
+}
+
+// UpdateChecksumsWith acts as a wrapper around the UpdateChecksumsWith
+// method for FileMatches objects. Progress is recorded using the provided
+// meter (if any). The number of errors ignored (as requested) is returned
+// along with any error that was not ignored.
+func (fi FileSizeIndex) UpdateChecksumsWith(ignoreErrors bool, extra checksums.Algorithm, meter *progress.Meter) (int, error) {
+
+	var ignoredErrors int
 
 	// for key, fileMatches := range combinedFileSizeIndex {
 	for _, fileMatches := range fi {
@@ -184,47 +764,144 @@ func (fi FileSizeIndex) UpdateChecksums(ignoreErrors bool) error {
 		// every key is a file size
 		// every value is a slice of files of that file size
 
-		if err := fileMatches.UpdateChecksums(ignoreErrors); err != nil {
+		// Errors which may be ignored are already logged and counted for
+		// each file; any error returned was not ignored.
+		ignored, err := fileMatches.UpdateChecksumsWith(ignoreErrors, extra, meter)
+		ignoredErrors += ignored
+		if err != nil {
 
 			// DEBUG
 			log.Println("Error encountered:", err)
-			if !ignoreErrors {
-				return err
+			return ignoredErrors, err
+		}
+	}
+
+	return ignoredErrors, nil
+}
+
+// UpdateChecksums generates checksum values for each file tracked by a
+// FileMatch entry and updates the associated FileMatch.Checksum field value.
+// The number of errors ignored (as requested) is returned along with any
+// error that was not ignored.
+func (fm FileMatches) UpdateChecksums(ignoreErrors bool) (int, error) {
+	return fm.updateChecksums(ignoreErrors, FileMatch.GenerateCheckSum, nil)
+}
+
+// UpdateChecksumsWith generates checksum values for each file (see
+// UpdateChecksums) along with a hash generated using the specified
+// additional algorithm, recorded in the FileMatch.ExtraChecksum field. Each
+// file is read only once. No additional hash is generated if no algorithm
+// is specified. Files with both a checksum and additional hash already
+// recorded are skipped, as are files which changed since they were found
+// (see Changed and RetryChangedFiles). The size of each file read is
+// recorded using the provided meter (if any).
+func (fm FileMatches) UpdateChecksumsWith(ignoreErrors bool, extra checksums.Algorithm, meter *progress.Meter) (int, error) {
+
+	if extra == "" {
+		return fm.updateChecksums(ignoreErrors, FileMatch.GenerateCheckSum, meter)
+	}
+
+	var ignoredErrors int
+
+	for index, file := range fm {
+
+		if file.Checksum != "" && file.ExtraChecksum != "" {
+			continue
+		}
+
+		if fm[index].skipChanged() {
+			meter.Add(file.Size())
+			continue
+		}
+
+		checksum, extraChecksum, err := file.GenerateCheckSums(extra)
+		meter.Add(file.Size())
+		if err != nil {
+
+			if fm[index].skipInUse(err) {
+				continue
 			}
-			// DEBUG
+
+			if !errreport.Ignore(ignoreErrors, err) {
+				return ignoredErrors, err
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
 			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(file.FullPath, errreport.OperationHash, err)
+
 			continue
 		}
+
+		fm[index].Checksum = checksum
+		fm[index].ExtraChecksum = extraChecksum
 	}
 
-	// TODO: Return bool and error instead of just error?
-	// This would allow returning true as in success, but also
-	// provide the original error that we chose to ignore.
-	return nil
+	return ignoredErrors, nil
 }
 
-// UpdateChecksums generates checksum values for each file tracked by a
-// FileMatch entry and updates the associated FileMatch.Checksum field value
-func (fm FileMatches) UpdateChecksums(ignoreErrors bool) error {
+// UpdateContentChecksums generates content-only checksums (ignoring image
+// metadata) for all files in the index. The number of errors ignored (as
+// requested) is also returned.
+func (fi FileSizeIndex) UpdateContentChecksums(ignoreErrors bool) (int, error) {
+
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		ignored, err := fileMatches.updateChecksums(ignoreErrors, FileMatch.GenerateContentCheckSum, nil)
+		ignoredErrors += ignored
+		if err != nil {
+			return ignoredErrors, err
+		}
+	}
+
+	return ignoredErrors, nil
+}
+
+// updateChecksums generates checksums for each FileMatch object using the
+// provided function. Files with a checksum already recorded (e.g., reused
+// from a previous report; see ApplyRecordedChecksums) are skipped, as are
+// files which changed since they were found (see Changed). The size of each
+// file read is recorded using the provided meter (if any). The number of
+// errors ignored (as requested) is also returned.
+func (fm FileMatches) updateChecksums(ignoreErrors bool, generate func(FileMatch) (checksums.SHA256Checksum, error), meter *progress.Meter) (int, error) {
 
-	var err error
+	var ignoredErrors int
 
 	// loop over each FileMatch object and generate a checksum
 	// https://yourbasic.org/golang/gotcha-change-value-range/
 	for index, file := range fm {
 
+		if file.Checksum != "" {
+			continue
+		}
+
+		if fm[index].skipChanged() {
+			meter.Add(file.Size())
+			continue
+		}
+
 		// DEBUG
 		// log.Println("Generating checksum for:", file.FullPath)
-		result, err := checksums.GenerateCheckSum(file.FullPath)
+		result, err := generate(file)
+		meter.Add(file.Size())
 		if err != nil {
 
-			if !ignoreErrors {
-				return err
+			if fm[index].skipInUse(err) {
+				continue
+			}
+
+			if !errreport.Ignore(ignoreErrors, err) {
+				return ignoredErrors, err
 			}
 
 			// WARN
 			log.Println("Error encountered:", err)
 			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(file.FullPath, errreport.OperationHash, err)
 
 			continue
 
@@ -237,69 +914,114 @@ func (fm FileMatches) UpdateChecksums(ignoreErrors bool) error {
 
 	}
 
-	// Relying on nil Zero value
-	return err
+	return ignoredErrors, nil
 }
 
 // GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as a
 // header row.
-func (fi FileChecksumIndex) GenerateCSVHeaderRow() []string {
-	return []string{
+func (fi FileChecksumIndex) GenerateCSVHeaderRow(columns ReportColumns) []string {
+	row := []string{
 		CSVDirectoryColumnHeaderName,
 		CSVFileColumnHeaderName,
 		CSVSizeColumnHeaderName,
 		CSVSizeInBytesDirectoryColumnHeaderName,
 		CSVChecksumColumnHeaderName,
 		CSVRemoveFileColumnHeaderName,
+		CSVSetIDColumnHeaderName,
+		CSVModifiedColumnHeaderName,
+		CSVKeepColumnHeaderName,
+		CSVRoleColumnHeaderName,
 	}
+
+	return append(row, columns.headers()...)
 }
 
 // GenerateEmptyCSVDataRow returns a string slice for use with a CSV Writer as a
 // empty data (non-header) row. This is used as a separator between sets of
 // duplicate files.
-func (fm FileMatches) GenerateEmptyCSVDataRow() []string {
-	return []string{
-		"",
-		"",
-		"",
-		"",
-		"",
-		"",
-	}
+func (fm FileMatches) GenerateEmptyCSVDataRow(columns ReportColumns) []string {
+	return make([]string, len(FileChecksumIndex{}.GenerateCSVHeaderRow(columns)))
 }
 
 // GenerateCSVDataRow returns a string slice for use with a CSV Writer as a
-// data (non-header) row
-func (fm FileMatch) GenerateCSVDataRow() []string {
-	return []string{
-		fm.ParentDirectory,
+// data (non-header) row. The set ID and scope identify the duplicate file
+// set that the file belongs to (see FileMatches.Scope).
+func (fm FileMatch) GenerateCSVDataRow(setID int, scope string, columns ReportColumns) []string {
+	row := []string{
+		fm.directoryField(columns),
 		fm.Name(),
 		fm.SizeHR(),
 		strconv.FormatInt(fm.Size(), 10),
 		fm.Checksum.String(),
 		"",
+		strconv.Itoa(setID),
+		fm.modifiedField(),
+		strconv.FormatBool(fm.Keep),
+		fm.Role(),
+	}
+
+	return append(row, fm.optionalFields(scope, columns)...)
+}
+
+// Role returns the role of the file within its duplicate file set: the
+// original to keep or a duplicate of it.
+func (fm FileMatch) Role() string {
+	if fm.Keep {
+		return RoleOriginal
+	}
+
+	return RoleDuplicate
+}
+
+// directoryField returns the parent directory of the file formatted for use
+// in CSV and Excel output, relative to the evaluated path if requested. The
+// full path is used if a relative path cannot be determined.
+func (fm FileMatch) directoryField(columns ReportColumns) string {
+	if !columns.RelativePaths || fm.Root == "" {
+		return fm.ParentDirectory()
 	}
+
+	rel, err := filepath.Rel(fm.Root, fm.ParentDirectory())
+	if err != nil {
+		return fm.ParentDirectory()
+	}
+
+	return filepath.ToSlash(rel)
 }
 
-// NewFileSizeIndex optionally recursively processes a provided path and returns a
-// slice of FileMatch objects
-func NewFileSizeIndex(recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64, dirs ...string) (FileSizeIndex, error) {
+// modifiedField returns the modification time of the file formatted for use
+// in CSV output, or an empty string if the modification time is not known.
+func (fm FileMatch) modifiedField() string {
+	if fm.ModTime().IsZero() {
+		return ""
+	}
+
+	return fm.ModTime().Format(time.RFC3339)
+}
+
+// NewFileSizeIndex optionally recursively processes a provided path and
+// returns a slice of FileMatch objects along with the number of errors
+// ignored (as requested) while processing the paths. See ProcessPath for
+// details of the walkers and excludes values.
+func NewFileSizeIndex(recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64, walkers int, excludes DirExcludes, dirs ...string) (FileSizeIndex, int, error) {
 
 	combinedFileSizeIndex := make(FileSizeIndex)
+	var ignoredErrors int
 
 	for _, path := range dirs {
 
 		if !paths.PathExists(path) {
-			return nil, fmt.Errorf("provided path %q does not exist", path)
+			return nil, ignoredErrors, fmt.Errorf("%w: provided path %q does not exist", paths.ErrPathNotExist, path)
 		}
 
 		// DEBUG
 		log.Println("Path exists:", path)
 
 		// TODO: Call ProcessPath here
-		fileSizeIndex, err := ProcessPath(recursiveSearch, ignoreErrors, fileSizeThreshold, path)
+		fileSizeIndex, ignored, err := ProcessPath(recursiveSearch, ignoreErrors, fileSizeThreshold, walkers, excludes, path)
+		ignoredErrors += ignored
 		if err != nil {
-			return nil, fmt.Errorf("failed to process path %q: %w", path, err)
+			return nil, ignoredErrors, fmt.Errorf("failed to process path %q: %w", path, err)
 		}
 
 		// FIXME: This needs to occur at the end of each loop?
@@ -307,148 +1029,331 @@ func NewFileSizeIndex(recursiveSearch bool, ignoreErrors bool, fileSizeThreshold
 
 	}
 
-	// TODO: Safe to return err here, relying on it being nil if no errors
-	// were caught earlier?
-	// return combinedFileSizeIndex, err
-	return combinedFileSizeIndex, nil
+	// Paths which overlap (e.g., via symbolic links) could otherwise list
+	// the same file more than once.
+	if len(dirs) > 1 {
+		removed := combinedFileSizeIndex.RemoveSamePaths()
+
+		// DEBUG
+		log.Printf("Skipped %d files already listed under another path\n", removed)
+	}
+
+	return combinedFileSizeIndex, ignoredErrors, nil
+
+}
+
+// ProcessPath optionally recursively processes a provided path and returns a
+// slice of FileMatch objects along with the number of errors ignored (as
+// requested) while processing the path. If walkers is greater than one,
+// recursive searches read up to that many directories concurrently.
+// Subdirectories matching the provided excludes are skipped.
+func ProcessPath(recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64, walkers int, excludes DirExcludes, path string) (FileSizeIndex, int, error) {
+
+	// Record fully-qualified paths that can be referenced from any location
+	// in the filesystem.
+	fullyQualifiedPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"unable to determine absolute path to %q: %w",
+			path,
+			err,
+		)
+	}
+
+	// Paths within the OS-backed filesystem are converted back to native
+	// OS paths so that files can be opened directly by later steps (e.g.,
+	// checksum generation, backup and removal).
+	toFullPath := func(fsPath string) string {
+		return filepath.Join(fullyQualifiedPath, filepath.FromSlash(fsPath))
+	}
+
+	return processFS(
+		os.DirFS(fullyQualifiedPath),
+		".",
+		toFullPath,
+		false,
+		recursiveSearch,
+		ignoreErrors,
+		fileSizeThreshold,
+		walkers,
+		excludes,
+	)
+}
+
+// ProcessFS optionally recursively processes the root directory within the
+// provided filesystem and returns a slice of FileMatch objects along with
+// the number of errors ignored (as requested) while processing the path.
+//
+// This allows evaluating files from sources other than the OS filesystem
+// (e.g., in-memory filesystems, zip archives). The FullPath field for each
+// FileMatch uses slash-separated paths relative to the provided filesystem
+// and the FS field is set so that file content can be read from the same
+// filesystem later.
+func ProcessFS(fsys fs.FS, root string, recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64) (FileSizeIndex, int, error) {
+
+	if !fs.ValidPath(root) {
+		return nil, 0, fmt.Errorf("invalid path %q for filesystem", root)
+	}
+
+	toFullPath := func(fsPath string) string {
+		return fsPath
+	}
+
+	return processFS(
+		fsys,
+		root,
+		toFullPath,
+		true,
+		recursiveSearch,
+		ignoreErrors,
+		fileSizeThreshold,
+		1,
+		nil,
+	)
+}
+
+// ProcessRemoteFS behaves like ProcessFS, but records the FullPath field for
+// each FileMatch using the provided location (e.g., "s3://bucket") as a
+// prefix. This allows files from remote storage
+// to be reported alongside local files while still reading content via the
+// provided filesystem.
+func ProcessRemoteFS(fsys fs.FS, location string, root string, recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64, excludes DirExcludes) (FileSizeIndex, int, error) {
+
+	if !fs.ValidPath(root) {
+		return nil, 0, fmt.Errorf("invalid path %q for filesystem", root)
+	}
+
+	location = strings.TrimSuffix(location, "/")
+
+	toFullPath := func(fsPath string) string {
+		if fsPath == "." {
+			return location
+		}
+		return location + "/" + fsPath
+	}
+
+	return processFS(
+		fsys,
+		root,
+		toFullPath,
+		true,
+		recursiveSearch,
+		ignoreErrors,
+		fileSizeThreshold,
+		1,
+		excludes,
+	)
+}
+
+// processFS handles crawling the root directory within the provided
+// filesystem on behalf of ProcessPath and ProcessFS. The toFullPath function
+// converts paths within the filesystem to the value recorded as the
+// FileMatch.FullPath field. If recordFS is set, the filesystem is recorded
+// with each FileMatch so that content is read from the same filesystem
+// instead of from the OS filesystem. If walkers is greater than one,
+// recursive searches read up to that many directories concurrently.
+// Subdirectories (other than the root) matching the provided excludes are
+// skipped along with their content.
+func processFS(
+	fsys fs.FS,
+	root string,
+	toFullPath func(string) string,
+	recordFS bool,
+	recursiveSearch bool,
+	ignoreErrors bool,
+	fileSizeThreshold int64,
+	walkers int,
+	excludes DirExcludes,
+) (FileSizeIndex, int, error) {
+
+	fileSizeIndex := make(FileSizeIndex)
+	var ignoredErrors int
+
+	// Record the root alongside each file so that duplicates found within
+	// a single root can be told apart from those found across roots.
+	rootPath := paths.Normalize(toFullPath(root))
+
+	// log.Println("RecursiveSearch:", recursiveSearch)
+
+	// addFile applies our criteria for evaluated files and records the file
+	// in our index based on file size if all criteria are met.
+	addFile := func(fsPath string, info fs.FileInfo) {
+
+		// ignore directories
+		if info.IsDir() {
+			return
+		}
+
+		// ignore anything other than regular files (e.g., symlinks, named
+		// pipes, devices); attempting to read some of these types (e.g., a
+		// named pipe) would block indefinitely
+		if !info.Mode().IsRegular() {
+			return
+		}
+
+		// ignore files below the size threshold
+		if info.Size() < fileSizeThreshold {
+			return
+		}
+
+		// Paths are recorded in a consistent Unicode normalization form
+		// so that paths from different systems (e.g., macOS and Linux)
+		// compare as equal; the original path is retained to read the file.
+		diskPath := toFullPath(fsPath)
+		fullPath := paths.Normalize(diskPath)
+
+		fileMatch := NewFileMatch(fullPath, info)
+		switch {
+		case recordFS:
+			fileMatch.FS = fsys
+			if fsPath != fullPath {
+				fileMatch.readPath = fsPath
+			}
+		case diskPath != fullPath:
+			fileMatch.readPath = diskPath
+		}
+		fileMatch.Root = rootPath
+
+		// If we made it to this point, then we must assume that the file
+		// has met all criteria to be evaluated by this application. Let's
+		// add the file to our slice of files of the same size using our
+		// index based on file size.
+		fileSizeIndex[info.Size()] = append(fileSizeIndex[info.Size()], fileMatch)
+	}
+
+	// skipDir indicates whether the content of a subdirectory should be
+	// skipped since it matches the provided excludes.
+	skipDir := func(fsPath string, name string) bool {
+		if !excludes.Match(name) {
+			return false
+		}
+
+		// DEBUG
+		log.Printf("Skipping excluded directory %q\n", toFullPath(fsPath))
 
-}
+		return true
+	}
 
-// ProcessPath optionally recursively processes a provided path and returns a
-// slice of FileMatch objects
-func ProcessPath(recursiveSearch bool, ignoreErrors bool, fileSizeThreshold int64, path string) (FileSizeIndex, error) {
+	// Reading directories concurrently helps when metadata calls dominate
+	// (e.g., network shares with many small files). Files are sorted by
+	// path afterwards so that results do not depend on scheduling.
+	if recursiveSearch && walkers > 1 {
+		ignored, err := walkParallel(fsys, root, walkers, ignoreErrors, toFullPath, skipDir, addFile)
+		ignoredErrors += ignored
+		if err != nil {
+			return fileSizeIndex, ignoredErrors, err
+		}
 
-	fileSizeIndex := make(FileSizeIndex)
-	var err error
+		for _, fileMatches := range fileSizeIndex {
+			fileMatches.SortByPath()
+		}
 
-	// log.Println("RecursiveSearch:", recursiveSearch)
+		return fileSizeIndex, ignoredErrors, nil
+	}
 
 	if recursiveSearch {
 
-		// Walk walks the file tree rooted at path, calling the anonymous function
-		// for each file or directory in the tree, including path. All errors that
-		// arise visiting files and directories are filtered by the anonymous
-		// function. The files are walked in lexical order, which makes the output
-		// deterministic but means that for very large directories Walk can be
-		// inefficient. Walk does not follow symbolic links.
-		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		// WalkDir walks the file tree rooted at root, calling the anonymous
+		// function for each file or directory in the tree, including root.
+		// All errors that arise visiting files and directories are filtered
+		// by the anonymous function. The files are walked in lexical order.
+		// WalkDir does not follow symbolic links.
+		err := fs.WalkDir(fsys, root, func(fsPath string, d fs.DirEntry, err error) error {
 
 			// If an error is received, check to see whether we should ignore
 			// it or return it. If we return a non-nil error, this will stop
-			// the filepath.Walk() function from continuing to walk the path,
-			// and your main function will immediately move to the next line.
+			// the fs.WalkDir() function from continuing to walk the path.
 			if err != nil {
-				if !ignoreErrors {
+				if !errreport.Ignore(ignoreErrors, err) {
 					return err
 				}
 
 				// WARN
 				log.Println("Error encountered:", err)
 				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(toFullPath(fsPath), errreport.OperationWalk, err)
 
+				// d may not be usable; skip this entry
+				return nil
 			}
 
-			// make sure we're not working with the root directory itself
-			if path != "." {
-
-				// ignore directories
-				if info.IsDir() {
-					return nil
+			// ignore directories; no need to retrieve full file metadata
+			if d.IsDir() {
+				if fsPath != root && skipDir(fsPath, d.Name()) {
+					return fs.SkipDir
 				}
+				return nil
+			}
 
-				// ignore files below the size threshold
-				if info.Size() < fileSizeThreshold {
-					return nil
+			info, err := d.Info()
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return fmt.Errorf(
+						"file %s renamed or removed since directory read: %w",
+						fsPath,
+						err,
+					)
 				}
 
-				// Since by this point we have already filtered out
-				// directories, `path` represents both the containing
-				// directory and the filename of the file being examined. Here
-				// we attempt to resolve the fully-qualified directory path
-				// containing the file for later use.
-				fullyQualifiedDirPath, err := filepath.Abs(filepath.Dir(path))
-				if err != nil {
-					return err
-				}
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(toFullPath(fsPath), errreport.OperationWalk, err)
 
-				// If we made it to this point, then we must assume that the file
-				// has met all criteria to be evaluated by this application.
-				// Let's add the file to our slice of files of the same size
-				// using our index based on file size.
-				fileSizeIndex[info.Size()] = append(
-					fileSizeIndex[info.Size()],
-					FileMatch{
-						FileInfo: info,
-						FullPath: path,
-						// Record fully-qualified path that can be referenced
-						// from any location in the filesystem.
-						ParentDirectory: fullyQualifiedDirPath,
-					})
+				return nil
 			}
 
-			return err
+			addFile(fsPath, info)
+
+			return nil
 		})
 
-	} else {
+		return fileSizeIndex, ignoredErrors, err
 
-		// If recursiveSearch is not enabled, process just the provided path
-		files, err := os.ReadDir(path)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf(
-				"error reading directory %s: %w",
-				path,
-				err,
-			)
-		}
+	// If recursiveSearch is not enabled, process just the provided path
+	files, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, ignoredErrors, fmt.Errorf(
+			"error reading directory %s: %w",
+			toFullPath(root),
+			err,
+		)
+	}
 
-		// Use []os.FileInfo returned from ioutil.ReadDir() to build slice of
-		// FileMatch objects
-		for _, file := range files {
+	for _, file := range files {
 
-			// ignore directories
-			if file.IsDir() {
-				continue
-			}
+		// ignore directories
+		if file.IsDir() {
+			continue
+		}
 
-			fileInfo, err := file.Info()
-			if err != nil {
-				return nil, fmt.Errorf(
+		fileInfo, err := file.Info()
+		if err != nil {
+			if !errreport.Ignore(ignoreErrors, err) {
+				return nil, ignoredErrors, fmt.Errorf(
 					"file %s renamed or removed since directory read: %w",
-					fileInfo.Name(),
+					file.Name(),
 					err,
 				)
 			}
 
-			// ignore files below the size threshold
-			if fileInfo.Size() < fileSizeThreshold {
-				continue
-			}
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(toFullPath(path.Join(root, file.Name())), errreport.OperationWalk, err)
 
-			// `path` is a flat directory structure (we are not using
-			// recursion in this code path)
-			fullyQualifiedDirPath, err := filepath.Abs(path)
-			if err != nil {
-				return nil, err
-			}
-
-			// If we made it to this point, then we must assume that the file
-			// has met all criteria to be evaluated by this application. Let's
-			// add the file to our slice of files of the same size using our
-			// index based on file size.
-			fileSizeIndex[fileInfo.Size()] = append(
-				fileSizeIndex[fileInfo.Size()],
-				FileMatch{
-					FileInfo: fileInfo,
-					FullPath: filepath.Join(path, file.Name()),
-					// Record fully-qualified path that can be referenced
-					// from any location in the filesystem.
-					ParentDirectory: fullyQualifiedDirPath,
-				})
+			continue
 		}
+
+		addFile(path.Join(root, file.Name()), fileInfo)
 	}
 
-	return fileSizeIndex, err
+	return fileSizeIndex, ignoredErrors, nil
 }
 
 // PruneFileSizeIndex removes map entries with single-entry slices which do
@@ -469,6 +1374,54 @@ func (fi FileSizeIndex) PruneFileSizeIndex(duplicatesThreshold int) {
 	}
 }
 
+// PruneByContentHint removes files from the index which are known to be
+// unique based on content hints provided by their metadata (see
+// ContentHinter), avoiding the cost of reading those files to generate
+// checksums. Only size groups where every file provides a hint are
+// evaluated; files within these groups whose hint is shared by fewer than
+// the specified number of files are removed. Any size groups left with
+// fewer files than the specified threshold are removed.
+func (fi FileSizeIndex) PruneByContentHint(duplicatesThreshold int) {
+
+	for fileSize, fileMatches := range fi {
+
+		hints := make(map[string]int, len(fileMatches))
+		allHinted := true
+		for _, file := range fileMatches {
+			hint, ok := contentHint(file)
+			if !ok {
+				allHinted = false
+				break
+			}
+			hints[hint]++
+		}
+
+		if !allHinted {
+			continue
+		}
+
+		var keep FileMatches
+		for _, file := range fileMatches {
+			hint, _ := contentHint(file)
+			if hints[hint] >= duplicatesThreshold {
+				keep = append(keep, file)
+			}
+		}
+
+		if len(keep) < duplicatesThreshold {
+			delete(fi, fileSize)
+			continue
+		}
+
+		fi[fileSize] = keep
+	}
+}
+
+// contentHint returns the content hint for the file if one is available.
+func contentHint(file FileMatch) (string, bool) {
+	return file.contentHint, file.contentHint != ""
+}
+
 // GetTotalFilesCount returns the total number of files in a
 // checksum-based file index
 func (fi FileSizeIndex) GetTotalFilesCount() int {
@@ -489,6 +1442,14 @@ func NewFileChecksumIndex(fi FileSizeIndex) FileChecksumIndex {
 	fileChecksumIndex := make(FileChecksumIndex)
 	for _, fileMatches := range fi {
 		for _, fileMatch := range fileMatches {
+
+			// Skip files where checksum generation failed (and the error
+			// was ignored as requested); grouping these files together
+			// would falsely report them as duplicates of each other.
+			if fileMatch.Checksum == "" {
+				continue
+			}
+
 			fileChecksumIndex[fileMatch.Checksum] = append(
 				fileChecksumIndex[fileMatch.Checksum],
 				fileMatch)
@@ -498,6 +1459,119 @@ func NewFileChecksumIndex(fi FileSizeIndex) FileChecksumIndex {
 	return fileChecksumIndex
 }
 
+// SplitContentChecksumFiles removes files selected for content-only
+// checksums by the provided function (based on filename) from the index,
+// returning them in a new FileSizeIndex. Since metadata may differ in size,
+// these files are not grouped by file size.
+func (fi FileSizeIndex) SplitContentChecksumFiles(selected func(filename string) bool) FileSizeIndex {
+
+	images := make(FileSizeIndex)
+
+	for key, fileMatches := range fi {
+		var remaining FileMatches
+		for _, file := range fileMatches {
+			if selected(file.Name()) {
+				images[file.Size()] = append(images[file.Size()], file)
+				continue
+			}
+			remaining = append(remaining, file)
+		}
+
+		if len(remaining) == 0 {
+			delete(fi, key)
+			continue
+		}
+		fi[key] = remaining
+	}
+
+	return images
+}
+
+// MergeFileChecksumIndexes receives one or more FileChecksumIndex objects
+// and merges entries between these objects, returning a combined
+// FileChecksumIndex object.
+func MergeFileChecksumIndexes(fileChecksumIndexes ...FileChecksumIndex) FileChecksumIndex {
+
+	combined := make(FileChecksumIndex)
+
+	for _, fileChecksumIndex := range fileChecksumIndexes {
+		for checksum, fileMatches := range fileChecksumIndex {
+			combined[checksum] = append(combined[checksum], fileMatches...)
+		}
+	}
+
+	return combined
+}
+
+// DuplicateSetFunc is called for each confirmed duplicate file set as it is
+// produced. Returning a non-nil error stops further processing.
+type DuplicateSetFunc func(checksum checksums.SHA256Checksum, fileMatches FileMatches) error
+
+// ConfirmDuplicates generates checksums for each set of potential duplicate
+// files (grouped by file size), calling fn (if provided) for each confirmed
+// duplicate file set as soon as checksums for the set have been generated.
+// Sets are processed from largest file size to smallest. The combined
+// FileChecksumIndex of all confirmed duplicate file sets is returned along
+// with the number of errors ignored (as requested).
+//
+// The provided context is checked before processing each set of potential
+// duplicate files, allowing long-running operations to be cancelled.
+func (fi FileSizeIndex) ConfirmDuplicates(
+	ctx context.Context,
+	ignoreErrors bool,
+	duplicatesThreshold int,
+	fn DuplicateSetFunc,
+) (FileChecksumIndex, int, error) {
+
+	fileChecksumIndex := make(FileChecksumIndex)
+	var ignoredErrors int
+
+	sizes := make([]int64, 0, len(fi))
+	for size := range fi {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	for _, size := range sizes {
+
+		if err := ctx.Err(); err != nil {
+			return fileChecksumIndex, ignoredErrors, err
+		}
+
+		fileMatches := fi[size]
+		ignored, err := fileMatches.UpdateChecksums(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return fileChecksumIndex, ignoredErrors, err
+		}
+
+		// Files with identical checksums also have identical file sizes, so
+		// the sets confirmed here are final.
+		sizeChecksumIndex := NewFileChecksumIndex(FileSizeIndex{size: fileMatches})
+		sizeChecksumIndex.PruneFileChecksumIndex(duplicatesThreshold)
+
+		confirmed := make([]checksums.SHA256Checksum, 0, len(sizeChecksumIndex))
+		for checksum := range sizeChecksumIndex {
+			confirmed = append(confirmed, checksum)
+		}
+		sort.Slice(confirmed, func(i, j int) bool { return confirmed[i] < confirmed[j] })
+
+		for _, checksum := range confirmed {
+			fileChecksumIndex[checksum] = sizeChecksumIndex[checksum]
+
+			if fn == nil {
+				continue
+			}
+
+			if err := fn(checksum, sizeChecksumIndex[checksum]); err != nil {
+				return fileChecksumIndex, ignoredErrors, err
+			}
+		}
+	}
+
+	return fileChecksumIndex, ignoredErrors, nil
+}
+
 // PruneFileChecksumIndex removes map entries with single-entry slices which
 // do not reflect duplicate files.
 func (fi FileChecksumIndex) PruneFileChecksumIndex(duplicatesThreshold int) {
@@ -538,30 +1612,35 @@ func (fi FileChecksumIndex) GetTotalFilesCount() int {
 }
 
 // GetWastedSpace calculates the wasted space from all confirmed duplicate
-// files
+// files. The space allocated on disk to each file is used if recorded (see
+// UpdateAllocatedSizes).
 func (fi FileChecksumIndex) GetWastedSpace() int64 {
 	var wastedSpace int64
 
-	// Loop over each duplicate file set in the file checksum index
-	// Get count of duplicate file set, minus 1 for the original
-	// Get file size in bytes of first entry in that duplicate file set
-	// Multiply file size by earlier count of duplicate file set
-	// Append cumulative file size of the set (minus original file)
+	// Total the space used by each duplicate file set, minus the file kept
+	// as the original.
 	for _, fileMatches := range fi {
+		wastedSpace += fileMatches.WastedSpace()
+	}
 
-		duplicateFileMatchEntries := (len(fileMatches) - 1)
+	return wastedSpace
+}
 
-		// FIXME: This shouldn't be reachable
-		// if len(fileMatches) == 0 {
-		// 	return 0, fmt.Errorf("attempted to calculate wasted space of empty duplicate file set")
-		// }
+// GetContentEqualSetsCount returns the number of duplicate file sets
+// confirmed using content-only checksums. Files within these sets have
+// identical image content, but their metadata (and so their bytes) may
+// differ.
+func (fi FileChecksumIndex) GetContentEqualSetsCount() int {
 
-		fileSize := fileMatches[0].Size()
-		wastedSpace += int64(duplicateFileMatchEntries) * fileSize
+	var sets int
+
+	for checksum := range fi {
+		if checksum.IsContentChecksum() {
+			sets++
+		}
 	}
 
-	// return wastedSpace, nil
-	return wastedSpace
+	return sets
 }
 
 // GetDuplicateFilesCount returns the number of non-original files in a
@@ -579,8 +1658,9 @@ func (fi FileChecksumIndex) GetDuplicateFilesCount() int {
 }
 
 // WriteFileMatchesWorkbook is a prototype method to generate an Excel
-// workbook from duplicate file details
-func (fi FileChecksumIndex) WriteFileMatchesWorkbook(filename string, summary DuplicateFilesSummary) error {
+// workbook from duplicate file details. If provided, directory summaries are
+// written to a separate sheet.
+func (fi FileChecksumIndex) WriteFileMatchesWorkbook(filename string, summary DuplicateFilesSummary, order SortOrder, columns ReportColumns, directories DirectorySummaries) error {
 
 	if !paths.PathExists(filepath.Dir(filename)) {
 		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
@@ -707,179 +1787,257 @@ func (fi FileChecksumIndex) WriteFileMatchesWorkbook(filename string, summary Du
 		return err
 	}
 
-	for duplicateFileSetIndex, fileMatches := range fi {
+	// Chart the wasted space by file extension and the duplicate file sets
+	// by file size alongside the summary
+	if err := fi.writeSummaryCharts(f, summarySheet, summary.Extensions); err != nil {
+		return err
+	}
 
-		// sheetHeader := []string{"directory", "file", "size", "checksum"}
+	if len(directories) > 0 {
 
-		// Create a new sheet for duplicate file metadata
-		duplicateFileSetIndexSheet := duplicateFileSetIndex.String()
-		if _, err := f.NewSheet(duplicateFileSetIndexSheet); err != nil {
+		directoriesSheet := "Directories"
+		if _, err := f.NewSheet(directoriesSheet); err != nil {
 			return fmt.Errorf(
 				"failed to add new worksheet: %w",
 				err,
 			)
 		}
 
-		headerEntries := []excelSheetEntry{
-			{
-				Sheet: duplicateFileSetIndexSheet,
-				Cell:  "A1",
-				Value: "directory",
-			},
-			{
-				Sheet: duplicateFileSetIndexSheet,
-				Cell:  "B1",
-				Value: "file",
-			},
-			{
-				Sheet: duplicateFileSetIndexSheet,
-				Cell:  "C1",
-				Value: "size",
-			},
-			{
-				Sheet: duplicateFileSetIndexSheet,
-				Cell:  "D1",
-				Value: "size in bytes",
-			},
-			{
-				Sheet: duplicateFileSetIndexSheet,
-				Cell:  "E1",
-				Value: "checksum",
-			},
-		}
-
-		// Write out the sheet header
-		if err := writeExcelSheet(f, headerEntries...); err != nil {
-			return err
+		var directoryEntries []excelSheetEntry
+
+		for i, header := range directories.GenerateCSVHeaderRow() {
+			cell, err := excelize.CoordinatesToCellName(i+1, 1)
+			if err != nil {
+				return err
+			}
+			directoryEntries = append(directoryEntries, excelSheetEntry{
+				Sheet: directoriesSheet,
+				Cell:  cell,
+				Value: strings.ReplaceAll(header, "_", " "),
+			})
 		}
 
-		for index, file := range fileMatches {
+		for index, directory := range directories {
 
 			// Excel starts at 1, but our header occupies row 1, so increment
 			// by +2 to account for that
 			row := index + 2
 
-			dataEntries := []excelSheetEntry{
-				{
-					Sheet: duplicateFileSetIndexSheet,
-					Cell:  fmt.Sprintf("A%d", row),
-					Value: file.ParentDirectory,
-				},
-				{
-					Sheet: duplicateFileSetIndexSheet,
-					Cell:  fmt.Sprintf("B%d", row),
-					Value: file.Name(),
-				},
-				{
-					Sheet: duplicateFileSetIndexSheet,
-					Cell:  fmt.Sprintf("C%d", row),
-					Value: file.SizeHR,
-				},
-				{
-					Sheet: duplicateFileSetIndexSheet,
-					Cell:  fmt.Sprintf("D%d", row),
-					Value: file.Size(),
-				},
-				{
-					Sheet: duplicateFileSetIndexSheet,
-					Cell:  fmt.Sprintf("E%d", row),
-					Value: file.Checksum.String(),
-				},
-			}
-
-			// Write out a row of details per each entry in the fileMatch set
-			if err := writeExcelSheet(f, dataEntries...); err != nil {
-				return err
+			values := []interface{}{
+				directory.Directory,
+				directory.EvaluatedFiles,
+				directory.DuplicateFiles,
+				directory.CopiesElsewhere,
+				units.ByteCountIEC(directory.WastedSpace),
+				directory.WastedSpace,
+				directory.Removable(),
 			}
 
+			for i, value := range values {
+				cell, err := excelize.CoordinatesToCellName(i+1, row)
+				if err != nil {
+					return err
+				}
+				directoryEntries = append(directoryEntries, excelSheetEntry{
+					Sheet: directoriesSheet,
+					Cell:  cell,
+					Value: value,
+				})
+			}
 		}
 
+		if err := writeExcelSheet(f, directoryEntries...); err != nil {
+			return err
+		}
 	}
 
-	// Set the summary sheet as the active sheet so it displays first upon
-	// opening the Excel file
-	f.SetActiveSheet(summarySheetIndex)
+	if len(summary.Extensions) > 0 {
 
-	// Save xlsx file by the given path.
-	return f.SaveAs(filename)
+		extensionsSheet := "Extensions"
+		if _, err := f.NewSheet(extensionsSheet); err != nil {
+			return fmt.Errorf(
+				"failed to add new worksheet: %w",
+				err,
+			)
+		}
 
-}
+		var extensionEntries []excelSheetEntry
 
-// WriteFileMatchesCSV writes duplicate files recorded in a FileChecksumIndex
-// to the specified CSV file.
-func (fi FileChecksumIndex) WriteFileMatchesCSV(filename string, blankLineBetweenSets bool) error {
+		for i, header := range summary.Extensions.GenerateCSVHeaderRow() {
+			cell, err := excelize.CoordinatesToCellName(i+1, 1)
+			if err != nil {
+				return err
+			}
+			extensionEntries = append(extensionEntries, excelSheetEntry{
+				Sheet: extensionsSheet,
+				Cell:  cell,
+				Value: strings.ReplaceAll(header, "_", " "),
+			})
+		}
 
-	if !paths.PathExists(filepath.Dir(filepath.Clean(filename))) {
-		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+		for index, extension := range summary.Extensions {
+
+			// Excel starts at 1, but our header occupies row 1, so increment
+			// by +2 to account for that
+			row := index + 2
+
+			values := []interface{}{
+				extension.Extension,
+				extension.DuplicateFiles,
+				units.ByteCountIEC(extension.WastedSpace),
+				extension.WastedSpace,
+			}
+
+			for i, value := range values {
+				cell, err := excelize.CoordinatesToCellName(i+1, row)
+				if err != nil {
+					return err
+				}
+				extensionEntries = append(extensionEntries, excelSheetEntry{
+					Sheet: extensionsSheet,
+					Cell:  cell,
+					Value: value,
+				})
+			}
+		}
+
+		if err := writeExcelSheet(f, extensionEntries...); err != nil {
+			return err
+		}
 	}
 
-	file, err := os.Create(filepath.Clean(filename))
+	setStyles, err := newExcelSetStyles(f)
 	if err != nil {
 		return err
 	}
 
-	// #nosec G307
-	// Believed to be a false-positive from recent gosec release
-	// https://github.com/securego/gosec/issues/714
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf(
-				"error occurred closing file %q: %v",
-				filename,
-				err,
-			)
+	// Sets are numbered in the order listed, matching the set_id column of
+	// the CSV file. Each set is listed on its own sheet (named after the set
+	// number since checksums exceed the sheet name length limit) up to a
+	// limit; any further sets are listed together on a single sheet.
+	ordered := fi.OrderedChecksums(order)
+	setSheets := fi.excelSetSheets(ordered)
+	if err := fi.writeExcelSetsIndex(f, ordered, setSheets); err != nil {
+		return err
+	}
+
+	// Sheets listing several sets are formatted once all of their rows
+	// are written.
+	var moreSetsSheet string
+	var moreSetsRow, moreSetsColumns int
+	finishMoreSets := func() error {
+		if moreSetsSheet == "" {
+			return nil
 		}
-	}()
+		return formatMoreSetsSheet(f, moreSetsSheet, setStyles, moreSetsRow-2, moreSetsColumns)
+	}
 
-	// w := csv.NewWriter(os.Stdout)
-	w := csv.NewWriter(file)
+	for setIndex, checksum := range ordered {
 
-	if err := w.Write(fi.GenerateCSVHeaderRow()); err != nil {
-		// at this point we're still trying to write to a non-flushed buffer,
-		// so any failures are highly unexpected
-		// TODO: Wrap error
-		return err
-	}
+		setID := setIndex + 1
+		fileMatches := fi[checksum]
+		sheet := setSheets[setIndex]
 
-	// for key, fileMatches := range fi {
-	for _, fileMatches := range fi {
+		if setIndex < maxExcelSetSheets {
+			if _, err := f.NewSheet(sheet); err != nil {
+				return fmt.Errorf(
+					"failed to add new worksheet: %w",
+					err,
+				)
+			}
 
-		// This can be useful when focusing just on the sets themselves.
-		if blankLineBetweenSets {
-			if err := w.Write(fileMatches.GenerateEmptyCSVDataRow()); err != nil {
-				// TODO: Use error wrapping instead?
-				return fmt.Errorf("error writing record to csv: %w", err)
+			sheetColumns, err := writeExcelSetHeader(f, sheet, columns, false)
+			if err != nil {
+				return err
+			}
+
+			if err := writeExcelSetRows(f, sheet, 2, setID, false, fileMatches, columns); err != nil {
+				return err
 			}
+
+			// Highlight files suggested for removal and band alternate sets
+			if err := formatSetSheet(
+				f,
+				sheet,
+				setStyles,
+				setIndex,
+				len(fileMatches),
+				sheetColumns,
+			); err != nil {
+				return err
+			}
+
+			continue
 		}
 
-		for _, file := range fileMatches {
-			if err := w.Write(file.GenerateCSVDataRow()); err != nil {
-				// TODO: Use error wrapping instead?
-				return fmt.Errorf("error writing record to csv: %w", err)
+		if sheet != moreSetsSheet {
+			if err := finishMoreSets(); err != nil {
+				return err
+			}
+
+			if _, err := f.NewSheet(sheet); err != nil {
+				return fmt.Errorf(
+					"failed to add new worksheet: %w",
+					err,
+				)
+			}
+
+			moreSetsColumns, err = writeExcelSetHeader(f, sheet, columns, true)
+			if err != nil {
+				return err
 			}
+			moreSetsSheet = sheet
+			moreSetsRow = 2
+		}
+
+		if err := writeExcelSetRows(f, sheet, moreSetsRow, setID, true, fileMatches, columns); err != nil {
+			return err
 		}
+		moreSetsRow += len(fileMatches)
+	}
 
+	if err := finishMoreSets(); err != nil {
+		return err
 	}
 
-	// Write any buffered data to the underlying writer (standard output).
-	w.Flush()
+	// Set the summary sheet as the active sheet so it displays first upon
+	// opening the Excel file
+	f.SetActiveSheet(summarySheetIndex)
+
+	// Save xlsx file by the given path.
+	return f.SaveAs(filename)
+
+}
+
+// WriteFileMatchesCSV writes duplicate files recorded in a FileChecksumIndex
+// to the specified CSV file, listing duplicate file sets in the specified
+// order.
+func (fi FileChecksumIndex) WriteFileMatchesCSV(filename string, blankLineBetweenSets bool, order SortOrder, columns ReportColumns) error {
+
+	sw, err := NewCSVSetWriter(filename, blankLineBetweenSets, columns)
+	if err != nil {
+		return err
+	}
 
-	if err := w.Error(); err != nil {
-		// TODO: Wrap the error here with the context?
-		// TODO: We accept that CSV Write() or Flush() errors are returned
-		// here and not file closure or Sync errors?
+	// Sets are numbered in the order written so that rows belonging to the
+	// same set can be grouped (and validated) even if rows are reordered.
+	if err := fi.WriteSets(sw, order); err != nil {
+		_ = sw.Close()
 		return err
 	}
 
-	// TODO: How to return errors from CSV package AND any potential errors
-	// from attempting to close the file handle?
-	return file.Sync()
+	return sw.Close()
 }
 
 // PrintFileMatches prints duplicate files recorded in a FileChecksumIndex to
-// stdout for development or troubleshooting purposes. See also
-// WriteFileMatches for the expected production output method.
-func (fi FileChecksumIndex) PrintFileMatches(blankLineBetweenSets bool) {
+// stdout for development or troubleshooting purposes, listing duplicate file
+// sets in the specified order. If color is true, files suggested for
+// removal are printed in red, files suggested for keeping in green and set
+// separators in cyan. See also WriteFileMatches for the expected production
+// output method.
+func (fi FileChecksumIndex) PrintFileMatches(blankLineBetweenSets bool, order SortOrder, color bool) {
+
+	colors := consoleColors(color)
 
 	w := new(tabwriter.Writer)
 	// w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, '.', tabwriter.AlignRight|tabwriter.Debug)
@@ -889,18 +2047,34 @@ func (fi FileChecksumIndex) PrintFileMatches(blankLineBetweenSets bool) {
 	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
 
 	// Header row in output
-	_, _ = fmt.Fprintln(w,
-		"Directory\tFile\tSize\tChecksum\t")
-	for _, fileMatches := range fi {
+	_, _ = fmt.Fprintf(w,
+		"%sDirectory\tFile\tSize\tChecksum\t%s\n", colors.line(ansiBold), colors.reset())
+	for setIndex, checksum := range fi.OrderedChecksums(order) {
+		fileMatches := fi[checksum]
+
+		// Separate sets using a rule in each column so that columns stay
+		// aligned across sets.
+		if color && setIndex > 0 {
+			rule := strings.Repeat("-", consoleSetSeparatorWidth)
+			_, _ = fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", ansiCyan, rule, rule, rule, rule, ansiReset)
+		}
+
 		for _, file := range fileMatches {
 
+			lineColor := ansiRed
+			if file.Keep {
+				lineColor = ansiGreen
+			}
+
 			// TODO: Confirm that newline between file sets is useful
 			_, _ = fmt.Fprintf(w,
-				"%s\t%s\t%s\t%s\n",
-				file.ParentDirectory,
+				"%s%s\t%s\t%s\t%s%s\n",
+				colors.line(lineColor),
+				file.ParentDirectory(),
 				file.Name(),
 				file.SizeHR(),
-				file.Checksum)
+				file.Checksum,
+				colors.reset())
 		}
 
 		// This throws off cohesive formatting across all sets, but can be
@@ -922,8 +2096,12 @@ func (fi FileChecksumIndex) PrintFileMatches(blankLineBetweenSets bool) {
 }
 
 // PrintSummary is used to generate a basic summary report of file metadata
-// collected while evaluating files for potential duplicates.
-func (dfs DuplicateFilesSummary) PrintSummary() {
+// collected while evaluating files for potential duplicates. If color is
+// true, duplicate files and wasted space are highlighted in red and
+// headings in bold.
+func (dfs DuplicateFilesSummary) PrintSummary(color bool) {
+
+	colors := consoleColors(color)
 
 	w := new(tabwriter.Writer)
 	// w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, '.', tabwriter.AlignRight|tabwriter.Debug)
@@ -931,16 +2109,91 @@ func (dfs DuplicateFilesSummary) PrintSummary() {
 	// Format in tab-separated columns
 	w.Init(os.Stdout, 8, 8, 5, '\t', 0)
 
+	// Every line starts with a color sequence of the same length so that
+	// columns stay aligned.
+	printLine := func(lineColor string, format string, a ...interface{}) {
+		_, _ = fmt.Fprintf(w, colors.line(lineColor)+format+colors.reset()+"\n", a...)
+	}
+
 	// TODO: Use tabwriter to generate summary report?
-	_, _ = fmt.Fprintf(w, "%d\tevaluated files in specified paths\n", dfs.TotalEvaluatedFiles)
-	_, _ = fmt.Fprintf(w, "%d\tpotential duplicate file sets found using file size\n", dfs.FileSizeMatchSets)
-	_, _ = fmt.Fprintf(w, "%d\tconfirmed duplicate file sets found using file hash\n", dfs.FileHashMatchSets)
-	_, _ = fmt.Fprintf(w, "%d\tfiles with identical file size\n", dfs.FileSizeMatches)
-	_, _ = fmt.Fprintf(w, "%d\tfiles with identical file hash\n", dfs.FileHashMatches)
-	_, _ = fmt.Fprintf(w, "%d\tduplicate files\n", dfs.DuplicateCount)
-	_, _ = fmt.Fprintf(w, "%s\twasted space for duplicate file sets\n", units.ByteCountIEC(dfs.WastedSpace))
+	printLine(ansiDefault, "%d\tevaluated files in specified paths", dfs.TotalEvaluatedFiles)
+	printLine(ansiDefault, "%d\tpotential duplicate file sets found using file size", dfs.FileSizeMatchSets)
+	printLine(ansiDefault, "%d\tconfirmed duplicate file sets found using file hash", dfs.FileHashMatchSets)
+	printLine(ansiDefault, "%d\tfiles with identical file size", dfs.FileSizeMatches)
+	printLine(ansiDefault, "%d\tfiles with identical file hash", dfs.FileHashMatches)
+	printLine(ansiRed, "%d\tduplicate files", dfs.DuplicateCount)
+	printLine(ansiRed, "%s\twasted space for duplicate file sets", units.ByteCountIEC(dfs.WastedSpace))
+	if dfs.NearDuplicateSets > 0 {
+		printLine(ansiDefault, "%d\tnear duplicate image sets found using perceptual hash", dfs.NearDuplicateSets)
+	}
+	if dfs.ContentEqualSets > 0 {
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets are content-equal (metadata ignored)", dfs.ContentEqualSets)
+	}
+	if dfs.CrossRootSets > 0 || dfs.IntraRootSets > 0 {
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets span multiple paths", dfs.CrossRootSets)
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets are within a single path", dfs.IntraRootSets)
+	}
+	if dfs.ByteComparisonSets > 0 {
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets found using byte comparison", dfs.ByteComparisonSets)
+	}
+	if dfs.PartialSets > 0 {
+		printLine(ansiRed, "%d\tduplicate file sets matched using partial checksums only (UNVERIFIED)", dfs.PartialSets)
+	}
+	if dfs.BytesVerifiedSets > 0 || dfs.ByteMismatches > 0 {
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets verified byte for byte", dfs.BytesVerifiedSets)
+		printLine(ansiRed, "%d\tfiles excluded due to differing content despite matching checksum", dfs.ByteMismatches)
+	}
+	if dfs.EXIFMatchSets > 0 {
+		printLine(ansiDefault, "%d\timage sets with matching EXIF capture time and dimensions", dfs.EXIFMatchSets)
+	}
+	if dfs.RelatedFileSets > 0 {
+		printLine(ansiDefault, "%d\trelated file sets with near-identical names but differing content", dfs.RelatedFileSets)
+	}
+	if dfs.SimilarFileSets > 0 {
+		printLine(ansiDefault, "%d\tsimilar file sets found using fuzzy hash", dfs.SimilarFileSets)
+	}
+	if dfs.BaselineSets > 0 {
+		printLine(ansiDefault, "%d\tconfirmed duplicate file sets excluded as unchanged since baseline", dfs.BaselineSets)
+	}
+	if dfs.ChangedFiles > 0 {
+		printLine(ansiRed, "%d\tfiles skipped as they changed during evaluation", dfs.ChangedFiles)
+	}
+	if len(dfs.InUseFiles) > 0 {
+		printLine(ansiRed, "%d\tfiles skipped as they were in use by another process", len(dfs.InUseFiles))
+	}
+	if dfs.SmallFiles > 0 {
+		printLine(ansiDefault, "%d\tfiles below the file size threshold listed separately", dfs.SmallFiles)
+		printLine(ansiDefault, "%d\tzero-byte files listed separately", dfs.ZeroByteFiles)
+	}
 	_, _ = fmt.Fprintln(w)
 
+	if len(dfs.Extensions) > 0 {
+		printLine(ansiBold, "Wasted space by file extension:")
+		printLine(ansiBold, "Extension\tFiles\tSize")
+		for _, es := range dfs.Extensions.Top(consoleExtensionsLimit) {
+			printLine(
+				ansiDefault,
+				"%s\t%d\t%s",
+				es.Extension,
+				es.DuplicateFiles,
+				units.ByteCountIEC(es.WastedSpace),
+			)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(dfs.InUseFiles) > 0 {
+		printLine(ansiBold, "Skipped (in use):")
+		for i, file := range dfs.InUseFiles {
+			if i == consoleInUseFilesLimit {
+				printLine(ansiDefault, "(%d more files not shown)", len(dfs.InUseFiles)-consoleInUseFilesLimit)
+				break
+			}
+			printLine(ansiRed, "%s", file)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
 	if err := w.Flush(); err != nil {
 		log.Printf(
 			"error occurred flushing tabwriter: %v",
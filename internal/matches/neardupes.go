@@ -0,0 +1,296 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/imagehash"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// NearDuplicateFile is an image file with its perceptual hash.
+type NearDuplicateFile struct {
+	FileMatch
+
+	// PerceptualHash is the difference hash (dHash) of the image
+	PerceptualHash imagehash.Hash
+}
+
+// NearDuplicateSet is a collection of image files which appear visually
+// identical based on their perceptual hashes. Unlike duplicate file sets
+// confirmed by checksum, the content of these files may differ (e.g.,
+// re-encoded, resized or with metadata stripped) and should be reviewed
+// before any files are removed.
+type NearDuplicateSet []NearDuplicateFile
+
+// NearDuplicateSets is a collection of NearDuplicateSet values.
+type NearDuplicateSets []NearDuplicateSet
+
+// open opens the file for reading from the filesystem containing it.
+func (fm FileMatch) open() (io.ReadCloser, error) {
+	if fm.FS != nil {
+		return fm.FS.Open(fm.FSPath())
+	}
+
+	return os.Open(filepath.Clean(fm.osPath()))
+}
+
+// PerceptualHash decodes the image file and returns its perceptual hash.
+func (fm FileMatch) PerceptualHash() (imagehash.Hash, error) {
+
+	f, err := fm.open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %q: %w", fm.FullPath, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				fm.FullPath,
+				err,
+			)
+		}
+	}()
+
+	hash, err := imagehash.DHash(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash %q: %w", fm.FullPath, err)
+	}
+
+	return hash, nil
+}
+
+// FindNearDuplicates generates perceptual hashes for all supported image
+// files in the index and groups images whose hashes differ by no more than
+// maxDistance bits. Sets composed entirely of files already confirmed as
+// duplicates of each other (via the provided checksum index) are omitted.
+// The number of errors ignored (as requested) is also returned.
+func (fi FileSizeIndex) FindNearDuplicates(maxDistance int, ignoreErrors bool, exact FileChecksumIndex) (NearDuplicateSets, int, error) {
+
+	var images []NearDuplicateFile
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if !imagehash.IsSupported(file.Name()) {
+				continue
+			}
+
+			hash, err := file.PerceptualHash()
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return nil, ignoredErrors, err
+				}
+
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(file.FullPath, errreport.OperationMetadata, err)
+
+				continue
+			}
+
+			images = append(images, NearDuplicateFile{
+				FileMatch:      file,
+				PerceptualHash: hash,
+			})
+		}
+	}
+
+	// Record checksums for confirmed duplicates so that sets of
+	// byte-identical files (already reported) can be skipped.
+	confirmed := make(map[string]checksums.SHA256Checksum)
+	for checksum, fileMatches := range exact {
+		for _, file := range fileMatches {
+			confirmed[file.FullPath] = checksum
+		}
+	}
+
+	hashes := make([]imagehash.Hash, len(images))
+	for i, img := range images {
+		hashes[i] = img.PerceptualHash
+	}
+
+	var sets NearDuplicateSets
+	for _, group := range imagehash.Group(hashes, maxDistance) {
+
+		set := make(NearDuplicateSet, 0, len(group))
+		identical := true
+		for _, i := range group {
+			set = append(set, images[i])
+
+			checksum, ok := confirmed[images[i].FullPath]
+			if !ok || checksum != confirmed[images[group[0]].FullPath] {
+				identical = false
+			}
+		}
+
+		if identical {
+			continue
+		}
+
+		sort.Slice(set, func(i, j int) bool {
+			return set[i].FullPath < set[j].FullPath
+		})
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i][0].FullPath < sets[j][0].FullPath
+	})
+
+	return sets, ignoredErrors, nil
+}
+
+// GetTotalFilesCount returns the total number of files in all near
+// duplicate sets.
+func (nds NearDuplicateSets) GetTotalFilesCount() int {
+
+	var files int
+
+	for _, set := range nds {
+		files += len(set)
+	}
+
+	return files
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (nds NearDuplicateSets) GenerateCSVHeaderRow() []string {
+	return []string{
+		"set",
+		CSVDirectoryColumnHeaderName,
+		CSVFileColumnHeaderName,
+		CSVSizeColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		"perceptual_hash",
+		"distance",
+	}
+}
+
+// WriteNearDuplicatesCSV writes the near duplicate sets to the specified
+// CSV file. The distance column records the number of bits by which the
+// perceptual hash of each file differs from the first file in its set.
+func (nds NearDuplicateSets) WriteNearDuplicatesCSV(filename string, blankLineBetweenSets bool) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(nds.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for i, set := range nds {
+
+		if blankLineBetweenSets && i > 0 {
+			if err := w.Write(make([]string, len(nds.GenerateCSVHeaderRow()))); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+
+		for _, file := range set {
+			record := []string{
+				strconv.Itoa(i + 1),
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				strconv.FormatInt(file.Size(), 10),
+				file.PerceptualHash.String(),
+				strconv.Itoa(file.PerceptualHash.Distance(set[0].PerceptualHash)),
+			}
+			if err := w.Write(record); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// PrintNearDuplicates prints near duplicate sets to stdout in a section
+// clearly separated from confirmed duplicate file sets.
+func (nds NearDuplicateSets) PrintNearDuplicates(blankLineBetweenSets bool) {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Near duplicates (visually identical images; file content may differ)")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w,
+		"Set\tDirectory\tFile\tSize\tPerceptual Hash\tDistance\t")
+
+	for i, set := range nds {
+		for _, file := range set {
+			_, _ = fmt.Fprintf(w,
+				"%d\t%s\t%s\t%s\t%s\t%d\n",
+				i+1,
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				file.PerceptualHash,
+				file.PerceptualHash.Distance(set[0].PerceptualHash),
+			)
+		}
+
+		if blankLineBetweenSets {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
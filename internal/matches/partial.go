@@ -0,0 +1,79 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import "github.com/atc0005/bridge/internal/checksums"
+
+// SplitAboveSize removes files larger than the provided size (in bytes) from
+// the index, returning them in a new FileSizeIndex. This allows very large
+// files to be evaluated using partial checksums (see
+// UpdatePartialChecksums).
+func (fi FileSizeIndex) SplitAboveSize(size int64) FileSizeIndex {
+
+	largeFiles := make(FileSizeIndex)
+
+	for key, fileMatches := range fi {
+		if key <= size {
+			continue
+		}
+
+		largeFiles[key] = fileMatches
+		delete(fi, key)
+	}
+
+	return largeFiles
+}
+
+// GeneratePartialCheckSum returns a SHA256 checksum of the first size bytes
+// of the file content, reading from the filesystem recorded for the file.
+func (fm FileMatch) GeneratePartialCheckSum(size int64) (checksums.SHA256Checksum, error) {
+	if fm.FS != nil {
+		return checksums.GeneratePartialCheckSumFS(fm.FS, fm.FSPath(), size)
+	}
+
+	return checksums.GeneratePartialCheckSum(fm.osPath(), size)
+}
+
+// UpdatePartialChecksums generates checksums of the first size bytes of
+// each file in the index. Files with matching partial checksums are likely,
+// but not confirmed, to be identical. The number of errors ignored (as
+// requested) is also returned.
+func (fi FileSizeIndex) UpdatePartialChecksums(ignoreErrors bool, size int64) (int, error) {
+
+	var ignoredErrors int
+
+	generate := func(fm FileMatch) (checksums.SHA256Checksum, error) {
+		return fm.GeneratePartialCheckSum(size)
+	}
+
+	for _, fileMatches := range fi {
+		ignored, err := fileMatches.updateChecksums(ignoreErrors, generate, nil)
+		ignoredErrors += ignored
+		if err != nil {
+			return ignoredErrors, err
+		}
+	}
+
+	return ignoredErrors, nil
+}
+
+// GetPartialSetsCount returns the number of duplicate file sets found using
+// partial checksums whose files were not also compared byte for byte; these
+// sets are unverified.
+func (fi FileChecksumIndex) GetPartialSetsCount() int {
+
+	var sets int
+
+	for checksum, fileMatches := range fi {
+		if checksum.IsPartial() && len(fileMatches) > 0 && !fileMatches[0].BytesVerified {
+			sets++
+		}
+	}
+
+	return sets
+}
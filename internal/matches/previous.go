@@ -0,0 +1,81 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+)
+
+// RecordedChecksum is the checksum recorded for a file in a previously
+// generated report along with the details used to determine whether the
+// file has changed since.
+type RecordedChecksum struct {
+
+	// Size is the length of the file in bytes when the report was generated
+	Size int64
+
+	// ModTime is the modification time of the file when the report was
+	// generated. Reports record modification times to the second.
+	ModTime time.Time
+
+	// Checksum is the checksum recorded for the file
+	Checksum checksums.SHA256Checksum
+}
+
+// RecordedChecksums indexes checksums recorded in a previously generated
+// report by the fully-qualified path to the file.
+type RecordedChecksums map[string]RecordedChecksum
+
+// Add records the checksum of the specified file if it can be reused for
+// unchanged files. Only checksums of the complete file content are
+// recorded; content-only and partial checksums and byte comparison
+// identifiers depend on the options used to generate the report. Files
+// without a recorded size or modification time are skipped.
+func (rc RecordedChecksums) Add(fullPath string, recorded RecordedChecksum) {
+
+	if !strings.HasPrefix(string(recorded.Checksum), checksums.SHA256Prefix) ||
+		recorded.Size == 0 ||
+		recorded.ModTime.IsZero() {
+		return
+	}
+
+	rc[fullPath] = recorded
+}
+
+// unchanged indicates whether the file has the size and modification time
+// recorded for it.
+func (recorded RecordedChecksum) unchanged(file FileMatch) bool {
+	return file.Size() == recorded.Size &&
+		file.ModTime().Truncate(time.Second).Equal(recorded.ModTime)
+}
+
+// ApplyRecordedChecksums sets the checksum of each file in the index which
+// is unchanged (same size and modification time) since the provided
+// checksums were recorded, so that the file is not read again (see
+// UpdateChecksums). The number of checksums reused is returned.
+func (fi FileSizeIndex) ApplyRecordedChecksums(recorded RecordedChecksums) int {
+
+	var reused int
+
+	for _, fileMatches := range fi {
+		for index, file := range fileMatches {
+			previous, ok := recorded[file.FullPath]
+			if !ok || !previous.unchanged(file) {
+				continue
+			}
+
+			fileMatches[index].Checksum = previous.Checksum
+			reused++
+		}
+	}
+
+	return reused
+}
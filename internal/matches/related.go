@@ -0,0 +1,282 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// CSVRelatedNameColumnHeaderName is the name of the column recording the
+// normalized file name shared by all files in a related file set.
+const CSVRelatedNameColumnHeaderName = "related_name"
+
+// RelatedFileSets is a collection of file sets whose names are
+// near-identical but whose content differs. Files in these sets are often
+// edited variants or copies of the same file (e.g., "IMG_1234.jpg" and
+// "IMG_1234 (1).jpg") and should be reviewed before any files are removed.
+type RelatedFileSets []FileMatches
+
+// variantNamePatterns match the prefixes and suffixes commonly added to the
+// names of copied or edited files by operating systems and applications.
+var variantNamePatterns = []*regexp.Regexp{
+	// "IMG_1234 (1)", "IMG_1234(2)"
+	regexp.MustCompile(`\s*\(\d+\)$`),
+
+	// "IMG_1234 - Copy", "IMG_1234 copy 2", "IMG_1234_copy"
+	regexp.MustCompile(`[\s_-]+copy(\s*\d+)?$`),
+
+	// "IMG_1234-edited", "IMG_1234_edit", "IMG_1234 modified",
+	// "report-final", "report_v2"
+	regexp.MustCompile(`[\s_-]+(edited|edit|modified|final|v\d+)$`),
+
+	// "Copy of IMG_1234"
+	regexp.MustCompile(`^copy of\s+`),
+}
+
+// relatedName returns the normalized name used to group files with
+// near-identical names: the lowercase file name without any variant
+// prefixes or suffixes (see variantNamePatterns), followed by the
+// lowercase file extension.
+func relatedName(filename string) string {
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	stem := strings.ToLower(strings.TrimSuffix(filename, filepath.Ext(filename)))
+
+	// Strip variant prefixes and suffixes until none remain, so that
+	// stacked suffixes (e.g., "IMG_1234 - Copy (2)") are also handled.
+	for {
+		stripped := stem
+		for _, pattern := range variantNamePatterns {
+			stripped = pattern.ReplaceAllString(stripped, "")
+		}
+		stripped = strings.TrimSpace(stripped)
+
+		// Never strip the entire name (e.g., a file named "Copy.txt")
+		if stripped == "" || stripped == stem {
+			break
+		}
+		stem = stripped
+	}
+
+	return stem + ext
+}
+
+// FindRelatedFiles groups files in the index by normalized name (see
+// relatedName), ignoring the directory containing each file. Sets composed
+// entirely of files already confirmed as duplicates of each other (via the
+// provided checksum index) are omitted; every remaining set includes files
+// whose content differs.
+func (fi FileSizeIndex) FindRelatedFiles(exact FileChecksumIndex) RelatedFileSets {
+
+	groups := make(map[string]FileMatches)
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			name := relatedName(file.Name())
+			groups[name] = append(groups[name], file)
+		}
+	}
+
+	// Record checksums for confirmed duplicates so that sets of
+	// byte-identical files (already reported) can be skipped.
+	confirmed := make(map[string]checksums.SHA256Checksum)
+	for checksum, fileMatches := range exact {
+		for _, file := range fileMatches {
+			confirmed[file.FullPath] = checksum
+		}
+	}
+
+	var sets RelatedFileSets
+	for _, set := range groups {
+		if len(set) < 2 {
+			continue
+		}
+
+		// Files with identical names are not variants of each other; these
+		// are either confirmed duplicates or unrelated files which happen
+		// to share a name.
+		variants := false
+		for _, file := range set {
+			if file.Name() != set[0].Name() {
+				variants = true
+				break
+			}
+		}
+
+		if !variants {
+			continue
+		}
+
+		identical := true
+		for _, file := range set {
+			checksum, ok := confirmed[file.FullPath]
+			if !ok || checksum != confirmed[set[0].FullPath] {
+				identical = false
+				break
+			}
+		}
+
+		if identical {
+			continue
+		}
+
+		sort.Slice(set, func(i, j int) bool {
+			return set[i].FullPath < set[j].FullPath
+		})
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i][0].FullPath < sets[j][0].FullPath
+	})
+
+	return sets
+}
+
+// GetTotalFilesCount returns the total number of files in all related file
+// sets.
+func (rfs RelatedFileSets) GetTotalFilesCount() int {
+
+	var files int
+
+	for _, set := range rfs {
+		files += len(set)
+	}
+
+	return files
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (rfs RelatedFileSets) GenerateCSVHeaderRow() []string {
+	return []string{
+		"set",
+		CSVDirectoryColumnHeaderName,
+		CSVFileColumnHeaderName,
+		CSVSizeColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		CSVModifiedColumnHeaderName,
+		CSVRelatedNameColumnHeaderName,
+	}
+}
+
+// WriteRelatedFilesCSV writes the related file sets to the specified CSV
+// file.
+func (rfs RelatedFileSets) WriteRelatedFilesCSV(filename string, blankLineBetweenSets bool) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(rfs.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for i, set := range rfs {
+
+		if blankLineBetweenSets && i > 0 {
+			if err := w.Write(make([]string, len(rfs.GenerateCSVHeaderRow()))); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+
+		for _, file := range set {
+			record := []string{
+				strconv.Itoa(i + 1),
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				strconv.FormatInt(file.Size(), 10),
+				file.modifiedField(),
+				relatedName(file.Name()),
+			}
+
+			if err := w.Write(record); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// PrintRelatedFiles prints related file sets to stdout in a section clearly
+// separated from confirmed duplicate file sets.
+func (rfs RelatedFileSets) PrintRelatedFiles(blankLineBetweenSets bool) {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Related files (near-identical names; file content differs)")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w,
+		"Set\tDirectory\tFile\tSize\tModified\t")
+
+	for i, set := range rfs {
+		for _, file := range set {
+			_, _ = fmt.Fprintf(w,
+				"%d\t%s\t%s\t%s\t%s\n",
+				i+1,
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				file.modifiedField(),
+			)
+		}
+
+		if blankLineBetweenSets {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
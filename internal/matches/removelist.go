@@ -0,0 +1,66 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/s3"
+)
+
+// RemoveListSetWriter writes the path to each file not suggested for
+// keeping (see KeepPolicy) from each duplicate file set to a list file, for
+// use with other tools (e.g., xargs). S3 objects are not listed.
+type RemoveListSetWriter struct {
+	lw *paths.ListWriter
+}
+
+// NewRemoveListSetWriter creates the specified list file. Paths are listed
+// one per line, or NUL-terminated if requested.
+func NewRemoveListSetWriter(filename string, null bool) (*RemoveListSetWriter, error) {
+
+	lw, err := paths.NewListWriter(filename, null)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoveListSetWriter{lw: lw}, nil
+}
+
+// WriteSet lists the files not suggested for keeping from the duplicate
+// file set. The set ID is not recorded.
+func (rlw *RemoveListSetWriter) WriteSet(_ int, fileMatches FileMatches) error {
+
+	// Never list every copy of a file (e.g., when the file suggested for
+	// keeping is an S3 object).
+	var kept bool
+	for _, file := range fileMatches {
+		if file.Keep && !s3.IsURL(file.FullPath) {
+			kept = true
+		}
+	}
+	if !kept {
+		return nil
+	}
+
+	for _, file := range fileMatches {
+		if file.Keep || s3.IsURL(file.FullPath) {
+			continue
+		}
+
+		if err := rlw.lw.Write(file.FullPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close flushes any buffered paths and closes the list file.
+func (rlw *RemoveListSetWriter) Close() error {
+	return rlw.lw.Close()
+}
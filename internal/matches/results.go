@@ -0,0 +1,62 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+// ResultsReport is a machine-readable collection of the SummaryReport along
+// with every duplicate file set found by the scan. This is intended for use
+// by a central inventory service collecting results from multiple machines.
+// Sets use the same representation as the NDJSON output.
+type ResultsReport struct {
+	Host string `json:"host"`
+	SummaryReport
+	Sets []ndjsonSet `json:"sets"`
+}
+
+// SetCollector retains duplicate file sets in memory, in the order written,
+// so that they can be included in a ResultsReport once a scan completes.
+type SetCollector struct {
+	// Scoped indicates whether the scope of each duplicate file set and the
+	// root path of each file are recorded (see NewNDJSONSetWriter).
+	Scoped bool
+
+	sets []ndjsonSet
+}
+
+// WriteSet retains the duplicate file set identified by setID.
+func (sc *SetCollector) WriteSet(setID int, fileMatches FileMatches) error {
+
+	if len(fileMatches) == 0 {
+		return nil
+	}
+
+	sc.sets = append(sc.sets, newNDJSONSet(setID, fileMatches, sc.Scoped))
+
+	return nil
+}
+
+// Close is a no-op; sets are retained until the ResultsReport is created.
+func (sc *SetCollector) Close() error {
+	return nil
+}
+
+// ResultsReport creates a ResultsReport for the specified host using the
+// provided summary and all duplicate file sets written so far.
+func (sc *SetCollector) ResultsReport(host string, summary SummaryReport) ResultsReport {
+
+	sets := sc.sets
+	if sets == nil {
+		// Encode as an empty list rather than null.
+		sets = []ndjsonSet{}
+	}
+
+	return ResultsReport{
+		Host:          host,
+		SummaryReport: summary,
+		Sets:          sets,
+	}
+}
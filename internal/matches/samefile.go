@@ -0,0 +1,103 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RemoveSamePaths removes entries from the index which refer to a file
+// already listed under another path resolving to the same canonical absolute
+// path (e.g., via symbolic links to a directory or case variations on a
+// case-insensitive filesystem), returning the number of entries removed. This
+// ensures that a duplicate file set never consists of a single physical
+// file. The first entry listed for each file is kept. Files read from other
+// filesystems (see ProcessFS) are not evaluated.
+func (fi FileSizeIndex) RemoveSamePaths() int {
+
+	// Symbolic links are resolved once per directory; files themselves are
+	// never symbolic links since only regular files are indexed.
+	resolvedDirs := make(map[string]string)
+	canonicalPath := func(file FileMatch) string {
+		osPath := file.osPath()
+		dir := filepath.Dir(osPath)
+
+		resolved, ok := resolvedDirs[dir]
+		if !ok {
+			resolved = dir
+			if absDir, err := filepath.Abs(dir); err == nil {
+				resolved = absDir
+			}
+			if evaluated, err := filepath.EvalSymlinks(resolved); err == nil {
+				resolved = evaluated
+			}
+			resolvedDirs[dir] = resolved
+		}
+
+		return filepath.Join(resolved, filepath.Base(osPath))
+	}
+
+	var removed int
+
+	for key, fileMatches := range fi {
+
+		// Paths are compared case-insensitively to find candidates; paths
+		// which differ only in case are confirmed to refer to the same file
+		// since many filesystems are case-sensitive.
+		seen := make(map[string]FileMatch, len(fileMatches))
+		kept := fileMatches[:0]
+
+		for _, file := range fileMatches {
+			if file.FS != nil {
+				kept = append(kept, file)
+				continue
+			}
+
+			canonical := canonicalPath(file)
+			folded := strings.ToLower(canonical)
+
+			if earlier, ok := seen[folded]; ok {
+				if canonical == canonicalPath(earlier) || sameFile(earlier, file) {
+					removed++
+					continue
+				}
+			} else {
+				seen[folded] = file
+			}
+
+			kept = append(kept, file)
+		}
+
+		if len(kept) == 0 {
+			delete(fi, key)
+			continue
+		}
+		fi[key] = kept
+	}
+
+	return removed
+}
+
+// sameFile indicates whether both entries refer to the same file on disk.
+// Files which cannot be accessed are assumed to differ.
+func sameFile(a FileMatch, b FileMatch) bool {
+
+	infoA, err := os.Stat(a.osPath())
+	if err != nil {
+		return false
+	}
+
+	infoB, err := os.Stat(b.osPath())
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVSchemaVersion is the version of the layout of duplicate file set CSV
+// files generated by this application. The version is incremented whenever
+// the meaning of existing columns changes; new columns may be added without
+// changing the version since readers map columns using the header row.
+//
+// Versions:
+//
+//	1: directory, file, size, size_in_bytes, checksum and remove_file
+//	   columns (optionally followed by set_id and report columns), without a
+//	   schema comment line
+//	2: as version 1, preceded by a schema comment line
+//	3: as version 2, with checksums prefixed by the hash algorithm (e.g.,
+//	   "sha256:"); bare checksums recorded by earlier versions are accepted
+//	   as SHA256 hashes
+const CSVSchemaVersion int = 3
+
+// CSVLegacySchemaVersion is the schema version assumed for CSV files without
+// a schema comment line.
+const CSVLegacySchemaVersion int = 1
+
+// CSVSchemaCommentPrefix precedes the schema version in the comment line
+// written as the first line of generated CSV files. Comment lines are
+// skipped when CSV files are read.
+const CSVSchemaCommentPrefix string = "# bridge csv schema version: "
+
+// CSVCommentCharacter is the character which begins comment lines in
+// generated CSV files.
+const CSVCommentCharacter rune = '#'
+
+// utf8BOM is the UTF-8 encoded byte order mark.
+const utf8BOM string = "\ufeff"
+
+// writeCSVSchemaComment writes the schema comment line to the provided
+// writer.
+func writeCSVSchemaComment(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s%d\n", CSVSchemaCommentPrefix, CSVSchemaVersion)
+	return err
+}
+
+// ReadCSVSchemaVersion reads the schema comment line (if present) from the
+// beginning of the provided reader, leaving the reader positioned at the
+// start of the next line. CSVLegacySchemaVersion is returned (without
+// consuming any input other than a byte order mark) if the first line is
+// not a comment line. The number of lines consumed is also returned so that
+// row numbers reported to users match those shown by spreadsheet
+// applications.
+func ReadCSVSchemaVersion(r *bufio.Reader) (int, int, error) {
+
+	// Spreadsheet applications may begin the file with a UTF-8 byte order
+	// mark when saving it.
+	if bom, err := r.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		if _, err := r.Discard(len(utf8BOM)); err != nil {
+			return 0, 0, fmt.Errorf("failed to read schema version: %w", err)
+		}
+	}
+
+	next, err := r.Peek(1)
+	switch {
+	case errors.Is(err, io.EOF):
+		return CSVLegacySchemaVersion, 0, nil
+	case err != nil:
+		return 0, 0, fmt.Errorf("failed to read schema version: %w", err)
+	case rune(next[0]) != CSVCommentCharacter:
+		return CSVLegacySchemaVersion, 0, nil
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	// Spreadsheet applications may pad the line with empty fields (and
+	// quote it) when saving the file.
+	line = strings.TrimRight(strings.TrimSpace(line), ",")
+	line = strings.Trim(line, `"`)
+
+	if !strings.HasPrefix(line, CSVSchemaCommentPrefix) {
+		return CSVLegacySchemaVersion, 1, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, CSVSchemaCommentPrefix)))
+	if err != nil || version < 1 {
+		return 0, 1, fmt.Errorf("invalid schema comment line %q", line)
+	}
+
+	return version, 1, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+// Duplicate file set scopes recorded in the scope column of generated CSV
+// files and Excel workbooks.
+const (
+	// ScopeWithinRoot indicates that all files in a duplicate file set were
+	// found within a single evaluated path.
+	ScopeWithinRoot string = "within-root"
+
+	// ScopeAcrossRoots indicates that files in a duplicate file set were
+	// found in more than one evaluated path.
+	ScopeAcrossRoots string = "across-roots"
+)
+
+// Scope returns ScopeAcrossRoots if the files were found in more than one
+// evaluated path, otherwise ScopeWithinRoot.
+func (fm FileMatches) Scope() string {
+	for _, file := range fm {
+		if file.Root != fm[0].Root {
+			return ScopeAcrossRoots
+		}
+	}
+
+	return ScopeWithinRoot
+}
+
+// GetCrossRootSetsCount returns the number of duplicate file sets with files
+// found in more than one evaluated path.
+func (fi FileChecksumIndex) GetCrossRootSetsCount() int {
+
+	var sets int
+
+	for _, fileMatches := range fi {
+		if fileMatches.Scope() == ScopeAcrossRoots {
+			sets++
+		}
+	}
+
+	return sets
+}
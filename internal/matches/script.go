@@ -0,0 +1,76 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"strconv"
+
+	"github.com/atc0005/bridge/internal/s3"
+	"github.com/atc0005/bridge/internal/script"
+)
+
+// ScriptSetWriter writes a script removing the files not suggested for
+// keeping (see KeepPolicy) from each duplicate file set, instead of removing
+// them directly. S3 objects are not included.
+type ScriptSetWriter struct {
+	sw *script.Writer
+}
+
+// NewScriptSetWriter creates the specified script file in the requested
+// format. Commands copying each file to the backup directory before it is
+// removed are included if backupDir is not empty.
+func NewScriptSetWriter(filename string, format script.Format, backupDir string) (*ScriptSetWriter, error) {
+
+	sw, err := script.NewWriter(
+		filename,
+		format,
+		backupDir,
+		"Removes the files not suggested for keeping from each duplicate file set found by the report subcommand.",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScriptSetWriter{sw: sw}, nil
+}
+
+// WriteSet writes commands removing the files not suggested for keeping
+// from the duplicate file set identified by setID.
+func (ssw *ScriptSetWriter) WriteSet(setID int, fileMatches FileMatches) error {
+
+	var keep []string
+	var remove []script.File
+
+	for _, file := range fileMatches {
+		switch {
+		case s3.IsURL(file.FullPath):
+			continue
+		case file.Keep:
+			keep = append(keep, file.FullPath)
+		default:
+			remove = append(remove, script.File{
+				Path:     file.FullPath,
+				Size:     file.Size(),
+				Checksum: file.Checksum.String(),
+			})
+		}
+	}
+
+	// Never remove every copy of a file (e.g., when the file suggested for
+	// keeping is an S3 object).
+	if len(keep) == 0 {
+		return nil
+	}
+
+	return ssw.sw.WriteSet(strconv.Itoa(setID), keep, remove)
+}
+
+// Close flushes any buffered commands and closes the script file.
+func (ssw *ScriptSetWriter) Close() error {
+	return ssw.sw.Close()
+}
@@ -0,0 +1,286 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/fuzzyhash"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// SimilarFile is a document or archive file with its fuzzy hash.
+type SimilarFile struct {
+	FileMatch
+
+	// FuzzyHash is the fuzzy hash (ssdeep) of the file content
+	FuzzyHash fuzzyhash.Hash
+}
+
+// Score returns the similarity score (0-100) of the file compared with the
+// provided file.
+func (sf SimilarFile) Score(other SimilarFile) int {
+
+	// Hashes generated by this application are always valid.
+	score, _ := fuzzyhash.Compare(sf.FuzzyHash, other.FuzzyHash)
+
+	return score
+}
+
+// SimilarSet is a collection of document or archive files which are highly
+// similar but not identical based on their fuzzy hashes (e.g., successive
+// edits of the same document). Unlike duplicate file sets confirmed by
+// checksum, the content of these files differs and should be reviewed
+// before any files are removed.
+type SimilarSet []SimilarFile
+
+// SimilarSets is a collection of SimilarSet values.
+type SimilarSets []SimilarSet
+
+// FuzzyHash reads the file and returns its fuzzy hash.
+func (fm FileMatch) FuzzyHash() (fuzzyhash.Hash, error) {
+
+	var hash fuzzyhash.Hash
+
+	err := fm.read(func(r io.Reader) error {
+		var err error
+		hash, err = fuzzyhash.Sum(r)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %q: %w", fm.FullPath, err)
+	}
+
+	return hash, nil
+}
+
+// FindSimilarFiles generates fuzzy hashes for all supported document and
+// archive files in the index (other than files smaller than
+// fuzzyhash.MinFileSize) and groups files whose similarity score is at
+// least minScore. Sets composed entirely of files already confirmed as
+// duplicates of each other (via the provided checksum index) are omitted.
+// The number of errors ignored (as requested) is also returned.
+func (fi FileSizeIndex) FindSimilarFiles(minScore int, ignoreErrors bool, exact FileChecksumIndex) (SimilarSets, int, error) {
+
+	var files []SimilarFile
+	var ignoredErrors int
+
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if !fuzzyhash.IsSupported(file.Name()) || file.Size() < fuzzyhash.MinFileSize {
+				continue
+			}
+
+			hash, err := file.FuzzyHash()
+			if err != nil {
+				if !errreport.Ignore(ignoreErrors, err) {
+					return nil, ignoredErrors, err
+				}
+
+				// WARN
+				log.Println("Error encountered:", err)
+				log.Println("Ignoring error as requested")
+				ignoredErrors++
+				errreport.Record(file.FullPath, errreport.OperationHash, err)
+
+				continue
+			}
+
+			files = append(files, SimilarFile{
+				FileMatch: file,
+				FuzzyHash: hash,
+			})
+		}
+	}
+
+	// Record checksums for confirmed duplicates so that sets of
+	// byte-identical files (already reported) can be skipped.
+	confirmed := make(map[string]checksums.SHA256Checksum)
+	for checksum, fileMatches := range exact {
+		for _, file := range fileMatches {
+			confirmed[file.FullPath] = checksum
+		}
+	}
+
+	hashes := make([]fuzzyhash.Hash, len(files))
+	for i, file := range files {
+		hashes[i] = file.FuzzyHash
+	}
+
+	var sets SimilarSets
+	for _, group := range fuzzyhash.Group(hashes, minScore) {
+
+		set := make(SimilarSet, 0, len(group))
+		identical := true
+		for _, i := range group {
+			set = append(set, files[i])
+
+			checksum, ok := confirmed[files[i].FullPath]
+			if !ok || checksum != confirmed[files[group[0]].FullPath] {
+				identical = false
+			}
+		}
+
+		if identical {
+			continue
+		}
+
+		sort.Slice(set, func(i, j int) bool {
+			return set[i].FullPath < set[j].FullPath
+		})
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i][0].FullPath < sets[j][0].FullPath
+	})
+
+	return sets, ignoredErrors, nil
+}
+
+// GetTotalFilesCount returns the total number of files in all similar file
+// sets.
+func (ss SimilarSets) GetTotalFilesCount() int {
+
+	var files int
+
+	for _, set := range ss {
+		files += len(set)
+	}
+
+	return files
+}
+
+// GenerateCSVHeaderRow returns a string slice for use with a CSV Writer as
+// a header row.
+func (ss SimilarSets) GenerateCSVHeaderRow() []string {
+	return []string{
+		"set",
+		CSVDirectoryColumnHeaderName,
+		CSVFileColumnHeaderName,
+		CSVSizeColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		"fuzzy_hash",
+		"score",
+	}
+}
+
+// WriteSimilarFilesCSV writes the similar file sets to the specified CSV
+// file. The score column records the similarity score of each file compared
+// with the first file in its set.
+func (ss SimilarSets) WriteSimilarFilesCSV(filename string, blankLineBetweenSets bool) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified CSV file to create does not exist")
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	if err := w.Write(ss.GenerateCSVHeaderRow()); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for i, set := range ss {
+
+		if blankLineBetweenSets && i > 0 {
+			if err := w.Write(make([]string, len(ss.GenerateCSVHeaderRow()))); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+
+		for _, file := range set {
+			record := []string{
+				strconv.Itoa(i + 1),
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				strconv.FormatInt(file.Size(), 10),
+				file.FuzzyHash.String(),
+				strconv.Itoa(file.Score(set[0])),
+			}
+			if err := w.Write(record); err != nil {
+				return fmt.Errorf("error writing record to csv: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// PrintSimilarFiles prints similar file sets to stdout in a section clearly
+// separated from confirmed duplicate file sets.
+func (ss SimilarSets) PrintSimilarFiles(blankLineBetweenSets bool) {
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 4, '\t', 0)
+
+	_, _ = fmt.Fprintln(w, "Similar files (highly similar documents and archives; file content differs)")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w,
+		"Set\tDirectory\tFile\tSize\tFuzzy Hash\tScore\t")
+
+	for i, set := range ss {
+		for _, file := range set {
+			_, _ = fmt.Fprintf(w,
+				"%d\t%s\t%s\t%s\t%s\t%d\n",
+				i+1,
+				file.ParentDirectory(),
+				file.Name(),
+				file.SizeHR(),
+				file.FuzzyHash,
+				file.Score(set[0]),
+			)
+		}
+
+		if blankLineBetweenSets {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if err := w.Flush(); err != nil {
+		log.Printf(
+			"error occurred flushing tabwriter: %v",
+			err,
+		)
+	}
+}
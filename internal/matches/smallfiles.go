@@ -0,0 +1,98 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import "sort"
+
+// SplitBelowSize removes files smaller than the provided size (in bytes)
+// from the index, returning them in a new FileSizeIndex. This allows files
+// excluded from duplicate detection by the size threshold (e.g., zero-byte
+// files) to be reported separately.
+func (fi FileSizeIndex) SplitBelowSize(size int64) FileSizeIndex {
+
+	smallFiles := make(FileSizeIndex)
+
+	for key, fileMatches := range fi {
+		if key >= size {
+			continue
+		}
+
+		smallFiles[key] = fileMatches
+		delete(fi, key)
+	}
+
+	return smallFiles
+}
+
+// ZeroByteFilesCount returns the number of zero-byte files in the index.
+func (fi FileSizeIndex) ZeroByteFilesCount() int {
+	return len(fi[0])
+}
+
+// WriteSmallFilesCSV writes the files in the index to the specified CSV
+// file, smallest first. Each file is listed as a set of its own using the
+// same columns as the duplicate files CSV file so that files flagged for
+// removal can be removed using the prune subcommand. Each file is marked
+// to be kept in the keep column. Files without a checksum (e.g., due to an
+// ignored error) are omitted.
+func (fi FileSizeIndex) WriteSmallFilesCSV(filename string) error {
+
+	sw, err := NewCSVSetWriter(filename, false, ReportColumns{})
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, 0, len(fi))
+	for size := range fi {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	var setID int
+	for _, size := range sizes {
+
+		fileMatches := fi[size]
+		fileMatches.SortByPath()
+
+		for _, file := range fileMatches {
+			if file.Checksum == "" {
+				continue
+			}
+
+			// Small files are not duplicates; mark each as kept so that
+			// only files explicitly flagged for removal are removed.
+			file.Keep = true
+
+			setID++
+			if err := sw.WriteSet(setID, FileMatches{file}); err != nil {
+				_ = sw.Close()
+				return err
+			}
+		}
+	}
+
+	return sw.Close()
+}
+
+// RemoveAboveSize removes files larger than the provided size (in bytes)
+// from the index, returning the number of files removed.
+func (fi FileSizeIndex) RemoveAboveSize(size int64) int {
+
+	var removed int
+
+	for key, fileMatches := range fi {
+		if key <= size {
+			continue
+		}
+
+		removed += len(fileMatches)
+		delete(fi, key)
+	}
+
+	return removed
+}
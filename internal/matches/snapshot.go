@@ -0,0 +1,233 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// SnapshotPathColumnHeaderName is the header name of the column listing the
+// fully-qualified path to each file in a snapshot manifest.
+const SnapshotPathColumnHeaderName string = "path"
+
+// SnapshotEntry records the details of a file used to determine whether it
+// has been modified since a snapshot manifest was written.
+type SnapshotEntry struct {
+
+	// Size is the length of the file in bytes
+	Size int64
+
+	// ModTime is the modification time of the file
+	ModTime time.Time
+}
+
+// Snapshot indexes the files evaluated by a scan by the fully-qualified path
+// to each file. A snapshot is used as a baseline to exclude duplicate file
+// sets which were already known (and deliberately kept) when it was written.
+type Snapshot map[string]SnapshotEntry
+
+// Changed indicates whether the file was added or modified (differing size
+// or modification time) since the snapshot was written.
+func (s Snapshot) Changed(file FileMatch) bool {
+
+	entry, ok := s[file.FullPath]
+	if !ok {
+		return true
+	}
+
+	return entry.Size != file.Size() || !entry.ModTime.Equal(file.ModTime())
+}
+
+// ChangedSince indicates whether any file in the set was added or modified
+// since the provided snapshot was written.
+func (fm FileMatches) ChangedSince(baseline Snapshot) bool {
+	for _, file := range fm {
+		if baseline.Changed(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExcludeUnchanged removes duplicate file sets whose files were all present
+// and unmodified when the provided baseline snapshot was written. The number
+// of sets removed is returned.
+func (fi FileChecksumIndex) ExcludeUnchanged(baseline Snapshot) int {
+
+	var excluded int
+
+	for key, fileMatches := range fi {
+		if fileMatches.ChangedSince(baseline) {
+			continue
+		}
+
+		delete(fi, key)
+		excluded++
+	}
+
+	return excluded
+}
+
+// WriteSnapshot writes a snapshot manifest listing the path, size and
+// modification time of every file in the index to the specified CSV file,
+// sorted by path. Modification times are recorded with full precision so
+// that unmodified files compare as equal.
+func (fi FileSizeIndex) WriteSnapshot(filename string) error {
+
+	if !paths.PathExists(filepath.Dir(filename)) {
+		return fmt.Errorf("parent directory for specified snapshot file to create does not exist")
+	}
+
+	var files FileMatches
+	for _, fileMatches := range fi {
+		files = append(files, fileMatches...)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FullPath < files[j].FullPath
+	})
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	w := csv.NewWriter(file)
+
+	header := []string{
+		SnapshotPathColumnHeaderName,
+		CSVSizeInBytesDirectoryColumnHeaderName,
+		CSVModifiedColumnHeaderName,
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	for _, fm := range files {
+		row := []string{
+			fm.FullPath,
+			strconv.FormatInt(fm.Size(), 10),
+			fm.ModTime().UTC().Format(time.RFC3339Nano),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// ReadSnapshot reads a snapshot manifest previously written by
+// WriteSnapshot. Columns are located using the header row.
+func ReadSnapshot(filename string) (Snapshot, error) {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf(
+				"error occurred closing file %q: %v",
+				filename,
+				err,
+			)
+		}
+	}()
+
+	r := csv.NewReader(file)
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header row of snapshot file %q: %w", filename, err)
+	}
+
+	positions := map[string]int{
+		SnapshotPathColumnHeaderName:            -1,
+		CSVSizeInBytesDirectoryColumnHeaderName: -1,
+		CSVModifiedColumnHeaderName:             -1,
+	}
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if position, ok := positions[name]; ok && position == -1 {
+			positions[name] = index
+		}
+	}
+	for name, position := range positions {
+		if position == -1 {
+			return nil, fmt.Errorf("snapshot file %q is missing required column %q", filename, name)
+		}
+	}
+
+	snapshot := make(Snapshot)
+
+	// The header occupies row 1
+	rowNum := 1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		rowNum++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot file %q: %w", filename, err)
+		}
+
+		size, err := strconv.ParseInt(record[positions[CSVSizeInBytesDirectoryColumnHeaderName]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d of snapshot file %q has invalid size: %w", rowNum, filename, err)
+		}
+
+		modTime, err := time.Parse(time.RFC3339Nano, record[positions[CSVModifiedColumnHeaderName]])
+		if err != nil {
+			return nil, fmt.Errorf("row %d of snapshot file %q has invalid modification time: %w", rowNum, filename, err)
+		}
+
+		snapshot[record[positions[SnapshotPathColumnHeaderName]]] = SnapshotEntry{
+			Size:    size,
+			ModTime: modTime,
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"sort"
+
+	"github.com/atc0005/bridge/internal/checksums"
+)
+
+// SortOrder specifies the order in which duplicate file sets are listed in
+// console, CSV and Excel output.
+type SortOrder string
+
+const (
+	// SortByWastedSpace lists sets with the most wasted space first.
+	SortByWastedSpace SortOrder = "wasted-space"
+
+	// SortBySize lists sets of the largest files first.
+	SortBySize SortOrder = "size"
+
+	// SortByPath lists sets by the path of their first file.
+	SortByPath SortOrder = "path"
+
+	// SortByCount lists sets with the most files first.
+	SortByCount SortOrder = "count"
+)
+
+// SortOrders is the list of supported sort orders.
+var SortOrders = []SortOrder{
+	SortByWastedSpace,
+	SortBySize,
+	SortByPath,
+	SortByCount,
+}
+
+// FileOrder specifies the order in which files are listed within each
+// duplicate file set in console, CSV and Excel output.
+type FileOrder string
+
+const (
+	// FileOrderPath lists files by full path.
+	FileOrderPath FileOrder = "path"
+
+	// FileOrderModTime lists files by modification time, oldest first.
+	// Files without a known modification time are listed last.
+	FileOrderModTime FileOrder = "mtime"
+
+	// FileOrderParentSize lists files in the directories holding the most
+	// duplicate data (the total size of all files in the index within the
+	// directory) first.
+	FileOrderParentSize FileOrder = "parent-size"
+)
+
+// FileOrders is the list of supported file orders.
+var FileOrders = []FileOrder{
+	FileOrderPath,
+	FileOrderModTime,
+	FileOrderParentSize,
+}
+
+// fileOrder is the order in which files are listed within each duplicate
+// file set (see SetFileOrder).
+var fileOrder = FileOrderPath
+
+// IsValid indicates whether the sort order is supported.
+func (so SortOrder) IsValid() bool {
+	for _, order := range SortOrders {
+		if so == order {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsValid indicates whether the file order is supported.
+func (fo FileOrder) IsValid() bool {
+	for _, order := range FileOrders {
+		if fo == order {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetFileOrder sets the order in which files are listed within each
+// duplicate file set by OrderedChecksums. Reviewers often treat the first
+// row of each set as the file to keep, so the order should be consistent.
+func SetFileOrder(order FileOrder) {
+	fileOrder = order
+}
+
+// SortByPath sorts the FileMatch objects by full path.
+func (fm FileMatches) SortByPath() {
+	sort.Slice(fm, func(i, j int) bool {
+		return fm[i].FullPath < fm[j].FullPath
+	})
+}
+
+// sortFiles sorts the FileMatch objects in the specified order, breaking
+// ties by full path. The provided function returns the size of the duplicate
+// data held by a directory (see FileOrderParentSize).
+func (fm FileMatches) sortFiles(order FileOrder, parentSize func(dir string) int64) {
+
+	fm.SortByPath()
+
+	switch order {
+	case FileOrderModTime:
+		sort.SliceStable(fm, func(i, j int) bool {
+			a, b := fm[i].ModTime(), fm[j].ModTime()
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+	case FileOrderParentSize:
+		sort.SliceStable(fm, func(i, j int) bool {
+			return parentSize(fm[i].ParentDirectory()) > parentSize(fm[j].ParentDirectory())
+		})
+	}
+}
+
+// parentSizes returns the total size of the files in the index within each
+// directory.
+func (fi FileChecksumIndex) parentSizes() map[string]int64 {
+
+	sizes := make(map[string]int64)
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			sizes[file.ParentDirectory()] += file.Size()
+		}
+	}
+
+	return sizes
+}
+
+// OrderedChecksums returns the checksums of the duplicate file sets in the
+// index in the specified order. Files within each set are sorted in the
+// configured order (see SetFileOrder) so that repeated runs produce
+// identical output. Ties are broken by the path of the first file in each
+// set.
+func (fi FileChecksumIndex) OrderedChecksums(order SortOrder) []checksums.SHA256Checksum {
+
+	var sizes map[string]int64
+	if fileOrder == FileOrderParentSize {
+		sizes = fi.parentSizes()
+	}
+	parentSize := func(dir string) int64 {
+		return sizes[dir]
+	}
+
+	keys := make([]checksums.SHA256Checksum, 0, len(fi))
+	for checksum, fileMatches := range fi {
+		fileMatches.sortFiles(fileOrder, parentSize)
+		keys = append(keys, checksum)
+	}
+
+	firstPath := func(checksum checksums.SHA256Checksum) string {
+		if len(fi[checksum]) == 0 {
+			return ""
+		}
+		return fi[checksum][0].FullPath
+	}
+
+	wastedSpace := func(checksum checksums.SHA256Checksum) int64 {
+		return fi[checksum].WastedSpace()
+	}
+
+	fileSize := func(checksum checksums.SHA256Checksum) int64 {
+		if len(fi[checksum]) == 0 {
+			return 0
+		}
+		return fi[checksum][0].Size()
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+
+		switch order {
+		case SortByWastedSpace:
+			if wastedSpace(a) != wastedSpace(b) {
+				return wastedSpace(a) > wastedSpace(b)
+			}
+		case SortBySize:
+			if fileSize(a) != fileSize(b) {
+				return fileSize(a) > fileSize(b)
+			}
+		case SortByCount:
+			if len(fi[a]) != len(fi[b]) {
+				return len(fi[a]) > len(fi[b])
+			}
+		}
+
+		if firstPath(a) != firstPath(b) {
+			return firstPath(a) < firstPath(b)
+		}
+
+		return a < b
+	})
+
+	return keys
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SplitCSVSetWriter writes duplicate file sets to CSV files in the same
+// format as CSVSetWriter, starting a new numbered part (e.g.,
+// report-002.csv) once the current part reaches the maximum size. Each part
+// has its own header row so that it can be opened (or pruned) on its own.
+// Sets are never split across parts, so a part may exceed the maximum size
+// by up to one set.
+//
+// If all sets fit within a single part, the output is identical to that of
+// CSVSetWriter; the file is renamed to the first numbered part only once a
+// second part is needed.
+type SplitCSVSetWriter struct {
+	filename             string
+	maxSize              int64
+	blankLineBetweenSets bool
+	columns              ReportColumns
+	current              *CSVSetWriter
+	parts                []string
+}
+
+// NewSplitCSVSetWriter creates the specified CSV file and writes the header
+// row. A maxSize of zero applies no limit.
+func NewSplitCSVSetWriter(filename string, maxSize int64, blankLineBetweenSets bool, columns ReportColumns) (*SplitCSVSetWriter, error) {
+
+	sw, err := NewCSVSetWriter(filename, blankLineBetweenSets, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SplitCSVSetWriter{
+		filename:             filename,
+		maxSize:              maxSize,
+		blankLineBetweenSets: blankLineBetweenSets,
+		columns:              columns,
+		current:              sw,
+		parts:                []string{filename},
+	}, nil
+}
+
+// csvPartFilename returns the name of the specified part (starting with
+// one) of a CSV file split into parts (e.g., report-001.csv).
+func csvPartFilename(filename string, part int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(filename, ext), part, ext)
+}
+
+// WriteSet writes a row for each file in the duplicate file set to the
+// current part, starting a new part first if the previous one is full.
+func (sw *SplitCSVSetWriter) WriteSet(setID int, fileMatches FileMatches) error {
+
+	if sw.current == nil {
+		if err := sw.nextPart(); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.current.WriteSet(setID, fileMatches); err != nil {
+		return err
+	}
+
+	if sw.maxSize == 0 {
+		return nil
+	}
+
+	// Rows are flushed once each set is written, so the file size is
+	// current.
+	info, err := sw.current.file.Stat()
+	if err != nil {
+		return fmt.Errorf("error occurred checking size of file %q: %w", sw.current.filename, err)
+	}
+
+	if info.Size() < sw.maxSize {
+		return nil
+	}
+
+	// Close the full part now; the next part is only created if there are
+	// more sets to write.
+	current := sw.current
+	sw.current = nil
+
+	return current.Close()
+}
+
+// nextPart creates the next numbered part, renaming the original file to
+// the first numbered part if this is the second part.
+func (sw *SplitCSVSetWriter) nextPart() error {
+
+	if len(sw.parts) == 1 {
+		first := csvPartFilename(sw.filename, 1)
+		if err := os.Rename(sw.filename, first); err != nil {
+			return fmt.Errorf("error occurred renaming file %q to %q: %w", sw.filename, first, err)
+		}
+		sw.parts[0] = first
+	}
+
+	filename := csvPartFilename(sw.filename, len(sw.parts)+1)
+	part, err := NewCSVSetWriter(filename, sw.blankLineBetweenSets, sw.columns)
+	if err != nil {
+		return err
+	}
+
+	sw.current = part
+	sw.parts = append(sw.parts, filename)
+
+	return nil
+}
+
+// Close flushes any buffered rows, then syncs and closes the current part.
+func (sw *SplitCSVSetWriter) Close() error {
+
+	if sw.current == nil {
+		return nil
+	}
+
+	current := sw.current
+	sw.current = nil
+
+	return current.Close()
+}
+
+// Files returns the names of the CSV files written so far, in order.
+func (sw *SplitCSVSetWriter) Files() []string {
+	return sw.parts
+}
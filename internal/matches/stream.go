@@ -0,0 +1,403 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/internal/progress"
+)
+
+// SetWriter is implemented by types which write duplicate file sets to a
+// destination one set at a time, allowing sets to be written as soon as
+// they are confirmed.
+type SetWriter interface {
+
+	// WriteSet writes the duplicate file set identified by setID.
+	WriteSet(setID int, fileMatches FileMatches) error
+
+	// Close flushes any buffered sets and closes the destination.
+	Close() error
+}
+
+// CSVSetWriter writes duplicate file sets to a CSV file in the same format
+// as WriteFileMatchesCSV. Each set is flushed to the file once written so
+// that partial results are available while a scan is still running.
+type CSVSetWriter struct {
+	filename             string
+	file                 *os.File
+	w                    *csv.Writer
+	blankLineBetweenSets bool
+	columns              ReportColumns
+}
+
+// NDJSONSetWriter writes duplicate file sets to a file as newline-delimited
+// JSON, one JSON object per set. Each set is flushed to the file once
+// written.
+type NDJSONSetWriter struct {
+	filename string
+	file     *os.File
+	w        *bufio.Writer
+	scoped   bool
+}
+
+// ndjsonFile is the NDJSON representation of a file in a duplicate file set.
+type ndjsonFile struct {
+	Directory     string `json:"directory"`
+	File          string `json:"file"`
+	SizeInBytes   int64  `json:"size_in_bytes"`
+	Allocated     *int64 `json:"allocated_size_in_bytes,omitempty"`
+	Keep          bool   `json:"keep"`
+	Role          string `json:"role"`
+	Root          string `json:"root,omitempty"`
+	ExtraChecksum string `json:"extra_checksum,omitempty"`
+}
+
+// ndjsonSet is the NDJSON representation of a duplicate file set.
+type ndjsonSet struct {
+	SetID       int          `json:"set_id"`
+	Checksum    string       `json:"checksum"`
+	SizeInBytes int64        `json:"size_in_bytes"`
+	WastedSpace int64        `json:"wasted_space"`
+	Scope       string       `json:"scope,omitempty"`
+	Verified    bool         `json:"bytes_verified,omitempty"`
+	Partial     bool         `json:"partial,omitempty"`
+	Files       []ndjsonFile `json:"files"`
+}
+
+// createOutputFile creates the specified file after confirming that the
+// parent directory exists.
+func createOutputFile(filename string) (*os.File, error) {
+
+	if !paths.PathExists(filepath.Dir(filepath.Clean(filename))) {
+		return nil, fmt.Errorf("parent directory for specified file %q to create does not exist", filename)
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	return file, nil
+}
+
+// closeOutputFile syncs and closes the provided file, returning the first
+// error encountered.
+func closeOutputFile(file *os.File, filename string) error {
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error occurred syncing file %q: %w", filename, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing file %q: %w", filename, err)
+	}
+
+	return nil
+}
+
+// NewCSVSetWriter creates the specified CSV file and writes the header row.
+func NewCSVSetWriter(filename string, blankLineBetweenSets bool, columns ReportColumns) (*CSVSetWriter, error) {
+
+	file, err := createOutputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := CSVSetWriter{
+		filename:             filename,
+		file:                 file,
+		w:                    csv.NewWriter(file),
+		blankLineBetweenSets: blankLineBetweenSets,
+		columns:              columns,
+	}
+
+	if err := writeCSVSchemaComment(file); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error writing schema comment line to csv: %w", err)
+	}
+
+	if err := sw.w.Write(FileChecksumIndex{}.GenerateCSVHeaderRow(columns)); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error writing header row to csv: %w", err)
+	}
+
+	return &sw, nil
+}
+
+// WriteSet writes a row for each file in the duplicate file set.
+func (sw *CSVSetWriter) WriteSet(setID int, fileMatches FileMatches) error {
+
+	// This can be useful when focusing just on the sets themselves.
+	if sw.blankLineBetweenSets {
+		if err := sw.w.Write(fileMatches.GenerateEmptyCSVDataRow(sw.columns)); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	var scope string
+	if sw.columns.Scope {
+		scope = fileMatches.Scope()
+	}
+
+	for _, file := range fileMatches {
+		if err := sw.w.Write(file.GenerateCSVDataRow(setID, scope, sw.columns)); err != nil {
+			return fmt.Errorf("error writing record to csv: %w", err)
+		}
+	}
+
+	sw.w.Flush()
+
+	return sw.w.Error()
+}
+
+// Close flushes any buffered rows, then syncs and closes the CSV file.
+func (sw *CSVSetWriter) Close() error {
+
+	sw.w.Flush()
+
+	if err := sw.w.Error(); err != nil {
+		_ = sw.file.Close()
+		return fmt.Errorf("error writing records to csv: %w", err)
+	}
+
+	return closeOutputFile(sw.file, sw.filename)
+}
+
+// NewNDJSONSetWriter creates the specified NDJSON file. If scoped is true,
+// the scope of each duplicate file set and the root path of each file are
+// recorded (e.g., when multiple paths are evaluated).
+func NewNDJSONSetWriter(filename string, scoped bool) (*NDJSONSetWriter, error) {
+
+	file, err := createOutputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NDJSONSetWriter{
+		filename: filename,
+		file:     file,
+		w:        bufio.NewWriter(file),
+		scoped:   scoped,
+	}, nil
+}
+
+// newNDJSONSet returns the NDJSON representation of the duplicate file set
+// identified by setID. Roots are only meaningful if multiple paths were
+// evaluated, so the set scope and file roots are only recorded if scoped is
+// true.
+func newNDJSONSet(setID int, fileMatches FileMatches, scoped bool) ndjsonSet {
+
+	set := ndjsonSet{
+		SetID:       setID,
+		Checksum:    fileMatches[0].Checksum.String(),
+		SizeInBytes: fileMatches[0].Size(),
+		WastedSpace: fileMatches.WastedSpace(),
+		Verified:    fileMatches[0].BytesVerified,
+		Partial:     fileMatches[0].Checksum.IsPartial(),
+		Files:       make([]ndjsonFile, 0, len(fileMatches)),
+	}
+
+	for _, file := range fileMatches {
+		entry := ndjsonFile{
+			Directory:     file.ParentDirectory(),
+			File:          file.Name(),
+			SizeInBytes:   file.Size(),
+			Keep:          file.Keep,
+			Role:          file.Role(),
+			ExtraChecksum: file.ExtraChecksum,
+		}
+
+		if scoped {
+			entry.Root = file.Root
+		}
+
+		if file.allocatedRecorded {
+			allocated := file.allocated
+			entry.Allocated = &allocated
+		}
+
+		set.Files = append(set.Files, entry)
+	}
+
+	if scoped {
+		set.Scope = fileMatches.Scope()
+	}
+
+	return set
+}
+
+// WriteSet writes the duplicate file set as a single JSON object followed by
+// a newline.
+func (sw *NDJSONSetWriter) WriteSet(setID int, fileMatches FileMatches) error {
+
+	if len(fileMatches) == 0 {
+		return nil
+	}
+
+	set := newNDJSONSet(setID, fileMatches, sw.scoped)
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("failed to encode duplicate file set as JSON: %w", err)
+	}
+
+	if _, err := sw.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing record to %q: %w", sw.filename, err)
+	}
+
+	return sw.w.Flush()
+}
+
+// Close flushes any buffered sets, then syncs and closes the NDJSON file.
+func (sw *NDJSONSetWriter) Close() error {
+
+	if err := sw.w.Flush(); err != nil {
+		_ = sw.file.Close()
+		return fmt.Errorf("error writing records to %q: %w", sw.filename, err)
+	}
+
+	return closeOutputFile(sw.file, sw.filename)
+}
+
+// WriteSets writes all duplicate file sets in the index to the provided
+// SetWriter in the specified order, numbering sets in the order written.
+// The SetWriter is not closed.
+func (fi FileChecksumIndex) WriteSets(sw SetWriter, order SortOrder) error {
+
+	for index, checksum := range fi.OrderedChecksums(order) {
+		if err := sw.WriteSet(index+1, fi[checksum]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteFileMatchesNDJSON writes duplicate files recorded in a
+// FileChecksumIndex to the specified file as newline-delimited JSON, listing
+// duplicate file sets in the specified order. See NewNDJSONSetWriter for the
+// scoped flag.
+func (fi FileChecksumIndex) WriteFileMatchesNDJSON(filename string, order SortOrder, scoped bool) error {
+
+	sw, err := NewNDJSONSetWriter(filename, scoped)
+	if err != nil {
+		return err
+	}
+
+	if err := fi.WriteSets(sw, order); err != nil {
+		_ = sw.Close()
+		return err
+	}
+
+	return sw.Close()
+}
+
+// StreamDuplicateSets generates checksums for one group of identically
+// sized files at a time and calls fn with each confirmed duplicate file set
+// (at least duplicatesThreshold files with identical checksums) as soon as
+// the group has been checksummed. Groups are processed largest file size
+// first and files within each set are sorted by path. A hash using the
+// additional algorithm (if any) is also generated for each file (see
+// UpdateChecksumsWith). Progress is recorded using the provided meter (if
+// any).
+//
+// Groups are removed from the index once processed so that memory used by
+// files which turn out to be unique can be reclaimed during large scans;
+// only files skipped as they changed since they were found or were in use
+// are retained (see ChangedFiles and InUseFiles). The number of errors
+// ignored (as requested) is also returned.
+func (fi FileSizeIndex) StreamDuplicateSets(ignoreErrors bool, duplicatesThreshold int, extra checksums.Algorithm, meter *progress.Meter, fn func(FileMatches) error) (int, error) {
+
+	var ignoredErrors int
+
+	sizes := make([]int64, 0, len(fi))
+	for size := range fi {
+		sizes = append(sizes, size)
+	}
+
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i] > sizes[j]
+	})
+
+	for _, size := range sizes {
+
+		fileMatches := fi[size]
+
+		ignored, err := fileMatches.UpdateChecksumsWith(ignoreErrors, extra, meter)
+		ignoredErrors += ignored
+		if err != nil {
+			return ignoredErrors, err
+		}
+
+		group := NewFileChecksumIndex(FileSizeIndex{size: fileMatches})
+		group.PruneFileChecksumIndex(duplicatesThreshold)
+
+		for _, checksum := range group.OrderedChecksums(SortByPath) {
+			if err := fn(group[checksum]); err != nil {
+				return ignoredErrors, err
+			}
+		}
+
+		// Files skipped as they changed or were in use are retained so that
+		// they can be reported (see ChangedFiles and InUseFiles).
+		if skipped := fileMatches.skippedFiles(); len(skipped) > 0 {
+			fi[size] = skipped
+			continue
+		}
+
+		delete(fi, size)
+	}
+
+	return ignoredErrors, nil
+}
+
+// AddSet updates the summary to include the provided confirmed duplicate
+// file set. This is used to build a summary when sets are not retained in a
+// FileChecksumIndex (e.g., when streaming results). Sets are counted by scope
+// only if scoped is true (e.g., when multiple paths are evaluated).
+func (dfs *DuplicateFilesSummary) AddSet(fileMatches FileMatches, scoped bool) {
+
+	if len(fileMatches) == 0 {
+		return
+	}
+
+	dfs.FileHashMatchSets++
+	dfs.FileHashMatches += len(fileMatches)
+	dfs.DuplicateCount += len(fileMatches) - 1
+	dfs.WastedSpace += fileMatches.WastedSpace()
+	dfs.Extensions = dfs.Extensions.AddSet(fileMatches)
+
+	if fileMatches[0].Checksum.IsContentChecksum() {
+		dfs.ContentEqualSets++
+	}
+
+	if fileMatches[0].BytesVerified {
+		dfs.BytesVerifiedSets++
+	}
+
+	if !scoped {
+		return
+	}
+
+	switch fileMatches.Scope() {
+	case ScopeAcrossRoots:
+		dfs.CrossRootSets++
+	case ScopeWithinRoot:
+		dfs.IntraRootSets++
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/atc0005/bridge/internal/bytecmp"
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// SameBytes compares the content of the file with the content of another
+// file, byte for byte.
+func (fm FileMatch) SameBytes(other FileMatch) (bool, error) {
+
+	if fm.Size() != other.Size() {
+		return false, nil
+	}
+
+	var same bool
+
+	err := fm.read(func(a io.Reader) error {
+		return other.read(func(b io.Reader) error {
+			equal, failed, err := bytecmp.Equal(a, b)
+			if err != nil {
+				filename := fm.FullPath
+				if failed == 1 {
+					filename = other.FullPath
+				}
+				return fmt.Errorf("failed to read %q: %w", filename, err)
+			}
+
+			same = equal
+
+			return nil
+		})
+	})
+
+	return same, err
+}
+
+// VerifyBytes compares the content of each file in the duplicate file set
+// with the content of the first file, byte for byte. This guards against
+// hash collisions and read errors during checksum generation. Files with
+// matching content are returned (and flagged as verified if at least two
+// remain) separately from files whose content differs. The number of
+// errors ignored (as requested) is also returned; files which could not be
+// compared are omitted from both results.
+func (fm FileMatches) VerifyBytes(ignoreErrors bool) (FileMatches, FileMatches, int, error) {
+
+	var verified, mismatched FileMatches
+	var ignoredErrors int
+
+	for _, file := range fm {
+
+		if len(verified) == 0 {
+			verified = append(verified, file)
+			continue
+		}
+
+		same, err := verified[0].SameBytes(file)
+		if err != nil {
+			if !errreport.Ignore(ignoreErrors, err) {
+				return nil, nil, ignoredErrors, err
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			ignoredErrors++
+			errreport.Record(file.FullPath, errreport.OperationCompare, err)
+
+			continue
+		}
+
+		if !same {
+			mismatched = append(mismatched, file)
+			continue
+		}
+
+		verified = append(verified, file)
+	}
+
+	if len(verified) > 1 {
+		for index := range verified {
+			verified[index].BytesVerified = true
+		}
+	}
+
+	return verified, mismatched, ignoredErrors, nil
+}
+
+// VerifyBytes compares the content of the files in each duplicate file set
+// byte for byte, removing files whose content differs from the first file
+// in the set. Sets confirmed using content-only checksums are skipped since
+// their files are not expected to be byte-equal. The number of files
+// removed due to differing content is returned along with the number of
+// errors ignored (as requested).
+func (fi FileChecksumIndex) VerifyBytes(ignoreErrors bool) (int, int, error) {
+
+	var mismatches int
+	var ignoredErrors int
+
+	for checksum, fileMatches := range fi {
+
+		if checksum.IsContentChecksum() {
+			continue
+		}
+
+		verified, mismatched, ignored, err := fileMatches.VerifyBytes(ignoreErrors)
+		ignoredErrors += ignored
+		if err != nil {
+			return mismatches, ignoredErrors, err
+		}
+
+		for _, file := range mismatched {
+			// WARN
+			log.Printf(
+				"Content of %q differs from %q despite matching checksum %s; excluding file from duplicate file set",
+				file.FullPath,
+				verified[0].FullPath,
+				checksum,
+			)
+		}
+		mismatches += len(mismatched)
+
+		fi[checksum] = verified
+	}
+
+	return mismatches, ignoredErrors, nil
+}
+
+// GetBytesVerifiedSetsCount returns the number of duplicate file sets whose
+// files were compared byte for byte.
+func (fi FileChecksumIndex) GetBytesVerifiedSetsCount() int {
+
+	var sets int
+
+	for _, fileMatches := range fi {
+		if len(fileMatches) > 0 && fileMatches[0].BytesVerified {
+			sets++
+		}
+	}
+
+	return sets
+}
+
+// bytesVerifiedField returns the value of the bytes_verified column for the
+// file.
+func (fm FileMatch) bytesVerifiedField() string {
+	if !fm.BytesVerified {
+		return ""
+	}
+
+	return strconv.FormatBool(fm.BytesVerified)
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sync"
+
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// dirQueue is a queue of directories waiting to be read by a pool of
+// walkers. The walk is complete once no directories are queued or being
+// read.
+type dirQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// dirs is the list of directories waiting to be read
+	dirs []string
+
+	// pending is the number of directories queued or being read
+	pending int
+
+	// err is the first error which was not ignored; once set, walkers stop
+	// reading further directories
+	err error
+}
+
+// next returns the next directory to read, waiting for other walkers to
+// queue directories if needed. False is returned once the walk is complete
+// or has been stopped due to an error.
+func (q *dirQueue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.dirs) == 0 && q.pending > 0 && q.err == nil {
+		q.cond.Wait()
+	}
+
+	if q.err != nil || len(q.dirs) == 0 {
+		return "", false
+	}
+
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+
+	return dir, true
+}
+
+// done records that a directory has been read, queueing the provided
+// subdirectories (if any) or stopping the walk if an error is provided.
+func (q *dirQueue) done(subdirs []string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+
+	q.dirs = append(q.dirs, subdirs...)
+	q.pending += len(subdirs) - 1
+
+	q.cond.Broadcast()
+}
+
+// walkParallel crawls the directory tree rooted at root within the provided
+// filesystem, reading up to the specified number of directories
+// concurrently. The visit function is called (serially) with the metadata
+// of each non-directory entry. Unlike fs.WalkDir, entries are not visited
+// in lexical order. Subdirectories for which the skipDir function returns
+// true are not crawled. Errors ignored (as requested) are recorded using the
+// full path returned by the toFullPath function. The number of errors
+// ignored is also returned.
+func walkParallel(
+	fsys fs.FS,
+	root string,
+	walkers int,
+	ignoreErrors bool,
+	toFullPath func(string) string,
+	skipDir func(fsPath string, name string) bool,
+	visit func(fsPath string, info fs.FileInfo),
+) (int, error) {
+
+	var ignoredErrors int
+
+	rootInfo, err := fs.Stat(fsys, root)
+	if err != nil {
+		return ignoredErrors, err
+	}
+
+	// Match fs.WalkDir behavior for a root which is not a directory.
+	if !rootInfo.IsDir() {
+		visit(root, rootInfo)
+		return ignoredErrors, nil
+	}
+
+	// visitMu serializes calls to visit along with updates to the ignored
+	// errors count.
+	var visitMu sync.Mutex
+
+	// ignore logs, counts and records the provided error encountered
+	// reading the specified path if requested, otherwise returns it.
+	ignore := func(fsPath string, err error) error {
+		if !errreport.Ignore(ignoreErrors, err) {
+			return err
+		}
+
+		visitMu.Lock()
+		defer visitMu.Unlock()
+
+		// WARN
+		log.Println("Error encountered:", err)
+		log.Println("Ignoring error as requested")
+		ignoredErrors++
+		errreport.Record(toFullPath(fsPath), errreport.OperationWalk, err)
+
+		return nil
+	}
+
+	// readDir reads the provided directory, visiting each non-directory
+	// entry and returning the subdirectories to crawl.
+	readDir := func(dir string) ([]string, error) {
+
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, ignore(dir, err)
+		}
+
+		type file struct {
+			fsPath string
+			info   fs.FileInfo
+		}
+
+		var subdirs []string
+		files := make([]file, 0, len(entries))
+
+		for _, entry := range entries {
+			fsPath := path.Join(dir, entry.Name())
+
+			if entry.IsDir() {
+				if skipDir(fsPath, entry.Name()) {
+					continue
+				}
+				subdirs = append(subdirs, fsPath)
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				err = fmt.Errorf(
+					"file %s renamed or removed since directory read: %w",
+					fsPath,
+					err,
+				)
+				if err := ignore(fsPath, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			files = append(files, file{fsPath: fsPath, info: info})
+		}
+
+		visitMu.Lock()
+		for _, f := range files {
+			visit(f.fsPath, f.info)
+		}
+		visitMu.Unlock()
+
+		return subdirs, nil
+	}
+
+	queue := dirQueue{
+		dirs:    []string{root},
+		pending: 1,
+	}
+	queue.cond = sync.NewCond(&queue.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < walkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := queue.next()
+				if !ok {
+					return
+				}
+				queue.done(readDir(dir))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return ignoredErrors, queue.err
+}
@@ -0,0 +1,489 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/atc0005/bridge/internal/errreport"
+)
+
+// testFS returns an in-memory filesystem used to exercise the walk logic.
+func testFS() fstest.MapFS {
+	file := func(size int) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(strings.Repeat("x", size))}
+	}
+
+	return fstest.MapFS{
+		"a.txt":                    file(10),
+		"b.txt":                    file(10),
+		"small.txt":                file(2),
+		"empty.txt":                file(0),
+		"sub/c.txt":                file(10),
+		"sub/deep/d.txt":           file(5),
+		"sub/deep/deeper/e.txt":    file(10),
+		"sub/.git/objects/f.txt":   file(10),
+		".git/config":              file(10),
+		"node_modules/pkg/g.js":    file(10),
+		"photos/@eaDir/thumb.jpg":  file(10),
+		"photos/2019/beach.jpg":    file(20),
+		"photos/2019/beach-1.jpg":  file(20),
+		"photos/.Trash-1000/x.jpg": file(20),
+	}
+}
+
+// errFS wraps a filesystem, failing to read the listed directories.
+type errFS struct {
+	fs.FS
+	fail map[string]error
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (e errFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err, ok := e.fail[name]; ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return fs.ReadDir(e.FS, name)
+}
+
+// indexed returns a sorted description of each file in the index (size,
+// path, parent directory and path within the filesystem) for comparison.
+func indexed(t *testing.T, fi FileSizeIndex) []string {
+	t.Helper()
+
+	var files []string
+	for size, fileMatches := range fi {
+		for _, file := range fileMatches {
+			if file.Size() != size {
+				t.Errorf("file %q of size %d indexed under size %d", file.FullPath, file.Size(), size)
+			}
+			files = append(files, fmt.Sprintf("%d %s %s %s", size, file.FullPath, file.ParentDirectory(), file.FSPath()))
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// indexedPaths returns the sorted paths of the files in the index.
+func indexedPaths(fi FileSizeIndex) []string {
+
+	var files []string
+	for _, fileMatches := range fi {
+		for _, file := range fileMatches {
+			files = append(files, file.FullPath)
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func TestProcessFS(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		root      string
+		recursive bool
+		threshold int64
+		want      []string
+	}{
+		{
+			name: "non-recursive",
+			root: ".",
+			want: []string{"a.txt", "b.txt", "empty.txt", "small.txt"},
+		},
+		{
+			name:      "non-recursive subdirectory",
+			root:      "sub",
+			threshold: 1,
+			want:      []string{"sub/c.txt"},
+		},
+		{
+			name:      "recursive",
+			root:      ".",
+			recursive: true,
+			want: []string{
+				".git/config",
+				"a.txt",
+				"b.txt",
+				"empty.txt",
+				"node_modules/pkg/g.js",
+				"photos/.Trash-1000/x.jpg",
+				"photos/2019/beach-1.jpg",
+				"photos/2019/beach.jpg",
+				"photos/@eaDir/thumb.jpg",
+				"small.txt",
+				"sub/.git/objects/f.txt",
+				"sub/c.txt",
+				"sub/deep/d.txt",
+				"sub/deep/deeper/e.txt",
+			},
+		},
+		{
+			name:      "recursive subdirectory",
+			root:      "sub/deep",
+			recursive: true,
+			want:      []string{"sub/deep/d.txt", "sub/deep/deeper/e.txt"},
+		},
+		{
+			name:      "size threshold",
+			root:      ".",
+			recursive: true,
+			threshold: 11,
+			want: []string{
+				"photos/.Trash-1000/x.jpg",
+				"photos/2019/beach-1.jpg",
+				"photos/2019/beach.jpg",
+			},
+		},
+		{
+			name:      "size threshold excludes empty files",
+			root:      ".",
+			threshold: 1,
+			want:      []string{"a.txt", "b.txt", "small.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := testFS()
+
+			fi, ignored, err := ProcessFS(fsys, tt.root, tt.recursive, false, tt.threshold)
+			if err != nil {
+				t.Fatalf("ProcessFS() error = %v", err)
+			}
+			if ignored != 0 {
+				t.Errorf("ProcessFS() ignored %d errors, want 0", ignored)
+			}
+
+			if got := indexedPaths(fi); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProcessFS() files = %q, want %q", got, tt.want)
+			}
+
+			for _, fileMatches := range fi {
+				for _, file := range fileMatches {
+					if file.FS == nil || file.FSPath() != file.FullPath {
+						t.Errorf("file %q not recorded as read from the provided filesystem", file.FullPath)
+					}
+					if file.ParentDirectory() != filepath.ToSlash(filepath.Dir(file.FullPath)) {
+						t.Errorf("file %q recorded with parent directory %q", file.FullPath, file.ParentDirectory())
+					}
+					if file.Root != tt.root {
+						t.Errorf("file %q recorded with root %q, want %q", file.FullPath, file.Root, tt.root)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessFSInvalidRoot(t *testing.T) {
+
+	if _, _, err := ProcessFS(testFS(), "../outside", true, false, 0); err == nil {
+		t.Error("ProcessFS() with invalid root succeeded, want error")
+	}
+
+	if _, _, err := ProcessFS(testFS(), "missing", true, false, 0); err == nil {
+		t.Error("ProcessFS() with missing root succeeded, want error")
+	}
+}
+
+func TestProcessFSDirExcludes(t *testing.T) {
+
+	identity := func(fsPath string) string { return fsPath }
+
+	tests := []struct {
+		name     string
+		root     string
+		excludes DirExcludes
+		want     []string
+	}{
+		{
+			name:     "default excludes",
+			root:     ".",
+			excludes: DefaultDirExcludes,
+			want: []string{
+				"a.txt",
+				"b.txt",
+				"empty.txt",
+				"photos/2019/beach-1.jpg",
+				"photos/2019/beach.jpg",
+				"small.txt",
+				"sub/c.txt",
+				"sub/deep/d.txt",
+				"sub/deep/deeper/e.txt",
+			},
+		},
+		{
+			name:     "pattern",
+			root:     "sub",
+			excludes: DirExcludes{"de*"},
+			want:     []string{"sub/.git/objects/f.txt", "sub/c.txt"},
+		},
+		{
+			name:     "root is never excluded",
+			root:     "sub/deep",
+			excludes: DirExcludes{"deep"},
+			want:     []string{"sub/deep/d.txt", "sub/deep/deeper/e.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, walkers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/walkers=%d", tt.name, walkers), func(t *testing.T) {
+				fi, _, err := processFS(testFS(), tt.root, identity, true, true, false, 0, walkers, tt.excludes)
+				if err != nil {
+					t.Fatalf("processFS() error = %v", err)
+				}
+
+				if got := indexedPaths(fi); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("processFS() files = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestProcessFSIgnoreErrors(t *testing.T) {
+
+	t.Cleanup(errreport.Reset)
+
+	fsys := errFS{
+		FS: testFS(),
+		fail: map[string]error{
+			"sub/deep": fs.ErrPermission,
+			"photos":   fs.ErrPermission,
+		},
+	}
+
+	identity := func(fsPath string) string { return fsPath }
+
+	for _, walkers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("walkers=%d", walkers), func(t *testing.T) {
+
+			if _, _, err := processFS(fsys, ".", identity, true, true, false, 0, walkers, nil); !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("processFS() error = %v, want %v", err, fs.ErrPermission)
+			}
+
+			errreport.Reset()
+
+			fi, ignored, err := processFS(fsys, ".", identity, true, true, true, 0, walkers, nil)
+			if err != nil {
+				t.Fatalf("processFS() error = %v", err)
+			}
+
+			if ignored != 2 {
+				t.Errorf("processFS() ignored %d errors, want 2", ignored)
+			}
+
+			want := []string{
+				".git/config",
+				"a.txt",
+				"b.txt",
+				"empty.txt",
+				"node_modules/pkg/g.js",
+				"small.txt",
+				"sub/.git/objects/f.txt",
+				"sub/c.txt",
+			}
+			if got := indexedPaths(fi); !reflect.DeepEqual(got, want) {
+				t.Errorf("processFS() files = %q, want %q", got, want)
+			}
+
+			var recorded []string
+			for _, entry := range errreport.Entries() {
+				recorded = append(recorded, entry.Path)
+			}
+			sort.Strings(recorded)
+			if want := []string{"photos", "sub/deep"}; !reflect.DeepEqual(recorded, want) {
+				t.Errorf("recorded errors for %q, want %q", recorded, want)
+			}
+		})
+	}
+
+	// Errors reading the root of a non-recursive search are never ignored.
+	root := errFS{FS: testFS(), fail: map[string]error{".": fs.ErrPermission}}
+	if _, _, err := ProcessFS(root, ".", false, true, 0); err == nil {
+		t.Error("ProcessFS() reading unreadable root succeeded, want error")
+	}
+}
+
+func TestWalkParallelMatchesSerialWalk(t *testing.T) {
+
+	t.Cleanup(errreport.Reset)
+
+	identity := func(fsPath string) string { return fsPath }
+
+	failing := errFS{
+		FS:   testFS(),
+		fail: map[string]error{"sub/deep": fs.ErrPermission},
+	}
+
+	tests := []struct {
+		name      string
+		fsys      fs.FS
+		root      string
+		threshold int64
+		excludes  DirExcludes
+	}{
+		{name: "all files", fsys: testFS(), root: "."},
+		{name: "subdirectory", fsys: testFS(), root: "sub"},
+		{name: "size threshold", fsys: testFS(), root: ".", threshold: 10},
+		{name: "excludes", fsys: testFS(), root: ".", excludes: DefaultDirExcludes},
+		{name: "ignored errors", fsys: failing, root: "."},
+		{name: "file root", fsys: testFS(), root: "a.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serial, serialIgnored, err := processFS(tt.fsys, tt.root, identity, true, true, true, tt.threshold, 1, tt.excludes)
+			if err != nil {
+				t.Fatalf("serial walk error = %v", err)
+			}
+			want := indexed(t, serial)
+
+			for _, walkers := range []int{2, 4, 16} {
+				parallel, ignored, err := processFS(tt.fsys, tt.root, identity, true, true, true, tt.threshold, walkers, tt.excludes)
+				if err != nil {
+					t.Fatalf("parallel walk (%d walkers) error = %v", walkers, err)
+				}
+
+				if got := indexed(t, parallel); !reflect.DeepEqual(got, want) {
+					t.Errorf("parallel walk (%d walkers) files = %q, want %q", walkers, got, want)
+				}
+				if ignored != serialIgnored {
+					t.Errorf("parallel walk (%d walkers) ignored %d errors, want %d", walkers, ignored, serialIgnored)
+				}
+
+				for size, fileMatches := range parallel {
+					if !sort.SliceIsSorted(fileMatches, func(i, j int) bool {
+						return fileMatches[i].FullPath < fileMatches[j].FullPath
+					}) {
+						t.Errorf("parallel walk (%d walkers) files of size %d not sorted by path", walkers, size)
+					}
+				}
+			}
+		})
+	}
+}
+
+// writeTestFiles creates the provided files (path to content) within dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessPathWalkersMatchSerialWalk(t *testing.T) {
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt":           "duplicate",
+		"b/a.txt":         "duplicate",
+		"b/c/d.txt":       "other",
+		"b/c/e/f.txt":     "duplicate",
+		"node_modules/g":  "duplicate",
+		"h/i/j/k/l/m.txt": "x",
+	})
+
+	serial, _, err := ProcessPath(true, false, 0, 1, DefaultDirExcludes, dir)
+	if err != nil {
+		t.Fatalf("ProcessPath() error = %v", err)
+	}
+	want := indexed(t, serial)
+
+	if len(want) != 5 {
+		t.Fatalf("ProcessPath() indexed %d files, want 5: %q", len(want), want)
+	}
+
+	for _, walkers := range []int{2, 8} {
+		parallel, _, err := ProcessPath(true, false, 0, walkers, DefaultDirExcludes, dir)
+		if err != nil {
+			t.Fatalf("ProcessPath() with %d walkers error = %v", walkers, err)
+		}
+
+		if got := indexed(t, parallel); !reflect.DeepEqual(got, want) {
+			t.Errorf("ProcessPath() with %d walkers files = %q, want %q", walkers, got, want)
+		}
+	}
+}
+
+func TestRemoveSamePaths(t *testing.T) {
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"data/a.txt":     "duplicate",
+		"data/sub/b.txt": "duplicate",
+		"other/c.txt":    "duplicate",
+	})
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(filepath.Join(dir, "data"), link); err != nil {
+		t.Skipf("unable to create symbolic link: %v", err)
+	}
+
+	for _, walkers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("walkers=%d", walkers), func(t *testing.T) {
+
+			var indexes []FileSizeIndex
+			for _, path := range []string{filepath.Join(dir, "data"), link, filepath.Join(dir, "other")} {
+				fi, _, err := ProcessPath(true, false, 0, walkers, nil, path)
+				if err != nil {
+					t.Fatalf("ProcessPath(%q) error = %v", path, err)
+				}
+				indexes = append(indexes, fi)
+			}
+
+			// In-memory files share paths with the OS files but are never
+			// considered the same file.
+			memory, _, err := ProcessFS(fstest.MapFS{
+				"a.txt": &fstest.MapFile{Data: []byte("duplicate")},
+			}, ".", true, false, 0)
+			if err != nil {
+				t.Fatalf("ProcessFS() error = %v", err)
+			}
+			indexes = append(indexes, memory)
+
+			fi := MergeFileSizeIndexes(indexes...)
+
+			if removed := fi.RemoveSamePaths(); removed != 2 {
+				t.Errorf("RemoveSamePaths() removed %d entries, want 2", removed)
+			}
+
+			want := []string{
+				"a.txt",
+				filepath.Join(dir, "data", "a.txt"),
+				filepath.Join(dir, "data", "sub", "b.txt"),
+				filepath.Join(dir, "other", "c.txt"),
+			}
+			sort.Strings(want)
+			if got := indexedPaths(fi); !reflect.DeepEqual(got, want) {
+				t.Errorf("RemoveSamePaths() kept %q, want %q", got, want)
+			}
+		})
+	}
+}
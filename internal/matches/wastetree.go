@@ -0,0 +1,176 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WasteNode is a directory within a WasteTree, recording the space wasted
+// by duplicate files within the directory and all of its subdirectories.
+// As with GetWastedSpace, the file kept from each duplicate file set is not
+// counted as wasted space.
+type WasteNode struct {
+
+	// Path is the path to the directory
+	Path string
+
+	// WastedSpace is the space (in bytes) wasted by duplicate files within
+	// the directory and its subdirectories
+	WastedSpace int64
+
+	// DuplicateFiles is the number of duplicate files (excluding the file
+	// kept from each set) within the directory and its subdirectories
+	DuplicateFiles int
+
+	// Parent is the directory containing this directory, or nil for the
+	// top of the tree
+	Parent *WasteNode
+
+	// Children lists the subdirectories containing duplicate files, most
+	// wasted space first
+	Children []*WasteNode
+
+	// Files lists the duplicate files directly within the directory, most
+	// wasted space first
+	Files FileMatches
+
+	// children indexes Children by path while the tree is built
+	children map[string]*WasteNode
+}
+
+// NewWasteTree aggregates the space wasted by duplicate file sets in the
+// index into a tree of directories, allowing users to drill into where
+// wasted space actually lives. The returned node is the deepest directory
+// containing all duplicate files (e.g., the single evaluated path) or a
+// node with an empty path if duplicate files were found under more than one
+// root (e.g., several drives or S3 buckets).
+func NewWasteTree(fi FileChecksumIndex) *WasteNode {
+
+	root := &WasteNode{children: make(map[string]*WasteNode)}
+
+	for _, fileMatches := range fi {
+		kept := fileMatches.keptIndex()
+		for index, file := range fileMatches {
+			if index == kept {
+				continue
+			}
+
+			dir := root.node(file.ParentDirectory())
+			dir.Files = append(dir.Files, file)
+
+			for node := dir; node != nil; node = node.Parent {
+				node.WastedSpace += file.Allocated()
+				node.DuplicateFiles++
+			}
+		}
+	}
+
+	root.sort()
+
+	// Skip directories which merely lead to the directories containing
+	// duplicate files.
+	top := root
+	for len(top.Children) == 1 && len(top.Files) == 0 {
+		top = top.Children[0]
+	}
+	top.Parent = nil
+
+	return top
+}
+
+// node returns the node for the specified directory, adding it (and any
+// missing parent directories) to the tree if needed.
+func (wn *WasteNode) node(dir string) *WasteNode {
+
+	parent := wn
+	if parentDir, ok := wasteTreeParent(dir); ok {
+		parent = wn.node(parentDir)
+	}
+
+	if node, ok := parent.children[dir]; ok {
+		return node
+	}
+
+	node := &WasteNode{
+		Path:     dir,
+		Parent:   parent,
+		children: make(map[string]*WasteNode),
+	}
+	parent.children[dir] = node
+	parent.Children = append(parent.Children, node)
+
+	return node
+}
+
+// wasteTreeParent returns the parent of the specified directory, or false
+// if the directory is the root of a filesystem (or S3 bucket).
+func wasteTreeParent(dir string) (string, bool) {
+
+	// S3 URLs (e.g., s3://bucket/prefix) use forward slashes regardless of
+	// platform; the bucket is the root.
+	if scheme, rest, found := strings.Cut(dir, "://"); found {
+		bucket, prefix, found := strings.Cut(rest, "/")
+		if !found || prefix == "" {
+			return "", false
+		}
+		if i := strings.LastIndex(prefix, "/"); i != -1 {
+			return scheme + "://" + bucket + "/" + prefix[:i], true
+		}
+		return scheme + "://" + bucket, true
+	}
+
+	parent := filepath.Dir(dir)
+	if parent == dir || parent == "." {
+		return "", false
+	}
+
+	return parent, true
+}
+
+// sort orders the subdirectories and files of the node and all of its
+// subdirectories by wasted space, largest first, then by path.
+func (wn *WasteNode) sort() {
+
+	sort.Slice(wn.Children, func(i, j int) bool {
+		a, b := wn.Children[i], wn.Children[j]
+		if a.WastedSpace != b.WastedSpace {
+			return a.WastedSpace > b.WastedSpace
+		}
+		return a.Path < b.Path
+	})
+
+	sort.Slice(wn.Files, func(i, j int) bool {
+		a, b := wn.Files[i], wn.Files[j]
+		if a.Allocated() != b.Allocated() {
+			return a.Allocated() > b.Allocated()
+		}
+		return a.FullPath < b.FullPath
+	})
+
+	for _, child := range wn.Children {
+		child.sort()
+	}
+
+	wn.children = nil
+}
+
+// Name returns the name of the directory relative to its parent, or the
+// full path for the top of the tree.
+func (wn *WasteNode) Name() string {
+
+	if wn.Parent == nil || wn.Parent.Path == "" {
+		return wn.Path
+	}
+
+	name := strings.TrimPrefix(wn.Path, wn.Parent.Path)
+
+	return strings.TrimLeft(name, `/\`)
+}
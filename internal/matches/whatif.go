@@ -0,0 +1,140 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package matches
+
+import "sort"
+
+// KeepPolicyImpact describes the effect of removing every file other than
+// the file suggested for keeping by a keep policy from each duplicate file
+// set. This is used to compare policies before committing to one.
+type KeepPolicyImpact struct {
+
+	// Policy describes the evaluated keep policy (see KeepRule)
+	Policy string `json:"policy"`
+
+	// FilesRemoved is the number of files which would be removed
+	FilesRemoved int `json:"files_removed"`
+
+	// SpaceReclaimed is the space (in bytes) which would be reclaimed.
+	// This differs between policies only for sets whose files differ in
+	// (allocated) size, such as content-equal sets.
+	SpaceReclaimed int64 `json:"space_reclaimed"`
+
+	// ChangedSets is the number of duplicate file sets where the file
+	// suggested for keeping differs from the file currently marked as the
+	// original to keep
+	ChangedSets int `json:"changed_sets"`
+
+	// Directories lists the directories which would have the most space
+	// reclaimed, largest first
+	Directories []ReclaimedDirectory `json:"directories"`
+}
+
+// ReclaimedDirectory is the number of files removed from a directory and
+// the space reclaimed by doing so.
+type ReclaimedDirectory struct {
+	Directory      string `json:"directory"`
+	FilesRemoved   int    `json:"files_removed"`
+	SpaceReclaimed int64  `json:"space_reclaimed"`
+}
+
+// KeepPolicyImpact evaluates the effect of applying the specified keep
+// rule to every duplicate file set in the index without changing the files
+// currently marked as the original to keep. Up to directoryLimit directories
+// with the most space reclaimed are listed.
+func (fi FileChecksumIndex) KeepPolicyImpact(rule KeepRule, directoryLimit int) KeepPolicyImpact {
+
+	impact := KeepPolicyImpact{Policy: rule.String()}
+	directories := make(map[string]*ReclaimedDirectory)
+
+	for _, fileMatches := range fi {
+
+		keep := fileMatches.SuggestedKeep(rule)
+		if keep == -1 {
+			continue
+		}
+
+		// Sets without a file marked for keeping (e.g., hand-edited
+		// reports) are not counted as changed.
+		if current := fileMatches.markedKeep(); current != -1 && current != keep {
+			impact.ChangedSets++
+		}
+
+		for index, file := range fileMatches {
+			if index == keep {
+				continue
+			}
+
+			impact.FilesRemoved++
+			impact.SpaceReclaimed += file.Allocated()
+
+			dir, ok := directories[file.ParentDirectory()]
+			if !ok {
+				dir = &ReclaimedDirectory{Directory: file.ParentDirectory()}
+				directories[file.ParentDirectory()] = dir
+			}
+			dir.FilesRemoved++
+			dir.SpaceReclaimed += file.Allocated()
+		}
+	}
+
+	impact.Directories = make([]ReclaimedDirectory, 0, len(directories))
+	for _, dir := range directories {
+		impact.Directories = append(impact.Directories, *dir)
+	}
+
+	sort.Slice(impact.Directories, func(i, j int) bool {
+		a, b := impact.Directories[i], impact.Directories[j]
+		if a.SpaceReclaimed != b.SpaceReclaimed {
+			return a.SpaceReclaimed > b.SpaceReclaimed
+		}
+		return a.Directory < b.Directory
+	})
+
+	if len(impact.Directories) > directoryLimit {
+		impact.Directories = impact.Directories[:directoryLimit]
+	}
+
+	return impact
+}
+
+// KeepPolicyImpacts evaluates the effect of applying each supported keep
+// policy (see KeepPolicyImpact). If the provided rule has preferences (e.g.,
+// preferred paths), the effect of applying those preferences is evaluated
+// for each policy as well; the policy of the provided rule is ignored.
+func (fi FileChecksumIndex) KeepPolicyImpacts(preferences KeepRule, directoryLimit int) []KeepPolicyImpact {
+
+	impacts := make([]KeepPolicyImpact, 0, 2*len(KeepPolicies))
+	for _, policy := range KeepPolicies {
+		impacts = append(impacts, fi.KeepPolicyImpact(KeepRule{Policy: policy}, directoryLimit))
+	}
+
+	if !preferences.HasPreferences() {
+		return impacts
+	}
+
+	for _, policy := range KeepPolicies {
+		rule := preferences
+		rule.Policy = policy
+		impacts = append(impacts, fi.KeepPolicyImpact(rule, directoryLimit))
+	}
+
+	return impacts
+}
+
+// markedKeep returns the index of the file currently marked as the original
+// to keep, or -1 if no file is marked.
+func (fm FileMatches) markedKeep() int {
+	for index, file := range fm {
+		if file.Keep {
+			return index
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,179 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package metrics tracks counters and gauges describing the work performed
+// by long running subcommands (e.g., watch, serve) and exposes them in the
+// Prometheus text exposition format so that duplication can be trended over
+// time.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoint is the path that metrics are served on.
+const Endpoint string = "/metrics"
+
+// contentType is the media type of the Prometheus text exposition format.
+const contentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types as used by the Prometheus text exposition format
+const (
+	typeCounter string = "counter"
+	typeGauge   string = "gauge"
+)
+
+// Metrics tracks the values exposed via the metrics endpoint. A nil Metrics
+// is valid and records nothing.
+type Metrics struct {
+	scans         atomic.Int64
+	filesScanned  atomic.Int64
+	bytesHashed   atomic.Int64
+	duplicateSets atomic.Int64
+	wastedBytes   atomic.Int64
+	errors        atomic.Int64
+}
+
+// New returns a Metrics with all values set to zero.
+func New() *Metrics {
+	return &Metrics{}
+}
+
+// AddScans records the number of completed scans.
+func (m *Metrics) AddScans(n int) {
+	if m == nil {
+		return
+	}
+	m.scans.Add(int64(n))
+}
+
+// AddFilesScanned records the number of files evaluated by a scan.
+func (m *Metrics) AddFilesScanned(n int) {
+	if m == nil {
+		return
+	}
+	m.filesScanned.Add(int64(n))
+}
+
+// AddBytesHashed records the number of bytes read to generate checksums.
+func (m *Metrics) AddBytesHashed(n int64) {
+	if m == nil {
+		return
+	}
+	m.bytesHashed.Add(n)
+}
+
+// AddErrors records the number of errors encountered, including minor
+// errors ignored as requested.
+func (m *Metrics) AddErrors(n int) {
+	if m == nil {
+		return
+	}
+	m.errors.Add(int64(n))
+}
+
+// SetDuplicates records the current number of duplicate file sets and the
+// space consumed by duplicate files.
+func (m *Metrics) SetDuplicates(sets int, wastedBytes int64) {
+	if m == nil {
+		return
+	}
+	m.duplicateSets.Store(int64(sets))
+	m.wastedBytes.Store(wastedBytes)
+}
+
+// WriteTo writes all metrics to w using the Prometheus text exposition
+// format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value int64
+	}{
+		{"bridge_scans_total", "Number of completed scans.", typeCounter, m.scans.Load()},
+		{"bridge_files_scanned_total", "Number of files evaluated by completed scans.", typeCounter, m.filesScanned.Load()},
+		{"bridge_hashed_bytes_total", "Number of bytes read to generate checksums.", typeCounter, m.bytesHashed.Load()},
+		{"bridge_duplicate_sets", "Number of duplicate file sets currently known.", typeGauge, m.duplicateSets.Load()},
+		{"bridge_wasted_bytes", "Number of bytes consumed by duplicate files currently known.", typeGauge, m.wastedBytes.Load()},
+		{"bridge_errors_total", "Number of errors encountered, including those ignored as requested.", typeCounter, m.errors.Load()},
+	}
+
+	var written int64
+	for _, metric := range metrics {
+		n, err := fmt.Fprintf(
+			w,
+			"# HELP %s %s\n# TYPE %s %s\n%s %d\n",
+			metric.name,
+			metric.help,
+			metric.name,
+			metric.kind,
+			metric.name,
+			metric.value,
+		)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Handler returns an http.Handler serving all metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if _, err := m.WriteTo(w); err != nil {
+			log.Printf("error occurred writing metrics: %v", err)
+		}
+	})
+}
+
+// ListenAndServe serves metrics on the specified address until the provided
+// context is canceled.
+func (m *Metrics) ListenAndServe(ctx context.Context, addr string) error {
+
+	mux := http.NewServeMux()
+	mux.Handle(Endpoint, m.Handler())
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve metrics on %s: %w", addr, err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return httpServer.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package notify sends notifications once a subcommand finishes, posting a
+// JSON summary to a webhook (e.g., Slack, Discord or ntfy) and/or sending it
+// by email. This is intended for long running scans which are launched and
+// then left unattended.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// requestTimeout limits how long posting to a webhook may take.
+const requestTimeout time.Duration = 30 * time.Second
+
+// maxErrorBodySize limits how much of a failed webhook response body is
+// included in the returned error.
+const maxErrorBodySize int64 = 512
+
+// Options specifies where notifications are sent. Notifications are not
+// sent if neither a webhook URL nor an SMTP server is specified.
+type Options struct {
+
+	// WebhookURL is the URL that the JSON notification is posted to
+	WebhookURL string
+
+	// SMTPServer is the host:port of the mail server used to send email
+	// notifications
+	SMTPServer string
+
+	// SMTPUsername is the (optional) username used to authenticate to the
+	// mail server
+	SMTPUsername string
+
+	// SMTPPassword is the password used to authenticate to the mail server
+	// if a username is specified
+	SMTPPassword string
+
+	// EmailFrom is the sender address of email notifications
+	EmailFrom string
+
+	// EmailTo lists the recipient addresses of email notifications
+	EmailTo []string
+}
+
+// Enabled indicates whether notifications are to be sent.
+func (o Options) Enabled() bool {
+	return o.WebhookURL != "" || o.SMTPServer != ""
+}
+
+// Validate verifies that the webhook URL (if any) is usable and that all
+// settings needed to send email are specified if any are.
+func (o Options) Validate() error {
+
+	if o.WebhookURL != "" {
+		u, err := url.Parse(o.WebhookURL)
+		if err != nil {
+			return fmt.Errorf("invalid notification webhook URL: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid notification webhook URL %q; an http or https URL is required", o.WebhookURL)
+		}
+	}
+
+	emailRequested := o.SMTPServer != "" || o.SMTPUsername != "" ||
+		o.EmailFrom != "" || len(o.EmailTo) > 0
+	if !emailRequested {
+		return nil
+	}
+
+	switch {
+	case o.SMTPServer == "":
+		return fmt.Errorf("SMTP server for email notifications not specified")
+	case o.EmailFrom == "":
+		return fmt.Errorf("sender address for email notifications not specified")
+	case len(o.EmailTo) == 0:
+		return fmt.Errorf("recipient address for email notifications not specified")
+	}
+
+	if _, _, err := net.SplitHostPort(o.SMTPServer); err != nil {
+		return fmt.Errorf("invalid SMTP server %q; host:port required: %w", o.SMTPServer, err)
+	}
+
+	return nil
+}
+
+// Notification describes the outcome of a subcommand. The Text and Content
+// fields hold the same human readable message; these are the fields
+// displayed by Slack and Discord webhooks respectively.
+type Notification struct {
+	Text        string      `json:"text"`
+	Content     string      `json:"content"`
+	Subcommand  string      `json:"subcommand"`
+	Host        string      `json:"host"`
+	Success     bool        `json:"success"`
+	Error       string      `json:"error,omitempty"`
+	StartTime   time.Time   `json:"start_time"`
+	EndTime     time.Time   `json:"end_time"`
+	ElapsedTime string      `json:"elapsed_time"`
+	Summary     interface{} `json:"summary,omitempty"`
+}
+
+// New creates a Notification for the specified subcommand, recording the
+// current time as the end time. The provided summary (if any) is included
+// as-is in the JSON payload. A non-nil err indicates that the subcommand
+// failed.
+func New(subcommand string, summary interface{}, startTime time.Time, err error) Notification {
+
+	host, hostErr := os.Hostname()
+	if hostErr != nil {
+		host = "unknown"
+	}
+
+	endTime := time.Now()
+	elapsed := endTime.Sub(startTime).Round(time.Second)
+
+	n := Notification{
+		Subcommand:  subcommand,
+		Host:        host,
+		Success:     err == nil,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		ElapsedTime: elapsed.String(),
+		Summary:     summary,
+	}
+
+	switch {
+	case err != nil:
+		n.Error = err.Error()
+		n.Text = fmt.Sprintf("bridge %s on %s failed after %s: %v", subcommand, host, elapsed, err)
+	default:
+		n.Text = fmt.Sprintf("bridge %s on %s completed in %s", subcommand, host, elapsed)
+	}
+	n.Content = n.Text
+
+	return n
+}
+
+// Send delivers the notification to the webhook and by email as configured.
+// Delivery is attempted using every configured method even if one fails;
+// all failures are returned.
+func Send(opts Options, n Notification) error {
+
+	payload, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	var errs []error
+
+	if opts.WebhookURL != "" {
+		if err := postWebhook(opts.WebhookURL, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if opts.SMTPServer != "" {
+		if err := sendEmail(opts, n.Text, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// postWebhook posts the JSON payload to the specified URL.
+func postWebhook(webhookURL string, payload []byte) error {
+
+	client := &http.Client{Timeout: requestTimeout}
+
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to post notification to webhook: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf(
+			"failed to post notification to webhook: unexpected response %q: %s",
+			resp.Status,
+			strings.TrimSpace(string(body)),
+		)
+	}
+
+	return nil
+}
+
+// sendEmail sends the JSON payload by email using the provided subject.
+// STARTTLS is used if supported by the mail server.
+func sendEmail(opts Options, subject string, payload []byte) error {
+
+	var auth smtp.Auth
+	if opts.SMTPUsername != "" {
+		host, _, err := net.SplitHostPort(opts.SMTPServer)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP server %q: %w", opts.SMTPServer, err)
+		}
+		auth = smtp.PlainAuth("", opts.SMTPUsername, opts.SMTPPassword, host)
+	}
+
+	// Error messages may span lines; keep them from breaking the headers.
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", opts.EmailFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(opts.EmailTo, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(subject)
+	msg.WriteString("\r\n\r\n")
+	msg.Write(bytes.ReplaceAll(payload, []byte("\n"), []byte("\r\n")))
+	msg.WriteString("\r\n")
+
+	if err := smtp.SendMail(opts.SMTPServer, auth, opts.EmailFrom, opts.EmailTo, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send notification email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package paths
+
+import "errors"
+
+// errCloneUnsupported indicates that cloning files is not supported on the
+// current platform.
+var errCloneUnsupported = errors.New("cloning files not supported on this platform")
@@ -0,0 +1,59 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build darwin
+
+package paths
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// sysClonefileat is the system call number of clonefileat(2).
+const sysClonefileat = 462
+
+// atFDCWD indicates that relative paths are relative to the current working
+// directory.
+const atFDCWD = -2
+
+// cloneFile creates the destination file as a copy-on-write clone of the
+// source file using clonefileat(2). Clones share storage with the source
+// file until either is modified and are only supported by some filesystems
+// (e.g., APFS). The destination file must not exist.
+func cloneFile(source string, destination string) error {
+
+	sourcePtr, err := syscall.BytePtrFromString(filepath.Clean(source))
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", source, err)
+	}
+
+	destinationPtr, err := syscall.BytePtrFromString(filepath.Clean(destination))
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", destination, err)
+	}
+
+	fdcwd := atFDCWD
+
+	// #nosec G103
+	_, _, errno := syscall.Syscall6(
+		sysClonefileat,
+		uintptr(fdcwd),
+		uintptr(unsafe.Pointer(sourcePtr)),
+		uintptr(fdcwd),
+		uintptr(unsafe.Pointer(destinationPtr)),
+		0,
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to clone %q to %q: %w", source, destination, errno)
+	}
+
+	return nil
+}
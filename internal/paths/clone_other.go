@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build !darwin && !windows
+
+package paths
+
+// cloneFile is not supported on this platform; files are copied using
+// io.Copy instead, which uses copy_file_range where available (e.g., Linux
+// and FreeBSD).
+func cloneFile(source string, destination string) error {
+	return errCloneUnsupported
+}
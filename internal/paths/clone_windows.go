@@ -0,0 +1,55 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build windows
+
+package paths
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var procCopyFileExW = syscall.NewLazyDLL("kernel32.dll").NewProc("CopyFileExW")
+
+// copyFileFailIfExists prevents CopyFileExW from replacing an existing
+// destination file.
+const copyFileFailIfExists = 0x00000001
+
+// cloneFile copies the source file to the destination file using
+// CopyFileExW, which copies content within the operating system (cloning
+// blocks on filesystems which support it, such as ReFS) and preserves file
+// attributes. The destination file must not exist.
+func cloneFile(source string, destination string) error {
+
+	sourcePtr, err := syscall.UTF16PtrFromString(filepath.Clean(source))
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", source, err)
+	}
+
+	destinationPtr, err := syscall.UTF16PtrFromString(filepath.Clean(destination))
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", destination, err)
+	}
+
+	// #nosec G103
+	result, _, err := procCopyFileExW.Call(
+		uintptr(unsafe.Pointer(sourcePtr)),
+		uintptr(unsafe.Pointer(destinationPtr)),
+		0,
+		0,
+		0,
+		copyFileFailIfExists,
+	)
+	if result == 0 {
+		return fmt.Errorf("failed to copy %q to %q: %w", source, destination, err)
+	}
+
+	return nil
+}
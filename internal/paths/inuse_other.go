@@ -0,0 +1,17 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build !windows
+
+package paths
+
+// IsInUse indicates whether the error was returned because the file is in
+// use (locked) by another process. Files are not locked against reading on
+// this platform, so this is always false.
+func IsInUse(err error) bool {
+	return false
+}
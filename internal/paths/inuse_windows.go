@@ -0,0 +1,29 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+//go:build windows
+
+package paths
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows system error codes returned when opening or reading a file locked
+// by another process.
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// IsInUse indicates whether the error was returned because the file is in
+// use (locked) by another process, such as an open Outlook data file or a
+// video being played.
+func IsInUse(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package paths
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StdinListName is the name used in place of a file name to read a list of
+// paths from standard input.
+const StdinListName string = "-"
+
+// scanNUL is a bufio.SplitFunc splitting input into NUL-terminated tokens
+// (e.g., the output of find -print0). A final unterminated token is
+// returned as-is.
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	// Request more data.
+	return 0, nil, nil
+}
+
+// ReadList reads paths from the provided reader. Paths are read one per
+// line (e.g., the output of find or locate) with trailing carriage returns
+// removed, or NUL-delimited if requested (e.g., the output of find -print0)
+// so that paths containing newlines are read intact. Empty entries are
+// skipped.
+func ReadList(r io.Reader, null bool) ([]string, error) {
+
+	var list []string
+
+	scanner := bufio.NewScanner(r)
+
+	// Allow for paths longer than the default maximum token size.
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024)
+
+	if null {
+		scanner.Split(scanNUL)
+	}
+
+	for scanner.Scan() {
+		path := scanner.Text()
+		if !null {
+			path = strings.TrimRight(path, "\r")
+			if strings.TrimSpace(path) == "" {
+				continue
+			}
+		}
+
+		if path == "" {
+			continue
+		}
+		list = append(list, path)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ReadListFile reads paths from the specified file, or from standard input
+// if the file name is StdinListName (see ReadList).
+func ReadListFile(filename string, null bool) ([]string, error) {
+
+	if filename == StdinListName {
+		list, err := ReadList(os.Stdin, null)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read paths from standard input: %w", err)
+		}
+		return list, nil
+	}
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open paths file %q: %w", filename, err)
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		_ = file.Close()
+	}()
+
+	list, err := ReadList(file, null)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paths file %q: %w", filename, err)
+	}
+
+	return list, nil
+}
+
+// ListWriter writes paths to a file one per line, or NUL-terminated if
+// requested (e.g., for use with xargs -0).
+type ListWriter struct {
+	filename string
+	file     *os.File
+	w        *bufio.Writer
+	null     bool
+}
+
+// NewListWriter creates the specified file for writing a list of paths.
+func NewListWriter(filename string, null bool) (*ListWriter, error) {
+
+	if !PathExists(filepath.Dir(filepath.Clean(filename))) {
+		return nil, fmt.Errorf("parent directory for specified list file %q to create does not exist", filename)
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	return &ListWriter{
+		filename: filename,
+		file:     file,
+		w:        bufio.NewWriter(file),
+		null:     null,
+	}, nil
+}
+
+// Write adds the specified path to the list. Paths containing a newline
+// cannot be listed one per line and are rejected unless the list is
+// NUL-delimited.
+func (lw *ListWriter) Write(path string) error {
+
+	terminator := "\n"
+	if lw.null {
+		terminator = "\x00"
+	}
+
+	if strings.Contains(path, terminator) || (!lw.null && strings.Contains(path, "\r")) {
+		return fmt.Errorf(
+			"unable to list path %q in %q: path contains the list delimiter (use NUL-delimited output instead)",
+			path,
+			lw.filename,
+		)
+	}
+
+	if _, err := lw.w.WriteString(path + terminator); err != nil {
+		return fmt.Errorf("error writing path to %q: %w", lw.filename, err)
+	}
+
+	return nil
+}
+
+// Close flushes any buffered paths, syncs and closes the list file.
+func (lw *ListWriter) Close() error {
+
+	if err := lw.w.Flush(); err != nil {
+		_ = lw.file.Close()
+		return fmt.Errorf("error writing paths to %q: %w", lw.filename, err)
+	}
+
+	if err := lw.file.Sync(); err != nil {
+		_ = lw.file.Close()
+		return fmt.Errorf("error occurred syncing file %q: %w", lw.filename, err)
+	}
+
+	if err := lw.file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing file %q: %w", lw.filename, err)
+	}
+
+	return nil
+}
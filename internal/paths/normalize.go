@@ -0,0 +1,99 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package paths
+
+import (
+	"os"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is the Unicode normalization form applied to paths.
+// Filesystems on macOS commonly store names in decomposed form (NFD) while
+// Linux and Windows commonly use composed form (NFC); paths which differ
+// only in normalization refer to the same file name.
+type Normalization string
+
+// Supported Unicode normalization forms
+const (
+	// NormalizationNFC converts paths to Unicode Normalization Form C
+	// (canonical composition)
+	NormalizationNFC Normalization = "nfc"
+
+	// NormalizationNFD converts paths to Unicode Normalization Form D
+	// (canonical decomposition)
+	NormalizationNFD Normalization = "nfd"
+
+	// NormalizationNone leaves paths as provided by the filesystem
+	NormalizationNone Normalization = "none"
+)
+
+// Normalizations is the list of supported Unicode normalization forms.
+var Normalizations = []Normalization{
+	NormalizationNFC,
+	NormalizationNFD,
+	NormalizationNone,
+}
+
+// normalization is the normalization form applied by Normalize.
+var normalization = NormalizationNFC
+
+// IsValid indicates whether the normalization form is supported.
+func (n Normalization) IsValid() bool {
+	for _, form := range Normalizations {
+		if n == form {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetNormalization sets the Unicode normalization form applied to paths by
+// Normalize.
+func SetNormalization(n Normalization) {
+	normalization = n
+}
+
+// Normalize returns the path converted to the configured Unicode
+// normalization form (see SetNormalization).
+func Normalize(path string) string {
+	switch normalization {
+	case NormalizationNFC:
+		return norm.NFC.String(path)
+	case NormalizationNFD:
+		return norm.NFD.String(path)
+	default:
+		return path
+	}
+}
+
+// Resolve returns the path used by the filesystem for the specified path.
+// If the path does not exist as given, the composed (NFC) and decomposed
+// (NFD) forms of the path are tried in turn so that paths recorded on a
+// system using a different normalization form (e.g., CSV files generated on
+// macOS) can still be used. The path is returned unmodified if no variant
+// exists.
+func Resolve(path string) string {
+
+	if _, err := os.Lstat(path); err == nil {
+		return path
+	}
+
+	for _, variant := range []string{norm.NFC.String(path), norm.NFD.String(path)} {
+		if variant == path {
+			continue
+		}
+
+		if _, err := os.Lstat(variant); err == nil {
+			return variant
+		}
+	}
+
+	return path
+}
@@ -13,16 +13,171 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/atc0005/bridge/internal/bytecmp"
 	"github.com/atc0005/bridge/internal/units"
 )
 
 const defaultDirectoryPerms os.FileMode = 0700
 
+// ErrPathNotExist indicates that a file or directory does not exist. It also
+// matches fs.ErrNotExist so that callers need not distinguish it from errors
+// returned by the os package.
+var ErrPathNotExist error = pathNotExistError{}
+
+// ErrBackupCollision indicates that a file was not backed up because the
+// destination file already exists (see CollisionPolicy).
+var ErrBackupCollision = errors.New("backup collision")
+
+// ErrNotRegularFile indicates that a path expected to be a regular file is
+// not (e.g., a directory).
+var ErrNotRegularFile = errors.New("not a regular file")
+
+// ErrNotDirectory indicates that a path expected to be a directory is not.
+var ErrNotDirectory = errors.New("not a directory")
+
+// ErrIncompleteCopy indicates that fewer bytes were copied (or cloned) than
+// the size of the original file.
+var ErrIncompleteCopy = errors.New("incomplete copy")
+
+// pathNotExistError is the type of ErrPathNotExist.
+type pathNotExistError struct{}
+
+func (pathNotExistError) Error() string {
+	return "path does not exist"
+}
+
+// Is indicates whether the target is fs.ErrNotExist.
+func (pathNotExistError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// CollisionPolicy determines how backups are handled when the destination
+// file already exists.
+type CollisionPolicy string
+
+// Supported collision policies
+const (
+	// CollisionFail aborts the backup
+	CollisionFail CollisionPolicy = "fail"
+
+	// CollisionSkipIfIdentical treats an existing destination file with
+	// identical content as the backup copy, aborting the backup otherwise
+	CollisionSkipIfIdentical CollisionPolicy = "skip-if-identical"
+
+	// CollisionRename adds a numeric suffix to the destination file name
+	CollisionRename CollisionPolicy = "rename"
+)
+
+// CollisionPolicies is the list of supported collision policies.
+var CollisionPolicies = []CollisionPolicy{
+	CollisionFail,
+	CollisionSkipIfIdentical,
+	CollisionRename,
+}
+
+// IsValid indicates whether the collision policy is supported.
+func (cp CollisionPolicy) IsValid() bool {
+	for _, policy := range CollisionPolicies {
+		if cp == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backupDestination returns the fully-qualified path to use for a backup
+// copy of the specified file, creating the backup directory tree as needed.
+// If the destination file already exists, the collision policy is applied;
+// true is returned if an identical copy already exists at the returned path.
+func backupDestination(
+	sourceFilename string,
+	destinationDirectory string,
+	collision CollisionPolicy,
+) (string, bool, error) {
+
+	targetBackupDirPath, err := CreateBackupDirectoryTree(sourceFilename, destinationDirectory)
+	if err != nil {
+		return "", false, fmt.Errorf(
+			"failed to create directory %q in order to backup %q: %w",
+			targetBackupDirPath,
+			sourceFilename,
+			err,
+		)
+	}
+
+	baseFileName := filepath.Base(sourceFilename)
+	destinationFile := filepath.Join(targetBackupDirPath, baseFileName)
+
+	// verify that destinationFile does not already exist before creating it,
+	// otherwise we will end up truncating the existing file
+	if !PathExists(destinationFile) {
+		return destinationFile, false, nil
+	}
+
+	switch collision {
+	case CollisionSkipIfIdentical:
+		same, err := bytecmp.EqualFiles(sourceFilename, destinationFile)
+		if err != nil {
+			return "", false, fmt.Errorf(
+				"failed to compare %q with existing destination file: %w",
+				sourceFilename,
+				err,
+			)
+		}
+
+		if same {
+			log.Printf(
+				"Destination file %q already exists with identical content; skipping backup of %q\n",
+				destinationFile,
+				sourceFilename,
+			)
+			return destinationFile, true, nil
+		}
+
+		return "", false, fmt.Errorf(
+			"%w: destination file %q already exists with different content; skipping backup of %q to prevent overwriting existing file",
+			ErrBackupCollision,
+			destinationFile,
+			sourceFilename,
+		)
+
+	case CollisionRename:
+		ext := filepath.Ext(baseFileName)
+		name := strings.TrimSuffix(baseFileName, ext)
+
+		for suffix := 1; ; suffix++ {
+			candidate := filepath.Join(
+				targetBackupDirPath,
+				fmt.Sprintf("%s_%d%s", name, suffix, ext),
+			)
+			if !PathExists(candidate) {
+				log.Printf(
+					"Destination file %q already exists; backing up %q as %q\n",
+					destinationFile,
+					sourceFilename,
+					candidate,
+				)
+				return candidate, false, nil
+			}
+		}
+
+	default:
+		return "", false, fmt.Errorf(
+			"%w: destination file %q already exists; skipping backup of %q to prevent overwriting existing file",
+			ErrBackupCollision,
+			destinationFile,
+			sourceFilename,
+		)
+	}
+}
+
 // PathExists confirms that the specified path exists
 func PathExists(path string) bool {
 
@@ -56,7 +211,7 @@ func RemoveFile(filename string, dryRun bool) error {
 	}
 
 	log.Printf("File removal enabled, attempting to remove %q\n", filename)
-	err := os.Remove(filename)
+	err := os.Remove(Resolve(filename))
 	if err != nil {
 		return fmt.Errorf("error encountered while removing %q: %w", filename, err)
 	}
@@ -65,6 +220,20 @@ func RemoveFile(filename string, dryRun bool) error {
 	return nil
 }
 
+// CreateBackupDirectory creates the specified backup directory along with
+// any missing parent directories. Created directories are only accessible
+// to the current user.
+func CreateBackupDirectory(path string) error {
+
+	if err := os.MkdirAll(filepath.Clean(path), defaultDirectoryPerms); err != nil {
+		return fmt.Errorf("failed to create backup directory %q: %w", path, err)
+	}
+
+	log.Printf("Created backup directory %q\n", path)
+
+	return nil
+}
+
 // GetBackupTargetDir returns a fully-qualified directory path based off of  a
 // source file name and a destination "base" directory which will be used to
 // hold a nested directory structure which attempts to approximate the
@@ -80,7 +249,7 @@ func GetBackupTargetDir(filename string, fullPathToBackupDir string) (string, er
 	}
 
 	if !PathExists(fullPathToFile) {
-		return "", fmt.Errorf("file %q does not exist or is inaccessible", fullPathToFile)
+		return "", fmt.Errorf("%w: file %q does not exist or is inaccessible", ErrPathNotExist, fullPathToFile)
 	}
 
 	fileInfo, err := os.Stat(fullPathToFile)
@@ -90,13 +259,14 @@ func GetBackupTargetDir(filename string, fullPathToBackupDir string) (string, er
 
 	if fileInfo.IsDir() {
 		return "", fmt.Errorf(
-			"provided path to file %q is a directory, not a fully-qualified filename",
+			"%w: provided path to file %q is a directory, not a fully-qualified filename",
+			ErrNotRegularFile,
 			fullPathToFile,
 		)
 	}
 
 	if !PathExists(fullPathToBackupDir) {
-		return "", fmt.Errorf("directory %q does not exist or is inaccessible", fullPathToBackupDir)
+		return "", fmt.Errorf("%w: directory %q does not exist or is inaccessible", ErrPathNotExist, fullPathToBackupDir)
 	}
 
 	dirInfo, err := os.Stat(fullPathToBackupDir)
@@ -106,7 +276,8 @@ func GetBackupTargetDir(filename string, fullPathToBackupDir string) (string, er
 
 	if !dirInfo.IsDir() {
 		return "", fmt.Errorf(
-			"provided path %q is not an existing directory to use for backups",
+			"%w: provided path %q is not an existing directory to use for backups",
+			ErrNotDirectory,
 			fullPathToBackupDir,
 		)
 	}
@@ -116,20 +287,49 @@ func GetBackupTargetDir(filename string, fullPathToBackupDir string) (string, er
 	// or writing from the provided locations, so access has (AFAIK) not been
 	// confirmed.
 
-	// create a sanitized version of the source filename path
-	slashConvertedSourcePath := filepath.ToSlash(fullPathToFile)
-	volumeName := filepath.VolumeName(slashConvertedSourcePath)
-	volRemoved := strings.ReplaceAll(slashConvertedSourcePath, volumeName+"/", "")
+	return backupTargetDir(fullPathToFile, fullPathToBackupDir), err
+
+}
+
+// backupTargetDir returns the directory within the backup directory which
+// approximates the location of the provided fully-qualified path to a file.
+func backupTargetDir(fullPathToFile string, fullPathToBackupDir string) string {
+
+	// create a sanitized version of the source filename path, removing the
+	// volume name (if any) and leading separator so that the path can be
+	// nested within the backup directory
+	volumeName := filepath.VolumeName(fullPathToFile)
+	volRemoved := strings.TrimLeft(
+		filepath.ToSlash(strings.TrimPrefix(fullPathToFile, volumeName)),
+		"/",
+	)
 
 	// Strip off filename
-	filenameRemoved := filepath.Dir(volRemoved)
+	filenameRemoved := filepath.Dir(filepath.FromSlash(volRemoved))
 
 	// fully-qualified path to place file backup which approximates
 	// the original fully-qualified path
-	targetBackupDirPath := filepath.Join(fullPathToBackupDir, filenameRemoved)
+	return filepath.Join(fullPathToBackupDir, filenameRemoved)
+}
 
-	return targetBackupDirPath, err
+// PlannedBackupPath returns the path that BackupFile would use for a backup
+// copy of the specified file, without confirming that either path exists and
+// before any collision policy is applied. This is intended for reporting
+// planned changes (e.g., in dry-run mode).
+func PlannedBackupPath(filename string, fullPathToBackupDir string) (string, error) {
+
+	fullPathToFile, err := filepath.Abs(filename)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine absolute path to %q: %w",
+			filename,
+			err,
+		)
+	}
 
+	return filepath.Join(
+		backupTargetDir(fullPathToFile, fullPathToBackupDir),
+		filepath.Base(fullPathToFile),
+	), nil
 }
 
 // CreateBackupDirectoryTree receives a full path to a file that should be
@@ -163,46 +363,164 @@ func CreateBackupDirectoryTree(filename string, fullPathToBackupDir string) (str
 
 }
 
-// BackupFile accepts a path to a file and a destination directory where the
-// file should be placed. The destination directory structure serves as a base
-// directory for a nested structure that approximates the source file
-// directory structure, omitting any OS-specific volume names (e.g., "C:\" on
-// Windows).
-func BackupFile(sourceFilename string, destinationDirectory string) error {
+// LinkBackupFile accepts a path to a file, the path to an existing backup
+// copy of a file with identical content and a destination directory. Instead
+// of copying the file, a hard link to the existing backup copy is created at
+// the location BackupFile would have used, so that the content is only
+// stored once. Hard links require both paths to be on the same filesystem
+// and a filesystem that supports them. Existing destination files are
+// handled according to the collision policy. The fully-qualified path to the
+// new link is returned.
+func LinkBackupFile(
+	sourceFilename string,
+	existingBackup string,
+	destinationDirectory string,
+	collision CollisionPolicy,
+) (string, error) {
+
+	// The backup copy mirrors the location of the file on disk, which may
+	// use a different normalization form than the provided path.
+	destinationFile, identical, err := backupDestination(Resolve(sourceFilename), destinationDirectory, collision)
+	if err != nil {
+		return "", err
+	}
+
+	if identical {
+		return destinationFile, nil
+	}
+
+	if err := os.Link(existingBackup, destinationFile); err != nil {
+		return "", fmt.Errorf(
+			"failed to link %q to existing backup copy %q: %w",
+			destinationFile,
+			existingBackup,
+			err,
+		)
+	}
 
 	// DEBUG
-	// fmt.Printf("Calling CreateBackupDirectoryTree(%s, %s)\n", sourceFilename, destinationDirectory)
+	log.Printf("File %q linked to existing backup copy %q as %q",
+		sourceFilename,
+		existingBackup,
+		destinationFile,
+	)
 
-	targetBackupDirPath, err := CreateBackupDirectoryTree(sourceFilename, destinationDirectory)
+	return destinationFile, nil
+}
+
+// clonedBackupFile confirms that a cloned backup copy of a file is complete
+// and syncs it to disk. The fully-qualified path to the backup copy is
+// returned.
+func clonedBackupFile(sourceFilename string, destinationFile string, size int64) (string, error) {
+
+	// Syncing requires write access on some platforms (e.g., Windows).
+	destinationFileHandle, err := os.OpenFile(filepath.Clean(destinationFile), os.O_RDWR, 0)
 	if err != nil {
-		return fmt.Errorf(
-			"failed to create directory %q in order to backup %q: %w",
+		return "", fmt.Errorf("unable to open cloned backup file %q: %w", destinationFile, err)
+	}
+
+	destinationFileStat, err := destinationFileHandle.Stat()
+	if err != nil {
+		_ = destinationFileHandle.Close()
+		return "", fmt.Errorf("unable to stat cloned backup file %q: %w", destinationFile, err)
+	}
+
+	if destinationFileStat.Size() != size {
+		_ = destinationFileHandle.Close()
+		return "", fmt.Errorf(
+			"%w: failed to clone %q to %q: %d of %d bytes cloned",
+			ErrIncompleteCopy,
 			sourceFilename,
-			targetBackupDirPath,
+			destinationFile,
+			destinationFileStat.Size(),
+			size,
+		)
+	}
+
+	if err := destinationFileHandle.Sync(); err != nil {
+		_ = destinationFileHandle.Close()
+		return "", fmt.Errorf(
+			"failed to explicitly sync file %q after backup attempt: %w",
+			destinationFile,
 			err,
 		)
 	}
 
-	baseFileName := filepath.Base(sourceFilename)
-	destinationFile := filepath.Join(targetBackupDirPath, baseFileName)
+	if err := destinationFileHandle.Close(); err != nil {
+		return "", err
+	}
 
 	// DEBUG
-	fmt.Printf("sourceFilename: %q, baseFilename: %q, destinationFile: %q\n",
-		sourceFilename, baseFileName, destinationFile)
-
-	// verify that destinationFile does not already exist before calling
-	// os.Create(), otherwise we will end up truncating the existing file
-	if PathExists(destinationFile) {
-		return fmt.Errorf(
-			"destination file %q already exists; skipping backup of %q to prevent overwriting existing file",
-			destinationFile,
-			sourceFilename,
-		)
+	log.Printf("File %q successfully cloned to %q (%s)",
+		sourceFilename,
+		destinationFile,
+		units.ByteCountIEC(size),
+	)
+
+	return destinationFile, nil
+}
+
+// BackupFile accepts a path to a file and a destination directory where the
+// file should be placed. The destination directory structure serves as a base
+// directory for a nested structure that approximates the source file
+// directory structure, omitting any OS-specific volume names (e.g., "C:\" on
+// Windows). Files are cloned where supported (clonefile on macOS, CopyFileEx
+// on Windows), otherwise copied. Existing destination files are handled
+// according to the collision policy. The fully-qualified path to the backup
+// copy is returned.
+func BackupFile(sourceFilename string, destinationDirectory string, collision CollisionPolicy) (string, error) {
+
+	// The file is located (and the backup copy named) using the path used
+	// by the filesystem, which may use a different normalization form than
+	// the provided path (e.g., from a CSV file generated on macOS).
+	diskPath := Resolve(sourceFilename)
+
+	// DEBUG
+	// fmt.Printf("Calling backupDestination(%s, %s, %s)\n", diskPath, destinationDirectory, collision)
+
+	destinationFile, identical, err := backupDestination(diskPath, destinationDirectory, collision)
+	if err != nil {
+		return "", err
+	}
+
+	// DEBUG
+	fmt.Printf("sourceFilename: %q, destinationFile: %q\n",
+		sourceFilename, destinationFile)
+
+	if identical {
+		return destinationFile, nil
+	}
+
+	// guard against invalid source files
+	// NOTE: This shouldn't be possible since we only add files to a list to
+	// be removed once we verify checksum and collect file size details, but
+	// to be complete (e.g, perhaps if/when this function is moved to a
+	// standalone package for use by other applications one day) we go ahead
+	// and verify again that the source file is a valid backup source
+	sourceFileStat, err := os.Stat(diskPath)
+	if err != nil {
+		return "", err
+	}
+	if !sourceFileStat.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %q", ErrNotRegularFile, sourceFilename)
+	}
+
+	// Clone the file if supported by the platform and filesystem so that
+	// large files are backed up without copying their content. Other files
+	// are copied using io.Copy, which uses copy_file_range on Linux and
+	// FreeBSD to copy content within the kernel (or share extents on
+	// filesystems such as Btrfs and XFS).
+	switch err := cloneFile(diskPath, destinationFile); {
+	case err == nil:
+		return clonedBackupFile(sourceFilename, destinationFile, sourceFileStat.Size())
+	case !errors.Is(err, errCloneUnsupported):
+		// DEBUG
+		log.Printf("Unable to clone %q to %q, copying file instead: %v", sourceFilename, destinationFile, err)
 	}
 
 	destinationFileHandle, err := os.Create(filepath.Clean(destinationFile))
 	if err != nil {
-		return fmt.Errorf("unable to create new backup file %q: %w",
+		return "", fmt.Errorf("unable to create new backup file %q: %w",
 			destinationFile, err)
 	}
 
@@ -219,23 +537,9 @@ func BackupFile(sourceFilename string, destinationDirectory string) error {
 		}
 	}()
 
-	// guard against invalid source files
-	// NOTE: This shouldn't be possible since we only add files to a list to
-	// be removed once we verify checksum and collect file size details, but
-	// to be complete (e.g, perhaps if/when this function is moved to a
-	// standalone package for use by other applications one day) we go ahead
-	// and verify again that the source file is a valid backup source
-	sourceFileStat, err := os.Stat(sourceFilename)
-	if err != nil {
-		return err
-	}
-	if !sourceFileStat.Mode().IsRegular() {
-		return fmt.Errorf("%q is not a regular file", sourceFileStat)
-	}
-
-	sourceFileHandle, err := os.Open(filepath.Clean(sourceFilename))
+	sourceFileHandle, err := os.Open(filepath.Clean(diskPath))
 	if err != nil {
-		return fmt.Errorf("unable to open source file %q in order to create backup copy: %w",
+		return "", fmt.Errorf("unable to open source file %q in order to create backup copy: %w",
 			sourceFilename, err)
 	}
 
@@ -256,7 +560,7 @@ func BackupFile(sourceFilename string, destinationDirectory string) error {
 	if err != nil {
 		// copy failed, we should cleanup here
 		log.Printf("failed to copy %q to %q: %s\n", sourceFilename, destinationFile, err)
-		return fmt.Errorf("failed to copy %q to %q: %w", sourceFilename, destinationFile, err)
+		return "", fmt.Errorf("failed to copy %q to %q: %w", sourceFilename, destinationFile, err)
 	}
 
 	// I encountered this when I unintentionally switched the dest/source
@@ -264,15 +568,16 @@ func BackupFile(sourceFilename string, destinationDirectory string) error {
 	// not copy to destination from source)
 	if sizeCopied != sourceFileStat.Size() {
 		// no content copied failed, we should consider this a failure
-		sizeCopiedMismatchMsg := fmt.Sprintf(
-			"failed to copy %q to %q: %d of %d bytes copied\n",
+		err := fmt.Errorf(
+			"%w: failed to copy %q to %q: %d of %d bytes copied",
+			ErrIncompleteCopy,
 			sourceFilename,
 			destinationFile,
 			sizeCopied,
 			sourceFileStat.Size(),
 		)
-		log.Println(sizeCopiedMismatchMsg)
-		return errors.New(sizeCopiedMismatchMsg)
+		log.Println(err)
+		return "", err
 	}
 
 	// copy was successful, we should cleanup and log (DEBUG) how much data
@@ -286,7 +591,7 @@ func BackupFile(sourceFilename string, destinationDirectory string) error {
 	)
 
 	if err := destinationFileHandle.Sync(); err != nil {
-		return fmt.Errorf(
+		return "", fmt.Errorf(
 			"failed to explicitly sync file %q after backup attempt: %w",
 			destinationFile,
 			err,
@@ -294,13 +599,17 @@ func BackupFile(sourceFilename string, destinationDirectory string) error {
 	}
 
 	if err := sourceFileHandle.Close(); err != nil {
-		return fmt.Errorf(
+		return "", fmt.Errorf(
 			"failed to close original file %q after backup attempt: %w",
 			sourceFilename,
 			err,
 		)
 	}
 
-	return destinationFileHandle.Close()
+	if err := destinationFileHandle.Close(); err != nil {
+		return "", err
+	}
+
+	return destinationFile, nil
 
 }
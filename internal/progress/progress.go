@@ -0,0 +1,181 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package progress reports the progress of long running operations (e.g.,
+// generating checksums) along with throughput and an estimate of the time
+// remaining.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// terminalInterval is how often progress is redrawn in place when
+	// writing to a terminal.
+	terminalInterval time.Duration = 250 * time.Millisecond
+
+	// logInterval is how often a progress line is logged when not writing
+	// to a terminal (e.g., when output is redirected to a file).
+	logInterval time.Duration = 15 * time.Second
+
+	// megabyte is the number of bytes in a (decimal) megabyte, used when
+	// reporting throughput.
+	megabyte float64 = 1000 * 1000
+)
+
+// Meter tracks the number of bytes processed out of a known total and
+// periodically reports the percent complete, throughput (MB/s) and an
+// estimate of the time remaining. Progress is redrawn in place when
+// standard error is a terminal and logged periodically otherwise. A nil
+// Meter is valid and reports nothing.
+type Meter struct {
+	mu       sync.Mutex
+	label    string
+	total    int64
+	done     int64
+	start    time.Time
+	last     time.Time
+	terminal bool
+	interval time.Duration
+	out      io.Writer
+}
+
+// NewMeter returns a Meter for an operation processing the specified total
+// number of bytes.
+func NewMeter(label string, total int64) *Meter {
+
+	terminal := IsTerminal(os.Stderr)
+
+	interval := logInterval
+	if terminal {
+		interval = terminalInterval
+	}
+
+	now := time.Now()
+
+	return &Meter{
+		label:    label,
+		total:    total,
+		start:    now,
+		last:     now,
+		terminal: terminal,
+		interval: interval,
+		out:      os.Stderr,
+	}
+}
+
+// IsTerminal indicates whether the provided file is a terminal (character
+// device) as opposed to a pipe or regular file.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Add records that the specified number of bytes have been processed,
+// reporting progress if the reporting interval has elapsed.
+func (m *Meter) Add(bytes int64) {
+
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.done += bytes
+
+	now := time.Now()
+	if now.Sub(m.last) < m.interval {
+		return
+	}
+	m.last = now
+
+	m.report(now)
+}
+
+// Done reports final progress for the operation. When drawing progress in
+// place, the line is ended so that later output is not overwritten.
+func (m *Meter) Done() {
+
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Nothing was reported for operations which finished quickly.
+	if m.last.Equal(m.start) {
+		return
+	}
+
+	m.report(time.Now())
+
+	if m.terminal {
+		fmt.Fprintln(m.out)
+	}
+}
+
+// status returns the percent complete, throughput and estimated time
+// remaining as of the specified time.
+func (m *Meter) status(now time.Time) string {
+
+	elapsed := now.Sub(m.start)
+
+	var percent float64
+	if m.total > 0 {
+		percent = float64(m.done) / float64(m.total) * 100
+	}
+
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(m.done) / elapsed.Seconds()
+	}
+
+	eta := "unknown"
+	switch {
+	case m.done >= m.total:
+		eta = "0s"
+	case rate > 0:
+		remaining := time.Duration(float64(m.total-m.done) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf(
+		"%s: %.1f%% (%.1f of %.1f MB), %.1f MB/s, ETA %s",
+		m.label,
+		percent,
+		float64(m.done)/megabyte,
+		float64(m.total)/megabyte,
+		rate/megabyte,
+		eta,
+	)
+}
+
+// report writes the current progress, redrawing the line in place when
+// writing to a terminal.
+func (m *Meter) report(now time.Time) {
+
+	if m.terminal {
+		// Clear to the end of the line in case the previous status was
+		// longer.
+		fmt.Fprintf(m.out, "\r%s\033[K", m.status(now))
+		return
+	}
+
+	log.Println(m.status(now))
+}
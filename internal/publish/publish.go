@@ -0,0 +1,155 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package publish posts scan results to a remote HTTP endpoint, allowing a
+// central inventory service to collect results from multiple machines.
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// requestTimeout limits how long posting results may take. Results for
+// large scans may list many duplicate file sets.
+const requestTimeout time.Duration = 5 * time.Minute
+
+// maxErrorBodySize limits how much of a failed response body is included in
+// the returned error.
+const maxErrorBodySize int64 = 512
+
+// Options specifies the endpoint that results are posted to.
+type Options struct {
+
+	// URL is the http or https URL that results are posted to
+	URL string
+
+	// Headers lists additional request headers in "Name: value" form (e.g.,
+	// API keys required by the endpoint)
+	Headers []string
+
+	// Token is an optional bearer token sent via the Authorization header
+	Token string
+}
+
+// RedactedURL returns the scheme and host of the URL for use in log
+// messages and errors. Other URL components (user information, path and
+// query) are omitted since they commonly carry credentials.
+func (o Options) RedactedURL() string {
+
+	u, err := url.Parse(o.URL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+
+	redacted := url.URL{Scheme: u.Scheme, Host: u.Host}
+
+	return redacted.String()
+}
+
+// Validate verifies that the URL is usable and that all headers are in
+// "Name: value" form.
+func (o Options) Validate() error {
+
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		// Parse errors include the URL; only the reason is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("invalid results URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid results URL %q; an http or https URL is required", o.RedactedURL())
+	}
+
+	for _, header := range o.Headers {
+		if _, _, err := ParseHeader(header); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseHeader splits a header in "Name: value" form into its name and
+// value.
+func ParseHeader(header string) (string, string, error) {
+
+	name, value, found := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t\r\n") ||
+		strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("invalid header %q; \"Name: value\" form required", header)
+	}
+
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// Post encodes the provided results as JSON and posts them to the
+// configured URL along with the configured headers.
+func Post(opts Options, results interface{}) error {
+
+	payload, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to encode results as JSON: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, opts.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request for %q: %w", opts.RedactedURL(), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	for _, header := range opts.Headers {
+		name, value, err := ParseHeader(header)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Request errors include the full URL; only the reason is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to post results to %q: %w", opts.RedactedURL(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf(
+			"failed to post results to %q: unexpected response %q: %s",
+			opts.RedactedURL(),
+			resp.Status,
+			strings.TrimSpace(string(body)),
+		)
+	}
+
+	return nil
+}
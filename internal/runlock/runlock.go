@@ -0,0 +1,121 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package runlock provides lock files preventing more than one instance of
+// this application (e.g., started by competing cron entries) from operating
+// on the same files at the same time.
+package runlock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLocked indicates that a lock file is held by another instance of this
+// application.
+var ErrLocked = errors.New("lock held by another process")
+
+// Lock is a lock file created by this instance of the application.
+type Lock struct {
+	path string
+}
+
+// Acquire creates the lock file at the specified path, recording the
+// process ID, hostname and time the lock was acquired. ErrLocked is
+// returned (along with the details recorded by the holder) if the lock file
+// already exists, unless force is set, in which case the existing lock file
+// is removed first. Lock files are not removed by the operating system if
+// the holder exits unexpectedly; force is intended for removing such stale
+// locks.
+func Acquire(path string, force bool) (*Lock, error) {
+
+	path = filepath.Clean(path)
+
+	if force {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove lock file %q: %w", path, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("%w: %q (%s)", ErrLocked, path, holder(path))
+		}
+		return nil, fmt.Errorf("failed to create lock file %q: %w", path, err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	_, writeErr := fmt.Fprintf(file, "pid=%d\nhost=%s\nstarted=%s\n",
+		os.Getpid(),
+		hostname,
+		time.Now().Format(time.RFC3339),
+	)
+	closeErr := file.Close()
+
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to write lock file %q: %w", path, err)
+	}
+
+	return &Lock{path: path}, nil
+}
+
+// Path returns the path to the lock file.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// Release removes the lock file. Releasing a nil Lock does nothing.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock file %q: %w", l.path, err)
+	}
+
+	return nil
+}
+
+// holder returns a description of the holder of the specified lock file
+// using the details recorded in the file.
+func holder(path string) string {
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "holder unknown"
+	}
+
+	details := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			details[key] = value
+		}
+	}
+
+	if details["pid"] == "" {
+		return "holder unknown"
+	}
+
+	return fmt.Sprintf(
+		"held by process %s on host %s since %s",
+		details["pid"],
+		details["host"],
+		details["started"],
+	)
+}
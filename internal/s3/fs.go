@@ -0,0 +1,277 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS provides read-only access to the objects within a bucket as an
+// fs.FS. Object keys are treated as slash-separated paths, with common
+// prefixes presented as directories.
+type FS struct {
+	client *Client
+	bucket string
+	ctx    context.Context
+}
+
+// Compile-time checks that FS implements the optional interfaces used by
+// fs.WalkDir and fs.Stat to avoid extra requests.
+var (
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// NewFS returns an fs.FS for the specified bucket. The provided context is
+// used for all requests made via the filesystem.
+func (c *Client) NewFS(ctx context.Context, bucket string) *FS {
+	return &FS{
+		client: c,
+		bucket: bucket,
+		ctx:    ctx,
+	}
+}
+
+// Open opens the named object (or directory) for reading.
+func (fsys *FS) Open(name string) (fs.File, error) {
+
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		return &dir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	return &file{fsys: fsys, name: name, info: info}, nil
+}
+
+// Stat returns a FileInfo describing the named object (or directory).
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return info, nil
+}
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	prefix := dirPrefix(name)
+	objects, prefixes, err := fsys.client.ListObjects(fsys.ctx, fsys.bucket, prefix, "/")
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(objects)+len(prefixes))
+
+	for _, commonPrefix := range prefixes {
+		dirName := strings.TrimSuffix(strings.TrimPrefix(commonPrefix, prefix), "/")
+		if dirName == "" {
+			continue
+		}
+		entries = append(entries, fileInfo{name: dirName, dir: true})
+	}
+
+	for _, object := range objects {
+		objectName := strings.TrimPrefix(object.Key, prefix)
+
+		// Skip "directory marker" objects created by some tools
+		if objectName == "" || strings.HasSuffix(objectName, "/") {
+			continue
+		}
+		entries = append(entries, fileInfo{name: objectName, object: object})
+	}
+
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// stat retrieves details for the named object, falling back to treating the
+// name as a directory if an object with that key does not exist.
+func (fsys *FS) stat(name string) (fileInfo, error) {
+
+	if !fs.ValidPath(name) {
+		return fileInfo{}, fs.ErrInvalid
+	}
+
+	if name == "." {
+		return fileInfo{name: ".", dir: true}, nil
+	}
+
+	object, err := fsys.client.HeadObject(fsys.ctx, fsys.bucket, name)
+	switch {
+	case err == nil:
+		return fileInfo{name: path.Base(name), object: object}, nil
+	case !errors.Is(err, ErrNotFound):
+		return fileInfo{}, err
+	}
+
+	// Directories do not exist as objects; confirm that at least one object
+	// exists beneath the prefix.
+	objects, prefixes, err := fsys.client.ListObjects(fsys.ctx, fsys.bucket, dirPrefix(name), "/")
+	if err != nil {
+		return fileInfo{}, err
+	}
+
+	if len(objects) == 0 && len(prefixes) == 0 {
+		return fileInfo{}, fs.ErrNotExist
+	}
+
+	return fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// dirPrefix returns the key prefix used to list the content of the named
+// directory.
+func dirPrefix(name string) string {
+	if name == "." {
+		return ""
+	}
+
+	return name + "/"
+}
+
+// fileInfo describes an object or directory and implements both
+// fs.FileInfo and fs.DirEntry.
+type fileInfo struct {
+	name   string
+	dir    bool
+	object ObjectInfo
+}
+
+func (fi fileInfo) Name() string { return fi.name }
+func (fi fileInfo) Size() int64  { return fi.object.Size }
+func (fi fileInfo) IsDir() bool  { return fi.dir }
+
+func (fi fileInfo) ModTime() time.Time { return fi.object.LastModified }
+
+// Sys returns the ObjectInfo for objects, allowing callers to make use of
+// the ETag value.
+func (fi fileInfo) Sys() any {
+	if fi.dir {
+		return nil
+	}
+
+	return fi.object
+}
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0500
+	}
+
+	return 0400
+}
+
+func (fi fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+// file is an object opened for reading. The object content is requested on
+// first read.
+type file struct {
+	fsys *FS
+	name string
+	info fileInfo
+	body io.ReadCloser
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.body == nil {
+		body, err := f.fsys.client.GetObject(f.fsys.ctx, f.fsys.bucket, f.name)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = body
+	}
+
+	return f.body.Read(p)
+}
+
+func (f *file) Close() error {
+	if f.body == nil {
+		return nil
+	}
+
+	err := f.body.Close()
+	f.body = nil
+
+	return err
+}
+
+// dir is a directory opened for reading.
+type dir struct {
+	fsys    *FS
+	name    string
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+	loaded  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	if !d.loaded {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.loaded = true
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+
+	return remaining[:n], nil
+}
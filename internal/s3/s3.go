@@ -0,0 +1,508 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package s3 provides a minimal client for Amazon S3 (and S3-compatible
+// object stores) sufficient for listing, reading and writing objects
+// without pulling in the full AWS SDK. Requests are signed using AWS
+// Signature Version 4 with credentials sourced from the environment.
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// URLScheme is the scheme used to identify S3 paths (e.g.,
+// s3://bucket/prefix).
+const URLScheme string = "s3"
+
+// Environment variables used to configure the client. These match the
+// variables used by the AWS CLI and SDKs.
+const (
+	EnvAccessKeyID     string = "AWS_ACCESS_KEY_ID"
+	EnvSecretAccessKey string = "AWS_SECRET_ACCESS_KEY"
+	EnvSessionToken    string = "AWS_SESSION_TOKEN"
+	EnvRegion          string = "AWS_REGION"
+	EnvDefaultRegion   string = "AWS_DEFAULT_REGION"
+	EnvEndpointURL     string = "AWS_ENDPOINT_URL"
+)
+
+const (
+	defaultRegion      string = "us-east-1"
+	signingAlgorithm   string = "AWS4-HMAC-SHA256"
+	signingService     string = "s3"
+	amzDateFormat      string = "20060102T150405Z"
+	amzShortDateFormat string = "20060102"
+
+	// emptyPayloadHash is the SHA256 hash of an empty request body.
+	emptyPayloadHash string = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// unsignedPayload is used in place of a payload hash when streaming
+	// request bodies that have not been hashed up front.
+	unsignedPayload string = "UNSIGNED-PAYLOAD"
+)
+
+// ErrMissingCredentials indicates that credentials required to sign
+// requests were not found in the environment.
+var ErrMissingCredentials = errors.New("missing S3 credentials")
+
+// ErrNotFound indicates that the requested object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidURL indicates that a provided value is not a valid S3 URL.
+var ErrInvalidURL = errors.New("invalid S3 URL")
+
+// Client is a minimal S3 API client.
+type Client struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+
+	// endpoint is an optional custom endpoint for S3-compatible object
+	// stores. If set, path-style requests are used.
+	endpoint *url.URL
+
+	httpClient *http.Client
+}
+
+// ObjectInfo describes an object stored within a bucket.
+type ObjectInfo struct {
+
+	// Key is the full key for the object within the bucket.
+	Key string
+
+	// Size is the size of the object in bytes.
+	Size int64
+
+	// LastModified is the last modification time of the object.
+	LastModified time.Time
+
+	// ETag is the entity tag for the object with surrounding quotes
+	// removed. For objects uploaded in a single part without customer or
+	// KMS managed encryption this is the MD5 digest of the object content.
+	ETag string
+}
+
+// ContentHint returns the MD5 digest of the object content if it can be
+// reliably determined from the ETag. Objects uploaded using multipart
+// uploads have ETag values which are not MD5 digests and are reported as
+// unavailable.
+func (oi ObjectInfo) ContentHint() (string, bool) {
+	if len(oi.ETag) != 32 || strings.Contains(oi.ETag, "-") {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(oi.ETag); err != nil {
+		return "", false
+	}
+
+	return "md5:" + strings.ToLower(oi.ETag), true
+}
+
+// IsURL indicates whether the provided path is an S3 URL.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, URLScheme+"://")
+}
+
+// ParseURL parses an S3 URL of the form s3://bucket/prefix into its bucket
+// and (optional) prefix components. Any leading or trailing slashes are
+// removed from the prefix.
+func ParseURL(rawURL string) (string, string, error) {
+
+	if !IsURL(rawURL) {
+		return "", "", fmt.Errorf("%w: %q does not begin with %s://", ErrInvalidURL, rawURL, URLScheme)
+	}
+
+	remainder := strings.TrimPrefix(rawURL, URLScheme+"://")
+	bucket, prefix, _ := strings.Cut(remainder, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("%w: %q is missing bucket name", ErrInvalidURL, rawURL)
+	}
+
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// NewClientFromEnv creates a new Client using credentials, region and
+// (optional) custom endpoint settings from the environment.
+func NewClientFromEnv() (*Client, error) {
+
+	client := Client{
+		accessKeyID:     os.Getenv(EnvAccessKeyID),
+		secretAccessKey: os.Getenv(EnvSecretAccessKey),
+		sessionToken:    os.Getenv(EnvSessionToken),
+		region:          os.Getenv(EnvRegion),
+	}
+
+	if client.accessKeyID == "" || client.secretAccessKey == "" {
+		return nil, fmt.Errorf(
+			"%w: %s and %s environment variables are required",
+			ErrMissingCredentials,
+			EnvAccessKeyID,
+			EnvSecretAccessKey,
+		)
+	}
+
+	if client.region == "" {
+		client.region = os.Getenv(EnvDefaultRegion)
+	}
+	if client.region == "" {
+		client.region = defaultRegion
+	}
+
+	if endpoint := os.Getenv(EnvEndpointURL); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s value %q", EnvEndpointURL, endpoint)
+		}
+		client.endpoint = u
+	}
+
+	// No overall timeout is applied since object downloads of large files
+	// may legitimately take a long time; connection setup and initial
+	// responses are bounded instead.
+	client.httpClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   15 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+			MaxIdleConnsPerHost:   8,
+		},
+	}
+
+	return &client, nil
+}
+
+// objectURL returns the URL used to access the specified key within a
+// bucket.
+func (c *Client) objectURL(bucket string, key string, query url.Values) *url.URL {
+
+	var u url.URL
+
+	switch {
+	case c.endpoint != nil:
+		u = *c.endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	default:
+		u.Scheme = "https"
+		u.Host = fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, c.region)
+		u.Path = "/" + key
+	}
+
+	// Use the same encoding for the request as is used when signing the
+	// request so that the two are guaranteed to match.
+	u.RawPath = uriEncode(u.Path, false)
+
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+
+	return &u
+}
+
+// do signs and submits a request, returning the response for the caller to
+// process. Non-2xx responses are converted to errors.
+func (c *Client) do(ctx context.Context, method string, u *url.URL, body io.Reader, contentLength int64, payloadHash string) (*http.Response, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare S3 request: %w", err)
+	}
+
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+
+	c.sign(req, u, payloadHash, time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 request %s %s failed: %w", method, u.Redacted(), err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u.Path)
+	}
+
+	// Error details are provided as an XML document for most requests. HEAD
+	// requests have no body.
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+
+	return nil, fmt.Errorf(
+		"S3 request %s %s failed: %s %s %s",
+		method,
+		u.Redacted(),
+		resp.Status,
+		apiErr.Code,
+		apiErr.Message,
+	)
+}
+
+// sign applies AWS Signature Version 4 headers to the provided request.
+func (c *Client) sign(req *http.Request, u *url.URL, payloadHash string, now time.Time) {
+
+	amzDate := now.Format(amzDateFormat)
+	shortDate := now.Format(amzShortDateFormat)
+
+	req.Host = u.Host
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if c.sessionToken != "" {
+		headers["x-amz-security-token"] = c.sessionToken
+	}
+
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{shortDate, c.region, signingService, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.secretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, c.region)
+	signingKey = hmacSHA256(signingKey, signingService)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm,
+		c.accessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+// ListObjects lists objects and (if delimiter is non-empty) common prefixes
+// beneath the specified prefix within a bucket. All result pages are
+// retrieved before returning.
+func (c *Client) ListObjects(ctx context.Context, bucket string, prefix string, delimiter string) ([]ObjectInfo, []string, error) {
+
+	var objects []ObjectInfo
+	var prefixes []string
+	var continuationToken string
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, "", query), nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var result struct {
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+			Contents              []struct {
+				Key          string    `xml:"Key"`
+				LastModified time.Time `xml:"LastModified"`
+				ETag         string    `xml:"ETag"`
+				Size         int64     `xml:"Size"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+		}
+
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode S3 object listing: %w", err)
+		}
+
+		for _, item := range result.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          item.Key,
+				Size:         item.Size,
+				LastModified: item.LastModified,
+				ETag:         strings.Trim(item.ETag, `"`),
+			})
+		}
+
+		for _, item := range result.CommonPrefixes {
+			prefixes = append(prefixes, item.Prefix)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	return objects, prefixes, nil
+}
+
+// HeadObject retrieves metadata for the specified object. ErrNotFound is
+// returned if the object does not exist.
+func (c *Client) HeadObject(ctx context.Context, bucket string, key string) (ObjectInfo, error) {
+
+	resp, err := c.do(ctx, http.MethodHead, c.objectURL(bucket, key, nil), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	_ = resp.Body.Close()
+
+	info := ObjectInfo{
+		Key:  key,
+		Size: resp.ContentLength,
+		ETag: strings.Trim(resp.Header.Get("ETag"), `"`),
+	}
+
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = lastModified
+	}
+
+	return info, nil
+}
+
+// GetObject retrieves the content of the specified object. The caller is
+// responsible for closing the returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(bucket, key, nil), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// PutObject uploads content of the specified size to the specified key
+// within a bucket, replacing any existing object with the same key.
+func (c *Client) PutObject(ctx context.Context, bucket string, key string, body io.Reader, size int64) error {
+
+	resp, err := c.do(ctx, http.MethodPut, c.objectURL(bucket, key, nil), body, size, unsignedPayload)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using the provided key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters encoded and sorted as
+// required by AWS Signature Version 4.
+func canonicalQuery(query url.Values) string {
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes a value as required by AWS Signature Version 4. Only
+// unreserved characters are left as-is. If encodeSlash is false, slashes
+// are left as-is (used for object key paths).
+func uriEncode(value string, encodeSlash bool) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9'),
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,220 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package s3
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 -- used to compare with S3 ETag values only
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentInfo describes the content of a file uploaded (or to be uploaded)
+// as an object.
+type ContentInfo struct {
+
+	// Size is the size of the content in bytes.
+	Size int64
+
+	// MD5 is the hex-encoded MD5 digest of the content, used for comparison
+	// with object ETag values.
+	MD5 string
+
+	// SHA256 is the hex-encoded SHA256 hash of the content.
+	SHA256 string
+}
+
+// URL returns the S3 URL for the specified key within a bucket.
+func URL(bucket string, key string) string {
+	return URLScheme + "://" + bucket + "/" + key
+}
+
+// JoinKey joins the provided key prefix and name using a forward slash,
+// omitting the separator if the prefix is empty.
+func JoinKey(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(name, "/")
+}
+
+// hashingReader returns a reader which hashes content as it is read along
+// with a function returning the resulting ContentInfo.
+func hashingReader(r io.Reader) (io.Reader, func() ContentInfo) {
+
+	md5Hash := md5.New() // #nosec G401 -- used to compare with S3 ETag values only
+	sha256Hash := sha256.New()
+	var size int64
+
+	counter := writerFunc(func(p []byte) (int, error) {
+		size += int64(len(p))
+		return len(p), nil
+	})
+
+	tee := io.TeeReader(r, io.MultiWriter(md5Hash, sha256Hash, counter))
+
+	return tee, func() ContentInfo {
+		return ContentInfo{
+			Size:   size,
+			MD5:    fmt.Sprintf("%x", md5Hash.Sum(nil)),
+			SHA256: fmt.Sprintf("%x", sha256Hash.Sum(nil)),
+		}
+	}
+}
+
+// writerFunc adapts a function to the io.Writer interface.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// openFile opens the specified local file, returning the file along with
+// its size.
+func openFile(filename string) (*os.File, int64, error) {
+
+	file, err := os.Open(filepath.Clean(filename))
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to open %q: %w", filename, err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("unable to retrieve details for %q: %w", filename, err)
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("%q is not a regular file", filename)
+	}
+
+	return file, fileInfo.Size(), nil
+}
+
+// closeFile closes the provided file, logging any errors.
+func closeFile(file *os.File, filename string) {
+	if err := file.Close(); err != nil {
+		log.Printf(
+			"error occurred closing file %q: %v",
+			filename,
+			err,
+		)
+	}
+}
+
+// HashFile returns details of the content of the specified local file for
+// comparison with objects.
+func HashFile(filename string) (ContentInfo, error) {
+
+	file, size, err := openFile(filename)
+	if err != nil {
+		return ContentInfo{}, err
+	}
+	defer closeFile(file, filename)
+
+	r, info := hashingReader(file)
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return ContentInfo{}, fmt.Errorf("failed to read %q: %w", filename, err)
+	}
+
+	contentInfo := info()
+	if contentInfo.Size != size {
+		return ContentInfo{}, fmt.Errorf("%q changed while being read", filename)
+	}
+
+	return contentInfo, nil
+}
+
+// UploadFile uploads the content of the specified local file to the
+// specified key within a bucket, replacing any existing object with the same
+// key. Details of the uploaded content are returned for verification.
+func (c *Client) UploadFile(ctx context.Context, bucket string, key string, filename string) (ContentInfo, error) {
+
+	file, size, err := openFile(filename)
+	if err != nil {
+		return ContentInfo{}, err
+	}
+	defer closeFile(file, filename)
+
+	r, info := hashingReader(file)
+	if err := c.PutObject(ctx, bucket, key, r, size); err != nil {
+		return ContentInfo{}, fmt.Errorf("failed to upload %q to %s: %w", filename, URL(bucket, key), err)
+	}
+
+	contentInfo := info()
+	if contentInfo.Size != size {
+		return ContentInfo{}, fmt.Errorf(
+			"failed to upload %q to %s: %d of %d bytes uploaded",
+			filename,
+			URL(bucket, key),
+			contentInfo.Size,
+			size,
+		)
+	}
+
+	return contentInfo, nil
+}
+
+// ObjectMatches indicates whether the specified object has the described
+// content. The object ETag is compared when it is an MD5 digest of the
+// content, otherwise (e.g., for multipart uploads or objects encrypted
+// using KMS managed keys) the object is downloaded and hashed. False is
+// returned (without an error) if the object does not exist.
+func (c *Client) ObjectMatches(ctx context.Context, bucket string, key string, content ContentInfo) (bool, error) {
+
+	objectInfo, err := c.HeadObject(ctx, bucket, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	if objectInfo.Size != content.Size {
+		return false, nil
+	}
+
+	if hint, ok := objectInfo.ContentHint(); ok {
+		return hint == "md5:"+content.MD5, nil
+	}
+
+	body, err := c.GetObject(ctx, bucket, key)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", URL(bucket, key), err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)) == content.SHA256, nil
+}
+
+// ObjectExists indicates whether the specified object exists.
+func (c *Client) ObjectExists(ctx context.Context, bucket string, key string) (bool, error) {
+
+	_, err := c.HeadObject(ctx, bucket, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
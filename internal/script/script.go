@@ -0,0 +1,243 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package script generates shell scripts which remove (and optionally back
+// up) duplicate files, allowing removal to be reviewed and performed
+// separately (e.g., by an operator using a different account).
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// Format specifies the shell that a generated script is written for.
+type Format string
+
+const (
+
+	// Shell generates a POSIX shell script using cp and rm.
+	Shell Format = "sh"
+
+	// PowerShell generates a PowerShell script using Copy-Item and
+	// Remove-Item.
+	PowerShell Format = "powershell"
+)
+
+// Formats is the list of supported script formats.
+var Formats = []Format{
+	Shell,
+	PowerShell,
+}
+
+// IsValid indicates whether the script format is supported.
+func (f Format) IsValid() bool {
+	for _, format := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shellPreamble defines the function used by generated POSIX shell scripts
+// to back up files. Existing backup copies are never overwritten.
+const shellPreamble = `set -eu
+
+backup() {
+	if [ -e "$2" ]; then
+		echo "backup copy $2 of $1 already exists; stopping" >&2
+		exit 1
+	fi
+	mkdir -p "$(dirname "$2")"
+	cp -p "$1" "$2"
+}
+`
+
+// powerShellPreamble defines the function used by generated PowerShell
+// scripts to back up files. Existing backup copies are never overwritten.
+const powerShellPreamble = `$ErrorActionPreference = 'Stop'
+
+function Backup-File([string]$Path, [string]$Destination) {
+    if (Test-Path -LiteralPath $Destination) {
+        throw "backup copy $Destination of $Path already exists; stopping"
+    }
+    New-Item -ItemType Directory -Force -Path (Split-Path -Parent $Destination) | Out-Null
+    Copy-Item -LiteralPath $Path -Destination $Destination
+}
+`
+
+// File is a file to be removed by a generated script.
+type File struct {
+
+	// Path is the fully-qualified path to the file
+	Path string
+
+	// Size is the length of the file in bytes
+	Size int64
+
+	// Checksum is the checksum recorded for the file
+	Checksum string
+}
+
+// Writer writes a script which removes files one duplicate file set at a
+// time. Each file is backed up first if a backup directory was specified.
+type Writer struct {
+	filename  string
+	file      *os.File
+	w         *bufio.Writer
+	format    Format
+	backupDir string
+}
+
+// powerShellQuotes lists the characters which PowerShell accepts as single
+// quotes; each is escaped by doubling it.
+var powerShellQuotes = []string{"'", "\u2018", "\u2019", "\u201a", "\u201b"}
+
+// quote quotes the provided value as a single literal argument for the
+// script format.
+func (f Format) quote(value string) string {
+	switch f {
+	case PowerShell:
+		for _, q := range powerShellQuotes {
+			value = strings.ReplaceAll(value, q, q+q)
+		}
+		return "'" + value + "'"
+	default:
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+}
+
+// NewWriter creates the specified script file and writes the preamble,
+// including the provided description of the files removed by the script.
+// Backup commands are included for each file if backupDir is not empty.
+func NewWriter(filename string, format Format, backupDir string, description string) (*Writer, error) {
+
+	if !format.IsValid() {
+		return nil, fmt.Errorf(
+			"invalid script format %q; supported values: %v",
+			format,
+			Formats,
+		)
+	}
+
+	if !paths.PathExists(filepath.Dir(filepath.Clean(filename))) {
+		return nil, fmt.Errorf("parent directory for specified script file %q to create does not exist", filename)
+	}
+
+	file, err := os.Create(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("error encountered creating file %q: %w", filename, err)
+	}
+
+	sw := Writer{
+		filename:  filename,
+		file:      file,
+		w:         bufio.NewWriter(file),
+		format:    format,
+		backupDir: backupDir,
+	}
+
+	if format == Shell {
+		fmt.Fprintln(sw.w, "#!/bin/sh")
+	}
+	fmt.Fprintln(sw.w, "#")
+	fmt.Fprintf(sw.w, "# Generated by bridge on %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(sw.w, "# %s\n", strings.ReplaceAll(description, "\n", " "))
+	fmt.Fprintln(sw.w, "#")
+	fmt.Fprintln(sw.w, "# Review every command before running this script. Files are removed")
+	fmt.Fprintln(sw.w, "# without further confirmation and the script stops at the first error.")
+	if backupDir != "" {
+		fmt.Fprintf(sw.w, "# Each file is first copied to the backup directory %q.\n", backupDir)
+	}
+	fmt.Fprintln(sw.w, "#")
+	fmt.Fprintln(sw.w)
+
+	switch format {
+	case PowerShell:
+		fmt.Fprint(sw.w, powerShellPreamble)
+	default:
+		fmt.Fprint(sw.w, shellPreamble)
+	}
+
+	return &sw, nil
+}
+
+// WriteSet writes commands to remove (and optionally back up) the provided
+// files from the duplicate file set identified by setID. The files kept
+// from the set are listed in a comment for review.
+func (sw *Writer) WriteSet(setID string, keep []string, remove []File) error {
+
+	if len(remove) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(sw.w)
+	fmt.Fprintf(sw.w, "# Set %s\n", setID)
+	for _, path := range keep {
+		fmt.Fprintf(sw.w, "# keep %q\n", path)
+	}
+
+	for _, file := range remove {
+
+		fmt.Fprintf(sw.w, "# remove %q (%d bytes, %s)\n", file.Path, file.Size, file.Checksum)
+
+		if sw.backupDir != "" {
+			backupPath, err := paths.PlannedBackupPath(file.Path, sw.backupDir)
+			if err != nil {
+				return err
+			}
+
+			switch sw.format {
+			case PowerShell:
+				fmt.Fprintf(sw.w, "Backup-File %s %s\n", sw.format.quote(file.Path), sw.format.quote(backupPath))
+			default:
+				fmt.Fprintf(sw.w, "backup %s %s\n", sw.format.quote(file.Path), sw.format.quote(backupPath))
+			}
+		}
+
+		switch sw.format {
+		case PowerShell:
+			fmt.Fprintf(sw.w, "Remove-Item -LiteralPath %s\n", sw.format.quote(file.Path))
+		default:
+			fmt.Fprintf(sw.w, "rm -- %s\n", sw.format.quote(file.Path))
+		}
+	}
+
+	if err := sw.w.Flush(); err != nil {
+		return fmt.Errorf("error writing commands to %q: %w", sw.filename, err)
+	}
+
+	return nil
+}
+
+// Close flushes any buffered commands, syncs and closes the script file.
+func (sw *Writer) Close() error {
+
+	if err := sw.w.Flush(); err != nil {
+		_ = sw.file.Close()
+		return fmt.Errorf("error writing commands to %q: %w", sw.filename, err)
+	}
+
+	if err := sw.file.Sync(); err != nil {
+		_ = sw.file.Close()
+		return fmt.Errorf("error occurred syncing file %q: %w", sw.filename, err)
+	}
+
+	if err := sw.file.Close(); err != nil {
+		return fmt.Errorf("error occurred closing file %q: %w", sw.filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,904 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package server provides an HTTP API used to start duplicate file scans,
+// query their progress, retrieve duplicate file sets and submit removal
+// decisions. This allows other applications (e.g., home-server dashboards)
+// to use this application as a backend.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/metrics"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/atc0005/bridge/pkg/dupes"
+)
+
+// API endpoints
+const (
+	StatusEndpoint     string = "/api/status"
+	ScansEndpoint      string = "/api/scans"
+	DuplicatesEndpoint string = "/api/duplicates"
+	DecisionsEndpoint  string = "/api/decisions"
+	PreviewEndpoint    string = "/api/preview"
+	SettingsEndpoint   string = "/api/settings"
+)
+
+// Scan states reported via the status endpoint
+const (
+	StateIdle      string = "idle"
+	StateRunning   string = "running"
+	StateCompleted string = "completed"
+	StateCanceled  string = "canceled"
+	StateFailed    string = "failed"
+)
+
+// errUnsupportedContentType indicates that a request body was not sent as
+// JSON.
+var errUnsupportedContentType = errors.New("request body must use Content-Type application/json")
+
+// maxRequestBodySize limits the size of JSON request bodies accepted by the
+// API.
+const maxRequestBodySize int64 = 1 << 20
+
+// Options controls the behavior of a Server.
+type Options struct {
+
+	// BackupDirectory is an optional writable directory path where files
+	// are copied before they are removed
+	BackupDirectory string
+
+	// DryRun forces all removal decisions to be simulated
+	DryRun bool
+
+	// Token is an optional bearer token required for all API requests
+	Token string
+
+	// Metrics (if provided) records the results of completed scans and is
+	// served via the metrics endpoint
+	Metrics *metrics.Metrics
+}
+
+// ScanRequest specifies the criteria for a new scan.
+type ScanRequest struct {
+	Paths         []string `json:"paths"`
+	Recursive     bool     `json:"recursive"`
+	MinSize       int64    `json:"min_size"`
+	MinDuplicates int      `json:"min_duplicates"`
+	IgnoreErrors  bool     `json:"ignore_errors"`
+}
+
+// Status describes the most recent scan.
+type Status struct {
+	State          string         `json:"state"`
+	ScanID         int            `json:"scan_id"`
+	Request        *ScanRequest   `json:"request,omitempty"`
+	StartTime      *time.Time     `json:"start_time,omitempty"`
+	EndTime        *time.Time     `json:"end_time,omitempty"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	SetsFound      int            `json:"sets_found"`
+	Summary        *dupes.Summary `json:"summary,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// File is a file within a duplicate file set.
+type File struct {
+	Path    string    `json:"path"`
+	Dir     string    `json:"dir"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Set is a collection of files with identical content.
+type Set struct {
+	Checksum    string `json:"checksum"`
+	Size        int64  `json:"size"`
+	WastedSpace int64  `json:"wasted_space"`
+	Files       []File `json:"files"`
+}
+
+// DuplicatesResponse lists the duplicate file sets found by the most recent
+// completed scan.
+type DuplicatesResponse struct {
+	ScanID  int           `json:"scan_id"`
+	Summary dupes.Summary `json:"summary"`
+	Sets    []Set         `json:"sets"`
+}
+
+// DecisionRequest lists files from the most recent completed scan which
+// should be removed.
+type DecisionRequest struct {
+	ScanID int      `json:"scan_id"`
+	Remove []string `json:"remove"`
+	DryRun bool     `json:"dry_run"`
+}
+
+// FailedRemoval describes a file which could not be removed.
+type FailedRemoval struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// DecisionResponse reports the outcome of a DecisionRequest.
+type DecisionResponse struct {
+	DryRun  bool            `json:"dry_run"`
+	Removed []string        `json:"removed"`
+	Failed  []FailedRemoval `json:"failed"`
+}
+
+// Settings describes server options relevant to API clients.
+type Settings struct {
+	BackupDirectory string `json:"backup_directory"`
+	DryRun          bool   `json:"dry_run"`
+}
+
+// errorResponse is returned for all failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server tracks scans started via the HTTP API along with the results of the
+// most recent completed scan.
+type Server struct {
+	opts Options
+
+	mu        sync.Mutex
+	status    Status
+	cancel    context.CancelFunc
+	setsFound int
+
+	// results of the most recent completed scan
+	resultsScanID int
+	sets          []Set
+	summary       dupes.Summary
+
+	// deciding indicates whether removal decisions are being applied. New
+	// scans and other decisions are refused meanwhile so that the results
+	// the decisions were validated against remain current.
+	deciding bool
+}
+
+// New creates a Server using the provided options.
+func New(opts Options) *Server {
+	return &Server{
+		opts:   opts,
+		status: Status{State: StateIdle},
+	}
+}
+
+// Handler returns an http.Handler serving the API endpoints along with the
+// embedded web UI. The web UI itself is served without authentication; the
+// API requests it makes are authenticated.
+func (s *Server) Handler() http.Handler {
+
+	api := http.NewServeMux()
+	api.HandleFunc(StatusEndpoint, s.handleStatus)
+	api.HandleFunc(ScansEndpoint, s.handleScans)
+	api.HandleFunc(DuplicatesEndpoint, s.handleDuplicates)
+	api.HandleFunc(DecisionsEndpoint, s.handleDecisions)
+	api.HandleFunc(PreviewEndpoint, s.handlePreview)
+	api.HandleFunc(SettingsEndpoint, s.handleSettings)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", s.authenticate(api))
+	if s.opts.Metrics != nil {
+		mux.Handle(metrics.Endpoint, s.authenticate(s.opts.Metrics.Handler()))
+	}
+	mux.Handle("/", uiHandler())
+
+	return mux
+}
+
+// ListenAndServe serves the API on the specified address until the
+// provided context is canceled. Any running scan is canceled before
+// returning.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve API on %s: %w", addr, err)
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// authenticate rejects cross-site requests and requires the configured
+// bearer token (if any) for all requests. If no token is configured, only
+// requests addressed to localhost or a loopback address are accepted so that
+// a web page cannot reach the API via a hostname it controls (DNS
+// rebinding).
+func (s *Server) authenticate(next http.Handler) http.Handler {
+
+	expected := []byte("Bearer " + s.opts.Token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkOrigin(r); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+
+		if s.opts.Token == "" {
+			if !IsLoopback(r.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf(
+					"host %q not allowed without a bearer token; use localhost or a loopback address",
+					r.Host,
+				))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		provided := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin returns an error if the request changes state (i.e., uses a
+// method other than GET or HEAD) and was made by a page served from another
+// origin, as reported by the browser via the Sec-Fetch-Site or Origin
+// header.
+func checkOrigin(r *http.Request) error {
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return nil
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "cross-site", "same-site":
+		return errors.New("cross-origin requests are not allowed")
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Host, r.Host) {
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+
+	return nil
+}
+
+// IsLoopback reports whether the provided host (optionally including a
+// port, e.g., a Host header value or listen address) names localhost or a
+// loopback address (127.0.0.0/8 or ::1).
+func IsLoopback(host string) bool {
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleStatus reports the state and progress of the most recent scan.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.currentStatus())
+}
+
+// handleScans starts a new scan (POST) or cancels the running scan
+// (DELETE).
+func (s *Server) handleScans(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		s.mu.Lock()
+		running := s.status.State == StateRunning
+		if running {
+			s.cancel()
+		}
+		s.mu.Unlock()
+
+		if !running {
+			writeError(w, http.StatusConflict, errors.New("no scan is running"))
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, s.currentStatus())
+		return
+	}
+
+	var req ScanRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	scanner, err := dupes.NewScanner(dupes.Options{
+		Paths:         req.Paths,
+		Recursive:     req.Recursive,
+		MinSize:       req.MinSize,
+		MinDuplicates: req.MinDuplicates,
+		IgnoreErrors:  req.IgnoreErrors,
+		Handler:       dupes.SetHandlerFunc(s.recordProgress),
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, path := range req.Paths {
+		if !paths.PathExists(path) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("provided path %q does not exist", path))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if s.status.State == StateRunning {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("a scan is already running"))
+		return
+	}
+	if s.deciding {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("removal decisions are being applied"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startTime := time.Now()
+	s.cancel = cancel
+	s.setsFound = 0
+	s.status = Status{
+		State:     StateRunning,
+		ScanID:    s.status.ScanID + 1,
+		Request:   &req,
+		StartTime: &startTime,
+	}
+	scanID := s.status.ScanID
+	s.mu.Unlock()
+
+	// DEBUG
+	log.Printf("Starting scan %d of paths %q", scanID, req.Paths)
+
+	go s.runScan(ctx, scanID, scanner)
+
+	writeJSON(w, http.StatusAccepted, s.currentStatus())
+}
+
+// runScan performs a scan, recording the results once complete.
+func (s *Server) runScan(ctx context.Context, scanID int, scanner *dupes.Scanner) {
+
+	result, err := scanner.Scan(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel()
+	s.cancel = nil
+
+	endTime := time.Now()
+	s.status.EndTime = &endTime
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.status.State = StateCanceled
+		log.Printf("Scan %d canceled", scanID)
+		return
+
+	case err != nil:
+		s.opts.Metrics.AddErrors(1)
+		s.status.State = StateFailed
+		s.status.Error = err.Error()
+		log.Printf("Scan %d failed: %v", scanID, err)
+		return
+	}
+
+	summary := result.Summary()
+	s.status.State = StateCompleted
+	s.status.Summary = &summary
+	s.resultsScanID = scanID
+	s.summary = summary
+	s.sets = newSets(result.Sets())
+
+	s.opts.Metrics.AddScans(1)
+	s.opts.Metrics.AddFilesScanned(summary.EvaluatedFiles)
+	s.opts.Metrics.AddBytesHashed(summary.HashedBytes)
+	s.opts.Metrics.AddErrors(summary.IgnoredErrors)
+	s.opts.Metrics.SetDuplicates(summary.Sets, summary.WastedSpace)
+
+	log.Printf("Scan %d completed; %d duplicate file sets found", scanID, len(s.sets))
+}
+
+// recordProgress records each duplicate file set found by a running scan.
+func (s *Server) recordProgress(_ context.Context, _ dupes.Set) error {
+	s.mu.Lock()
+	s.setsFound++
+	s.mu.Unlock()
+
+	return nil
+}
+
+// currentStatus returns a snapshot of the most recent scan status.
+func (s *Server) currentStatus() Status {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.SetsFound = s.setsFound
+
+	if status.StartTime != nil {
+		end := time.Now()
+		if status.EndTime != nil {
+			end = *status.EndTime
+		}
+		status.ElapsedSeconds = end.Sub(*status.StartTime).Seconds()
+	}
+
+	return status
+}
+
+// handleSettings reports server options relevant to API clients.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Settings{
+		BackupDirectory: s.opts.BackupDirectory,
+		DryRun:          s.opts.DryRun,
+	})
+}
+
+// handlePreview serves the content of an image file listed in the current
+// duplicate file sets for display by the web UI. Other file types are
+// rejected to avoid serving arbitrary content.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+
+	s.mu.Lock()
+	var listed bool
+	for _, set := range s.sets {
+		for _, file := range set.Files {
+			if file.Path == path {
+				listed = true
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !listed {
+		writeError(w, http.StatusNotFound, fmt.Errorf("file %q is not listed in the current duplicate file sets", path))
+		return
+	}
+
+	f, err := os.Open(filepath.Clean(paths.Resolve(path)))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unable to open %q: %w", path, err))
+		return
+	}
+
+	// #nosec G307
+	// Believed to be a false-positive from recent gosec release
+	// https://github.com/securego/gosec/issues/714
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("error occurred closing file %q: %v", path, err)
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// sniff the content type from the first 512 bytes as documented for
+	// http.DetectContentType
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(contentType, "image/") {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("preview not available for content type %q", contentType))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// handleDuplicates lists the duplicate file sets found by the most recent
+// completed scan.
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	s.mu.Lock()
+	if s.sets == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, errors.New("no completed scan results available"))
+		return
+	}
+
+	resp := DuplicatesResponse{
+		ScanID:  s.resultsScanID,
+		Summary: s.summary,
+		Sets:    s.sets,
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDecisions removes (and optionally backs up) files flagged for
+// removal from the most recent completed scan results.
+func (s *Server) handleDecisions(w http.ResponseWriter, r *http.Request) {
+
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req DecisionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+
+	if s.status.State == StateRunning {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("a scan is running; wait for it to complete before removing files"))
+		return
+	}
+
+	if s.deciding {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("removal decisions are already being applied"))
+		return
+	}
+
+	if s.sets == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, errors.New("no completed scan results available"))
+		return
+	}
+
+	if req.ScanID != s.resultsScanID {
+		err := fmt.Errorf(
+			"scan_id %d does not match the results of the most recent completed scan (%d)",
+			req.ScanID,
+			s.resultsScanID,
+		)
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	if err := validateDecision(s.sets, req.Remove); err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Results are replaced (never modified in place), so the current sets
+	// may be used once the lock is released. Files are verified, backed up
+	// and removed without holding the lock so that status, results and
+	// previews remain available meanwhile.
+	sets := s.sets
+	s.deciding = true
+	s.mu.Unlock()
+
+	dryRun := req.DryRun || s.opts.DryRun
+	resp := DecisionResponse{
+		DryRun:  dryRun,
+		Removed: []string{},
+		Failed:  []FailedRemoval{},
+	}
+
+	checksumIndex := make(map[string]string)
+	for _, set := range sets {
+		for _, file := range set.Files {
+			checksumIndex[file.Path] = set.Checksum
+		}
+	}
+
+	removed := make(map[string]bool, len(req.Remove))
+	for _, path := range req.Remove {
+		if err := s.removeFile(path, checksumIndex[path], dryRun); err != nil {
+			log.Printf("Error encountered while attempting to remove %q: %v", path, err)
+			resp.Failed = append(resp.Failed, FailedRemoval{Path: path, Error: err.Error()})
+			continue
+		}
+		resp.Removed = append(resp.Removed, path)
+		removed[path] = !dryRun
+	}
+
+	s.mu.Lock()
+	s.deciding = false
+	s.sets = pruneRemoved(s.sets, removed)
+	s.opts.Metrics.AddErrors(len(resp.Failed))
+	s.opts.Metrics.SetDuplicates(len(s.sets), wastedSpace(s.sets))
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// removeFile confirms that the file content is unchanged since the scan,
+// optionally backs up the file and then removes it.
+func (s *Server) removeFile(path string, checksum string, dryRun bool) error {
+
+	// Listed paths are normalized (see paths.Normalize) and may differ
+	// from the path used by the filesystem.
+	diskPath := paths.Resolve(path)
+
+	if err := checksums.ParseChecksum(checksum).Verify(diskPath); err != nil {
+		return fmt.Errorf("checksum validation failed for %q: %w", path, err)
+	}
+
+	if dryRun {
+		return paths.RemoveFile(diskPath, dryRun)
+	}
+
+	if s.opts.BackupDirectory != "" {
+		backupPath, err := paths.BackupFile(diskPath, s.opts.BackupDirectory, paths.CollisionFail)
+		if err != nil {
+			return err
+		}
+
+		// Keep the original file if the backup copy is not good.
+		if err := checksums.ParseChecksum(checksum).VerifyCopy(diskPath, backupPath); err != nil {
+			return fmt.Errorf("backup copy %q of %q failed verification: %w", backupPath, path, err)
+		}
+	}
+
+	return paths.RemoveFile(diskPath, dryRun)
+}
+
+// validateDecision confirms that all files flagged for removal are listed in
+// the provided duplicate file sets and that at least one file from each set
+// is retained.
+func validateDecision(sets []Set, remove []string) error {
+
+	if len(remove) == 0 {
+		return errors.New("no files flagged for removal")
+	}
+
+	flagged := make(map[string]bool, len(remove))
+	for _, path := range remove {
+		if flagged[path] {
+			return fmt.Errorf("file %q flagged for removal more than once", path)
+		}
+		flagged[path] = true
+	}
+
+	found := make(map[string]bool, len(remove))
+	for _, set := range sets {
+		var flaggedInSet int
+		for _, file := range set.Files {
+			if flagged[file.Path] {
+				flaggedInSet++
+				found[file.Path] = true
+			}
+		}
+
+		if flaggedInSet > 0 && flaggedInSet == len(set.Files) {
+			return fmt.Errorf(
+				"all files in duplicate file set %s flagged for removal; at least one file must be kept",
+				set.Checksum,
+			)
+		}
+	}
+
+	for _, path := range remove {
+		if !found[path] {
+			return fmt.Errorf("file %q is not listed in the current duplicate file sets", path)
+		}
+	}
+
+	return nil
+}
+
+// pruneRemoved drops removed files from the provided duplicate file sets
+// along with any sets no longer containing duplicates.
+func pruneRemoved(sets []Set, removed map[string]bool) []Set {
+
+	pruned := make([]Set, 0, len(sets))
+	for _, set := range sets {
+		files := make([]File, 0, len(set.Files))
+		for _, file := range set.Files {
+			if !removed[file.Path] {
+				files = append(files, file)
+			}
+		}
+
+		if len(files) < 2 {
+			continue
+		}
+
+		set.Files = files
+		set.WastedSpace = int64(len(files)-1) * set.Size
+		pruned = append(pruned, set)
+	}
+
+	return pruned
+}
+
+// wastedSpace returns the space consumed by duplicate files across the
+// provided duplicate file sets.
+func wastedSpace(sets []Set) int64 {
+
+	var total int64
+	for _, set := range sets {
+		total += set.WastedSpace
+	}
+
+	return total
+}
+
+// newSets converts duplicate file sets returned from a scan to their API
+// representation.
+func newSets(dupeSets []dupes.Set) []Set {
+
+	sets := make([]Set, 0, len(dupeSets))
+	for _, dupeSet := range dupeSets {
+		set := Set{
+			Checksum:    dupeSet.Checksum,
+			Size:        dupeSet.Size,
+			WastedSpace: dupeSet.WastedSpace(),
+			Files:       make([]File, 0, len(dupeSet.Files)),
+		}
+		for _, file := range dupeSet.Files {
+			set.Files = append(set.Files, File{
+				Path:    file.Path,
+				Dir:     file.Dir,
+				Name:    file.Name,
+				Size:    file.Size,
+				ModTime: file.ModTime,
+			})
+		}
+		sets = append(sets, set)
+	}
+
+	return sets
+}
+
+// allowMethods responds with an error if the request method is not one of
+// those specified.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	for _, method := range methods {
+		w.Header().Add("Allow", method)
+	}
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+
+	return false
+}
+
+// decodeJSON decodes the JSON request body into v, rejecting unknown fields.
+// Request bodies not sent as JSON are rejected with errUnsupportedContentType
+// since browsers send other content types (e.g., from HTML forms) cross-site
+// without a preflight request.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedContentType
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	return nil
+}
+
+// writeDecodeError responds to a request whose body could not be decoded.
+func writeDecodeError(w http.ResponseWriter, err error) {
+
+	if errors.Is(err, errUnsupportedContentType) {
+		writeError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+
+	writeError(w, http.StatusBadRequest, err)
+}
+
+// writeJSON writes v as the JSON response body using the specified status
+// code.
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error occurred writing response: %v", err)
+	}
+}
+
+// writeError writes err as a JSON response using the specified status code.
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, errorResponse{Error: err.Error()})
+}
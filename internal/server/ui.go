@@ -0,0 +1,43 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles contains the single-page web UI used to review duplicate file
+// sets and flag files for removal.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiHandler returns an http.Handler serving the embedded web UI.
+func uiHandler() http.Handler {
+
+	// The embedded directory is known to exist; an error here would
+	// indicate a build problem.
+	ui, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+
+	fileServer := http.FileServer(http.FS(ui))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set(
+			"Content-Security-Policy",
+			"default-src 'self'; img-src 'self' blob:; frame-ancestors 'none'",
+		)
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+"use strict";
+
+(function () {
+  const tokenKey = "bridge-api-token";
+  const imageExtensions = /\.(avif|bmp|gif|ico|jpe?g|png|webp)$/i;
+
+  let scanID = 0;
+  let pollTimer = null;
+
+  const $ = (id) => document.getElementById(id);
+
+  // api performs an API request, adding the stored bearer token (if any)
+  // and decoding the JSON response.
+  async function api(method, path, body) {
+    const headers = {};
+    const token = sessionStorage.getItem(tokenKey);
+    if (token) {
+      headers["Authorization"] = "Bearer " + token;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+
+    const resp = await fetch(path, {
+      method: method,
+      headers: headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    if (resp.status === 401) {
+      $("auth").hidden = false;
+      throw new Error("API token required");
+    }
+
+    if (!resp.ok && !resp.headers.get("Content-Type")?.startsWith("application/json")) {
+      throw new Error(resp.status + " " + resp.statusText);
+    }
+
+    const data = await resp.json();
+    if (!resp.ok) {
+      throw new Error(data.error || resp.statusText);
+    }
+
+    return data;
+  }
+
+  function formatBytes(bytes) {
+    const units = ["B", "KB", "MB", "GB", "TB", "PB"];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1000 && unit < units.length - 1) {
+      value /= 1000;
+      unit++;
+    }
+    return (unit === 0 ? value : value.toFixed(1)) + " " + units[unit];
+  }
+
+  function setMessage(text, isError) {
+    const message = $("message");
+    message.textContent = text;
+    message.classList.toggle("error", Boolean(isError));
+  }
+
+  async function refreshStatus() {
+    let status;
+    try {
+      status = await api("GET", "/api/status");
+    } catch (err) {
+      $("status").textContent = err.message;
+      return;
+    }
+
+    let text = "Scan " + status.scan_id + ": " + status.state;
+    if (status.state === "running") {
+      text += " (" + status.sets_found + " sets found, " +
+        Math.round(status.elapsed_seconds) + "s elapsed)";
+    }
+    if (status.error) {
+      text += " - " + status.error;
+    }
+    $("status").textContent = status.scan_id === 0 ? "No scans yet" : text;
+
+    const running = status.state === "running";
+    $("start-scan").disabled = running;
+    $("cancel-scan").disabled = !running;
+
+    if (running) {
+      schedulePoll();
+      return;
+    }
+
+    if (status.state === "completed" && status.scan_id !== scanID) {
+      await loadDuplicates();
+    }
+  }
+
+  function schedulePoll() {
+    clearTimeout(pollTimer);
+    pollTimer = setTimeout(refreshStatus, 1000);
+  }
+
+  async function loadSettings() {
+    try {
+      const settings = await api("GET", "/api/settings");
+      $("backup").textContent = settings.backup_directory
+        ? "Files are backed up to " + settings.backup_directory + " before removal."
+        : "No backup directory configured; files are removed without a backup.";
+      if (settings.dry_run) {
+        $("dry-run").checked = true;
+        $("dry-run").disabled = true;
+      }
+    } catch (err) {
+      $("backup").textContent = err.message;
+    }
+  }
+
+  async function loadDuplicates() {
+    let results;
+    try {
+      results = await api("GET", "/api/duplicates");
+    } catch (err) {
+      return;
+    }
+
+    scanID = results.scan_id;
+    $("results").hidden = false;
+    $("summary").textContent =
+      results.summary.evaluated_files + " files evaluated, " +
+      results.sets.length + " duplicate file sets, " +
+      formatBytes(results.sets.reduce((sum, set) => sum + set.wasted_space, 0)) +
+      " wasted space.";
+
+    const container = $("sets");
+    container.replaceChildren();
+
+    results.sets.forEach((set, index) => {
+      const setElem = document.createElement("div");
+      setElem.className = "set";
+
+      const heading = document.createElement("h3");
+      heading.textContent = "Set " + (index + 1) + ": " + set.files.length +
+        " files, " + formatBytes(set.size) + " each";
+      const checksum = document.createElement("div");
+      checksum.className = "checksum";
+      checksum.textContent = set.checksum;
+      setElem.append(heading, checksum);
+
+      set.files.forEach((file) => {
+        const row = document.createElement("label");
+        row.className = "file";
+
+        const checkbox = document.createElement("input");
+        checkbox.type = "checkbox";
+        checkbox.value = file.path;
+        checkbox.dataset.set = set.checksum;
+        row.append(checkbox);
+
+        if (imageExtensions.test(file.name)) {
+          const img = document.createElement("img");
+          img.alt = file.name;
+          loadPreview(img, file.path);
+          row.append(img);
+        }
+
+        const path = document.createElement("span");
+        path.className = "path";
+        path.textContent = file.path;
+        const modified = document.createElement("span");
+        modified.textContent = new Date(file.mod_time).toLocaleString();
+        row.append(path, modified);
+
+        setElem.append(row);
+      });
+
+      container.append(setElem);
+    });
+  }
+
+  // loadPreview fetches the image via the API so that the bearer token can
+  // be provided, then displays it using an object URL.
+  async function loadPreview(img, path) {
+    const headers = {};
+    const token = sessionStorage.getItem(tokenKey);
+    if (token) {
+      headers["Authorization"] = "Bearer " + token;
+    }
+
+    try {
+      const resp = await fetch("/api/preview?path=" + encodeURIComponent(path), { headers: headers });
+      if (!resp.ok) {
+        img.remove();
+        return;
+      }
+      img.src = URL.createObjectURL(await resp.blob());
+    } catch (err) {
+      img.remove();
+    }
+  }
+
+  async function startScan(event) {
+    event.preventDefault();
+
+    const paths = $("paths").value
+      .split("\n")
+      .map((p) => p.trim())
+      .filter((p) => p !== "");
+
+    try {
+      await api("POST", "/api/scans", {
+        paths: paths,
+        recursive: $("recursive").checked,
+        min_size: Number($("min-size").value),
+        min_duplicates: Number($("min-duplicates").value),
+        ignore_errors: $("ignore-errors").checked,
+      });
+      setMessage("");
+    } catch (err) {
+      $("status").textContent = err.message;
+    }
+
+    refreshStatus();
+  }
+
+  async function cancelScan() {
+    try {
+      await api("DELETE", "/api/scans");
+    } catch (err) {
+      $("status").textContent = err.message;
+    }
+    refreshStatus();
+  }
+
+  async function removeSelected() {
+    const selected = Array.from(document.querySelectorAll("#sets input[type=checkbox]:checked"));
+    if (selected.length === 0) {
+      setMessage("No files selected.", true);
+      return;
+    }
+
+    const dryRun = $("dry-run").checked;
+    const prompt = (dryRun ? "Simulate removal of " : "Remove ") + selected.length + " file(s)?";
+    if (!window.confirm(prompt)) {
+      return;
+    }
+
+    let result;
+    try {
+      result = await api("POST", "/api/decisions", {
+        scan_id: scanID,
+        remove: selected.map((checkbox) => checkbox.value),
+        dry_run: dryRun,
+      });
+    } catch (err) {
+      setMessage(err.message, true);
+      return;
+    }
+
+    let text = (result.dry_run ? "Dry run: would have removed " : "Removed ") +
+      result.removed.length + " file(s).";
+    if (result.failed.length > 0) {
+      text += " Failed: " + result.failed.map((f) => f.path + " (" + f.error + ")").join("; ");
+    }
+    setMessage(text, result.failed.length > 0);
+
+    if (!result.dry_run) {
+      scanID = 0;
+      await loadDuplicates();
+    }
+  }
+
+  function saveToken(event) {
+    event.preventDefault();
+    sessionStorage.setItem(tokenKey, $("token").value);
+    $("auth").hidden = true;
+    loadSettings();
+    refreshStatus();
+  }
+
+  document.addEventListener("DOMContentLoaded", () => {
+    $("auth-form").addEventListener("submit", saveToken);
+    $("scan-form").addEventListener("submit", startScan);
+    $("cancel-scan").addEventListener("click", cancelScan);
+    $("remove").addEventListener("click", removeSelected);
+
+    loadSettings();
+    refreshStatus();
+  });
+})();
@@ -0,0 +1,96 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the (case-insensitive) unit suffixes accepted by
+// ParseByteCount along with the number of bytes in each unit. SI (decimal)
+// units are multiples of 1000 and IEC (binary) units are multiples of 1024.
+var byteUnits = map[string]int64{
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// ParseByteCount parses a size such as 500KB, 10MiB or 4GB (or a plain
+// number of bytes) and returns the size in bytes. Units are
+// case-insensitive and may be separated from the value by a space. SI units
+// (KB, MB, GB, TB, PB) are multiples of 1000 bytes and IEC units (KiB, MiB,
+// GiB, TiB, PiB) are multiples of 1024 bytes. Fractional values (e.g.,
+// 1.5GB) are rounded down to a whole number of bytes.
+func ParseByteCount(s string) (int64, error) {
+
+	value := strings.TrimSpace(s)
+
+	// Split the numeric value from the unit suffix (if any).
+	i := len(value)
+	for i > 0 && (value[i-1] < '0' || value[i-1] > '9') && value[i-1] != '.' {
+		i--
+	}
+	number := strings.TrimSpace(value[:i])
+	suffix := strings.ToLower(strings.TrimSpace(value[i:]))
+
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q: missing numeric value", s)
+	}
+
+	multiplier := int64(1)
+	if suffix != "" {
+		var ok bool
+		multiplier, ok = byteUnits[suffix]
+		if !ok {
+			return 0, fmt.Errorf(
+				"invalid size %q: unknown unit %q; supported units: B, KB, MB, GB, TB, PB, KiB, MiB, GiB, TiB, PiB",
+				s,
+				value[i:],
+			)
+		}
+	}
+
+	if count, err := strconv.ParseInt(number, 10, 64); err == nil {
+		if count < 0 {
+			return 0, fmt.Errorf("invalid size %q: value cannot be negative", s)
+		}
+
+		if count > math.MaxInt64/multiplier {
+			return 0, fmt.Errorf("invalid size %q: value out of range", s)
+		}
+
+		return count * multiplier, nil
+	}
+
+	count, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	if count < 0 {
+		return 0, fmt.Errorf("invalid size %q: value cannot be negative", s)
+	}
+
+	size := count * float64(multiplier)
+	if math.IsNaN(size) || size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: value out of range", s)
+	}
+
+	return int64(size), nil
+}
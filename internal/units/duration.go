@@ -0,0 +1,58 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits are units of time longer than those supported by
+// time.ParseDuration, along with their length. Months and years are
+// approximated as 30 and 365 days respectively.
+var durationUnits = map[string]time.Duration{
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration string as accepted by time.ParseDuration
+// (e.g., 12h, 90m) with additional support for whole numbers of days (e.g.,
+// 30d), weeks (e.g., 2w), months (e.g., 6mo) and years (e.g., 1y).
+func ParseDuration(s string) (time.Duration, error) {
+
+	s = strings.TrimSpace(s)
+
+	for suffix, length := range durationUnits {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+
+		count, err := strconv.ParseInt(strings.TrimSuffix(s, suffix), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+
+		if maxCount := int64(math.MaxInt64 / length); count > maxCount || count < -maxCount {
+			return 0, fmt.Errorf("invalid duration %q: value out of range", s)
+		}
+
+		return time.Duration(count) * length, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+
+	return d, nil
+}
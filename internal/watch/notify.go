@@ -0,0 +1,315 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/paths"
+	"github.com/fsnotify/fsnotify"
+)
+
+// errNotificationsUnavailable indicates that filesystem notifications could
+// not be used (or stopped being delivered) for the monitored paths.
+var errNotificationsUnavailable = errors.New("filesystem notifications unavailable")
+
+// scopes records directories to rescan, keyed by path. The value indicates
+// whether subdirectories are rescanned as well. A nil scopes value covers
+// all monitored paths.
+type scopes map[string]bool
+
+// add records the specified directory, extending an existing entry to
+// include subdirectories if requested.
+func (s scopes) add(dir string, recursive bool) {
+	s[dir] = s[dir] || recursive
+}
+
+// contains indicates whether files within the specified (normalized)
+// directory are covered.
+func (s scopes) contains(dir string) bool {
+
+	if s == nil {
+		return true
+	}
+
+	for scope, recursive := range s {
+		if dir == scope {
+			return true
+		}
+		if recursive && strings.HasPrefix(dir, scope+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startNotifier creates a filesystem notification watcher for the monitored
+// paths (and their subdirectories if monitored).
+func (w *Watcher) startNotifier() (*fsnotify.Watcher, error) {
+
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotificationsUnavailable, err)
+	}
+
+	for _, path := range w.opts.Paths {
+
+		// Events report paths relative to the watched directory as given,
+		// so absolute paths are used to match the paths of indexed files.
+		dir, err := filepath.Abs(path)
+		if err != nil {
+			w.closeNotifier(notifier)
+			return nil, fmt.Errorf("unable to determine absolute path to %q: %w", path, err)
+		}
+
+		if err := w.addWatches(notifier, dir); err != nil {
+			w.closeNotifier(notifier)
+			return nil, err
+		}
+	}
+
+	return notifier, nil
+}
+
+// closeNotifier stops the filesystem notification watcher.
+func (w *Watcher) closeNotifier(notifier *fsnotify.Watcher) {
+	if err := notifier.Close(); err != nil {
+		log.Printf("error occurred closing filesystem notification watcher: %v", err)
+	}
+}
+
+// addWatches adds the specified directory to the notification watcher along
+// with its subdirectories if subdirectories are monitored. Directories
+// removed while being added are skipped.
+func (w *Watcher) addWatches(notifier *fsnotify.Watcher, dir string) error {
+
+	addWatch := func(dir string) error {
+		if err := notifier.Add(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: failed to watch directory %q: %v", errNotificationsUnavailable, dir, err)
+		}
+		return nil
+	}
+
+	if !w.opts.Recursive {
+		return addWatch(dir)
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if !errreport.Ignore(w.opts.IgnoreErrors, err) {
+				return fmt.Errorf("failed to read monitored directory %q: %w", path, err)
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			w.opts.Metrics.AddErrors(1)
+
+			return nil
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		return addWatch(path)
+	})
+}
+
+// notify records the directories reported as changed by the notification
+// watcher and rescans them (along with the directories of files not yet
+// settled) at the configured interval until the provided context is
+// canceled. If notifications may have been lost (e.g., the event queue of
+// the system overflowed), the monitored paths are rescanned in full.
+func (w *Watcher) notify(ctx context.Context, notifier *fsnotify.Watcher, fn EventFunc) error {
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	changed := make(scopes)
+	var rescanAll bool
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return fmt.Errorf("%w: event channel closed", errNotificationsUnavailable)
+			}
+
+			if err := w.record(notifier, event, changed); err != nil {
+				return err
+			}
+
+		case err, ok := <-notifier.Errors:
+			if !ok {
+				return fmt.Errorf("%w: error channel closed", errNotificationsUnavailable)
+			}
+
+			// WARN
+			log.Println("Error encountered receiving filesystem notifications:", err)
+			log.Println("Rescanning monitored paths")
+			w.opts.Metrics.AddErrors(1)
+			rescanAll = true
+
+		case <-ticker.C:
+			if err := w.rescan(changed, rescanAll, fn); err != nil {
+				return err
+			}
+			changed = make(scopes)
+			rescanAll = false
+		}
+	}
+}
+
+// record notes the directories affected by the provided filesystem event.
+// New subdirectories are added to the notification watcher if
+// subdirectories are monitored.
+func (w *Watcher) record(notifier *fsnotify.Watcher, event fsnotify.Event, changed scopes) error {
+
+	changed.add(filepath.Dir(event.Name), false)
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Lstat(event.Name)
+		if err != nil || !info.IsDir() || !w.opts.Recursive {
+			return nil
+		}
+
+		// Files may have been added to the new directory before it was
+		// watched, so its content is scanned as well.
+		if err := w.addWatches(notifier, event.Name); err != nil {
+			return err
+		}
+		changed.add(event.Name, true)
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// The path may have been a directory whose content is no longer
+		// present.
+		changed.add(event.Name, true)
+	}
+
+	return nil
+}
+
+// rescan scans the provided changed directories along with the directories
+// of files not yet settled (or all monitored paths if requested) and applies
+// the results to the index.
+func (w *Watcher) rescan(changed scopes, rescanAll bool, fn EventFunc) error {
+
+	var current map[string]matches.FileMatch
+	var scanned scopes
+	var err error
+
+	switch {
+	case rescanAll:
+		current, err = w.scan()
+
+	default:
+		for _, file := range w.pending {
+			changed.add(filepath.Dir(file.FullPath), false)
+		}
+
+		if len(changed) == 0 {
+			return nil
+		}
+
+		current, scanned, err = w.scanDirs(changed)
+	}
+
+	if err != nil {
+		w.opts.Metrics.AddErrors(1)
+		if !errreport.Ignore(w.opts.IgnoreErrors, err) {
+			return err
+		}
+
+		// WARN
+		log.Println("Error encountered:", err)
+		log.Println("Ignoring error as requested")
+
+		return nil
+	}
+
+	return w.apply(current, scanned, fn)
+}
+
+// scanDirs returns all files currently found within the provided
+// directories which meet the size criteria, keyed by path, along with the
+// directories covered by the scan using normalized paths. Directories which
+// no longer exist are covered by the scan without contributing files.
+func (w *Watcher) scanDirs(dirs scopes) (map[string]matches.FileMatch, scopes, error) {
+
+	errreport.Reset()
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+
+	files := make(map[string]matches.FileMatch)
+	scanned := make(scopes, len(dirs))
+
+	for _, dir := range sorted {
+		recursive := w.opts.Recursive && dirs[dir]
+		diskPath := paths.Resolve(dir)
+
+		info, err := os.Stat(diskPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			scanned.add(paths.Normalize(dir), recursive)
+			continue
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to scan changed directory %q: %w", dir, err)
+		case !info.IsDir():
+			continue
+		}
+
+		fileSizeIndex, ignored, err := matches.ProcessPath(
+			recursive,
+			w.opts.IgnoreErrors,
+			w.opts.FileSizeThreshold,
+			1,
+			nil,
+			diskPath,
+		)
+		w.opts.Metrics.AddErrors(ignored)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan changed directory %q: %w", dir, err)
+		}
+
+		for _, fileMatches := range fileSizeIndex {
+			for _, file := range fileMatches {
+				files[file.FullPath] = file
+			}
+		}
+		scanned.add(paths.Normalize(dir), recursive)
+	}
+
+	w.opts.Metrics.AddScans(1)
+	w.opts.Metrics.AddFilesScanned(len(files))
+
+	return files, scanned, nil
+}
@@ -0,0 +1,469 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package watch provides types and functions used to monitor paths for new
+// or modified files and report files which duplicate existing content as
+// they are introduced.
+//
+// Changes are detected using filesystem notifications, rescanning only the
+// directories reported as changed. If notifications are unavailable (e.g.,
+// unsupported by the platform or filesystem, or the notification limits of
+// the system are reached), the monitored paths are instead periodically
+// rescanned in full.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/errreport"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/metrics"
+)
+
+// MinInterval is the shortest supported delay between checks of changed
+// files (or between scans of the monitored paths if filesystem notifications
+// are unavailable).
+const MinInterval time.Duration = time.Second
+
+// ErrInvalidInterval indicates that the requested delay between checks is
+// below the supported minimum.
+var ErrInvalidInterval = errors.New("invalid scan interval")
+
+// Options specifies the paths to monitor and the criteria applied to the
+// files found within them.
+type Options struct {
+
+	// Paths to monitor for new or modified files
+	Paths []string
+
+	// Recursive indicates whether subdirectories of each path are monitored
+	Recursive bool
+
+	// FileSizeThreshold is the minimum size (in bytes) of monitored files
+	FileSizeThreshold int64
+
+	// DuplicatesThreshold is the number of identical files needed before a
+	// file is reported as a duplicate
+	DuplicatesThreshold int
+
+	// IgnoreErrors indicates whether minor errors (e.g., a file removed
+	// while it was being evaluated) should be logged and skipped
+	IgnoreErrors bool
+
+	// Interval is the delay between checks of changed files. New or
+	// modified files are evaluated once unchanged between two checks. If
+	// filesystem notifications are unavailable, the monitored paths are
+	// rescanned in full at this interval.
+	Interval time.Duration
+
+	// Metrics (if provided) records the files scanned, bytes hashed,
+	// duplicate file sets found and errors encountered
+	Metrics *metrics.Metrics
+}
+
+// Event describes a newly introduced file which duplicates the content of
+// one or more existing files.
+type Event struct {
+
+	// File is the new (or modified) file
+	File matches.FileMatch
+
+	// Checksum shared by all files in the duplicate set
+	Checksum checksums.SHA256Checksum
+
+	// Matches is the complete set of files sharing the same content,
+	// including File
+	Matches matches.FileMatches
+}
+
+// EventFunc is called for each reported Event. Returning a non-nil error
+// stops the Watcher.
+type EventFunc func(event Event) error
+
+// entry tracks a known file along with its checksum once calculated.
+type entry struct {
+	file     matches.FileMatch
+	checksum checksums.SHA256Checksum
+}
+
+// Watcher monitors paths for new or modified files, incrementally updating
+// an index of known files by size and checksum.
+type Watcher struct {
+	opts Options
+
+	// files is the index of settled files, keyed by path
+	files map[string]*entry
+
+	// sizes indexes paths of settled files by file size
+	sizes map[int64]map[string]struct{}
+
+	// pending tracks new or modified files, keyed by path, until they are
+	// unchanged between two checks. This avoids evaluating files which are
+	// still being written.
+	pending map[string]matches.FileMatch
+}
+
+// New creates a Watcher for the provided options.
+func New(opts Options) (*Watcher, error) {
+
+	if len(opts.Paths) == 0 {
+		return nil, fmt.Errorf("one or more paths not provided")
+	}
+
+	if opts.Interval < MinInterval {
+		return nil, fmt.Errorf(
+			"%w: %s is below the minimum of %s",
+			ErrInvalidInterval,
+			opts.Interval,
+			MinInterval,
+		)
+	}
+
+	if opts.DuplicatesThreshold < 2 {
+		return nil, fmt.Errorf("2 is the minimum duplicates number for evaluated files")
+	}
+
+	return &Watcher{
+		opts:    opts,
+		files:   make(map[string]*entry),
+		sizes:   make(map[int64]map[string]struct{}),
+		pending: make(map[string]matches.FileMatch),
+	}, nil
+}
+
+// Run indexes all existing files within the monitored paths and then
+// watches the paths for changes until the provided context is canceled,
+// calling fn for each new or modified file which duplicates the content of
+// other known files. Existing files are indexed without being reported.
+//
+// Directories reported as changed via filesystem notifications are
+// rescanned at the configured interval. If notifications are unavailable,
+// the monitored paths are rescanned in full at the configured interval
+// instead.
+func (w *Watcher) Run(ctx context.Context, fn EventFunc) error {
+
+	// Notifications are started before existing files are indexed so that
+	// changes made while indexing are not missed.
+	notifier, notifyErr := w.startNotifier()
+
+	current, err := w.scan()
+	if err != nil {
+		w.opts.Metrics.AddErrors(1)
+		if notifier != nil {
+			w.closeNotifier(notifier)
+		}
+		return err
+	}
+
+	for path, file := range current {
+		w.add(path, file)
+	}
+
+	if notifyErr == nil {
+		// DEBUG
+		log.Printf("Indexed %d existing files; watching for changes using filesystem notifications", len(w.files))
+
+		notifyErr = w.notify(ctx, notifier, fn)
+		w.closeNotifier(notifier)
+
+		if !errors.Is(notifyErr, errNotificationsUnavailable) {
+			return notifyErr
+		}
+	}
+
+	// WARN
+	log.Println("Filesystem notifications unavailable:", notifyErr)
+	log.Printf("Indexed %d existing files; rescanning monitored paths every %s", len(w.files), w.opts.Interval)
+
+	return w.poll(ctx, fn)
+}
+
+// poll rescans the monitored paths in full at the configured interval until
+// the provided context is canceled.
+func (w *Watcher) poll(ctx context.Context, fn EventFunc) error {
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := w.scan()
+		if err != nil {
+			w.opts.Metrics.AddErrors(1)
+			if !errreport.Ignore(w.opts.IgnoreErrors, err) {
+				return err
+			}
+
+			// WARN
+			log.Println("Error encountered:", err)
+			log.Println("Ignoring error as requested")
+			continue
+		}
+
+		if err := w.apply(current, nil, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// apply updates the index using the results of the latest scan and
+// evaluates each new or modified file which has settled. If scanned is
+// non-nil, only known files within the scanned directories are affected;
+// otherwise the scan is assumed to cover all monitored paths.
+func (w *Watcher) apply(current map[string]matches.FileMatch, scanned scopes, fn EventFunc) error {
+
+	for _, file := range w.update(current, scanned) {
+		if err := w.evaluate(file, fn); err != nil {
+			return err
+		}
+	}
+
+	if w.opts.Metrics != nil {
+		w.opts.Metrics.SetDuplicates(w.duplicates())
+	}
+
+	return nil
+}
+
+// scan returns all files currently found within the monitored paths which
+// meet the size criteria, keyed by path.
+func (w *Watcher) scan() (map[string]matches.FileMatch, error) {
+
+	// Errors ignored during earlier scans are not summarized by this
+	// long-running subcommand; discard them instead of accumulating them.
+	errreport.Reset()
+
+	// ProcessPath is used directly (instead of NewFileSizeIndex) to avoid
+	// repeating per-path debug output on every scan.
+	fileSizeIndexes := make([]matches.FileSizeIndex, 0, len(w.opts.Paths))
+	for _, path := range w.opts.Paths {
+		fileSizeIndex, ignored, err := matches.ProcessPath(
+			w.opts.Recursive,
+			w.opts.IgnoreErrors,
+			w.opts.FileSizeThreshold,
+			1,
+			nil,
+			path,
+		)
+		w.opts.Metrics.AddErrors(ignored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan monitored path %q: %w", path, err)
+		}
+		fileSizeIndexes = append(fileSizeIndexes, fileSizeIndex)
+	}
+	fileSizeIndex := matches.MergeFileSizeIndexes(fileSizeIndexes...)
+
+	files := make(map[string]matches.FileMatch, fileSizeIndex.GetTotalFilesCount())
+	for _, fileMatches := range fileSizeIndex {
+		for _, file := range fileMatches {
+			files[file.FullPath] = file
+		}
+	}
+
+	w.opts.Metrics.AddScans(1)
+	w.opts.Metrics.AddFilesScanned(len(files))
+
+	return files, nil
+}
+
+// update applies the results of the latest scan to the index, returning the
+// new or modified files which have settled since the previous scan. If
+// scanned is non-nil, only known files within the scanned directories are
+// forgotten when missing from the scan results.
+func (w *Watcher) update(current map[string]matches.FileMatch, scanned scopes) []string {
+
+	// forget files which are no longer present
+	for path, known := range w.files {
+		if _, ok := current[path]; !ok && scanned.contains(known.file.ParentDirectory()) {
+			w.remove(path)
+		}
+	}
+	for path, file := range w.pending {
+		if _, ok := current[path]; !ok && scanned.contains(file.ParentDirectory()) {
+			delete(w.pending, path)
+		}
+	}
+
+	var settled []string
+
+	for path, file := range current {
+
+		if known, ok := w.files[path]; ok {
+			if unchanged(known.file, file) {
+				continue
+			}
+
+			// modified files are re-evaluated once they settle
+			w.remove(path)
+		}
+
+		if pending, ok := w.pending[path]; ok && unchanged(pending, file) {
+			delete(w.pending, path)
+			w.add(path, file)
+			settled = append(settled, path)
+			continue
+		}
+
+		w.pending[path] = file
+	}
+
+	sort.Strings(settled)
+
+	return settled
+}
+
+// evaluate generates checksums for the specified file and any other known
+// files of the same size, calling fn if the file duplicates the content of
+// other known files.
+func (w *Watcher) evaluate(path string, fn EventFunc) error {
+
+	newEntry, ok := w.files[path]
+	if !ok {
+		return nil
+	}
+
+	size := newEntry.file.Size()
+	if len(w.sizes[size]) < w.opts.DuplicatesThreshold {
+		return nil
+	}
+
+	if err := w.checksum(newEntry); err != nil {
+		return err
+	}
+	if newEntry.checksum == "" {
+		return nil
+	}
+
+	var duplicates matches.FileMatches
+	for candidatePath := range w.sizes[size] {
+		candidate := w.files[candidatePath]
+
+		if err := w.checksum(candidate); err != nil {
+			return err
+		}
+
+		if candidate.checksum == newEntry.checksum {
+			duplicates = append(duplicates, candidate.file)
+		}
+	}
+
+	if len(duplicates) < w.opts.DuplicatesThreshold {
+		return nil
+	}
+
+	duplicates.SortByModTimeAsc()
+
+	return fn(Event{
+		File:     newEntry.file,
+		Checksum: newEntry.checksum,
+		Matches:  duplicates,
+	})
+}
+
+// checksum generates the checksum for a known file if not already
+// calculated. If errors are ignored as requested, the checksum is left
+// empty when it cannot be generated.
+func (w *Watcher) checksum(known *entry) error {
+
+	if known.checksum != "" {
+		return nil
+	}
+
+	checksum, err := known.file.GenerateCheckSum()
+	if err != nil {
+		w.opts.Metrics.AddErrors(1)
+		if !errreport.Ignore(w.opts.IgnoreErrors, err) {
+			return err
+		}
+
+		// WARN
+		log.Println("Error encountered:", err)
+		log.Println("Ignoring error as requested")
+
+		return nil
+	}
+
+	known.checksum = checksum
+	known.file.Checksum = checksum
+	w.opts.Metrics.AddBytesHashed(known.file.Size())
+
+	return nil
+}
+
+// duplicates returns the number of duplicate file sets among known files
+// with a checksum already calculated along with the space consumed by
+// duplicate files. Existing files are only checksummed once a new file of
+// the same size is introduced, so only duplicates involving files introduced
+// while watching are included.
+func (w *Watcher) duplicates() (int, int64) {
+
+	counts := make(map[checksums.SHA256Checksum]int)
+	sizes := make(map[checksums.SHA256Checksum]int64)
+	for _, known := range w.files {
+		if known.checksum == "" {
+			continue
+		}
+		counts[known.checksum]++
+		sizes[known.checksum] = known.file.Size()
+	}
+
+	var sets int
+	var wastedSpace int64
+	for checksum, count := range counts {
+		if count < w.opts.DuplicatesThreshold {
+			continue
+		}
+		sets++
+		wastedSpace += int64(count-1) * sizes[checksum]
+	}
+
+	return sets, wastedSpace
+}
+
+// add records a settled file in the index.
+func (w *Watcher) add(path string, file matches.FileMatch) {
+	w.files[path] = &entry{file: file}
+
+	size := file.Size()
+	if w.sizes[size] == nil {
+		w.sizes[size] = make(map[string]struct{})
+	}
+	w.sizes[size][path] = struct{}{}
+}
+
+// remove drops a file from the index.
+func (w *Watcher) remove(path string) {
+	known, ok := w.files[path]
+	if !ok {
+		return
+	}
+	delete(w.files, path)
+
+	size := known.file.Size()
+	delete(w.sizes[size], path)
+	if len(w.sizes[size]) == 0 {
+		delete(w.sizes, size)
+	}
+}
+
+// unchanged indicates whether the size and modification time of a file
+// match those recorded previously.
+func unchanged(previous matches.FileMatch, current matches.FileMatch) bool {
+	return previous.Size() == current.Size() &&
+		previous.ModTime().Equal(current.ModTime())
+}
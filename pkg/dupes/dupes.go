@@ -0,0 +1,470 @@
+// Copyright 2020 Adam Chalkley
+//
+// https://github.com/atc0005/bridge
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+// Package dupes provides a supported API for locating duplicate files across
+// one or many paths. This is the same duplicate detection logic used by the
+// bridge CLI, exposed so that other Go programs can embed it without
+// shelling out to the CLI.
+//
+// Files are first grouped by size. Only files sharing a size with at least
+// one other file (per the MinDuplicates option) are hashed, and only files
+// sharing a checksum are reported as duplicates.
+//
+// Basic usage:
+//
+//	scanner, err := dupes.NewScanner(dupes.Options{
+//		Paths:     []string{"/photos/by-date", "/photos/collections"},
+//		Recursive: true,
+//	})
+//	if err != nil {
+//		// handle invalid options
+//	}
+//
+//	result, err := scanner.Scan(context.Background())
+//	if err != nil {
+//		// handle scan failure
+//	}
+//
+//	err = result.Each(func(set dupes.Set) error {
+//		fmt.Println(set.Checksum, len(set.Files))
+//		return nil
+//	})
+package dupes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atc0005/bridge/internal/checksums"
+	"github.com/atc0005/bridge/internal/matches"
+	"github.com/atc0005/bridge/internal/paths"
+)
+
+// DefaultMinDuplicates is the minimum number of identical files needed for
+// those files to be considered a duplicate file set. This value is used if
+// Options.MinDuplicates is not specified.
+const DefaultMinDuplicates int = 2
+
+// ErrNoPaths indicates that no paths were provided to scan.
+var ErrNoPaths = errors.New("no paths provided")
+
+// ErrInvalidOption indicates that a provided option value is invalid.
+var ErrInvalidOption = errors.New("invalid option")
+
+// ErrHandlerFailed indicates that the SetHandler provided via Options
+// returned an error, stopping the scan.
+var ErrHandlerFailed = errors.New("set handler failed")
+
+// ErrPathNotExist indicates that a path provided to scan does not exist. It
+// also matches fs.ErrNotExist, which is used instead for paths missing from
+// a provided filesystem (see Options.FS); check for fs.ErrNotExist to match
+// both.
+var ErrPathNotExist = paths.ErrPathNotExist
+
+// SetHandler is implemented by types that act on each confirmed duplicate
+// file set as it is produced during a scan. Sets are produced from largest
+// file size to smallest. Returning a non-nil error stops the scan and the
+// error is returned from Scanner.Scan wrapped with ErrHandlerFailed.
+//
+// HandleSet is called from the goroutine running the scan; long-running
+// work should be handed off elsewhere to avoid slowing the scan.
+type SetHandler interface {
+	HandleSet(ctx context.Context, set Set) error
+}
+
+// SetHandlerFunc is an adapter allowing use of an ordinary function as a
+// SetHandler.
+type SetHandlerFunc func(ctx context.Context, set Set) error
+
+// HandleSet calls f(ctx, set).
+func (f SetHandlerFunc) HandleSet(ctx context.Context, set Set) error {
+	return f(ctx, set)
+}
+
+// Options controls the behavior of a Scanner.
+type Options struct {
+
+	// Paths is the list of paths to evaluate for duplicate files.
+	Paths []string
+
+	// Recursive indicates whether paths are crawled recursively or treated
+	// as single level directories.
+	Recursive bool
+
+	// MinSize is the minimum size in bytes that a file must be in order to
+	// be evaluated. Files smaller than this are skipped. The zero value
+	// evaluates all files, including empty files.
+	MinSize int64
+
+	// MinDuplicates is the number of identical files needed before those
+	// files are reported as a duplicate file set. If not specified,
+	// DefaultMinDuplicates is used.
+	MinDuplicates int
+
+	// IgnoreErrors indicates whether minor errors (e.g., an unreadable
+	// file) are ignored so that the scan can continue. The number of
+	// ignored errors is recorded in the Summary.
+	IgnoreErrors bool
+
+	// Walkers is the number of directories read concurrently when paths
+	// within the OS filesystem are crawled recursively. Values less than
+	// two crawl directories serially.
+	Walkers int
+
+	// Handler is an optional SetHandler called for each confirmed duplicate
+	// file set as soon as it is produced, before the scan completes. This
+	// allows custom policies (e.g., tagging, moving, database writes) to be
+	// applied without waiting for the full Result.
+	Handler SetHandler
+
+	// FS is an optional filesystem to scan instead of the OS filesystem
+	// (e.g., an in-memory filesystem, a zip archive or a custom backend).
+	// If set, Paths are interpreted as slash-separated paths within FS
+	// (see fs.ValidPath) and File paths are reported relative to FS.
+	FS fs.FS
+}
+
+// File represents a single file within a duplicate file set.
+type File struct {
+
+	// Path is the fully-qualified path to the file. If Options.FS was
+	// specified, this is the slash-separated path within that filesystem.
+	Path string
+
+	// Dir is the path to the directory containing the file.
+	Dir string
+
+	// Name is the base name of the file.
+	Name string
+
+	// Size is the size of the file in bytes.
+	Size int64
+
+	// ModTime is the last modification time of the file.
+	ModTime time.Time
+
+	// Checksum is the SHA256 checksum of the file contents.
+	Checksum string
+}
+
+// Set is a collection of files with identical content.
+type Set struct {
+
+	// Checksum is the SHA256 checksum shared by all files in the set.
+	Checksum string
+
+	// Size is the size in bytes of each file in the set.
+	Size int64
+
+	// Files is the list of files with identical content.
+	Files []File
+}
+
+// WastedSpace returns the number of bytes consumed by all but one file in
+// the set.
+func (s Set) WastedSpace() int64 {
+	if len(s.Files) < 2 {
+		return 0
+	}
+
+	return int64(len(s.Files)-1) * s.Size
+}
+
+// Summary is a collection of the metadata calculated from evaluating
+// duplicate files.
+type Summary struct {
+
+	// EvaluatedFiles is the number of files evaluated in the scan paths.
+	EvaluatedFiles int `json:"evaluated_files"`
+
+	// SizeMatchSets is the number of sets based on identical file size.
+	SizeMatchSets int `json:"size_match_sets"`
+
+	// SizeMatches is the number of files with an identical file size.
+	SizeMatches int `json:"size_matches"`
+
+	// HashedBytes is the combined size of files with an identical file
+	// size, read to generate checksums.
+	HashedBytes int64 `json:"hashed_bytes"`
+
+	// Sets is the number of sets based on identical file checksum.
+	Sets int `json:"sets"`
+
+	// Files is the number of files with an identical file checksum.
+	Files int `json:"files"`
+
+	// Duplicates is the number of non-original files across all sets.
+	Duplicates int `json:"duplicates"`
+
+	// WastedSpace is the number of bytes consumed by duplicate files.
+	WastedSpace int64 `json:"wasted_space"`
+
+	// IgnoredErrors is the number of errors ignored as requested.
+	IgnoredErrors int `json:"ignored_errors"`
+}
+
+// Result is the outcome of a completed scan.
+type Result struct {
+	sets    []Set
+	summary Summary
+}
+
+// Summary returns metadata calculated from evaluating duplicate files.
+func (r *Result) Summary() Summary {
+	return r.summary
+}
+
+// Len returns the number of duplicate file sets found.
+func (r *Result) Len() int {
+	return len(r.sets)
+}
+
+// Sets returns a copy of the duplicate file sets found. Sets are ordered by
+// wasted space (largest first) with ties broken by checksum. Files within
+// each set are ordered by path.
+func (r *Result) Sets() []Set {
+	sets := make([]Set, len(r.sets))
+	copy(sets, r.sets)
+
+	return sets
+}
+
+// Each calls fn for each duplicate file set in the same order as returned by
+// Sets. Iteration stops at the first non-nil error returned by fn and that
+// error is returned to the caller.
+func (r *Result) Each(fn func(Set) error) error {
+	for _, set := range r.sets {
+		if err := fn(set); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Scanner locates duplicate files using the provided Options.
+type Scanner struct {
+	opts Options
+}
+
+// NewScanner validates the provided Options and returns a Scanner ready for
+// use.
+func NewScanner(opts Options) (*Scanner, error) {
+
+	if len(opts.Paths) == 0 {
+		return nil, ErrNoPaths
+	}
+
+	for _, path := range opts.Paths {
+		if strings.TrimSpace(path) == "" {
+			return nil, fmt.Errorf("%w: empty path provided", ErrInvalidOption)
+		}
+
+		if opts.FS != nil && !fs.ValidPath(path) {
+			return nil, fmt.Errorf(
+				"%w: path %q is not valid for the provided filesystem",
+				ErrInvalidOption,
+				path,
+			)
+		}
+	}
+
+	if opts.MinSize < 0 {
+		return nil, fmt.Errorf("%w: minimum size must be 0 or greater", ErrInvalidOption)
+	}
+
+	switch {
+	case opts.MinDuplicates == 0:
+		opts.MinDuplicates = DefaultMinDuplicates
+	case opts.MinDuplicates < DefaultMinDuplicates:
+		return nil, fmt.Errorf(
+			"%w: minimum duplicates must be %d or greater",
+			ErrInvalidOption,
+			DefaultMinDuplicates,
+		)
+	}
+
+	// Guard against caller modifying the paths slice after validation.
+	opts.Paths = append([]string(nil), opts.Paths...)
+
+	return &Scanner{opts: opts}, nil
+}
+
+// Options returns the (validated) options used by the Scanner.
+func (s *Scanner) Options() Options {
+	opts := s.opts
+	opts.Paths = append([]string(nil), s.opts.Paths...)
+
+	return opts
+}
+
+// Scan evaluates the configured paths and returns the duplicate file sets
+// found. The provided context is checked between each group of
+// potential duplicates, allowing long-running scans to be cancelled. If a
+// SetHandler was provided via Options, it is called for each confirmed
+// duplicate file set as it is produced.
+func (s *Scanner) Scan(ctx context.Context) (*Result, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fileSizeIndex, ignoredErrors, err := s.newFileSizeIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate paths: %w", err)
+	}
+
+	summary := Summary{
+		EvaluatedFiles: fileSizeIndex.GetTotalFilesCount(),
+	}
+
+	fileSizeIndex.PruneFileSizeIndex(s.opts.MinDuplicates)
+
+	summary.SizeMatchSets = len(fileSizeIndex)
+	summary.SizeMatches = fileSizeIndex.GetTotalFilesCount()
+	summary.HashedBytes = fileSizeIndex.PendingChecksumBytes("")
+
+	var onSet matches.DuplicateSetFunc
+	if s.opts.Handler != nil {
+		onSet = func(checksum checksums.SHA256Checksum, fileMatches matches.FileMatches) error {
+			set := newSet(checksum, fileMatches)
+			if err := s.opts.Handler.HandleSet(ctx, set); err != nil {
+				return fmt.Errorf("%w: set %s: %w", ErrHandlerFailed, set.Checksum, err)
+			}
+
+			return nil
+		}
+	}
+
+	fileChecksumIndex, ignored, err := fileSizeIndex.ConfirmDuplicates(
+		ctx,
+		s.opts.IgnoreErrors,
+		s.opts.MinDuplicates,
+		onSet,
+	)
+	ignoredErrors += ignored
+	switch {
+	case errors.Is(err, ErrHandlerFailed):
+		return nil, err
+	case err != nil && ctx.Err() != nil:
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("failed to generate checksums: %w", err)
+	}
+
+	summary.Sets = len(fileChecksumIndex)
+	summary.Files = fileChecksumIndex.GetTotalFilesCount()
+	summary.Duplicates = fileChecksumIndex.GetDuplicateFilesCount()
+	summary.WastedSpace = fileChecksumIndex.GetWastedSpace()
+	summary.IgnoredErrors = ignoredErrors
+
+	return &Result{
+		sets:    newSets(fileChecksumIndex),
+		summary: summary,
+	}, nil
+}
+
+// newFileSizeIndex evaluates the configured paths, using the configured
+// filesystem if provided, and returns the combined index of files based on
+// size along with the number of ignored errors.
+func (s *Scanner) newFileSizeIndex() (matches.FileSizeIndex, int, error) {
+
+	if s.opts.FS == nil {
+		return matches.NewFileSizeIndex(
+			s.opts.Recursive,
+			s.opts.IgnoreErrors,
+			s.opts.MinSize,
+			s.opts.Walkers,
+			nil,
+			s.opts.Paths...,
+		)
+	}
+
+	combinedFileSizeIndex := make(matches.FileSizeIndex)
+	var ignoredErrors int
+
+	for _, path := range s.opts.Paths {
+		fileSizeIndex, ignored, err := matches.ProcessFS(
+			s.opts.FS,
+			path,
+			s.opts.Recursive,
+			s.opts.IgnoreErrors,
+			s.opts.MinSize,
+		)
+		ignoredErrors += ignored
+		if err != nil {
+			return nil, ignoredErrors, fmt.Errorf("failed to process path %q: %w", path, err)
+		}
+
+		combinedFileSizeIndex = matches.MergeFileSizeIndexes(combinedFileSizeIndex, fileSizeIndex)
+	}
+
+	return combinedFileSizeIndex, ignoredErrors, nil
+}
+
+// newSets converts the internal representation of duplicate file sets into
+// the public Set type, applying a stable order.
+func newSets(fi matches.FileChecksumIndex) []Set {
+
+	sets := make([]Set, 0, len(fi))
+
+	for checksum, fileMatches := range fi {
+		sets = append(sets, newSet(checksum, fileMatches))
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].WastedSpace() != sets[j].WastedSpace() {
+			return sets[i].WastedSpace() > sets[j].WastedSpace()
+		}
+
+		return sets[i].Checksum < sets[j].Checksum
+	})
+
+	return sets
+}
+
+// newSet converts the internal representation of a duplicate file set into
+// the public Set type with files ordered by path.
+func newSet(checksum checksums.SHA256Checksum, fileMatches matches.FileMatches) Set {
+
+	set := Set{
+		Checksum: checksum.String(),
+		Files:    make([]File, 0, len(fileMatches)),
+	}
+
+	for _, fm := range fileMatches {
+		set.Files = append(set.Files, newFile(fm))
+	}
+
+	if len(set.Files) > 0 {
+		set.Size = set.Files[0].Size
+	}
+
+	sort.Slice(set.Files, func(i, j int) bool {
+		return set.Files[i].Path < set.Files[j].Path
+	})
+
+	return set
+}
+
+// newFile converts the internal representation of a matched file into the
+// public File type.
+func newFile(fm matches.FileMatch) File {
+	return File{
+		Path:     fm.FullPath,
+		Dir:      fm.ParentDirectory(),
+		Name:     fm.Name(),
+		Size:     fm.Size(),
+		ModTime:  fm.ModTime(),
+		Checksum: fm.Checksum.String(),
+	}
+}